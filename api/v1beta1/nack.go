@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strings"
+	"time"
+)
+
+// RetryAfterPrefix is the prefix of the retry-after hint in the error field of a nack.
+// A consumer that is overloaded nacks an event with a hint such as "retry-after=30s"
+// to request that redelivery of the event is delayed by at least the specified duration.
+const RetryAfterPrefix = "retry-after="
+
+// SetRetryAfter adds a retry-after hint to the error message of the nack, preserving
+// any existing error message after the hint, e.g. "retry-after=30s; downstream busy".
+// If the nack already has a hint it is replaced.
+func (n *Nack) SetRetryAfter(delay time.Duration) {
+	hint := RetryAfterPrefix + delay.String()
+	if _, msg, ok := n.splitRetryAfter(); ok {
+		n.Error = msg
+	}
+
+	if n.Error != "" {
+		n.Error = hint + "; " + n.Error
+		return
+	}
+	n.Error = hint
+}
+
+// RetryAfter parses the retry-after hint from the error message of the nack, returning
+// false if there is no hint or the hint cannot be parsed.
+func (n *Nack) RetryAfter() (time.Duration, bool) {
+	delay, _, ok := n.splitRetryAfter()
+	return delay, ok
+}
+
+// Message returns the error message of the nack without the retry-after hint.
+func (n *Nack) Message() string {
+	if _, msg, ok := n.splitRetryAfter(); ok {
+		return msg
+	}
+	return n.Error
+}
+
+func (n *Nack) splitRetryAfter() (delay time.Duration, msg string, ok bool) {
+	if !strings.HasPrefix(n.GetError(), RetryAfterPrefix) {
+		return 0, "", false
+	}
+
+	hint, msg, _ := strings.Cut(strings.TrimPrefix(n.Error, RetryAfterPrefix), ";")
+	var err error
+	if delay, err = time.ParseDuration(strings.TrimSpace(hint)); err != nil || delay < 0 {
+		return 0, "", false
+	}
+	return delay, strings.TrimSpace(msg), true
+}
@@ -0,0 +1,173 @@
+package api
+
+// NackCategory groups nack codes by the party responsible for the failure so that
+// retry and alerting logic can handle related codes consistently.
+type NackCategory uint8
+
+const (
+	// NackUnknownFault is the category of unknown or unspecified nack codes.
+	NackUnknownFault NackCategory = iota
+
+	// NackClientFault codes indicate a problem with the event or the request (e.g. the
+	// topic does not exist or the event is too large) that will not be resolved by
+	// retrying without changes, or that the consumer was unable to handle the event.
+	NackClientFault
+
+	// NackServerFault codes indicate a transient problem on the Ensign server; the
+	// operation can be retried with backoff and alerts should be raised if it persists.
+	NackServerFault
+
+	// NackThrottling codes indicate that delivery was deferred, e.g. because the
+	// consumer is busy or timed out; the event will be redelivered and no action is
+	// required other than slowing down.
+	NackThrottling
+)
+
+// String returns a human readable name of the category.
+func (c NackCategory) String() string {
+	switch c {
+	case NackClientFault:
+		return "client-fault"
+	case NackServerFault:
+		return "server-fault"
+	case NackThrottling:
+		return "throttling"
+	default:
+		return "unknown"
+	}
+}
+
+// NackCodeInfo describes a nack code for humans and for retry logic. The Code is set
+// when the info is returned by Nack_Code.Info.
+type NackCodeInfo struct {
+	Code        Nack_Code
+	Category    NackCategory
+	Retryable   bool
+	Description string
+	Action      string
+}
+
+// Describes every nack code defined by the Ensign protocol; use Nack_Code.Info to
+// look up the description of a code.
+var nackCodes = map[Nack_Code]NackCodeInfo{
+	Nack_UNKNOWN: {
+		Category:    NackUnknownFault,
+		Description: "the event was not handled for an unknown reason",
+		Action:      "check the nack error message for details",
+	},
+	Nack_MAX_EVENT_SIZE_EXCEEDED: {
+		Category:    NackClientFault,
+		Description: "the event is larger than the maximum event size allowed by the server",
+		Action:      "reduce the size of the event data or split it into multiple events",
+	},
+	Nack_TOPIC_UNKNOWN: {
+		Category:    NackClientFault,
+		Description: "the topic does not exist or is not available to the stream",
+		Action:      "create the topic or check the topic name and the permissions of the API key",
+	},
+	Nack_TOPIC_ARCHIVED: {
+		Category:    NackClientFault,
+		Description: "the topic has been archived and is read-only",
+		Action:      "publish to a different topic or unarchive the topic",
+	},
+	Nack_TOPIC_DELETED: {
+		Category:    NackClientFault,
+		Description: "the topic has been deleted",
+		Action:      "publish to a different topic",
+	},
+	Nack_PERMISSION_DENIED: {
+		Category:    NackClientFault,
+		Description: "the API key does not have permission to perform the operation",
+		Action:      "use an API key with the required permissions for the topic",
+	},
+	Nack_CONSENSUS_FAILURE: {
+		Category:    NackServerFault,
+		Retryable:   true,
+		Description: "the Ensign replicas could not agree to commit the event",
+		Action:      "retry with backoff",
+	},
+	Nack_SHARDING_FAILURE: {
+		Category:    NackServerFault,
+		Retryable:   true,
+		Description: "the event could not be assigned to a shard of the topic",
+		Action:      "retry with backoff",
+	},
+	Nack_REDIRECT: {
+		Category:    NackServerFault,
+		Retryable:   true,
+		Description: "the event must be published to a different Ensign node",
+		Action:      "reconnect and retry the event",
+	},
+	Nack_INTERNAL: {
+		Category:    NackServerFault,
+		Retryable:   true,
+		Description: "an internal error occurred on the Ensign server",
+		Action:      "retry with backoff and contact support if the error persists",
+	},
+	Nack_UNPROCESSED: {
+		Category:    NackClientFault,
+		Retryable:   true,
+		Description: "the consumer could not process the event",
+		Action:      "the event will be redelivered; check the consumer logs for errors",
+	},
+	Nack_TIMEOUT: {
+		Category:    NackThrottling,
+		Retryable:   true,
+		Description: "the consumer did not handle the event in time",
+		Action:      "the event will be redelivered; scale up consumers if this persists",
+	},
+	Nack_UNHANDLED_MIMETYPE: {
+		Category:    NackClientFault,
+		Description: "the consumer cannot handle the mimetype of the event",
+		Action:      "publish the event with a mimetype supported by the consumer",
+	},
+	Nack_UNKNOWN_TYPE: {
+		Category:    NackClientFault,
+		Description: "the consumer does not recognize the type of the event",
+		Action:      "register the event type with the consumer or check the type of the event",
+	},
+	Nack_DELIVER_AGAIN_ANY: {
+		Category:    NackThrottling,
+		Retryable:   true,
+		Description: "the consumer deferred the event to be delivered again to any consumer",
+		Action:      "no action required, the event will be redelivered",
+	},
+	Nack_DELIVER_AGAIN_NOT_ME: {
+		Category:    NackThrottling,
+		Retryable:   true,
+		Description: "the consumer deferred the event to be delivered to a different consumer",
+		Action:      "no action required, the event will be redelivered to another consumer",
+	},
+}
+
+// Info returns the description of the nack code. Codes that are not defined by the
+// protocol are described as unknown.
+func (c Nack_Code) Info() NackCodeInfo {
+	info, ok := nackCodes[c]
+	if !ok {
+		info = nackCodes[Nack_UNKNOWN]
+	}
+	info.Code = c
+	return info
+}
+
+// Description returns a human readable description of the nack code.
+func (c Nack_Code) Description() string {
+	return c.Info().Description
+}
+
+// Action returns the recommended action that the client should take for the code.
+func (c Nack_Code) Action() string {
+	return c.Info().Action
+}
+
+// Category returns the category of the nack code, e.g. for alerting.
+func (c Nack_Code) Category() NackCategory {
+	return c.Info().Category
+}
+
+// Retryable returns true if the operation may succeed when retried or the event
+// will be redelivered without requiring changes by the client.
+func (c Nack_Code) Retryable() bool {
+	return c.Info().Retryable
+}
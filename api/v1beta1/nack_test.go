@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNackRetryAfter(t *testing.T) {
+	nack := &api.Nack{Code: api.Nack_UNPROCESSED}
+	_, ok := nack.RetryAfter()
+	require.False(t, ok, "expected no hint on a nack without an error message")
+
+	nack.SetRetryAfter(30 * time.Second)
+	require.Equal(t, "retry-after=30s", nack.Error)
+	require.Empty(t, nack.Message())
+
+	delay, ok := nack.RetryAfter()
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, delay)
+
+	// Replacing the hint should preserve the message
+	nack = &api.Nack{Error: "downstream busy"}
+	nack.SetRetryAfter(1500 * time.Millisecond)
+	require.Equal(t, "retry-after=1.5s; downstream busy", nack.Error)
+	require.Equal(t, "downstream busy", nack.Message())
+
+	nack.SetRetryAfter(time.Minute)
+	require.Equal(t, "retry-after=1m0s; downstream busy", nack.Error)
+
+	delay, ok = nack.RetryAfter()
+	require.True(t, ok)
+	require.Equal(t, time.Minute, delay)
+
+	// Invalid hints should not be parsed
+	for _, msg := range []string{"downstream busy", "retry-after=soon", "retry-after=-5s", "error: retry-after=5s"} {
+		nack = &api.Nack{Error: msg}
+		_, ok = nack.RetryAfter()
+		require.False(t, ok, "expected %q not to be parsed as a hint", msg)
+		require.Equal(t, msg, nack.Message())
+	}
+}
+
+func TestNackCodes(t *testing.T) {
+	// Every code defined by the protocol must be described
+	for value, name := range api.Nack_Code_name {
+		code := api.Nack_Code(value)
+		info := code.Info()
+		if code != api.Nack_UNKNOWN {
+			require.NotEqual(t, api.Nack_UNKNOWN.Description(), info.Description, "no description for nack code %s", name)
+		}
+		require.NotEmpty(t, info.Description, "no description for nack code %s", name)
+		require.NotEmpty(t, info.Action, "no action for nack code %s", name)
+		require.Equal(t, code, code.Info().Code)
+	}
+
+	require.Equal(t, api.NackClientFault, api.Nack_TOPIC_UNKNOWN.Category())
+	require.False(t, api.Nack_TOPIC_UNKNOWN.Retryable())
+	require.Equal(t, api.NackServerFault, api.Nack_INTERNAL.Category())
+	require.True(t, api.Nack_INTERNAL.Retryable())
+	require.Equal(t, api.NackThrottling, api.Nack_DELIVER_AGAIN_ANY.Category())
+	require.Equal(t, "throttling", api.NackThrottling.String())
+
+	// Undefined codes are described as unknown
+	code := api.Nack_Code(9999)
+	require.Equal(t, api.Nack_UNKNOWN.Description(), code.Description())
+	require.Equal(t, api.NackUnknownFault, code.Category())
+	require.Equal(t, code, code.Info().Code)
+}
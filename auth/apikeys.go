@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIKeysEP is the Quarterdeck endpoint used to manage the API keys of a project. The
+// endpoint requires an access token with the apikeys permissions, e.g. the token of a
+// user from a token source; if the client is not logged in ErrNoAPIKeys is returned.
+const APIKeysEP = "/v1/apikeys"
+
+// APIKeyDetail describes an API key managed by Quarterdeck. The ClientSecret is only
+// returned when the key is created; store it securely since it cannot be retrieved
+// again. This struct is also used to POST JSON requests to create API keys.
+type APIKeyDetail struct {
+	ID           string    `json:"id,omitempty"`
+	ClientID     string    `json:"client_id,omitempty"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	OrgID        string    `json:"org_id,omitempty"`
+	ProjectID    string    `json:"project_id"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	LastUsed     time.Time `json:"last_used,omitempty"`
+	Permissions  []string  `json:"permissions,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Created      time.Time `json:"created,omitempty"`
+	Modified     time.Time `json:"modified,omitempty"`
+}
+
+// APIKey returns the credentials of the key, which can be used to login the client.
+func (k *APIKeyDetail) APIKey() *APIKey {
+	return &APIKey{ClientID: k.ClientID, ClientSecret: k.ClientSecret}
+}
+
+// APIKeyQuery filters and paginates the API keys returned by ListAPIKeys.
+type APIKeyQuery struct {
+	ProjectID     string
+	PageSize      uint32
+	NextPageToken string
+}
+
+// APIKeyPage is a page of API keys; if NextPageToken is not empty, it can be used to
+// fetch the next page of results.
+type APIKeyPage struct {
+	APIKeys       []*APIKeyDetail `json:"apikeys"`
+	NextPageToken string          `json:"next_page_token,omitempty"`
+}
+
+// ListAPIKeys returns a page of the API keys of the project in the query. If the query
+// is nil, the keys of the project of the access token are returned.
+func (c *Client) ListAPIKeys(ctx context.Context, query *APIKeyQuery) (out *APIKeyPage, err error) {
+	params := url.Values{}
+	if query != nil {
+		if query.ProjectID != "" {
+			params.Set("project_id", query.ProjectID)
+		}
+		if query.PageSize > 0 {
+			params.Set("page_size", strconv.FormatUint(uint64(query.PageSize), 10))
+		}
+		if query.NextPageToken != "" {
+			params.Set("next_page_token", query.NextPageToken)
+		}
+	}
+
+	out = &APIKeyPage{}
+	if err = c.manageAPIKeys(ctx, http.MethodGet, APIKeysEP, params, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateAPIKey creates a new API key with the name, project, and permissions of the
+// key. The returned key includes the client secret, which is only available now.
+func (c *Client) CreateAPIKey(ctx context.Context, in *APIKeyDetail) (out *APIKeyDetail, err error) {
+	out = &APIKeyDetail{}
+	if err = c.manageAPIKeys(ctx, http.MethodPost, APIKeysEP, nil, in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RetrieveAPIKey returns the details of the API key with the specified ID.
+func (c *Client) RetrieveAPIKey(ctx context.Context, id string) (out *APIKeyDetail, err error) {
+	out = &APIKeyDetail{}
+	if err = c.manageAPIKeys(ctx, http.MethodGet, apikeyPath(id), nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RevokeAPIKey deletes the API key with the specified ID; the key can no longer be used
+// to authenticate, though access tokens already issued for it remain valid until they
+// expire.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) (err error) {
+	return c.manageAPIKeys(ctx, http.MethodDelete, apikeyPath(id), nil, nil, nil)
+}
+
+// RotateAPIKey replaces the API key with the specified ID with a new key that has the
+// same name, project, and permissions, then revokes the old key. Quarterdeck does not
+// rotate keys in place so the new key has a new ID. If the old key cannot be revoked,
+// the new key is returned along with the error so that its secret is not lost.
+func (c *Client) RotateAPIKey(ctx context.Context, id string) (out *APIKeyDetail, err error) {
+	var old *APIKeyDetail
+	if old, err = c.RetrieveAPIKey(ctx, id); err != nil {
+		return nil, err
+	}
+
+	in := &APIKeyDetail{
+		Name:        old.Name,
+		ProjectID:   old.ProjectID,
+		Permissions: old.Permissions,
+	}
+
+	if out, err = c.CreateAPIKey(ctx, in); err != nil {
+		return nil, err
+	}
+
+	if err = c.RevokeAPIKey(ctx, id); err != nil {
+		return out, fmt.Errorf("created api key %s but could not revoke api key %s: %w", out.ID, id, err)
+	}
+	return out, nil
+}
+
+// Makes an authenticated request to the API key endpoints, renewing the access token if
+// necessary. The lock is held for the duration of the request.
+func (c *Client) manageAPIKeys(ctx context.Context, method, path string, params url.Values, in, out interface{}) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, err = c.credentials(ctx); err != nil {
+		return err
+	}
+
+	var req *http.Request
+	if req, err = c.newRequest(ctx, method, path, in); err != nil {
+		return err
+	}
+
+	if len(params) > 0 {
+		req.URL.RawQuery = params.Encode()
+	}
+
+	_, err = c.do(req, out)
+	return err
+}
+
+func apikeyPath(id string) string {
+	return APIKeysEP + "/" + url.PathEscape(id)
+}
@@ -0,0 +1,95 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/rotationalio/go-ensign/auth"
+)
+
+func (s *authTestSuite) TestAPIKeys() {
+	require := s.Require()
+	ctx := context.Background()
+
+	// Clients that are not logged in cannot manage api keys
+	client, err := auth.New(s.srv.URL(), false)
+	require.NoError(err, "could not create auth client")
+	_, err = client.ListAPIKeys(ctx, nil)
+	require.ErrorIs(err, auth.ErrNoAPIKeys)
+
+	// Login with an api key that has permission to manage api keys
+	clientID, clientSecret := s.srv.Register(auth.PermissionAPIKeysCreate, auth.PermissionAPIKeysRead, auth.PermissionAPIKeysDelete)
+	_, err = client.Login(ctx, clientID, clientSecret)
+	require.NoError(err, "could not login")
+
+	const projectID = "01GQ7P8DNR9MR64RJR9D64FFNT"
+	key, err := client.CreateAPIKey(ctx, &auth.APIKeyDetail{
+		Name:        "publisher",
+		ProjectID:   projectID,
+		Permissions: []string{auth.PermissionPublisher},
+	})
+	require.NoError(err, "could not create api key")
+	require.NotEmpty(key.ID)
+	require.NotEmpty(key.ClientID)
+	require.NotEmpty(key.ClientSecret, "expected the client secret when the key is created")
+	require.Equal(clientID, key.CreatedBy)
+
+	// The new api key can be used to authenticate
+	tokens, err := client.Authenticate(ctx, key.APIKey())
+	require.NoError(err, "could not authenticate with the new api key")
+	require.NotEmpty(tokens.AccessToken)
+
+	// The client secret cannot be retrieved again
+	detail, err := client.RetrieveAPIKey(ctx, key.ID)
+	require.NoError(err, "could not retrieve api key")
+	require.Equal(key.Name, detail.Name)
+	require.Equal(key.ClientID, detail.ClientID)
+	require.Empty(detail.ClientSecret)
+
+	page, err := client.ListAPIKeys(ctx, &auth.APIKeyQuery{ProjectID: projectID})
+	require.NoError(err, "could not list api keys")
+	require.Len(page.APIKeys, 1)
+	require.Equal(key.ID, page.APIKeys[0].ID)
+
+	// Rotating the key creates a new key with the same permissions and revokes the old key
+	rotated, err := client.RotateAPIKey(ctx, key.ID)
+	require.NoError(err, "could not rotate api key")
+	require.NotEqual(key.ID, rotated.ID)
+	require.NotEqual(key.ClientID, rotated.ClientID)
+	require.NotEmpty(rotated.ClientSecret)
+	require.Equal(key.Name, rotated.Name)
+	require.Equal(key.ProjectID, rotated.ProjectID)
+	require.Equal(key.Permissions, rotated.Permissions)
+
+	_, err = client.Authenticate(ctx, key.APIKey())
+	require.Error(err, "expected the old api key to be revoked")
+
+	_, err = client.Authenticate(ctx, rotated.APIKey())
+	require.NoError(err, "could not authenticate with the rotated api key")
+
+	// Revoking the key prevents it from authenticating
+	err = client.RevokeAPIKey(ctx, rotated.ID)
+	require.NoError(err, "could not revoke api key")
+
+	_, err = client.Authenticate(ctx, rotated.APIKey())
+	require.Error(err, "expected the api key to be revoked")
+
+	page, err = client.ListAPIKeys(ctx, &auth.APIKeyQuery{ProjectID: projectID})
+	require.NoError(err, "could not list api keys")
+	require.Empty(page.APIKeys)
+
+	var serr *auth.StatusError
+	_, err = client.RetrieveAPIKey(ctx, rotated.ID)
+	require.True(errors.As(err, &serr), "expected a status error")
+	require.Equal(http.StatusNotFound, serr.StatusCode)
+
+	// API keys without the apikeys permissions cannot manage api keys
+	clientID, clientSecret = s.srv.Register(auth.PermissionPublisher)
+	_, err = client.Login(ctx, clientID, clientSecret)
+	require.NoError(err, "could not login")
+
+	_, err = client.ListAPIKeys(ctx, nil)
+	require.True(errors.As(err, &serr), "expected a status error")
+	require.Equal(http.StatusForbidden, serr.StatusCode)
+}
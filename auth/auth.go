@@ -16,21 +16,31 @@ by the Ensign client to maintain an authenticated connection to Ensign for long-
 processes (e.g. publishers and subscribers) and will make requests to Quarterdeck in an
 on-demand fashion to maintain authentication without logging out. The Ensign SDK must
 ensure that it requests credentials for every RPC call that it makes.
+
+Tokens can also be verified locally with a KeySet, which fetches and caches the public
+keys that Quarterdeck serves as a JSON Web Key Set (JWKS).
+
+Clients whose access token has the apikeys permissions can also create, list, rotate,
+and revoke the API keys of a project, e.g. to rotate credentials from operational tools.
 */
 package auth
 
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sync"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/rotationalio/go-ensign/backoff"
+	"github.com/rotationalio/go-ensign/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -41,16 +51,85 @@ const (
 	StatusEP       = "/v1/status"
 )
 
+// TracerName is the name of the tracer used to trace requests to Quarterdeck.
+const TracerName = "github.com/rotationalio/go-ensign/auth"
+
 // Client connects to the Quarterdeck authentication service in order to authenticate
 // API Keys and to refresh access tokens for Ensign access. The Client maintains the
 // API Keys and tokens so that it can hand out credentials in long running processes,
 // ensuring that the Ensign client can stay logged into Ensign for as long as possible.
 type Client struct {
-	endpoint *url.URL
-	api      *http.Client
-	apikey   *APIKey
-	tokens   *Tokens
-	insecure bool
+	sync.Mutex
+	endpoint  *url.URL
+	api       *http.Client
+	apikey    *APIKey
+	tokens    *Tokens
+	insecure  bool
+	backoff   backoff.Policy
+	tracer    trace.Tracer
+	cache     TokenCache
+	claims    *Claims
+	claimsTks string
+	window    time.Duration
+	onRefresh RefreshHook
+	source    TokenSource
+	stop      context.CancelFunc
+	stopped   chan struct{}
+}
+
+// Option configures the authentication client when it is created.
+type Option func(c *Client)
+
+// WithBackoff specifies the backoff policy used when retrying requests to Quarterdeck,
+// e.g. by WaitForReady. By default an exponential backoff with jitter is used.
+func WithBackoff(policy backoff.Policy) Option {
+	return func(c *Client) {
+		c.backoff = policy
+	}
+}
+
+// WithTracerProvider traces the requests made to Quarterdeck, e.g. to authenticate and
+// to refresh access tokens. By default requests are not traced.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracer = provider.Tracer(TracerName)
+	}
+}
+
+// WithTokenCache persists the tokens from Quarterdeck in the cache when the client
+// authenticates or refreshes its tokens so that the cached tokens can be used by Login
+// instead of reauthenticating, e.g. after the process restarts.
+func WithTokenCache(cache TokenCache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithTLSConfig uses the TLS configuration to connect to Quarterdeck, e.g. to present a
+// client certificate or to verify the server with a custom CA pool. By default the
+// system roots are used to verify Quarterdeck.
+func WithTLSConfig(conf *tls.Config) Option {
+	return func(c *Client) {
+		c.transport().TLSClientConfig = conf.Clone()
+	}
+}
+
+// WithProxy connects to Quarterdeck through the HTTP(S) or SOCKS5 proxy at the URL. By
+// default the proxy is determined by the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY
+// environment variables.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *Client) {
+		c.transport().Proxy = http.ProxyURL(proxy)
+	}
+}
+
+// Returns the transport of the http client so that it can be configured by options,
+// cloning the default transport if the client does not have its own transport yet.
+func (c *Client) transport() *http.Transport {
+	if c.api.Transport == nil {
+		c.api.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	return c.api.Transport.(*http.Transport)
 }
 
 // Create a new authentication client to connect to Quarterdeck. The authURL should be
@@ -58,10 +137,13 @@ type Client struct {
 // flag tells the client to create Ensign credentials that are insecure; e.g. not
 // requiring a TLS connection. The insecure flag should only be true in development.
 // After creating a Quarterdeck client, ensure to call Login() to prepare it to hand out
-// credentials to connect to Ensign.
-func New(authURL string, insecure bool) (client *Client, err error) {
+// credentials to connect to Ensign. Additional options such as the backoff policy can
+// be specified to configure the client.
+func New(authURL string, insecure bool, opts ...Option) (client *Client, err error) {
 	client = &Client{
 		insecure: insecure,
+		backoff:  backoff.Default(),
+		tracer:   trace.NewNoopTracerProvider().Tracer(TracerName),
 		api: &http.Client{
 			Transport:     nil,
 			CheckRedirect: nil,
@@ -77,6 +159,10 @@ func New(authURL string, insecure bool) (client *Client, err error) {
 		return nil, fmt.Errorf("could not create cookiejar: %w", err)
 	}
 
+	for _, opt := range opts {
+		opt(client)
+	}
+
 	return client, nil
 }
 
@@ -90,6 +176,14 @@ func New(authURL string, insecure bool) (client *Client, err error) {
 // the UnaryInterceptor and StreamInterceptor methods or call Credentials to get a
 // PerRPCCredentials CallOption to add to every RPC call.
 func (c *Client) Login(ctx context.Context, clientID, clientSecret string) (creds credentials.PerRPCCredentials, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	// If the client has a token source, the access tokens are fetched from the source.
+	if c.source != nil {
+		return c.login(ctx)
+	}
+
 	// Require both clientID and clientSecret
 	if clientID == "" || clientSecret == "" {
 		return nil, ErrIncompleteCreds
@@ -101,13 +195,27 @@ func (c *Client) Login(ctx context.Context, clientID, clientSecret string) (cred
 		ClientSecret: clientSecret,
 	}
 
-	// Authenticate and store the tokens on the client to cache for each call.
-	if c.tokens, err = c.Authenticate(ctx, c.apikey); err != nil {
-		return nil, err
+	// Use cached tokens if they are still valid, otherwise authenticate and store the
+	// tokens on the client to cache for each call.
+	if c.tokens = c.cachedTokens(); c.tokens == nil {
+		if c.tokens, err = c.Authenticate(ctx, c.apikey); err != nil {
+			return nil, err
+		}
+		c.cacheTokens()
+	}
+
+	return c.login(ctx)
+}
+
+// Starts the refresher if necessary and returns the credentials; the lock must be held.
+func (c *Client) login(ctx context.Context) (_ credentials.PerRPCCredentials, err error) {
+	// Renew the access token in the background if a refresh window is specified.
+	if c.window > 0 && c.stop == nil {
+		c.startRefresher()
 	}
 
 	// Return credentials for dial options.
-	return c.Credentials(ctx)
+	return c.credentials(ctx)
 }
 
 // Credentials returns the PerRPC credentials to make a gRPC request. If the tokens are
@@ -115,10 +223,18 @@ func (c *Client) Login(ctx context.Context, clientID, clientSecret string) (cred
 // is returned if the client is not logged in. This method should be called before every
 // Ensign RPC in order to ensure the RPC has valid credentials.
 func (c *Client) Credentials(ctx context.Context) (_ credentials.PerRPCCredentials, err error) {
-	// Check if tokens exist; if they don't exist, then authenticate.
-	if c.tokens == nil || c.tokens.AccessToken == "" || c.tokens.RefreshToken == "" {
-		// Tokens are missing or are partial, authenticate to get new tokens
-		if c.tokens, err = c.Authenticate(ctx, c.apikey); err != nil {
+	c.Lock()
+	defer c.Unlock()
+	return c.credentials(ctx)
+}
+
+// Returns the credentials, renewing the tokens if necessary; the lock must be held.
+func (c *Client) credentials(ctx context.Context) (_ credentials.PerRPCCredentials, err error) {
+	// Check if tokens exist; if they don't exist, then authenticate. Tokens from a
+	// token source do not have a refresh token.
+	if c.tokens == nil || c.tokens.AccessToken == "" || (c.tokens.RefreshToken == "" && c.source == nil) {
+		// Tokens are missing or are partial, renew them to get new tokens
+		if err = c.renew(ctx); err != nil {
 			return nil, err
 		}
 	}
@@ -133,24 +249,9 @@ func (c *Client) Credentials(ctx context.Context) (_ credentials.PerRPCCredentia
 
 	// If the access token is not valid, attempt to use the refresh token to validate.
 	if !accessValid {
-		var refreshValid bool
-		if refreshValid, err = c.tokens.RefreshValid(); err != nil {
-			// Returning an error is acceptable here because we checked if the refresh
-			// tokens were missing in an above step.
+		if err = c.renew(ctx); err != nil {
 			return nil, err
 		}
-
-		// If the refresh tokens are valid, use it to refresh the access token,
-		// otherwise reauthenticate using the credentials.
-		if refreshValid {
-			if c.tokens, err = c.Refresh(ctx, c.tokens); err != nil {
-				return nil, err
-			}
-		} else {
-			if c.tokens, err = c.Authenticate(ctx, c.apikey); err != nil {
-				return nil, err
-			}
-		}
 	}
 
 	// At this point we should have a valid access token one way or another ...
@@ -160,6 +261,33 @@ func (c *Client) Credentials(ctx context.Context) (_ credentials.PerRPCCredentia
 	}, nil
 }
 
+// Claims returns the claims of the current access token, e.g. the project ID and the
+// permissions of the API key. The claims are parsed without verifying the token since
+// the token was issued to the client by Quarterdeck; the parsed claims are cached until
+// the access token is renewed. An error is returned if the client is not logged in.
+func (c *Client) Claims() (_ *Claims, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.tokens == nil || c.tokens.AccessToken == "" {
+		return nil, ErrNoAPIKeys
+	}
+
+	if c.claims == nil || c.claimsTks != c.tokens.AccessToken {
+		claims := &Claims{}
+		if _, _, err = parser.ParseUnverified(c.tokens.AccessToken, claims); err != nil {
+			return nil, err
+		}
+		c.claims, c.claimsTks = claims, c.tokens.AccessToken
+	}
+
+	// Return a copy so that the cached claims cannot be modified by the caller.
+	claims := *c.claims
+	claims.Audience = append(jwt.ClaimStrings(nil), c.claims.Audience...)
+	claims.Permissions = append([]string(nil), c.claims.Permissions...)
+	return &claims, nil
+}
+
 // An interceptor that adds credentials on every unary request made by the gRPC client.
 func (c *Client) UnaryAuthenticate(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
 	var creds credentials.PerRPCCredentials
@@ -270,26 +398,40 @@ func (c *Client) WaitForReady(ctx context.Context) (err error) {
 		return nil
 	}
 
-	// Create exponential backoff ticker for retries
-	ticker := backoff.NewExponentialBackOff()
+	// Keep checking if Quarterdeck is ready until it responds, the backoff policy stops
+	// retrying, or the context expires.
+	return backoff.Retry(ctx, c.backoff(), checkReady)
+}
 
-	// Keep checking if Quarterdeck is ready until it responds or the context expires.
-	for {
-		// Execute the status request
-		if err = checkReady(); err == nil {
-			// Success - Quarterdeck is ready for requests!
-			return nil
-		}
+// Returns the tokens cached for the API key if the access token or the refresh token
+// is still valid, otherwise nil is returned and the client must authenticate.
+func (c *Client) cachedTokens() *Tokens {
+	if c.cache == nil || c.apikey == nil {
+		return nil
+	}
 
-		// Delay until the next backoff retry or the context expires
-		wait := time.After(ticker.NextBackOff())
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-wait:
-		}
+	tokens, err := c.cache.Get(c.apikey.ClientID)
+	if err != nil || tokens == nil || tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		return nil
+	}
+
+	if valid, err := tokens.AccessValid(); err == nil && valid {
+		return tokens
+	}
+
+	if valid, err := tokens.RefreshValid(); err == nil && valid {
+		return tokens
 	}
+	return nil
+}
 
+// Stores the current tokens in the cache. The cache is an optimization, so if the
+// tokens cannot be cached the client continues with the tokens in memory.
+func (c *Client) cacheTokens() {
+	if c.cache == nil || c.apikey == nil || c.tokens == nil || c.source != nil {
+		return
+	}
+	c.cache.Put(c.apikey.ClientID, c.tokens)
 }
 
 //===========================================================================
@@ -298,17 +440,23 @@ func (c *Client) WaitForReady(ctx context.Context) (err error) {
 
 // Reset removes the apikeys and tokens from the client (used for testing).
 func (c *Client) Reset() {
+	c.Lock()
+	defer c.Unlock()
 	c.apikey = nil
 	c.tokens = nil
 }
 
 // SetTokens allows the test suite to set the tokens on the client.
 func (c *Client) SetTokens(tokens *Tokens) {
+	c.Lock()
+	defer c.Unlock()
 	c.tokens = tokens
 }
 
 // SetAPIKey allows the test suite to set the apikey on the client.
 func (c *Client) SetAPIKey(key *APIKey) {
+	c.Lock()
+	defer c.Unlock()
 	c.apikey = key
 }
 
@@ -369,7 +517,18 @@ func (c *Client) newRequest(ctx context.Context, method, path string, data inter
 // Execute an http request against the server, perform error checking, and
 // deserialize the response data into the specified struct.
 func (c *Client) do(req *http.Request, data interface{}) (rep *http.Response, err error) {
-	if rep, err = c.api.Do(req); err != nil {
+	ctx, span := c.tracer.Start(req.Context(), "quarterdeck "+req.URL.Path, trace.Attr("http.method", req.Method), trace.Attr("http.url", req.URL.String()))
+	defer func() {
+		if rep != nil {
+			span.SetAttributes(trace.Attr("http.status_code", rep.StatusCode))
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
+	if rep, err = c.api.Do(req.WithContext(ctx)); err != nil {
 		return rep, fmt.Errorf("could not execute request: %s", err)
 	}
 	defer rep.Body.Close()
@@ -2,6 +2,13 @@ package auth_test
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
 	"regexp"
 	"testing"
 	"time"
@@ -9,6 +16,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/rotationalio/go-ensign/auth"
 	"github.com/rotationalio/go-ensign/auth/authtest"
+	"github.com/rotationalio/go-ensign/trace/tracetest"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -210,3 +218,277 @@ func (s *authTestSuite) TestStatus() {
 	require.Equal("ok", status.Status)
 	require.Equal("test", status.Version)
 }
+
+func (s *authTestSuite) TestClaims() {
+	require := s.Require()
+
+	_, err := s.auth.Claims()
+	require.ErrorIs(err, auth.ErrNoAPIKeys, "expected an error before login")
+
+	tokens := &auth.Tokens{}
+	claims := &authtest.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "testing"},
+		ProjectID:        "01HCG64Y1SMFQBW7A42SRV207A",
+		Permissions:      []string{"publisher"},
+	}
+	tokens.AccessToken, tokens.RefreshToken, err = s.srv.CreateTokenPair(claims)
+	require.NoError(err, "could not create tokens")
+	s.auth.SetTokens(tokens)
+
+	parsed, err := s.auth.Claims()
+	require.NoError(err, "could not parse claims")
+	require.Equal("01HCG64Y1SMFQBW7A42SRV207A", parsed.ProjectID)
+	require.Equal([]string{"publisher"}, parsed.Permissions)
+
+	// Modifying the returned claims should not modify the cached claims
+	parsed.Permissions[0] = "admin"
+	parsed, err = s.auth.Claims()
+	require.NoError(err, "could not parse claims")
+	require.True(parsed.HasPermission("publisher"))
+
+	// When the access token is renewed, the claims of the new token are returned
+	claims = &authtest.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "testing"},
+		ProjectID:        "01HCG64Y1SMFQBW7A42SRV207A",
+		Permissions:      []string{"publisher", "subscriber"},
+	}
+	tokens = &auth.Tokens{}
+	tokens.AccessToken, tokens.RefreshToken, err = s.srv.CreateTokenPair(claims)
+	require.NoError(err, "could not create tokens")
+	s.auth.SetTokens(tokens)
+
+	parsed, err = s.auth.Claims()
+	require.NoError(err, "could not parse claims")
+	require.Equal([]string{"publisher", "subscriber"}, parsed.Permissions)
+}
+
+func (s *authTestSuite) TestRefreshAhead() {
+	require := s.Require()
+
+	type refresh struct {
+		expires time.Time
+		err     error
+	}
+
+	// Refreshing the entire lifetime of the access token ahead renews it immediately
+	refreshes := make(chan refresh, 8)
+	hook := func(expires time.Time, err error) { refreshes <- refresh{expires, err} }
+	client, err := auth.New(s.srv.URL(), false, auth.WithRefreshAhead(authtest.AccessDuration), auth.WithRefreshHook(hook))
+	require.NoError(err, "could not create auth client")
+
+	clientID, clientSecret := s.srv.Register()
+	creds, err := client.Login(context.Background(), clientID, clientSecret)
+	require.NoError(err, "could not login with credentials")
+	require.Empty(refreshes, "expected login not to call the refresh hook")
+
+	select {
+	case rep := <-refreshes:
+		require.NoError(rep.err)
+		require.WithinDuration(time.Now().Add(authtest.AccessDuration), rep.expires, 5*time.Second)
+	case <-time.After(5 * time.Second):
+		require.Fail("expected the access token to be renewed in the background")
+	}
+
+	// The renewed access token should be handed out as credentials
+	other, err := client.Credentials(context.Background())
+	require.NoError(err, "could not fetch credentials")
+	require.False(creds.(*auth.Credentials).Equals(other.(*auth.Credentials)), "expected renewed credentials")
+
+	// Closing the client stops the refresher
+	require.NoError(client.Close())
+	for len(refreshes) > 0 {
+		<-refreshes
+	}
+	time.Sleep(1500 * time.Millisecond)
+	require.Empty(refreshes, "expected the refresher to be stopped")
+}
+
+func (s *authTestSuite) TestTokenSource() {
+	require := s.Require()
+
+	// Mint tokens that expire at the specified time with the authtest server
+	mint := func(expires time.Time) string {
+		claims := &authtest.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "on-behalf-of",
+				ExpiresAt: jwt.NewNumericDate(expires),
+			},
+			Permissions: []string{auth.PermissionPublisher},
+		}
+
+		tks, err := s.srv.Sign(s.srv.CreateToken(claims))
+		require.NoError(err, "could not sign token")
+		return tks
+	}
+
+	var calls int
+	source := auth.TokenSourceFunc(func(context.Context) (string, error) {
+		calls++
+		return mint(time.Now().Add(time.Hour + time.Duration(calls)*time.Second)), nil
+	})
+
+	client, err := auth.New(s.srv.URL(), false, auth.WithTokenSource(source))
+	require.NoError(err, "could not create auth client")
+
+	// The client ID and secret are not required to login with a token source
+	creds, err := client.Login(context.Background(), "", "")
+	require.NoError(err, "could not login with the token source")
+	require.Equal(1, calls, "expected login to fetch a token from the source")
+
+	claims, err := client.Claims()
+	require.NoError(err, "could not parse claims of the sourced token")
+	require.Equal("on-behalf-of", claims.Subject)
+	require.True(claims.HasPermission(auth.PermissionPublisher))
+
+	// The access token is reused until it expires
+	other, err := client.Credentials(context.Background())
+	require.NoError(err, "could not fetch credentials")
+	require.True(creds.(*auth.Credentials).Equals(other.(*auth.Credentials)))
+	require.Equal(1, calls, "expected the valid access token to be reused")
+
+	// Expired access tokens are replaced with a new token from the source
+	client.SetTokens(&auth.Tokens{AccessToken: mint(time.Now().Add(-time.Minute))})
+	other, err = client.Credentials(context.Background())
+	require.NoError(err, "could not fetch credentials")
+	require.False(creds.(*auth.Credentials).Equals(other.(*auth.Credentials)))
+	require.Equal(2, calls, "expected a new token from the source")
+
+	// Errors from the source are returned to the caller
+	client, err = auth.New(s.srv.URL(), false, auth.WithTokenSource(auth.TokenSourceFunc(func(context.Context) (string, error) {
+		return "", errors.New("identity provider unavailable")
+	})))
+	require.NoError(err, "could not create auth client")
+	_, err = client.Login(context.Background(), "", "")
+	require.EqualError(err, "identity provider unavailable")
+
+	// The source must return an access token
+	client, err = auth.New(s.srv.URL(), false, auth.WithTokenSource(auth.TokenSourceFunc(func(context.Context) (string, error) {
+		return "", nil
+	})))
+	require.NoError(err, "could not create auth client")
+	_, err = client.Credentials(context.Background())
+	require.ErrorIs(err, auth.ErrNoAccessToken)
+}
+
+func (s *authTestSuite) TestTLSConfig() {
+	require := s.Require()
+	srv := httptest.NewTLSServer(http.HandlerFunc(s.srv.Status))
+	defer srv.Close()
+
+	// The test server certificate is not signed by the system roots
+	client, err := auth.New(srv.URL, false)
+	require.NoError(err, "could not create auth client")
+	_, err = client.Status(context.Background())
+	require.Error(err, "expected the server certificate to be unverified")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	client, err = auth.New(srv.URL, false, auth.WithTLSConfig(&tls.Config{RootCAs: roots}))
+	require.NoError(err, "could not create auth client")
+
+	status, err := client.Status(context.Background())
+	require.NoError(err, "expected the server to be verified with the ca pool")
+	require.Equal("ok", status.Status)
+}
+
+func (s *authTestSuite) TestProxy() {
+	require := s.Require()
+
+	// The proxy receives the requests to the Quarterdeck host and forwards them
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		s.srv.Status(w, r)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client, err := auth.New("http://quarterdeck.test", true, auth.WithProxy(proxyURL))
+	require.NoError(err, "could not create auth client")
+
+	status, err := client.Status(context.Background())
+	require.NoError(err, "could not make status request through the proxy")
+	require.Equal("ok", status.Status)
+	require.Equal([]string{"http://quarterdeck.test/v1/status"}, proxied)
+}
+
+func (s *authTestSuite) TestTracing() {
+	require := s.Require()
+	recorder := tracetest.NewRecorder()
+	client, err := auth.New(s.srv.URL(), false, auth.WithTracerProvider(recorder))
+	require.NoError(err, "could not create auth client")
+
+	clientID, clientSecret := s.srv.Register()
+	_, err = client.Login(context.Background(), clientID, clientSecret)
+	require.NoError(err, "could not login with credentials")
+
+	spans := recorder.Find("quarterdeck " + auth.AuthenticateEP)
+	require.Len(spans, 1, "expected authenticate request to be traced")
+	require.True(spans[0].IsEnded())
+	require.Equal(200, spans[0].Attributes["http.status_code"])
+	require.Empty(spans[0].Errors)
+
+	// Failed requests should record the error on the span
+	recorder.Reset()
+	_, err = client.Login(context.Background(), "hacker", "password")
+	require.Error(err)
+
+	spans = recorder.Find("quarterdeck " + auth.AuthenticateEP)
+	require.Len(spans, 1, "expected authenticate request to be traced")
+	require.Equal(401, spans[0].Attributes["http.status_code"])
+	require.Len(spans[0].Errors, 1)
+}
+
+func (s *authTestSuite) TestTokenCache() {
+	require := s.Require()
+	ctx := context.Background()
+	cache := auth.NewFileTokenCache(filepath.Join(s.T().TempDir(), "tokens.json"))
+	clientID, clientSecret := s.srv.Register()
+
+	// The first login should authenticate and cache the tokens
+	recorder := tracetest.NewRecorder()
+	client, err := auth.New(s.srv.URL(), false, auth.WithTokenCache(cache), auth.WithTracerProvider(recorder))
+	require.NoError(err, "could not create auth client")
+
+	_, err = client.Login(ctx, clientID, clientSecret)
+	require.NoError(err, "could not login with credentials")
+	require.Len(recorder.Find("quarterdeck "+auth.AuthenticateEP), 1)
+
+	cached, err := cache.Get(clientID)
+	require.NoError(err, "expected tokens to be cached")
+
+	// Another client using the same cache should not reauthenticate
+	recorder.Reset()
+	other, err := auth.New(s.srv.URL(), false, auth.WithTokenCache(cache), auth.WithTracerProvider(recorder))
+	require.NoError(err, "could not create auth client")
+
+	creds, err := other.Login(ctx, clientID, clientSecret)
+	require.NoError(err, "could not login with cached tokens")
+	require.Empty(recorder.Spans(), "expected no requests to quarterdeck")
+
+	md, err := creds.GetRequestMetadata(ctx)
+	require.NoError(err)
+	require.Equal("Bearer "+cached.AccessToken, md["Authorization"])
+
+	// Expired tokens in the cache should not be used
+	expired := &authtest.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(time.Now().Add(-10 * time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-5 * time.Minute)),
+		},
+	}
+
+	atks, err := s.srv.Sign(s.srv.CreateToken(expired))
+	require.NoError(err, "could not create expired access token")
+	rtks, err := s.srv.Sign(s.srv.CreateToken(expired))
+	require.NoError(err, "could not create expired refresh token")
+	require.NoError(cache.Put(clientID, &auth.Tokens{AccessToken: atks, RefreshToken: rtks}))
+
+	_, err = other.Login(ctx, clientID, clientSecret)
+	require.NoError(err, "could not login with credentials")
+	require.Len(recorder.Find("quarterdeck "+auth.AuthenticateEP), 1, "expected expired tokens to be replaced")
+
+	cached, err = cache.Get(clientID)
+	require.NoError(err)
+	require.NotEqual(atks, cached.AccessToken, "expected new tokens to be cached")
+}
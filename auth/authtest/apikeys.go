@@ -0,0 +1,159 @@
+package authtest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign/auth"
+)
+
+// APIKeys lists the API keys of a project or creates a new API key. Keys that are
+// created can be used to authenticate with the server until they are revoked.
+func (s *Server) APIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		claims, ok := s.authorize(w, r, auth.PermissionAPIKeysRead)
+		if !ok {
+			return
+		}
+
+		projectID := r.URL.Query().Get("project_id")
+		if projectID == "" {
+			projectID = claims.ProjectID
+		}
+
+		out := &auth.APIKeyPage{APIKeys: make([]*auth.APIKeyDetail, 0)}
+		s.Lock()
+		for _, key := range s.keys {
+			if key.ProjectID == projectID {
+				detail := *key
+				out.APIKeys = append(out.APIKeys, &detail)
+			}
+		}
+		s.Unlock()
+
+		sort.Slice(out.APIKeys, func(i, j int) bool { return out.APIKeys[i].ID < out.APIKeys[j].ID })
+		JSON(w, http.StatusOK, out)
+	case http.MethodPost:
+		claims, ok := s.authorize(w, r, auth.PermissionAPIKeysCreate)
+		if !ok {
+			return
+		}
+
+		in := &auth.APIKeyDetail{}
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			Err(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if in.Name == "" {
+			Err(w, http.StatusBadRequest, errors.New("api key name is required"))
+			return
+		}
+
+		out := &auth.APIKeyDetail{
+			ID:          ulid.Make().String(),
+			Name:        in.Name,
+			OrgID:       claims.OrgID,
+			ProjectID:   in.ProjectID,
+			CreatedBy:   claims.Subject,
+			Permissions: in.Permissions,
+			Created:     time.Now().UTC(),
+		}
+		out.Modified = out.Created
+
+		if out.ProjectID == "" {
+			out.ProjectID = claims.ProjectID
+		}
+
+		out.ClientID, out.ClientSecret = s.Register(out.Permissions...)
+
+		// The client secret is not stored with the key so it cannot be retrieved again.
+		detail := *out
+		detail.ClientSecret = ""
+		s.Lock()
+		s.keys[out.ID] = &detail
+		s.Unlock()
+
+		JSON(w, http.StatusCreated, out)
+	default:
+		Err(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// APIKey retrieves or revokes the API key with the ID in the URL path.
+func (s *Server) APIKey(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, auth.APIKeysEP+"/")
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := s.authorize(w, r, auth.PermissionAPIKeysRead); !ok {
+			return
+		}
+
+		s.Lock()
+		key, ok := s.keys[id]
+		s.Unlock()
+
+		if !ok {
+			Err(w, http.StatusNotFound, errors.New("api key not found"))
+			return
+		}
+		JSON(w, http.StatusOK, key)
+	case http.MethodDelete:
+		if _, ok := s.authorize(w, r, auth.PermissionAPIKeysDelete); !ok {
+			return
+		}
+
+		s.Lock()
+		key, ok := s.keys[id]
+		if ok {
+			delete(s.keys, id)
+			delete(s.authn, key.ClientID)
+			delete(s.perms, key.ClientID)
+		}
+		s.Unlock()
+
+		if !ok {
+			Err(w, http.StatusNotFound, errors.New("api key not found"))
+			return
+		}
+		JSON(w, http.StatusOK, &auth.Reply{Success: true})
+	default:
+		Err(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// Verifies the bearer token of the request and checks that it has the permission,
+// writing an error response if the request is not authorized.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, permission string) (_ *Claims, ok bool) {
+	tks := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if tks == "" {
+		Err(w, http.StatusUnauthorized, errors.New("missing bearer token"))
+		return nil, false
+	}
+
+	claims, err := s.Verify(tks)
+	if err != nil {
+		Err(w, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	if !claims.HasPermission(permission) {
+		Err(w, http.StatusForbidden, errors.New("user does not have permission to perform this operation"))
+		return nil, false
+	}
+	return claims, true
+}
+
+// JSON writes the data as a JSON response with the specified status code.
+func JSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
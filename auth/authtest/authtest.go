@@ -15,10 +15,12 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign/auth"
 )
 
 const (
@@ -37,12 +39,15 @@ var (
 // Server implements an endpoint to host JWKS public keys and also provides simple
 // functionality to create access and refresh tokens that would be authenticated.
 type Server struct {
+	sync.Mutex
 	srv   *httptest.Server
 	mux   *http.ServeMux
 	url   *url.URL
 	key   *rsa.PrivateKey
 	keyID ulid.ULID
 	authn map[string]string
+	perms map[string][]string
+	keys  map[string]*auth.APIKeyDetail
 }
 
 // NewServer starts and returns a new authtest server. The caller should call Close
@@ -51,11 +56,16 @@ func NewServer() (s *Server, err error) {
 	// Setup routes for the mux
 	s = &Server{
 		authn: make(map[string]string),
+		perms: make(map[string][]string),
+		keys:  make(map[string]*auth.APIKeyDetail),
 	}
 	s.mux = http.NewServeMux()
 	s.mux.HandleFunc("/v1/status", s.Status)
 	s.mux.HandleFunc("/v1/authenticate", s.Authenticate)
 	s.mux.HandleFunc("/v1/refresh", s.Refresh)
+	s.mux.HandleFunc(auth.JWKSEP, s.JWKS)
+	s.mux.HandleFunc(auth.APIKeysEP, s.APIKeys)
+	s.mux.HandleFunc(auth.APIKeysEP+"/", s.APIKey)
 
 	// Setup httptest Server
 	s.srv = httptest.NewServer(s.mux)
@@ -83,7 +93,8 @@ func (s *Server) ResolveReference(u *url.URL) string {
 }
 
 // Register creates a clientID and clientSecret that can be used for authentication.
-func (s *Server) Register() (clientID, clientSecret string) {
+// The permissions are added to the claims of the access tokens issued to the client.
+func (s *Server) Register(permissions ...string) (clientID, clientSecret string) {
 	cidbuf := make([]byte, 9)
 	rand.Read(cidbuf)
 	clientID = base64.RawURLEncoding.EncodeToString(cidbuf)
@@ -92,7 +103,10 @@ func (s *Server) Register() (clientID, clientSecret string) {
 	rand.Read(csbuf)
 	clientSecret = base64.RawURLEncoding.EncodeToString(csbuf)
 
+	s.Lock()
 	s.authn[clientID] = clientSecret
+	s.perms[clientID] = permissions
+	s.Unlock()
 	return clientID, clientSecret
 }
 
@@ -105,7 +119,11 @@ func (s *Server) Authenticate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check credentials
+	s.Lock()
 	secret, ok := s.authn[creds["client_id"]]
+	permissions := s.perms[creds["client_id"]]
+	s.Unlock()
+
 	if !ok || secret != creds["client_secret"] {
 		Err(w, http.StatusUnauthorized, errors.New("invalid credentials"))
 		return
@@ -116,6 +134,7 @@ func (s *Server) Authenticate(w http.ResponseWriter, r *http.Request) {
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: creds["client_id"],
 		},
+		Permissions: permissions,
 	}
 
 	atks, rtks, err := s.CreateTokenPair(claims)
@@ -158,6 +177,11 @@ func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) {
 		Err(w, http.StatusUnauthorized, errors.New("invalid refresh token"))
 	}
 
+	// Refresh tokens do not carry the permissions so look them up for the client
+	s.Lock()
+	claims.Permissions = s.perms[claims.Subject]
+	s.Unlock()
+
 	// Create response
 	atks, rtks, err := s.CreateTokenPair(claims)
 	if err != nil {
@@ -175,6 +199,27 @@ func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(rep)
 }
 
+// JWKS serves the public key used to sign tokens as a JSON Web Key Set.
+func (s *Server) JWKS(w http.ResponseWriter, r *http.Request) {
+	key, err := auth.NewJWK(s.keyID.String(), &s.key.PublicKey)
+	if err != nil {
+		Err(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&auth.JWKS{Keys: []auth.JWK{key}})
+}
+
+// RotateKeys creates a new signing key, e.g. to test key rotation; tokens signed by the
+// previous key can no longer be verified with the key set served by the server.
+func (s *Server) RotateKeys() (err error) {
+	s.keyID = ulid.Make()
+	s.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	return err
+}
+
 func (s *Server) Status(w http.ResponseWriter, r *http.Request) {
 	status := map[string]string{
 		"status":  "ok",
@@ -254,6 +299,20 @@ func (s *Server) Sign(token *jwt.Token) (tks string, err error) {
 	return token.SignedString(s.key)
 }
 
+// Verify parses the access token and validates its signature, expiration, and audience,
+// returning the claims of the token. Refresh tokens cannot be verified as access tokens.
+func (s *Server) Verify(tks string) (claims *Claims, err error) {
+	claims = &Claims{}
+	if _, err = jwt.ParseWithClaims(tks, claims, s.keyFunc); err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyAudience(Audience, true) || claims.VerifyAudience(RefreshAudience, true) {
+		return nil, errors.New("token is not an access token")
+	}
+	return claims, nil
+}
+
 func (s *Server) keyFunc(token *jwt.Token) (key interface{}, err error) {
 	return &s.key.PublicKey, nil
 }
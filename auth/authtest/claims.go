@@ -1,11 +1,6 @@
 package authtest
 
-import "github.com/golang-jwt/jwt/v4"
+import "github.com/rotationalio/go-ensign/auth"
 
 // Claims implements Quarterdeck-like claims for use in testing the SDK client.
-type Claims struct {
-	jwt.RegisteredClaims
-	OrgID       string   `json:"org,omitempty"`
-	ProjectID   string   `json:"project,omitempty"`
-	Permissions []string `json:"permissions,omitempty"`
-}
+type Claims = auth.Claims
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenCache persists the access and refresh tokens handed out by Quarterdeck so that
+// they can be reused across process restarts or shared by short-lived processes such as
+// CLI invocations instead of reauthenticating every time. Tokens are keyed by the
+// client ID of the API key they were issued for. Get must return ErrCacheMiss if no
+// tokens are cached for the client ID.
+type TokenCache interface {
+	Get(clientID string) (*Tokens, error)
+	Put(clientID string, tokens *Tokens) error
+}
+
+// FileTokenCache stores tokens as JSON in a file that is only readable by the current
+// user. The file is replaced atomically on every write so that concurrent processes
+// sharing the cache never read a partially written file.
+type FileTokenCache struct {
+	sync.Mutex
+	path string
+}
+
+var _ TokenCache = &FileTokenCache{}
+
+// NewFileTokenCache creates a token cache that stores tokens in the file at the path.
+// The file and its parent directories are created on the first write.
+func NewFileTokenCache(path string) *FileTokenCache {
+	return &FileTokenCache{path: path}
+}
+
+// DefaultTokenCachePath returns the path of the token cache file in the user's cache
+// directory, e.g. ~/.cache/ensign/tokens.json on Linux.
+func DefaultTokenCachePath() (_ string, err error) {
+	var dir string
+	if dir, err = os.UserCacheDir(); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ensign", "tokens.json"), nil
+}
+
+// Path returns the path of the token cache file.
+func (c *FileTokenCache) Path() string {
+	return c.path
+}
+
+// Get the tokens cached for the client ID.
+func (c *FileTokenCache) Get(clientID string) (_ *Tokens, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	var tokens map[string]*Tokens
+	if tokens, err = c.load(); err != nil {
+		return nil, err
+	}
+
+	if cached, ok := tokens[clientID]; ok && cached != nil {
+		return cached, nil
+	}
+	return nil, ErrCacheMiss
+}
+
+// Put the tokens for the client ID into the cache, replacing any cached tokens.
+func (c *FileTokenCache) Put(clientID string, tokens *Tokens) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	var cache map[string]*Tokens
+	if cache, err = c.load(); err != nil {
+		return err
+	}
+	cache[clientID] = tokens
+
+	var data []byte
+	if data, err = json.MarshalIndent(cache, "", "  "); err != nil {
+		return fmt.Errorf("could not marshal token cache: %w", err)
+	}
+
+	dir := filepath.Dir(c.path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("could not create token cache directory: %w", err)
+	}
+
+	// Write to a temporary file and rename it to atomically replace the cache.
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(dir, ".tokens-*"); err != nil {
+		return fmt.Errorf("could not create token cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write token cache: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("could not write token cache: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("could not write token cache: %w", err)
+	}
+	return nil
+}
+
+// Load the cached tokens from disk; a missing file is an empty cache.
+func (c *FileTokenCache) load() (tokens map[string]*Tokens, err error) {
+	var data []byte
+	if data, err = os.ReadFile(c.path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string]*Tokens), nil
+		}
+		return nil, fmt.Errorf("could not read token cache: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("could not parse token cache: %w", err)
+	}
+
+	if tokens == nil {
+		tokens = make(map[string]*Tokens)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,69 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rotationalio/go-ensign/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ensign", "tokens.json")
+	cache := auth.NewFileTokenCache(path)
+	require.Equal(t, path, cache.Path())
+
+	// An empty cache should return a cache miss
+	_, err := cache.Get("client1")
+	require.ErrorIs(t, err, auth.ErrCacheMiss)
+
+	// Tokens should be cached by client ID
+	tokens := &auth.Tokens{AccessToken: "access1", RefreshToken: "refresh1"}
+	require.NoError(t, cache.Put("client1", tokens))
+	require.NoError(t, cache.Put("client2", &auth.Tokens{AccessToken: "access2", RefreshToken: "refresh2"}))
+
+	cached, err := cache.Get("client1")
+	require.NoError(t, err)
+	require.Equal(t, tokens.AccessToken, cached.AccessToken)
+	require.Equal(t, tokens.RefreshToken, cached.RefreshToken)
+
+	// Tokens should be shared with other caches using the same file
+	cached, err = auth.NewFileTokenCache(path).Get("client2")
+	require.NoError(t, err)
+	require.Equal(t, "access2", cached.AccessToken)
+
+	_, err = cache.Get("client3")
+	require.ErrorIs(t, err, auth.ErrCacheMiss)
+
+	// Putting tokens should replace the cached tokens
+	require.NoError(t, cache.Put("client1", &auth.Tokens{AccessToken: "access3", RefreshToken: "refresh3"}))
+	cached, err = cache.Get("client1")
+	require.NoError(t, err)
+	require.Equal(t, "access3", cached.AccessToken)
+
+	// The cache file should only be readable by the user
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// A corrupted cache file should return an error rather than a cache miss
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+	_, err = cache.Get("client1")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, auth.ErrCacheMiss)
+}
+
+func TestDefaultTokenCachePath(t *testing.T) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip("no user cache directory available")
+	}
+
+	path, err := auth.DefaultTokenCachePath()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "ensign", "tokens.json"), path)
+}
@@ -7,9 +7,14 @@ import (
 )
 
 var (
-	ErrIncompleteCreds = errors.New("both client id and secret are required")
-	ErrNoAPIKeys       = errors.New("no api keys available: must login the client first")
-	unsuccessful       = Reply{Success: false}
+	ErrIncompleteCreds   = errors.New("both client id and secret are required")
+	ErrNoAPIKeys         = errors.New("no api keys available: must login the client first")
+	ErrCacheMiss         = errors.New("no tokens cached for client id")
+	ErrInvalidJWK        = errors.New("invalid json web key")
+	ErrUnknownSigningKey = errors.New("token is signed by an unknown key")
+	ErrNoKeyID           = errors.New("token does not have a kid header")
+	ErrNoAccessToken     = errors.New("token source did not return an access token")
+	unsuccessful         = Reply{Success: false}
 )
 
 // StatusError decodes an error response from Quarterdeck.
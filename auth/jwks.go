@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// JWKSEP is the endpoint where Quarterdeck hosts the public keys that are used to
+// verify the signatures of the access and refresh tokens.
+const JWKSEP = "/.well-known/jwks.json"
+
+// DefaultKeySetTTL is how long the public keys are cached before they are refetched.
+const DefaultKeySetTTL = time.Hour
+
+// Signing algorithms that are accepted when verifying tokens with the key set.
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Claims are the claims of the access tokens issued by Quarterdeck, which include the
+// organization and project the API key belongs to and the permissions of the key.
+type Claims struct {
+	jwt.RegisteredClaims
+	OrgID       string   `json:"org,omitempty"`
+	ProjectID   string   `json:"project,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Permissions that Quarterdeck assigns to API keys and includes in the access tokens.
+const (
+	PermissionPublisher     = "publisher"
+	PermissionSubscriber    = "subscriber"
+	PermissionTopicsCreate  = "topics:create"
+	PermissionTopicsRead    = "topics:read"
+	PermissionTopicsEdit    = "topics:edit"
+	PermissionTopicsDestroy = "topics:destroy"
+	PermissionMetricsRead   = "metrics:read"
+	PermissionProjectsRead  = "projects:read"
+	PermissionAPIKeysCreate = "apikeys:create"
+	PermissionAPIKeysRead   = "apikeys:read"
+	PermissionAPIKeysDelete = "apikeys:delete"
+)
+
+// HasPermission returns true if the claims include the specified permission.
+func (c *Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// JWKS is a JSON Web Key Set as served by Quarterdeck.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a JSON Web Key; only the fields of RSA and EC public keys are supported.
+type JWK struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// NewJWK creates a JSON Web Key from an RSA or ECDSA public key with the specified ID.
+func NewJWK(keyID string, key crypto.PublicKey) (_ JWK, err error) {
+	enc := base64.RawURLEncoding
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyID:   keyID,
+			KeyType: "RSA",
+			Use:     "sig",
+			N:       enc.EncodeToString(pub.N.Bytes()),
+			E:       enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			KeyID:   keyID,
+			KeyType: "EC",
+			Use:     "sig",
+			Curve:   pub.Curve.Params().Name,
+			X:       enc.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:       enc.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("%w: unsupported key type %T", ErrInvalidJWK, key)
+	}
+}
+
+// PublicKey parses the public key from the JSON Web Key.
+func (k JWK) PublicKey() (_ crypto.PublicKey, err error) {
+	switch k.KeyType {
+	case "RSA":
+		var n, e []byte
+		if n, err = decodeJWKField(k.N); err != nil {
+			return nil, err
+		}
+		if e, err = decodeJWKField(k.E); err != nil {
+			return nil, err
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: rsa exponent is too large", ErrInvalidJWK)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: unsupported curve %q", ErrInvalidJWK, k.Curve)
+		}
+
+		var x, y []byte
+		if x, err = decodeJWKField(k.X); err != nil {
+			return nil, err
+		}
+		if y, err = decodeJWKField(k.Y); err != nil {
+			return nil, err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: point is not on curve %s", ErrInvalidJWK, k.Curve)
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %q", ErrInvalidJWK, k.KeyType)
+	}
+}
+
+func decodeJWKField(field string) (_ []byte, err error) {
+	var data []byte
+	if data, err = base64.RawURLEncoding.DecodeString(field); err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("%w: could not decode key parameter", ErrInvalidJWK)
+	}
+	return data, nil
+}
+
+// JWKS fetches the public keys that Quarterdeck uses to sign tokens.
+func (c *Client) JWKS(ctx context.Context) (keys *JWKS, err error) {
+	var req *http.Request
+	c.Lock()
+	req, err = c.newRequest(ctx, http.MethodGet, JWKSEP, nil)
+	c.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	keys = &JWKS{}
+	if _, err = c.do(req, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// KeySet retrieves the public keys from Quarterdeck and caches them so that tokens can
+// be verified locally, e.g. by servers that accept Ensign access tokens. The keys are
+// refetched when the cache expires or when a token is signed by an unknown key, so
+// that the key set picks up keys that are rotated by Quarterdeck. A KeySet is safe for
+// concurrent use.
+type KeySet struct {
+	sync.RWMutex
+	client  *Client
+	ttl     time.Duration
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// KeySet returns a key set that fetches the public keys from the Quarterdeck server of
+// the client and caches them for the ttl; if ttl is zero, DefaultKeySetTTL is used.
+func (c *Client) KeySet(ttl time.Duration) *KeySet {
+	if ttl <= 0 {
+		ttl = DefaultKeySetTTL
+	}
+	return &KeySet{client: c, ttl: ttl}
+}
+
+// Key returns the public key with the specified key ID, fetching the keys from
+// Quarterdeck if the cache has expired or if the key is not in the cache.
+func (k *KeySet) Key(ctx context.Context, keyID string) (_ crypto.PublicKey, err error) {
+	k.RLock()
+	key, ok := k.keys[keyID]
+	expired := time.Since(k.fetched) > k.ttl
+	k.RUnlock()
+
+	if ok && !expired {
+		return key, nil
+	}
+
+	if err = k.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	k.RLock()
+	defer k.RUnlock()
+	if key, ok = k.keys[keyID]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSigningKey, keyID)
+	}
+	return key, nil
+}
+
+// Refresh fetches the public keys from Quarterdeck, replacing the cached keys. Keys
+// that cannot be parsed are skipped so that one bad key does not prevent verification.
+func (k *KeySet) Refresh(ctx context.Context) (err error) {
+	var jwks *JWKS
+	if jwks, err = k.client.JWKS(ctx); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		if key, err = jwk.PublicKey(); err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	k.Lock()
+	k.keys = keys
+	k.fetched = time.Now()
+	k.Unlock()
+	return nil
+}
+
+// Verify parses the token and verifies its signature using the public key identified
+// by the kid header of the token, returning the claims if the token is valid and has
+// not expired. Callers should also check the audience and permissions of the claims.
+func (k *KeySet) Verify(ctx context.Context, tks string) (_ *Claims, err error) {
+	claims := &Claims{}
+	parser := &jwt.Parser{ValidMethods: validMethods}
+	if _, err = parser.ParseWithClaims(tks, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		if keyID == "" {
+			return nil, ErrNoKeyID
+		}
+		return k.Key(ctx, keyID)
+	}); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
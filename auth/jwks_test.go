@@ -0,0 +1,118 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rotationalio/go-ensign/auth"
+	"github.com/rotationalio/go-ensign/auth/authtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWK(t *testing.T) {
+	rsakey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwk, err := auth.NewJWK("rsa", &rsakey.PublicKey)
+	require.NoError(t, err, "could not create rsa jwk")
+	require.Equal(t, "RSA", jwk.KeyType)
+	require.Equal(t, "AQAB", jwk.E)
+
+	pub, err := jwk.PublicKey()
+	require.NoError(t, err, "could not parse rsa jwk")
+	require.True(t, rsakey.PublicKey.Equal(pub))
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		eckey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		require.NoError(t, err)
+
+		jwk, err := auth.NewJWK("ec", &eckey.PublicKey)
+		require.NoError(t, err, "could not create ec jwk")
+		require.Equal(t, curve.Params().Name, jwk.Curve)
+
+		pub, err := jwk.PublicKey()
+		require.NoError(t, err, "could not parse ec jwk")
+		require.True(t, eckey.PublicKey.Equal(pub))
+	}
+
+	// Invalid keys should not be parsed
+	invalid := []auth.JWK{
+		{KeyType: "oct"},
+		{KeyType: "RSA", E: "AQAB"},
+		{KeyType: "RSA", N: "!!", E: "AQAB"},
+		{KeyType: "EC", Curve: "P-224"},
+		{KeyType: "EC", Curve: "P-256", X: "AQAB", Y: "AQAB"},
+	}
+	for _, jwk := range invalid {
+		_, err := jwk.PublicKey()
+		require.ErrorIs(t, err, auth.ErrInvalidJWK)
+	}
+
+	_, err = auth.NewJWK("ed", "not a key")
+	require.ErrorIs(t, err, auth.ErrInvalidJWK)
+}
+
+func TestKeySet(t *testing.T) {
+	srv, err := authtest.NewServer()
+	require.NoError(t, err, "could not create authtest server")
+	defer srv.Close()
+
+	client, err := auth.New(srv.URL(), false)
+	require.NoError(t, err, "could not create auth client")
+
+	jwks, err := client.JWKS(context.Background())
+	require.NoError(t, err, "could not fetch jwks")
+	require.Len(t, jwks.Keys, 1)
+
+	sign := func(claims *authtest.Claims) string {
+		tks, err := srv.Sign(srv.CreateAccessToken(claims))
+		require.NoError(t, err, "could not sign token")
+		return tks
+	}
+
+	keys := client.KeySet(0)
+	tks := sign(&authtest.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "testing"},
+		ProjectID:        "01HCG64Y1SMFQBW7A42SRV207A",
+		Permissions:      []string{"publisher", "subscriber"},
+	})
+
+	claims, err := keys.Verify(context.Background(), tks)
+	require.NoError(t, err, "could not verify token")
+	require.Equal(t, "testing", claims.Subject)
+	require.Equal(t, "01HCG64Y1SMFQBW7A42SRV207A", claims.ProjectID)
+	require.True(t, claims.HasPermission("publisher"))
+	require.False(t, claims.HasPermission("topics:destroy"))
+
+	// Tampered tokens should not be verified
+	_, err = keys.Verify(context.Background(), tks[:len(tks)-4]+"AAAA")
+	require.Error(t, err, "expected tampered token to be invalid")
+
+	// Expired tokens should not be verified
+	expired, err := srv.Sign(srv.CreateToken(&authtest.Claims{RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}}))
+	require.NoError(t, err)
+	_, err = keys.Verify(context.Background(), expired)
+	require.Error(t, err, "expected expired token to be invalid")
+
+	// Unsigned tokens cannot be verified
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, &authtest.Claims{}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = keys.Verify(context.Background(), unsigned)
+	require.Error(t, err, "expected unsigned token to be invalid")
+
+	// When the keys are rotated, the key set fetches the new keys
+	require.NoError(t, srv.RotateKeys())
+	claims, err = keys.Verify(context.Background(), sign(&authtest.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "rotated"}}))
+	require.NoError(t, err, "could not verify token signed by the rotated key")
+	require.Equal(t, "rotated", claims.Subject)
+
+	// Tokens signed by the previous key are now signed by an unknown key
+	_, err = keys.Verify(context.Background(), tks)
+	require.ErrorIs(t, err, auth.ErrUnknownSigningKey)
+}
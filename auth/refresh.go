@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/rotationalio/go-ensign/backoff"
+)
+
+// The minimum time the refresher waits between renewing tokens, which prevents the
+// refresher from continuously renewing tokens if the refresh window is longer than the
+// lifetime of the access token.
+const minRefreshInterval = time.Second
+
+// RefreshHook is called every time the client renews its access token, either in the
+// background or when credentials are requested for an RPC, with the expiration time of
+// the new access token or the error if the tokens could not be renewed. The hook is
+// called synchronously while the client is locked, so it must not block or call the
+// client; it is intended for observability, e.g. logging and metrics.
+type RefreshHook func(expires time.Time, err error)
+
+// WithRefreshAhead renews the access token in a background go routine the window
+// before the token expires, so that RPCs do not have to wait for the tokens to be
+// refreshed after the access token expires. The refresher is started by Login and is
+// stopped by Close. By default tokens are only refreshed when credentials are needed.
+func WithRefreshAhead(window time.Duration) Option {
+	return func(c *Client) {
+		c.window = window
+	}
+}
+
+// WithRefreshHook registers a hook that is called every time the client renews its
+// access token (see RefreshHook).
+func WithRefreshHook(hook RefreshHook) Option {
+	return func(c *Client) {
+		c.onRefresh = hook
+	}
+}
+
+// Close stops the background refresher if it is running. The client can still hand out
+// credentials after it is closed, refreshing tokens when they are needed.
+func (c *Client) Close() error {
+	c.Lock()
+	stop, stopped := c.stop, c.stopped
+	c.stop, c.stopped = nil, nil
+	c.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+	return nil
+}
+
+// Starts the background refresher; the lock must be held.
+func (c *Client) startRefresher() {
+	var ctx context.Context
+	ctx, c.stop = context.WithCancel(context.Background())
+	c.stopped = make(chan struct{})
+	go c.refresher(ctx, c.stopped)
+}
+
+// Renews the access token the refresh window before it expires until the context is
+// canceled. If the tokens cannot be renewed the refresher retries using the backoff
+// policy of the client; if the backoff stops the refresher waits until the next window.
+func (c *Client) refresher(ctx context.Context, stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := c.backoff()
+
+	for {
+		wait := c.untilRefresh()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		c.Lock()
+		err := c.renew(ctx)
+		c.Unlock()
+
+		if err == nil {
+			ticker.Reset()
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Retry after the backoff interval or at the next window if the backoff stops.
+		if err = backoff.Wait(ctx, ticker); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			ticker.Reset()
+		}
+	}
+}
+
+// Returns how long to wait until the access token should be renewed.
+func (c *Client) untilRefresh() time.Duration {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.tokens == nil || c.tokens.AccessToken == "" {
+		return c.window
+	}
+
+	expires, err := ExpiresAt(c.tokens.AccessToken)
+	if err != nil {
+		return c.window
+	}
+
+	if wait := time.Until(expires) - c.window; wait > minRefreshInterval {
+		return wait
+	}
+	return minRefreshInterval
+}
+
+// Renews the tokens using the token source if the client has one, using the refresh
+// token if it is valid, or otherwise by authenticating with the API key, caching the new
+// tokens and calling the refresh hook. The lock must be held.
+func (c *Client) renew(ctx context.Context) (err error) {
+	var refreshValid bool
+	if c.source == nil && c.tokens != nil && c.tokens.RefreshToken != "" {
+		if refreshValid, err = c.tokens.RefreshValid(); err != nil {
+			c.refreshed(err)
+			return err
+		}
+	}
+
+	// If the refresh token is valid, use it to refresh the access token, otherwise
+	// reauthenticate using the credentials.
+	var tokens *Tokens
+	switch {
+	case c.source != nil:
+		tokens, err = c.sourceTokens(ctx)
+	case refreshValid:
+		tokens, err = c.Refresh(ctx, c.tokens)
+	default:
+		tokens, err = c.Authenticate(ctx, c.apikey)
+	}
+
+	if err != nil {
+		c.refreshed(err)
+		return err
+	}
+
+	c.tokens = tokens
+	c.cacheTokens()
+	c.refreshed(nil)
+	return nil
+}
+
+// Calls the refresh hook with the expiration of the current access token or the error
+// if the tokens could not be renewed. The lock must be held.
+func (c *Client) refreshed(err error) {
+	if c.onRefresh == nil {
+		return
+	}
+
+	var expires time.Time
+	if err == nil {
+		expires, _ = ExpiresAt(c.tokens.AccessToken)
+	}
+	c.onRefresh(expires, err)
+}
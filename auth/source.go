@@ -0,0 +1,48 @@
+package auth
+
+import "context"
+
+// TokenSource supplies access tokens for Ensign RPCs in place of the Quarterdeck API key
+// flow, e.g. for deployments that mint Ensign-compatible tokens from their own identity
+// provider or that act on behalf of another user. The client calls Token when it does
+// not have an access token or when the access token has expired, so the source does not
+// need to cache tokens itself. The returned token must be a JWT with an expiration.
+type TokenSource interface {
+	Token(ctx context.Context) (accessToken string, err error)
+}
+
+// TokenSourceFunc is an adapter to allow ordinary functions to be used as token sources.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// WithTokenSource uses the token source to get access tokens instead of authenticating
+// with Quarterdeck; the client ID and secret passed to Login are ignored and the tokens
+// are not cached in the token cache. Background refreshes with WithRefreshAhead request
+// a new access token from the source before the current access token expires.
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) {
+		c.source = source
+	}
+}
+
+// Requests a new access token from the token source; the lock must be held.
+func (c *Client) sourceTokens(ctx context.Context) (_ *Tokens, err error) {
+	var atks string
+	if atks, err = c.source.Token(ctx); err != nil {
+		return nil, err
+	}
+
+	if atks == "" {
+		return nil, ErrNoAccessToken
+	}
+
+	tokens := &Tokens{AccessToken: atks}
+	if _, err = tokens.AccessValid(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
@@ -0,0 +1,280 @@
+/*
+Package backoff provides the retry delay policies used throughout the Ensign SDK, e.g.
+when waiting for Quarterdeck to be ready or when waiting for a dropped connection to
+Ensign to be re-established by a publish or subscribe stream. A single abstraction is
+used so that retry behavior is consistent and can be tuned from the client options.
+*/
+package backoff
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Stop is returned by NextBackOff to indicate that no more retries should be made.
+const Stop time.Duration = -1
+
+// Default values for the exponential backoff.
+const (
+	DefaultInitialInterval     = 500 * time.Millisecond
+	DefaultRandomizationFactor = 0.5
+	DefaultMultiplier          = 1.5
+	DefaultMaxInterval         = 30 * time.Second
+	DefaultMaxElapsedTime      = 5 * time.Minute
+)
+
+// Backoff computes the delay before the next retry of an operation. Backoffs are
+// stateful and should not be shared between concurrent retry loops; use a Policy to
+// create a new Backoff for each retry loop.
+type Backoff interface {
+	// NextBackOff returns the duration to wait before the next retry or Stop if no
+	// more retries should be attempted.
+	NextBackOff() time.Duration
+
+	// Reset the backoff to its initial state.
+	Reset()
+}
+
+// Policy creates a new Backoff for each retry loop so that retry state is not shared.
+type Policy func() Backoff
+
+// Exponential returns a Policy that creates exponential backoffs with the specified
+// initial interval, maximum interval and maximum elapsed time along with the default
+// multiplier and randomization factor. A zero max elapsed time means retry forever.
+func Exponential(initial, maxInterval, maxElapsed time.Duration) Policy {
+	return func() Backoff {
+		b := NewExponentialBackOff()
+		b.InitialInterval = initial
+		b.MaxInterval = maxInterval
+		b.MaxElapsedTime = maxElapsed
+		b.Reset()
+		return b
+	}
+}
+
+// Default returns a Policy that creates exponential backoffs with the default values.
+func Default() Policy {
+	return func() Backoff {
+		return NewExponentialBackOff()
+	}
+}
+
+// Config describes an exponential backoff policy so that retries can be tuned, e.g. to
+// reconnect for longer on flaky networks. Use DefaultConfig as a starting point since
+// the zero value of each field is used as is. If both MaxElapsedTime and MaxRetries are
+// zero the policy retries forever.
+type Config struct {
+	// The interval before the first retry.
+	InitialInterval time.Duration
+
+	// The maximum interval between retries; zero means the interval is not capped.
+	MaxInterval time.Duration
+
+	// The interval is multiplied by the multiplier after every retry.
+	Multiplier float64
+
+	// The randomization factor used to add jitter to each interval, between 0 and 1.
+	Jitter float64
+
+	// Stop retrying after the elapsed time or number of retries; zero means no limit.
+	MaxElapsedTime time.Duration
+	MaxRetries     int
+}
+
+// DefaultConfig returns the configuration of the default exponential backoff.
+func DefaultConfig() Config {
+	return Config{
+		InitialInterval: DefaultInitialInterval,
+		MaxInterval:     DefaultMaxInterval,
+		Multiplier:      DefaultMultiplier,
+		Jitter:          DefaultRandomizationFactor,
+		MaxElapsedTime:  DefaultMaxElapsedTime,
+	}
+}
+
+// Validate returns an error if the configuration cannot be used to create backoffs.
+func (c Config) Validate() error {
+	switch {
+	case c.InitialInterval <= 0:
+		return fmt.Errorf("%w: initial interval must be greater than zero", ErrInvalidConfig)
+	case c.MaxInterval < 0:
+		return fmt.Errorf("%w: max interval cannot be negative", ErrInvalidConfig)
+	case c.Multiplier < 1:
+		return fmt.Errorf("%w: multiplier must be at least 1", ErrInvalidConfig)
+	case c.Jitter < 0 || c.Jitter > 1:
+		return fmt.Errorf("%w: jitter must be between 0 and 1", ErrInvalidConfig)
+	case c.MaxElapsedTime < 0:
+		return fmt.Errorf("%w: max elapsed time cannot be negative", ErrInvalidConfig)
+	case c.MaxRetries < 0:
+		return fmt.Errorf("%w: max retries cannot be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Policy returns a Policy that creates exponential backoffs with the configuration.
+// The configuration should be validated first; Policy does not check it.
+func (c Config) Policy() Policy {
+	return func() Backoff {
+		b := NewExponentialBackOff()
+		b.InitialInterval = c.InitialInterval
+		b.MaxInterval = c.MaxInterval
+		b.Multiplier = c.Multiplier
+		b.RandomizationFactor = c.Jitter
+		b.MaxElapsedTime = c.MaxElapsedTime
+		b.MaxRetries = c.MaxRetries
+		b.Reset()
+		return b
+	}
+}
+
+// ExponentialBackOff increases the interval between retries by the multiplier on every
+// call to NextBackOff until the max interval is reached. Jitter is added to each
+// interval using the randomization factor such that the actual interval is in the range
+// [interval * (1 - factor), interval * (1 + factor)]. Once the max elapsed time since
+// the backoff was created or reset has passed or NextBackOff has been called max
+// retries times, NextBackOff returns Stop.
+type ExponentialBackOff struct {
+	InitialInterval     time.Duration
+	RandomizationFactor float64
+	Multiplier          float64
+	MaxInterval         time.Duration
+	MaxElapsedTime      time.Duration
+	MaxRetries          int
+
+	// Clock returns the current time used to compute the elapsed time; if nil then
+	// time.Now is used. It is primarily used to make tests deterministic.
+	Clock func() time.Time
+
+	mu       sync.Mutex
+	current  time.Duration
+	start    time.Time
+	retries  int
+	jitter   *rand.Rand
+	interval time.Duration
+}
+
+// NewExponentialBackOff creates an exponential backoff with the default values.
+func NewExponentialBackOff() *ExponentialBackOff {
+	b := &ExponentialBackOff{
+		InitialInterval:     DefaultInitialInterval,
+		RandomizationFactor: DefaultRandomizationFactor,
+		Multiplier:          DefaultMultiplier,
+		MaxInterval:         DefaultMaxInterval,
+		MaxElapsedTime:      DefaultMaxElapsedTime,
+		jitter:              rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	b.Reset()
+	return b
+}
+
+// NextBackOff returns the next randomized interval or Stop if the max elapsed time has
+// passed or would be exceeded by the next interval or if the max retries are reached.
+func (b *ExponentialBackOff) NextBackOff() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.jitter == nil {
+		b.jitter = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	if b.MaxRetries > 0 && b.retries >= b.MaxRetries {
+		return Stop
+	}
+
+	next := b.randomize(b.current)
+	if b.MaxElapsedTime > 0 && b.now().Sub(b.start)+next > b.MaxElapsedTime {
+		return Stop
+	}
+	b.retries++
+
+	// Increment the current interval, ensuring it does not exceed the max interval.
+	if b.MaxInterval > 0 && float64(b.current) >= float64(b.MaxInterval)/b.Multiplier {
+		b.current = b.MaxInterval
+	} else {
+		b.current = time.Duration(float64(b.current) * b.Multiplier)
+	}
+	return next
+}
+
+// Reset the interval to the initial interval and restart the elapsed time and retries.
+func (b *ExponentialBackOff) Reset() {
+	b.mu.Lock()
+	b.current = b.InitialInterval
+	b.start = b.now()
+	b.retries = 0
+	b.mu.Unlock()
+}
+
+// Elapsed returns the time since the backoff was created or reset.
+func (b *ExponentialBackOff) Elapsed() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.now().Sub(b.start)
+}
+
+func (b *ExponentialBackOff) now() time.Time {
+	if b.Clock != nil {
+		return b.Clock()
+	}
+	return time.Now()
+}
+
+func (b *ExponentialBackOff) randomize(interval time.Duration) time.Duration {
+	if b.RandomizationFactor <= 0 {
+		return interval
+	}
+
+	delta := b.RandomizationFactor * float64(interval)
+	lo := float64(interval) - delta
+	hi := float64(interval) + delta
+	return time.Duration(lo + (b.jitter.Float64() * (hi - lo)))
+}
+
+// ConstantBackOff retries after the same interval forever.
+type ConstantBackOff struct {
+	Interval time.Duration
+}
+
+func (b *ConstantBackOff) NextBackOff() time.Duration { return b.Interval }
+func (b *ConstantBackOff) Reset()                     {}
+
+// Wait for the next backoff interval or until the context is done. If the backoff
+// returns Stop then ErrStop is returned, otherwise the context error is returned if
+// the context is done before the interval has elapsed.
+func Wait(ctx context.Context, b Backoff) error {
+	delay := b.NextBackOff()
+	if delay == Stop {
+		return ErrStop
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Retry the operation until it succeeds, the backoff stops, or the context is done.
+// If the operation does not succeed, the last error from the operation is returned
+// unless the context was canceled before the operation was attempted.
+func Retry(ctx context.Context, b Backoff, operation func() error) (err error) {
+	for {
+		if err = operation(); err == nil {
+			return nil
+		}
+
+		if werr := Wait(ctx, b); werr != nil {
+			if werr == ErrStop {
+				return err
+			}
+			return werr
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package backoff_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/rotationalio/go-ensign/backoff"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialBackOff(t *testing.T) {
+	b := NewExponentialBackOff()
+	b.InitialInterval = 100 * time.Millisecond
+	b.MaxInterval = time.Second
+	b.RandomizationFactor = 0
+	b.MaxElapsedTime = 0
+	b.Reset()
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		150 * time.Millisecond,
+		225 * time.Millisecond,
+		337500 * time.Microsecond,
+		506250 * time.Microsecond,
+		759375 * time.Microsecond,
+		time.Second,
+		time.Second,
+	}
+
+	for i, delay := range expected {
+		require.Equal(t, delay, b.NextBackOff(), "unexpected delay for retry %d", i)
+	}
+
+	b.Reset()
+	require.Equal(t, 100*time.Millisecond, b.NextBackOff(), "expected reset to restart intervals")
+}
+
+func TestExponentialJitter(t *testing.T) {
+	b := NewExponentialBackOff()
+	b.InitialInterval = time.Second
+	b.RandomizationFactor = 0.5
+	b.Multiplier = 1
+	b.Reset()
+
+	for i := 0; i < 100; i++ {
+		delay := b.NextBackOff()
+		require.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		require.LessOrEqual(t, delay, 1500*time.Millisecond)
+	}
+}
+
+func TestMaxElapsedTime(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := NewExponentialBackOff()
+	b.InitialInterval = time.Second
+	b.Multiplier = 2
+	b.RandomizationFactor = 0
+	b.MaxInterval = 10 * time.Second
+	b.MaxElapsedTime = 10 * time.Second
+	b.Clock = func() time.Time { return now }
+	b.Reset()
+
+	// Advance the clock by each interval as though the caller slept for it.
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := b.NextBackOff()
+		require.Equal(t, expected, delay)
+		now = now.Add(delay)
+	}
+	require.Equal(t, 7*time.Second, b.Elapsed())
+
+	// The next interval of 8s would exceed the max elapsed time of 10s.
+	require.Equal(t, Stop, b.NextBackOff())
+
+	// Resetting the backoff restarts the elapsed time from the clock.
+	b.Reset()
+	require.Equal(t, time.Duration(0), b.Elapsed())
+	require.Equal(t, time.Second, b.NextBackOff())
+
+	// Once the max elapsed time has passed no interval can fit.
+	now = now.Add(10 * time.Second)
+	require.Equal(t, Stop, b.NextBackOff())
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), &ConstantBackOff{Interval: time.Millisecond}, func() error {
+		if calls++; calls < 3 {
+			return errors.New("not ready")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// Retries should stop when the context is canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = Retry(ctx, &ConstantBackOff{Interval: time.Millisecond}, func() error {
+		return errors.New("not ready")
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Wait should return ErrStop if the backoff stops.
+	b := Exponential(time.Second, time.Second, time.Millisecond)()
+	require.ErrorIs(t, Wait(context.Background(), b), ErrStop)
+}
+
+func TestMaxRetries(t *testing.T) {
+	b := NewExponentialBackOff()
+	b.InitialInterval = time.Millisecond
+	b.MaxRetries = 3
+	b.Reset()
+
+	for i := 0; i < 3; i++ {
+		require.NotEqual(t, Stop, b.NextBackOff(), "expected retry %d to be allowed", i)
+	}
+	require.Equal(t, Stop, b.NextBackOff(), "expected backoff to stop after max retries")
+
+	b.Reset()
+	require.NotEqual(t, Stop, b.NextBackOff(), "expected reset to restart retries")
+}
+
+func TestConfig(t *testing.T) {
+	conf := DefaultConfig()
+	require.NoError(t, conf.Validate(), "default config should be valid")
+
+	b, ok := conf.Policy()().(*ExponentialBackOff)
+	require.True(t, ok, "expected an exponential backoff")
+	require.Equal(t, DefaultInitialInterval, b.InitialInterval)
+	require.Equal(t, DefaultMaxInterval, b.MaxInterval)
+	require.Equal(t, DefaultMultiplier, b.Multiplier)
+	require.Equal(t, DefaultRandomizationFactor, b.RandomizationFactor)
+	require.Equal(t, DefaultMaxElapsedTime, b.MaxElapsedTime)
+	require.Zero(t, b.MaxRetries)
+
+	conf = Config{InitialInterval: 10 * time.Millisecond, MaxInterval: 40 * time.Millisecond, Multiplier: 2, MaxRetries: 4}
+	require.NoError(t, conf.Validate())
+
+	b = conf.Policy()().(*ExponentialBackOff)
+	for i, delay := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, Stop} {
+		require.Equal(t, delay, b.NextBackOff(), "unexpected delay for retry %d", i)
+	}
+
+	invalid := []Config{
+		{},
+		{InitialInterval: time.Second, Multiplier: 0.5},
+		{InitialInterval: time.Second, Multiplier: 1, MaxInterval: -1},
+		{InitialInterval: time.Second, Multiplier: 1, Jitter: 1.5},
+		{InitialInterval: time.Second, Multiplier: 1, MaxElapsedTime: -1},
+		{InitialInterval: time.Second, Multiplier: 1, MaxRetries: -1},
+	}
+
+	for i, conf := range invalid {
+		require.ErrorIs(t, conf.Validate(), ErrInvalidConfig, "expected config %d to be invalid", i)
+	}
+}
@@ -0,0 +1,8 @@
+package backoff
+
+import "errors"
+
+var (
+	ErrStop          = errors.New("backoff stopped: max elapsed time or retries reached")
+	ErrInvalidConfig = errors.New("invalid backoff configuration")
+)
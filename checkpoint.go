@@ -0,0 +1,308 @@
+package ensign
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// CheckpointVersion is the current version of the checkpoint export format. Checkpoints
+// with a newer version than the one supported by the SDK cannot be imported.
+const CheckpointVersion = 1
+
+// Checkpoint describes the positions of a consumer in the topics that it is subscribed
+// to so that the positions can be exported and restored elsewhere, e.g. to rehearse
+// disaster recovery or to migrate a consumer to a new environment. The checkpoint is
+// serialized as versioned JSON so that it can be stored and inspected by operators.
+type Checkpoint struct {
+	Version   int        `json:"version"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Group     string     `json:"group,omitempty"`
+	Topics    []string   `json:"topics,omitempty"`
+	Positions []Position `json:"positions"`
+	Created   time.Time  `json:"created"`
+}
+
+// Position is the epoch and offset of the last event acked by a consumer in a topic.
+type Position struct {
+	TopicID string `json:"topic_id"`
+	Epoch   uint64 `json:"epoch"`
+	Offset  uint64 `json:"offset"`
+}
+
+// ReadCheckpoint parses a checkpoint from the reader and validates its version.
+func ReadCheckpoint(r io.Reader) (checkpoint *Checkpoint, err error) {
+	checkpoint = &Checkpoint{}
+	if err = json.NewDecoder(r).Decode(checkpoint); err != nil {
+		return nil, fmt.Errorf("could not decode checkpoint: %w", err)
+	}
+
+	if err = checkpoint.Validate(); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// WriteTo writes the checkpoint as JSON to the specified writer.
+func (c *Checkpoint) WriteTo(w io.Writer) (n int64, err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(c, "", "  "); err != nil {
+		return 0, err
+	}
+
+	var nbytes int
+	nbytes, err = w.Write(append(data, '\n'))
+	return int64(nbytes), err
+}
+
+// Validate that the checkpoint is supported by this version of the SDK and that all of
+// the positions reference parseable topic IDs.
+func (c *Checkpoint) Validate() (err error) {
+	if c.Version < 1 || c.Version > CheckpointVersion {
+		return fmt.Errorf("%w: version %d", ErrCheckpointVersion, c.Version)
+	}
+
+	for _, pos := range c.Positions {
+		if _, err = ulid.Parse(pos.TopicID); err != nil {
+			return fmt.Errorf("%w: could not parse topic id %q", ErrInvalidCheckpoint, pos.TopicID)
+		}
+	}
+	return nil
+}
+
+// ExportCheckpoint writes the positions of the last acked event in each topic of the
+// subscription along with the subscription's topics and consumer group to the writer.
+func (c *Subscription) ExportCheckpoint(w io.Writer) (err error) {
+	sub := c.stream.Subscription()
+	checkpoint := &Checkpoint{
+		Version:   CheckpointVersion,
+		ClientID:  sub.ClientId,
+		Topics:    sub.Topics,
+		Positions: c.positions.list(),
+		Created:   time.Now(),
+	}
+
+	if sub.Group != nil {
+		checkpoint.Group = sub.Group.Name
+	}
+
+	_, err = checkpoint.WriteTo(w)
+	return err
+}
+
+// ImportCheckpoint reads a checkpoint exported by ExportCheckpoint and restores the
+// positions on the subscription. The positions are applied as the consumer group topic
+// offsets of the subscription and the stream is reopened so that the server resumes
+// delivering events from the restored positions. Because the server is only sent the
+// offsets, events at or before the epoch and offset of a restored position are acked
+// and skipped if the server delivers them, e.g. after an epoch change. If the
+// subscription has a checkpointer, the restored positions are also saved to it.
+func (c *Subscription) ImportCheckpoint(r io.Reader) (err error) {
+	var checkpoint *Checkpoint
+	if checkpoint, err = ReadCheckpoint(r); err != nil {
+		return err
+	}
+
+	if err = c.positions.restore(checkpoint.Positions); err != nil {
+		return err
+	}
+
+	return c.stream.Resubscribe(func(sub *api.Subscription) {
+		if sub.Group == nil {
+			sub.Group = &api.ConsumerGroup{Name: checkpoint.Group}
+		}
+
+		sub.Group.TopicOffsets = make(map[string]uint64, len(checkpoint.Positions))
+		for _, pos := range checkpoint.Positions {
+			sub.Group.TopicOffsets[pos.TopicID] = pos.Offset
+		}
+	})
+}
+
+// positions tracks the epoch and offset of the last acked event per topic. If the
+// subscription has a checkpointer, positions are saved to it as they are updated and
+// the positions loaded from it are used to skip events that were already acked.
+type positions struct {
+	sync.Mutex
+	topics map[ulid.ULID]Position
+	resume map[ulid.ULID]Position
+	store  Checkpointer
+	group  string
+}
+
+// Load the positions of the group from the checkpointer to resume the subscription.
+func (p *positions) load(store Checkpointer, group string) (err error) {
+	var in []Position
+	if in, err = store.Load(group); err != nil {
+		return fmt.Errorf("could not load checkpoint: %w", err)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.store, p.group = store, group
+	p.topics = make(map[ulid.ULID]Position, len(in))
+	p.resume = make(map[ulid.ULID]Position, len(in))
+	for _, pos := range in {
+		var topicID ulid.ULID
+		if topicID, err = ulid.Parse(pos.TopicID); err != nil {
+			return fmt.Errorf("%w: could not parse topic id %q", ErrInvalidCheckpoint, pos.TopicID)
+		}
+		p.topics[topicID] = pos
+		p.resume[topicID] = pos
+	}
+	return nil
+}
+
+func (p *positions) update(wrapper *api.EventWrapper) (err error) {
+	var topicID ulid.ULID
+	if topicID, err = wrapper.ParseTopicID(); err != nil {
+		return nil
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if p.topics == nil {
+		p.topics = make(map[ulid.ULID]Position)
+	}
+
+	// Only move the position forward, acks may arrive out of order.
+	if pos, ok := p.topics[topicID]; ok && !after(wrapper, pos) {
+		return nil
+	}
+
+	pos := Position{TopicID: topicID.String(), Epoch: wrapper.Epoch, Offset: wrapper.Offset}
+	p.topics[topicID] = pos
+	return p.save(pos)
+}
+
+// Restores the positions and resumes from them; events at or before the epoch and
+// offset of a restored position are skipped since only the offset is sent to the server.
+func (p *positions) restore(in []Position) (err error) {
+	p.Lock()
+	defer p.Unlock()
+	p.topics = make(map[ulid.ULID]Position, len(in))
+	p.resume = make(map[ulid.ULID]Position, len(in))
+	for _, pos := range in {
+		topicID := ulid.MustParse(pos.TopicID)
+		p.topics[topicID] = pos
+		p.resume[topicID] = pos
+		if err = p.save(pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Saves the position to the checkpointer if there is one; must hold the lock so that
+// positions are saved in the order they are updated.
+func (p *positions) save(pos Position) (err error) {
+	if p.store == nil {
+		return nil
+	}
+
+	if err = p.store.Save(p.group, pos); err != nil {
+		return fmt.Errorf("could not save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Returns true if the event is at or before the position loaded from the checkpointer
+// in its topic, e.g. an event that was acked before the subscription was restarted.
+func (p *positions) resumed(wrapper *api.EventWrapper) bool {
+	topicID, err := wrapper.ParseTopicID()
+	if err != nil {
+		return false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	pos, ok := p.resume[topicID]
+	return ok && !after(wrapper, pos)
+}
+
+// Returns the offsets loaded from the checkpointer keyed by topic ID.
+func (p *positions) offsets() map[string]uint64 {
+	p.Lock()
+	defer p.Unlock()
+	offsets := make(map[string]uint64, len(p.resume))
+	for _, pos := range p.resume {
+		offsets[pos.TopicID] = pos.Offset
+	}
+	return offsets
+}
+
+func (p *positions) list() []Position {
+	p.Lock()
+	defer p.Unlock()
+	out := make([]Position, 0, len(p.topics))
+	for _, pos := range p.topics {
+		out = append(out, pos)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].TopicID < out[j].TopicID })
+	return out
+}
+
+// Returns true if the event is after the position in the topic.
+func after(wrapper *api.EventWrapper, pos Position) bool {
+	return wrapper.Epoch > pos.Epoch || (wrapper.Epoch == pos.Epoch && wrapper.Offset > pos.Offset)
+}
+
+// tracker wraps the acknowledger of a subscription event so that the position of the
+// event is recorded when it is successfully acked.
+type tracker struct {
+	acks      Acknowledger
+	wrapper   *api.EventWrapper
+	positions *positions
+	log       Logger
+	dlq       *deadLetter
+	inflight  *releaser
+	deadline  *time.Timer // nacks the event if it is not acked or nacked before the ack deadline
+}
+
+func (t *tracker) Ack(ack *api.Ack) (err error) {
+	defer t.inflight.release()
+	if err = t.acks.Ack(ack); err != nil {
+		return err
+	}
+	t.stopDeadline()
+
+	if t.dlq != nil {
+		t.dlq.forget(t.wrapper)
+	}
+
+	// The event has been acked even if its position could not be checkpointed.
+	if err = t.positions.update(t.wrapper); err != nil && t.log != nil {
+		t.log.Error("could not checkpoint acked event", "event_id", fmt.Sprintf("%x", t.wrapper.Id), "error", err)
+	}
+	return nil
+}
+
+// Nacks are counted if the subscription has a dead letter topic so that events that
+// are nacked too many times are moved to the dead letter topic.
+func (t *tracker) Nack(nack *api.Nack) (err error) {
+	defer t.inflight.release()
+	if t.dlq != nil {
+		err = t.dlq.nack(t.acks, t.wrapper, nack)
+	} else {
+		err = t.acks.Nack(nack)
+	}
+
+	if err == nil {
+		t.stopDeadline()
+	}
+	return err
+}
+
+// Stops the ack deadline timer so that it is not kept alive once the event is handled.
+func (t *tracker) stopDeadline() {
+	if t.deadline != nil {
+		t.deadline.Stop()
+	}
+}
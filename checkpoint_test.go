@@ -0,0 +1,233 @@
+package ensign_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+
+	topicID := ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A")
+	factory := &mock.EventFactory{Topic: topicID}
+
+	// Capture the subscriptions sent to the server
+	subs := make(chan *api.Subscription, 2)
+	handler := mock.NewSubscribeHandler()
+	handler.OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+		subs <- in
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock"}, nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	sub, err := client.Subscribe(topicID.String())
+	require.NoError(t, err, "could not subscribe")
+	defer sub.Close()
+	require.Nil(t, (<-subs).Group, "expected no consumer group on initial subscription")
+
+	// Ack the first two events and nack the third; only acked events are checkpointed
+	for i := 0; i < 3; i++ {
+		handler.Send <- factory.Make()
+		event := <-sub.C
+		if i < 2 {
+			_, err = event.Ack()
+		} else {
+			_, err = event.Nack(api.Nack_UNPROCESSED)
+		}
+		require.NoError(t, err)
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, sub.ExportCheckpoint(buf), "could not export checkpoint")
+
+	checkpoint, err := sdk.ReadCheckpoint(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err, "could not read exported checkpoint")
+	require.Equal(t, sdk.CheckpointVersion, checkpoint.Version)
+	require.Equal(t, []string{topicID.String()}, checkpoint.Topics)
+	require.Equal(t, []sdk.Position{{TopicID: topicID.String(), Epoch: 0, Offset: 2}}, checkpoint.Positions)
+
+	// Importing the checkpoint should resubscribe with the restored offsets
+	checkpoint.Group = "drill"
+	checkpoint.Positions[0].Epoch = 2
+	checkpoint.Positions[0].Offset = 42
+	buf.Reset()
+	_, err = checkpoint.WriteTo(buf)
+	require.NoError(t, err)
+
+	// The new stream is handled by a new handler so that events are sent on it
+	resumed := mock.NewSubscribeHandler()
+	resumed.OnInitialize = handler.OnInitialize
+	emock.OnSubscribe = resumed.OnSubscribe
+	defer resumed.Shutdown()
+
+	require.NoError(t, sub.ImportCheckpoint(buf), "could not import checkpoint")
+	resub := <-subs
+	require.Equal(t, "drill", resub.Group.Name)
+	require.Equal(t, map[string]uint64{topicID.String(): 42}, resub.Group.TopicOffsets)
+
+	// Events at or before the restored epoch and offset are skipped
+	at := func(epoch, offset uint64) *api.EventWrapper {
+		wrapper := factory.Make()
+		wrapper.Epoch, wrapper.Offset = epoch, offset
+		return wrapper
+	}
+
+	resumed.Send <- at(1, 50)
+	resumed.Send <- at(2, 42)
+	delivered := at(3, 1)
+	resumed.Send <- delivered
+	require.Equal(t, delivered.Id, (<-sub.C).Info().Id, "expected events before the restored position to be skipped")
+
+	buf.Reset()
+	require.NoError(t, sub.ExportCheckpoint(buf))
+	checkpoint, err = sdk.ReadCheckpoint(buf)
+	require.NoError(t, err)
+	require.Equal(t, "drill", checkpoint.Group)
+	require.Equal(t, uint64(42), checkpoint.Positions[0].Offset)
+}
+
+func TestReadCheckpoint(t *testing.T) {
+	testCases := []struct {
+		in  string
+		err error
+	}{
+		{`{"version": 0, "positions": []}`, sdk.ErrCheckpointVersion},
+		{`{"version": 2, "positions": []}`, sdk.ErrCheckpointVersion},
+		{`{"version": 1, "positions": [{"topic_id": "foo"}]}`, sdk.ErrInvalidCheckpoint},
+		{`{"version": 1, "positions": [{"topic_id": "01HCG64Y1SMFQBW7A42SRV207A", "epoch": 1, "offset": 8}]}`, nil},
+	}
+
+	for i, tc := range testCases {
+		_, err := sdk.ReadCheckpoint(strings.NewReader(tc.in))
+		if tc.err != nil {
+			require.ErrorIs(t, err, tc.err, "test case %d failed", i)
+		} else {
+			require.NoError(t, err, "test case %d failed", i)
+		}
+	}
+}
+
+func TestCheckpointers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ensign", "checkpoints.json")
+	checkpointers := map[string]sdk.Checkpointer{
+		"memory": sdk.NewMemoryCheckpointer(),
+		"file":   sdk.NewFileCheckpointer(path),
+	}
+
+	for name, checkpointer := range checkpointers {
+		t.Run(name, func(t *testing.T) {
+			positions, err := checkpointer.Load("billing")
+			require.NoError(t, err, "expected no error for an unknown group")
+			require.Empty(t, positions)
+
+			alpha := sdk.Position{TopicID: "01HCG64Y1SMFQBW7A42SRV207A", Epoch: 1, Offset: 8}
+			bravo := sdk.Position{TopicID: "01GWM89049D49FHJH81BT8795H", Epoch: 1, Offset: 3}
+			require.NoError(t, checkpointer.Save("billing", alpha))
+			require.NoError(t, checkpointer.Save("billing", bravo))
+			require.NoError(t, checkpointer.Save("shipping", bravo))
+
+			// Saving a position replaces the position of the group in the topic
+			alpha.Offset = 9
+			require.NoError(t, checkpointer.Save("billing", alpha))
+
+			positions, err = checkpointer.Load("billing")
+			require.NoError(t, err)
+			require.Equal(t, []sdk.Position{bravo, alpha}, positions)
+
+			positions, err = checkpointer.Load("shipping")
+			require.NoError(t, err)
+			require.Equal(t, []sdk.Position{bravo}, positions)
+		})
+	}
+
+	// Positions are persisted across file checkpointers
+	positions, err := sdk.NewFileCheckpointer(path).Load("shipping")
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+	_, err = sdk.NewFileCheckpointer(path).Load("shipping")
+	require.ErrorIs(t, err, sdk.ErrInvalidCheckpoint)
+}
+
+func TestSubscribeCheckpointer(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+
+	topicID := ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A")
+	checkpointer := sdk.NewMemoryCheckpointer()
+	require.NoError(t, checkpointer.Save("billing", sdk.Position{TopicID: topicID.String(), Epoch: 1, Offset: 41}))
+
+	subs := make(chan *api.Subscription, 1)
+	acks := make(chan *api.Ack, 1)
+	handler := mock.NewSubscribeHandler()
+	handler.OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+		subs <- in
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock"}, nil
+	}
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	// The subscription resumes from the saved offsets
+	sub, err := client.CreateSubscriber([]string{topicID.String()}, sdk.WithCheckpointer(checkpointer, "billing"))
+	require.NoError(t, err, "could not subscribe")
+	defer sub.Close()
+	require.Equal(t, map[string]uint64{topicID.String(): 41}, (<-subs).Group.TopicOffsets)
+
+	at := func(offset uint64) *api.EventWrapper {
+		wrapper := mock.NewEventWrapper()
+		wrapper.TopicId = topicID.Bytes()
+		wrapper.Epoch, wrapper.Offset = 1, offset
+		return wrapper
+	}
+
+	// Events that were acked before the subscription was resumed are skipped
+	skipped := at(41)
+	handler.Send <- skipped
+	select {
+	case ack := <-acks:
+		require.Equal(t, skipped.Id, ack.Id)
+	case <-time.After(time.Second):
+		t.Fatal("expected the checkpointed event to be acked")
+	}
+
+	// Acked events are saved to the checkpointer
+	delivered := at(42)
+	handler.Send <- delivered
+	event := <-sub.C
+	require.Equal(t, delivered.Id, event.Info().Id)
+	_, err = event.Ack()
+	require.NoError(t, err)
+	<-acks
+
+	positions, err := checkpointer.Load("billing")
+	require.NoError(t, err)
+	require.Equal(t, []sdk.Position{{TopicID: topicID.String(), Epoch: 1, Offset: 42}}, positions)
+
+	// Invalid checkpoints cannot be resumed from
+	require.NoError(t, checkpointer.Save("billing", sdk.Position{TopicID: "foo"}))
+	_, err = client.CreateSubscriber([]string{topicID.String()}, sdk.WithCheckpointer(checkpointer, "billing"))
+	require.ErrorIs(t, err, sdk.ErrInvalidCheckpoint)
+}
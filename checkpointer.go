@@ -0,0 +1,165 @@
+package ensign
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Checkpointer stores the position of the last event acked by a consumer in each topic
+// so that a subscription that is restarted resumes where it left off rather than
+// relying only on the offsets of the consumer group kept by the server (see
+// WithCheckpointer). Positions are keyed by the consumer group and the topic ID of the
+// position. Load must return no positions and no error for an unknown group.
+type Checkpointer interface {
+	Load(group string) ([]Position, error)
+	Save(group string, pos Position) error
+}
+
+// MemoryCheckpointer stores positions in memory so that subscriptions can be resumed
+// within a process, e.g. when a subscription is recreated after a fatal error.
+type MemoryCheckpointer struct {
+	sync.Mutex
+	groups map[string]map[string]Position
+}
+
+var _ Checkpointer = &MemoryCheckpointer{}
+
+// NewMemoryCheckpointer creates a checkpointer without any stored positions.
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{groups: make(map[string]map[string]Position)}
+}
+
+// Load the positions stored for the group, sorted by topic ID.
+func (c *MemoryCheckpointer) Load(group string) ([]Position, error) {
+	c.Lock()
+	defer c.Unlock()
+	return sortPositions(c.groups[group]), nil
+}
+
+// Save the position of the group in the topic of the position.
+func (c *MemoryCheckpointer) Save(group string, pos Position) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.groups[group]; !ok {
+		c.groups[group] = make(map[string]Position)
+	}
+	c.groups[group][pos.TopicID] = pos
+	return nil
+}
+
+// FileCheckpointer stores positions as JSON in a file so that subscriptions can be
+// resumed after the process restarts. The file is replaced atomically every time a
+// position is saved, so the file is never partially written, but note that this means
+// that the file is written every time an event is acked.
+type FileCheckpointer struct {
+	sync.Mutex
+	path string
+}
+
+var _ Checkpointer = &FileCheckpointer{}
+
+// NewFileCheckpointer creates a checkpointer that stores positions in the file at the
+// path. The file and its parent directories are created when a position is first saved.
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// Path returns the path of the checkpoint file.
+func (c *FileCheckpointer) Path() string {
+	return c.path
+}
+
+// Load the positions stored for the group, sorted by topic ID.
+func (c *FileCheckpointer) Load(group string) (_ []Position, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	var groups map[string]map[string]Position
+	if groups, err = c.load(); err != nil {
+		return nil, err
+	}
+	return sortPositions(groups[group]), nil
+}
+
+// Save the position of the group in the topic of the position.
+func (c *FileCheckpointer) Save(group string, pos Position) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	var groups map[string]map[string]Position
+	if groups, err = c.load(); err != nil {
+		return err
+	}
+
+	if _, ok := groups[group]; !ok {
+		groups[group] = make(map[string]Position)
+	}
+	groups[group][pos.TopicID] = pos
+
+	var data []byte
+	if data, err = json.MarshalIndent(groups, "", "  "); err != nil {
+		return fmt.Errorf("could not marshal checkpoints: %w", err)
+	}
+
+	dir := filepath.Dir(c.path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("could not create checkpoint directory: %w", err)
+	}
+
+	// Write to a temporary file and rename it to atomically replace the checkpoints.
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(dir, ".checkpoints-*"); err != nil {
+		return fmt.Errorf("could not create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write checkpoints: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("could not write checkpoints: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("could not write checkpoints: %w", err)
+	}
+	return nil
+}
+
+// Load the checkpoints from disk; a missing file has no checkpoints.
+func (c *FileCheckpointer) load() (groups map[string]map[string]Position, err error) {
+	var data []byte
+	if data, err = os.ReadFile(c.path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string]map[string]Position), nil
+		}
+		return nil, fmt.Errorf("could not read checkpoints: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("%w: could not parse checkpoints: %s", ErrInvalidCheckpoint, err)
+	}
+
+	if groups == nil {
+		groups = make(map[string]map[string]Position)
+	}
+	return groups, nil
+}
+
+func sortPositions(topics map[string]Position) []Position {
+	out := make([]Position, 0, len(topics))
+	for _, pos := range topics {
+		out = append(out, pos)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].TopicID < out[j].TopicID })
+	return out
+}
@@ -0,0 +1,241 @@
+package ensign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/stream"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metadata keys that identify the chunks of an event that was split with Chunk so that
+// the event can be reassembled from its chunks by a ChunkAssembler.
+const (
+	ChunkIDKey    = "chunk_id"
+	ChunkIndexKey = "chunk_index"
+	ChunkCountKey = "chunk_count"
+)
+
+// The number of bytes reserved in each chunk published by PublishChunked for the event
+// wrapper, the chunk metadata, and the metadata that is added when the chunk is
+// published, e.g. signatures and trace context.
+const chunkOverhead = 4096
+
+// Chunk splits the event into events whose payloads are at most size bytes so that
+// payloads that are larger than the maximum event size can be published as multiple
+// events. Each chunk is a clone of the event with its part of the payload and the
+// ChunkIDKey, ChunkIndexKey, and ChunkCountKey metadata that a ChunkAssembler uses to
+// reassemble the event. If the event does not have a key, the chunks are keyed with the
+// chunk ID so that they are assigned to the same shard and are kept in order. If the
+// payload of the event is not larger than the size, the event is returned unchanged.
+func (e *Event) Chunk(size int) (_ []*Event, err error) {
+	if size <= 0 {
+		return nil, ErrInvalidChunkSize
+	}
+
+	if len(e.Data) <= size {
+		return []*Event{e}, nil
+	}
+
+	id := ulid.Make()
+	count := (len(e.Data) + size - 1) / size
+	chunks := make([]*Event, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(e.Data) {
+			end = len(e.Data)
+		}
+
+		chunk := e.CloneWithData(e.Data[i*size : end])
+		chunk.Created = e.Created
+		if len(chunk.Key) == 0 {
+			chunk.Key = id.Bytes()
+		}
+
+		chunk.Metadata.Set(ChunkIDKey, id.String())
+		chunk.Metadata.SetInt(ChunkIndexKey, int64(i))
+		chunk.Metadata.SetInt(ChunkCountKey, int64(count))
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// IsChunk returns true if the event is a chunk of a larger event (see Chunk).
+func (e *Event) IsChunk() bool {
+	_, ok := e.Metadata[ChunkIDKey]
+	return ok
+}
+
+// PublishChunked publishes the event to the specified topic name or topic ID, splitting
+// it into chunks (see Chunk) if it is larger than the maximum event size of the client
+// (see WithMaxEventSize). The published events are returned so that their acks can be
+// checked; if the event was not split, the event itself is the only published event.
+// Chunks are published in order but not atomically: if an error is returned, some of
+// the chunks may have been published. Subscribers reassemble the event from its chunks
+// using a ChunkAssembler.
+func (c *Client) PublishChunked(ctx context.Context, topic string, event *Event) (_ []*Event, err error) {
+	// Normalize the event before it is chunked so the chunks share the same defaults.
+	if err = event.normalize(); err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	if limit := c.opts.maxEventSize(); limit > 0 {
+		// Reserve room in each chunk for the fields of the event other than the payload.
+		size := limit - chunkOverhead - (proto.Size(event.Proto()) - len(event.Data))
+		if events, err = event.Chunk(size); err != nil {
+			return nil, err
+		}
+	} else {
+		events = []*Event{event}
+	}
+
+	if err = c.PublishContext(ctx, topic, events...); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Returns the maximum size of a published event: the size specified WithMaxEventSize,
+// otherwise the maximum send message size of the client, otherwise the stream default.
+func (o *Options) maxEventSize() int {
+	switch {
+	case o.MaxEventSize != 0:
+		return o.MaxEventSize
+	case o.MaxSendMsgSize > 0:
+		return o.MaxSendMsgSize
+	default:
+		return stream.DefaultMaxEventSize
+	}
+}
+
+// ChunkAssembler reassembles events that were split with Chunk (e.g. by PublishChunked)
+// from their chunks as they are received by a subscriber; chunks may be received in any
+// order. The assembled event can be acked or nacked like any other subscribed event,
+// which acks or nacks all of its chunks, so the chunks should not be acked separately.
+// The chunks of incomplete events are held in memory until their remaining chunks are
+// received. A ChunkAssembler is safe for concurrent use.
+type ChunkAssembler struct {
+	sync.Mutex
+	partial map[string]*chunkedEvent
+}
+
+// The chunks of an event that have been received, keyed by chunk index.
+type chunkedEvent struct {
+	count  int64
+	chunks map[int64]*Event
+}
+
+// NewChunkAssembler returns an assembler with no partially received events.
+func NewChunkAssembler() *ChunkAssembler {
+	return &ChunkAssembler{partial: make(map[string]*chunkedEvent)}
+}
+
+// Add a received event to the assembler, returning the reassembled event once all of
+// its chunks have been received or nil if chunks are still missing. Events that are not
+// chunks are returned as is. If the chunk metadata of the event is invalid or does not
+// match the chunks previously received for the event, ErrInvalidChunk is returned.
+func (a *ChunkAssembler) Add(event *Event) (_ *Event, err error) {
+	if !event.IsChunk() {
+		return event, nil
+	}
+
+	id := event.Metadata.Get(ChunkIDKey)
+	index, iok := event.Metadata.GetInt(ChunkIndexKey)
+	count, cok := event.Metadata.GetInt(ChunkCountKey)
+	if !iok || !cok || index < 0 || index >= count {
+		return nil, fmt.Errorf("%w: chunk %q has an invalid index or count", ErrInvalidChunk, id)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	partial, ok := a.partial[id]
+	if !ok {
+		partial = &chunkedEvent{count: count, chunks: make(map[int64]*Event)}
+		a.partial[id] = partial
+	}
+
+	if partial.count != count {
+		return nil, fmt.Errorf("%w: chunk %q has %d chunks, expected %d", ErrInvalidChunk, id, count, partial.count)
+	}
+
+	// Chunks that are redelivered replace the previously received chunk.
+	partial.chunks[index] = event
+	if int64(len(partial.chunks)) < partial.count {
+		return nil, nil
+	}
+
+	delete(a.partial, id)
+	return partial.assemble(id), nil
+}
+
+// Pending returns the number of events that are missing chunks.
+func (a *ChunkAssembler) Pending() int {
+	a.Lock()
+	defer a.Unlock()
+	return len(a.partial)
+}
+
+// Joins the payloads of the chunks in order into a clone of the first chunk without the
+// chunk metadata. If the chunks were received from a subscription, the assembled event
+// has the wrapper of the last chunk and acks or nacks all of the chunks.
+func (c *chunkedEvent) assemble(id string) *Event {
+	chunks := make([]*Event, 0, c.count)
+	size := 0
+	for i := int64(0); i < c.count; i++ {
+		chunks = append(chunks, c.chunks[i])
+		size += len(c.chunks[i].Data)
+	}
+
+	data := make([]byte, 0, size)
+	for _, chunk := range chunks {
+		data = append(data, chunk.Data...)
+	}
+
+	first, last := chunks[0], chunks[len(chunks)-1]
+	event := first.CloneWithData(data)
+	event.Created = first.Created
+	event.Metadata.Delete(ChunkIDKey)
+	event.Metadata.Delete(ChunkIndexKey)
+	event.Metadata.Delete(ChunkCountKey)
+
+	// Remove the key that was added by Chunk if the original event did not have one.
+	if chunkID, err := ulid.Parse(id); err == nil && bytes.Equal(event.Key, chunkID.Bytes()) {
+		event.Key = nil
+	}
+
+	last.mu.Lock()
+	if last.state == subscription {
+		event.state = subscription
+		event.info = last.info
+		event.sub = chunkAcks(chunks)
+	}
+	last.mu.Unlock()
+	return event
+}
+
+// chunkAcks acks or nacks every chunk of an assembled event.
+type chunkAcks []*Event
+
+func (c chunkAcks) Ack(*api.Ack) error {
+	for _, chunk := range c {
+		if _, err := chunk.Ack(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c chunkAcks) Nack(nack *api.Nack) error {
+	for _, chunk := range c {
+		if _, err := chunk.nack(&api.Nack{Code: nack.Code, Error: nack.Error}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package ensign_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunk(t *testing.T) {
+	event := &sdk.Event{
+		Data:     []byte("the quick brown fox"),
+		Mimetype: mimetype.TextPlain,
+		Metadata: sdk.Metadata{"source": "test"},
+		Type:     &api.Type{Name: "Sentence", MajorVersion: 1},
+		Created:  time.Now(),
+	}
+
+	_, err := event.Chunk(0)
+	require.ErrorIs(t, err, sdk.ErrInvalidChunkSize)
+
+	// Events that are small enough are not chunked
+	chunks, err := event.Chunk(64)
+	require.NoError(t, err)
+	require.Equal(t, []*sdk.Event{event}, chunks)
+
+	chunks, err = event.Chunk(8)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	for i, data := range []string{"the quic", "k brown ", "fox"} {
+		require.True(t, chunks[i].IsChunk())
+		require.Equal(t, data, string(chunks[i].Data))
+		require.Equal(t, "test", chunks[i].Metadata.Get("source"))
+		require.Equal(t, chunks[0].Metadata.Get(sdk.ChunkIDKey), chunks[i].Metadata.Get(sdk.ChunkIDKey))
+		require.Equal(t, chunks[0].Key, chunks[i].Key, "expected chunks to be assigned to the same shard")
+		require.True(t, event.Created.Equal(chunks[i].Created))
+	}
+	require.False(t, event.IsChunk(), "the original event should not be modified")
+
+	// Chunks can be reassembled in any order
+	assembler := sdk.NewChunkAssembler()
+	for _, i := range []int{2, 0} {
+		assembled, err := assembler.Add(chunks[i])
+		require.NoError(t, err)
+		require.Nil(t, assembled)
+	}
+	require.Equal(t, 1, assembler.Pending())
+
+	assembled, err := assembler.Add(chunks[1])
+	require.NoError(t, err)
+	require.True(t, event.Equals(assembled), "expected the event to be reassembled")
+	require.Nil(t, assembled.Key)
+	require.Zero(t, assembler.Pending())
+
+	// Events that are not chunks are returned as is
+	assembled, err = assembler.Add(event)
+	require.NoError(t, err)
+	require.Same(t, event, assembled)
+
+	// Chunks with invalid metadata are rejected
+	invalid := chunks[0].Clone()
+	invalid.Metadata.SetInt(sdk.ChunkIndexKey, 3)
+	_, err = assembler.Add(invalid)
+	require.ErrorIs(t, err, sdk.ErrInvalidChunk)
+
+	invalid.Metadata.SetInt(sdk.ChunkIndexKey, 0)
+	invalid.Metadata.SetInt(sdk.ChunkCountKey, 4)
+	_, err = assembler.Add(chunks[1])
+	require.NoError(t, err)
+	_, err = assembler.Add(invalid)
+	require.ErrorIs(t, err, sdk.ErrInvalidChunk)
+}
+
+func TestPublishChunked(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithMaxEventSize(64*1024))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	// Events larger than the maximum event size fail fast
+	topicID := "01GWM89049D49FHJH81BT8795H"
+	event := &sdk.Event{Data: bytes.Repeat([]byte("ensign"), 25000), Mimetype: mimetype.TextPlain}
+	require.ErrorIs(t, client.Publish(topicID, event), sdk.ErrEventTooLarge)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chunks, err := client.PublishChunked(ctx, topicID, event)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	require.NoError(t, client.Flush(ctx))
+
+	// The subscriber reassembles the event and acking it acks all of the chunks
+	acks := &ackRecorder{}
+	assembler := sdk.NewChunkAssembler()
+
+	var assembled *sdk.Event
+	published := recorder.Published()
+	require.Len(t, published, 3)
+	for _, pub := range published {
+		pub.Wrapper.Id = pub.Wrapper.LocalId
+		assembled, err = assembler.Add(sdk.NewIncomingEvent(pub.Wrapper, acks))
+		require.NoError(t, err)
+	}
+
+	require.NotNil(t, assembled)
+	require.Equal(t, event.Data, assembled.Data)
+
+	acked, err := assembled.Ack()
+	require.NoError(t, err)
+	require.True(t, acked)
+	require.Len(t, acks.acks, 3)
+}
+
+type ackRecorder struct {
+	acks []*api.Ack
+}
+
+func (r *ackRecorder) Ack(in *api.Ack) error {
+	r.acks = append(r.acks, in)
+	return nil
+}
+
+func (r *ackRecorder) Nack(*api.Nack) error { return nil }
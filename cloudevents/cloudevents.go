@@ -0,0 +1,111 @@
+/*
+Package cloudevents converts between Ensign events and CloudEvents 1.0 so that Ensign
+can be used in CloudEvents-based architectures, e.g. to publish CloudEvents received
+from a webhook to an Ensign topic or to forward events from a subscription to a
+CloudEvents sink. Events can be converted using either the binary content mode, where
+the CloudEvent attributes are stored in the metadata of the Ensign event and the data
+is the event payload, or the structured content mode, where the entire CloudEvent is
+encoded as JSON in the payload of the Ensign event.
+*/
+package cloudevents
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// SpecVersion is the version of the CloudEvents specification implemented by this
+// package and the only version that is accepted when validating events.
+const SpecVersion = "1.0"
+
+var (
+	ErrMissingAttribute = errors.New("cloudevent is missing a required attribute")
+	ErrSpecVersion      = errors.New("unsupported cloudevents spec version")
+	ErrInvalidExtension = errors.New("invalid cloudevent extension name")
+	ErrInvalidEncoding  = errors.New("could not decode structured cloudevent")
+)
+
+// Extension attribute names must be lower-case alphanumeric strings.
+var extensionName = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// Event is a CloudEvent with the required and optional context attributes of the 1.0
+// specification. Extension attributes are represented as strings, which is the
+// canonical representation of all CloudEvents attribute types.
+type Event struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	Extensions      map[string]string
+	Data            []byte
+}
+
+// New creates a CloudEvent with the current spec version and the required attributes.
+func New(id, source, eventType string) *Event {
+	return &Event{
+		ID:          id,
+		Source:      source,
+		SpecVersion: SpecVersion,
+		Type:        eventType,
+	}
+}
+
+// SetExtension sets the value of the extension attribute, creating the extensions map
+// if necessary. The name is not validated until the event is validated.
+func (e *Event) SetExtension(name, value string) {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]string)
+	}
+	e.Extensions[name] = value
+}
+
+// Validate that the event has the required attributes, that it uses the supported spec
+// version, and that the names of the extension attributes are valid and do not shadow
+// the context attributes defined by the specification.
+func (e *Event) Validate() error {
+	switch {
+	case e.ID == "":
+		return fmt.Errorf("%w: id", ErrMissingAttribute)
+	case e.Source == "":
+		return fmt.Errorf("%w: source", ErrMissingAttribute)
+	case e.Type == "":
+		return fmt.Errorf("%w: type", ErrMissingAttribute)
+	case e.SpecVersion == "":
+		return fmt.Errorf("%w: specversion", ErrMissingAttribute)
+	case e.SpecVersion != SpecVersion:
+		return fmt.Errorf("%w: %q", ErrSpecVersion, e.SpecVersion)
+	}
+
+	for name := range e.Extensions {
+		if !extensionName.MatchString(name) || isAttribute(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidExtension, name)
+		}
+	}
+	return nil
+}
+
+// Context attributes defined by the specification, including the data attributes of
+// the JSON format, which cannot be used as extension names.
+var attributes = map[string]struct{}{
+	"id":              {},
+	"source":          {},
+	"specversion":     {},
+	"type":            {},
+	"datacontenttype": {},
+	"dataschema":      {},
+	"subject":         {},
+	"time":            {},
+	"data":            {},
+	"data_base64":     {},
+}
+
+func isAttribute(name string) bool {
+	_, ok := attributes[name]
+	return ok
+}
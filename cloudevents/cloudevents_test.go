@@ -0,0 +1,182 @@
+package cloudevents_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/cloudevents"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent() *cloudevents.Event {
+	ce := cloudevents.New("A234-1234-1234", "/mycontext", "com.example.someevent")
+	ce.Subject = "orders/42"
+	ce.DataContentType = "application/json"
+	ce.DataSchema = "https://example.com/schemas/order.json"
+	ce.Time = time.Date(2023, 8, 14, 12, 31, 3, 0, time.UTC)
+	ce.Data = []byte(`{"order":42}`)
+	ce.SetExtension("comexampleextension", "value")
+	return ce
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, newEvent().Validate())
+
+	testCases := []struct {
+		modify func(*cloudevents.Event)
+		err    error
+	}{
+		{func(ce *cloudevents.Event) { ce.ID = "" }, cloudevents.ErrMissingAttribute},
+		{func(ce *cloudevents.Event) { ce.Source = "" }, cloudevents.ErrMissingAttribute},
+		{func(ce *cloudevents.Event) { ce.Type = "" }, cloudevents.ErrMissingAttribute},
+		{func(ce *cloudevents.Event) { ce.SpecVersion = "" }, cloudevents.ErrMissingAttribute},
+		{func(ce *cloudevents.Event) { ce.SpecVersion = "0.3" }, cloudevents.ErrSpecVersion},
+		{func(ce *cloudevents.Event) { ce.SetExtension("Upper", "value") }, cloudevents.ErrInvalidExtension},
+		{func(ce *cloudevents.Event) { ce.SetExtension("snake_case", "value") }, cloudevents.ErrInvalidExtension},
+		{func(ce *cloudevents.Event) { ce.SetExtension("subject", "value") }, cloudevents.ErrInvalidExtension},
+	}
+
+	for i, tc := range testCases {
+		ce := newEvent()
+		tc.modify(ce)
+		require.ErrorIs(t, ce.Validate(), tc.err, "test case %d failed", i)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	ce := newEvent()
+	data, err := json.Marshal(ce)
+	require.NoError(t, err, "could not marshal cloudevent")
+	require.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "A234-1234-1234",
+		"source": "/mycontext",
+		"type": "com.example.someevent",
+		"subject": "orders/42",
+		"datacontenttype": "application/json",
+		"dataschema": "https://example.com/schemas/order.json",
+		"time": "2023-08-14T12:31:03Z",
+		"comexampleextension": "value",
+		"data": {"order": 42}
+	}`, string(data))
+
+	cmp := &cloudevents.Event{}
+	require.NoError(t, json.Unmarshal(data, cmp), "could not unmarshal cloudevent")
+	require.Equal(t, ce, cmp)
+
+	// Binary data is base64 encoded
+	ce.DataContentType = "application/octet-stream"
+	ce.Data = []byte{0xde, 0xad, 0xbe, 0xef}
+	data, err = json.Marshal(ce)
+	require.NoError(t, err, "could not marshal cloudevent")
+	require.Contains(t, string(data), `"data_base64":"3q2+7w=="`)
+
+	cmp = &cloudevents.Event{}
+	require.NoError(t, json.Unmarshal(data, cmp), "could not unmarshal cloudevent")
+	require.Equal(t, ce, cmp)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	// Non-string extensions and text data are decoded
+	ce := &cloudevents.Event{}
+	err := json.Unmarshal([]byte(`{
+		"specversion": "1.0",
+		"id": "1",
+		"source": "/sensors",
+		"type": "com.example.reading",
+		"datacontenttype": "text/plain",
+		"sequence": 42,
+		"sampled": true,
+		"data": "hello world"
+	}`), ce)
+	require.NoError(t, err, "could not unmarshal cloudevent")
+	require.Equal(t, map[string]string{"sequence": "42", "sampled": "true"}, ce.Extensions)
+	require.Equal(t, []byte("hello world"), ce.Data)
+	require.True(t, ce.Time.IsZero())
+
+	testCases := []string{
+		`[]`,
+		`{"id": 1}`,
+		`{"time": "yesterday"}`,
+		`{"data_base64": "not base64!"}`,
+	}
+
+	for _, tc := range testCases {
+		require.ErrorIs(t, json.Unmarshal([]byte(tc), &cloudevents.Event{}), cloudevents.ErrInvalidEncoding, "expected error for %s", tc)
+	}
+}
+
+func TestBinaryMode(t *testing.T) {
+	ce := newEvent()
+	event, err := cloudevents.ToEnsign(ce)
+	require.NoError(t, err, "could not convert cloudevent to ensign event")
+	require.Equal(t, ce.Data, event.Data)
+	require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+	require.Equal(t, ce.Time, event.Created)
+	require.Equal(t, ensign.Metadata{
+		"ce_id":                  "A234-1234-1234",
+		"ce_source":              "/mycontext",
+		"ce_specversion":         "1.0",
+		"ce_type":                "com.example.someevent",
+		"ce_subject":             "orders/42",
+		"ce_datacontenttype":     "application/json",
+		"ce_dataschema":          "https://example.com/schemas/order.json",
+		"ce_comexampleextension": "value",
+	}, event.Metadata)
+
+	cmp, err := cloudevents.FromEnsign(event)
+	require.NoError(t, err, "could not convert ensign event to cloudevent")
+	require.Equal(t, ce, cmp)
+
+	// Invalid cloudevents cannot be converted
+	ce.Type = ""
+	_, err = cloudevents.ToEnsign(ce)
+	require.ErrorIs(t, err, cloudevents.ErrMissingAttribute)
+}
+
+func TestFromEnsign(t *testing.T) {
+	// Metadata without the prefix is ignored and missing attributes are derived
+	event := &ensign.Event{
+		Metadata: ensign.Metadata{"ce_id": "1", "ce_source": "/orders", "region": "us-east-1"},
+		Data:     []byte("hello world"),
+		Mimetype: mimetype.TextPlain,
+		Type:     &api.Type{Name: "Greeting", MajorVersion: 1},
+	}
+
+	ce, err := cloudevents.FromEnsign(event)
+	require.NoError(t, err, "could not convert ensign event to cloudevent")
+	require.Equal(t, "Greeting", ce.Type)
+	require.Equal(t, cloudevents.SpecVersion, ce.SpecVersion)
+	require.Equal(t, "text/plain", ce.DataContentType)
+	require.Empty(t, ce.Extensions)
+
+	// An event without an id or source cannot be converted
+	_, err = cloudevents.FromEnsign(&ensign.Event{Type: &api.Type{Name: "Greeting"}})
+	require.ErrorIs(t, err, cloudevents.ErrMissingAttribute)
+}
+
+func TestStructuredMode(t *testing.T) {
+	ce := newEvent()
+	event, err := cloudevents.ToEnsignStructured(ce)
+	require.NoError(t, err, "could not convert cloudevent to ensign event")
+	require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+	require.Equal(t, cloudevents.MediaType, event.Metadata.Get(cloudevents.ContentTypeKey))
+	require.Equal(t, "com.example.someevent", event.Metadata.Get("ce_type"))
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(event.Data, &payload))
+	require.Equal(t, "A234-1234-1234", payload["id"])
+
+	cmp, err := cloudevents.FromEnsign(event)
+	require.NoError(t, err, "could not convert ensign event to cloudevent")
+	require.Equal(t, ce, cmp)
+
+	// An invalid structured payload cannot be converted
+	event.Data = []byte("not json")
+	_, err = cloudevents.FromEnsign(event)
+	require.ErrorIs(t, err, cloudevents.ErrInvalidEncoding)
+}
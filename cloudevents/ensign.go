@@ -0,0 +1,174 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rotationalio/go-ensign"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+const (
+	// MetadataPrefix is prepended to the names of the CloudEvent attributes and
+	// extensions that are stored in the metadata of an Ensign event in the binary
+	// content mode, e.g. the type of the CloudEvent is stored in the ce_type key.
+	MetadataPrefix = "ce_"
+
+	// ContentTypeKey is the metadata key that marks an Ensign event as a CloudEvent in
+	// the structured content mode; its value is the MediaType.
+	ContentTypeKey = "content-type"
+)
+
+// ToEnsign converts the CloudEvent to an Ensign event using the binary content mode.
+// The attributes and extensions of the CloudEvent are stored in the event metadata
+// using the MetadataPrefix, the data is the event payload, and the time is used as the
+// created timestamp. The mimetype of the event is parsed from the data content type;
+// if it is not specified or cannot be parsed, application/octet-stream is used.
+func ToEnsign(ce *Event) (_ *ensign.Event, err error) {
+	if err = ce.Validate(); err != nil {
+		return nil, err
+	}
+
+	event := &ensign.Event{
+		Metadata: make(ensign.Metadata, 7+len(ce.Extensions)),
+		Data:     ce.Data,
+		Mimetype: mimetype.ApplicationOctetStream,
+		Created:  ce.Time,
+	}
+
+	for name, value := range ce.Extensions {
+		event.Metadata.Set(MetadataPrefix+name, value)
+	}
+
+	setAttribute(event.Metadata, "id", ce.ID)
+	setAttribute(event.Metadata, "source", ce.Source)
+	setAttribute(event.Metadata, "specversion", ce.SpecVersion)
+	setAttribute(event.Metadata, "type", ce.Type)
+	setAttribute(event.Metadata, "datacontenttype", ce.DataContentType)
+	setAttribute(event.Metadata, "dataschema", ce.DataSchema)
+	setAttribute(event.Metadata, "subject", ce.Subject)
+
+	if ce.DataContentType != "" {
+		if mime, err := mimetype.Parse(ce.DataContentType); err == nil {
+			event.Mimetype = mime
+		}
+	}
+	return event, nil
+}
+
+// ToEnsignStructured converts the CloudEvent to an Ensign event using the structured
+// content mode: the payload is the CloudEvent encoded in the JSON event format and the
+// event is marked with the ContentTypeKey. The id, source, and type of the CloudEvent
+// are also stored in the event metadata so that events can be filtered without decoding
+// the payload.
+func ToEnsignStructured(ce *Event) (_ *ensign.Event, err error) {
+	if err = ce.Validate(); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if data, err = json.Marshal(ce); err != nil {
+		return nil, err
+	}
+
+	event := &ensign.Event{
+		Metadata: ensign.Metadata{ContentTypeKey: MediaType},
+		Data:     data,
+		Mimetype: mimetype.ApplicationJSON,
+		Created:  ce.Time,
+	}
+
+	setAttribute(event.Metadata, "id", ce.ID)
+	setAttribute(event.Metadata, "source", ce.Source)
+	setAttribute(event.Metadata, "type", ce.Type)
+	return event, nil
+}
+
+// FromEnsign converts an Ensign event to a CloudEvent, decoding the payload if the event
+// is in the structured content mode and otherwise reading the attributes from the event
+// metadata. Metadata that does not use the MetadataPrefix is not included in the
+// CloudEvent. If the event does not have the required attributes, they are derived from
+// the Ensign event where possible: the id from the event ID, the source from the topic
+// ID, and the type from the name of the event type; the spec version defaults to
+// SpecVersion. An error is returned if the CloudEvent is not valid.
+func FromEnsign(event *ensign.Event) (ce *Event, err error) {
+	ce = &Event{}
+	if isStructured(event.Metadata.Get(ContentTypeKey)) {
+		if err = ce.UnmarshalJSON(event.Data); err != nil {
+			return nil, err
+		}
+
+		if err = ce.Validate(); err != nil {
+			return nil, err
+		}
+		return ce, nil
+	}
+
+	for key, value := range event.Metadata {
+		if !strings.HasPrefix(key, MetadataPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(key, MetadataPrefix)
+		switch name {
+		case "id":
+			ce.ID = value
+		case "source":
+			ce.Source = value
+		case "specversion":
+			ce.SpecVersion = value
+		case "type":
+			ce.Type = value
+		case "datacontenttype":
+			ce.DataContentType = value
+		case "dataschema":
+			ce.DataSchema = value
+		case "subject":
+			ce.Subject = value
+		default:
+			ce.SetExtension(name, value)
+		}
+	}
+
+	ce.Data = event.Data
+	ce.Time = event.Created
+
+	if ce.ID == "" {
+		ce.ID = event.ID()
+	}
+
+	if ce.Source == "" {
+		if topicID := event.TopicID(); topicID != "" {
+			ce.Source = fmt.Sprintf("/ensign/topics/%s", topicID)
+		}
+	}
+
+	if ce.Type == "" && event.Type != nil {
+		ce.Type = event.Type.Name
+	}
+
+	if ce.SpecVersion == "" {
+		ce.SpecVersion = SpecVersion
+	}
+
+	if ce.DataContentType == "" && event.Mimetype != mimetype.Unspecified {
+		ce.DataContentType = event.Mimetype.MimeType()
+	}
+
+	if err = ce.Validate(); err != nil {
+		return nil, err
+	}
+	return ce, nil
+}
+
+func setAttribute(meta ensign.Metadata, name, value string) {
+	if value != "" {
+		meta.Set(MetadataPrefix+name, value)
+	}
+}
+
+func isStructured(contentType string) bool {
+	contentType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return strings.EqualFold(contentType, MediaType)
+}
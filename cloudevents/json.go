@@ -0,0 +1,138 @@
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MediaType is the content type of a CloudEvent encoded in the structured content
+// mode using the JSON event format.
+const MediaType = "application/cloudevents+json"
+
+// MarshalJSON encodes the event in the CloudEvents JSON event format. If the data
+// content type is JSON (or is not specified) and the data is valid JSON, the data is
+// embedded in the data member, otherwise it is base64 encoded in data_base64.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, 8+len(e.Extensions))
+	for name, value := range e.Extensions {
+		out[name] = value
+	}
+
+	out["id"] = e.ID
+	out["source"] = e.Source
+	out["specversion"] = e.SpecVersion
+	out["type"] = e.Type
+
+	if e.DataContentType != "" {
+		out["datacontenttype"] = e.DataContentType
+	}
+
+	if e.DataSchema != "" {
+		out["dataschema"] = e.DataSchema
+	}
+
+	if e.Subject != "" {
+		out["subject"] = e.Subject
+	}
+
+	if !e.Time.IsZero() {
+		out["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	if e.Data != nil {
+		if isJSON(e.DataContentType) && json.Valid(e.Data) {
+			out["data"] = json.RawMessage(e.Data)
+		} else {
+			out["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes an event in the CloudEvents JSON event format. Members that are
+// not context attributes are decoded as extensions; extension values that are not
+// strings (e.g. integers or booleans) are stored using their JSON representation.
+func (e *Event) UnmarshalJSON(data []byte) (err error) {
+	var in map[string]json.RawMessage
+	if err = json.Unmarshal(data, &in); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEncoding, err)
+	}
+
+	*e = Event{}
+	strs := map[string]*string{
+		"id":              &e.ID,
+		"source":          &e.Source,
+		"specversion":     &e.SpecVersion,
+		"type":            &e.Type,
+		"datacontenttype": &e.DataContentType,
+		"dataschema":      &e.DataSchema,
+		"subject":         &e.Subject,
+	}
+
+	for name, raw := range in {
+		if dst, ok := strs[name]; ok {
+			if err = json.Unmarshal(raw, dst); err != nil {
+				return fmt.Errorf("%w: %s must be a string", ErrInvalidEncoding, name)
+			}
+			continue
+		}
+
+		switch name {
+		case "time", "data", "data_base64":
+			// Decoded after the data content type is known
+		default:
+			var value string
+			if err = json.Unmarshal(raw, &value); err != nil {
+				value = string(raw)
+			}
+			e.SetExtension(name, value)
+		}
+	}
+
+	if raw, ok := in["time"]; ok {
+		var ts string
+		if err = json.Unmarshal(raw, &ts); err != nil {
+			return fmt.Errorf("%w: time must be a string", ErrInvalidEncoding)
+		}
+
+		if e.Time, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return fmt.Errorf("%w: could not parse time: %s", ErrInvalidEncoding, err)
+		}
+	}
+
+	if raw, ok := in["data_base64"]; ok {
+		var encoded string
+		if err = json.Unmarshal(raw, &encoded); err != nil {
+			return fmt.Errorf("%w: data_base64 must be a string", ErrInvalidEncoding)
+		}
+
+		if e.Data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return fmt.Errorf("%w: could not decode data_base64: %s", ErrInvalidEncoding, err)
+		}
+	} else if raw, ok := in["data"]; ok {
+		// Non-JSON data is embedded as a JSON string, e.g. text/plain or text/xml data.
+		var text string
+		if !isJSON(e.DataContentType) && json.Unmarshal(raw, &text) == nil {
+			e.Data = []byte(text)
+		} else {
+			e.Data = []byte(raw)
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the content type is JSON or is not specified, which implies JSON in
+// the JSON event format.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	contentType = strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	return contentType == "application/json" || contentType == "text/json" || strings.HasSuffix(contentType, "+json")
+}
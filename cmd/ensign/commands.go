@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const topicsUsage = `usage: ensign topics <list|create|archive|destroy> [arguments]
+
+  topics list                            list the topics in the project
+  topics create <name>                   create a topic and wait until it is ready
+  topics archive <name>                  archive a topic, making it read-only
+  topics destroy -confirm <name> <name>  destroy a topic and all of its data
+
+flags:
+`
+
+func (c *cli) topics(ctx context.Context, args []string) (err error) {
+	flags := c.flagSet("topics", topicsUsage)
+	confirm := flags.String("confirm", "", "the name of the topic to destroy, required to confirm destroy")
+
+	if len(args) > 0 {
+		if err = flags.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+	}
+
+	var client *ensign.Client
+	switch {
+	case len(args) == 0:
+		flags.Usage()
+		return errUsage
+	case args[0] == "list" && flags.NArg() == 0:
+		if client, err = c.connect(); err != nil {
+			return err
+		}
+		return c.listTopics(ctx, client)
+	case args[0] == "create" && flags.NArg() == 1:
+		if client, err = c.connect(); err != nil {
+			return err
+		}
+
+		var topicID string
+		if topicID, err = client.CreateTopicAndWait(ctx, flags.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, topicID)
+		return nil
+	case args[0] == "archive" && flags.NArg() == 1:
+		if client, err = c.connect(); err != nil {
+			return err
+		}
+
+		var tomb *ensign.Tombstone
+		if tomb, err = client.ArchiveTopicByName(ctx, flags.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", tomb.TopicID, tomb.Name, tomb.State)
+		return nil
+	case args[0] == "destroy" && flags.NArg() == 1:
+		if client, err = c.connect(); err != nil {
+			return err
+		}
+
+		var tomb *ensign.Tombstone
+		if tomb, err = client.DestroyTopicByName(ctx, flags.Arg(0), *confirm); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", tomb.TopicID, tomb.Name, tomb.State)
+		return nil
+	default:
+		flags.Usage()
+		return errUsage
+	}
+}
+
+func (c *cli) listTopics(ctx context.Context, client *ensign.Client) (err error) {
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tREADONLY\tOFFSET\tSHARDS")
+
+	iter := client.Topics(ctx)
+	for iter.Next() {
+		topic := iter.Topic()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%d\t%d\n", formatULID(topic.Id), topic.Name, topic.Status, topic.Readonly, topic.Offset, topic.Shards)
+	}
+
+	if err = iter.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+const infoUsage = `usage: ensign info [topic ...]
+
+Prints the number of topics and events in the project; if topic names or IDs are
+specified, the statistics are limited to the specified topics.
+`
+
+func (c *cli) info(ctx context.Context, args []string) (err error) {
+	flags := c.flagSet("info", infoUsage)
+	if err = flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	var client *ensign.Client
+	if client, err = c.connect(); err != nil {
+		return err
+	}
+
+	// Info requires topic IDs, so resolve any topic names that are not IDs.
+	topicIDs := make([]string, 0, flags.NArg())
+	for _, topic := range flags.Args() {
+		if _, perr := ulid.Parse(topic); perr != nil {
+			if topic, err = client.TopicID(ctx, topic); err != nil {
+				return err
+			}
+		}
+		topicIDs = append(topicIDs, topic)
+	}
+
+	var info *api.ProjectInfo
+	if info, err = client.Info(ctx, topicIDs...); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "project:\t%s\n", formatULID(info.ProjectId))
+	fmt.Fprintf(w, "topics:\t%d\n", info.NumTopics)
+	fmt.Fprintf(w, "readonly topics:\t%d\n", info.NumReadonlyTopics)
+	fmt.Fprintf(w, "events:\t%d\n", info.Events)
+	fmt.Fprintf(w, "duplicates:\t%d\n", info.Duplicates)
+	fmt.Fprintf(w, "data size:\t%d bytes\n", info.DataSizeBytes)
+
+	if len(info.Topics) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "TOPIC\tEVENTS\tDUPLICATES\tDATA SIZE")
+		for _, topic := range info.Topics {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", formatULID(topic.TopicId), topic.Events, topic.Duplicates, topic.DataSizeBytes)
+		}
+	}
+	return w.Flush()
+}
+
+const publishUsage = `usage: ensign publish [flags] <topic> [file]
+
+Publishes one event per line of the file or of stdin if no file is specified (empty
+lines are skipped), then waits for the events to be acked.
+
+flags:
+`
+
+func (c *cli) publish(ctx context.Context, args []string) (err error) {
+	flags := c.flagSet("publish", publishUsage)
+	var (
+		mime     = flags.String("mimetype", "text/plain", "the mimetype of the event data")
+		whole    = flags.Bool("whole", false, "publish the entire input as a single event")
+		key      = flags.String("key", "", "the partition key of the events")
+		metadata = make(metadataFlag)
+	)
+	flags.Var(metadata, "meta", "add `key=value` metadata to the events (repeatable)")
+
+	if err = flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var mt mimetype.MIME
+	if mt, err = mimetype.Parse(*mime); err != nil {
+		return err
+	}
+
+	var input io.Reader = c.stdin
+	if flags.NArg() == 2 {
+		var f *os.File
+		if f, err = os.Open(flags.Arg(1)); err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+
+	var client *ensign.Client
+	if client, err = c.connect(); err != nil {
+		return err
+	}
+
+	newEvent := func(data []byte) *ensign.Event {
+		event := &ensign.Event{
+			Metadata: make(ensign.Metadata, len(metadata)),
+			Data:     data,
+			Mimetype: mt,
+			Created:  time.Now(),
+		}
+
+		for k, v := range metadata {
+			event.Metadata[k] = v
+		}
+
+		if *key != "" {
+			event.Key = []byte(*key)
+		}
+		return event
+	}
+
+	var events []*ensign.Event
+	if *whole {
+		var data []byte
+		if data, err = io.ReadAll(input); err != nil {
+			return err
+		}
+		events = append(events, newEvent(data))
+	} else {
+		reader := bufio.NewReader(input)
+		for {
+			line, rerr := reader.ReadBytes('\n')
+			if line = bytes.TrimRight(line, "\r\n"); len(line) > 0 {
+				events = append(events, newEvent(line))
+			}
+
+			if rerr != nil {
+				if errors.Is(rerr, io.EOF) {
+					break
+				}
+				return rerr
+			}
+		}
+	}
+
+	for _, event := range events {
+		if err = client.PublishContext(ctx, flags.Arg(0), event); err != nil {
+			return err
+		}
+	}
+
+	var nacked int
+	for _, event := range events {
+		if _, err = event.WaitForAck(ctx); err != nil {
+			var nack *ensign.NackError
+			if !errors.As(err, &nack) {
+				return err
+			}
+			fmt.Fprintf(c.stderr, "event nacked: %s\n", err)
+			nacked++
+		}
+	}
+
+	fmt.Fprintf(c.stderr, "published %d events to %s\n", len(events)-nacked, flags.Arg(0))
+	if nacked > 0 {
+		return fmt.Errorf("%d of %d events were nacked", nacked, len(events))
+	}
+	return nil
+}
+
+const subscribeUsage = `usage: ensign subscribe [flags] <topic ...>
+
+Prints the events published to the topics to stdout as JSON lines, acking each event
+once it has been printed, until interrupted.
+
+flags:
+`
+
+func (c *cli) subscribe(ctx context.Context, args []string) (err error) {
+	flags := c.flagSet("subscribe", subscribeUsage)
+	limit := flags.Int("n", 0, "exit after printing n events (0 for no limit)")
+
+	if err = flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var client *ensign.Client
+	if client, err = c.connect(); err != nil {
+		return err
+	}
+
+	var sub *ensign.Subscription
+	if sub, err = client.Subscribe(flags.Args()...); err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	out := newEventWriter(c.stdout)
+	for n := 0; *limit <= 0 || n < *limit; n++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return sub.Err()
+			}
+
+			if err = out.Write(event); err != nil {
+				return err
+			}
+
+			if _, err = event.Ack(); err != nil && !errors.Is(err, ensign.ErrReadOnlyClient) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const queryUsage = `usage: ensign query [flags] <query> [arg ...]
+
+Executes the EnSQL query and prints the results as JSON lines. Arguments are bound to
+the $1, $2, etc. placeholders of the query; arguments that can be parsed as integers,
+floats, or bools are bound as such, otherwise they are bound as strings.
+
+flags:
+`
+
+func (c *cli) query(ctx context.Context, args []string) (err error) {
+	flags := c.flagSet("query", queryUsage)
+	explain := flags.Bool("explain", false, "print the query plan rather than the results")
+
+	if err = flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var query *api.Query
+	if query, err = ensign.NewQuery(flags.Arg(0), parseArgs(flags.Args()[1:])...); err != nil {
+		return err
+	}
+
+	var client *ensign.Client
+	if client, err = c.connect(); err != nil {
+		return err
+	}
+
+	if *explain {
+		var plan *api.QueryExplanation
+		if plan, err = client.Explain(ctx, query); err != nil {
+			return err
+		}
+
+		var data []byte
+		if data, err = (protojson.MarshalOptions{Multiline: true}).Marshal(plan); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.stdout, string(data))
+		return err
+	}
+
+	var cursor *ensign.QueryCursor
+	if cursor, err = client.EnSQL(ctx, query); err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	out := newEventWriter(c.stdout)
+	for {
+		var event *ensign.Event
+		if event, err = cursor.FetchOne(); err != nil {
+			if errors.Is(err, ensign.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if err = out.Write(event); err != nil {
+			return err
+		}
+	}
+}
+
+// Parse the query arguments from the command line as integers, floats, or bools,
+// falling back to strings.
+func parseArgs(args []string) []interface{} {
+	params := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		if i, err := strconv.ParseInt(arg, 10, 64); err == nil {
+			params = append(params, i)
+		} else if f, err := strconv.ParseFloat(arg, 64); err == nil {
+			params = append(params, f)
+		} else if b, err := strconv.ParseBool(arg); err == nil {
+			params = append(params, b)
+		} else {
+			params = append(params, arg)
+		}
+	}
+	return params
+}
+
+// metadataFlag collects repeated -meta key=value flags.
+type metadataFlag map[string]string
+
+func (m metadataFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m metadataFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("metadata must be specified as key=value")
+	}
+	m[key] = value
+	return nil
+}
+
+func formatULID(id []byte) string {
+	var uid ulid.ULID
+	if err := uid.UnmarshalBinary(id); err != nil {
+		return ""
+	}
+	return uid.String()
+}
@@ -0,0 +1,161 @@
+/*
+Command ensign is a command line client for Ensign built on the Go SDK that allows
+operators to manage topics, inspect projects, publish and subscribe to events, and run
+EnSQL queries without writing Go. Usage:
+
+	ensign [flags] <command> [arguments]
+
+The commands are:
+
+	topics list                      list the topics in the project
+	topics create <name>             create a topic
+	topics archive <name>            archive a topic, making it read-only
+	topics destroy -confirm <name> <name>
+	                                 destroy a topic and all of its data
+	info [topic ...]                 print project info, optionally for specific topics
+	publish [flags] <topic> [file]   publish one event per line of the file or stdin
+	subscribe [flags] <topic ...>    print events to stdout as they are published
+	query [flags] <query> [arg ...]  execute an EnSQL query and print the results
+
+By default the client is configured from the $ENSIGN_CLIENT_ID and
+$ENSIGN_CLIENT_SECRET environment variables (and the other environment variables read
+by ensign.New); use -creds to load credentials downloaded from the web application.
+Events are printed as JSON lines; data that is not valid UTF-8 is base64 encoded in the
+data_base64 field.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/rotationalio/go-ensign"
+)
+
+const usage = `usage: ensign [flags] <command> [arguments]
+
+commands:
+  topics list                      list the topics in the project
+  topics create <name>             create a topic
+  topics archive <name>            archive a topic, making it read-only
+  topics destroy -confirm <name> <name>
+                                   destroy a topic and all of its data
+  info [topic ...]                 print project info, optionally for specific topics
+  publish [flags] <topic> [file]   publish one event per line of the file or stdin
+  subscribe [flags] <topic ...>    print events to stdout as they are published
+  query [flags] <query> [arg ...]  execute an EnSQL query and print the results
+
+flags:
+`
+
+// errUsage is returned when the command line arguments are invalid; the usage has
+// already been printed so the error is not printed again.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cmd := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	if err := cmd.run(ctx, os.Args[1:]); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "ensign: %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// cli holds the streams of the command and the options used to create the client so
+// that the commands can be tested against a mock Ensign server.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	opts   []ensign.Option
+	client *ensign.Client
+}
+
+// Parse the global flags and dispatch to the command.
+func (c *cli) run(ctx context.Context, args []string) (err error) {
+	flags := c.flagSet("ensign", usage)
+	var (
+		creds    = flags.String("creds", "", "path to a JSON credentials file")
+		endpoint = flags.String("endpoint", "", "the Ensign endpoint to connect to")
+		insecure = flags.Bool("insecure", false, "connect to the endpoint without TLS")
+		authURL  = flags.String("auth-url", "", "the Quarterdeck authentication URL")
+	)
+
+	if err = flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	if *creds != "" {
+		c.opts = append(c.opts, ensign.WithLoadCredentials(*creds))
+	}
+
+	if *endpoint != "" {
+		c.opts = append(c.opts, ensign.WithEnsignEndpoint(*endpoint, *insecure))
+	}
+
+	if *authURL != "" {
+		c.opts = append(c.opts, ensign.WithAuthenticator(*authURL, false))
+	}
+
+	var command func(context.Context, []string) error
+	switch name := flags.Arg(0); name {
+	case "topics":
+		command = c.topics
+	case "info":
+		command = c.info
+	case "publish":
+		command = c.publish
+	case "subscribe":
+		command = c.subscribe
+	case "query":
+		command = c.query
+	default:
+		fmt.Fprintf(c.stderr, "ensign: unknown command %q\n", name)
+		flags.Usage()
+		return errUsage
+	}
+
+	defer func() {
+		if c.client != nil {
+			if cerr := c.client.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}()
+	return command(ctx, flags.Args()[1:])
+}
+
+// Connect to Ensign the first time a command needs the client.
+func (c *cli) connect() (_ *ensign.Client, err error) {
+	if c.client == nil {
+		if c.client, err = ensign.New(c.opts...); err != nil {
+			return nil, err
+		}
+	}
+	return c.client, nil
+}
+
+// Create a flag set for a command that prints the usage to stderr.
+func (c *cli) flagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprint(c.stderr, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/topics"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const topicID = "01GWM89049D49FHJH81BT8795H"
+
+// Create a cli connected to the mock with the streams captured for assertions.
+func newCLI(emock *mock.Ensign, stdin string, opts ...ensign.Option) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := &cli{
+		stdin:  strings.NewReader(stdin),
+		stdout: stdout,
+		stderr: stderr,
+		opts:   append([]ensign.Option{ensign.WithMock(emock), ensign.WithAuthenticator("", true)}, opts...),
+	}
+	return cmd, stdout, stderr
+}
+
+func TestUsage(t *testing.T) {
+	cmd, _, stderr := newCLI(nil, "")
+	require.ErrorIs(t, cmd.run(context.Background(), nil), errUsage)
+	require.Contains(t, stderr.String(), "usage: ensign")
+
+	cmd, _, stderr = newCLI(nil, "")
+	require.ErrorIs(t, cmd.run(context.Background(), []string{"nope"}), errUsage)
+	require.Contains(t, stderr.String(), `unknown command "nope"`)
+
+	cmd, _, stderr = newCLI(nil, "")
+	require.ErrorIs(t, cmd.run(context.Background(), []string{"topics", "create"}), errUsage)
+	require.Contains(t, stderr.String(), "usage: ensign topics")
+}
+
+func TestTopics(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	emock.OnListTopics = func(context.Context, *api.PageInfo) (*api.TopicsPage, error) {
+		id := ulid.MustParse(topicID)
+		return &api.TopicsPage{Topics: []*api.Topic{{Id: id[:], Name: "orders", Status: api.TopicState_READY, Offset: 42, Shards: 1}}}, nil
+	}
+
+	emock.OnDeleteTopic = func(_ context.Context, in *api.TopicMod) (*api.TopicStatus, error) {
+		if in.Operation == api.TopicMod_ARCHIVE {
+			return &api.TopicStatus{Id: in.Id, State: api.TopicState_READONLY}, nil
+		}
+		return &api.TopicStatus{Id: in.Id, State: api.TopicState_DELETING}, nil
+	}
+
+	cmd, stdout, _ := newCLI(emock, "")
+	require.NoError(t, cmd.run(context.Background(), []string{"topics", "list"}))
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []string{"ID", "NAME", "STATUS", "READONLY", "OFFSET", "SHARDS"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{topicID, "orders", "READY", "false", "42", "1"}, strings.Fields(lines[1]))
+
+	cache := topics.NewCache(nil)
+	cache.Set("orders", topicID)
+
+	cmd, stdout, _ = newCLI(emock, "", ensign.WithTopicCache(cache))
+	require.NoError(t, cmd.run(context.Background(), []string{"topics", "archive", "orders"}))
+	require.Equal(t, topicID+"\torders\tREADONLY\n", stdout.String())
+
+	// Destroying a topic must be confirmed with the topic name
+	cmd, _, _ = newCLI(emock, "", ensign.WithTopicCache(cache))
+	require.ErrorIs(t, cmd.run(context.Background(), []string{"topics", "destroy", "orders"}), ensign.ErrDestroyNotConfirmed)
+
+	cmd, stdout, _ = newCLI(emock, "", ensign.WithTopicCache(cache))
+	require.NoError(t, cmd.run(context.Background(), []string{"topics", "destroy", "-confirm", "orders", "orders"}))
+	require.Equal(t, topicID+"\torders\tDELETING\n", stdout.String())
+}
+
+func TestInfo(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	var requested [][]byte
+	emock.OnInfo = func(_ context.Context, in *api.InfoRequest) (*api.ProjectInfo, error) {
+		requested = in.Topics
+		return &api.ProjectInfo{NumTopics: 3, Events: 1024, Topics: []*api.TopicInfo{{TopicId: in.Topics[0], Events: 12}}}, nil
+	}
+
+	cache := topics.NewCache(nil)
+	cache.Set("orders", topicID)
+
+	cmd, stdout, _ := newCLI(emock, "", ensign.WithTopicCache(cache))
+	require.NoError(t, cmd.run(context.Background(), []string{"info", "orders"}))
+	require.Len(t, requested, 1, "expected the topic name to be resolved to a topic ID")
+	require.Equal(t, topicID, formatULID(requested[0]))
+	require.Contains(t, stdout.String(), "events:           1024\n")
+	require.Contains(t, stdout.String(), topicID+"  12")
+}
+
+func TestPublish(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	cmd, _, stderr := newCLI(emock, "alpha\n\nbravo\r\ncharlie")
+	require.NoError(t, cmd.run(context.Background(), []string{"publish", "-meta", "source=cli", "-key", "k1", topicID}))
+	require.Equal(t, "published 3 events to "+topicID+"\n", stderr.String())
+
+	published := recorder.Published()
+	require.Len(t, published, 3)
+	for i, data := range []string{"alpha", "bravo", "charlie"} {
+		require.Equal(t, data, string(published[i].Event.Data))
+		require.Equal(t, mimetype.TextPlain, published[i].Event.Mimetype)
+		require.Equal(t, "cli", published[i].Event.Metadata["source"])
+	}
+
+	// Publish a file as a single event
+	path := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(path, []byte("{\n  \"order\": 1\n}\n"), 0600))
+
+	cmd, _, _ = newCLI(emock, "")
+	require.NoError(t, cmd.run(context.Background(), []string{"publish", "-whole", "-mimetype", "application/json", topicID, path}))
+
+	published = recorder.Published()
+	require.Len(t, published, 4)
+	require.Equal(t, "{\n  \"order\": 1\n}\n", string(published[3].Event.Data))
+	require.Equal(t, mimetype.ApplicationJSON, published[3].Event.Mimetype)
+
+	cmd, _, _ = newCLI(emock, "")
+	require.Error(t, cmd.run(context.Background(), []string{"publish", "-meta", "novalue", topicID}))
+}
+
+func TestSubscribe(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	acks := make(chan *api.Ack, 2)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	env := mock.NewEventWrapper()
+	env.Wrap(&api.Event{Data: []byte("hello"), Mimetype: mimetype.TextPlain, Metadata: map[string]string{"source": "test"}, Created: timestamppb.Now()})
+	handler.Send <- env
+	handler.Send <- mock.NewEventWrapper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmd, stdout, _ := newCLI(emock, "")
+	require.NoError(t, cmd.run(ctx, []string{"subscribe", "-n", "2", topicID}))
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+
+	rec := &eventRecord{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), rec))
+	require.Equal(t, "hello", *rec.Data)
+	require.Equal(t, "text/plain", rec.Mimetype)
+	require.Equal(t, map[string]string{"source": "test"}, rec.Metadata)
+	require.NotEmpty(t, rec.ID)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-acks:
+		case <-ctx.Done():
+			t.Fatal("expected the printed events to be acked")
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	var query *api.Query
+	emock.OnEnSQL = func(in *api.Query, stream api.Ensign_EnSQLServer) error {
+		query = in
+		for _, data := range [][]byte{[]byte(`{"order":1}`), {0xff, 0xfe}} {
+			wrapper := &api.EventWrapper{Committed: timestamppb.Now()}
+			if err := wrapper.Wrap(&api.Event{Data: data, Mimetype: mimetype.ApplicationJSON}); err != nil {
+				return err
+			}
+
+			if err := stream.Send(wrapper); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cmd, stdout, _ := newCLI(emock, "")
+	require.NoError(t, cmd.run(context.Background(), []string{"query", "SELECT * FROM orders WHERE id = $1 AND region = $2", "42", "us-east"}))
+
+	require.Len(t, query.Params, 2)
+	require.Equal(t, int64(42), query.Params[0].GetI())
+	require.Equal(t, "us-east", query.Params[1].GetS())
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"data":"{\"order\":1}"`)
+	require.Contains(t, lines[1], `"data_base64":"//4="`)
+}
+
+func TestParseArgs(t *testing.T) {
+	require.Equal(t, []interface{}{int64(-3), 2.5, true, "hello"}, parseArgs([]string{"-3", "2.5", "true", "hello"}))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rotationalio/go-ensign"
+)
+
+// eventRecord is the JSON representation of an event printed by the subscribe and query
+// commands. Data is printed as a string if it is valid UTF-8, otherwise it is base64
+// encoded in DataBase64 so that binary events can be printed on a single line.
+type eventRecord struct {
+	ID         string            `json:"id,omitempty"`
+	TopicID    string            `json:"topic_id,omitempty"`
+	Offset     uint64            `json:"offset,omitempty"`
+	Mimetype   string            `json:"mimetype"`
+	Type       string            `json:"type,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Created    *time.Time        `json:"created,omitempty"`
+	Committed  *time.Time        `json:"committed,omitempty"`
+	Data       *string           `json:"data,omitempty"`
+	DataBase64 []byte            `json:"data_base64,omitempty"`
+}
+
+// eventWriter writes events to the output as JSON lines.
+type eventWriter struct {
+	enc *json.Encoder
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &eventWriter{enc: enc}
+}
+
+func (w *eventWriter) Write(event *ensign.Event) error {
+	rec := &eventRecord{
+		ID:       event.ID(),
+		TopicID:  event.TopicID(),
+		Mimetype: event.Mimetype.MimeType(),
+		Key:      string(event.Key),
+		Metadata: event.Metadata,
+	}
+	rec.Offset, _ = event.Offset()
+
+	if event.Type != nil {
+		rec.Type = event.Type.Version()
+	}
+
+	if !event.Created.IsZero() {
+		created := event.Created
+		rec.Created = &created
+	}
+
+	if committed := event.Committed(); !committed.IsZero() {
+		rec.Committed = &committed
+	}
+
+	if utf8.Valid(event.Data) {
+		data := string(event.Data)
+		rec.Data = &data
+	} else {
+		rec.DataBase64 = event.Data
+	}
+	return w.enc.Encode(rec)
+}
@@ -0,0 +1,204 @@
+package ensign
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Expected lengths of the API key credentials generated by Quarterdeck.
+const (
+	ClientIDLength     = 32
+	ClientSecretLength = 64
+)
+
+// Credentials are the API key credentials downloaded from the Rotational web app as a
+// JSON file when the API key is created. The Credentials can be loaded from the file
+// with LoadCredentials or read from another source with ReadCredentials.
+type Credentials struct {
+	ClientID     string `json:"ClientID"`
+	ClientSecret string `json:"ClientSecret"`
+}
+
+// CredentialsError describes why a credentials file could not be loaded, naming the
+// path of the file (if known) and the field that is invalid (if applicable) so that
+// users can quickly fix malformed credentials. CredentialsErrors wrap
+// ErrInvalidCredentials so they can be checked with errors.Is.
+type CredentialsError struct {
+	Path   string
+	Field  string
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *CredentialsError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrInvalidCredentials.Error())
+	if e.Path != "" {
+		fmt.Fprintf(&b, " in %s", e.Path)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, ": field %s", e.Field)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Reason)
+	return b.String()
+}
+
+// Unwrap allows CredentialsErrors to be compared with ErrInvalidCredentials.
+func (e *CredentialsError) Unwrap() error {
+	return ErrInvalidCredentials
+}
+
+// Keys of files that are commonly mistaken for the credentials file.
+var tokenKeys = []string{"access_token", "refresh_token", "AccessToken", "RefreshToken"}
+
+// LoadCredentials loads and validates the API key credentials from the JSON file at
+// the specified path. If the file is malformed, a *CredentialsError is returned that
+// describes the problem and how to fix it.
+func LoadCredentials(path string) (_ *Credentials, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var creds *Credentials
+	if creds, err = ReadCredentials(f); err != nil {
+		var cerr *CredentialsError
+		if errors.As(err, &cerr) {
+			cerr.Path = path
+		}
+		return nil, err
+	}
+	return creds, nil
+}
+
+// ReadCredentials reads and validates the JSON API key credentials from the reader,
+// e.g. to embed credentials in other tools. If the credentials are malformed, a
+// *CredentialsError is returned that describes the problem.
+func ReadCredentials(r io.Reader) (_ *Credentials, err error) {
+	var data []byte
+	if data, err = io.ReadAll(r); err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, &CredentialsError{Reason: "credentials are empty"}
+	}
+
+	// Curly quotes are usually introduced when the credentials are copied through a
+	// rich text editor or chat application and cause a confusing JSON syntax error.
+	if idx := bytes.IndexAny(data, "“”‘’"); idx >= 0 {
+		line, col := position(data, idx)
+		reason := fmt.Sprintf("curly quote at line %d column %d; replace curly quotes with straight quotes (\")", line, col)
+		return nil, &CredentialsError{Reason: reason + " or download the credentials again instead of copying them through a text editor"}
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(data, &fields); err != nil {
+		var serr *json.SyntaxError
+		if errors.As(err, &serr) {
+			line, col := position(data, int(serr.Offset))
+			return nil, &CredentialsError{Reason: fmt.Sprintf("invalid json at line %d column %d: %s; was the file truncated?", line, col, serr)}
+		}
+		return nil, &CredentialsError{Reason: "credentials must be a json object with ClientID and ClientSecret fields"}
+	}
+
+	// Detect if the user passed a tokens file rather than the API key credentials.
+	for _, key := range tokenKeys {
+		if _, ok := fields[key]; ok {
+			reason := "file contains access tokens rather than API key credentials"
+			return nil, &CredentialsError{Reason: reason + "; download the API key credentials file from the Rotational web app"}
+		}
+	}
+
+	creds := &Credentials{}
+	if creds.ClientID, err = credentialsField(fields, keyClientID); err != nil {
+		return nil, err
+	}
+
+	if creds.ClientSecret, err = credentialsField(fields, keyClientSecret); err != nil {
+		return nil, err
+	}
+
+	if err = creds.Validate(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Validate checks that the client ID and secret have the format of API key credentials
+// generated by Quarterdeck, detecting truncated or mangled credentials.
+func (c *Credentials) Validate() error {
+	if err := validateKey(keyClientID, c.ClientID, ClientIDLength); err != nil {
+		return err
+	}
+	return validateKey(keyClientSecret, c.ClientSecret, ClientSecretLength)
+}
+
+// Fetch the field from the credentials, detecting common misspellings of the key.
+func credentialsField(fields map[string]json.RawMessage, key string) (val string, err error) {
+	raw, ok := fields[key]
+	if !ok {
+		// Check for a key that differs only by case or separators, e.g. client_id.
+		normalized := normalizeKey(key)
+		for name := range fields {
+			if normalizeKey(name) == normalized {
+				return "", &CredentialsError{Field: key, Reason: fmt.Sprintf("field is missing but found %q; rename the field to %q", name, key)}
+			}
+		}
+		return "", &CredentialsError{Field: key, Reason: "required field is missing"}
+	}
+
+	if err = json.Unmarshal(raw, &val); err != nil {
+		return "", &CredentialsError{Field: key, Reason: "value must be a string"}
+	}
+	return val, nil
+}
+
+func validateKey(field, val string, length int) error {
+	switch {
+	case val == "":
+		return &CredentialsError{Field: field, Reason: "value is empty"}
+	case strings.TrimSpace(val) != val:
+		return &CredentialsError{Field: field, Reason: "value has leading or trailing whitespace"}
+	}
+
+	for _, r := range val {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return &CredentialsError{Field: field, Reason: fmt.Sprintf("value contains invalid character %q; only letters and digits are allowed", r)}
+		}
+	}
+
+	if len(val) < length {
+		return &CredentialsError{Field: field, Reason: fmt.Sprintf("value has %d characters but %d are expected; was it truncated when copied?", len(val), length)}
+	}
+
+	if len(val) > length {
+		return &CredentialsError{Field: field, Reason: fmt.Sprintf("value has %d characters but %d are expected", len(val), length)}
+	}
+	return nil
+}
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(key)
+}
+
+// Returns the 1-indexed line and column of the byte offset in the data.
+func position(data []byte, offset int) (line, col int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+
+	line = 1 + bytes.Count(data[:offset], []byte("\n"))
+	col = offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, col
+}
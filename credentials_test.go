@@ -0,0 +1,81 @@
+package ensign_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdk "github.com/rotationalio/go-ensign"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID     = "ABCDEFgHijKLMnopQRsTuvWXyZABCDEf"
+	testClientSecret = "a12BcD3EF45gHI6jKLmnOpQ78RStUVWXYzabCdE9FGHijkLmNOpq0RStUvwxyzab"
+)
+
+func TestReadCredentials(t *testing.T) {
+	creds, err := sdk.ReadCredentials(strings.NewReader(`{"ClientID": "` + testClientID + `", "ClientSecret": "` + testClientSecret + `", "ProjectID": "01H1PA4FA9G2Y79Z5FC36CWYYJ"}`))
+	require.NoError(t, err, "could not read valid credentials")
+	require.Equal(t, testClientID, creds.ClientID)
+	require.Equal(t, testClientSecret, creds.ClientSecret)
+
+	testCases := []struct {
+		data  string
+		field string
+		err   string
+	}{
+		{"", "", "credentials are empty"},
+		{"   \n", "", "credentials are empty"},
+		{`{“ClientID”: "foo"}`, "", "curly quote at line 1 column 2"},
+		{"{\n  \"ClientID\": \"" + testClientID + "\",\n  \"ClientSecret\": \"a12B", "", "invalid json at line 3"},
+		{`["ClientID", "ClientSecret"]`, "", "credentials must be a json object"},
+		{`{"access_token": "foo", "refresh_token": "bar"}`, "", "file contains access tokens rather than API key credentials"},
+		{`{"ClientSecret": "` + testClientSecret + `"}`, "ClientID", "required field is missing"},
+		{`{"client_id": "` + testClientID + `", "client_secret": "` + testClientSecret + `"}`, "ClientID", `field is missing but found "client_id"; rename the field to "ClientID"`},
+		{`{"ClientID": 42, "ClientSecret": "` + testClientSecret + `"}`, "ClientID", "value must be a string"},
+		{`{"ClientID": "", "ClientSecret": "` + testClientSecret + `"}`, "ClientID", "value is empty"},
+		{`{"ClientID": " ` + testClientID + `", "ClientSecret": "` + testClientSecret + `"}`, "ClientID", "value has leading or trailing whitespace"},
+		{`{"ClientID": "` + testClientID + `"}`, "ClientSecret", "required field is missing"},
+		{`{"ClientID": "` + testClientID + `", "ClientSecret": "` + testClientSecret[:40] + `"}`, "ClientSecret", "value has 40 characters but 64 are expected; was it truncated when copied?"},
+		{`{"ClientID": "` + testClientID + `", "ClientSecret": "` + testClientSecret + `abc"}`, "ClientSecret", "value has 67 characters but 64 are expected"},
+		{`{"ClientID": "` + testClientID + `", "ClientSecret": "` + testClientSecret[:63] + `…"}`, "ClientSecret", `value contains invalid character '…'`},
+	}
+
+	for i, tc := range testCases {
+		_, err := sdk.ReadCredentials(strings.NewReader(tc.data))
+		require.ErrorIs(t, err, sdk.ErrInvalidCredentials, "test case %d did not return an invalid credentials error", i)
+
+		var cerr *sdk.CredentialsError
+		require.True(t, errors.As(err, &cerr), "test case %d did not return a credentials error", i)
+		require.Equal(t, tc.field, cerr.Field, "test case %d field mismatch", i)
+		require.Contains(t, cerr.Reason, tc.err, "test case %d reason mismatch", i)
+	}
+}
+
+func TestLoadCredentialsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"access_token": "foo", "refresh_token": "bar"}`), 0600))
+
+	_, err := sdk.LoadCredentials(path)
+	require.ErrorIs(t, err, sdk.ErrInvalidCredentials)
+	require.EqualError(t, err, "invalid credentials in "+path+": file contains access tokens rather than API key credentials; download the API key credentials file from the Rotational web app")
+
+	_, err = sdk.NewOptions(sdk.WithLoadCredentials(path))
+	require.ErrorIs(t, err, sdk.ErrInvalidCredentials)
+
+	// The field should be named in the error message
+	path = filepath.Join(t.TempDir(), "client.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"ClientID": "`+testClientID+`", "ClientSecret": ""}`), 0600))
+
+	_, err = sdk.LoadCredentials(path)
+	require.EqualError(t, err, "invalid credentials in "+path+": field ClientSecret: value is empty")
+
+	// Credentials can be read from a reader
+	opts, err := sdk.NewOptions(sdk.WithReadCredentials(strings.NewReader(`{"ClientID": "` + testClientID + `", "ClientSecret": "` + testClientSecret + `"}`)))
+	require.NoError(t, err)
+	require.Equal(t, testClientID, opts.ClientID)
+	require.Equal(t, testClientSecret, opts.ClientSecret)
+}
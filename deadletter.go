@@ -0,0 +1,152 @@
+package ensign
+
+import (
+	"fmt"
+	"sync"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Metadata keys that record why an event was moved to the dead letter topic of a
+// subscription created WithDeadLetter.
+const (
+	DeadLetterReasonKey = "dead_letter_reason"
+	DeadLetterTopicKey  = "dead_letter_topic"
+	DeadLetterEventKey  = "dead_letter_event"
+	DeadLetterNacksKey  = "dead_letter_nacks"
+)
+
+// deadLetter counts the nacks of the events received by a subscription and republishes
+// events to the dead letter topic once they have been nacked too many times, acking the
+// original event so that it is not redelivered. Nack counts are kept in memory, so they
+// are only accurate for events redelivered to the same subscription.
+type deadLetter struct {
+	sync.Mutex
+	client   *Client
+	topic    string
+	maxNacks int
+	nacks    map[string]int
+}
+
+func newDeadLetter(client *Client, topic string, maxNacks int) *deadLetter {
+	if maxNacks < 1 {
+		maxNacks = 1
+	}
+
+	return &deadLetter{
+		client:   client,
+		topic:    topic,
+		maxNacks: maxNacks,
+		nacks:    make(map[string]int),
+	}
+}
+
+// Nack the event, or move the event to the dead letter topic and ack it if the event
+// has reached the maximum number of nacks.
+func (d *deadLetter) nack(acks Acknowledger, wrapper *api.EventWrapper, nack *api.Nack) error {
+	id := string(wrapper.Id)
+
+	d.Lock()
+	d.nacks[id]++
+	count := d.nacks[id]
+	d.Unlock()
+
+	if count < d.maxNacks {
+		return acks.Nack(nack)
+	}
+
+	reason := nack.Code.String()
+	if nack.Error != "" {
+		reason = fmt.Sprintf("%s: %s", reason, nack.Error)
+	}
+	_, err := d.move(acks, wrapper, reason, count)
+	return err
+}
+
+// Move the event to the dead letter topic and ack the original event. If the event
+// cannot be published to the dead letter topic it is nacked so that it is not lost and
+// false is returned.
+func (d *deadLetter) move(acks Acknowledger, wrapper *api.EventWrapper, reason string, nacks int) (moved bool, err error) {
+	if err = d.publish(wrapper, reason, nacks); err != nil {
+		if d.client.opts.Logger != nil {
+			d.client.opts.Logger.Error("could not publish event to dead letter topic", "topic", d.topic, "error", err)
+		}
+		return false, acks.Nack(&api.Nack{Id: wrapper.Id, Code: api.Nack_UNPROCESSED, Error: reason})
+	}
+
+	d.forget(wrapper)
+	return true, acks.Ack(&api.Ack{Id: wrapper.Id})
+}
+
+// Publish a copy of the event to the dead letter topic with the failure reason.
+func (d *deadLetter) publish(wrapper *api.EventWrapper, reason string, nacks int) (err error) {
+	orig := &Event{}
+	if err = orig.fromPB(wrapper, subscription); err != nil {
+		return err
+	}
+
+	event := &Event{
+		Metadata: make(Metadata, len(orig.Metadata)+4),
+		Data:     orig.Data,
+		Mimetype: orig.Mimetype,
+		Type:     orig.Type,
+		Created:  orig.Created,
+		Key:      orig.Key,
+	}
+
+	for key, value := range orig.Metadata {
+		event.Metadata[key] = value
+	}
+
+	event.Metadata.Set(DeadLetterReasonKey, reason)
+	event.Metadata.Set(DeadLetterTopicKey, orig.TopicID())
+	event.Metadata.Set(DeadLetterEventKey, orig.ID())
+	event.Metadata.SetInt(DeadLetterNacksKey, int64(nacks))
+
+	if err = d.client.Publish(d.topic, event); err != nil {
+		return err
+	}
+
+	if _, err = event.Wait(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stop counting the nacks of the event once it has been acked or dead lettered.
+func (d *deadLetter) forget(wrapper *api.EventWrapper) {
+	d.Lock()
+	delete(d.nacks, string(wrapper.Id))
+	d.Unlock()
+}
+
+// Moves the event to the dead letter topic immediately, e.g. because the handler
+// panicked, if the event was received by a subscription with a dead letter topic and
+// has not been acked or nacked. Returns false if the event was not dead lettered.
+func (e *Event) deadLetter(reason string) bool {
+	t, ok := e.sub.(*tracker)
+	if !ok || t.dlq == nil {
+		return false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state != subscription {
+		return false
+	}
+
+	var moved bool
+	moved, e.err = t.dlq.move(t.acks, t.wrapper, reason, 0)
+	if e.err != nil {
+		return false
+	}
+
+	t.stopDeadline()
+	switch {
+	case moved:
+		e.state = acked
+	default:
+		e.state = nacked
+	}
+	return moved
+}
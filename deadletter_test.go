@@ -0,0 +1,109 @@
+package ensign_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetter(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	dlqID := ulid.MustParse("01GWM8Q1V9Q7ZC7HS0A0SEF8K5")
+	recorder := mock.NewPublishRecorder(map[string]ulid.ULID{"dead.letters": dlqID})
+	emock.OnPublish = recorder.OnPublish
+
+	var (
+		replies sync.WaitGroup
+		acks    = make(chan *api.Ack, 8)
+		nacks   = make(chan *api.Nack, 8)
+	)
+
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		defer replies.Done()
+		acks <- in
+		return nil
+	}
+	handler.OnNack = func(in *api.Nack) error {
+		defer replies.Done()
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithDeadLetter("dead.letters", 3))
+	require.NoError(t, err, "could not create subscriber")
+	defer sub.Close()
+
+	factory := &mock.EventFactory{Topic: ulid.MustParse("01GWM89049D49FHJH81BT8795H")}
+
+	t.Run("MaxNacks", func(t *testing.T) {
+		wrapper := factory.Make()
+		var eventID string
+
+		// The first two nacks are sent to the server, the third dead letters the event.
+		for i := 1; i <= 3; i++ {
+			replies.Add(1)
+			handler.Send <- wrapper
+			event := <-sub.C
+			eventID = event.ID()
+			_, err := event.Nack(api.Nack_UNPROCESSED)
+			require.NoError(t, err, "could not nack event")
+			replies.Wait()
+
+			if i < 3 {
+				require.Len(t, nacks, 1)
+				require.Equal(t, api.Nack_UNPROCESSED, (<-nacks).Code)
+				require.Empty(t, recorder.Published())
+			}
+		}
+
+		require.Len(t, acks, 1)
+		require.Equal(t, wrapper.Id, (<-acks).Id)
+
+		mock.AssertPublished(t, recorder,
+			mock.OnTopic("dead.letters"),
+			mock.HasMetadata(sdk.DeadLetterReasonKey, "UNPROCESSED"),
+			mock.HasMetadata(sdk.DeadLetterTopicKey, "01GWM89049D49FHJH81BT8795H"),
+			mock.HasMetadata(sdk.DeadLetterEventKey, eventID),
+			mock.HasMetadata(sdk.DeadLetterNacksKey, "3"),
+		)
+		recorder.Reset()
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go sub.Run(ctx, func(event *sdk.Event) error {
+			panic("something bad happened")
+		})
+
+		replies.Add(1)
+		handler.Send <- factory.Make()
+		replies.Wait()
+
+		require.Len(t, acks, 1)
+		require.Empty(t, nacks)
+
+		mock.AssertPublished(t, recorder,
+			mock.OnTopic("dead.letters"),
+			mock.HasMetadata(sdk.DeadLetterReasonKey, "panic: something bad happened"),
+			mock.HasMetadata(sdk.DeadLetterNacksKey, "0"),
+		)
+	})
+}
@@ -0,0 +1,47 @@
+package ensign
+
+import (
+	"crypto/sha256"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// ContentHash specifies the hash algorithm used to compute the deduplication key of an
+// event from its payload (see WithContentKeys).
+type ContentHash uint8
+
+const (
+	// NoContentHash does not add content keys to events (default).
+	NoContentHash ContentHash = iota
+
+	// Murmur3 computes a fast 128 bit non-cryptographic hash of the event payload.
+	Murmur3
+
+	// SHA256 computes a 256 bit cryptographic hash of the event payload, which is slower
+	// than Murmur3 but makes accidental collisions between payloads vanishingly rare.
+	SHA256
+)
+
+// Sum returns the hash of the data or nil if no algorithm is specified.
+func (h ContentHash) Sum(data []byte) []byte {
+	switch h {
+	case Murmur3:
+		hash := murmur3.New128()
+		hash.Write(data)
+		return hash.Sum(nil)
+	case SHA256:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	default:
+		return nil
+	}
+}
+
+// Returns the key of the event wrapper: the partition key of the event if it has one,
+// otherwise the content hash of the event payload if the client adds content keys.
+func (o *Options) eventKey(event *Event) []byte {
+	if len(event.Key) > 0 {
+		return event.Key
+	}
+	return o.ContentKeys.Sum(event.Data)
+}
@@ -0,0 +1,139 @@
+package ensign
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// EventDescriptor describes an event as it was stored by Ensign: its identifiers, its
+// position in the topic, where and when it was committed, and how it was encoded. The
+// descriptor is a read-only copy that is safe to use in production code and that is
+// insulated from changes to the Ensign protocol buffers, unlike the wrapper returned by
+// Event.Info. The descriptor of an event that has not been published or received from
+// Ensign is the zero value.
+type EventDescriptor struct {
+	// The ID assigned to the event by Ensign (see Event.ID).
+	ID string
+
+	// The topic the event was published to; zero if not available.
+	TopicID ulid.ULID
+
+	// The offset and epoch of the event that define its total ordering in the topic.
+	Offset uint64
+	Epoch  uint64
+
+	// The name of the region the event was committed in, e.g. "LKE_US_EAST_1A".
+	Region string
+
+	// The publisher of the event as recorded by the Ensign server.
+	Publisher PublisherInfo
+
+	// The shard the event was assigned to by the sharding strategy of the topic.
+	Shard uint64
+
+	// The timestamp that the event was committed by the Ensign server.
+	Committed time.Time
+
+	// If the event is compressed, the compression algorithm, e.g. "GZIP".
+	Compressed  bool
+	Compression string
+
+	// If the event is encrypted, the encryption algorithm, e.g. "AES256_GCM".
+	Encrypted  bool
+	Encryption string
+
+	// Duplicate events refer to the ID of the event that contains the data.
+	Duplicate   bool
+	DuplicateOf string
+}
+
+// Descriptor returns a read-only description of the event as it was stored by Ensign.
+// The descriptor is the zero value if the event has not been published or received.
+func (e *Event) Descriptor() (desc EventDescriptor) {
+	if e.info == nil {
+		return desc
+	}
+
+	desc.ID = e.ID()
+	desc.TopicID, _ = e.TopicULID()
+	desc.Offset, desc.Epoch = e.info.Offset, e.info.Epoch
+	desc.Shard = e.info.Shard
+	desc.Committed = e.Committed()
+	desc.Region = e.Region()
+	desc.Publisher = e.Publisher()
+
+	desc.Compression = e.Compression()
+	desc.Compressed = desc.Compression != ""
+
+	desc.Encryption = e.Encryption()
+	desc.Encrypted = desc.Encryption != ""
+
+	if e.info.IsDuplicate {
+		desc.Duplicate = true
+		desc.DuplicateOf = encodeEventID(e.info.DuplicateId)
+	}
+	return desc
+}
+
+// PublisherInfo identifies the publisher of an event as recorded by the Ensign server
+// when the event was committed.
+type PublisherInfo struct {
+	// The ID of the API key that published the event.
+	PublisherID string
+
+	// The client ID of the publish stream (see WithClientName).
+	ClientID string
+
+	// The IP address and user agent of the publisher.
+	IPAddr    string
+	UserAgent string
+}
+
+// Publisher returns the publisher of the event as recorded by the Ensign server, or the
+// zero value if the event has not been received from Ensign.
+func (e *Event) Publisher() (pub PublisherInfo) {
+	if e.info == nil || e.info.Publisher == nil {
+		return pub
+	}
+
+	return PublisherInfo{
+		PublisherID: e.info.Publisher.PublisherId,
+		ClientID:    e.info.Publisher.ClientId,
+		IPAddr:      e.info.Publisher.Ipaddr,
+		UserAgent:   e.info.Publisher.UserAgent,
+	}
+}
+
+// Region returns the name of the region the event was committed in, e.g.
+// "LKE_US_EAST_1A", or an empty string if the region is not known.
+func (e *Event) Region() string {
+	if e.info == nil || e.info.Region == 0 {
+		return ""
+	}
+	return e.info.Region.String()
+}
+
+// Encryption returns the algorithm the event was encrypted with, e.g. "AES256_GCM", or
+// an empty string if the event is not encrypted.
+func (e *Event) Encryption() string {
+	if e.info == nil || e.info.Encryption == nil || e.info.Encryption.EncryptionAlgorithm == api.Encryption_PLAINTEXT {
+		return ""
+	}
+	return e.info.Encryption.EncryptionAlgorithm.String()
+}
+
+// Compression returns the algorithm the event was compressed with, e.g. "GZIP", or an
+// empty string if the event is not compressed.
+func (e *Event) Compression() string {
+	if e.info == nil || e.info.Compression == nil || e.info.Compression.Algorithm == api.Compression_NONE {
+		return ""
+	}
+	return e.info.Compression.Algorithm.String()
+}
+
+// IsZero returns true if the descriptor does not describe a published event.
+func (d EventDescriptor) IsZero() bool {
+	return d == EventDescriptor{}
+}
@@ -0,0 +1,174 @@
+package ensign
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// Discovery returns the host:port addresses of the Ensign nodes that the client can
+// connect to. When the client is configured with WithEnsignEndpoints or
+// WithEnsignDiscovery, the client connection is load balanced across the discovered
+// nodes: each RPC and each publish or subscribe stream is sent to the next node that is
+// ready (round robin), so streams that reconnect after a node fails are opened on
+// the remaining nodes. Discovery is run when the client connects and again when the
+// connection to a node fails, at most once every DiscoveryInterval.
+type Discovery func(ctx context.Context) (endpoints []string, err error)
+
+// DiscoveryInterval is the minimum time between two runs of the discovery of a client.
+var DiscoveryInterval = 30 * time.Second
+
+// The scheme of the resolver that connects the client to the discovered nodes and the
+// load balancing policy used when no service config is specified.
+const (
+	discoveryScheme  = "ensign-discovery"
+	discoveryTarget  = discoveryScheme + ":///ensign"
+	discoveryTimeout = 10 * time.Second
+	roundRobinConfig = `{"loadBalancingConfig": [{"round_robin":{}}]}`
+)
+
+// StaticEndpoints returns a discovery that always returns the specified endpoints.
+func StaticEndpoints(endpoints ...string) Discovery {
+	return func(context.Context) ([]string, error) {
+		return endpoints, nil
+	}
+}
+
+// DiscoverSRV returns a discovery that looks up the Ensign nodes in the DNS SRV record
+// with the specified name, e.g. "_ensign._tcp.example.com". Because the host names of
+// the nodes are used to verify their TLS certificates, only targets in the domain of the
+// record (e.g. "node1.example.com") are returned so that a spoofed record cannot direct
+// the client to an arbitrary host.
+func DiscoverSRV(name string) Discovery {
+	domain := strings.TrimSuffix(name, ".")
+	if parts := strings.SplitN(domain, ".", 3); len(parts) == 3 {
+		domain = parts[2]
+	}
+
+	return func(ctx context.Context) (endpoints []string, err error) {
+		var records []*net.SRV
+		if _, records, err = net.DefaultResolver.LookupSRV(ctx, "", "", name); err != nil {
+			return nil, err
+		}
+
+		// Records are returned sorted by priority and randomized by weight.
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			if target != domain && !strings.HasSuffix(target, "."+domain) {
+				continue
+			}
+			endpoints = append(endpoints, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+		return endpoints, nil
+	}
+}
+
+// discoveryBuilder is a gRPC resolver builder that resolves the discovery target to the
+// addresses returned by the discovery. The builder is added to the dial options of a
+// single client so the scheme does not need to be unique across clients.
+type discoveryBuilder struct {
+	discover Discovery
+}
+
+func (b *discoveryBuilder) Scheme() string {
+	return discoveryScheme
+}
+
+func (b *discoveryBuilder) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &discoveryResolver{
+		discover: b.discover,
+		cc:       cc,
+		ctx:      ctx,
+		cancel:   cancel,
+		trigger:  make(chan struct{}, 1),
+	}
+
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// discoveryResolver runs the discovery when the resolver is built and whenever gRPC
+// requests that the target is resolved again, e.g. because a connection failed.
+type discoveryResolver struct {
+	discover Discovery
+	cc       resolver.ClientConn
+	ctx      context.Context
+	cancel   context.CancelFunc
+	trigger  chan struct{}
+	wg       sync.WaitGroup
+}
+
+func (r *discoveryResolver) run() {
+	defer r.wg.Done()
+	for {
+		r.resolve()
+
+		// Wait for gRPC to request that the target is resolved again, then wait for the
+		// remainder of the discovery interval to limit the rate of discovery.
+		timer := time.NewTimer(DiscoveryInterval)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-r.trigger:
+		}
+
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *discoveryResolver) resolve() {
+	ctx, cancel := context.WithTimeout(r.ctx, discoveryTimeout)
+	defer cancel()
+
+	endpoints, err := r.discover(ctx)
+	if err != nil {
+		r.cc.ReportError(fmt.Errorf("could not discover ensign nodes: %w", err))
+		return
+	}
+
+	if len(endpoints) == 0 {
+		r.cc.ReportError(ErrNoEndpoints)
+		return
+	}
+
+	state := resolver.State{Addresses: make([]resolver.Address, 0, len(endpoints))}
+	for _, endpoint := range endpoints {
+		addr := resolver.Address{Addr: endpoint, ServerName: endpoint}
+
+		// The host is used to verify the certificate of the node since the authority
+		// of the discovery target does not name any of the nodes.
+		if host, _, err := net.SplitHostPort(endpoint); err == nil {
+			addr.ServerName = host
+		} else {
+			addr.Addr = net.JoinHostPort(endpoint, "443")
+		}
+		state.Addresses = append(state.Addresses, addr)
+	}
+	r.cc.UpdateState(state)
+}
+
+func (r *discoveryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (r *discoveryResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
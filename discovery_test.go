@@ -0,0 +1,113 @@
+package ensign_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// Serves the mock on a TCP listener so that the client can dial it by address.
+func serveNode(t *testing.T) (*mock.Ensign, *grpc.Server, string) {
+	emock := mock.New(nil)
+	t.Cleanup(emock.Shutdown)
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+	}
+
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "could not listen on tcp socket")
+
+	srv := grpc.NewServer()
+	api.RegisterEnsignServer(srv, emock)
+	go srv.Serve(sock)
+	t.Cleanup(srv.Stop)
+	return emock, srv, sock.Addr().String()
+}
+
+func statusCalls(emock *mock.Ensign) int {
+	emock.RLock()
+	defer emock.RUnlock()
+	return emock.Calls[mock.StatusRPC]
+}
+
+func TestEnsignEndpoints(t *testing.T) {
+	alpha, alphaSrv, alphaAddr := serveNode(t)
+	bravo, _, bravoAddr := serveNode(t)
+
+	client, err := sdk.New(sdk.WithEnsignEndpoints(true, alphaAddr, bravoAddr), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	// Requests are balanced across the nodes once both are connected
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.Eventually(t, func() bool {
+		_, err := client.Status(ctx)
+		return err == nil && statusCalls(alpha) > 0 && statusCalls(bravo) > 0
+	}, 5*time.Second, 10*time.Millisecond, "expected requests to be sent to both nodes")
+
+	// When a node fails, requests are sent to the remaining node
+	alphaSrv.Stop()
+	require.Eventually(t, func() bool {
+		_, err := client.Status(ctx)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	calls := statusCalls(bravo)
+	for i := 0; i < 5; i++ {
+		_, err = client.Status(ctx)
+		require.NoError(t, err, "expected requests to fail over to the remaining node")
+	}
+	require.Equal(t, calls+5, statusCalls(bravo))
+}
+
+func TestEnsignDiscovery(t *testing.T) {
+	_, err := sdk.New(sdk.WithEnsignEndpoints(true), sdk.WithAuthenticator("", true))
+	require.ErrorIs(t, err, sdk.ErrMissingEndpoint)
+
+	// Nodes are discovered when the client connects
+	alpha, _, alphaAddr := serveNode(t)
+	discovered := make(chan struct{}, 1)
+	discover := func(context.Context) ([]string, error) {
+		select {
+		case discovered <- struct{}{}:
+		default:
+		}
+		return []string{alphaAddr}, nil
+	}
+
+	client, err := sdk.New(sdk.WithEnsignDiscovery(discover, true), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, statusCalls(alpha))
+	require.Len(t, discovered, 1)
+
+	// Discovery errors are returned by requests
+	client, err = sdk.New(sdk.WithEnsignDiscovery(func(context.Context) ([]string, error) {
+		return nil, errors.New("no such host")
+	}, true), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = client.Status(ctx)
+	require.ErrorContains(t, err, "no such host")
+}
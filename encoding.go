@@ -0,0 +1,159 @@
+package ensign
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec serializes values into event data with the specified mimetype. Codecs for JSON
+// and protocol buffers are registered by default; other codecs, e.g. for msgpack, can
+// be registered with RegisterCodec so that the SDK does not depend on their libraries.
+type Codec interface {
+	Mimetype() mimetype.MIME
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	codecmu sync.RWMutex
+	codecs  = map[mimetype.MIME]Codec{
+		mimetype.ApplicationJSON:     jsonCodec{},
+		mimetype.ApplicationProtobuf: protoCodec{},
+	}
+)
+
+// RegisterCodec registers the codec for its mimetype, replacing any codec that was
+// previously registered for the mimetype. For example, to use MarshalMsgPackData, a
+// codec for mimetype.ApplicationMsgPack that wraps a msgpack library must be registered.
+func RegisterCodec(codec Codec) {
+	codecmu.Lock()
+	defer codecmu.Unlock()
+	codecs[codec.Mimetype()] = codec
+}
+
+// UnregisterCodec removes the codec registered for the mimetype, if any.
+func UnregisterCodec(mime mimetype.MIME) {
+	codecmu.Lock()
+	defer codecmu.Unlock()
+	delete(codecs, mime)
+}
+
+// LookupCodec returns the codec registered for the mimetype.
+func LookupCodec(mime mimetype.MIME) (Codec, error) {
+	codecmu.RLock()
+	defer codecmu.RUnlock()
+	if codec, ok := codecs[mime]; ok {
+		return codec, nil
+	}
+	return nil, fmt.Errorf("%w for %s", ErrNoCodec, mime.MimeType())
+}
+
+// MarshalData serializes the value using the codec registered for the mimetype, then
+// sets the data and the mimetype of the event so that they always agree.
+func (e *Event) MarshalData(mime mimetype.MIME, v interface{}) (err error) {
+	var codec Codec
+	if codec, err = LookupCodec(mime); err != nil {
+		return err
+	}
+
+	var data []byte
+	if data, err = codec.Marshal(v); err != nil {
+		return err
+	}
+
+	e.Data = data
+	e.Mimetype = codec.Mimetype()
+	return nil
+}
+
+// UnmarshalData deserializes the data of the event into the value using the codec
+// registered for the mimetype of the event. Returns ErrMimetypeMismatch if the event
+// was not published with the expected mimetype.
+func (e *Event) UnmarshalData(mime mimetype.MIME, v interface{}) (err error) {
+	if e.Mimetype != mime {
+		return fmt.Errorf("%w: event has mimetype %s not %s", ErrMimetypeMismatch, e.Mimetype.MimeType(), mime.MimeType())
+	}
+
+	var codec Codec
+	if codec, err = LookupCodec(mime); err != nil {
+		return err
+	}
+	return codec.Unmarshal(e.Data, v)
+}
+
+// Decode deserializes the data of the event into the value using the codec registered
+// for the mimetype of the event, e.g. when consuming events with different mimetypes.
+func (e *Event) Decode(v interface{}) (err error) {
+	var codec Codec
+	if codec, err = LookupCodec(e.Mimetype); err != nil {
+		return err
+	}
+	return codec.Unmarshal(e.Data, v)
+}
+
+// MarshalJSONData serializes the value as JSON into the data of the event and sets the
+// mimetype of the event to application/json.
+func (e *Event) MarshalJSONData(v interface{}) error {
+	return e.MarshalData(mimetype.ApplicationJSON, v)
+}
+
+// UnmarshalJSONData deserializes the JSON data of the event into the value.
+func (e *Event) UnmarshalJSONData(v interface{}) error {
+	return e.UnmarshalData(mimetype.ApplicationJSON, v)
+}
+
+// MarshalMsgPackData serializes the value as msgpack into the data of the event and
+// sets the mimetype of the event to application/msgpack. A msgpack codec must be
+// registered with RegisterCodec, otherwise ErrNoCodec is returned.
+func (e *Event) MarshalMsgPackData(v interface{}) error {
+	return e.MarshalData(mimetype.ApplicationMsgPack, v)
+}
+
+// UnmarshalMsgPackData deserializes the msgpack data of the event into the value using
+// the msgpack codec registered with RegisterCodec.
+func (e *Event) UnmarshalMsgPackData(v interface{}) error {
+	return e.UnmarshalData(mimetype.ApplicationMsgPack, v)
+}
+
+// MarshalProtoData serializes the protocol buffer message into the data of the event
+// and sets the mimetype of the event to application/protobuf.
+func (e *Event) MarshalProtoData(m proto.Message) error {
+	return e.MarshalData(mimetype.ApplicationProtobuf, m)
+}
+
+// UnmarshalProtoData deserializes the protocol buffer data of the event into the message.
+func (e *Event) UnmarshalProtoData(m proto.Message) error {
+	return e.UnmarshalData(mimetype.ApplicationProtobuf, m)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Mimetype() mimetype.MIME                    { return mimetype.ApplicationJSON }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type protoCodec struct{}
+
+func (protoCodec) Mimetype() mimetype.MIME {
+	return mimetype.ApplicationProtobuf
+}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not a proto.Message", ErrInvalidCodecValue, v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T is not a proto.Message", ErrInvalidCodecValue, v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,83 @@
+package ensign_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type order struct {
+	ID    int      `json:"id"`
+	Items []string `json:"items"`
+}
+
+func TestJSONData(t *testing.T) {
+	event := &sdk.Event{Mimetype: mimetype.TextPlain}
+	require.NoError(t, event.MarshalJSONData(order{ID: 1, Items: []string{"apple"}}))
+	require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+	require.JSONEq(t, `{"id": 1, "items": ["apple"]}`, string(event.Data))
+
+	var out order
+	require.NoError(t, event.UnmarshalJSONData(&out))
+	require.Equal(t, order{ID: 1, Items: []string{"apple"}}, out)
+
+	// Cannot unmarshal data with a different mimetype
+	event.Mimetype = mimetype.ApplicationOctetStream
+	err := event.UnmarshalJSONData(&out)
+	require.ErrorIs(t, err, sdk.ErrMimetypeMismatch)
+	require.EqualError(t, err, "event data does not have the expected mimetype: event has mimetype application/octet-stream not application/json")
+}
+
+func TestProtoData(t *testing.T) {
+	msg := &api.Type{Name: "OrderPlaced", MajorVersion: 1}
+
+	event := &sdk.Event{}
+	require.NoError(t, event.MarshalProtoData(msg))
+	require.Equal(t, mimetype.ApplicationProtobuf, event.Mimetype)
+
+	out := &api.Type{}
+	require.NoError(t, event.UnmarshalProtoData(out))
+	require.True(t, proto.Equal(msg, out))
+
+	require.ErrorIs(t, event.MarshalData(mimetype.ApplicationProtobuf, order{}), sdk.ErrInvalidCodecValue)
+}
+
+func TestMsgPackData(t *testing.T) {
+	event := &sdk.Event{}
+	err := event.MarshalMsgPackData(order{ID: 1})
+	require.ErrorIs(t, err, sdk.ErrNoCodec)
+	require.EqualError(t, err, "no codec registered for application/msgpack")
+	require.Empty(t, event.Data)
+
+	// Once registered, the codec is used to marshal msgpack data
+	sdk.RegisterCodec(gobCodec{})
+	defer sdk.UnregisterCodec(mimetype.ApplicationMsgPack)
+
+	require.NoError(t, event.MarshalMsgPackData(order{ID: 2, Items: []string{"pear"}}))
+	require.Equal(t, mimetype.ApplicationMsgPack, event.Mimetype)
+
+	var out order
+	require.NoError(t, event.UnmarshalMsgPackData(&out))
+	require.Equal(t, order{ID: 2, Items: []string{"pear"}}, out)
+}
+
+// Stands in for a msgpack library in tests.
+type gobCodec struct{}
+
+func (gobCodec) Mimetype() mimetype.MIME { return mimetype.ApplicationMsgPack }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -2,14 +2,17 @@ package ensign
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
+	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	"github.com/rotationalio/go-ensign/auth"
+	"github.com/rotationalio/go-ensign/backoff"
 	"github.com/rotationalio/go-ensign/stream"
+	"github.com/rotationalio/go-ensign/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
@@ -17,8 +20,9 @@ import (
 )
 
 const (
-	// Specifies the wait period before checking if a gRPC connection has been
-	// established while waiting for a ready connection.
+	// Specifies the initial wait period before checking if a gRPC connection has been
+	// established while waiting for a ready connection. Subsequent checks are made
+	// according to the backoff policy of the client (see WithBackoff).
 	ReconnectTick = 750 * time.Millisecond
 
 	// The default page size for paginated gRPC responses.
@@ -35,15 +39,33 @@ const (
 // applications that leverage data flows.
 type Client struct {
 	sync.RWMutex
-	opts    Options
-	cc      *grpc.ClientConn
-	api     api.EnsignClient
-	auth    *auth.Client
-	copts   []grpc.CallOption
-	pub     *stream.Publisher
-	openPub sync.Once
+	opts  Options
+	cc    *grpc.ClientConn
+	api   api.EnsignClient
+	auth  *auth.Client
+	copts []grpc.CallOption
+	pub   *stream.Publisher
+	pmu   sync.Mutex // serializes opening the publish stream
+	clone bool
+
+	streams *streams
+
+	tracer     trace.Tracer
+	propagator trace.Propagator
 }
 
+// Streams opened by the client can reconnect using their own backoff policy.
+var _ stream.BackoffObserver = &Client{}
+
+// Publisher publishes events to Ensign; it is implemented by *Client. Packages that
+// publish events on behalf of the user (e.g. natsbridge, source, and outbox) accept a
+// Publisher so that they can be used with wrapped clients or tested with fakes.
+type Publisher interface {
+	PublishContext(ctx context.Context, topic string, events ...*Event) error
+}
+
+var _ Publisher = &Client{}
+
 // Create a new Ensign client, specifying connection and authentication options if
 // necessary. Ensign expects that credentials are stored in the environment, set using
 // the $ENSIGN_CLIENT_ID and $ENSIGN_CLIENT_SECRET environment variables. They can also
@@ -51,17 +73,55 @@ type Client struct {
 // also specify a mock ensign server to test your code that uses Ensign via WithMock.
 // This function returns an error if the client is unable to dial ensign; however,
 // authentication errors and connectivity checks may require an Ensign RPC call. You can
-// use the Ping() method to check if your connection credentials to Ensign is correct.
+// use the Ping method to check if your connection credentials to Ensign are correct.
 func New(opts ...Option) (client *Client, err error) {
 	client = &Client{}
 	if client.opts, err = NewOptions(opts...); err != nil {
 		return nil, err
 	}
+	client.streams = &streams{max: client.opts.MaxStreams}
+
+	// Create the tracer and propagator; by default the client is not traced.
+	client.tracer, client.propagator = client.opts.tracing()
+
+	// Use the client to lookup topics that are not in a shared topic cache.
+	if client.opts.TopicCache != nil {
+		client.opts.TopicCache.UseClient(client)
+	}
 
 	// Connect to the authentication service -- this must happen before the connection
 	// to the ensign server so that the client-side interceptors can be created.
 	if !client.opts.NoAuthentication {
-		if client.auth, err = auth.New(client.opts.AuthURL, client.opts.Insecure); err != nil {
+		aopts := []auth.Option{auth.WithBackoff(client.opts.backoffPolicy())}
+		if client.opts.TracerProvider != nil {
+			aopts = append(aopts, auth.WithTracerProvider(client.opts.TracerProvider))
+		}
+
+		if client.opts.TokenCache != nil {
+			aopts = append(aopts, auth.WithTokenCache(client.opts.TokenCache))
+		}
+
+		if client.opts.TLSConfig != nil {
+			aopts = append(aopts, auth.WithTLSConfig(client.opts.TLSConfig))
+		}
+
+		if client.opts.Proxy != nil {
+			aopts = append(aopts, auth.WithProxy(client.opts.Proxy))
+		}
+
+		if client.opts.TokenRefreshWindow > 0 {
+			aopts = append(aopts, auth.WithRefreshAhead(client.opts.TokenRefreshWindow))
+		}
+
+		if client.opts.OnTokenRefresh != nil {
+			aopts = append(aopts, auth.WithRefreshHook(client.opts.OnTokenRefresh))
+		}
+
+		if client.opts.TokenSource != nil {
+			aopts = append(aopts, auth.WithTokenSource(client.opts.TokenSource))
+		}
+
+		if client.auth, err = auth.New(client.opts.AuthURL, client.opts.Insecure, aopts...); err != nil {
 			return nil, err
 		}
 	}
@@ -91,7 +151,7 @@ func (c *Client) connect() (err error) {
 		if c.opts.Insecure {
 			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		} else {
-			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(c.opts.tlsConfig())))
 		}
 
 		if !c.opts.NoAuthentication {
@@ -111,11 +171,48 @@ func (c *Client) connect() (err error) {
 		opts = append(opts, grpc.WithUserAgent(fmt.Sprintf(UserAgent, VersionMajor)))
 	}
 
-	if c.cc, err = grpc.Dial(c.opts.Endpoint, opts...); err != nil {
+	// Add custom resolvers and service config without clobbering the dial options.
+	if len(c.opts.Resolvers) > 0 {
+		opts = append(opts, grpc.WithResolvers(c.opts.Resolvers...))
+	}
+
+	if c.opts.ServiceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(c.opts.ServiceConfig))
+	}
+
+	// Connect to the discovered nodes rather than the endpoint, round robin by default.
+	target := c.opts.Endpoint
+	if c.opts.Discovery != nil {
+		target = discoveryTarget
+		opts = append(opts, grpc.WithResolvers(&discoveryBuilder{discover: c.opts.Discovery}))
+		if c.opts.ServiceConfig == "" {
+			opts = append(opts, grpc.WithDefaultServiceConfig(roundRobinConfig))
+		}
+	}
+
+	// Dial through the proxy if one is configured rather than the proxy specified by the
+	// environment without clobbering the dial options.
+	if c.opts.Proxy != nil {
+		var dialer func(context.Context, string) (net.Conn, error)
+		if dialer, err = proxyDialer(c.opts.Proxy); err != nil {
+			return err
+		}
+		opts = append(opts, grpc.WithContextDialer(dialer))
+	}
+
+	// Tune the connection and chain the tracing, retry, and user interceptors without
+	// clobbering the dial options.
+	opts = append(opts, c.opts.tuning()...)
+	opts = append(opts, c.interceptors()...)
+
+	if c.cc, err = grpc.Dial(target, opts...); err != nil {
 		return err
 	}
 
 	c.api = api.NewEnsignClient(c.cc)
+	if c.opts.OnConnection != nil {
+		go watchConnection(c.cc, c.opts.OnConnection)
+	}
 	return nil
 }
 
@@ -124,26 +221,79 @@ func (c *Client) connectMock() (err error) {
 		return ErrMissingMock
 	}
 
-	if c.api, err = c.opts.Mock.Client(context.Background(), c.opts.Dialing...); err != nil {
+	// Tune the connection and chain the tracing, retry, and user interceptors to the
+	// mock, ensuring the default mock credentials are used.
+	opts := c.opts.Dialing
+	if extra := append(c.opts.tuning(), c.interceptors()...); len(extra) > 0 {
+		if len(opts) == 0 {
+			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		}
+		opts = append(opts, extra...)
+	}
+
+	if c.api, err = c.opts.Mock.Client(context.Background(), opts...); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Returns the dial options that chain the tracing, user, and retry interceptors
+// configured by the options; the chained interceptors are called after the auth
+// interceptors. The tracing interceptor is chained first so that each span covers all
+// of the attempts of an RPC, and user interceptors are called once per RPC.
+func (c *Client) interceptors() (opts []grpc.DialOption) {
+	if c.opts.TracerProvider != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(trace.UnaryClientInterceptor(c.tracer)))
+	}
+
+	if len(c.opts.UnaryInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(c.opts.UnaryInterceptors...))
+	}
+
+	if len(c.opts.StreamInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainStreamInterceptor(c.opts.StreamInterceptors...))
+	}
+
+	if c.opts.Retries != nil {
+		retries := *c.opts.Retries
+		if retries.Backoff == nil {
+			retries.Backoff = c.opts.backoffPolicy()
+		}
+		opts = append(opts, grpc.WithChainUnaryInterceptor(retries.interceptor()))
+	}
+	return opts
+}
+
 // Close the connection to the current Ensign server. Closing the connection may block
 // if streaming RPCs such as publish or subscribe are running. It is useful to Close the
 // Ensign connection when you're done to free up any resources in long running programs,
 // however, once closed, the Client cannot be reconnected and a new Client must be
-// initialized to re-establish the connection.
+// initialized to re-establish the connection. Closing a client returned by
+// WithCallOptions only closes the publish stream opened by the clone; the connection
+// is shared with and can only be closed by the original client.
 func (c *Client) Close() (err error) {
 	c.Lock()
 	defer func() {
 		c.cc = nil
 		c.api = nil
+		c.pub = nil
 		c.Unlock()
 	}()
 
-	if c.cc != nil {
+	if c.pub != nil {
+		if err = c.pub.Close(); err != nil {
+			return err
+		}
+		c.streams.release()
+	}
+
+	if c.auth != nil && !c.clone {
+		if err = c.auth.Close(); err != nil {
+			return err
+		}
+	}
+
+	if c.cc != nil && !c.clone {
 		if err = c.cc.Close(); err != nil {
 			return err
 		}
@@ -151,6 +301,42 @@ func (c *Client) Close() (err error) {
 	return nil
 }
 
+// Shutdown gracefully stops the client, unlike Close which immediately closes the
+// connection. First Shutdown waits for the server to ack or nack every event that has
+// been published, then it closes the subscriptions opened by the client and its clones
+// and waits for them to terminate so that Run handlers can finish, and finally it
+// closes the publish stream and the connection. If the context is done before the
+// publish stream is flushed or the subscriptions terminate, the client is closed
+// anyway and the context error is returned; the replies to any events that were still
+// pending are lost. Shutting down a client returned by WithCallOptions only flushes and
+// closes the publish stream of the clone, similar to Close.
+func (c *Client) Shutdown(ctx context.Context) (err error) {
+	if err = c.Flush(ctx); err != nil {
+		err = fmt.Errorf("could not flush publish stream: %w", err)
+	}
+
+	if !c.clone {
+		for _, sub := range c.streams.subscriptions() {
+			if serr := sub.Close(); serr != nil && err == nil {
+				err = serr
+			}
+
+			select {
+			case <-sub.Done():
+			case <-ctx.Done():
+				if err == nil {
+					err = ctx.Err()
+				}
+			}
+		}
+	}
+
+	if cerr := c.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
 // Status performs an unauthenticated check to the Ensign service to determine the state
 // of the service. This may be useful in debugging connectivity issues.
 //
@@ -159,27 +345,114 @@ func (c *Client) Status(ctx context.Context) (state *api.ServiceState, err error
 	return c.api.Status(ctx, &api.HealthCheck{}, c.copts...)
 }
 
+// Ping checks that the client can connect to Ensign and that its credentials are valid
+// so that applications can health-check their connection at startup. The round-trip
+// latency of the Status RPC and the version of the Ensign node are returned. Errors are
+// translated in the same manner as other RPCs, e.g. if the credentials are rejected by
+// the server then the error wraps ErrUnauthorized.
+func (c *Client) Ping(ctx context.Context) (latency time.Duration, version string, err error) {
+	var state *api.ServiceState
+	start := time.Now()
+	if state, err = c.Status(ctx); err != nil {
+		return 0, "", translateError(err)
+	}
+	latency = time.Since(start)
+
+	// Status is unauthenticated, so make the cheapest authenticated RPC to ensure that
+	// the credentials of the client are accepted by Ensign.
+	if _, err = c.api.TopicNames(ctx, &api.PageInfo{PageSize: 1}, c.copts...); err != nil {
+		return latency, state.Version, translateError(err)
+	}
+	return latency, state.Version, nil
+}
+
 // WithCallOptions configures the next client Call to use the specified call options,
 // after the call, the call options are removed. This method returns the Client pointer
 // so that you can easily chain a call e.g. client.WithCallOptions(opts...).ListTopics()
 // -- this ensures that we don't have to pass call options in to each individual call.
 // Ensure that the clone of the client is discarded and garbage collected after use;
-// the clone cannot be used to close the connection or fetch the options.
+// the clone cannot be used to close the connection.
+//
+// Streams opened by the clone carry the call options for the lifetime of the stream,
+// including reconnects. Calling Subscribe on the clone opens a new subscribe stream
+// with the call options. Calling Publish on the clone opens a publish stream that is
+// owned by the clone and is separate from the publish stream of the original client;
+// call Close on the clone when finished publishing to close the clone's stream.
 //
 // Experimental: call options and thread-safe cloning is an experimental feature and its
 // signature may be subject to change in the future.
 func (c *Client) WithCallOptions(opts ...grpc.CallOption) *Client {
-	// Return a clone of the client with the api interface and the opts but do not
-	// include the grpc connection to ensure only the original client can close it.
+	// Return a clone of the client with the api interface and the opts; the grpc
+	// connection is included to monitor connectivity but only the original client can
+	// close it. The clone does not share the publisher of the original client.
 	client := &Client{
 		opts:  c.opts,
+		cc:    c.cc,
 		api:   c.api,
 		auth:  c.auth,
 		copts: opts,
+		clone: true,
+
+		streams: c.streams,
+
+		tracer:     c.tracer,
+		propagator: c.propagator,
 	}
 	return client
 }
 
+// Claims returns the claims of the access token the client uses to authenticate with
+// Ensign, e.g. the project ID and the permissions of the API key. The claims are cached
+// until the access token is renewed. ErrNotAuthenticated is returned if the client was
+// created without authentication, e.g. when connected to a mock.
+func (c *Client) Claims() (_ *auth.Claims, err error) {
+	if c.auth == nil {
+		return nil, ErrNotAuthenticated
+	}
+	return c.auth.Claims()
+}
+
+// HasPermission returns true if the API key of the client has the specified permission
+// (e.g. auth.PermissionPublisher) according to the claims of its access token. False
+// is returned if the claims are not available, e.g. if the client is not authenticated.
+func (c *Client) HasPermission(permission string) bool {
+	claims, err := c.Claims()
+	if err != nil {
+		return false
+	}
+	return claims.HasPermission(permission)
+}
+
+// Checks that the API key of the client has the permission before opening a stream so
+// that users get an actionable error rather than an opaque stream failure. The check is
+// skipped if the claims are not available or do not list any permissions (e.g. tokens
+// supplied by WithTokenSource), in which case the server enforces the permissions.
+func (c *Client) preflight(permission string) error {
+	claims, err := c.Claims()
+	if err != nil || len(claims.Permissions) == 0 {
+		return nil
+	}
+
+	if !claims.HasPermission(permission) {
+		return fmt.Errorf("%w: api key does not have the %q permission", ErrPermissionMissing, permission)
+	}
+	return nil
+}
+
+// ProjectID returns the ID of the project that the API key of the client belongs to,
+// which is the project that topics are created in and events are published to.
+func (c *Client) ProjectID() (projectID ulid.ULID, err error) {
+	var claims *auth.Claims
+	if claims, err = c.Claims(); err != nil {
+		return ulid.ULID{}, err
+	}
+
+	if projectID, err = ulid.Parse(claims.ProjectID); err != nil {
+		return ulid.ULID{}, fmt.Errorf("%w: %q", ErrInvalidProjectID, claims.ProjectID)
+	}
+	return projectID, nil
+}
+
 // Returns the underlying gRPC client for Ensign; useful for testing or advanced calls.
 // It is not recommended to use this client for production code.
 func (c *Client) EnsignClient() api.EnsignClient {
@@ -208,28 +481,66 @@ func (c *Client) WaitForConnStateChange(ctx context.Context, sourceState connect
 	return c.cc.WaitForStateChange(ctx, sourceState)
 }
 
-// WaitForReconnect checks if the connection has been reconnected periodically and
-// returns true when the connection is ready. If the context deadline times out before
-// a connection can be re-established, false is returned.
+// Reports the transitions of the gRPC connection to the connection hook until the
+// connection is closed. The connection is connected the first time it is ready and is
+// reconnecting whenever it leaves the ready state. gRPC retries failed connections
+// indefinitely so the client connection never reports a fatal event.
+func watchConnection(cc *grpc.ClientConn, hook stream.ConnectionHook) {
+	var connected, down bool
+	state := cc.GetState()
+	for {
+		switch state {
+		case connectivity.Ready:
+			switch {
+			case !connected:
+				connected = true
+				hook.Notify(stream.Connected, stream.SourceClient, nil)
+			case down:
+				down = false
+				hook.Notify(stream.Reconnected, stream.SourceClient, nil)
+			}
+		case connectivity.Shutdown:
+			return
+		default:
+			if connected && !down {
+				down = true
+				hook.Notify(stream.Reconnecting, stream.SourceClient, nil)
+			}
+		}
+
+		cc.WaitForStateChange(context.Background(), state)
+		state = cc.GetState()
+	}
+}
+
+// WaitForReconnect checks if the connection has been reconnected periodically using
+// the backoff policy of the client and returns true when the connection is ready. If
+// the context deadline times out or the backoff policy stops retrying before a
+// connection can be re-established, false is returned.
 //
 // Experimental: this method relies on an experimental gRPC API that could be changed.
 func (c *Client) WaitForReconnect(ctx context.Context) bool {
-	ticker := time.NewTicker(ReconnectTick)
-	defer ticker.Stop()
+	return c.WaitForReconnectBackoff(ctx, c.opts.backoffPolicy()())
+}
 
+// WaitForReconnectBackoff is like WaitForReconnect but checks the connection using the
+// specified backoff rather than the backoff policy of the client. It is used by streams
+// that are configured with their own backoff policy (see WithReconnectBackoff).
+//
+// Experimental: this method relies on an experimental gRPC API that could be changed.
+func (c *Client) WaitForReconnectBackoff(ctx context.Context, ticker backoff.Backoff) bool {
 	for {
-		select {
-		case <-ticker.C:
-			// Connect causes all subchannels in the ClientConn to attempt to connect if
-			// the channel is idle. Does not wait for the connection attempts to begin.
-			c.cc.Connect()
-
-			// Check if the connection is ready
-			if c.cc.GetState() == connectivity.Ready {
-				return true
-			}
-		case <-ctx.Done():
+		if err := backoff.Wait(ctx, ticker); err != nil {
 			return false
 		}
+
+		// Connect causes all subchannels in the ClientConn to attempt to connect if
+		// the channel is idle. Does not wait for the connection attempts to begin.
+		c.cc.Connect()
+
+		// Check if the connection is ready
+		if c.cc.GetState() == connectivity.Ready {
+			return true
+		}
 	}
 }
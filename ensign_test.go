@@ -2,18 +2,30 @@ package ensign_test
 
 import (
 	"context"
+	"net"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/oklog/ulid/v2"
 	sdk "github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	"github.com/rotationalio/go-ensign/auth"
 	"github.com/rotationalio/go-ensign/auth/authtest"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
 	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/stream"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 	"google.golang.org/grpc/status"
 )
 
@@ -142,3 +154,668 @@ func (s *sdkTestSuite) TestWithCallOptions() {
 	// This must happen last for the test to pass
 	require.NotPanics(func() { clone.Close() }, "expected clone to not panic on close")
 }
+
+func (s *sdkTestSuite) TestWithCallOptionsStreams() {
+	require := s.Require()
+	err := s.Authenticate(context.Background())
+	require.NoError(err, "must be able to authenticate")
+
+	err = s.mock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json")
+	require.NoError(err, "could not load topic names fixture")
+
+	handler := mock.NewSubscribeHandler()
+	s.mock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	// Streams opened by the clone should use the clone's call options
+	clone := s.client.WithCallOptions(grpc.CallContentSubtype("json"))
+
+	_, err = clone.Subscribe("01GWM89049D49FHJH81BT8795H")
+	s.GRPCErrorIs(err, codes.Internal, "no codec registered for content-subtype json")
+
+	err = clone.Publish("01H1S1F67V282KQJSWAMARG8QF", NewEvent())
+	s.GRPCErrorIs(err, codes.Internal, "no codec registered for content-subtype json")
+
+	// A failure to open the publish stream should be retried on the next publish
+	err = clone.Publish("01H1S1F67V282KQJSWAMARG8QF", NewEvent())
+	s.GRPCErrorIs(err, codes.Internal, "no codec registered for content-subtype json")
+
+	// The original client should not be affected by the clone's call options
+	sub, err := s.client.Subscribe("testing.topics.topica")
+	require.NoError(err, "could not subscribe with the original client")
+	require.NoError(sub.Close())
+	require.Zero(s.client.PublishStats().Events, "expected the clone not to share the publisher")
+
+	// Closing the clone should not close the connection of the original client
+	require.NoError(clone.Close())
+	sub, err = s.client.Subscribe("testing.topics.topica")
+	require.NoError(err, "expected the original client connection to remain open")
+	require.NoError(sub.Close())
+}
+
+func TestReadOnlyClient(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithReadOnly())
+	require.NoError(t, err, "could not create read-only client")
+
+	ctx := context.Background()
+	topicID := "01HCG64Y1SMFQBW7A42SRV207A"
+
+	_, err = client.CreateTopic(ctx, "testing")
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	_, err = client.ArchiveTopic(ctx, topicID)
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	_, err = client.DestroyTopic(ctx, topicID)
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	_, err = client.SetTopicDeduplicationPolicy(ctx, topicID, api.Deduplication_STRICT, api.Deduplication_OFFSET_EARLIEST, nil, false)
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	_, err = client.SetTopicShardingStrategy(ctx, topicID, api.ShardingStrategy_NO_SHARDING)
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	err = client.Publish(topicID, NewEvent())
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	_, err = client.PublishStream(ctx)
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	// No mutating RPCs should have been made to the server
+	require.Empty(t, emock.Calls, "expected no calls to the mock server")
+
+	// Read RPCs should still be allowed
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+	}
+
+	_, err = client.Status(ctx)
+	require.NoError(t, err, "expected status to be allowed in read-only mode")
+
+	// Subscriptions are opened in inspect mode and events cannot be acked or nacked
+	handler := mock.NewSubscribeHandler()
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	sub, err := client.Subscribe(topicID)
+	require.NoError(t, err, "expected subscriptions to be allowed in read-only mode")
+	defer sub.Close()
+
+	handler.Send <- mock.NewEventWrapper()
+	event := <-sub.C
+
+	acked, err := event.Ack()
+	require.False(t, acked, "expected event not to be acked in inspect mode")
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+
+	nacked, err := event.Nack(api.Nack_UNPROCESSED)
+	require.False(t, nacked, "expected event not to be nacked in inspect mode")
+	require.ErrorIs(t, err, sdk.ErrReadOnlyClient)
+}
+
+func TestWithResolver(t *testing.T) {
+	// Serve the mock on a TCP socket so that the resolver can return its address.
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "could not listen on a local port")
+
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	srv := grpc.NewServer()
+	api.RegisterEnsignServer(srv, emock)
+	go srv.Serve(sock)
+	defer srv.Stop()
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+	}
+
+	// The endpoint can only be resolved by the custom resolver
+	discovery := manual.NewBuilderWithScheme("discovery")
+	discovery.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: sock.Addr().String()}}})
+
+	client, err := sdk.New(
+		sdk.WithEnsignEndpoint("discovery:///ensign.test", true),
+		sdk.WithAuthenticator("", true),
+		sdk.WithResolver(discovery),
+		sdk.WithServiceConfigJSON(`{"loadBalancingConfig": [{"round_robin": {}}]}`),
+	)
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	state, err := client.Status(context.Background())
+	require.NoError(t, err, "could not connect using the custom resolver")
+	require.Equal(t, api.ServiceState_HEALTHY, state.Status)
+
+	// An invalid service config should return an error
+	_, err = sdk.New(sdk.WithServiceConfigJSON("{not json"))
+	require.ErrorIs(t, err, sdk.ErrInvalidServiceConfig)
+}
+
+func TestConnectionHook(t *testing.T) {
+	emock, srv, addr := serveNode(t)
+
+	var (
+		mu     sync.Mutex
+		events []stream.ConnectionEvent
+	)
+	states := func() []stream.ConnectionState {
+		mu.Lock()
+		defer mu.Unlock()
+		states := make([]stream.ConnectionState, 0, len(events))
+		for _, event := range events {
+			states = append(states, event.State)
+		}
+		return states
+	}
+
+	client, err := sdk.New(sdk.WithEnsignEndpoint(addr, true), sdk.WithAuthenticator("", true), sdk.WithConnectionHook(func(event stream.ConnectionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	// The client is connected once the first RPC is made
+	_, err = client.Status(context.Background())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(states()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []stream.ConnectionState{stream.Connected}, states())
+
+	// The client reconnects when the node is restarted
+	srv.Stop()
+	require.Eventually(t, func() bool {
+		return len(states()) == 2
+	}, 5*time.Second, 10*time.Millisecond, "expected the client to be reconnecting")
+
+	sock, err := net.Listen("tcp", addr)
+	require.NoError(t, err, "could not listen on the address of the node")
+	srv = grpc.NewServer()
+	api.RegisterEnsignServer(srv, emock)
+	go srv.Serve(sock)
+	defer srv.Stop()
+
+	require.Eventually(t, func() bool {
+		_, err := client.Status(context.Background())
+		return err == nil && len(states()) == 3
+	}, 10*time.Second, 50*time.Millisecond, "expected the client to be reconnected")
+	require.Equal(t, []stream.ConnectionState{stream.Connected, stream.Reconnecting, stream.Reconnected}, states())
+
+	mu.Lock()
+	for _, event := range events {
+		require.Equal(t, stream.SourceClient, event.Source)
+	}
+	mu.Unlock()
+}
+
+func TestMaxStreams(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	_, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithMaxStreams(-1))
+	require.ErrorIs(t, err, sdk.ErrInvalidMaxStreams)
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithMaxStreams(2))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	handler := mock.NewSubscribeHandler()
+	emock.OnSubscribe = handler.OnSubscribe
+	emock.OnPublish = mock.NewPublishHandler(nil).OnPublish
+	defer handler.Shutdown()
+
+	require.Zero(t, client.ActiveStreams())
+
+	sub, err := client.Subscribe("testing.topics.topica")
+	require.NoError(t, err, "could not subscribe")
+	defer sub.Close()
+	require.Equal(t, 1, client.ActiveStreams())
+
+	// Clones share the streams of the client's connection
+	clone := client.WithCallOptions()
+	other, err := clone.Subscribe("testing.topics.topicb")
+	require.NoError(t, err, "could not subscribe")
+	require.Equal(t, 2, client.ActiveStreams())
+	require.Equal(t, 2, clone.ActiveStreams())
+
+	_, err = client.Subscribe("testing.topics.topicc")
+	require.ErrorIs(t, err, sdk.ErrTooManyStreams)
+	require.EqualError(t, err, "maximum number of open streams reached: 2 of 2 streams are open")
+
+	err = client.Publish("01GWM89049D49FHJH81BT8795H", NewEvent())
+	require.ErrorIs(t, err, sdk.ErrTooManyStreams)
+
+	// Closing a subscription releases its stream
+	require.NoError(t, other.Close())
+	<-other.Done()
+	require.Equal(t, 1, client.ActiveStreams())
+
+	event := NewEvent()
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", event))
+	_, err = event.Wait()
+	require.NoError(t, err, "event was not acked")
+	require.Equal(t, 2, client.ActiveStreams())
+
+	// The publish stream is only counted once
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", NewEvent()))
+	require.Equal(t, 2, client.ActiveStreams())
+}
+
+func TestShutdown(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	// Delay the acks from the server so that events are pending when shutting down.
+	var acked int32
+	publisher := mock.NewPublishHandler(nil)
+	ack := publisher.OnEvent
+	publisher.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&acked, 1)
+		return ack(in)
+	}
+	emock.OnPublish = publisher.OnPublish
+
+	handler := mock.NewSubscribeHandler()
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	sub, err := client.Subscribe("testing.topics.topica")
+	require.NoError(t, err, "could not subscribe")
+
+	running := make(chan error, 1)
+	go func() {
+		running <- sub.Run(context.Background(), func(*sdk.Event) error { return nil })
+	}()
+
+	events := make([]*sdk.Event, 0, 5)
+	for i := 0; i < 5; i++ {
+		event := NewEvent()
+		require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", event))
+		events = append(events, event)
+	}
+	require.Equal(t, 2, client.ActiveStreams())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.Shutdown(ctx), "could not shutdown client")
+
+	// All events were acked by the server before the publish stream was closed.
+	require.Equal(t, int32(5), atomic.LoadInt32(&acked))
+	for _, event := range events {
+		ok, err := event.Wait()
+		require.NoError(t, err)
+		require.True(t, ok, "expected event to be acked")
+	}
+
+	// The subscription was closed and the runner stopped.
+	<-sub.Done()
+	require.NoError(t, <-running)
+	require.NoError(t, sub.Close(), "closing a shutdown subscription should not error")
+	require.Zero(t, client.ActiveStreams())
+}
+
+func TestPing(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY, Version: "0.12.8"}, nil
+	}
+	emock.OnTopicNames = func(context.Context, *api.PageInfo) (*api.TopicNamesPage, error) {
+		return &api.TopicNamesPage{}, nil
+	}
+
+	latency, version, err := client.Ping(context.Background())
+	require.NoError(t, err, "could not ping ensign")
+	require.Greater(t, latency, time.Duration(0))
+	require.Equal(t, "0.12.8", version)
+	require.Equal(t, 1, emock.Calls[mock.StatusRPC])
+	require.Equal(t, 1, emock.Calls[mock.TopicNamesRPC])
+
+	// Rejected credentials should be reported as unauthorized like other RPCs
+	emock.OnTopicNames = func(context.Context, *api.PageInfo) (*api.TopicNamesPage, error) {
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	_, version, err = client.Ping(context.Background())
+	require.ErrorIs(t, err, sdk.ErrUnauthorized)
+	require.Equal(t, "0.12.8", version)
+
+	var serr *sdk.StatusError
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, codes.Unauthenticated, serr.Status.Code())
+
+	// If ensign is unavailable the status error is returned
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return nil, status.Error(codes.Unavailable, "ensign is down")
+	}
+	_, _, err = client.Ping(context.Background())
+	require.ErrorIs(t, err, sdk.ErrUnavailable)
+	require.NotErrorIs(t, err, sdk.ErrUnauthorized)
+}
+
+func TestInterceptors(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	var calls []string
+	unary := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name+" "+method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		calls = append(calls, "stream "+method)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+
+	client, err := sdk.New(
+		sdk.WithMock(emock),
+		sdk.WithAuthenticator("", true),
+		sdk.WithUnaryInterceptor(unary("first")),
+		sdk.WithUnaryInterceptor(unary("second")),
+		sdk.WithStreamInterceptor(streamer),
+	)
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+	}
+
+	_, err = client.Status(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"first " + mock.StatusRPC, "second " + mock.StatusRPC}, calls)
+
+	calls = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = client.PublishStream(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"stream " + mock.PublishRPC}, calls)
+}
+
+func TestRecordReplay(t *testing.T) {
+	// Record the responses of the "live" server to a fixtures directory
+	live := mock.New(nil)
+	defer live.Shutdown()
+
+	live.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY, Version: "v0.12.0"}, nil
+	}
+	live.OnPublish = mock.NewPublishRecorder(map[string]ulid.ULID{"orders": ulid.MustParse("01GWM89049D49FHJH81BT8795H")}).OnPublish
+	live.OnEnSQL = func(in *api.Query, stream api.Ensign_EnSQLServer) error {
+		for i := 0; i < 3; i++ {
+			if err := stream.Send(mock.NewEventWrapper()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	dir := t.TempDir()
+	recorder, err := mock.NewFixtureRecorder(dir)
+	require.NoError(t, err, "could not create fixture recorder")
+
+	client, err := sdk.New(
+		sdk.WithMock(live),
+		sdk.WithAuthenticator("", true),
+		sdk.WithUnaryInterceptor(recorder.UnaryInterceptor),
+		sdk.WithStreamInterceptor(recorder.StreamInterceptor),
+	)
+	require.NoError(t, err, "could not create client")
+
+	scenario := func(client *sdk.Client) {
+		state, err := client.Status(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "v0.12.0", state.Version)
+
+		event := &sdk.Event{Data: []byte("hello"), Mimetype: mimetype.TextPlain}
+		require.NoError(t, client.Publish("orders", event))
+		acked, err := event.Wait()
+		require.NoError(t, err)
+		require.True(t, acked, "expected event to be acked")
+
+		cursor, err := client.Query(context.Background(), "SELECT * FROM orders")
+		require.NoError(t, err)
+		events, err := cursor.FetchAll()
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+	}
+
+	scenario(client)
+	require.NoError(t, client.Close())
+
+	// Streams are recorded when they end
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "publish.pb.json"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "expected publish stream to be recorded")
+	require.NoError(t, recorder.Err())
+
+	// Replay the scenario against an offline mock using the recorded fixtures
+	offline := mock.New(nil)
+	defer offline.Shutdown()
+	require.NoError(t, offline.Replay(dir))
+
+	client, err = sdk.New(sdk.WithMock(offline), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	scenario(client)
+}
+
+func TestClaims(t *testing.T) {
+	// Clients that are not authenticated do not have claims
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	_, err = client.ProjectID()
+	require.ErrorIs(t, err, sdk.ErrNotAuthenticated)
+
+	// Authenticated clients parse the claims from their access token
+	quarterdeck, err := authtest.NewServer()
+	require.NoError(t, err, "could not create authtest server")
+	defer quarterdeck.Close()
+
+	clientID, clientSecret := quarterdeck.Register()
+	client, err = sdk.New(
+		sdk.WithEnsignEndpoint("bufnet", true),
+		sdk.WithAuthenticator(quarterdeck.URL(), false),
+		sdk.WithCredentials(clientID, clientSecret),
+	)
+	require.NoError(t, err, "could not create authenticated client")
+	defer client.Close()
+
+	claims, err := client.Claims()
+	require.NoError(t, err, "could not get claims")
+	require.Equal(t, clientID, claims.Subject)
+
+	// The authtest server does not add a project to the claims
+	_, err = client.ProjectID()
+	require.ErrorIs(t, err, sdk.ErrInvalidProjectID)
+}
+
+func TestPermissions(t *testing.T) {
+	quarterdeck, err := authtest.NewServer()
+	require.NoError(t, err, "could not create authtest server")
+	defer quarterdeck.Close()
+
+	clientID, clientSecret := quarterdeck.Register(auth.PermissionSubscriber, auth.PermissionTopicsRead)
+	client, err := sdk.New(
+		sdk.WithEnsignEndpoint("bufnet", true),
+		sdk.WithAuthenticator(quarterdeck.URL(), false),
+		sdk.WithCredentials(clientID, clientSecret),
+	)
+	require.NoError(t, err, "could not create authenticated client")
+	defer client.Close()
+
+	require.True(t, client.HasPermission(auth.PermissionSubscriber))
+	require.True(t, client.HasPermission(auth.PermissionTopicsRead))
+	require.False(t, client.HasPermission(auth.PermissionPublisher))
+
+	// Publishing without the publisher permission fails before a stream is opened
+	err = client.Publish("testing.123", &sdk.Event{Data: []byte("hello")})
+	require.ErrorIs(t, err, sdk.ErrPermissionMissing)
+	require.ErrorContains(t, err, auth.PermissionPublisher)
+
+	// Subscribing without the subscriber permission fails before a stream is opened
+	clientID, clientSecret = quarterdeck.Register(auth.PermissionPublisher)
+	client, err = sdk.New(
+		sdk.WithEnsignEndpoint("bufnet", true),
+		sdk.WithAuthenticator(quarterdeck.URL(), false),
+		sdk.WithCredentials(clientID, clientSecret),
+	)
+	require.NoError(t, err, "could not create authenticated client")
+	defer client.Close()
+
+	_, err = client.Subscribe("testing.123")
+	require.ErrorIs(t, err, sdk.ErrPermissionMissing)
+	require.ErrorContains(t, err, auth.PermissionSubscriber)
+
+	// Clients without claims cannot check permissions but are not prevented from
+	// opening streams; the server is responsible for enforcing the permissions.
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err = sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+	require.False(t, client.HasPermission(auth.PermissionPublisher))
+}
+
+func TestAuthenticatedMock(t *testing.T) {
+	quarterdeck, err := authtest.NewServer()
+	require.NoError(t, err, "could not create authtest server")
+	defer quarterdeck.Close()
+
+	var claims *auth.Claims
+	newClient := func(login bool, permissions ...string) *sdk.Client {
+		emock := mock.New(nil, mock.NewAuthenticator(quarterdeck).ServerOptions()...)
+		t.Cleanup(emock.Shutdown)
+
+		emock.OnListTopics = func(ctx context.Context, in *api.PageInfo) (*api.TopicsPage, error) {
+			claims, _ = mock.ClaimsFrom(ctx)
+			return &api.TopicsPage{}, nil
+		}
+		emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+			return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+		}
+
+		// Clients that do not login do not send an access token with requests
+		dialing := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if login {
+			authClient, err := auth.New(quarterdeck.URL(), true)
+			require.NoError(t, err, "could not create auth client")
+
+			clientID, clientSecret := quarterdeck.Register(permissions...)
+			_, err = authClient.Login(context.Background(), clientID, clientSecret)
+			require.NoError(t, err, "could not login")
+
+			dialing = append(dialing, grpc.WithUnaryInterceptor(authClient.UnaryAuthenticate), grpc.WithStreamInterceptor(authClient.StreamAuthenticate))
+		}
+
+		client, err := sdk.New(sdk.WithMock(emock, dialing...), sdk.WithAuthenticator(quarterdeck.URL(), true))
+		require.NoError(t, err, "could not create client")
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+
+	// Requests without an access token are not authenticated
+	client := newClient(false)
+	_, err = client.ListTopics(context.Background())
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// The status RPC does not require authentication
+	_, err = client.Status(context.Background())
+	require.NoError(t, err)
+
+	// Requests without the required permission are denied
+	client = newClient(true, auth.PermissionPublisher)
+	_, err = client.ListTopics(context.Background())
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Authorized requests are handled with the claims of the access token
+	client = newClient(true, auth.PermissionTopicsRead)
+	_, err = client.ListTopics(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, claims, "expected claims to be passed to the handler")
+	require.True(t, claims.HasPermission(auth.PermissionTopicsRead))
+}
+
+func TestWithTokenSource(t *testing.T) {
+	quarterdeck, err := authtest.NewServer()
+	require.NoError(t, err, "could not create authtest server")
+	defer quarterdeck.Close()
+
+	// Tokens are minted by the source rather than by authenticating with an API key
+	var calls int32
+	source := auth.TokenSourceFunc(func(context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		claims := &authtest.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "on-behalf-of"},
+			Permissions:      []string{auth.PermissionSubscriber},
+		}
+		return quarterdeck.Sign(quarterdeck.CreateAccessToken(claims))
+	})
+
+	client, err := sdk.New(
+		sdk.WithEnsignEndpoint("bufnet", true),
+		sdk.WithAuthenticator(quarterdeck.URL(), false),
+		sdk.WithTokenSource(source),
+	)
+	require.NoError(t, err, "could not create client with token source")
+	defer client.Close()
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls), "expected the client to login with the source")
+
+	claims, err := client.Claims()
+	require.NoError(t, err, "could not get claims")
+	require.Equal(t, "on-behalf-of", claims.Subject)
+	require.True(t, client.HasPermission(auth.PermissionSubscriber))
+	require.False(t, client.HasPermission(auth.PermissionPublisher))
+
+	// Tokens that do not list any permissions are not checked by the client; the server
+	// is responsible for enforcing the permissions of the token.
+	source = auth.TokenSourceFunc(func(context.Context) (string, error) {
+		claims := &authtest.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "on-behalf-of"}}
+		return quarterdeck.Sign(quarterdeck.CreateAccessToken(claims))
+	})
+
+	client, err = sdk.New(
+		sdk.WithEnsignEndpoint("bufnet", true),
+		sdk.WithAuthenticator(quarterdeck.URL(), false),
+		sdk.WithTokenSource(source),
+	)
+	require.NoError(t, err, "could not create client with token source")
+	defer client.Close()
+
+	claims, err = client.Claims()
+	require.NoError(t, err, "could not get claims")
+	require.Empty(t, claims.Permissions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = client.PublishContext(ctx, "testing.123", &sdk.Event{Data: []byte("hello")})
+	require.NotErrorIs(t, err, sdk.ErrPermissionMissing)
+}
@@ -0,0 +1,171 @@
+/*
+Package ensql provides a builder for EnSQL queries so that queries can be assembled
+from Go code and validated before they are sent to Ensign. For example:
+
+	query, err := ensql.Select("meta.region", "created").
+		From("orders").
+		Where("meta.region = ?", region).
+		Limit(10).
+		Query()
+
+The values passed to Where are bound to the query as parameters rather than being
+interpolated into the query string, which prevents injection. The resulting query can
+be executed with the EnSQL or Explain methods of the ensign client.
+*/
+package ensql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+var (
+	// ErrInvalidParam is returned when an argument cannot be encoded as a query
+	// parameter; it is aliased by the ensign package as ensign.ErrInvalidQueryParam.
+	ErrInvalidParam = errors.New("invalid query parameter")
+
+	// ErrInvalidQuery is returned by the Builder when the query it assembles is not a
+	// valid EnSQL query, e.g. because it has no topic or a placeholder has no argument.
+	ErrInvalidQuery = errors.New("invalid ensql query")
+)
+
+// Identifiers such as topic names and fields may be qualified with dots, e.g. meta.foo.
+var identifier = regexp.MustCompile(`^[A-Za-z_][\w\-]*(\.[A-Za-z_][\w\-]*)*$`)
+
+// Builder assembles an EnSQL query one clause at a time. Errors are deferred until the
+// query is built with Query so that the methods can be chained. A Builder should not
+// be modified after it is built, nor used from multiple go routines.
+type Builder struct {
+	fields     []string
+	topic      string
+	conditions []string
+	args       []interface{}
+	limit      uint64
+	offset     uint64
+	errs       []string
+}
+
+// Select starts a query that returns the specified fields of the events; if no fields
+// are specified then all fields are selected.
+func Select(fields ...string) *Builder {
+	b := &Builder{}
+	for _, field := range fields {
+		if field != "*" && !identifier.MatchString(field) {
+			b.errorf("invalid field %q", field)
+		}
+	}
+	b.fields = fields
+	return b
+}
+
+// From specifies the topic that the query selects events from.
+func (b *Builder) From(topic string) *Builder {
+	if !identifier.MatchString(topic) {
+		b.errorf("invalid topic name %q", topic)
+	}
+	b.topic = topic
+	return b
+}
+
+// Where adds a condition to the query; multiple conditions are combined with AND. The
+// condition may contain ? placeholders that are bound to the arguments in order, e.g.
+// Where("meta.foo = ? OR meta.bar = ?", foo, bar), and there must be an argument for
+// every placeholder.
+func (b *Builder) Where(condition string, args ...interface{}) *Builder {
+	if strings.TrimSpace(condition) == "" {
+		b.errorf("empty where condition")
+		return b
+	}
+
+	if n := strings.Count(condition, "?"); n != len(args) {
+		b.errorf("condition %q has %d placeholders but %d arguments", condition, n, len(args))
+		return b
+	}
+
+	// Number the placeholders after the arguments of the previous conditions.
+	var sb strings.Builder
+	for i, part := range strings.Split(condition, "?") {
+		if i > 0 {
+			sb.WriteString("$" + strconv.Itoa(len(b.args)+i))
+		}
+		sb.WriteString(part)
+	}
+
+	b.conditions = append(b.conditions, sb.String())
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Limit restricts the number of events returned by the query.
+func (b *Builder) Limit(n uint64) *Builder {
+	b.limit = n
+	return b
+}
+
+// Offset skips the specified number of events before results are returned.
+func (b *Builder) Offset(n uint64) *Builder {
+	b.offset = n
+	return b
+}
+
+// Query validates the query and returns it with its parameters bound so that it can be
+// executed by the ensign client. An error wrapping ErrInvalidQuery is returned if the
+// query is not valid, or ErrInvalidParam if an argument cannot be bound.
+func (b *Builder) Query() (_ *api.Query, err error) {
+	if b.topic == "" {
+		b.errorf("no topic specified")
+	}
+
+	if len(b.errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, strings.Join(b.errs, "; "))
+	}
+
+	query := &api.Query{Query: b.String()}
+	if query.Params, err = Bind(b.args...); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// String returns the EnSQL query string with $1, $2, etc. placeholders for arguments.
+func (b *Builder) String() string {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	if len(b.fields) == 0 {
+		sb.WriteString("*")
+	} else {
+		sb.WriteString(strings.Join(b.fields, ", "))
+	}
+
+	sb.WriteString(" FROM ")
+	sb.WriteString(b.topic)
+
+	if len(b.conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		if len(b.conditions) == 1 {
+			sb.WriteString(b.conditions[0])
+		} else {
+			sb.WriteString("(" + strings.Join(b.conditions, ") AND (") + ")")
+		}
+	}
+
+	if b.limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(strconv.FormatUint(b.limit, 10))
+	}
+
+	if b.offset > 0 {
+		sb.WriteString(" OFFSET ")
+		sb.WriteString(strconv.FormatUint(b.offset, 10))
+	}
+	return sb.String()
+}
+
+func (b *Builder) errorf(format string, args ...interface{}) {
+	b.errs = append(b.errs, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,96 @@
+package ensql_test
+
+import (
+	"testing"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/ensql"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		builder  *ensql.Builder
+		expected string
+		params   []*api.Parameter
+	}{
+		{
+			ensql.Select().From("orders"),
+			"SELECT * FROM orders",
+			nil,
+		},
+		{
+			ensql.Select("meta.region", "created").From("orders").Limit(10).Offset(20),
+			"SELECT meta.region, created FROM orders LIMIT 10 OFFSET 20",
+			nil,
+		},
+		{
+			ensql.Select().From("orders").Where("meta.region = ?", "us-east-1"),
+			"SELECT * FROM orders WHERE meta.region = $1",
+			[]*api.Parameter{{Name: "$1", Value: &api.Parameter_S{S: "us-east-1"}}},
+		},
+		{
+			ensql.Select().From("orders").Where("meta.region = ? OR meta.region = ?", "us", "eu").Where("meta.total > ?", 100),
+			"SELECT * FROM orders WHERE (meta.region = $1 OR meta.region = $2) AND (meta.total > $3)",
+			[]*api.Parameter{
+				{Name: "$1", Value: &api.Parameter_S{S: "us"}},
+				{Name: "$2", Value: &api.Parameter_S{S: "eu"}},
+				{Name: "$3", Value: &api.Parameter_I{I: 100}},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		query, err := tc.builder.Query()
+		require.NoError(t, err, "test case %d failed", i)
+		require.Equal(t, tc.expected, query.Query, "test case %d failed", i)
+		require.Len(t, query.Params, len(tc.params), "test case %d failed", i)
+		for j, param := range query.Params {
+			require.True(t, proto.Equal(tc.params[j], param), "test case %d param %d does not match", i, j)
+		}
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	testCases := []*ensql.Builder{
+		ensql.Select(),
+		ensql.Select("meta.foo; DROP"),
+		ensql.Select().From("orders WHERE 1=1"),
+		ensql.Select().From("orders").Where(""),
+		ensql.Select().From("orders").Where("meta.foo = ?"),
+		ensql.Select().From("orders").Where("meta.foo = 'bar'", "baz"),
+	}
+
+	for i, builder := range testCases {
+		_, err := builder.Query()
+		require.ErrorIs(t, err, ensql.ErrInvalidQuery, "test case %d failed", i)
+	}
+
+	_, err := ensql.Select().From("orders").Where("meta.foo = ?", struct{}{}).Query()
+	require.ErrorIs(t, err, ensql.ErrInvalidParam)
+}
+
+func TestBind(t *testing.T) {
+	ts := time.Date(2023, 8, 14, 12, 31, 3, 0, time.UTC)
+	params, err := ensql.Bind(int8(-4), uint32(42), float32(0.5), false, []byte{0x1}, ts)
+	require.NoError(t, err)
+
+	expected := []*api.Parameter{
+		{Name: "$1", Value: &api.Parameter_I{I: -4}},
+		{Name: "$2", Value: &api.Parameter_I{I: 42}},
+		{Name: "$3", Value: &api.Parameter_D{D: 0.5}},
+		{Name: "$4", Value: &api.Parameter_B{B: false}},
+		{Name: "$5", Value: &api.Parameter_Y{Y: []byte{0x1}}},
+		{Name: "$6", Value: &api.Parameter_S{S: "2023-08-14T12:31:03Z"}},
+	}
+
+	require.Len(t, params, len(expected))
+	for i, param := range params {
+		require.True(t, proto.Equal(expected[i], param), "param %d does not match", i)
+	}
+
+	_, err = ensql.Bind(uint(1<<63), 1)
+	require.ErrorIs(t, err, ensql.ErrInvalidParam)
+}
@@ -0,0 +1,74 @@
+package ensql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Bind encodes the positional arguments as the query parameters for the $1, $2, etc.
+// placeholders in a query. Arguments may be signed or unsigned integers, floats, bools,
+// strings, byte slices, or time.Time values, which are encoded as RFC 3339 strings. An
+// error wrapping ErrInvalidParam is returned for any other type of argument.
+func Bind(args ...interface{}) (params []*api.Parameter, err error) {
+	params = make([]*api.Parameter, 0, len(args))
+	for i, arg := range args {
+		var param *api.Parameter
+		if param, err = Param("$"+strconv.Itoa(i+1), arg); err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// Param encodes the Go value as a query parameter with the specified name.
+func Param(name string, arg interface{}) (_ *api.Parameter, err error) {
+	param := &api.Parameter{Name: name}
+	switch v := arg.(type) {
+	case int:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case int8:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case int16:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case int32:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case int64:
+		param.Value = &api.Parameter_I{I: v}
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: %s overflows int64", ErrInvalidParam, name)
+		}
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case uint8:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case uint16:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case uint32:
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: %s overflows int64", ErrInvalidParam, name)
+		}
+		param.Value = &api.Parameter_I{I: int64(v)}
+	case float32:
+		param.Value = &api.Parameter_D{D: float64(v)}
+	case float64:
+		param.Value = &api.Parameter_D{D: v}
+	case bool:
+		param.Value = &api.Parameter_B{B: v}
+	case []byte:
+		param.Value = &api.Parameter_Y{Y: v}
+	case string:
+		param.Value = &api.Parameter_S{S: v}
+	case time.Time:
+		param.Value = &api.Parameter_S{S: v.Format(time.RFC3339Nano)}
+	default:
+		return nil, fmt.Errorf("%w: %s has unsupported type %T", ErrInvalidParam, name, arg)
+	}
+	return param, nil
+}
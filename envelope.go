@@ -0,0 +1,94 @@
+package ensign
+
+import (
+	"encoding/json"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+)
+
+// Envelope is a stable, self-describing JSON representation of an event that includes
+// the event's identifiers and position in the topic along with the user-defined event
+// fields. Envelopes are intended to be used to log events, store them in audit trails,
+// and to diff events across systems. The payload is base64 encoded in the data field;
+// use WithoutPayload to omit the payload, e.g. if it contains sensitive information.
+type Envelope struct {
+	ID        string            `json:"id,omitempty"`
+	TopicID   string            `json:"topic_id,omitempty"`
+	Epoch     uint64            `json:"epoch"`
+	Offset    uint64            `json:"offset"`
+	Region    string            `json:"region,omitempty"`
+	Key       []byte            `json:"key,omitempty"`
+	Type      *EnvelopeType     `json:"type,omitempty"`
+	Mimetype  string            `json:"mimetype"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Size      int               `json:"size"`
+	Data      []byte            `json:"data,omitempty"`
+	Created   *time.Time        `json:"created,omitempty"`
+	Committed *time.Time        `json:"committed,omitempty"`
+}
+
+// EnvelopeType describes the schema of the event in an envelope.
+type EnvelopeType struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Envelope returns the JSON envelope representation of the event including the data.
+func (e *Event) Envelope() *Envelope {
+	env := &Envelope{
+		ID:       e.ID(),
+		TopicID:  e.TopicID(),
+		Mimetype: e.Mimetype.MimeType(),
+		Metadata: e.Metadata,
+		Size:     len(e.Data),
+		Data:     e.Data,
+	}
+
+	if e.Type != nil {
+		env.Type = &EnvelopeType{Name: e.Type.Name, Version: e.Type.Semver()}
+	}
+
+	if !e.Created.IsZero() {
+		created := e.Created
+		env.Created = &created
+	}
+
+	if e.info != nil {
+		env.Offset, env.Epoch = e.Offset()
+		env.Key = e.info.Key
+		if e.info.Region != region.Region_UNKNOWN {
+			env.Region = e.info.Region.String()
+		}
+	}
+
+	if committed := e.Committed(); !committed.IsZero() {
+		env.Committed = &committed
+	}
+	return env
+}
+
+// NewEnvelope creates the JSON envelope representation of an event wrapper, e.g. when
+// working with the events received from the low-level stream or query APIs.
+func NewEnvelope(wrapper *api.EventWrapper) (_ *Envelope, err error) {
+	event := &Event{}
+	if err = event.fromPB(wrapper, query); err != nil {
+		return nil, err
+	}
+	return event.Envelope(), nil
+}
+
+// WithoutPayload returns a copy of the envelope without the event data so that the
+// envelope can be logged without exposing the payload; the size of the data is kept.
+func (e *Envelope) WithoutPayload() *Envelope {
+	env := *e
+	env.Data = nil
+	return &env
+}
+
+// MarshalJSON returns the JSON envelope representation of the event, including the
+// base64 encoded payload. Use Envelope().WithoutPayload() to omit the payload.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Envelope())
+}
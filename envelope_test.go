@@ -0,0 +1,63 @@
+package ensign_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestEventMarshalJSON(t *testing.T) {
+	created := time.Date(2023, 10, 12, 8, 31, 2, 0, time.UTC)
+	committed := created.Add(250 * time.Millisecond)
+
+	wrapper := &api.EventWrapper{
+		Id:        []byte{0x01, 0x83, 0x42, 0x5F, 0x66, 0x6F, 0x00, 0x6F, 0xEB, 0x6B},
+		TopicId:   ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A").Bytes(),
+		Epoch:     2,
+		Offset:    42,
+		Region:    region.Region_LKE_US_EAST_1A,
+		Committed: timestamppb.New(committed),
+	}
+
+	err := wrapper.Wrap(&api.Event{
+		Data:     []byte(`{"hello":"world"}`),
+		Metadata: map[string]string{"foo": "bar"},
+		Mimetype: mimetype.ApplicationJSON,
+		Type:     &api.Type{Name: "Greeting", MajorVersion: 1, MinorVersion: 2},
+		Created:  timestamppb.New(created),
+	})
+	require.NoError(t, err, "could not wrap event")
+
+	expected := `{"id":"061m4qv6dw06ztvb","topic_id":"01HCG64Y1SMFQBW7A42SRV207A","epoch":2,"offset":42,"region":"LKE_US_EAST_1A","type":{"name":"Greeting","version":"1.2.0"},"mimetype":"application/json","metadata":{"foo":"bar"},"size":17,"data":"eyJoZWxsbyI6IndvcmxkIn0=","created":"2023-10-12T08:31:02Z","committed":"2023-10-12T08:31:02.25Z"}`
+
+	// Marshal the event received from a subscription
+	event := ensign.NewIncomingEvent(wrapper, nil)
+	data, err := json.Marshal(event)
+	require.NoError(t, err, "could not marshal event")
+	require.JSONEq(t, expected, string(data))
+
+	// The wrapper envelope should be identical to the event envelope
+	env, err := ensign.NewEnvelope(wrapper)
+	require.NoError(t, err, "could not create wrapper envelope")
+	require.Equal(t, event.Envelope(), env)
+
+	// The payload can be omitted from the envelope
+	data, err = json.Marshal(env.WithoutPayload())
+	require.NoError(t, err, "could not marshal envelope without payload")
+	require.NotContains(t, string(data), `"data"`)
+	require.Contains(t, string(data), `"size":17`)
+	require.NotNil(t, env.Data, "expected original envelope to be unmodified")
+
+	// An event that has not been published should only have the user-defined fields
+	data, err = json.Marshal(&ensign.Event{Data: []byte("foo"), Mimetype: mimetype.TextPlain})
+	require.NoError(t, err, "could not marshal unpublished event")
+	require.JSONEq(t, `{"epoch":0,"offset":0,"mimetype":"text/plain","size":3,"data":"Zm9v"}`, string(data))
+}
@@ -1,32 +1,164 @@
 package ensign
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/ensql"
+	"github.com/rotationalio/go-ensign/stream"
+	"github.com/rotationalio/go-ensign/topics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Standardized errors that the client may return from configuration issues or parsed
 // from gRPC service calls. These errors can be evaluated using errors.Is to test for
 // different error conditions in client code.
 var (
-	ErrMissingEndpoint     = errors.New("invalid options: endpoint is required")
-	ErrMissingClientID     = errors.New("invalid options: client ID is required")
-	ErrMissingClientSecret = errors.New("invalid options: client secret is required")
-	ErrMissingAuthURL      = errors.New("invalid options: auth url is required")
-	ErrMissingMock         = errors.New("invalid options: in testing mode a mock grpc server is required")
-	ErrTopicNameNotFound   = errors.New("topic name not found in project")
-	ErrCannotAck           = errors.New("cannot ack or nack an event not received from subscribe")
-	ErrOverwrite           = errors.New("this operation would overwrite existing event data")
-	ErrNoTopicID           = errors.New("topic id is not available on event")
-	ErrEmptyQuery          = errors.New("query cannot be empty")
-	ErrCursorClosed        = errors.New("cursor is closed")
-	ErrTopicInfoNotFound   = errors.New("no info found for specified topic")
-	ErrAmbiguousTopicInfo  = errors.New("could not identify info for topic")
-	ErrNoRows              = errors.New("ensql: no rows in result set")
+	ErrMissingEndpoint      = errors.New("invalid options: endpoint is required")
+	ErrMissingClientID      = errors.New("invalid options: client ID is required")
+	ErrMissingClientSecret  = errors.New("invalid options: client secret is required")
+	ErrMissingAuthURL       = errors.New("invalid options: auth url is required")
+	ErrMissingMock          = errors.New("invalid options: in testing mode a mock grpc server is required")
+	ErrInvalidServiceConfig = errors.New("invalid options: service config must be valid json")
+	ErrNoEndpoints          = errors.New("no ensign endpoints were discovered")
+	ErrTopicNameNotFound    = topics.ErrTopicNameNotFound
+	ErrCannotAck            = errors.New("cannot ack or nack an event not received from subscribe")
+	ErrNotPublished         = errors.New("cannot wait for an event that has not been published")
+	ErrOverwrite            = errors.New("this operation would overwrite existing event data")
+	ErrNoTopicID            = errors.New("topic id is not available on event")
+	ErrEmptyQuery           = errors.New("query cannot be empty")
+	ErrCursorClosed         = errors.New("cursor is closed")
+	ErrTopicInfoNotFound    = errors.New("no info found for specified topic")
+	ErrAmbiguousTopicInfo   = errors.New("could not identify info for topic")
+	ErrNoRows               = errors.New("ensql: no rows in result set")
+	ErrInvalidScanDest      = errors.New("ensql: invalid scan destination")
+	ErrReadOnlyClient       = errors.New("operation not permitted: client is in read-only mode")
+	ErrCheckpointVersion    = errors.New("unsupported checkpoint version")
+	ErrInvalidCheckpoint    = errors.New("invalid checkpoint")
+	ErrInvalidInterval      = errors.New("interval must be greater than zero")
+	ErrNoHandlers           = errors.New("at least one topic handler is required")
+	ErrNotReady             = errors.New("client is not ready")
+	ErrClientClosed         = errors.New("client is closed")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrTopicNotReady        = errors.New("topic is not ready")
+	ErrInvalidProject       = errors.New("invalid project name")
+	ErrProjectExists        = errors.New("project has already been added")
+	ErrUnknownProject       = errors.New("unknown project")
+	ErrMultipleProjects     = errors.New("cannot subscribe to topics in multiple projects on one stream")
+	ErrTooManyStreams       = errors.New("maximum number of open streams reached")
+	ErrInvalidMaxStreams    = errors.New("invalid options: max streams cannot be negative")
+	ErrInvalidRetryAttempts = errors.New("invalid options: max retry attempts cannot be negative")
+	ErrInvalidMsgSize       = errors.New("invalid options: max message size cannot be negative")
+	ErrInvalidWindowSize    = errors.New("invalid options: window size cannot be negative")
+	ErrNoCertificates       = errors.New("invalid options: no certificates found in ca file")
+	ErrInvalidProxy         = errors.New("invalid proxy")
+	ErrInvalidRefreshWindow = errors.New("invalid options: token refresh window cannot be negative")
+	ErrNotAuthenticated     = errors.New("client is not authenticated")
+	ErrInvalidProjectID     = errors.New("access token does not have a valid project id")
+	ErrPermissionMissing    = errors.New("permission missing")
+	ErrNoCodec              = errors.New("no codec registered")
+	ErrMimetypeMismatch     = errors.New("event data does not have the expected mimetype")
+	ErrInvalidMimetype      = errors.New("unknown event mimetype")
+	ErrInvalidCodecValue    = errors.New("value cannot be encoded by the codec")
+	ErrInvalidTopicID       = errors.New("invalid topic id")
+	ErrDestroyNotConfirmed  = errors.New("topic destroy was not confirmed")
+	ErrTopicNotDestroyed    = errors.New("topic has not been destroyed")
+	ErrTopicAlreadyExists   = topics.ErrTopicAlreadyExists
+	ErrTopicNotFound        = errors.New("topic not found")
+	ErrTopicArchived        = errors.New("topic is archived and read-only")
+	ErrUnauthorized         = errors.New("not authorized to perform this operation")
+	ErrQuotaExceeded        = errors.New("quota exceeded")
+	ErrUnavailable          = errors.New("ensign is unavailable")
+	ErrInvalidArgument      = errors.New("invalid argument")
+	ErrInvalidSigningKey    = errors.New("invalid options: signing key id and secret are required")
+	ErrUnsignedEvent        = errors.New("event is not signed")
+	ErrInvalidSignature     = errors.New("invalid event signature")
+	ErrInvalidContentHash   = errors.New("invalid options: unknown content hash algorithm")
+	ErrInvalidQueryParam    = ensql.ErrInvalidParam
+	ErrReservedMetadataKey  = errors.New("metadata key is reserved for use by the sdk")
+	ErrEventTooLarge        = stream.ErrEventTooLarge
+	ErrInvalidChunkSize     = errors.New("chunk size is too small to split the event")
+	ErrInvalidChunk         = errors.New("invalid event chunk")
 )
 
+// A StatusError is returned when an Ensign RPC fails with a gRPC error that can be
+// categorized, e.g. an Unauthenticated status code is categorized as ErrUnauthorized.
+// StatusErrors unwrap to the category so that they can be evaluated with errors.Is;
+// the gRPC status is preserved and can still be inspected using status.FromError.
+type StatusError struct {
+	Err    error
+	Status *status.Status
+}
+
+// Error implements the error interface so that a StatusError can be returned as an error.
+func (e *StatusError) Error() string {
+	if msg := e.Status.Message(); msg != "" {
+		return fmt.Sprintf("%s: %s", e.Err, msg)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap allows StatusErrors to be compared with the error category.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the gRPC status of the error for use with status.FromError.
+func (e *StatusError) GRPCStatus() *status.Status {
+	return e.Status
+}
+
+// Code returns the gRPC status code of the error.
+func (e *StatusError) Code() codes.Code {
+	return e.Status.Code()
+}
+
+// Translates gRPC errors returned by the Ensign API into StatusErrors so that callers
+// can use errors.Is instead of matching status codes. Errors that are not gRPC errors
+// or that cannot be categorized are returned unmodified. Canceled and deadline exceeded
+// errors are translated into the corresponding context errors.
+func translateError(err error) error {
+	serr, ok := status.FromError(err)
+	if err == nil || !ok {
+		return err
+	}
+
+	var category error
+	switch serr.Code() {
+	case codes.Canceled:
+		category = context.Canceled
+	case codes.DeadlineExceeded:
+		category = context.DeadlineExceeded
+	case codes.Unauthenticated, codes.PermissionDenied:
+		category = ErrUnauthorized
+	case codes.ResourceExhausted:
+		category = ErrQuotaExceeded
+	case codes.Unavailable:
+		category = ErrUnavailable
+	case codes.InvalidArgument:
+		category = ErrInvalidArgument
+	case codes.NotFound:
+		category = ErrTopicNotFound
+	case codes.AlreadyExists:
+		category = ErrTopicAlreadyExists
+	case codes.FailedPrecondition:
+		// Ensign reports operations on archived topics as failed preconditions.
+		msg := strings.ToLower(serr.Message())
+		if !strings.Contains(msg, "archived") && !strings.Contains(msg, "read-only") && !strings.Contains(msg, "readonly") {
+			return err
+		}
+		category = ErrTopicArchived
+	default:
+		return err
+	}
+	return &StatusError{Err: category, Status: serr}
+}
+
 // A Nack from the server on a publish stream indicates that the event was not
 // successfully published for the reason specified by the code and the message. Nacks
 // received by the publisher indicate that the event should be retried or dropped.
@@ -47,6 +179,34 @@ func (e *NackError) Error() string {
 	return e.Code.String()
 }
 
+// RetryAfter returns the delay requested by the retry-after hint of the nack if the
+// nack was sent with a backoff hint (see Event.NackWithBackoff).
+func (e *NackError) RetryAfter() (time.Duration, bool) {
+	return (&api.Nack{Error: e.Message}).RetryAfter()
+}
+
+// RetryAfter returns the retry-after hint of the nack if the error is a NackError or a
+// stream.NackError (e.g. returned to a PublishAsync callback) that has a backoff hint.
+// Publishers can use the hint to pace retries when the server signals congestion.
+func RetryAfter(err error) (time.Duration, bool) {
+	var nerr *NackError
+	if errors.As(err, &nerr) {
+		return nerr.RetryAfter()
+	}
+
+	var serr *stream.NackError
+	if errors.As(err, &serr) && serr.Nack != nil {
+		return serr.Nack.RetryAfter()
+	}
+	return 0, false
+}
+
+// Info describes the nack code, e.g. the recommended action the client should take to
+// handle the nack and whether the nacked operation may succeed without changes.
+func (e *NackError) Info() api.NackCodeInfo {
+	return e.Code.Info()
+}
+
 func makeNackError(nack *api.Nack) error {
 	return &NackError{
 		ID:      nack.Id,
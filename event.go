@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -37,6 +40,11 @@ type Event struct {
 	// Created is the timestamp that the event was created according to the client clock.
 	Created time.Time
 
+	// Key is an optional partition key. If the topic is sharded using the
+	// CONSISTENT_KEY_HASH strategy, events with the same key are assigned to the same
+	// shard and are kept in order relative to each other (see stream.ShardFor).
+	Key []byte
+
 	// Internal fields used for managing the event through the publish or subscribe
 	// workflows. The goal of the public facing parts of the event is to give the user
 	// an easy tool to work with events while abstracting Ensign eventing details.
@@ -47,6 +55,8 @@ type Event struct {
 	err   error
 	pub   <-chan *api.PublisherReply
 	sub   Acknowledger
+	sent  time.Time
+	acked time.Time
 }
 
 // Acknowledger allows consumers to send acks/nacks back to the server when they have
@@ -70,6 +80,26 @@ const (
 	nacked                         // event has been nacked from user or server
 )
 
+// String returns a human readable name of the event state.
+func (s eventState) String() string {
+	switch s {
+	case initialized:
+		return "initialized"
+	case published:
+		return "published"
+	case subscription:
+		return "subscription"
+	case query:
+		return "query"
+	case acked:
+		return "acked"
+	case nacked:
+		return "nacked"
+	default:
+		return fmt.Sprintf("eventState(%d)", s)
+	}
+}
+
 const (
 	rlidSize    = 10
 	encodedSize = 16
@@ -78,29 +108,37 @@ const (
 
 // Returns the event ID if the event has been published; otherwise returns empty string.
 func (e *Event) ID() string {
-	if e.info != nil && len(e.info.Id) > 0 {
+	if e.info != nil {
+		return encodeEventID(e.info.Id)
+	}
+	return ""
+}
+
+// Encodes an event ID as a string, returning an empty string if there is no ID.
+func encodeEventID(id []byte) string {
+	if len(id) > 0 {
 		// TODO: this is a port of the RLID encoding; is this the best way to encode?
-		if len(e.info.Id) == rlidSize {
+		if len(id) == rlidSize {
 			dst := make([]byte, encodedSize)
-			dst[0] = encoding[(e.info.Id[0]&248)>>3]
-			dst[1] = encoding[((e.info.Id[0]&7)<<2)|((e.info.Id[1]&192)>>6)]
-			dst[2] = encoding[(e.info.Id[1]&62)>>1]
-			dst[3] = encoding[((e.info.Id[1]&1)<<4)|((e.info.Id[2]&240)>>4)]
-			dst[4] = encoding[((e.info.Id[2]&15)<<1)|((e.info.Id[3]&128)>>7)]
-			dst[5] = encoding[(e.info.Id[3]&124)>>2]
-			dst[6] = encoding[((e.info.Id[3]&3)<<3)|((e.info.Id[4]&224)>>5)]
-			dst[7] = encoding[e.info.Id[4]&31]
-			dst[8] = encoding[(e.info.Id[5]&248)>>3]
-			dst[9] = encoding[((e.info.Id[5]&7)<<2)|((e.info.Id[6]&192)>>6)]
-			dst[10] = encoding[(e.info.Id[6]&62)>>1]
-			dst[11] = encoding[((e.info.Id[6]&1)<<4)|((e.info.Id[7]&240)>>4)]
-			dst[12] = encoding[((e.info.Id[7]&15)<<1)|((e.info.Id[8]&128)>>7)]
-			dst[13] = encoding[(e.info.Id[8]&124)>>2]
-			dst[14] = encoding[((e.info.Id[8]&3)<<3)|((e.info.Id[9]&224)>>5)]
-			dst[15] = encoding[e.info.Id[9]&31]
+			dst[0] = encoding[(id[0]&248)>>3]
+			dst[1] = encoding[((id[0]&7)<<2)|((id[1]&192)>>6)]
+			dst[2] = encoding[(id[1]&62)>>1]
+			dst[3] = encoding[((id[1]&1)<<4)|((id[2]&240)>>4)]
+			dst[4] = encoding[((id[2]&15)<<1)|((id[3]&128)>>7)]
+			dst[5] = encoding[(id[3]&124)>>2]
+			dst[6] = encoding[((id[3]&3)<<3)|((id[4]&224)>>5)]
+			dst[7] = encoding[id[4]&31]
+			dst[8] = encoding[(id[5]&248)>>3]
+			dst[9] = encoding[((id[5]&7)<<2)|((id[6]&192)>>6)]
+			dst[10] = encoding[(id[6]&62)>>1]
+			dst[11] = encoding[((id[6]&1)<<4)|((id[7]&240)>>4)]
+			dst[12] = encoding[((id[7]&15)<<1)|((id[8]&128)>>7)]
+			dst[13] = encoding[(id[8]&124)>>2]
+			dst[14] = encoding[((id[8]&3)<<3)|((id[9]&224)>>5)]
+			dst[15] = encoding[id[9]&31]
 			return string(dst)
 		}
-		return fmt.Sprintf("%X", e.info.Id)
+		return fmt.Sprintf("%X", id)
 	}
 	return ""
 }
@@ -157,6 +195,37 @@ func (e *Event) Committed() time.Time {
 	return time.Time{}
 }
 
+// Latency returns the duration between when the event was created according to the
+// client clock and when the event was committed by the Ensign server. If the event has
+// not been committed or has no created timestamp, zero is returned. Note that because
+// the created and committed timestamps come from different clocks, clock skew between
+// the client and server may affect the measurement.
+func (e *Event) Latency() time.Duration {
+	committed := e.Committed()
+	if committed.IsZero() || e.Created.IsZero() {
+		return 0
+	}
+	return committed.Sub(e.Created)
+}
+
+// RoundTrip returns the duration between when the event was published by the client
+// and when the ack from the server was observed on the event (e.g. by calling Acked).
+// If the event has not been published or acked then zero is returned. For precise,
+// aggregated publish latencies use the publisher stats returned by PublishStats.
+func (e *Event) RoundTrip() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state == published {
+		e.checkpub()
+	}
+
+	if e.sent.IsZero() || e.acked.IsZero() {
+		return 0
+	}
+	return e.acked.Sub(e.sent)
+}
+
 // Acked allows a user to check if an event published to an event stream has been
 // successfully received by the server.
 func (e *Event) Acked() (bool, error) {
@@ -185,24 +254,79 @@ func (e *Event) Nacked() (bool, error) {
 	return e.state == nacked, e.err
 }
 
+// Wait blocks until an event published to an event stream is acked or nacked by the
+// server or until the event context is done, returning true if the event was acked.
+// If the context is done before a reply is received, the context error is returned. An
+// error is returned if the event was not published.
+func (e *Event) Wait() (bool, error) {
+	return e.wait(nil)
+}
+
+// WaitForAck blocks until an event published to an event stream is acked or nacked by
+// the server or until the context is done, returning true if the event was acked. It
+// behaves like Wait but is bounded by the specified context rather than the context of
+// the event. If the client was created WithPublishAckTimeout, events that are not
+// replied to within the timeout are nacked with the TIMEOUT code.
+func (e *Event) WaitForAck(ctx context.Context) (bool, error) {
+	return e.wait(ctx)
+}
+
+// Waits for the reply to the published event; if ctx is nil the event context is used.
+func (e *Event) wait(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	switch e.state {
+	case published:
+	case acked, nacked:
+		defer e.mu.Unlock()
+		return e.state == acked, e.err
+	default:
+		e.mu.Unlock()
+		return false, ErrNotPublished
+	}
+
+	// Do not hold the lock while waiting so that the state can be checked concurrently.
+	pub := e.pub
+	if ctx == nil {
+		ctx = e.Context()
+	}
+	e.mu.Unlock()
+
+	select {
+	case rep, ok := <-pub:
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if ok && e.state == published {
+			e.handlepub(rep)
+		}
+		return e.state == acked, e.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
 func (e *Event) checkpub() {
 	select {
 	case rep := <-e.pub:
-		switch msg := rep.Embed.(type) {
-		case *api.PublisherReply_Ack:
-			e.state = acked
-			e.info.Id = msg.Ack.Id
-			e.info.Committed = msg.Ack.Committed
-		case *api.PublisherReply_Nack:
-			e.state = nacked
-			e.err = makeNackError(msg.Nack)
-		default:
-			e.err = fmt.Errorf("unhandled publisher reply %T", rep.Embed)
-		}
+		e.handlepub(rep)
 	default:
 	}
 }
 
+func (e *Event) handlepub(rep *api.PublisherReply) {
+	switch msg := rep.Embed.(type) {
+	case *api.PublisherReply_Ack:
+		e.state = acked
+		e.acked = time.Now()
+		e.info.Id = msg.Ack.Id
+		e.info.Committed = msg.Ack.Committed
+	case *api.PublisherReply_Nack:
+		e.state = nacked
+		e.err = makeNackError(msg.Nack)
+	default:
+		e.err = fmt.Errorf("unhandled publisher reply %T", rep.Embed)
+	}
+}
+
 // Ack allows a user to acknowledge back to the Ensign server that an event received by
 // a subscription stream has been successfully consumed. For consumer groups that have
 // exactly-once or at-least-once semantics, this signals the message has been delivered
@@ -241,6 +365,22 @@ func (e *Event) Ack() (bool, error) {
 // the nack, then this method returns false. If this event was not received on a
 // subscribe stream then an error is returned.
 func (e *Event) Nack(code api.Nack_Code) (bool, error) {
+	return e.nack(&api.Nack{Code: code})
+}
+
+// NackWithBackoff nacks the event in the same manner as Nack but includes a hint that
+// the event should not be redelivered until after the delay, e.g. because a downstream
+// service that the consumer depends on is overloaded and immediate redelivery would make
+// things worse. The hint is encoded in the error message of the nack (see
+// api.RetryAfterPrefix) and can be parsed from a NackError using RetryAfter. Honoring
+// the hint is up to the server or the application that handles the nack.
+func (e *Event) NackWithBackoff(code api.Nack_Code, delay time.Duration) (bool, error) {
+	nack := &api.Nack{Code: code}
+	nack.SetRetryAfter(delay)
+	return e.nack(nack)
+}
+
+func (e *Event) nack(nack *api.Nack) (bool, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -254,7 +394,8 @@ func (e *Event) Nack(code api.Nack_Code) (bool, error) {
 	}
 
 	// Send the nack on the sub channel to the Ensign server?
-	if e.err = e.sub.Nack(&api.Nack{Id: e.info.Id, Code: code}); e.err != nil {
+	nack.Id = e.info.Id
+	if e.err = e.sub.Nack(nack); e.err != nil {
 		return false, e.err
 	}
 
@@ -262,6 +403,13 @@ func (e *Event) Nack(code api.Nack_Code) (bool, error) {
 	return true, nil
 }
 
+// Returns true if the event has been acked or nacked.
+func (e *Event) handled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state == acked || e.state == nacked
+}
+
 // Err returns any error that occurred processing the event.
 func (e *Event) Err() error {
 	return e.err
@@ -282,12 +430,19 @@ func (e *Event) SetContext(ctx context.Context) {
 
 // Clone the event, resetting its state and removing acks, nacks, created timestamp and
 // context. Useful for resending events or for duplicating an event to edit and publish.
+// The data, metadata, key, and type of the event are deep copied so that modifying the
+// clone does not modify the original event.
 func (e *Event) Clone() *Event {
+	return e.CloneWithData(e.Data)
+}
+
+// CloneWithData clones the event in the same manner as Clone but replaces the payload of
+// the clone with a copy of the specified data, e.g. to publish the same kind of event
+// with a different datagram.
+func (e *Event) CloneWithData(data []byte) *Event {
 	event := &Event{
-		Metadata: make(Metadata),
-		Data:     make([]byte, 0, len(e.Data)),
+		Metadata: make(Metadata, len(e.Metadata)),
 		Mimetype: e.Mimetype,
-		Type:     e.Type,
 		state:    initialized,
 	}
 
@@ -296,12 +451,39 @@ func (e *Event) Clone() *Event {
 		event.Metadata[key] = val
 	}
 
-	// Copy the data
-	copy(event.Data, e.Data)
+	// Copy the data and key, preserving nil slices
+	if data != nil {
+		event.Data = make([]byte, len(data))
+		copy(event.Data, data)
+	}
+
+	if e.Key != nil {
+		event.Key = make([]byte, len(e.Key))
+		copy(event.Key, e.Key)
+	}
+
+	// Copy the type
+	if e.Type != nil {
+		event.Type = proto.Clone(e.Type).(*api.Type)
+	}
 
 	return event
 }
 
+// CloneN returns n clones of the event (see Clone), e.g. to publish the same event to
+// multiple topics. If n is not positive, nil is returned.
+func (e *Event) CloneN(n int) []*Event {
+	if n <= 0 {
+		return nil
+	}
+
+	events := make([]*Event, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, e.Clone())
+	}
+	return events
+}
+
 // Compare two events to determine if they are equivalent by data.
 // See Same() to determine if they are the same event by offset/topic.
 func (e *Event) Equals(o *Event) bool {
@@ -335,6 +517,25 @@ func (e *Event) Equals(o *Event) bool {
 	return bytes.Equal(e.Data, o.Data)
 }
 
+// Sets the defaults of an event that is about to be published so that it is not sent
+// with a zero created timestamp or nil metadata: the created timestamp is set to now
+// and the metadata is initialized. Returns ErrInvalidMimetype if the mimetype of the
+// event is not defined by the Ensign protocol.
+func (e *Event) normalize() error {
+	if _, ok := mimetype.MIME_name[int32(e.Mimetype)]; !ok {
+		return fmt.Errorf("%w: %d", ErrInvalidMimetype, int32(e.Mimetype))
+	}
+
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+
+	if e.Metadata == nil {
+		e.Metadata = make(Metadata)
+	}
+	return nil
+}
+
 // Convert an event into a protocol buffer event.
 func (e *Event) Proto() *api.Event {
 	return &api.Event{
@@ -346,11 +547,122 @@ func (e *Event) Proto() *api.Event {
 	}
 }
 
-// Returns the event wrapper which contains the API event info. Used for debugging.
+// SignedBy returns the ID of the key that signed the event or an empty string if the
+// event is not signed. The signature is only verified if the event was received by a
+// subscription created WithVerifySignatures.
+func (e *Event) SignedBy() string {
+	if e.info != nil && e.info.Encryption != nil && e.info.Encryption.SignatureAlgorithm != api.Encryption_PLAINTEXT {
+		return e.info.Encryption.PublicKeyId
+	}
+	return ""
+}
+
+// Returns the event wrapper which contains the API event info. Used for debugging; use
+// Descriptor to access the event info in production code.
 func (e *Event) Info() *api.EventWrapper {
 	return e.info
 }
 
+// String returns a concise, single line summary of the event for debugging and logging
+// that includes the event's identifiers, type, mimetype, size and state. The event data
+// and metadata values are never included so that the summary can be safely logged.
+func (e *Event) String() string {
+	return e.summarize().String()
+}
+
+// Format implements fmt.Formatter so that events are printed using their summary rather
+// than as a struct with mutexes and channels. The %+v verb also includes the created
+// timestamp and the metadata keys (but not the values) in the summary.
+func (e *Event) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		summary := e.summarize()
+		if f.Flag('+') {
+			fmt.Fprint(f, summary.verbose())
+			return
+		}
+		fmt.Fprint(f, summary.String())
+	case 's':
+		fmt.Fprint(f, e.String())
+	case 'q':
+		fmt.Fprintf(f, "%q", e.String())
+	default:
+		fmt.Fprintf(f, "%%!%c(ensign.Event=%s)", verb, e.String())
+	}
+}
+
+// eventSummary is a redacted snapshot of the event used for String, Format and logging.
+type eventSummary struct {
+	id       string
+	topic    string
+	etype    string
+	mimetype string
+	size     int
+	state    eventState
+	epoch    uint64
+	offset   uint64
+	created  time.Time
+	metadata []string
+}
+
+func (e *Event) summarize() (s eventSummary) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s = eventSummary{
+		id:       e.ID(),
+		topic:    e.TopicID(),
+		mimetype: e.Mimetype.MimeType(),
+		size:     len(e.Data),
+		state:    e.state,
+		created:  e.Created,
+	}
+
+	if e.Type != nil {
+		s.etype = e.Type.Version()
+	}
+
+	s.offset, s.epoch = e.Offset()
+
+	s.metadata = make([]string, 0, len(e.Metadata))
+	for key := range e.Metadata {
+		s.metadata = append(s.metadata, key)
+	}
+	sort.Strings(s.metadata)
+	return s
+}
+
+func (s eventSummary) String() string {
+	var b strings.Builder
+	b.WriteString("Event{")
+	if s.id != "" {
+		fmt.Fprintf(&b, "id=%s ", s.id)
+	}
+	if s.topic != "" {
+		fmt.Fprintf(&b, "topic=%s ", s.topic)
+	}
+	if s.etype != "" {
+		fmt.Fprintf(&b, "type=%q ", s.etype)
+	}
+	fmt.Fprintf(&b, "mimetype=%s size=%d state=%s", s.mimetype, s.size, s.state)
+	if s.epoch > 0 || s.offset > 0 {
+		fmt.Fprintf(&b, " epoch=%d offset=%d", s.epoch, s.offset)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (s eventSummary) verbose() string {
+	out := strings.TrimSuffix(s.String(), "}")
+	if !s.created.IsZero() {
+		out += " created=" + s.created.Format(time.RFC3339Nano)
+	}
+	if len(s.metadata) > 0 {
+		out += " metadata=[" + strings.Join(s.metadata, ",") + "]"
+	}
+	return out + "}"
+}
+
 // Convert a protocol buffer event into this event.
 func (e *Event) fromPB(wrapper *api.EventWrapper, state eventState) (err error) {
 	if e.state != initialized {
@@ -370,6 +682,7 @@ func (e *Event) fromPB(wrapper *api.EventWrapper, state eventState) (err error)
 	e.Mimetype = event.Mimetype
 	e.Type = event.Type
 	e.Created = event.Created.AsTime()
+	e.Key = wrapper.Key
 	e.state = state
 
 	return nil
@@ -379,7 +692,7 @@ func (e *Event) fromPB(wrapper *api.EventWrapper, state eventState) (err error)
 // to create mock events with the acked/nacked channels listening for a response from
 // the publisher stream.
 func NewOutgoingEvent(e *api.EventWrapper, pub <-chan *api.PublisherReply) *Event {
-	event := &Event{pub: pub}
+	event := &Event{pub: pub, sent: time.Now()}
 	event.fromPB(e, published)
 	return event
 }
@@ -0,0 +1,55 @@
+//go:build go1.21
+
+package ensign
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LogValue implements slog.LogValuer so that events are logged as a group of their
+// identifiers, type, mimetype, size and state. The event data and metadata values are
+// never logged, only the metadata keys are included in the group.
+func (e *Event) LogValue() slog.Value {
+	s := e.summarize()
+	attrs := make([]slog.Attr, 0, 10)
+	if s.id != "" {
+		attrs = append(attrs, slog.String("id", s.id))
+	}
+	if s.topic != "" {
+		attrs = append(attrs, slog.String("topic", s.topic))
+	}
+	if s.etype != "" {
+		attrs = append(attrs, slog.String("type", s.etype))
+	}
+
+	attrs = append(attrs,
+		slog.String("mimetype", s.mimetype),
+		slog.Int("size", s.size),
+		slog.String("state", s.state.String()),
+	)
+
+	if s.epoch > 0 || s.offset > 0 {
+		attrs = append(attrs, slog.Uint64("epoch", s.epoch), slog.Uint64("offset", s.offset))
+	}
+	if !s.created.IsZero() {
+		attrs = append(attrs, slog.String("created", s.created.Format(time.RFC3339Nano)))
+	}
+	if len(s.metadata) > 0 {
+		attrs = append(attrs, slog.Any("metadata", s.metadata))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements slog.LogValuer so that nack errors are logged as a group.
+func (e *NackError) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("code", e.Code.String())}
+	if len(e.ID) > 0 {
+		attrs = append(attrs, slog.String("id", fmt.Sprintf("%X", e.ID)))
+	}
+	if e.Message != "" {
+		attrs = append(attrs, slog.String("message", e.Message))
+	}
+	return slog.GroupValue(attrs...)
+}
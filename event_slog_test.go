@@ -0,0 +1,36 @@
+//go:build go1.21
+
+package ensign_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLogValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	event := NewEvent()
+	event.Metadata["secret"] = "supersecretvalue"
+	logger.Info("published", "event", event, "err", &ensign.NackError{ID: []byte{0x42}, Code: api.Nack_UNKNOWN_TYPE})
+	require.NotContains(t, buf.String(), "supersecretvalue", "metadata values should be redacted")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, map[string]interface{}{
+		"type":     "random v1.0.0",
+		"mimetype": "application/octet-stream",
+		"size":     float64(256),
+		"state":    "initialized",
+		"created":  event.Created.Format("2006-01-02T15:04:05.999999999Z07:00"),
+		"metadata": []interface{}{"length", "secret"},
+	}, record["event"])
+	require.Equal(t, map[string]interface{}{"code": "UNKNOWN_TYPE", "id": "42"}, record["err"])
+}
@@ -2,7 +2,10 @@ package ensign_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -10,7 +13,10 @@ import (
 	"github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+	"github.com/rotationalio/go-ensign/stream"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // NewEvent returns a new random event for testing purposes.
@@ -30,6 +36,66 @@ func NewEvent() *ensign.Event {
 	return event
 }
 
+func TestEventDescriptor(t *testing.T) {
+	require.True(t, NewEvent().Descriptor().IsZero(), "expected no descriptor for an unpublished event")
+
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	committed := time.Date(2023, 10, 12, 14, 21, 9, 0, time.UTC)
+	wrapper := &api.EventWrapper{
+		Id:          []byte{0x01, 0x8b, 0x2a, 0x4f, 0x7c, 0x00, 0x00, 0x00, 0x00, 0x01},
+		TopicId:     topicID[:],
+		Offset:      42,
+		Epoch:       7,
+		Region:      region.Region_LKE_US_EAST_1A,
+		Shard:       3,
+		Publisher:   &api.Publisher{PublisherId: "01GWM8AQH2TMVQR8D5BN1T8ZC4", ClientId: "orders-service", Ipaddr: "10.0.0.1"},
+		Committed:   timestamppb.New(committed),
+		Compression: &api.Compression{Algorithm: api.Compression_GZIP, Level: 9},
+		Encryption:  &api.Encryption{EncryptionAlgorithm: api.Encryption_PLAINTEXT},
+		IsDuplicate: true,
+		DuplicateId: []byte{0x01, 0x8b, 0x2a, 0x4f, 0x7c, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	wrapper.Wrap(&api.Event{Data: []byte("foo")})
+
+	event := ensign.NewIncomingEvent(wrapper, nil)
+	desc := event.Descriptor()
+	require.False(t, desc.IsZero())
+	require.Equal(t, ensign.EventDescriptor{
+		ID:          event.ID(),
+		TopicID:     topicID,
+		Offset:      42,
+		Epoch:       7,
+		Region:      "LKE_US_EAST_1A",
+		Publisher:   ensign.PublisherInfo{PublisherID: "01GWM8AQH2TMVQR8D5BN1T8ZC4", ClientID: "orders-service", IPAddr: "10.0.0.1"},
+		Shard:       3,
+		Committed:   committed,
+		Compressed:  true,
+		Compression: "GZIP",
+		Duplicate:   true,
+		DuplicateOf: "065jmkvw00000000",
+	}, desc)
+	require.Equal(t, "065jmkvw00000001", desc.ID)
+
+	// The delivery details are also available directly from the event
+	require.Equal(t, "LKE_US_EAST_1A", event.Region())
+	require.Equal(t, "orders-service", event.Publisher().ClientID)
+	require.Equal(t, "GZIP", event.Compression())
+	require.Empty(t, event.Encryption(), "expected plaintext events to not be encrypted")
+
+	wrapper.Encryption.EncryptionAlgorithm = api.Encryption_AES256_GCM
+	require.Equal(t, "AES256_GCM", event.Encryption())
+
+	unpublished := NewEvent()
+	require.Empty(t, unpublished.Region())
+	require.Zero(t, unpublished.Publisher())
+	require.Empty(t, unpublished.Compression())
+	require.Empty(t, unpublished.Encryption())
+
+	// The descriptor is a copy that is not affected by changes to the event info
+	wrapper.Offset = 43
+	require.Equal(t, uint64(42), desc.Offset)
+}
+
 func TestEventIDParsing(t *testing.T) {
 	testCases := []struct {
 		input    []byte
@@ -95,3 +161,230 @@ func TestTopicIDParsing(t *testing.T) {
 
 	}
 }
+
+func TestEventLatency(t *testing.T) {
+	// An event that has not been committed should have no latency
+	event := NewEvent()
+	require.Zero(t, event.Latency(), "expected zero latency for an uncommitted event")
+	require.Zero(t, event.RoundTrip(), "expected zero round trip for an unpublished event")
+
+	// Create an outgoing event that has a created timestamp
+	created := time.Now().Add(-1 * time.Second)
+	wrapper := &api.EventWrapper{}
+	wrapper.Wrap(&api.Event{Data: []byte("foo"), Created: timestamppb.New(created)})
+
+	replies := make(chan *api.PublisherReply, 1)
+	event = ensign.NewOutgoingEvent(wrapper, replies)
+	require.Zero(t, event.Latency(), "expected zero latency before the ack is received")
+	require.Zero(t, event.RoundTrip(), "expected zero round trip before the ack is received")
+
+	// Send an ack with a committed timestamp from the server
+	committed := created.Add(250 * time.Millisecond)
+	replies <- &api.PublisherReply{Embed: &api.PublisherReply_Ack{Ack: &api.Ack{Id: []byte{0x42}, Committed: timestamppb.New(committed)}}}
+
+	acked, err := event.Acked()
+	require.NoError(t, err, "expected no error on ack")
+	require.True(t, acked, "expected event to be acked")
+	require.Equal(t, 250*time.Millisecond, event.Latency(), "expected latency computed from created and committed timestamps")
+	require.Greater(t, event.RoundTrip(), time.Duration(0), "expected round trip to be measured")
+}
+
+func TestEventWait(t *testing.T) {
+	// Cannot wait on an event that hasn't been published
+	_, err := NewEvent().Wait()
+	require.ErrorIs(t, err, ensign.ErrNotPublished)
+
+	wrapper := &api.EventWrapper{}
+	wrapper.Wrap(&api.Event{Data: []byte("foo")})
+
+	// Wait should return the context error if no reply is received before the deadline
+	replies := make(chan *api.PublisherReply, 1)
+	event := ensign.NewOutgoingEvent(wrapper, replies)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	event.SetContext(ctx)
+
+	acked, err := event.Wait()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, acked)
+
+	// Wait should block until the ack is received from the server
+	event.SetContext(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		replies <- &api.PublisherReply{Embed: &api.PublisherReply_Ack{Ack: &api.Ack{Id: []byte{0x42}}}}
+		close(replies)
+	}()
+
+	acked, err = event.Wait()
+	require.NoError(t, err)
+	require.True(t, acked)
+
+	// Subsequent calls return the state of the event
+	acked, err = event.Wait()
+	require.NoError(t, err)
+	require.True(t, acked)
+
+	// Wait should return the nack error
+	nacks := make(chan *api.PublisherReply, 1)
+	nacks <- &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: []byte{0x42}, Code: api.Nack_TOPIC_UNKNOWN}}}
+	event = ensign.NewOutgoingEvent(wrapper, nacks)
+
+	acked, err = event.Wait()
+	require.Error(t, err)
+	require.False(t, acked)
+}
+
+func TestEventString(t *testing.T) {
+	event := NewEvent()
+	event.Metadata["secret"] = "supersecretvalue"
+	event.Created = time.Date(2023, 10, 12, 8, 31, 2, 0, time.UTC)
+
+	expected := `Event{type="random v1.0.0" mimetype=application/octet-stream size=256 state=initialized}`
+	require.Equal(t, expected, event.String())
+	require.Equal(t, expected, fmt.Sprintf("%v", event))
+	require.Equal(t, expected, fmt.Sprintf("%s", event))
+
+	verbose := fmt.Sprintf("%+v", event)
+	require.Equal(t, `Event{type="random v1.0.0" mimetype=application/octet-stream size=256 state=initialized created=2023-10-12T08:31:02Z metadata=[length,secret]}`, verbose)
+	require.NotContains(t, verbose, "supersecretvalue", "metadata values should be redacted")
+
+	// An event received from a subscription should include its identifiers and offsets
+	wrapper := &api.EventWrapper{
+		Id:      []byte{0x01, 0x83, 0x42, 0x5F, 0x66, 0x6F, 0x00, 0x6F, 0xEB, 0x6B},
+		TopicId: ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A").Bytes(),
+		Epoch:   2,
+		Offset:  42,
+	}
+	wrapper.Wrap(&api.Event{Data: []byte(`{"hello": "world"}`), Mimetype: mimetype.ApplicationJSON})
+
+	event = ensign.NewIncomingEvent(wrapper, nil)
+	expected = "Event{id=061m4qv6dw06ztvb topic=01HCG64Y1SMFQBW7A42SRV207A mimetype=application/json size=18 state=subscription epoch=2 offset=42}"
+	require.Equal(t, expected, event.String())
+}
+
+type nackRecorder struct {
+	nacks []*api.Nack
+}
+
+func (r *nackRecorder) Ack(*api.Ack) error { return nil }
+
+func (r *nackRecorder) Nack(in *api.Nack) error {
+	r.nacks = append(r.nacks, in)
+	return nil
+}
+
+func TestNackWithBackoff(t *testing.T) {
+	wrapper := &api.EventWrapper{Id: ulid.Make().Bytes()}
+	wrapper.Wrap(&api.Event{Data: []byte("foo")})
+
+	acks := &nackRecorder{}
+	event := ensign.NewIncomingEvent(wrapper, acks)
+
+	nacked, err := event.NackWithBackoff(api.Nack_UNPROCESSED, 10*time.Second)
+	require.NoError(t, err)
+	require.True(t, nacked)
+
+	require.Len(t, acks.nacks, 1)
+	require.Equal(t, wrapper.Id, acks.nacks[0].Id)
+	require.Equal(t, api.Nack_UNPROCESSED, acks.nacks[0].Code)
+
+	// The hint should be parseable from the nack error on the publish side
+	delay, ok := ensign.RetryAfter(&ensign.NackError{Code: acks.nacks[0].Code, Message: acks.nacks[0].Error})
+	require.True(t, ok)
+	require.Equal(t, 10*time.Second, delay)
+
+	delay, ok = ensign.RetryAfter(fmt.Errorf("wrapped: %w", &stream.NackError{Nack: acks.nacks[0]}))
+	require.True(t, ok)
+	require.Equal(t, 10*time.Second, delay)
+
+	_, ok = ensign.RetryAfter(&ensign.NackError{Code: api.Nack_UNPROCESSED})
+	require.False(t, ok)
+
+	_, ok = ensign.RetryAfter(errors.New("not a nack"))
+	require.False(t, ok)
+
+	nerr := &ensign.NackError{Code: api.Nack_UNPROCESSED}
+	info := nerr.Info()
+	require.Equal(t, api.Nack_UNPROCESSED, info.Code)
+	require.Equal(t, api.Nack_UNPROCESSED.Description(), info.Description)
+	require.Equal(t, api.NackClientFault, info.Category)
+	require.True(t, info.Retryable)
+	require.NotEmpty(t, info.Action)
+
+	// Cannot nack an event that has already been nacked
+	nacked, err = event.NackWithBackoff(api.Nack_UNPROCESSED, time.Second)
+	require.NoError(t, err)
+	require.True(t, nacked)
+	require.Len(t, acks.nacks, 1)
+}
+
+func TestEventClone(t *testing.T) {
+	event := NewEvent()
+	event.Key = []byte("order-42")
+
+	clone := event.Clone()
+	require.Equal(t, event.Data, clone.Data, "expected the payload to be copied")
+	require.Equal(t, event.Metadata, clone.Metadata)
+	require.Equal(t, event.Mimetype, clone.Mimetype)
+	require.Equal(t, event.Key, clone.Key)
+	require.True(t, event.Type.Equals(clone.Type), "expected the type to be copied")
+	require.True(t, clone.Created.IsZero(), "expected the created timestamp to be reset")
+
+	// Modifying the clone should not modify the original event
+	original := append([]byte(nil), event.Data...)
+	clone.Data[0] ^= 0xff
+	clone.Key[0] = 'O'
+	clone.Metadata["length"] = "512"
+	clone.Type.Name = "modified"
+	clone.Type.MajorVersion = 2
+
+	require.Equal(t, original, event.Data)
+	require.Equal(t, []byte("order-42"), event.Key)
+	require.Equal(t, "256", event.Metadata["length"])
+	require.Equal(t, "random", event.Type.Name)
+	require.Equal(t, uint32(1), event.Type.MajorVersion)
+
+	// Events without a type, key, or payload can be cloned
+	clone = (&ensign.Event{Mimetype: mimetype.TextPlain}).Clone()
+	require.Nil(t, clone.Data)
+	require.Nil(t, clone.Key)
+	require.Nil(t, clone.Type)
+	require.NotNil(t, clone.Metadata)
+}
+
+func TestEventCloneWithData(t *testing.T) {
+	event := NewEvent()
+	data := []byte("hello world")
+
+	clone := event.CloneWithData(data)
+	require.Equal(t, data, clone.Data)
+	require.Equal(t, event.Metadata, clone.Metadata)
+	require.True(t, event.Type.Equals(clone.Type))
+	require.Len(t, event.Data, 256, "expected the original payload to be unchanged")
+
+	// The data is copied into the clone
+	data[0] = 'H'
+	require.Equal(t, []byte("hello world"), clone.Data)
+}
+
+func TestEventCloneN(t *testing.T) {
+	require.Nil(t, NewEvent().CloneN(0))
+	require.Nil(t, NewEvent().CloneN(-1))
+
+	event := NewEvent()
+	clones := event.CloneN(3)
+	require.Len(t, clones, 3)
+
+	for _, clone := range clones {
+		require.Equal(t, event.Data, clone.Data)
+		require.True(t, event.Type.Equals(clone.Type))
+	}
+
+	// Clones do not share data with each other
+	clones[0].Data[0] ^= 0xff
+	clones[0].Type.Name = "modified"
+	require.NotEqual(t, clones[0].Data, clones[1].Data)
+	require.Equal(t, "random", clones[1].Type.Name)
+}
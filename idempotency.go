@@ -0,0 +1,176 @@
+package ensign
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rotationalio/go-ensign/stream"
+)
+
+// IdempotencyKey is the metadata key of the unique key that is added to events published
+// by a client created WithPublishResend, so that events republished after the publish
+// stream reconnects can be deduplicated. Events that already have the key are published
+// with their own key, e.g. to deduplicate events that are retried by the application.
+const IdempotencyKey = stream.IdempotencyKey
+
+// IdempotencyKey returns the idempotency key of an event received from a subscription
+// or an empty string if the event was not published with one. The key is not added to
+// the metadata of the event that is published since the publisher adds it to a copy.
+func (e *Event) IdempotencyKey() string {
+	return e.Metadata.Get(IdempotencyKey)
+}
+
+// ProcessedStore records the keys of events that have been processed by a consumer so
+// that events that are redelivered (e.g. because their ack was lost or because they
+// were republished after the publish stream reconnected) are not processed twice (see
+// ExactlyOnce). Stores that are shared by consumers or that persist across restarts,
+// e.g. backed by Redis (EXISTS and SET) or an embedded key/value database, can be used
+// by implementing this interface; NewProcessedCache creates an in-memory store.
+type ProcessedStore interface {
+	Processed(ctx context.Context, key string) (bool, error)
+	MarkProcessed(ctx context.Context, key string) error
+}
+
+// ExactlyOnce returns middleware that skips events that have already been processed
+// according to the store, acking the event without calling the handler, and records
+// events as processed when the handler returns nil. Events are identified by their
+// idempotency key (see WithPublishResend) or by their event ID if they do not have one.
+// Concurrent deliveries of the same event to the workers of a Run are processed one at
+// a time so that only one of them calls the handler. Processing is exactly-once as
+// long as the handler's side effects are committed before the event is marked as
+// processed and the consumer does not fail in between; otherwise the event is
+// redelivered and processed again, i.e. at-least-once.
+func ExactlyOnce(store ProcessedStore) Middleware {
+	locks := &keyLocks{locks: make(map[string]*keyLock)}
+	return func(next EventHandler) EventHandler {
+		return func(event *Event) (err error) {
+			key := event.IdempotencyKey()
+			if key == "" {
+				key = event.ID()
+			}
+
+			// Events that cannot be identified cannot be deduplicated.
+			if key == "" {
+				return next(event)
+			}
+
+			unlock := locks.lock(key)
+			defer unlock()
+
+			ctx := event.Context()
+			var processed bool
+			if processed, err = store.Processed(ctx, key); err != nil {
+				return fmt.Errorf("could not check if event was processed: %w", err)
+			}
+
+			if processed {
+				_, err = event.Ack()
+				return err
+			}
+
+			if err = next(event); err != nil {
+				return err
+			}
+
+			if err = store.MarkProcessed(ctx, key); err != nil {
+				return fmt.Errorf("could not mark event as processed: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// DefaultProcessedCacheSize is the number of keys held by a ProcessedCache if the size
+// specified is not positive.
+const DefaultProcessedCacheSize = 10000
+
+// ProcessedCache is an in-memory ProcessedStore that holds the keys of the most
+// recently processed events, evicting the least recently processed keys once the cache
+// is full. Redeliveries of events that were evicted or that were processed before the
+// process restarted are not detected.
+type ProcessedCache struct {
+	sync.Mutex
+	size  int
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+var _ ProcessedStore = &ProcessedCache{}
+
+// NewProcessedCache creates a processed store that holds up to size keys.
+func NewProcessedCache(size int) *ProcessedCache {
+	if size <= 0 {
+		size = DefaultProcessedCacheSize
+	}
+	return &ProcessedCache{size: size, order: list.New(), keys: make(map[string]*list.Element)}
+}
+
+// Processed returns true if the key is in the cache.
+func (c *ProcessedCache) Processed(_ context.Context, key string) (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	_, ok := c.keys[key]
+	return ok, nil
+}
+
+// MarkProcessed adds the key to the cache, evicting the least recently processed key
+// if the cache is full.
+func (c *ProcessedCache) MarkProcessed(_ context.Context, key string) error {
+	c.Lock()
+	defer c.Unlock()
+	if elem, ok := c.keys[key]; ok {
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.keys[key] = c.order.PushFront(key)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.keys, oldest.Value.(string))
+	}
+	return nil
+}
+
+// Len returns the number of keys in the cache.
+func (c *ProcessedCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.order.Len()
+}
+
+// keyLocks serializes the processing of events with the same key.
+type keyLocks struct {
+	sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Locks the key, returning a function that unlocks it; the lock is removed once it is
+// no longer referenced.
+func (k *keyLocks) lock(key string) func() {
+	k.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		k.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.Unlock()
+	}
+}
@@ -0,0 +1,119 @@
+package ensign_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExactlyOnce(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	acks := make(chan *api.Ack, 8)
+	nacks := make(chan *api.Nack, 8)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithAckMode(sdk.AckOnHandlerSuccess))
+	require.NoError(t, err, "could not subscribe")
+
+	// The handler fails the first time an event with the fail metadata is processed
+	var (
+		mu      sync.Mutex
+		handled = make(map[string]int)
+	)
+	fail := errors.New("downstream unavailable")
+	process := func(event *sdk.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		key := event.Metadata.Get("fail")
+		handled[key]++
+		if key == "true" && handled[key] == 1 {
+			return fail
+		}
+		return nil
+	}
+
+	store := sdk.NewProcessedCache(8)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Run(ctx, process, sdk.WithWorkers(4), sdk.WithMiddleware(sdk.ExactlyOnce(store)))
+	}()
+
+	wait := func() {
+		select {
+		case <-acks:
+		case <-nacks:
+		case <-time.After(time.Second):
+			t.Fatal("expected event to be acked or nacked")
+		}
+	}
+
+	// Redeliveries of a processed event are acked without being processed again
+	alpha := mock.NewEventWrapper()
+	handler.Send <- alpha
+	wait()
+	handler.Send <- proto.Clone(alpha).(*api.EventWrapper)
+	wait()
+
+	// Events that fail are processed again when they are redelivered
+	bravo := mock.NewEventWrapper()
+	event, err := bravo.Unwrap()
+	require.NoError(t, err)
+	event.Metadata = map[string]string{"fail": "true"}
+	require.NoError(t, bravo.Wrap(event))
+
+	for i := 0; i < 3; i++ {
+		handler.Send <- proto.Clone(bravo).(*api.EventWrapper)
+		wait()
+	}
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.NoError(t, sub.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, map[string]int{"": 1, "true": 2}, handled)
+	require.Equal(t, 2, store.Len())
+}
+
+func TestProcessedCache(t *testing.T) {
+	ctx := context.Background()
+	cache := sdk.NewProcessedCache(2)
+
+	for _, key := range []string{"alpha", "bravo", "alpha", "charlie"} {
+		require.NoError(t, cache.MarkProcessed(ctx, key))
+	}
+	require.Equal(t, 2, cache.Len())
+
+	// The least recently processed key is evicted
+	for key, expected := range map[string]bool{"alpha": true, "bravo": false, "charlie": true} {
+		processed, err := cache.Processed(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, expected, processed, "unexpected processed state for %q", key)
+	}
+}
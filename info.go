@@ -30,8 +30,7 @@ func (c *Client) Info(ctx context.Context, topicIDs ...string) (info *api.Projec
 	}
 
 	if info, err = c.api.Info(ctx, req, c.copts...); err != nil {
-		// TODO: do a better job of categorizing the error
-		return nil, err
+		return nil, translateError(err)
 	}
 	return info, nil
 }
@@ -43,7 +42,7 @@ func (c *Client) TopicInfo(ctx context.Context, topicID ulid.ULID) (info *api.To
 
 	var project *api.ProjectInfo
 	if project, err = c.api.Info(ctx, req, c.copts...); err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	switch len(project.Topics) {
@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+// EnvStaging is the environment variable that enables integration tests when it is set
+// to a true value (e.g. 1 or true).
+const EnvStaging = "ENSIGN_TEST_STAGING"
+
+// Enabled returns true if the environment variable is set to a true value. A .env file
+// in the current working directory is loaded before checking the environment.
+func Enabled(key string) bool {
+	LoadDotEnv(".env")
+	return ParseBool(os.Getenv(key))
+}
+
+// SkipUnlessEnabled skips the test unless integration tests have been enabled by the
+// $ENSIGN_TEST_STAGING environment variable.
+func SkipUnlessEnabled(t testing.TB) {
+	t.Helper()
+	if !Enabled(EnvStaging) {
+		t.Skipf("set the $%s environment variable to execute this test", EnvStaging)
+	}
+}
+
+// LoadDotEnv is a lightweight mechanism to load a .env file without adding godotenv as
+// a dependency. This method is not as robust as godotenv and some valid .env files may
+// not load.
+func LoadDotEnv(path string) (err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewScanner(f)
+	for reader.Scan() {
+		line := strings.TrimSpace(reader.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "=")
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		if err = os.Setenv(key, val); err != nil {
+			return err
+		}
+	}
+	return reader.Err()
+}
+
+// ParseBool returns true if the string is a true value such as 1, t, y, yes, or true.
+func ParseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "y", "yes", "true":
+		return true
+	default:
+		return false
+	}
+}
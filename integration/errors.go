@@ -0,0 +1,7 @@
+package integration
+
+import "errors"
+
+var (
+	ErrQuotaExceeded = errors.New("integration test quota exceeded")
+)
@@ -0,0 +1,188 @@
+/*
+Package integration provides a test harness for running integration tests against live
+Ensign environments (e.g. staging) that leaves the environment clean. The harness
+creates topics in a unique namespace for each test and destroys them when the test
+completes, keeps track of the number of topics and events used by the test so that
+tests stay within a quota, and provides helpers for enabling integration tests from
+the environment. The harness is used by the SDK's staging suite but is intended to be
+used by any Ensign user's integration tests as well.
+*/
+package integration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultPrefix is the namespace prefix used for topics created by the harness.
+	DefaultPrefix = "testing"
+
+	// DefaultTimeout is the timeout of topic management requests made by the harness.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Client is the subset of the Ensign client used by the harness.
+type Client interface {
+	CreateTopic(context.Context, string) (string, error)
+	DestroyTopic(context.Context, string) (api.TopicState, error)
+	Publish(string, ...*ensign.Event) error
+}
+
+// Harness creates namespaced topics for an integration test and destroys them when the
+// test completes. The harness is safe for concurrent use by multiple go routines.
+type Harness struct {
+	t         testing.TB
+	client    Client
+	opts      Options
+	namespace string
+	mu        sync.Mutex
+	topics    map[string]string
+	usage     Usage
+}
+
+// Usage is the number of resources that have been used by the test harness.
+type Usage struct {
+	Topics uint64
+	Events uint64
+	Bytes  uint64
+}
+
+// New creates a test harness that uses the client to manage topics for the test. The
+// topics created by the harness are destroyed using t.Cleanup when the test completes.
+func New(t testing.TB, client Client, opts ...Option) *Harness {
+	t.Helper()
+	h := &Harness{
+		t:      t,
+		client: client,
+		opts:   newOptions(opts...),
+		topics: make(map[string]string),
+	}
+
+	h.namespace = fmt.Sprintf("%s.%s", h.opts.Prefix, strings.ToLower(ulid.Make().String()))
+	t.Cleanup(h.Teardown)
+	return h
+}
+
+// Namespace returns the unique namespace that topics are created in by the harness.
+func (h *Harness) Namespace() string {
+	return h.namespace
+}
+
+// TopicName returns the fully qualified name of the topic in the namespace.
+func (h *Harness) TopicName(name string) string {
+	return h.namespace + "." + name
+}
+
+// CreateTopic creates a topic in the namespace of the harness, returning the topic ID.
+// The topic is destroyed when the test completes. An error is returned if the topic
+// could not be created or if creating the topic would exceed the topic quota.
+func (h *Harness) CreateTopic(ctx context.Context, name string) (topicID string, err error) {
+	h.mu.Lock()
+	if h.opts.MaxTopics > 0 && h.usage.Topics >= h.opts.MaxTopics {
+		h.mu.Unlock()
+		return "", fmt.Errorf("%w: cannot create more than %d topics", ErrQuotaExceeded, h.opts.MaxTopics)
+	}
+	h.usage.Topics++
+	h.mu.Unlock()
+
+	topic := h.TopicName(name)
+	if topicID, err = h.client.CreateTopic(ctx, topic); err != nil {
+		h.mu.Lock()
+		h.usage.Topics--
+		h.mu.Unlock()
+		return "", err
+	}
+
+	h.mu.Lock()
+	h.topics[topic] = topicID
+	h.mu.Unlock()
+	return topicID, nil
+}
+
+// Topic creates a topic in the namespace of the harness and returns the topic ID,
+// failing the test if the topic cannot be created.
+func (h *Harness) Topic(name string) string {
+	h.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	topicID, err := h.CreateTopic(ctx, name)
+	if err != nil {
+		h.t.Fatalf("could not create topic %q: %s", name, err)
+	}
+	return topicID
+}
+
+// Topics returns a map of the fully qualified names to IDs of the topics created by the
+// harness that have not been destroyed yet.
+func (h *Harness) Topics() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topics := make(map[string]string, len(h.topics))
+	for name, topicID := range h.topics {
+		topics[name] = topicID
+	}
+	return topics
+}
+
+// Publish events to the topic using the client, accounting for the number of events
+// and bytes published. An error is returned without publishing any events if the
+// events would exceed the event or byte quota of the harness.
+func (h *Harness) Publish(topic string, events ...*ensign.Event) error {
+	var nbytes uint64
+	for _, event := range events {
+		nbytes += uint64(proto.Size(event.Proto()))
+	}
+
+	h.mu.Lock()
+	if h.opts.MaxEvents > 0 && h.usage.Events+uint64(len(events)) > h.opts.MaxEvents {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: cannot publish more than %d events", ErrQuotaExceeded, h.opts.MaxEvents)
+	}
+
+	if h.opts.MaxBytes > 0 && h.usage.Bytes+nbytes > h.opts.MaxBytes {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: cannot publish more than %d bytes", ErrQuotaExceeded, h.opts.MaxBytes)
+	}
+
+	h.usage.Events += uint64(len(events))
+	h.usage.Bytes += nbytes
+	h.mu.Unlock()
+
+	return h.client.Publish(topic, events...)
+}
+
+// Usage returns the resources used by the harness so far.
+func (h *Harness) Usage() Usage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.usage
+}
+
+// Teardown destroys all of the topics created by the harness, reporting an error on
+// the test for any topic that could not be destroyed. Teardown is automatically called
+// when the test completes but can be called early to cleanup the environment.
+func (h *Harness) Teardown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, topicID := range h.topics {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		if _, err := h.client.DestroyTopic(ctx, topicID); err != nil {
+			h.t.Errorf("could not destroy topic %q (%s): %s", name, topicID, err)
+		} else {
+			delete(h.topics, name)
+		}
+		cancel()
+	}
+}
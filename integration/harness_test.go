@@ -0,0 +1,90 @@
+package integration_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	. "github.com/rotationalio/go-ensign/integration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarness(t *testing.T) {
+	client := &mockClient{topics: make(map[string]string)}
+
+	var harness *Harness
+	t.Run("Topics", func(t *testing.T) {
+		harness = New(t, client, WithPrefix("sdk"), WithQuota(2, 3, 0))
+		require.True(t, strings.HasPrefix(harness.Namespace(), "sdk."), "expected namespace to use prefix")
+
+		topicA := harness.Topic("topica")
+		topicB, err := harness.CreateTopic(context.Background(), "topicb")
+		require.NoError(t, err, "could not create second topic")
+		require.NotEqual(t, topicA, topicB)
+
+		require.Equal(t, map[string]string{
+			harness.TopicName("topica"): topicA,
+			harness.TopicName("topicb"): topicB,
+		}, harness.Topics())
+
+		// Creating more topics than the quota should fail
+		_, err = harness.CreateTopic(context.Background(), "topicc")
+		require.ErrorIs(t, err, ErrQuotaExceeded)
+
+		// Publishing more events than the quota should fail
+		require.NoError(t, harness.Publish(topicA, &ensign.Event{Data: []byte("foo")}, &ensign.Event{Data: []byte("bar")}))
+		require.ErrorIs(t, harness.Publish(topicA, &ensign.Event{}, &ensign.Event{}), ErrQuotaExceeded)
+
+		usage := harness.Usage()
+		require.Equal(t, uint64(2), usage.Topics)
+		require.Equal(t, uint64(2), usage.Events)
+		require.Greater(t, usage.Bytes, uint64(0))
+		require.Equal(t, 2, client.published)
+	})
+
+	// When the test completes all of the topics should be destroyed
+	require.Empty(t, client.topics, "expected all topics to be destroyed")
+	require.Empty(t, harness.Topics())
+}
+
+func TestParseBool(t *testing.T) {
+	for _, s := range []string{"1", "t", "y", "yes", "true", " TRUE "} {
+		require.True(t, ParseBool(s), "expected %q to be true", s)
+	}
+
+	for _, s := range []string{"", "0", "f", "n", "no", "false", "foo"} {
+		require.False(t, ParseBool(s), "expected %q to be false", s)
+	}
+}
+
+type mockClient struct {
+	sync.Mutex
+	topics    map[string]string
+	published int
+}
+
+func (c *mockClient) CreateTopic(_ context.Context, name string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	topicID := ulid.Make().String()
+	c.topics[topicID] = name
+	return topicID, nil
+}
+
+func (c *mockClient) DestroyTopic(_ context.Context, topicID string) (api.TopicState, error) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.topics, topicID)
+	return api.TopicState_DELETING, nil
+}
+
+func (c *mockClient) Publish(_ string, events ...*ensign.Event) error {
+	c.Lock()
+	defer c.Unlock()
+	c.published += len(events)
+	return nil
+}
@@ -0,0 +1,46 @@
+package integration
+
+// Option configures the test harness.
+type Option func(o *Options)
+
+// Options for the test harness; by default there are no quotas.
+type Options struct {
+	// The prefix of the namespace that topics are created in; by default "testing".
+	Prefix string
+
+	// The maximum number of topics that can be created by the harness.
+	MaxTopics uint64
+
+	// The maximum number of events and bytes that can be published by the harness.
+	MaxEvents uint64
+	MaxBytes  uint64
+}
+
+// WithPrefix specifies the prefix of the namespace that topics are created in.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithQuota limits the number of topics, events and bytes that the harness can use;
+// specify zero to not limit the resource.
+func WithQuota(topics, events, bytes uint64) Option {
+	return func(o *Options) {
+		o.MaxTopics = topics
+		o.MaxEvents = events
+		o.MaxBytes = bytes
+	}
+}
+
+func newOptions(opts ...Option) Options {
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Prefix == "" {
+		options.Prefix = DefaultPrefix
+	}
+	return options
+}
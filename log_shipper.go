@@ -0,0 +1,255 @@
+package ensign
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// LogShipper publishes structured log records as events to a topic so that Ensign can
+// be used as a log transport directly from a logger. The shipper is an io.Writer that
+// expects each call to Write to be a single JSON log record, which is how zerolog and
+// zap's JSON encoder write to their output, e.g. zerolog.New(shipper) or
+// zapcore.AddSync(shipper). On Go 1.21+ the SlogHandler method returns a slog.Handler.
+//
+// Records are buffered and published asynchronously in batches by a background go
+// routine so that logging does not block on the publish stream. If the buffer is full,
+// records are dropped according to the drop policy. Publish errors are passed to the
+// error handler of the shipper rather than to the client's logger, since the logger may
+// itself be shipping records. Close must be called to publish the buffered records.
+type LogShipper struct {
+	client  *Client
+	topic   string
+	opts    LogShipperOptions
+	records chan []byte
+	dropped uint64
+
+	mu     sync.RWMutex
+	closed bool
+	stop   chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// DropPolicy specifies how a LogShipper handles records when its buffer is full.
+type DropPolicy uint8
+
+const (
+	// DropNewest discards the record that is being written (default).
+	DropNewest DropPolicy = iota
+
+	// DropOldest discards the oldest buffered record to make room for the new record.
+	DropOldest
+
+	// BlockOnFull blocks the logger until there is room in the buffer; this ensures that
+	// no records are dropped but may stall the application if Ensign is unavailable.
+	BlockOnFull
+)
+
+// LogShipperOption configures how a LogShipper buffers and publishes records.
+type LogShipperOption func(o *LogShipperOptions)
+
+// LogShipperOptions configure the buffering, batching, and drop policy of a LogShipper.
+type LogShipperOptions struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+	DropPolicy    DropPolicy
+	OnError       func(err error)
+}
+
+// WithLogBufferSize sets the maximum number of records that are buffered before records
+// are dropped according to the drop policy; by default 1024 records are buffered.
+func WithLogBufferSize(size int) LogShipperOption {
+	return func(o *LogShipperOptions) {
+		o.BufferSize = size
+	}
+}
+
+// WithLogBatching publishes records once size records have been buffered or when the
+// interval has elapsed since the last batch was published, whichever happens first. By
+// default up to 128 records are published every second.
+func WithLogBatching(size int, interval time.Duration) LogShipperOption {
+	return func(o *LogShipperOptions) {
+		o.BatchSize = size
+		o.FlushInterval = interval
+	}
+}
+
+// WithLogDropPolicy specifies how records are handled when the buffer is full.
+func WithLogDropPolicy(policy DropPolicy) LogShipperOption {
+	return func(o *LogShipperOptions) {
+		o.DropPolicy = policy
+	}
+}
+
+// WithLogErrorHandler is called with the error when a batch of records cannot be
+// published; by default publish errors are discarded.
+func WithLogErrorHandler(handler func(err error)) LogShipperOption {
+	return func(o *LogShipperOptions) {
+		o.OnError = handler
+	}
+}
+
+// NewLogShipper creates a LogShipper that publishes log records to the specified topic
+// name or ID and starts the go routine that publishes buffered records in batches.
+func NewLogShipper(client *Client, topic string, opts ...LogShipperOption) *LogShipper {
+	options := LogShipperOptions{
+		BufferSize:    1024,
+		BatchSize:     128,
+		FlushInterval: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.BufferSize < 1 {
+		options.BufferSize = 1
+	}
+
+	if options.BatchSize < 1 {
+		options.BatchSize = 1
+	}
+
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+
+	if options.OnError == nil {
+		options.OnError = func(error) {}
+	}
+
+	shipper := &LogShipper{
+		client:  client,
+		topic:   topic,
+		opts:    options,
+		records: make(chan []byte, options.BufferSize),
+		stop:    make(chan struct{}),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go shipper.run()
+	return shipper
+}
+
+// Write buffers the log record to be published; the record is copied so the logger can
+// reuse its buffer. Write never returns an error unless the shipper is closed, records
+// that are dropped are counted by Dropped.
+func (s *LogShipper) Write(p []byte) (n int, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	record := bytes.TrimRight(p, "\n")
+	record = append(make([]byte, 0, len(record)), record...)
+
+	switch s.opts.DropPolicy {
+	case BlockOnFull:
+		select {
+		case s.records <- record:
+		case <-s.stop:
+			return 0, io.ErrClosedPipe
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.records <- record:
+				return len(p), nil
+			default:
+			}
+
+			select {
+			case <-s.records:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.records <- record:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of records that were dropped because the buffer was full.
+func (s *LogShipper) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close stops accepting records and blocks until the buffered records are published.
+// The client is not closed and the events are not waited on to be acked.
+func (s *LogShipper) Close() error {
+	s.once.Do(func() {
+		// Unblock writers that are waiting on a full buffer before acquiring the lock.
+		close(s.stop)
+
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+
+		close(s.quit)
+	})
+
+	<-s.done
+	return nil
+}
+
+// Publish buffered records in batches until the shipper is closed, then publish any
+// remaining records.
+func (s *LogShipper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Event, 0, s.opts.BatchSize)
+	add := func(record []byte) {
+		batch = append(batch, &Event{Data: record, Mimetype: mimetype.ApplicationJSON, Created: time.Now()})
+		if len(batch) >= s.opts.BatchSize {
+			batch = s.publish(batch)
+		}
+	}
+
+	for {
+		select {
+		case record := <-s.records:
+			add(record)
+		case <-ticker.C:
+			batch = s.publish(batch)
+		case <-s.quit:
+			for {
+				select {
+				case record := <-s.records:
+					add(record)
+				default:
+					s.publish(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *LogShipper) publish(batch []*Event) []*Event {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := s.client.Publish(s.topic, batch...); err != nil {
+		s.opts.OnError(err)
+	}
+	return make([]*Event, 0, s.opts.BatchSize)
+}
@@ -0,0 +1,11 @@
+//go:build go1.21
+
+package ensign
+
+import "log/slog"
+
+// SlogHandler returns a structured log handler that writes JSON records to the shipper
+// so that a slog.Logger can ship its records to Ensign, e.g. slog.New(shipper.SlogHandler(nil)).
+func (s *LogShipper) SlogHandler(opts *slog.HandlerOptions) slog.Handler {
+	return slog.NewJSONHandler(s, opts)
+}
@@ -0,0 +1,41 @@
+//go:build go1.21
+
+package ensign_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdk "github.com/rotationalio/go-ensign"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogShipperSlog(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	shipper := sdk.NewLogShipper(client, "01GWM89049D49FHJH81BT8795H")
+	logger := slog.New(shipper.SlogHandler(nil))
+	logger.Info("order created", "order_id", 42)
+	require.NoError(t, shipper.Close())
+	require.NoError(t, client.Flush(context.Background()))
+
+	published := recorder.Published()
+	require.Len(t, published, 1)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(published[0].Event.Data, &record))
+	require.Equal(t, "INFO", record["level"])
+	require.Equal(t, "order created", record["msg"])
+	require.Equal(t, float64(42), record["order_id"])
+}
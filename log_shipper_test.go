@@ -0,0 +1,131 @@
+package ensign_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogShipper(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	shipper := sdk.NewLogShipper(client, "01GWM89049D49FHJH81BT8795H", sdk.WithLogBatching(2, 10*time.Millisecond))
+
+	// Records are written one per line as by zerolog and zap
+	for i := 0; i < 3; i++ {
+		n, err := fmt.Fprintf(shipper, "{\"level\":\"info\",\"n\":%d}\n", i)
+		require.NoError(t, err)
+		require.Equal(t, 23, n)
+	}
+
+	// A full batch is published immediately and a partial batch after the interval
+	require.Eventually(t, func() bool { return len(recorder.Published()) == 3 }, time.Second, 5*time.Millisecond)
+
+	for i, pub := range recorder.Published() {
+		require.Equal(t, mimetype.ApplicationJSON, pub.Event.Mimetype)
+
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(pub.Event.Data, &record))
+		require.Equal(t, float64(i), record["n"], "expected records to be published in order")
+	}
+
+	require.NoError(t, shipper.Close())
+	require.Zero(t, shipper.Dropped())
+
+	_, err = shipper.Write([]byte(`{"level":"info"}`))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestLogShipperDropPolicy(t *testing.T) {
+	testCases := []struct {
+		policy   sdk.DropPolicy
+		expected []string
+		dropped  uint64
+	}{
+		{sdk.DropNewest, []string{"0", "1", "2"}, 1},
+		{sdk.DropOldest, []string{"0", "2", "3"}, 1},
+		{sdk.BlockOnFull, []string{"0", "1", "2", "3"}, 0},
+	}
+
+	for _, tc := range testCases {
+		emock := mock.New(nil)
+		defer emock.Shutdown()
+
+		// Stall the publish stream until the buffer has been filled
+		opening, release := make(chan struct{}), make(chan struct{})
+		recorder := mock.NewPublishRecorder(nil)
+		init := recorder.OnInitialize
+		recorder.OnInitialize = func(in *api.OpenStream) (*api.StreamReady, error) {
+			close(opening)
+			<-release
+			return init(in)
+		}
+		emock.OnPublish = recorder.OnPublish
+
+		client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+		require.NoError(t, err, "could not create client")
+		defer client.Close()
+
+		shipper := sdk.NewLogShipper(client, "01GWM89049D49FHJH81BT8795H", sdk.WithLogBufferSize(2), sdk.WithLogBatching(1, time.Second), sdk.WithLogDropPolicy(tc.policy))
+
+		// The first record is being published when the buffer fills up
+		shipper.Write([]byte("0"))
+		<-opening
+
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			for i := 1; i < 4; i++ {
+				shipper.Write([]byte(fmt.Sprintf("%d", i)))
+			}
+		}()
+
+		if tc.policy != sdk.BlockOnFull {
+			<-written
+		}
+
+		close(release)
+		<-written
+		require.NoError(t, shipper.Close())
+		require.NoError(t, client.Flush(context.Background()))
+		require.Equal(t, tc.dropped, shipper.Dropped(), "unexpected number of dropped records for policy %d", tc.policy)
+
+		var records []string
+		for _, pub := range recorder.Published() {
+			records = append(records, string(pub.Event.Data))
+		}
+		require.Equal(t, tc.expected, records, "unexpected records published for policy %d", tc.policy)
+	}
+}
+
+func TestLogShipperErrors(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithReadOnly())
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	errs := make(chan error, 1)
+	shipper := sdk.NewLogShipper(client, "01GWM89049D49FHJH81BT8795H", sdk.WithLogErrorHandler(func(err error) { errs <- err }))
+	shipper.Write([]byte(`{"level":"error"}`))
+	require.NoError(t, shipper.Close())
+	require.ErrorIs(t, <-errs, sdk.ErrReadOnlyClient)
+}
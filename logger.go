@@ -0,0 +1,9 @@
+package ensign
+
+import "github.com/rotationalio/go-ensign/stream"
+
+// Logger is used by the client to report publish and subscribe stream reconnects,
+// events that are dropped when a subscriber falls behind, and unhandled replies from
+// the server. Arguments are alternating key/value pairs; the method set matches the
+// standard library's *slog.Logger so that it can be passed to WithLogger directly.
+type Logger = stream.Logger
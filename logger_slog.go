@@ -0,0 +1,17 @@
+//go:build go1.21
+
+package ensign
+
+import "log/slog"
+
+// Ensure the standard library structured logger can be used as the client's logger.
+var _ Logger = (*slog.Logger)(nil)
+
+// NewSlogLogger returns a Logger that writes to the specified structured logger. If
+// the logger is nil then the default slog logger is used.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger
+}
@@ -1,9 +1,41 @@
 package ensign
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Metadata are user-defined key/value pairs that can be optionally added to an
 // event to store/lookup data without unmarshaling the entire payload.
 type Metadata map[string]string
 
+// Metadata keys and key prefixes that are managed by the SDK, e.g. to propagate trace
+// context or to deduplicate republished events. User-defined metadata should not use
+// these keys since the SDK may overwrite them or interpret their values.
+var reservedKeys = []string{
+	IdempotencyKey,
+	ExpiresAtKey,
+	"chunk_",
+	"dead_letter_",
+	"traceparent",
+	"tracestate",
+	"baggage",
+}
+
+// IsReservedKey returns true if the metadata key is managed by the SDK, e.g. the
+// IdempotencyKey, ExpiresAtKey, chunk and dead letter keys, or W3C trace context keys.
+func IsReservedKey(key string) bool {
+	for _, reserved := range reservedKeys {
+		if strings.HasPrefix(key, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
 // Get returns the metadata value for the given key. If the key is not in the metadata
 // an empty string is returned without an error.
 func (m Metadata) Get(key string) string {
@@ -13,7 +45,88 @@ func (m Metadata) Get(key string) string {
 	return ""
 }
 
-// Set a metadata value for the given key; overwrites existing keys.
+// Set a metadata value for the given key; overwrites existing keys. Set does not check
+// if the key is reserved so that the SDK and trace propagators can use it; use Merge to
+// add user-defined metadata without overwriting reserved keys.
 func (m Metadata) Set(key, value string) {
 	m[key] = value
 }
+
+// Delete the metadata value for the given key; no-op if the key does not exist.
+func (m Metadata) Delete(key string) {
+	delete(m, key)
+}
+
+// Keys returns the metadata keys in sorted order. Together with Get and Set this
+// allows metadata to be used as a carrier to propagate trace context with the event.
+func (m Metadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Merge copies the key/value pairs of the other metadata into this metadata,
+// overwriting existing keys. If the other metadata contains a reserved key (see
+// IsReservedKey) then ErrReservedMetadataKey is returned and no keys are copied.
+func (m Metadata) Merge(other Metadata) error {
+	for key := range other {
+		if IsReservedKey(key) {
+			return fmt.Errorf("%w: %q", ErrReservedMetadataKey, key)
+		}
+	}
+
+	for key, val := range other {
+		m[key] = val
+	}
+	return nil
+}
+
+// GetInt returns the metadata value for the given key parsed as a base 10 integer. If
+// the key is not in the metadata or cannot be parsed, false is returned.
+func (m Metadata) GetInt(key string) (int64, bool) {
+	val, err := strconv.ParseInt(m.Get(key), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return val, true
+}
+
+// SetInt sets the metadata value for the given key to the base 10 integer.
+func (m Metadata) SetInt(key string, value int64) {
+	m.Set(key, strconv.FormatInt(value, 10))
+}
+
+// GetBool returns the metadata value for the given key parsed as a boolean (see
+// strconv.ParseBool). If the key is not in the metadata or cannot be parsed, false is
+// returned as the second value.
+func (m Metadata) GetBool(key string) (bool, bool) {
+	val, err := strconv.ParseBool(m.Get(key))
+	if err != nil {
+		return false, false
+	}
+	return val, true
+}
+
+// SetBool sets the metadata value for the given key to "true" or "false".
+func (m Metadata) SetBool(key string, value bool) {
+	m.Set(key, strconv.FormatBool(value))
+}
+
+// GetTime returns the metadata value for the given key parsed as an RFC3339 timestamp.
+// If the key is not in the metadata or cannot be parsed, false is returned.
+func (m Metadata) GetTime(key string) (time.Time, bool) {
+	val, err := time.Parse(time.RFC3339Nano, m.Get(key))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return val, true
+}
+
+// SetTime sets the metadata value for the given key to the timestamp in UTC, formatted
+// as an RFC3339 timestamp with nanosecond precision.
+func (m Metadata) SetTime(key string, value time.Time) {
+	m.Set(key, value.UTC().Format(time.RFC3339Nano))
+}
@@ -2,6 +2,7 @@ package ensign_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/rotationalio/go-ensign"
 	"github.com/stretchr/testify/require"
@@ -13,4 +14,80 @@ func TestMetadata(t *testing.T) {
 
 	meta.Set("key", "value")
 	require.Equal(t, "value", meta.Get("key"), "should be able to get and set key/value pair")
+
+	meta.Set("alpha", "first")
+	require.Equal(t, []string{"alpha", "key"}, meta.Keys(), "expected sorted keys")
+	require.Empty(t, ensign.Metadata(nil).Keys(), "expected no keys for nil metadata")
+}
+
+func TestMetadataDelete(t *testing.T) {
+	meta := ensign.Metadata{"key": "value", "alpha": "first"}
+	meta.Delete("key")
+	meta.Delete("missing")
+	require.Equal(t, ensign.Metadata{"alpha": "first"}, meta)
+}
+
+func TestMetadataMerge(t *testing.T) {
+	meta := ensign.Metadata{"key": "value", ensign.IdempotencyKey: "order-42"}
+	require.NoError(t, meta.Merge(ensign.Metadata{"key": "updated", "alpha": "first"}))
+	require.NoError(t, meta.Merge(nil))
+	require.Equal(t, ensign.Metadata{"key": "updated", "alpha": "first", ensign.IdempotencyKey: "order-42"}, meta)
+
+	// Reserved keys cannot be merged and no keys are copied
+	err := meta.Merge(ensign.Metadata{"beta": "second", ensign.IdempotencyKey: "order-43"})
+	require.ErrorIs(t, err, ensign.ErrReservedMetadataKey)
+	require.Equal(t, "order-42", meta.Get(ensign.IdempotencyKey))
+	require.Empty(t, meta.Get("beta"))
+}
+
+func TestIsReservedKey(t *testing.T) {
+	for _, key := range []string{ensign.IdempotencyKey, ensign.ExpiresAtKey, ensign.DeadLetterReasonKey, ensign.DeadLetterNacksKey, "traceparent", "tracestate", "baggage"} {
+		require.True(t, ensign.IsReservedKey(key), "expected %q to be reserved", key)
+	}
+
+	for _, key := range []string{"", "key", "trace", "order_id"} {
+		require.False(t, ensign.IsReservedKey(key), "expected %q not to be reserved", key)
+	}
+}
+
+func TestMetadataTyped(t *testing.T) {
+	meta := make(ensign.Metadata)
+
+	// Missing keys are not ok
+	_, ok := meta.GetInt("count")
+	require.False(t, ok)
+	_, ok = meta.GetBool("flag")
+	require.False(t, ok)
+	_, ok = meta.GetTime("ts")
+	require.False(t, ok)
+
+	meta.SetInt("count", -42)
+	meta.SetBool("flag", true)
+	ts := time.Date(2023, 8, 14, 12, 31, 3, 42, time.FixedZone("EST", -5*3600))
+	meta.SetTime("ts", ts)
+
+	require.Equal(t, "-42", meta.Get("count"))
+	require.Equal(t, "true", meta.Get("flag"))
+	require.Equal(t, "2023-08-14T17:31:03.000000042Z", meta.Get("ts"))
+
+	count, ok := meta.GetInt("count")
+	require.True(t, ok)
+	require.Equal(t, int64(-42), count)
+
+	flag, ok := meta.GetBool("flag")
+	require.True(t, ok)
+	require.True(t, flag)
+
+	parsed, ok := meta.GetTime("ts")
+	require.True(t, ok)
+	require.True(t, ts.Equal(parsed))
+
+	// Values that cannot be parsed are not ok
+	meta = ensign.Metadata{"count": "many", "flag": "maybe", "ts": "yesterday"}
+	_, ok = meta.GetInt("count")
+	require.False(t, ok)
+	_, ok = meta.GetBool("flag")
+	require.False(t, ok)
+	_, ok = meta.GetTime("ts")
+	require.False(t, ok)
 }
@@ -0,0 +1,252 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// PublishedEvent is an event that was published to the mock, unwrapped so that it can
+// be inspected by matchers. Topic is the name of the topic if the topic ID is in the
+// topic map of the recorder, otherwise it is empty.
+type PublishedEvent struct {
+	Wrapper *api.EventWrapper
+	Event   *api.Event
+	TopicID ulid.ULID
+	Topic   string
+}
+
+// Recorder is implemented by types that record the events published to the mock, e.g.
+// a PublishRecorder, so that they can be checked with AssertPublished.
+type Recorder interface {
+	Published() []*PublishedEvent
+}
+
+// PublishRecorder is a PublishHandler that records every event published to the mock
+// before acking it. Use the OnPublish method of the recorder as the OnPublish handler
+// of the mock and then use AssertPublished to check that the expected events were
+// published by the code under test.
+type PublishRecorder struct {
+	*PublishHandler
+	sync.Mutex
+	names  map[ulid.ULID]string
+	events []*PublishedEvent
+}
+
+var _ Recorder = &PublishRecorder{}
+
+// NewPublishRecorder returns a recorder that acks all events and returns the specified
+// topic map when the publish stream is opened. The topic map is also used to resolve
+// the names of topics for the OnTopic matcher.
+func NewPublishRecorder(topics map[string]ulid.ULID) *PublishRecorder {
+	rec := &PublishRecorder{
+		PublishHandler: NewPublishHandler(topics),
+		names:          make(map[ulid.ULID]string, len(topics)),
+	}
+
+	for name, topicID := range topics {
+		rec.names[topicID] = name
+	}
+
+	ack := rec.OnEvent
+	rec.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		rec.record(in)
+		return ack(in)
+	}
+	return rec
+}
+
+// Published returns the events that have been published in the order they were received.
+func (r *PublishRecorder) Published() []*PublishedEvent {
+	r.Lock()
+	defer r.Unlock()
+	return append([]*PublishedEvent(nil), r.events...)
+}
+
+// Reset removes all recorded events.
+func (r *PublishRecorder) Reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.events = nil
+}
+
+func (r *PublishRecorder) record(in *api.EventWrapper) {
+	pub := &PublishedEvent{Wrapper: in}
+	pub.Event, _ = in.Unwrap()
+	pub.TopicID, _ = in.ParseTopicID()
+
+	r.Lock()
+	defer r.Unlock()
+	pub.Topic = r.names[pub.TopicID]
+	r.events = append(r.events, pub)
+}
+
+// A Matcher checks a property of a published event, returning an error that describes
+// the mismatch if the event does not have the property.
+type Matcher func(pub *PublishedEvent) error
+
+// AssertPublished checks that at least one event recorded by the recorder matches all
+// of the matchers, marking the test as failed if not. The failure message describes
+// why each recorded event did not match. Returns the first matching event or nil.
+func AssertPublished(t testing.TB, recorder Recorder, matchers ...Matcher) *PublishedEvent {
+	t.Helper()
+
+	published := recorder.Published()
+	if len(published) == 0 {
+		t.Errorf("expected an event to be published but no events were published")
+		return nil
+	}
+
+	mismatches := make([]string, 0, len(published))
+	for i, pub := range published {
+		if err := Match(pub, matchers...); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("  event %d: %s", i, err))
+			continue
+		}
+		return pub
+	}
+
+	t.Errorf("no published event matched (%d events published):\n%s", len(published), strings.Join(mismatches, "\n"))
+	return nil
+}
+
+// AssertNotPublished checks that none of the events recorded by the recorder match all
+// of the matchers, marking the test as failed if one does.
+func AssertNotPublished(t testing.TB, recorder Recorder, matchers ...Matcher) {
+	t.Helper()
+	for i, pub := range recorder.Published() {
+		if err := Match(pub, matchers...); err == nil {
+			t.Errorf("expected no matching event to be published but event %d matched", i)
+			return
+		}
+	}
+}
+
+// Match returns the error of the first matcher that does not match the event.
+func Match(pub *PublishedEvent, matchers ...Matcher) (err error) {
+	if pub.Event == nil {
+		return fmt.Errorf("could not unwrap event")
+	}
+
+	for _, match := range matchers {
+		if err = match(pub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnTopic matches events published to the topic, specified by topic ID or by topic
+// name if the topic is in the topic map of the recorder.
+func OnTopic(topic string) Matcher {
+	return func(pub *PublishedEvent) error {
+		if topicID, err := ulid.Parse(topic); err == nil && topicID.Compare(pub.TopicID) == 0 {
+			return nil
+		}
+
+		if pub.Topic != "" && pub.Topic == topic {
+			return nil
+		}
+		return fmt.Errorf("published to topic %s not %s", pub.TopicID, topic)
+	}
+}
+
+// HasMetadata matches events whose metadata has the key with the specified value.
+func HasMetadata(key, value string) Matcher {
+	return func(pub *PublishedEvent) error {
+		actual, ok := pub.Event.Metadata[key]
+		if !ok {
+			return fmt.Errorf("metadata key %q is missing", key)
+		}
+
+		if actual != value {
+			return fmt.Errorf("metadata %q is %q not %q", key, actual, value)
+		}
+		return nil
+	}
+}
+
+// HasKey matches events published with the specified partition key.
+func HasKey(key []byte) Matcher {
+	return func(pub *PublishedEvent) error {
+		if !bytes.Equal(pub.Wrapper.Key, key) {
+			return fmt.Errorf("partition key is %q not %q", pub.Wrapper.Key, key)
+		}
+		return nil
+	}
+}
+
+// HasType matches events with the type name and, if not empty, the semantic version
+// of the type (e.g. "1.2.0").
+func HasType(name, version string) Matcher {
+	return func(pub *PublishedEvent) error {
+		actual := pub.Event.Type
+		if actual == nil {
+			return fmt.Errorf("event has no type")
+		}
+
+		if actual.Name != name {
+			return fmt.Errorf("type is %q not %q", actual.Name, name)
+		}
+
+		if version != "" && actual.Semver() != version {
+			return fmt.Errorf("type version is %s not %s", actual.Semver(), version)
+		}
+		return nil
+	}
+}
+
+// HasData matches events whose data is exactly equal to the specified bytes.
+func HasData(data []byte) Matcher {
+	return func(pub *PublishedEvent) error {
+		if !bytes.Equal(pub.Event.Data, data) {
+			return fmt.Errorf("data does not match")
+		}
+		return nil
+	}
+}
+
+// PayloadJSONEquals matches events whose data is JSON that is semantically equal to the
+// expected value, which may be a JSON string or byte slice or any value that can be
+// marshaled to JSON. Key order and whitespace are ignored.
+func PayloadJSONEquals(expected interface{}) Matcher {
+	var (
+		want    interface{}
+		wantErr error
+	)
+
+	switch v := expected.(type) {
+	case string:
+		wantErr = json.Unmarshal([]byte(v), &want)
+	case []byte:
+		wantErr = json.Unmarshal(v, &want)
+	default:
+		var data []byte
+		if data, wantErr = json.Marshal(v); wantErr == nil {
+			wantErr = json.Unmarshal(data, &want)
+		}
+	}
+
+	return func(pub *PublishedEvent) error {
+		if wantErr != nil {
+			return fmt.Errorf("invalid expected json: %w", wantErr)
+		}
+
+		var got interface{}
+		if err := json.Unmarshal(pub.Event.Data, &got); err != nil {
+			return fmt.Errorf("data is not json: %w", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("json payload %s does not match", pub.Event.Data)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// StreamFixture describes a scripted sequence of replies for a streaming RPC so that
+// stream behavior can be mocked with UseFixture rather than with handler functions.
+// Each reply is the protocol buffer JSON of a PublisherReply for the Publish RPC, a
+// SubscribeReply for the Subscribe RPC, or an EventWrapper for the EnSQL RPC, e.g.:
+//
+//	{
+//	  "replies": [
+//	    {"ready": {"server_id": "mock", "topics": {"orders": "AYo5xOFc..."}}},
+//	    {"ack": {}},
+//	    {"nack": {"code": "MAX_EVENT_SIZE_EXCEEDED"}},
+//	    {"close_stream": {"events": 2}}
+//	  ],
+//	  "error": {"code": "UNAVAILABLE", "message": "server is shutting down"}
+//	}
+//
+// Publish streams wait for the client to open the stream, then for each ack or nack
+// wait for the next published event; if the ack or nack has no id, the local id of the
+// event is used so that the client can match it. Subscribe streams wait for the client
+// to send the subscription, then send all of the replies. If the error is specified it
+// is returned once the replies have been sent, otherwise streams are kept open until
+// the client closes them unless the last reply closes the stream.
+type StreamFixture struct {
+	Replies []json.RawMessage `json:"replies"`
+	Error   *FixtureError     `json:"error,omitempty"`
+}
+
+// FixtureError is the gRPC status error returned when a stream fixture ends.
+type FixtureError struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// Err returns the gRPC status error or nil if the fixture error is not specified.
+func (e *FixtureError) Err() error {
+	if e == nil {
+		return nil
+	}
+	return status.Error(e.Code, e.Message)
+}
+
+// Loads the stream fixture, unmarshaling each of its replies into the message returned
+// by the next function, which should also collect the replies.
+func (f *StreamFixture) load(data []byte, jsonpb *protojson.UnmarshalOptions, next func() proto.Message) (err error) {
+	if err = json.Unmarshal(data, f); err != nil {
+		return fmt.Errorf("could not unmarshal stream fixture: %v", err)
+	}
+
+	for i, raw := range f.Replies {
+		reply := next()
+		if err = jsonpb.Unmarshal(raw, reply); err != nil {
+			return fmt.Errorf("could not unmarshal json into %T (reply %d): %v", reply, i, err)
+		}
+	}
+	return nil
+}
+
+func (f *StreamFixture) onPublish(replies []*api.PublisherReply) func(api.Ensign_PublishServer) error {
+	return func(stream api.Ensign_PublishServer) (err error) {
+		var msg *api.PublisherRequest
+		if msg, err = stream.Recv(); err != nil {
+			return recvErr(err)
+		}
+
+		if msg.GetOpenStream() == nil {
+			return status.Error(codes.FailedPrecondition, "an open stream message must be sent immediately after opening the stream")
+		}
+
+		for _, reply := range replies {
+			// Acks and nacks are sent in response to the next published event.
+			var id *[]byte
+			switch embed := reply.Embed.(type) {
+			case *api.PublisherReply_Ack:
+				id = &embed.Ack.Id
+			case *api.PublisherReply_Nack:
+				id = &embed.Nack.Id
+			}
+
+			if id != nil {
+				if msg, err = stream.Recv(); err != nil {
+					return recvErr(err)
+				}
+
+				if len(*id) == 0 {
+					reply = proto.Clone(reply).(*api.PublisherReply)
+					switch embed := reply.Embed.(type) {
+					case *api.PublisherReply_Ack:
+						embed.Ack.Id = msg.GetEvent().GetLocalId()
+					case *api.PublisherReply_Nack:
+						embed.Nack.Id = msg.GetEvent().GetLocalId()
+					}
+				}
+			}
+
+			if err = stream.Send(reply); err != nil {
+				return err
+			}
+		}
+
+		if n := len(replies); f.Error == nil && n > 0 && replies[n-1].GetCloseStream() != nil {
+			return nil
+		}
+		return f.drain(func() error { _, err := stream.Recv(); return err })
+	}
+}
+
+func (f *StreamFixture) onSubscribe(replies []*api.SubscribeReply) func(api.Ensign_SubscribeServer) error {
+	return func(stream api.Ensign_SubscribeServer) (err error) {
+		var msg *api.SubscribeRequest
+		if msg, err = stream.Recv(); err != nil {
+			return recvErr(err)
+		}
+
+		if msg.GetSubscription() == nil {
+			return status.Error(codes.FailedPrecondition, "must send subscription to initialize stream")
+		}
+
+		for _, reply := range replies {
+			if err = stream.Send(reply); err != nil {
+				return err
+			}
+		}
+
+		if n := len(replies); f.Error == nil && n > 0 && replies[n-1].GetCloseStream() != nil {
+			return nil
+		}
+		return f.drain(func() error { _, err := stream.Recv(); return err })
+	}
+}
+
+func (f *StreamFixture) onEnSQL(replies []*api.EventWrapper) func(*api.Query, api.Ensign_EnSQLServer) error {
+	return func(_ *api.Query, stream api.Ensign_EnSQLServer) (err error) {
+		for _, reply := range replies {
+			if err = stream.Send(reply); err != nil {
+				return err
+			}
+		}
+		return f.Error.Err()
+	}
+}
+
+// Returns the fixture error if specified, otherwise receives messages from the client
+// (ignoring acks and events) until the client closes the stream.
+func (f *StreamFixture) drain(recv func() error) error {
+	if f.Error != nil {
+		return f.Error.Err()
+	}
+
+	for {
+		if err := recv(); err != nil {
+			return recvErr(err)
+		}
+	}
+}
+
+// Stream fixtures end without an error when the client closes the stream.
+func recvErr(err error) error {
+	if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+		return nil
+	}
+	return err
+}
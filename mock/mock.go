@@ -7,7 +7,6 @@ package mock
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -18,6 +17,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 // RPC Name constants based on the FullMethod that is returned from gRPC info. These
@@ -31,9 +31,9 @@ const (
 	RetrieveTopicRPC  = "/ensign.v1beta1.Ensign/RetrieveTopic"
 	DeleteTopicRPC    = "/ensign.v1beta1.Ensign/DeleteTopic"
 	TopicNamesRPC     = "/ensign.v1beta1.Ensign/TopicNames"
-	TopicExistsRPC    = "/ensign/v1beta1.Ensign/TopicExists"
-	SetTopicPolicyRPC = "/ensign/v1beta1.Ensign/SetTopicPolicy"
-	InfoRPC           = "/ensign/v1beta1.Ensign/Info"
+	TopicExistsRPC    = "/ensign.v1beta1.Ensign/TopicExists"
+	SetTopicPolicyRPC = "/ensign.v1beta1.Ensign/SetTopicPolicy"
+	InfoRPC           = "/ensign.v1beta1.Ensign/Info"
 	StatusRPC         = "/ensign.v1beta1.Ensign/Status"
 )
 
@@ -134,7 +134,8 @@ func (s *Ensign) Reset() {
 }
 
 // UseFixture loads a JSON fixture from disk (usually in the testdata folder) to use as
-// the protocol buffer response to the specified RPC, simplifying handler mocking.
+// the protocol buffer response to the specified RPC, simplifying handler mocking. The
+// fixture for a streaming RPC describes a scripted sequence of replies, see StreamFixture.
 func (s *Ensign) UseFixture(rpc, path string) (err error) {
 	var data []byte
 	if data, err = os.ReadFile(path); err != nil {
@@ -147,8 +148,39 @@ func (s *Ensign) UseFixture(rpc, path string) (err error) {
 	}
 
 	switch rpc {
-	case PublishRPC, SubscribeRPC, EnSQLRPC:
-		return errors.New("cannot use fixture for a streaming RPC (yet)")
+	case PublishRPC:
+		var out []*api.PublisherReply
+		fixture := &StreamFixture{}
+		if err = fixture.load(data, jsonpb, func() proto.Message {
+			reply := &api.PublisherReply{}
+			out = append(out, reply)
+			return reply
+		}); err != nil {
+			return err
+		}
+		s.OnPublish = fixture.onPublish(out)
+	case SubscribeRPC:
+		var out []*api.SubscribeReply
+		fixture := &StreamFixture{}
+		if err = fixture.load(data, jsonpb, func() proto.Message {
+			reply := &api.SubscribeReply{}
+			out = append(out, reply)
+			return reply
+		}); err != nil {
+			return err
+		}
+		s.OnSubscribe = fixture.onSubscribe(out)
+	case EnSQLRPC:
+		var out []*api.EventWrapper
+		fixture := &StreamFixture{}
+		if err = fixture.load(data, jsonpb, func() proto.Message {
+			reply := &api.EventWrapper{}
+			out = append(out, reply)
+			return reply
+		}); err != nil {
+			return err
+		}
+		s.OnEnSQL = fixture.onEnSQL(out)
 	case ListTopicsRPC:
 		out := &api.TopicsPage{}
 		if err = jsonpb.Unmarshal(data, out); err != nil {
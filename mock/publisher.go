@@ -14,7 +14,8 @@ import (
 // PublishHandler provides an OnPublish function that assists in the testing of publish
 // streams by breaking down the initialization and messaging phase of the publisher
 // stream. For example, this handler can be used to ensure that a specific number of
-// events get published or to send acks or nacks to specific events.
+// events get published or to send acks or nacks to specific events. If OnEvent returns
+// a nil reply, no reply is sent for the event, e.g. to test publisher ack timeouts.
 type PublishHandler struct {
 	OnInitialize func(in *api.OpenStream) (out *api.StreamReady, err error)
 	OnEvent      func(in *api.EventWrapper) (out *api.PublisherReply, err error)
@@ -98,6 +99,11 @@ func (s *PublishHandler) OnPublish(stream api.Ensign_PublishServer) (err error)
 				rep = &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: req.Event.LocalId, Code: api.Nack_UNPROCESSED}}}
 			}
 
+			// A nil reply simulates a server that never replies to the event.
+			if rep == nil {
+				continue
+			}
+
 			if err = stream.Send(rep); err != nil {
 				if errors.Is(err, io.EOF) {
 					return nil
@@ -0,0 +1,190 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// FixtureRecorder provides client interceptors that capture the responses of a live
+// Ensign server (e.g. staging) as fixture files that the mock can replay, so that
+// integration scenarios can be converted into fast offline tests. For example:
+//
+//	recorder, _ := mock.NewFixtureRecorder("testdata/scenario")
+//	client, _ := ensign.New(
+//		ensign.WithUnaryInterceptor(recorder.UnaryInterceptor),
+//		ensign.WithStreamInterceptor(recorder.StreamInterceptor),
+//	)
+//
+// Then in the offline test, load the recorded fixtures with Ensign.Replay. Fixtures are
+// named after the RPC, e.g. listtopics.pb.json, and only the last response of each RPC
+// is kept. Unary RPCs are recorded when they succeed; streams are recorded as a
+// StreamFixture when the stream ends, including the error the stream ended with.
+type FixtureRecorder struct {
+	sync.Mutex
+	dir    string
+	jsonpb protojson.MarshalOptions
+	err    error
+}
+
+// NewFixtureRecorder creates a recorder that writes fixtures to the directory, which
+// is created if it does not exist.
+func NewFixtureRecorder(dir string) (_ *FixtureRecorder, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create fixture directory: %v", err)
+	}
+
+	return &FixtureRecorder{
+		dir:    dir,
+		jsonpb: protojson.MarshalOptions{Multiline: true, Indent: "  ", UseProtoNames: true},
+	}, nil
+}
+
+// Err returns the first error that occurred while writing a fixture, if any. Recording
+// errors are not returned from the interceptors so that they do not affect the client.
+func (r *FixtureRecorder) Err() error {
+	r.Lock()
+	defer r.Unlock()
+	return r.err
+}
+
+// UnaryInterceptor records the response of successful unary RPCs.
+func (r *FixtureRecorder) UnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+	if err = invoker(ctx, method, req, reply, cc, opts...); err != nil {
+		return err
+	}
+
+	if msg, ok := reply.(proto.Message); ok {
+		data, merr := r.jsonpb.Marshal(msg)
+		r.write(method, data, merr)
+	}
+	return nil
+}
+
+// StreamInterceptor records the replies received on streams until the stream ends.
+func (r *FixtureRecorder) StreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (_ grpc.ClientStream, err error) {
+	var stream grpc.ClientStream
+	if stream, err = streamer(ctx, desc, cc, method, opts...); err != nil {
+		return nil, err
+	}
+	return &recordingStream{ClientStream: stream, recorder: r, method: method}, nil
+}
+
+// Writes the fixture for the method, recording the error if the fixture could not be
+// marshaled or written.
+func (r *FixtureRecorder) write(method string, data []byte, err error) {
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.dir, fixtureName(method)), data, 0644)
+	}
+
+	if err != nil {
+		r.Lock()
+		if r.err == nil {
+			r.err = fmt.Errorf("could not record %s fixture: %v", method, err)
+		}
+		r.Unlock()
+	}
+}
+
+type recordingStream struct {
+	grpc.ClientStream
+	recorder *FixtureRecorder
+	method   string
+	fixture  StreamFixture
+	once     sync.Once
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) (err error) {
+	if err = s.ClientStream.RecvMsg(m); err != nil {
+		s.once.Do(func() { s.finish(err) })
+		return err
+	}
+
+	if msg, ok := m.(proto.Message); ok {
+		data, merr := s.recorder.jsonpb.Marshal(stripReplyID(msg))
+		if merr != nil {
+			s.recorder.write(s.method, nil, merr)
+			return nil
+		}
+		s.fixture.Replies = append(s.fixture.Replies, data)
+	}
+	return nil
+}
+
+// Writes the stream fixture once the stream has ended; streams that are closed by the
+// client end without an error.
+func (s *recordingStream) finish(err error) {
+	if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
+		serr, _ := status.FromError(err)
+		s.fixture.Error = &FixtureError{Code: serr.Code(), Message: serr.Message()}
+	}
+
+	data, err := json.MarshalIndent(&s.fixture, "", "  ")
+	s.recorder.write(s.method, data, err)
+}
+
+// Acks and nacks reference the local IDs of the recorded events, which will not match
+// the events published during replay, so the IDs are removed and filled in on replay.
+func stripReplyID(msg proto.Message) proto.Message {
+	reply, ok := msg.(*api.PublisherReply)
+	if !ok || (reply.GetAck() == nil && reply.GetNack() == nil) {
+		return msg
+	}
+
+	reply = proto.Clone(reply).(*api.PublisherReply)
+	if ack := reply.GetAck(); ack != nil {
+		ack.Id = nil
+	}
+	if nack := reply.GetNack(); nack != nil {
+		nack.Id = nil
+	}
+	return reply
+}
+
+// Replay loads the fixtures in the directory that were recorded by a FixtureRecorder,
+// using each fixture as the response of its RPC (see UseFixture). Files that are not
+// named after an RPC are ignored.
+func (s *Ensign) Replay(dir string) (err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(dir); err != nil {
+		return fmt.Errorf("could not read fixture directory: %v", err)
+	}
+
+	rpcs := make(map[string]string, len(allRPCs))
+	for _, rpc := range allRPCs {
+		rpcs[fixtureName(rpc)] = rpc
+	}
+
+	for _, entry := range entries {
+		if rpc, ok := rpcs[entry.Name()]; ok && !entry.IsDir() {
+			if err = s.UseFixture(rpc, filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var allRPCs = []string{
+	PublishRPC, SubscribeRPC, EnSQLRPC, ListTopicsRPC, CreateTopicRPC, RetrieveTopicRPC,
+	DeleteTopicRPC, TopicNamesRPC, TopicExistsRPC, SetTopicPolicyRPC, InfoRPC, StatusRPC,
+}
+
+// Returns the fixture file name for the full method name of the RPC.
+func fixtureName(method string) string {
+	return strings.ToLower(path.Base(method)) + ".pb.json"
+}
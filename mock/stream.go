@@ -0,0 +1,312 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/stream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Streams implements stream.PublishClient and stream.SubscribeClient with pure
+// in-memory streams so that stream.Publisher, stream.Subscriber, and user code that
+// works with api.Ensign_PublishClient or api.Ensign_SubscribeClient can be unit tested
+// without gRPC or a bufconn. Each call to PublishStream or SubscribeStream runs the
+// OnPublish or OnSubscribe handler in a go routine with the server side of the stream,
+// so the same handlers used with the Ensign mock server (e.g. PublishHandler or
+// SubscribeHandler) can be used. As with gRPC, messages are copied when they are sent,
+// the error returned by the handler is received by the client as a status error (or
+// io.EOF if the handler returns nil), and the server context is canceled when the
+// handler returns or the client context is canceled.
+//
+// The connection of the streams is always ready; a stream failure can be simulated by
+// returning an error from the handler, in which case a reconnecting stream opens a new
+// stream immediately. The Calls map counts the streams opened by RPC name.
+type Streams struct {
+	sync.RWMutex
+	Calls       map[string]int
+	OnPublish   func(api.Ensign_PublishServer) error
+	OnSubscribe func(api.Ensign_SubscribeServer) error
+}
+
+var (
+	_ stream.PublishClient   = &Streams{}
+	_ stream.SubscribeClient = &Streams{}
+)
+
+// NewStreams creates in-memory streams with no handlers; streams opened before a
+// handler is set fail with ErrUnavailable.
+func NewStreams() *Streams {
+	return &Streams{Calls: make(map[string]int)}
+}
+
+// PublishStream opens an in-memory publish stream handled by OnPublish.
+func (s *Streams) PublishStream(ctx context.Context, _ ...grpc.CallOption) (api.Ensign_PublishClient, error) {
+	s.Lock()
+	s.Calls[PublishRPC]++
+	handler := s.OnPublish
+	s.Unlock()
+
+	p := newPipe(ctx)
+	go p.serve(func() error {
+		if handler != nil {
+			return handler(&publishServer{pipeServer{p}})
+		}
+		return ErrUnavailable
+	})
+	return &publishClient{pipeClient{p}}, nil
+}
+
+// SubscribeStream opens an in-memory subscribe stream handled by OnSubscribe.
+func (s *Streams) SubscribeStream(ctx context.Context, _ ...grpc.CallOption) (api.Ensign_SubscribeClient, error) {
+	s.Lock()
+	s.Calls[SubscribeRPC]++
+	handler := s.OnSubscribe
+	s.Unlock()
+
+	p := newPipe(ctx)
+	go p.serve(func() error {
+		if handler != nil {
+			return handler(&subscribeServer{pipeServer{p}})
+		}
+		return ErrUnavailable
+	})
+	return &subscribeClient{pipeClient{p}}, nil
+}
+
+// ConnState always returns connectivity.Ready.
+func (s *Streams) ConnState() connectivity.State {
+	return connectivity.Ready
+}
+
+// WaitForReconnect always returns true since the streams are always connected.
+func (s *Streams) WaitForReconnect(context.Context) bool {
+	return true
+}
+
+// Reset the calls map and the handlers in preparation for a new test.
+func (s *Streams) Reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	for key := range s.Calls {
+		delete(s.Calls, key)
+	}
+
+	s.OnPublish = nil
+	s.OnSubscribe = nil
+}
+
+var errSendAfterClose = errors.New("send called after CloseSend")
+
+// pipe connects the client and server sides of an in-memory stream; up carries the
+// messages from the client to the server and down the messages from the server to the
+// client. The buffers stand in for the flow control windows of a gRPC stream.
+type pipe struct {
+	cctx    context.Context
+	sctx    context.Context
+	cancel  context.CancelFunc
+	up      chan proto.Message
+	down    chan proto.Message
+	eof     chan struct{}
+	eofOnce sync.Once
+	done    chan struct{}
+	err     error
+}
+
+func newPipe(ctx context.Context) *pipe {
+	sctx, cancel := context.WithCancel(ctx)
+	return &pipe{
+		cctx:   ctx,
+		sctx:   sctx,
+		cancel: cancel,
+		up:     make(chan proto.Message, stream.BufferSize),
+		down:   make(chan proto.Message, stream.BufferSize),
+		eof:    make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Run the handler and record its error as a status error for the client.
+func (p *pipe) serve(handler func() error) {
+	if err := handler(); err != nil {
+		p.err = status.Convert(err).Err()
+	} else {
+		p.err = io.EOF
+	}
+
+	p.cancel()
+	close(p.done)
+}
+
+type pipeClient struct {
+	p *pipe
+}
+
+func (c pipeClient) send(msg proto.Message) error {
+	select {
+	case <-c.p.eof:
+		return errSendAfterClose
+	default:
+	}
+
+	// As with gRPC, the actual error is returned by Recv once the stream has ended.
+	select {
+	case c.p.up <- proto.Clone(msg):
+		return nil
+	case <-c.p.done:
+		return io.EOF
+	case <-c.p.cctx.Done():
+		return io.EOF
+	}
+}
+
+func (c pipeClient) recv() (proto.Message, error) {
+	select {
+	case msg := <-c.p.down:
+		return msg, nil
+	case <-c.p.done:
+		// Deliver the messages sent by the handler before it returned.
+		select {
+		case msg := <-c.p.down:
+			return msg, nil
+		default:
+			return nil, c.p.err
+		}
+	case <-c.p.cctx.Done():
+		return nil, status.FromContextError(c.p.cctx.Err()).Err()
+	}
+}
+
+func (c pipeClient) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (c pipeClient) Trailer() metadata.MD         { return metadata.MD{} }
+func (c pipeClient) Context() context.Context     { return c.p.cctx }
+
+func (c pipeClient) CloseSend() error {
+	c.p.eofOnce.Do(func() { close(c.p.eof) })
+	return nil
+}
+
+func (c pipeClient) SendMsg(m interface{}) error {
+	return c.send(m.(proto.Message))
+}
+
+func (c pipeClient) RecvMsg(m interface{}) error {
+	msg, err := c.recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(m.(proto.Message), msg)
+	return nil
+}
+
+type pipeServer struct {
+	p *pipe
+}
+
+func (s pipeServer) send(msg proto.Message) error {
+	select {
+	case s.p.down <- proto.Clone(msg):
+		return nil
+	case <-s.p.sctx.Done():
+		return status.FromContextError(s.p.sctx.Err()).Err()
+	}
+}
+
+func (s pipeServer) recv() (proto.Message, error) {
+	select {
+	case msg := <-s.p.up:
+		return msg, nil
+	case <-s.p.eof:
+		// Deliver the messages sent by the client before it closed the stream.
+		select {
+		case msg := <-s.p.up:
+			return msg, nil
+		default:
+			return nil, io.EOF
+		}
+	case <-s.p.sctx.Done():
+		return nil, status.FromContextError(s.p.sctx.Err()).Err()
+	}
+}
+
+func (s pipeServer) SetHeader(metadata.MD) error  { return nil }
+func (s pipeServer) SendHeader(metadata.MD) error { return nil }
+func (s pipeServer) SetTrailer(metadata.MD)       {}
+func (s pipeServer) Context() context.Context     { return s.p.sctx }
+
+func (s pipeServer) SendMsg(m interface{}) error {
+	return s.send(m.(proto.Message))
+}
+
+func (s pipeServer) RecvMsg(m interface{}) error {
+	msg, err := s.recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(m.(proto.Message), msg)
+	return nil
+}
+
+type publishClient struct{ pipeClient }
+
+func (c *publishClient) Send(in *api.PublisherRequest) error {
+	return c.send(in)
+}
+
+func (c *publishClient) Recv() (*api.PublisherReply, error) {
+	msg, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*api.PublisherReply), nil
+}
+
+type publishServer struct{ pipeServer }
+
+func (s *publishServer) Send(out *api.PublisherReply) error {
+	return s.send(out)
+}
+
+func (s *publishServer) Recv() (*api.PublisherRequest, error) {
+	msg, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*api.PublisherRequest), nil
+}
+
+type subscribeClient struct{ pipeClient }
+
+func (c *subscribeClient) Send(in *api.SubscribeRequest) error {
+	return c.send(in)
+}
+
+func (c *subscribeClient) Recv() (*api.SubscribeReply, error) {
+	msg, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*api.SubscribeReply), nil
+}
+
+type subscribeServer struct{ pipeServer }
+
+func (s *subscribeServer) Send(out *api.SubscribeReply) error {
+	return s.send(out)
+}
+
+func (s *subscribeServer) Recv() (*api.SubscribeRequest, error) {
+	msg, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*api.SubscribeRequest), nil
+}
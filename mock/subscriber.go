@@ -32,14 +32,22 @@ func NewSubscribeHandler() *SubscribeHandler {
 }
 
 // UseTopicMap sets OnInitialize to use the topics in the topic map, returning an error
-// if the subscription contains topics that are not in the topics map.
+// if the subscription contains topics that are not in the topics map. Like the Ensign
+// server, the subscription may reference topics by name or by topic ID.
 func (s *SubscribeHandler) UseTopicMap(topics map[string]ulid.ULID) {
+	names := make(map[string]string, len(topics))
+	for name, id := range topics {
+		names[name] = name
+		names[id.String()] = name
+	}
+
 	s.OnInitialize = func(in *api.Subscription) (out *api.StreamReady, err error) {
 		// Filter topics from subscription
 		filter := make(map[string]struct{})
-		for _, name := range in.Topics {
-			if _, ok := topics[name]; !ok {
-				return nil, status.Errorf(codes.InvalidArgument, "unknown topic %q", name)
+		for _, topic := range in.Topics {
+			name, ok := names[topic]
+			if !ok {
+				return nil, status.Errorf(codes.InvalidArgument, "unknown topic %q", topic)
 			}
 			filter[name] = struct{}{}
 		}
@@ -0,0 +1,275 @@
+package ensign
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProjectSeparator separates the project name from the topic name or ID in a qualified
+// topic reference handled by a MultiClient, e.g. "billing/invoices".
+const ProjectSeparator = "/"
+
+// MultiClient manages connections to several Ensign projects for applications that
+// span projects. Because API keys are scoped to a single project, the MultiClient
+// holds one Client per project, each created with its own credentials, and routes
+// topic operations, publishes, and subscribes to the client of the project that owns
+// the topic. Topics are routed either by qualifying the topic with the project name
+// (e.g. "billing/invoices") or by registering a route for the topic with Route.
+// Unqualified topics without a route are sent to the default project, which is the
+// first project added unless specified with SetDefault.
+type MultiClient struct {
+	sync.RWMutex
+	clients  map[string]*Client
+	routes   map[string]string
+	fallback string
+}
+
+// NewMultiClient creates a MultiClient with no projects; use AddProject to connect to
+// each of the projects that the application requires.
+func NewMultiClient() *MultiClient {
+	return &MultiClient{
+		clients: make(map[string]*Client),
+		routes:  make(map[string]string),
+	}
+}
+
+// AddProject creates a client for the project using the specified options, which
+// should include the credentials of an API key for the project (e.g. using
+// WithCredentials or WithLoadCredentials). The project name is only used for routing
+// and does not have to match the name of the project in Ensign.
+func (m *MultiClient) AddProject(project string, opts ...Option) (client *Client, err error) {
+	if project == "" || strings.Contains(project, ProjectSeparator) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProject, project)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.clients[project]; ok {
+		return nil, fmt.Errorf("%w: %q", ErrProjectExists, project)
+	}
+
+	if client, err = New(opts...); err != nil {
+		return nil, err
+	}
+
+	m.clients[project] = client
+	if m.fallback == "" {
+		m.fallback = project
+	}
+	return client, nil
+}
+
+// Project returns the client for the specified project.
+func (m *MultiClient) Project(project string) (_ *Client, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.project(project)
+}
+
+// Projects returns the sorted names of the projects the MultiClient is connected to.
+func (m *MultiClient) Projects() []string {
+	m.RLock()
+	defer m.RUnlock()
+	return m.sortedProjects()
+}
+
+// SetDefault sets the project that unqualified topics without a route are sent to.
+func (m *MultiClient) SetDefault(project string) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	if _, err = m.project(project); err != nil {
+		return err
+	}
+	m.fallback = project
+	return nil
+}
+
+// Route unqualified references to the topic name or ID to the specified project.
+func (m *MultiClient) Route(topic, project string) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	if _, err = m.project(project); err != nil {
+		return err
+	}
+	m.routes[topic] = project
+	return nil
+}
+
+// Resolve returns the client of the project that owns the topic and the topic name or
+// ID with any project qualifier removed, so that it can be passed to the client.
+func (m *MultiClient) Resolve(topic string) (client *Client, name string, err error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if project, name, ok := strings.Cut(topic, ProjectSeparator); ok {
+		if client, err = m.project(project); err != nil {
+			return nil, "", err
+		}
+		return client, name, nil
+	}
+
+	project, ok := m.routes[topic]
+	if !ok {
+		if m.fallback == "" {
+			return nil, "", fmt.Errorf("%w: no route for topic %q", ErrUnknownProject, topic)
+		}
+		project = m.fallback
+	}
+
+	if client, err = m.project(project); err != nil {
+		return nil, "", err
+	}
+	return client, topic, nil
+}
+
+// Publish events to the topic using the client of the project that owns the topic.
+func (m *MultiClient) Publish(topic string, events ...*Event) error {
+	return m.PublishContext(context.Background(), topic, events...)
+}
+
+// PublishContext publishes events to the topic using the client of the project that
+// owns the topic; see Client.PublishContext for details.
+func (m *MultiClient) PublishContext(ctx context.Context, topic string, events ...*Event) (err error) {
+	var (
+		client *Client
+		name   string
+	)
+
+	if client, name, err = m.Resolve(topic); err != nil {
+		return err
+	}
+	return client.PublishContext(ctx, name, events...)
+}
+
+// Subscribe to the topics using the client of the project that owns them. All of the
+// topics must belong to the same project since a subscribe stream is connected to a
+// single project; create a subscription per project to consume from several projects.
+func (m *MultiClient) Subscribe(topics ...string) (*Subscription, error) {
+	return m.CreateSubscriber(topics)
+}
+
+// CreateSubscriber subscribes to the topics with the subscribe options using the
+// client of the project that owns the topics; see Client.CreateSubscriber.
+func (m *MultiClient) CreateSubscriber(topics []string, opts ...SubscribeOption) (_ *Subscription, err error) {
+	var (
+		client *Client
+		names  []string
+	)
+
+	if client, names, err = m.resolveAll(topics); err != nil {
+		return nil, err
+	}
+	return client.CreateSubscriber(names, opts...)
+}
+
+// CreateTopic creates the topic in the project that owns it.
+func (m *MultiClient) CreateTopic(ctx context.Context, topic string) (_ string, err error) {
+	return m.CreateTopicWithOptions(ctx, topic)
+}
+
+// CreateTopicWithOptions creates the topic with the options in the project that owns it.
+func (m *MultiClient) CreateTopicWithOptions(ctx context.Context, topic string, opts ...CreateTopicOption) (_ string, err error) {
+	var (
+		client *Client
+		name   string
+	)
+
+	if client, name, err = m.Resolve(topic); err != nil {
+		return "", err
+	}
+	return client.CreateTopicWithOptions(ctx, name, opts...)
+}
+
+// TopicExists checks if the topic exists in the project that owns it.
+func (m *MultiClient) TopicExists(ctx context.Context, topic string) (_ bool, err error) {
+	var (
+		client *Client
+		name   string
+	)
+
+	if client, name, err = m.Resolve(topic); err != nil {
+		return false, err
+	}
+	return client.TopicExists(ctx, name)
+}
+
+// TopicID finds the topic ID of the topic in the project that owns it.
+func (m *MultiClient) TopicID(ctx context.Context, topic string) (_ string, err error) {
+	var (
+		client *Client
+		name   string
+	)
+
+	if client, name, err = m.Resolve(topic); err != nil {
+		return "", err
+	}
+	return client.TopicID(ctx, name)
+}
+
+// Close the clients of all projects, returning the first error that occurs.
+func (m *MultiClient) Close() (err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, project := range m.sortedProjects() {
+		if cerr := m.clients[project].Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("could not close client for project %q: %w", project, cerr)
+		}
+	}
+
+	m.clients = make(map[string]*Client)
+	m.routes = make(map[string]string)
+	m.fallback = ""
+	return err
+}
+
+func (m *MultiClient) project(project string) (*Client, error) {
+	client, ok := m.clients[project]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProject, project)
+	}
+	return client, nil
+}
+
+func (m *MultiClient) resolveAll(topics []string) (client *Client, names []string, err error) {
+	names = make([]string, 0, len(topics))
+	for _, topic := range topics {
+		var (
+			owner *Client
+			name  string
+		)
+
+		if owner, name, err = m.Resolve(topic); err != nil {
+			return nil, nil, err
+		}
+
+		if client != nil && owner != client {
+			return nil, nil, ErrMultipleProjects
+		}
+
+		client = owner
+		names = append(names, name)
+	}
+
+	// Subscribing to no topics subscribes to all topics in the default project.
+	if client == nil {
+		m.RLock()
+		defer m.RUnlock()
+		if client, err = m.project(m.fallback); err != nil {
+			return nil, nil, err
+		}
+	}
+	return client, names, nil
+}
+
+func (m *MultiClient) sortedProjects() []string {
+	projects := make([]string, 0, len(m.clients))
+	for project := range m.clients {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	return projects
+}
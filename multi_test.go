@@ -0,0 +1,113 @@
+package ensign_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiClient(t *testing.T) {
+	billing := mock.New(nil)
+	defer billing.Shutdown()
+
+	shipping := mock.New(nil)
+	defer shipping.Shutdown()
+
+	// Each project records the topics created in it
+	created := make(map[string][]string)
+	onCreateTopic := func(project string) func(context.Context, *api.Topic) (*api.Topic, error) {
+		return func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+			created[project] = append(created[project], in.Name)
+			topicID := ulid.Make()
+			return &api.Topic{Id: topicID[:], Name: in.Name}, nil
+		}
+	}
+	billing.OnCreateTopic = onCreateTopic("billing")
+	shipping.OnCreateTopic = onCreateTopic("shipping")
+
+	multi := sdk.NewMultiClient()
+	_, err := multi.AddProject("billing", sdk.WithMock(billing), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not add billing project")
+	_, err = multi.AddProject("shipping", sdk.WithMock(shipping), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not add shipping project")
+
+	_, err = multi.AddProject("billing", sdk.WithMock(billing), sdk.WithAuthenticator("", true))
+	require.ErrorIs(t, err, sdk.ErrProjectExists)
+	_, err = multi.AddProject("bad/name", sdk.WithMock(billing), sdk.WithAuthenticator("", true))
+	require.ErrorIs(t, err, sdk.ErrInvalidProject)
+
+	require.Equal(t, []string{"billing", "shipping"}, multi.Projects())
+
+	ctx := context.Background()
+
+	// Qualified topics are routed to the named project
+	_, err = multi.CreateTopic(ctx, "shipping/parcels")
+	require.NoError(t, err)
+
+	// Routed topics are sent to the routed project
+	require.NoError(t, multi.Route("labels", "shipping"))
+	_, err = multi.CreateTopic(ctx, "labels")
+	require.NoError(t, err)
+
+	// Unqualified topics are sent to the default project
+	_, err = multi.CreateTopic(ctx, "invoices")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"invoices"}, created["billing"])
+	require.Equal(t, []string{"parcels", "labels"}, created["shipping"])
+
+	require.NoError(t, multi.SetDefault("shipping"))
+	client, name, err := multi.Resolve("receipts")
+	require.NoError(t, err)
+	require.Equal(t, "receipts", name)
+	shippingClient, err := multi.Project("shipping")
+	require.NoError(t, err)
+	require.Same(t, shippingClient, client)
+
+	// Unknown projects cannot be routed to
+	_, err = multi.CreateTopic(ctx, "marketing/campaigns")
+	require.ErrorIs(t, err, sdk.ErrUnknownProject)
+	require.ErrorIs(t, multi.Route("campaigns", "marketing"), sdk.ErrUnknownProject)
+	require.ErrorIs(t, multi.SetDefault("marketing"), sdk.ErrUnknownProject)
+
+	// A single subscription cannot span projects
+	_, err = multi.Subscribe("billing/invoices", "shipping/parcels")
+	require.ErrorIs(t, err, sdk.ErrMultipleProjects)
+
+	require.NoError(t, multi.Close())
+	require.Empty(t, multi.Projects())
+
+	_, _, err = multi.Resolve("invoices")
+	require.ErrorIs(t, err, sdk.ErrUnknownProject)
+}
+
+func TestMultiClientPublish(t *testing.T) {
+	billing := mock.New(nil)
+	defer billing.Shutdown()
+
+	shipping := mock.New(nil)
+	defer shipping.Shutdown()
+
+	billing.OnPublish = mock.NewPublishHandler(nil).OnPublish
+	shipping.OnPublish = mock.NewPublishHandler(nil).OnPublish
+
+	multi := sdk.NewMultiClient()
+	_, err := multi.AddProject("billing", sdk.WithMock(billing), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not add billing project")
+	_, err = multi.AddProject("shipping", sdk.WithMock(shipping), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not add shipping project")
+	defer multi.Close()
+
+	event := NewEvent()
+	require.NoError(t, multi.Publish("shipping/01GWM89049D49FHJH81BT8795H", event))
+	_, err = event.Wait()
+	require.NoError(t, err, "event was not acked")
+
+	require.Equal(t, 1, shipping.Calls[mock.PublishRPC])
+	require.Equal(t, 0, billing.Calls[mock.PublishRPC])
+}
@@ -0,0 +1,164 @@
+/*
+Package natsbridge converts between NATS messages and Ensign events and relays messages
+from NATS subjects to Ensign topics so that hybrid deployments can mirror NATS and
+JetStream subjects into Ensign with the subject, headers, and JetStream metadata of the
+messages preserved in the event metadata. To avoid requiring the SDK to depend on the
+NATS client, messages are represented by the Msg struct, whose fields mirror nats.Msg
+so that a NATS message can be adapted with a few lines of code:
+
+	msg := &natsbridge.Msg{Subject: m.Subject, Reply: m.Reply, Header: m.Header, Data: m.Data, Ack: m.Ack}
+*/
+package natsbridge
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// Metadata keys used to preserve the NATS message properties in Ensign events.
+const (
+	SubjectKey   = "nats_subject"
+	ReplyKey     = "nats_reply"
+	HeaderPrefix = "nats_header_"
+	StreamKey    = "nats_stream"
+	ConsumerKey  = "nats_consumer"
+	SequenceKey  = "nats_sequence"
+)
+
+// ContentType is the NATS header that is used to determine the mimetype of the event.
+const ContentType = "Content-Type"
+
+// Msg is a NATS message. The Header has the same underlying type as nats.Header. If
+// Ack is not nil, it is called by the relay once the event is acked by Ensign, e.g. the
+// Ack method of a JetStream message so that it is redelivered if it is not mirrored.
+type Msg struct {
+	Subject string
+	Reply   string
+	Header  map[string][]string
+	Data    []byte
+	Ack     func() error
+}
+
+// ToEnsign converts the NATS message to an Ensign event. The subject, reply subject,
+// and headers are stored in the event metadata; header values are prefixed by the
+// HeaderPrefix and multiple values of the same header are joined by newlines, which
+// cannot appear in NATS header values. The mimetype is parsed from the Content-Type
+// header, defaulting to application/octet-stream. If the message was delivered by a
+// JetStream consumer, the stream, consumer, and stream sequence are also stored in the
+// metadata and the created timestamp is the time the message was stored in the stream,
+// otherwise the created timestamp is the current time.
+func ToEnsign(msg *Msg) *ensign.Event {
+	event := &ensign.Event{
+		Metadata: make(ensign.Metadata, 2+len(msg.Header)),
+		Data:     msg.Data,
+		Mimetype: mimetype.ApplicationOctetStream,
+		Created:  time.Now(),
+	}
+
+	event.Metadata.Set(SubjectKey, msg.Subject)
+	if msg.Reply != "" {
+		event.Metadata.Set(ReplyKey, msg.Reply)
+	}
+
+	for name, values := range msg.Header {
+		event.Metadata.Set(HeaderPrefix+name, strings.Join(values, "\n"))
+	}
+
+	if values := msg.Header[ContentType]; len(values) > 0 {
+		if mime, err := mimetype.Parse(values[0]); err == nil {
+			event.Mimetype = mime
+		}
+	}
+
+	if meta, ok := ParseJetStream(msg.Reply); ok {
+		event.Metadata.Set(StreamKey, meta.Stream)
+		event.Metadata.Set(ConsumerKey, meta.Consumer)
+		event.Metadata.SetInt(SequenceKey, int64(meta.Sequence))
+		event.Created = meta.Timestamp
+	}
+	return event
+}
+
+// FromEnsign converts an Ensign event to a NATS message, restoring the subject, reply
+// subject, and headers from the event metadata. If the event was not converted from a
+// NATS message the subject is empty and should be set by the caller.
+func FromEnsign(event *ensign.Event) *Msg {
+	msg := &Msg{
+		Subject: event.Metadata.Get(SubjectKey),
+		Reply:   event.Metadata.Get(ReplyKey),
+		Data:    event.Data,
+	}
+
+	for key, value := range event.Metadata {
+		if name := strings.TrimPrefix(key, HeaderPrefix); name != key {
+			if msg.Header == nil {
+				msg.Header = make(map[string][]string)
+			}
+			msg.Header[name] = strings.Split(value, "\n")
+		}
+	}
+	return msg
+}
+
+// JetStreamMeta is the metadata of a message delivered by a JetStream consumer, which
+// is encoded in the reply subject used to ack the message.
+type JetStreamMeta struct {
+	Domain           string
+	Stream           string
+	Consumer         string
+	Delivered        uint64
+	Sequence         uint64
+	ConsumerSequence uint64
+	Timestamp        time.Time
+	Pending          uint64
+}
+
+// ParseJetStream parses the metadata of a JetStream message from its reply subject,
+// returning false if the reply subject is not a JetStream ack subject. Both the
+// original $JS.ACK.<stream>.<consumer>.<delivered>.<sseq>.<cseq>.<ts>.<pending> form and
+// the newer form that includes the domain and account hash are supported.
+func ParseJetStream(reply string) (meta *JetStreamMeta, ok bool) {
+	tokens := strings.Split(reply, ".")
+	if len(tokens) < 9 || tokens[0] != "$JS" || tokens[1] != "ACK" {
+		return nil, false
+	}
+
+	meta = &JetStreamMeta{}
+	if len(tokens) == 9 {
+		tokens = tokens[2:]
+	} else {
+		if len(tokens) < 11 {
+			return nil, false
+		}
+
+		if tokens[2] != "_" {
+			meta.Domain = tokens[2]
+		}
+		tokens = tokens[4:]
+	}
+
+	meta.Stream, meta.Consumer = tokens[0], tokens[1]
+
+	var err error
+	nums := []*uint64{&meta.Delivered, &meta.Sequence, &meta.ConsumerSequence}
+	for i, num := range nums {
+		if *num, err = strconv.ParseUint(tokens[2+i], 10, 64); err != nil {
+			return nil, false
+		}
+	}
+
+	var ts int64
+	if ts, err = strconv.ParseInt(tokens[5], 10, 64); err != nil {
+		return nil, false
+	}
+	meta.Timestamp = time.Unix(0, ts).UTC()
+
+	if meta.Pending, err = strconv.ParseUint(tokens[6], 10, 64); err != nil {
+		return nil, false
+	}
+	return meta, true
+}
@@ -0,0 +1,201 @@
+package natsbridge_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/natsbridge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	msg := &natsbridge.Msg{
+		Subject: "orders.created",
+		Reply:   "_INBOX.abc",
+		Header:  map[string][]string{"Content-Type": {"application/json"}, "X-Tags": {"a", "b"}},
+		Data:    []byte(`{"order":42}`),
+	}
+
+	event := natsbridge.ToEnsign(msg)
+	require.Equal(t, msg.Data, event.Data)
+	require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+	require.False(t, event.Created.IsZero())
+	require.Equal(t, ensign.Metadata{
+		natsbridge.SubjectKey:                    "orders.created",
+		natsbridge.ReplyKey:                      "_INBOX.abc",
+		natsbridge.HeaderPrefix + "Content-Type": "application/json",
+		natsbridge.HeaderPrefix + "X-Tags":       "a\nb",
+	}, event.Metadata)
+
+	require.Equal(t, msg, natsbridge.FromEnsign(event))
+
+	// Messages without headers use the default mimetype
+	event = natsbridge.ToEnsign(&natsbridge.Msg{Subject: "orders.created", Data: []byte{0x1}})
+	require.Equal(t, mimetype.ApplicationOctetStream, event.Mimetype)
+	require.Equal(t, ensign.Metadata{natsbridge.SubjectKey: "orders.created"}, event.Metadata)
+	require.Nil(t, natsbridge.FromEnsign(event).Header)
+}
+
+func TestConvertJetStream(t *testing.T) {
+	msg := &natsbridge.Msg{
+		Subject: "orders.created",
+		Reply:   "$JS.ACK.ORDERS.mirror.1.42.7.1692016263000000000.3",
+		Data:    []byte("hello"),
+	}
+
+	event := natsbridge.ToEnsign(msg)
+	require.Equal(t, "ORDERS", event.Metadata.Get(natsbridge.StreamKey))
+	require.Equal(t, "mirror", event.Metadata.Get(natsbridge.ConsumerKey))
+	require.Equal(t, "42", event.Metadata.Get(natsbridge.SequenceKey))
+	require.Equal(t, time.Date(2023, 8, 14, 12, 31, 3, 0, time.UTC), event.Created)
+}
+
+func TestParseJetStream(t *testing.T) {
+	meta, ok := natsbridge.ParseJetStream("$JS.ACK.ORDERS.mirror.2.42.7.1692016263000000000.3")
+	require.True(t, ok)
+	require.Equal(t, &natsbridge.JetStreamMeta{
+		Stream:           "ORDERS",
+		Consumer:         "mirror",
+		Delivered:        2,
+		Sequence:         42,
+		ConsumerSequence: 7,
+		Timestamp:        time.Date(2023, 8, 14, 12, 31, 3, 0, time.UTC),
+		Pending:          3,
+	}, meta)
+
+	// The newer format includes the domain and account hash
+	meta, ok = natsbridge.ParseJetStream("$JS.ACK.hub.ACCHASH.ORDERS.mirror.2.42.7.1692016263000000000.3.RANDOM")
+	require.True(t, ok)
+	require.Equal(t, "hub", meta.Domain)
+	require.Equal(t, "ORDERS", meta.Stream)
+	require.Equal(t, uint64(42), meta.Sequence)
+
+	meta, ok = natsbridge.ParseJetStream("$JS.ACK._.ACCHASH.ORDERS.mirror.2.42.7.1692016263000000000.3")
+	require.True(t, ok)
+	require.Empty(t, meta.Domain)
+
+	for _, reply := range []string{"", "_INBOX.abc", "$JS.ACK.ORDERS.mirror", "$JS.ACK.ORDERS.mirror.a.42.7.1692016263000000000.3", "$JS.ACK.ORDERS.mirror.2.42.7.1692016263000000000.3.1"} {
+		_, ok = natsbridge.ParseJetStream(reply)
+		require.False(t, ok, "expected %q not to be parsed", reply)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	// Nack events that have no data, record the topics and keys of the others
+	var (
+		mu     sync.Mutex
+		topics []string
+		keys   []string
+	)
+
+	handler := mock.NewPublishHandler(nil)
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		event, err := in.Unwrap()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(event.Data) == 0 {
+			return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_UNPROCESSED}}}, nil
+		}
+
+		mu.Lock()
+		topics = append(topics, string(in.TopicId))
+		keys = append(keys, string(in.Key))
+		mu.Unlock()
+		return &api.PublisherReply{Embed: &api.PublisherReply_Ack{Ack: &api.Ack{Id: in.LocalId}}}, nil
+	}
+	emock.OnPublish = handler.OnPublish
+
+	client, err := ensign.New(ensign.WithMock(emock), ensign.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	var acked []string
+	msgs := []*natsbridge.Msg{
+		{Subject: "orders.created", Data: []byte("1")},
+		{Subject: "orders.shipped", Data: []byte("2")},
+		{Subject: "orders.created", Data: nil},
+		{Subject: "orders.deleted", Data: []byte("4")},
+	}
+
+	for _, msg := range msgs {
+		msg := msg
+		msg.Ack = func() error {
+			acked = append(acked, string(msg.Data))
+			return nil
+		}
+	}
+
+	source := func() natsbridge.Source {
+		i := 0
+		return natsbridge.SourceFunc(func(ctx context.Context) (*natsbridge.Msg, error) {
+			if i >= len(msgs) {
+				return nil, io.EOF
+			}
+			i++
+			return msgs[i-1], nil
+		})
+	}
+
+	mapper := func(subject string) string {
+		switch subject {
+		case "orders.created":
+			return "01GWM89049D49FHJH81BT8795H"
+		default:
+			return "01H1PA4FA9G2Y79Z5FC36CWYYJ"
+		}
+	}
+
+	// By default the relay stops on the nacked event
+	relay := natsbridge.NewRelay(client, "01GWM89049D49FHJH81BT8795H", natsbridge.WithTopicMapper(mapper), natsbridge.WithSubjectKeys())
+	err = relay.Run(context.Background(), source())
+
+	var nack *ensign.NackError
+	require.ErrorAs(t, err, &nack)
+	require.Equal(t, api.Nack_UNPROCESSED, nack.Code)
+	require.Equal(t, []string{"1", "2"}, acked, "expected messages to be acked once mirrored")
+	require.Equal(t, []string{"orders.created", "orders.shipped"}, keys)
+	require.Len(t, topics, 2)
+	require.NotEqual(t, topics[0], topics[1], "expected subjects to be mapped to topics")
+
+	// The error handler can skip messages that cannot be relayed
+	acked, keys = nil, nil
+	var skipped []*natsbridge.Msg
+	relay = natsbridge.NewRelay(client, "01GWM89049D49FHJH81BT8795H", natsbridge.WithErrorHandler(func(msg *natsbridge.Msg, err error) error {
+		skipped = append(skipped, msg)
+		return nil
+	}))
+
+	require.NoError(t, relay.Run(context.Background(), source()))
+	require.Equal(t, []string{"1", "2", "4"}, acked)
+	require.Equal(t, []*natsbridge.Msg{msgs[2]}, skipped)
+	require.Equal(t, []string{"", "", ""}, keys, "expected no keys without subject keys")
+
+	// Source errors stop the relay
+	failure := errors.New("subscription closed")
+	err = relay.Run(context.Background(), natsbridge.SourceFunc(func(context.Context) (*natsbridge.Msg, error) {
+		return nil, failure
+	}))
+	require.ErrorIs(t, err, failure)
+
+	// The relay stops when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = relay.Run(ctx, natsbridge.SourceFunc(func(ctx context.Context) (*natsbridge.Msg, error) {
+		<-ctx.Done()
+		return nil, errors.New("timeout")
+	}))
+	require.ErrorIs(t, err, context.Canceled)
+}
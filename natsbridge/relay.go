@@ -0,0 +1,135 @@
+package natsbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rotationalio/go-ensign"
+)
+
+// Source returns the next message received on a NATS subscription, blocking until a
+// message is available or the context is done. A *nats.Subscription can be adapted
+// using NextMsgWithContext; returning io.EOF stops the relay without an error.
+type Source interface {
+	NextMsg(ctx context.Context) (*Msg, error)
+}
+
+// SourceFunc adapts a function to the Source interface.
+type SourceFunc func(ctx context.Context) (*Msg, error)
+
+// NextMsg calls the function.
+func (f SourceFunc) NextMsg(ctx context.Context) (*Msg, error) {
+	return f(ctx)
+}
+
+// Relay mirrors the messages received from a NATS source into Ensign topics. Messages
+// are published in the order they are received. If a message has an Ack function, the
+// relay waits for Ensign to ack the event before acking the message so that JetStream
+// redelivers messages that could not be mirrored.
+type Relay struct {
+	pub     ensign.Publisher
+	topic   func(subject string) string
+	keys    bool
+	onError func(msg *Msg, err error) error
+}
+
+// Option configures a Relay.
+type Option func(r *Relay)
+
+// WithTopicMapper maps the subject of each message to the Ensign topic it is published
+// to, e.g. to mirror several subjects of a wildcard subscription into separate topics.
+func WithTopicMapper(mapper func(subject string) string) Option {
+	return func(r *Relay) {
+		r.topic = mapper
+	}
+}
+
+// WithSubjectKeys sets the partition key of each event to the subject of the message
+// so that the events of a subject are kept in order on topics that are sharded with
+// the CONSISTENT_KEY_HASH strategy, as they are in a NATS stream.
+func WithSubjectKeys() Option {
+	return func(r *Relay) {
+		r.keys = true
+	}
+}
+
+// WithErrorHandler is called when a message cannot be published or is nacked by
+// Ensign. If the handler returns nil the relay continues with the next message,
+// otherwise the relay stops and returns the error. By default the relay stops on the
+// first error.
+func WithErrorHandler(handler func(msg *Msg, err error) error) Option {
+	return func(r *Relay) {
+		r.onError = handler
+	}
+}
+
+// NewRelay creates a relay that publishes messages to the specified topic name or ID
+// unless a topic mapper is specified.
+func NewRelay(pub ensign.Publisher, topic string, opts ...Option) *Relay {
+	relay := &Relay{
+		pub:     pub,
+		topic:   func(string) string { return topic },
+		onError: func(_ *Msg, err error) error { return err },
+	}
+
+	for _, opt := range opts {
+		opt(relay)
+	}
+	return relay
+}
+
+// Run relays messages from the source until the context is done, the source returns
+// io.EOF, or a message cannot be relayed and the error handler returns an error. If the
+// context is done, the context error is returned.
+func (r *Relay) Run(ctx context.Context, src Source) (err error) {
+	for {
+		var msg *Msg
+		if msg, err = src.NextMsg(ctx); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if err = r.relay(ctx, msg); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+
+			if err = r.onError(msg, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Publish the message to Ensign, acking it once the event is acked if required.
+func (r *Relay) relay(ctx context.Context, msg *Msg) (err error) {
+	event := ToEnsign(msg)
+	if r.keys {
+		event.Key = []byte(msg.Subject)
+	}
+
+	if err = r.pub.PublishContext(ctx, r.topic(msg.Subject), event); err != nil {
+		return err
+	}
+
+	if msg.Ack == nil {
+		return nil
+	}
+
+	if _, err = event.WaitForAck(ctx); err != nil {
+		return err
+	}
+
+	if err = msg.Ack(); err != nil {
+		return fmt.Errorf("could not ack nats message: %w", err)
+	}
+	return nil
+}
@@ -1,12 +1,27 @@
 package ensign
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strings"
-
-	"github.com/rotationalio/go-ensign/mock"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/auth"
+	"github.com/rotationalio/go-ensign/backoff"
+	"github.com/rotationalio/go-ensign/schemas"
+	"github.com/rotationalio/go-ensign/stream"
+	"github.com/rotationalio/go-ensign/topics"
+	"github.com/rotationalio/go-ensign/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
 )
 
 // Environment variables for configuring Ensign. Unless otherwise specified in the
@@ -57,34 +72,34 @@ const (
 
 // WithLoadCredentials loads the Ensign API Key information from the JSON file that was
 // download from the Rotational web application. Pass in the path to the credentials on
-// disk to load them with this option!
+// disk to load them with this option! If the file is malformed, e.g. the secret was
+// truncated or the file is not a credentials file, a *CredentialsError is returned that
+// names the path and field and describes how to fix the problem.
 func WithLoadCredentials(path string) Option {
 	return func(o *Options) (err error) {
-		var f *os.File
-		if f, err = os.Open(path); err != nil {
+		var creds *Credentials
+		if creds, err = LoadCredentials(path); err != nil {
 			return err
 		}
-		defer f.Close()
 
-		data := make(map[string]interface{})
-		if err = json.NewDecoder(f).Decode(&data); err != nil {
-			return err
-		}
-
-		// Fetch and parse clientID
-		if val, ok := data[keyClientID]; ok {
-			if clientID, ok := val.(string); ok && clientID != "" {
-				o.ClientID = clientID
-			}
-		}
+		o.ClientID = creds.ClientID
+		o.ClientSecret = creds.ClientSecret
+		return nil
+	}
+}
 
-		// Fetch and parse clientSecret
-		if val, ok := data[keyClientSecret]; ok {
-			if clientSecret, ok := val.(string); ok && clientSecret != "" {
-				o.ClientSecret = clientSecret
-			}
+// WithReadCredentials reads the Ensign API Key information in the JSON format of the
+// credentials file downloaded from the Rotational web application from the reader,
+// which is useful for embedding credentials in other tools.
+func WithReadCredentials(r io.Reader) Option {
+	return func(o *Options) (err error) {
+		var creds *Credentials
+		if creds, err = ReadCredentials(r); err != nil {
+			return err
 		}
 
+		o.ClientID = creds.ClientID
+		o.ClientSecret = creds.ClientSecret
 		return nil
 	}
 }
@@ -94,7 +109,8 @@ func WithLoadCredentials(path string) Option {
 // a mock in local tests. Ensign developers may also use this to connect to staging.
 // If any gRPC dial options are specified, they override the default Ensign dial options
 // including the interceptors that perform authentication -- use only if you know what
-// you're doing and why!
+// you're doing and why! To add interceptors to the client while keeping the default
+// dial options, use WithUnaryInterceptor and WithStreamInterceptor instead.
 func WithEnsignEndpoint(endpoint string, insecure bool, opts ...grpc.DialOption) Option {
 	return func(o *Options) error {
 		o.Endpoint = endpoint
@@ -104,6 +120,34 @@ func WithEnsignEndpoint(endpoint string, insecure bool, opts ...grpc.DialOption)
 	}
 }
 
+// WithEnsignEndpoints connects the client to multiple Ensign nodes, balancing RPCs and
+// streams across the nodes and failing over to the remaining nodes when a node is
+// unavailable (see Discovery). Endpoints are host:port addresses; the port defaults to
+// 443. Unless a service config is specified with WithServiceConfigJSON, the round robin
+// load balancing policy is used.
+func WithEnsignEndpoints(insecure bool, endpoints ...string) Option {
+	return func(o *Options) error {
+		if len(endpoints) == 0 {
+			return ErrMissingEndpoint
+		}
+		o.Discovery = StaticEndpoints(endpoints...)
+		o.Insecure = insecure
+		return nil
+	}
+}
+
+// WithEnsignDiscovery connects the client to the Ensign nodes returned by the discovery,
+// e.g. DiscoverSRV to look up the nodes in a DNS SRV record, balancing RPCs and streams
+// across the nodes like WithEnsignEndpoints. The discovery is run again when the
+// connection to a node fails so that the client follows changes to the nodes.
+func WithEnsignDiscovery(discover Discovery, insecure bool) Option {
+	return func(o *Options) error {
+		o.Discovery = discover
+		o.Insecure = insecure
+		return nil
+	}
+}
+
 // WithAuthenticator specifies a different Quarterdeck URL or you can supply an empty
 // string and noauth set to true to have no authentication occur with the Ensign client.
 func WithAuthenticator(url string, noauth bool) Option {
@@ -114,6 +158,488 @@ func WithAuthenticator(url string, noauth bool) Option {
 	}
 }
 
+// WithPublishQuota sets soft and hard limits on the number of events and bytes that
+// the client can publish in a single publish stream session. When the soft limit is
+// reached the quota's OnWarning hook is called; when the hard limit is reached the
+// publisher is paused and Publish returns an error until ResumePublishing is called.
+func WithPublishQuota(quota stream.Quota) Option {
+	return func(o *Options) error {
+		o.PublishQuota = quota
+		return nil
+	}
+}
+
+// WithPublishIdleTimeout closes the client's publish stream when no events have been
+// published for the specified duration, which frees server resources for services that
+// publish infrequently. The stream is lazily reopened the next time Publish is called.
+// By default the publish stream is held open until the client is closed.
+func WithPublishIdleTimeout(timeout time.Duration) Option {
+	return func(o *Options) error {
+		o.PublishIdleTimeout = timeout
+		return nil
+	}
+}
+
+// WithPublishAckTimeout nacks published events with the TIMEOUT code if the server does
+// not ack or nack them within the timeout, so that Event.Acked and Event.WaitForAck do
+// not wait forever for replies that are lost. By default events wait for a reply from
+// the server until the client is closed.
+func WithPublishAckTimeout(timeout time.Duration) Option {
+	return func(o *Options) error {
+		o.PublishAckTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxEventSize sets the maximum size in bytes of a published event so that Publish
+// returns ErrEventTooLarge for larger events rather than sending events that the server
+// will reject; use PublishChunked to publish events with larger payloads. By default the
+// limit is the maximum send message size of the client (see WithMaxMsgSize) or
+// stream.DefaultMaxEventSize. A negative size disables the check.
+func WithMaxEventSize(size int) Option {
+	return func(o *Options) error {
+		o.MaxEventSize = size
+		return nil
+	}
+}
+
+// WithPublishTopics restricts the client's publish stream to the specified topic names
+// or IDs so that the server rejects events published to any other topic, e.g. to guard
+// against a service publishing to the wrong topic. By default the publish stream is
+// allowed to publish to all of the topics that the API key has access to.
+func WithPublishTopics(topics ...string) Option {
+	return func(o *Options) error {
+		o.PublishTopics = topics
+		return nil
+	}
+}
+
+// WithContentKeys sets the key of each published event to the hash of the event payload
+// so that topics with the DATAGRAM or UNIQUE_KEY deduplication policies can identify
+// duplicate events using deterministic keys, without publishers computing their own.
+// Events that have a partition key (see Event.Key) keep their key. Because the key is
+// also used to shard the topic, events with identical payloads are assigned the same
+// shard. By default events are published without a key.
+func WithContentKeys(algorithm ContentHash) Option {
+	return func(o *Options) error {
+		if algorithm > SHA256 {
+			return ErrInvalidContentHash
+		}
+		o.ContentKeys = algorithm
+		return nil
+	}
+}
+
+// WithPublishResend republishes events that were sent but not acked or nacked when the
+// publish stream goes down once the stream reconnects; by default the replies to these
+// events are lost and they are never acked. Published events are given a unique key in
+// their metadata (see IdempotencyKey) so that republished events can be deduplicated.
+func WithPublishResend() Option {
+	return func(o *Options) error {
+		o.PublishResend = true
+		return nil
+	}
+}
+
+// WithReadOnly puts the client into read-only mode, which is useful for dashboards and
+// debugging tools that should never mutate topics or publish events. In read-only mode
+// all mutating calls (e.g. CreateTopic, ArchiveTopic, DestroyTopic, setting topic
+// policies, and Publish) return ErrReadOnlyClient without making an RPC. Subscriptions
+// are opened in an inspect mode where events cannot be acked or nacked so that
+// consumer group offsets are not modified.
+func WithReadOnly() Option {
+	return func(o *Options) error {
+		o.ReadOnly = true
+		return nil
+	}
+}
+
+// WithTopicCache uses the specified topic cache to resolve and cache topic names to
+// topic IDs so that the client and user code share the same view of the topics in the
+// project. The cache backs TopicID, topic name resolution in Publish, and is updated
+// with the topic names and IDs returned by the server when publish and subscribe
+// streams are opened. If the cache was created without a client, the Ensign client is
+// used to lookup topics that are not in the cache. The cache is safe for concurrent use
+// but should only be shared by clients that are connected to the same project.
+func WithTopicCache(cache *topics.Cache) Option {
+	return func(o *Options) error {
+		o.TopicCache = cache
+		return nil
+	}
+}
+
+// WithBackoff specifies the backoff policy used when retrying operations such as
+// waiting for a dropped connection to be re-established by the publish and subscribe
+// streams or waiting for the authentication service to be ready. By default an
+// exponential backoff with jitter is used that stops retrying after 5 minutes. If a
+// policy is specified, streams stop reconnecting when the policy stops retrying rather
+// than after stream.ReconnectTimeout, so a policy that never stops retries forever.
+func WithBackoff(policy backoff.Policy) Option {
+	return func(o *Options) error {
+		o.Backoff = policy
+		return nil
+	}
+}
+
+// WithBackoffConfig specifies the backoff policy of the client (see WithBackoff) from
+// the intervals, multiplier, jitter and limits of an exponential backoff, returning an
+// error if the configuration is invalid. Use backoff.DefaultConfig as a starting point.
+func WithBackoffConfig(conf backoff.Config) Option {
+	return func(o *Options) error {
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+		o.Backoff = conf.Policy()
+		return nil
+	}
+}
+
+// WithClientName specifies a stable, human-readable name (e.g. the name of the service)
+// that is used to identify the publish and subscribe streams opened by the client on
+// the Ensign server. Each stream's client ID is the name suffixed with a unique instance
+// ULID. Note that this is not the same as the ClientID of the API key credentials.
+func WithClientName(name string) Option {
+	return func(o *Options) error {
+		o.ClientName = name
+		return nil
+	}
+}
+
+// WithWarmupSubscriber configures Warmup to open and close a subscription to the
+// warmed up topics to verify that the client is able to subscribe to them at startup.
+func WithWarmupSubscriber() Option {
+	return func(o *Options) error {
+		o.WarmupSubscriber = true
+		return nil
+	}
+}
+
+// WithLogger configures the client to report stream reconnects, dropped events, and
+// unhandled messages from the server to the specified logger. By default the client
+// does not log; a *slog.Logger can be passed directly as the logger.
+func WithLogger(logger Logger) Option {
+	return func(o *Options) error {
+		o.Logger = logger
+		return nil
+	}
+}
+
+// WithStreamReadyHook registers a function that is called with the stream info sent
+// by the Ensign server every time a publish or subscribe stream is opened or reopened
+// so that applications can log and verify the negotiated stream parameters, e.g. the
+// node that the stream is connected to after a reconnect.
+func WithStreamReadyHook(hook stream.ReadyHook) Option {
+	return func(o *Options) error {
+		o.OnStreamReady = hook
+		return nil
+	}
+}
+
+// WithConnectionHook registers a function that is called when the connection of the
+// client and the publish and subscribe streams opened by the client are connected, go
+// down and start reconnecting, are reconnected, or fail with a fatal error, so that
+// orchestration code can react to connection failures (e.g. by reporting the service
+// as unhealthy) rather than polling ConnState. The source of each event identifies the
+// client connection or stream that changed state; clients that use a mock only report
+// stream events. The hook is called synchronously so it should return quickly and must
+// not close the client.
+func WithConnectionHook(hook stream.ConnectionHook) Option {
+	return func(o *Options) error {
+		o.OnConnection = hook
+		return nil
+	}
+}
+
+// WithTracerProvider traces publishing, subscribe event handling, RPCs to Ensign, and
+// authentication requests to Quarterdeck with spans created by the tracer provider.
+// If a propagator is specified, the trace context of the publish span is injected
+// into the metadata of each published event and is extracted by subscribers so that
+// consumers continue the trace. By default the client is not traced.
+func WithTracerProvider(provider trace.TracerProvider, propagator trace.Propagator) Option {
+	return func(o *Options) error {
+		o.TracerProvider = provider
+		o.TracePropagator = propagator
+		return nil
+	}
+}
+
+// WithTokenCache persists the access and refresh tokens from Quarterdeck in the cache
+// so that the client can reuse them across process restarts rather than reauthenticate
+// every time it is created, e.g. using auth.NewFileTokenCache. Ignored if the client
+// is not authenticated.
+func WithTokenCache(cache auth.TokenCache) Option {
+	return func(o *Options) error {
+		o.TokenCache = cache
+		return nil
+	}
+}
+
+// WithTokenRefresh renews the access token from Quarterdeck in the background the
+// window before it expires, so that RPCs made right after the access token expires do
+// not have to wait for the token to be refreshed. The window should be shorter than the
+// lifetime of the access token. Ignored if the client is not authenticated.
+func WithTokenRefresh(window time.Duration) Option {
+	return func(o *Options) error {
+		if window < 0 {
+			return ErrInvalidRefreshWindow
+		}
+		o.TokenRefreshWindow = window
+		return nil
+	}
+}
+
+// WithTokenRefreshHook registers a hook that is called with the expiration of the new
+// access token (or the error) every time the client renews its access token, e.g. to
+// log or monitor token refreshes. The hook must not block.
+func WithTokenRefreshHook(hook auth.RefreshHook) Option {
+	return func(o *Options) error {
+		o.OnTokenRefresh = hook
+		return nil
+	}
+}
+
+// WithTokenSource uses the token source to get the access tokens for Ensign RPCs instead
+// of authenticating with Quarterdeck using the client ID and secret, e.g. to use tokens
+// minted by your own identity provider. When a token source is specified the client ID
+// and secret are not required and the token cache is not used.
+func WithTokenSource(source auth.TokenSource) Option {
+	return func(o *Options) error {
+		o.TokenSource = source
+		return nil
+	}
+}
+
+// WithMaxStreams limits the number of publish and subscribe streams that the client and
+// its clones may have open at the same time, e.g. as a safety net for frameworks that
+// create subscriptions dynamically. Once the limit is reached, opening a stream returns
+// ErrTooManyStreams until another stream is closed. Zero (the default) is unlimited.
+func WithMaxStreams(max int) Option {
+	return func(o *Options) error {
+		if max < 0 {
+			return ErrInvalidMaxStreams
+		}
+		o.MaxStreams = max
+		return nil
+	}
+}
+
+// WithUnaryInterceptor chains the interceptors to the unary RPCs made by the client,
+// e.g. to add metadata or to log requests. The interceptors are called after the auth
+// interceptors in the order they are specified and do not replace the default dial
+// options. The option can be specified multiple times to add more interceptors.
+func WithUnaryInterceptor(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *Options) error {
+		o.UnaryInterceptors = append(o.UnaryInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithStreamInterceptor chains the interceptors to the streaming RPCs opened by the
+// client such as publish and subscribe streams. The interceptors are called after the
+// auth interceptors in the order they are specified and do not replace the default dial
+// options. The option can be specified multiple times to add more interceptors.
+func WithStreamInterceptor(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *Options) error {
+		o.StreamInterceptors = append(o.StreamInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithTLSConfig uses the TLS configuration to connect to both Ensign and Quarterdeck,
+// e.g. to verify the servers with a custom CA pool in enterprise deployments. The TLS
+// configuration is ignored if the client is insecure or if gRPC dial options are
+// specified with WithEnsignEndpoint (in which case only Quarterdeck uses it).
+func WithTLSConfig(conf *tls.Config) Option {
+	return func(o *Options) error {
+		o.TLSConfig = conf.Clone()
+		return nil
+	}
+}
+
+// WithClientCertificate loads the PEM encoded certificate and key from the files and
+// presents the certificate to Ensign and Quarterdeck for mutual TLS authentication. If
+// caFile is not empty, the servers are verified using the certificates in the CA file
+// rather than the system roots. The certificate is added to the TLS configuration
+// specified by WithTLSConfig if the option is specified first.
+func WithClientCertificate(certFile, keyFile, caFile string) Option {
+	return func(o *Options) (err error) {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return fmt.Errorf("could not load client certificate: %w", err)
+		}
+
+		conf := &tls.Config{MinVersion: tls.VersionTLS12}
+		if o.TLSConfig != nil {
+			conf = o.TLSConfig.Clone()
+		}
+		conf.Certificates = append(conf.Certificates, cert)
+
+		if caFile != "" {
+			var pem []byte
+			if pem, err = os.ReadFile(caFile); err != nil {
+				return fmt.Errorf("could not read ca file: %w", err)
+			}
+
+			conf.RootCAs = x509.NewCertPool()
+			if !conf.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("%w: %s", ErrNoCertificates, caFile)
+			}
+		}
+
+		o.TLSConfig = conf
+		return nil
+	}
+}
+
+// WithProxy connects to Ensign and Quarterdeck through the proxy at the URL, e.g. from
+// a corporate network that only allows outbound connections through a proxy. HTTP and
+// HTTPS proxies (tunneling with CONNECT) and SOCKS5 proxies are supported; credentials
+// for the proxy can be specified as the user info of the URL. By default, the proxy is
+// determined by the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables.
+func WithProxy(proxyURL string) Option {
+	return func(o *Options) (err error) {
+		var u *url.URL
+		if u, err = url.Parse(proxyURL); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidProxy, err)
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("%w: unsupported scheme %q", ErrInvalidProxy, u.Scheme)
+		}
+
+		o.Proxy = u
+		return nil
+	}
+}
+
+// WithKeepalive pings the Ensign server when the connection is idle so that long-lived
+// publish and subscribe streams are not torn down by load balancers and proxies. The
+// client pings after the interval of inactivity and closes the connection if the ping is not
+// acknowledged within timeout; if permitWithoutStream is true, pings are sent even if
+// there are no open streams. Note that the server may close connections that ping more
+// frequently than its keepalive enforcement policy allows.
+func WithKeepalive(interval, timeout time.Duration, permitWithoutStream bool) Option {
+	return func(o *Options) error {
+		o.Keepalive = &keepalive.ClientParameters{
+			Time:                interval,
+			Timeout:             timeout,
+			PermitWithoutStream: permitWithoutStream,
+		}
+		return nil
+	}
+}
+
+// WithMaxMsgSize sets the maximum size in bytes of messages that the client can receive
+// and send, e.g. to publish or subscribe to events with large payloads. Zero uses the
+// gRPC default for that direction.
+func WithMaxMsgSize(recv, send int) Option {
+	return func(o *Options) error {
+		if recv < 0 || send < 0 {
+			return ErrInvalidMsgSize
+		}
+		o.MaxRecvMsgSize = recv
+		o.MaxSendMsgSize = send
+		return nil
+	}
+}
+
+// WithWindowSize sets the initial flow control window sizes in bytes of the streams and
+// of the connection of the client. Larger windows can improve the throughput of publish
+// and subscribe streams on high latency connections. Zero uses the gRPC default; gRPC
+// ignores window sizes that are less than 64KB.
+func WithWindowSize(stream, conn int32) Option {
+	return func(o *Options) error {
+		if stream < 0 || conn < 0 {
+			return ErrInvalidWindowSize
+		}
+		o.InitialWindowSize = stream
+		o.InitialConnWindowSize = conn
+		return nil
+	}
+}
+
+// WithRetries retries idempotent unary RPCs such as ListTopics, TopicNames, Info, and
+// Status when they fail with a transient error (by default Unavailable) so that the
+// error does not bubble straight up to application code. RPCs that modify state, e.g.
+// CreateTopic, are never retried. Zero values of the retry options are replaced by the
+// defaults; by default RPCs are not retried.
+func WithRetries(retries RetryOptions) Option {
+	return func(o *Options) error {
+		if retries.MaxAttempts < 0 {
+			return ErrInvalidRetryAttempts
+		}
+
+		if retries.MaxAttempts == 0 {
+			retries.MaxAttempts = DefaultRetryAttempts
+		}
+
+		if len(retries.Codes) == 0 {
+			retries.Codes = DefaultRetryCodes
+		}
+
+		o.Retries = &retries
+		return nil
+	}
+}
+
+// WithSchemaRegistry validates the payloads of events that have an event type against
+// the schemas in the registry. Events that do not match their schema are not published
+// and Publish returns an error wrapping schemas.ErrInvalidPayload. Received events that
+// do not match their schema are nacked and are not delivered to the consumer. Events
+// without a type are not validated.
+func WithSchemaRegistry(registry *schemas.Registry) Option {
+	return func(o *Options) error {
+		o.Schemas = registry
+		return nil
+	}
+}
+
+// WithSigningKey signs the events published by the client with the secret so that
+// subscribers that share the secret can verify that events were published by the
+// client, e.g. to prove the provenance of events in topics with multiple publishers.
+// Events are signed with HMAC-SHA256 and the key ID is sent with the signature so that
+// subscribers can look up the secret (see WithVerifySignatures).
+func WithSigningKey(keyID string, secret []byte) Option {
+	return func(o *Options) error {
+		if keyID == "" || len(secret) == 0 {
+			return ErrInvalidSigningKey
+		}
+
+		o.SigningKeyID = keyID
+		o.SigningKey = secret
+		return nil
+	}
+}
+
+// WithResolver registers gRPC resolver builders that are used to resolve the Ensign
+// endpoint when the client connects, e.g. to integrate with custom service discovery.
+// The endpoint specified by WithEnsignEndpoint should use the scheme of one of the
+// resolvers (e.g. "discovery:///ensign"). The resolvers are added to the default dialing
+// options so the authentication interceptors are not affected.
+func WithResolver(builders ...resolver.Builder) Option {
+	return func(o *Options) error {
+		o.Resolvers = append(o.Resolvers, builders...)
+		return nil
+	}
+}
+
+// WithServiceConfigJSON specifies the default gRPC service config used by the client
+// connection, e.g. to configure the load balancing policy used with a custom resolver.
+// An error is returned if the service config is not valid JSON. The service config is
+// added to the default dialing options so the authentication interceptors are not
+// affected.
+func WithServiceConfigJSON(cfg string) Option {
+	return func(o *Options) error {
+		if !json.Valid([]byte(cfg)) {
+			return ErrInvalidServiceConfig
+		}
+		o.ServiceConfig = cfg
+		return nil
+	}
+}
+
 // WithOptions sets the options to the passed in options value. Note that this will
 // override everything in the processing chain including zero-valued items; so use this
 // as the first variadic option in NewOptions to guarantee correct processing.
@@ -124,8 +650,18 @@ func WithOptions(opts Options) Option {
 	}
 }
 
-// WithMock connects ensign to the specified mock ensign server for local testing.
-func WithMock(mock *mock.Ensign, opts ...grpc.DialOption) Option {
+// Dialer creates the Ensign API client in testing mode, e.g. the *mock.Ensign server in
+// the mock package, which connects the client to the mock using an in-memory buffer.
+// The dial options specified with WithMock are passed to the dialer. Using a dialer
+// interface ensures that the mock and its dependencies are only compiled into binaries
+// that import the mock package (usually only tests).
+type Dialer interface {
+	Client(ctx context.Context, opts ...grpc.DialOption) (api.EnsignClient, error)
+}
+
+// WithMock connects ensign to the specified mock ensign server for local testing. Any
+// Dialer can be used as the mock, though usually this is a *mock.Ensign.
+func WithMock(mock Dialer, opts ...grpc.DialOption) Option {
 	return func(o *Options) error {
 		o.Testing = true
 		o.Mock = mock
@@ -150,11 +686,38 @@ type Options struct {
 	// The gRPC endpoint of the Ensign service; by default the EnsignEndpoint.
 	Endpoint string
 
+	// Discovers the Ensign nodes to connect to instead of the Endpoint, balancing the
+	// connection across the nodes.
+	Discovery Discovery
+
 	// Dial options allows the user to specify gRPC connection options if necessary.
 	// NOTE: use with care, this overrides the default dialing options including the
 	// interceptors for authentication!
 	Dialing []grpc.DialOption
 
+	// The TLS configuration used to connect to Ensign and Quarterdeck if not insecure.
+	TLSConfig *tls.Config
+
+	// The proxy used to connect to Ensign and Quarterdeck instead of the environment.
+	Proxy *url.URL
+
+	// Connection tuning options that are applied without clobbering the dial options.
+	Keepalive             *keepalive.ClientParameters
+	MaxRecvMsgSize        int
+	MaxSendMsgSize        int
+	InitialWindowSize     int32
+	InitialConnWindowSize int32
+
+	// User interceptors that are chained after the auth interceptors.
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
+
+	// Resolvers and the default service config are added to the dialing options to
+	// customize how the endpoint is resolved and load balanced without requiring the
+	// default dialing options to be overridden.
+	Resolvers     []resolver.Builder
+	ServiceConfig string
+
 	// The URL of the Quarterdeck system for authentication; by default AuthEndpoint.
 	AuthURL string
 
@@ -165,11 +728,88 @@ type Options struct {
 	// tokens from Ensign RPCs. This is primarily used for testing against mocks.
 	NoAuthentication bool
 
+	// If true, the client will not perform any mutating operations such as creating
+	// topics or publishing events; those methods will return ErrReadOnlyClient.
+	ReadOnly bool
+
+	// Limits the number of events and bytes published per publish stream session,
+	// warning when the soft limits are reached and pausing at the hard limits.
+	PublishQuota stream.Quota
+
+	// Closes the publish stream after the duration of inactivity; zero keeps it open.
+	PublishIdleTimeout time.Duration
+
+	// Nacks published events that are not replied to by the server within the duration.
+	PublishAckTimeout time.Duration
+
+	// If true, unacked events are republished after the publish stream reconnects.
+	PublishResend bool
+
+	// The maximum size of a published event; zero uses the default and negative disables.
+	MaxEventSize int
+
+	// The topic names or IDs that the publish stream is allowed to publish to.
+	PublishTopics []string
+
+	// Keys published events with the hash of their payload for deduplication.
+	ContentKeys ContentHash
+
+	// The maximum number of publish and subscribe streams open at the same time.
+	MaxStreams int
+
+	// A topic cache shared by the client and user code to map topic names to topic IDs.
+	TopicCache *topics.Cache
+
+	// Validates the payloads of published and received events against their schemas.
+	Schemas *schemas.Registry
+
+	// Signs published events with the secret, identified by the key ID.
+	SigningKeyID string
+	SigningKey   []byte
+
+	// The backoff policy used to retry reconnects and requests to the auth service.
+	Backoff backoff.Policy
+
+	// Retries idempotent unary RPCs that fail with transient errors if not nil.
+	Retries *RetryOptions
+
+	// A human-readable name used to create the client IDs of publish and subscribe
+	// streams so that the streams can be identified on the server.
+	ClientName string
+
+	// If true, Warmup verifies that the client can subscribe to the warmed up topics.
+	WarmupSubscriber bool
+
+	// Logs the activity of publish and subscribe streams; by default nothing is logged.
+	Logger Logger
+
+	// Called with the stream info every time a publish or subscribe stream is opened.
+	OnStreamReady stream.ReadyHook
+
+	// Called when the client connection or one of its streams changes connection state.
+	OnConnection stream.ConnectionHook
+
+	// Persists the tokens from Quarterdeck so they can be reused across restarts.
+	TokenCache auth.TokenCache
+
+	// Renews the access token in the background the window before it expires.
+	TokenRefreshWindow time.Duration
+
+	// Called every time the client renews its access token.
+	OnTokenRefresh auth.RefreshHook
+
+	// Supplies access tokens in place of authenticating with Quarterdeck.
+	TokenSource auth.TokenSource
+
+	// Traces the client with spans and propagates trace context in event metadata.
+	TracerProvider  trace.TracerProvider
+	TracePropagator trace.Propagator
+
 	// Mocking allows the client to be used in test code. Set testing mode to true and
-	// create a *mock.Ensign to add to the dialer. Any other dialer options can also be
-	// added to the mock for connection purposes.
+	// create a *mock.Ensign (or any other Dialer) to connect to. Any other dialer
+	// options can also be added to the mock for connection purposes.
 	Testing bool
-	Mock    *mock.Ensign
+	Mock    Dialer
 }
 
 // NewOptions instantiates an options object for configuring Ensign, sets defaults and
@@ -208,7 +848,7 @@ func (o *Options) Validate() (err error) {
 		return ErrMissingEndpoint
 	}
 
-	if !o.NoAuthentication {
+	if !o.NoAuthentication && o.TokenSource == nil {
 		if o.ClientID == "" {
 			return ErrMissingClientID
 		}
@@ -224,6 +864,53 @@ func (o *Options) Validate() (err error) {
 	return nil
 }
 
+// Returns the backoff policy from the options or an exponential backoff starting at the
+// reconnect tick by default.
+func (o *Options) backoffPolicy() backoff.Policy {
+	if o.Backoff != nil {
+		return o.Backoff
+	}
+	return backoff.Exponential(ReconnectTick, backoff.DefaultMaxInterval, backoff.DefaultMaxElapsedTime)
+}
+
+// Returns a copy of the TLS configuration from the options or an empty configuration
+// that verifies the server using the system roots by default.
+func (o *Options) tlsConfig() *tls.Config {
+	if o.TLSConfig != nil {
+		return o.TLSConfig.Clone()
+	}
+	return &tls.Config{}
+}
+
+// Returns the dial options that tune the connection as specified by the options.
+func (o *Options) tuning() (opts []grpc.DialOption) {
+	if o.Keepalive != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*o.Keepalive))
+	}
+
+	var copts []grpc.CallOption
+	if o.MaxRecvMsgSize > 0 {
+		copts = append(copts, grpc.MaxCallRecvMsgSize(o.MaxRecvMsgSize))
+	}
+
+	if o.MaxSendMsgSize > 0 {
+		copts = append(copts, grpc.MaxCallSendMsgSize(o.MaxSendMsgSize))
+	}
+
+	if len(copts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(copts...))
+	}
+
+	if o.InitialWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(o.InitialWindowSize))
+	}
+
+	if o.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(o.InitialConnWindowSize))
+	}
+	return opts
+}
+
 // Set defaults from the environment and then from any applicable constants.
 func (o *Options) setDefaults() {
 	// Set the client ID from the environment
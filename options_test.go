@@ -1,14 +1,33 @@
 package ensign_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go/build"
+	"math/big"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/auth"
+	"github.com/rotationalio/go-ensign/backoff"
 	"github.com/rotationalio/go-ensign/mock"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
 var testEnv = map[string]string{
@@ -107,6 +126,19 @@ func TestWithMock(t *testing.T) {
 	require.Len(t, opts.Dialing, 1, "expected one dial option to be set on mock")
 }
 
+func TestNoMockDependency(t *testing.T) {
+	// The mock is used via the Dialer interface so that production binaries do not
+	// depend on the mock package or testing libraries.
+	var _ sdk.Dialer = &mock.Ensign{}
+
+	pkg, err := build.ImportDir(".", 0)
+	require.NoError(t, err, "could not parse package imports")
+	for _, path := range pkg.Imports {
+		require.NotEqual(t, "github.com/rotationalio/go-ensign/mock", path, "the ensign package should not import the mock")
+		require.NotContains(t, path, "github.com/stretchr/testify", "the ensign package should not import testify")
+	}
+}
+
 func TestOptionsDefaults(t *testing.T) {
 	opts := &sdk.Options{
 		ClientID:     "testing123",
@@ -190,9 +222,124 @@ func TestOptionsValidation(t *testing.T) {
 	require.ErrorIs(t, err, sdk.ErrMissingClientSecret, "client secret should be required")
 	opts.ClientSecret = "supersecret"
 
+	// Credentials are not required if the client has a token source
+	opts.ClientID, opts.ClientSecret = "", ""
+	opts.TokenSource = auth.TokenSourceFunc(func(context.Context) (string, error) { return "", nil })
+	err = opts.Validate()
+	require.NoError(t, err, "expected token source to replace credentials")
+
 	// NOTE: cannot validate Endpoint and AuthURL required since the defaults will be set.
 }
 
+func TestWithBackoffConfig(t *testing.T) {
+	conf := backoff.Config{InitialInterval: time.Millisecond, Multiplier: 2, MaxRetries: 1}
+	opts := &sdk.Options{}
+	require.NoError(t, sdk.WithBackoffConfig(conf)(opts), "could not set backoff config")
+	require.NotNil(t, opts.Backoff, "expected backoff policy to be set")
+
+	b := opts.Backoff()
+	require.Equal(t, time.Millisecond, b.NextBackOff())
+	require.Equal(t, backoff.Stop, b.NextBackOff(), "expected max retries to be configured")
+
+	conf.Multiplier = 0
+	require.ErrorIs(t, sdk.WithBackoffConfig(conf)(opts), backoff.ErrInvalidConfig)
+}
+
+func TestConnectionTuning(t *testing.T) {
+	opts := &sdk.Options{}
+	require.NoError(t, sdk.WithKeepalive(30*time.Second, 10*time.Second, true)(opts))
+	require.Equal(t, &keepalive.ClientParameters{Time: 30 * time.Second, Timeout: 10 * time.Second, PermitWithoutStream: true}, opts.Keepalive)
+
+	require.NoError(t, sdk.WithMaxMsgSize(8388608, 4194304)(opts))
+	require.Equal(t, 8388608, opts.MaxRecvMsgSize)
+	require.Equal(t, 4194304, opts.MaxSendMsgSize)
+	require.ErrorIs(t, sdk.WithMaxMsgSize(-1, 0)(opts), sdk.ErrInvalidMsgSize)
+
+	require.NoError(t, sdk.WithWindowSize(1048576, 2097152)(opts))
+	require.Equal(t, int32(1048576), opts.InitialWindowSize)
+	require.Equal(t, int32(2097152), opts.InitialConnWindowSize)
+	require.ErrorIs(t, sdk.WithWindowSize(0, -1)(opts), sdk.ErrInvalidWindowSize)
+
+	// The tuning options should be applied to the connection
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithMaxMsgSize(64, 0))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY, Version: strings.Repeat("v", 128)}, nil
+	}
+
+	_, err = client.Status(context.Background())
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), "expected the max message size to be exceeded")
+}
+
+func TestWithTokenRefresh(t *testing.T) {
+	var calls int
+	hook := func(time.Time, error) { calls++ }
+
+	opts := &sdk.Options{}
+	require.NoError(t, sdk.WithTokenRefresh(5*time.Minute)(opts))
+	require.NoError(t, sdk.WithTokenRefreshHook(hook)(opts))
+	require.Equal(t, 5*time.Minute, opts.TokenRefreshWindow)
+
+	opts.OnTokenRefresh(time.Now(), nil)
+	require.Equal(t, 1, calls)
+
+	require.ErrorIs(t, sdk.WithTokenRefresh(-1*time.Second)(opts), sdk.ErrInvalidRefreshWindow)
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeCertificate(t, certFile, keyFile)
+
+	opts := &sdk.Options{}
+	require.NoError(t, sdk.WithClientCertificate(certFile, keyFile, "")(opts))
+	require.Len(t, opts.TLSConfig.Certificates, 1)
+	require.Nil(t, opts.TLSConfig.RootCAs, "expected system roots to be used")
+
+	// The certificate should be added to the TLS config with the custom CA pool
+	opts = &sdk.Options{}
+	require.NoError(t, sdk.WithTLSConfig(&tls.Config{ServerName: "ensign.test"})(opts))
+	require.NoError(t, sdk.WithClientCertificate(certFile, keyFile, certFile)(opts))
+	require.Len(t, opts.TLSConfig.Certificates, 1)
+	require.NotNil(t, opts.TLSConfig.RootCAs)
+	require.Equal(t, "ensign.test", opts.TLSConfig.ServerName)
+
+	err := sdk.WithClientCertificate(filepath.Join(dir, "missing.pem"), keyFile, "")(opts)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = sdk.WithClientCertificate(certFile, keyFile, keyFile)(opts)
+	require.ErrorIs(t, err, sdk.ErrNoCertificates)
+}
+
+// Writes a self-signed PEM encoded certificate and key to the files for testing.
+func writeCertificate(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "could not generate key")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ensign.test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "could not create certificate")
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err, "could not marshal key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0600))
+}
+
 func TestCredsNotRequired(t *testing.T) {
 	// Credentials should not be required if NoAuthentication is true
 	opts := &sdk.Options{NoAuthentication: true}
@@ -0,0 +1,228 @@
+/*
+Package outbox implements the transactional outbox pattern for Ensign: applications
+insert the events they want to publish into an outbox table in the same database
+transaction as the changes that produced them, and the Outbox polls the table for rows
+that have not been sent, publishes them to Ensign, and marks them as sent once they are
+acked. Each event is published with an idempotency key derived from the row ID so that
+rows that are republished (e.g. if the process stops after an event is published but
+before its row is marked as sent) can be deduplicated by topics with a deduplication
+policy, giving exactly-once-ish delivery. The outbox table must have the columns:
+
+	CREATE TABLE outbox (
+		id       BIGINT PRIMARY KEY, -- any type that orders rows and can be scanned into a string
+		topic    TEXT NOT NULL,      -- the topic name or ID to publish the event to
+		payload  BLOB NOT NULL,      -- the event data
+		mimetype TEXT,               -- the mimetype of the data, application/octet-stream if NULL
+		sent_at  TIMESTAMP           -- NULL until the event is acked
+	);
+*/
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// Dialect specifies the bind parameter placeholders of the database driver.
+type Dialect uint8
+
+const (
+	// Question uses ? placeholders, e.g. for MySQL and SQLite (default).
+	Question Dialect = iota
+
+	// Dollar uses $1, $2, ... placeholders, e.g. for PostgreSQL.
+	Dollar
+)
+
+// Returns the placeholder of the nth bind parameter, starting at 1.
+func (d Dialect) placeholder(n int) string {
+	if d == Dollar {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// Option configures an Outbox.
+type Option func(o *Outbox)
+
+// WithTable sets the name of the outbox table; by default the table is named outbox.
+func WithTable(table string) Option {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+// WithDialect sets the placeholder style of the database driver.
+func WithDialect(dialect Dialect) Option {
+	return func(o *Outbox) {
+		o.dialect = dialect
+	}
+}
+
+// WithBatchSize sets the maximum number of rows that are published each time the table
+// is polled; by default up to 100 rows are published per poll.
+func WithBatchSize(size int) Option {
+	return func(o *Outbox) {
+		if size > 0 {
+			o.batch = size
+		}
+	}
+}
+
+// WithPollInterval sets how often Run polls the table; by default every second.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *Outbox) {
+		if interval > 0 {
+			o.interval = interval
+		}
+	}
+}
+
+// Outbox polls an outbox table for unsent rows and publishes them to Ensign. An outbox
+// should only be run by one process at a time per table, otherwise rows may be
+// published by multiple processes (though they would be deduplicated by their keys).
+type Outbox struct {
+	db       *sql.DB
+	pub      ensign.Publisher
+	table    string
+	dialect  Dialect
+	batch    int
+	interval time.Duration
+}
+
+// New creates an outbox that publishes the rows of the outbox table in the database.
+func New(db *sql.DB, pub ensign.Publisher, opts ...Option) *Outbox {
+	outbox := &Outbox{
+		db:       db,
+		pub:      pub,
+		table:    "outbox",
+		batch:    100,
+		interval: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(outbox)
+	}
+	return outbox
+}
+
+// IdempotencyKey returns the idempotency key of the event published for the row of the
+// outbox table with the specified ID.
+func (o *Outbox) IdempotencyKey(id string) string {
+	return fmt.Sprintf("outbox:%s:%s", o.table, id)
+}
+
+// Run polls the outbox table at the poll interval until the context is done, returning
+// the context error, or until a poll fails, returning the error. Rows are polled again
+// immediately if the previous poll published a full batch.
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := o.Poll(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n == o.batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll publishes a batch of unsent rows in the order of their IDs, waits for the events
+// to be acked, and marks the acked rows as sent, returning the number of rows sent. If
+// an event is nacked, the rows before it are marked as sent and the nack error is
+// returned; the rows after it are left to be republished in order by the next poll.
+func (o *Outbox) Poll(ctx context.Context) (n int, err error) {
+	var rows []*row
+	if rows, err = o.unsent(ctx); err != nil {
+		return 0, err
+	}
+
+	events := make([]*ensign.Event, 0, len(rows))
+	for _, row := range rows {
+		event := row.event()
+		event.Metadata.Set(ensign.IdempotencyKey, o.IdempotencyKey(row.id))
+
+		if err = o.pub.PublishContext(ctx, row.topic, event); err != nil {
+			break
+		}
+		events = append(events, event)
+	}
+
+	// Mark the rows that were acked as sent, in order, stopping at the first failure.
+	for i, event := range events {
+		if _, nerr := event.WaitForAck(ctx); nerr != nil {
+			err = nerr
+			break
+		}
+
+		if merr := o.markSent(ctx, rows[i].id); merr != nil {
+			return n, merr
+		}
+		n++
+	}
+	return n, err
+}
+
+// A row of the outbox table.
+type row struct {
+	id       string
+	topic    string
+	payload  []byte
+	mimetype sql.NullString
+}
+
+func (r *row) event() *ensign.Event {
+	event := &ensign.Event{
+		Metadata: make(ensign.Metadata),
+		Data:     r.payload,
+		Mimetype: mimetype.ApplicationOctetStream,
+		Created:  time.Now(),
+	}
+
+	if r.mimetype.Valid {
+		if mime, err := mimetype.Parse(r.mimetype.String); err == nil {
+			event.Mimetype = mime
+		}
+	}
+	return event
+}
+
+func (o *Outbox) unsent(ctx context.Context) (out []*row, err error) {
+	query := fmt.Sprintf("SELECT id, topic, payload, mimetype FROM %s WHERE sent_at IS NULL ORDER BY id LIMIT %d", o.table, o.batch)
+
+	var rows *sql.Rows
+	if rows, err = o.db.QueryContext(ctx, query); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &row{}
+		if err = rows.Scan(&r.id, &r.topic, &r.payload, &r.mimetype); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (o *Outbox) markSent(ctx context.Context, id string) (err error) {
+	query := fmt.Sprintf("UPDATE %s SET sent_at = %s WHERE id = %s", o.table, o.dialect.placeholder(1), o.dialect.placeholder(2))
+	_, err = o.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	return err
+}
@@ -0,0 +1,263 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	// Nack the first attempt to publish the event for row 3
+	var attempts int
+	recorder := mock.NewPublishRecorder(nil)
+	ack := recorder.OnEvent
+	recorder.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		event, _ := in.Unwrap()
+		if event.Metadata[ensign.IdempotencyKey] == "outbox:outbox:3" {
+			attempts++
+			if attempts == 1 {
+				return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_INTERNAL}}}, nil
+			}
+		}
+		return ack(in)
+	}
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := ensign.New(ensign.WithMock(emock), ensign.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	db := openDB(t, []*fakeRow{
+		{id: 1, topic: "01GWM89049D49FHJH81BT8795H", payload: `{"order":1}`, mimetype: "application/json"},
+		{id: 2, topic: "01GWM89049D49FHJH81BT8795H", payload: "two"},
+		{id: 3, topic: "01GWM8AAQ4YHK7GVE5SMRJ0YCK", payload: "three"},
+		{id: 4, topic: "01GWM8AAQ4YHK7GVE5SMRJ0YCK", payload: "four"},
+	})
+
+	box := outbox.New(db.DB, client, outbox.WithBatchSize(3), outbox.WithDialect(outbox.Dollar))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The rows before the nacked event are marked as sent
+	n, err := box.Poll(ctx)
+	var nack *ensign.NackError
+	require.ErrorAs(t, err, &nack)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int64{1, 2}, db.sent())
+	require.Equal(t, "SELECT id, topic, payload, mimetype FROM outbox WHERE sent_at IS NULL ORDER BY id LIMIT 3", db.queries[0])
+	require.Equal(t, "UPDATE outbox SET sent_at = $1 WHERE id = $2", db.queries[1])
+
+	// The next poll publishes the remaining rows in order
+	n, err = box.Poll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int64{1, 2, 3, 4}, db.sent())
+
+	n, err = box.Poll(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	require.NoError(t, client.Flush(ctx))
+	published := recorder.Published()
+	require.Len(t, published, 4, "expected the nacked event not to be recorded")
+	require.Equal(t, mimetype.ApplicationJSON, published[0].Event.Mimetype)
+	require.Equal(t, mimetype.ApplicationOctetStream, published[1].Event.Mimetype)
+	require.Equal(t, "outbox:outbox:1", published[0].Event.Metadata[ensign.IdempotencyKey])
+	require.Equal(t, "outbox:outbox:3", published[2].Event.Metadata[ensign.IdempotencyKey], "expected the republished row to have the same idempotency key")
+	require.Equal(t, 2, attempts)
+}
+
+func TestOutboxRun(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := ensign.New(ensign.WithMock(emock), ensign.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	db := openDB(t, []*fakeRow{{id: 1, topic: "01GWM89049D49FHJH81BT8795H", payload: "one"}, {id: 2, topic: "01GWM89049D49FHJH81BT8795H", payload: "two"}})
+	box := outbox.New(db.DB, client, outbox.WithTable("events_outbox"), outbox.WithBatchSize(1), outbox.WithPollInterval(5*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- box.Run(ctx) }()
+
+	// Rows inserted while the outbox is running are published on the next poll
+	require.Eventually(t, func() bool { return len(db.sent()) == 2 }, time.Second, 5*time.Millisecond)
+	db.insert(&fakeRow{id: 3, topic: "01GWM89049D49FHJH81BT8795H", payload: "three"})
+	require.Eventually(t, func() bool { return len(db.sent()) == 3 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, "UPDATE events_outbox SET sent_at = ? WHERE id = ?", db.queries[1])
+
+	// Database errors stop the outbox
+	db.fail = errors.New("connection refused")
+	require.ErrorIs(t, box.Run(context.Background()), db.fail)
+}
+
+// A fake database/sql driver that implements just enough of the outbox queries to test
+// the outbox without a database.
+type fakeDB struct {
+	*sql.DB
+	sync.Mutex
+	rows    []*fakeRow
+	queries []string
+	fail    error
+}
+
+type fakeRow struct {
+	id       int64
+	topic    string
+	payload  string
+	mimetype string
+	sentAt   time.Time
+}
+
+var (
+	fakeMu  sync.Mutex
+	fakeDBs = make(map[string]*fakeDB)
+)
+
+func init() {
+	sql.Register("outboxtest", fakeDriver{})
+}
+
+func openDB(t *testing.T, rows []*fakeRow) *fakeDB {
+	db := &fakeDB{rows: rows}
+	fakeMu.Lock()
+	fakeDBs[t.Name()] = db
+	fakeMu.Unlock()
+
+	var err error
+	db.DB, err = sql.Open("outboxtest", t.Name())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (db *fakeDB) sent() (ids []int64) {
+	db.Lock()
+	defer db.Unlock()
+	for _, row := range db.rows {
+		if !row.sentAt.IsZero() {
+			ids = append(ids, row.id)
+		}
+	}
+	return ids
+}
+
+func (db *fakeDB) insert(row *fakeRow) {
+	db.Lock()
+	defer db.Unlock()
+	db.rows = append(db.rows, row)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return &fakeConn{db: fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.Lock()
+	defer c.db.Unlock()
+
+	if c.db.fail != nil {
+		return nil, c.db.fail
+	}
+
+	if len(c.db.queries) < 2 {
+		c.db.queries = append(c.db.queries, query)
+	}
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+// Marks the row with the ID in the second argument as sent.
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	for _, row := range s.db.rows {
+		if id, _ := args[1].(string); id == strconv.FormatInt(row.id, 10) {
+			row.sentAt = args[0].(time.Time)
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// Returns the unsent rows ordered by ID, limited by the limit at the end of the query.
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	var limit int
+	for _, c := range s.query[strings.LastIndex(s.query, " ")+1:] {
+		limit = limit*10 + int(c-'0')
+	}
+
+	rows := &fakeRows{}
+	for _, row := range s.db.rows {
+		if row.sentAt.IsZero() && len(rows.rows) < limit {
+			var mime interface{}
+			if row.mimetype != "" {
+				mime = row.mimetype
+			}
+			rows.rows = append(rows.rows, []driver.Value{row.id, row.topic, []byte(row.payload), mime})
+		}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "topic", "payload", "mimetype"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
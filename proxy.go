@@ -0,0 +1,117 @@
+package ensign
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Returns a gRPC context dialer that connects to the Ensign server through the proxy at
+// the URL. HTTP and HTTPS proxies are tunneled through using the CONNECT method, and
+// SOCKS5 proxies are supported by golang.org/x/net/proxy.
+func proxyDialer(u *url.URL) (_ func(context.Context, string) (net.Conn, error), err error) {
+	switch u.Scheme {
+	case "http", "https":
+		return func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialConnect(ctx, u, addr)
+		}, nil
+	case "socks5", "socks5h":
+		var dialer proxy.Dialer
+		if dialer, err = proxy.FromURL(u, proxy.Direct); err != nil {
+			return nil, err
+		}
+
+		return func(ctx context.Context, addr string) (net.Conn, error) {
+			if cd, ok := dialer.(proxy.ContextDialer); ok {
+				return cd.DialContext(ctx, "tcp", addr)
+			}
+			return dialer.Dial("tcp", addr)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidProxy, u.Scheme)
+	}
+}
+
+// Connects to the HTTP proxy and requests a tunnel to the address with CONNECT,
+// authenticating with the proxy if the URL has user info.
+func dialConnect(ctx context.Context, u *url.URL, addr string) (conn net.Conn, err error) {
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var dialer net.Dialer
+	if conn, err = dialer.DialContext(ctx, "tcp", host); err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+
+	// Ensure the handshake with the proxy does not outlive the context.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if u.User != nil {
+		password, _ := u.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not write proxy connect request: %w", err)
+	}
+
+	// The tunnel is established when the proxy replies with a 200 status.
+	var rep *http.Response
+	br := bufio.NewReader(conn)
+	if rep, err = http.ReadResponse(br, req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not read proxy connect reply: %w", err)
+	}
+	rep.Body.Close()
+
+	if rep.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("%w: proxy connect failed with %s", ErrInvalidProxy, rep.Status)
+	}
+
+	// Ensure that any data read from the tunnel along with the reply is not lost.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// A connection that reads the data buffered while reading the proxy reply first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
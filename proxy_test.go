@@ -0,0 +1,97 @@
+package ensign_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestProxy(t *testing.T) {
+	// Serve the mock on a tcp socket so that it can be reached through the proxy
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "could not listen on a tcp socket")
+
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	srv := grpc.NewServer()
+	api.RegisterEnsignServer(srv, emock)
+	go srv.Serve(sock)
+	defer srv.Stop()
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+	}
+
+	proxy, requests := connectProxy(t)
+	client, err := sdk.New(
+		sdk.WithEnsignEndpoint(sock.Addr().String(), true),
+		sdk.WithAuthenticator("", true),
+		sdk.WithProxy("http://proxyuser:supersecret@"+proxy),
+	)
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	state, err := client.Status(context.Background())
+	require.NoError(t, err, "could not connect through the proxy")
+	require.Equal(t, api.ServiceState_HEALTHY, state.Status)
+
+	req := <-requests
+	require.Equal(t, sock.Addr().String(), req.Host)
+	user, pass, ok := (&http.Request{Header: http.Header{"Authorization": req.Header["Proxy-Authorization"]}}).BasicAuth()
+	require.True(t, ok, "expected proxy credentials to be sent")
+	require.Equal(t, "proxyuser", user)
+	require.Equal(t, "supersecret", pass)
+
+	// Unsupported proxies should return an error
+	_, err = sdk.New(sdk.WithProxy("ftp://proxy.example.com"))
+	require.ErrorIs(t, err, sdk.ErrInvalidProxy)
+}
+
+// Starts a proxy that tunnels connections with CONNECT, returning the address of the
+// proxy and a channel of the CONNECT requests made to the proxy.
+func connectProxy(t *testing.T) (string, <-chan *http.Request) {
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "could not listen on a tcp socket")
+	t.Cleanup(func() { sock.Close() })
+
+	requests := make(chan *http.Request, 8)
+	go func() {
+		for {
+			conn, err := sock.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				requests <- req
+
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+	return sock.Addr().String(), requests
+}
@@ -2,8 +2,10 @@ package ensign
 
 import (
 	"context"
+	"time"
 
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/auth"
 	"github.com/rotationalio/go-ensign/stream"
 	"google.golang.org/grpc"
 )
@@ -11,28 +13,81 @@ import (
 // Publish one or more events to the specified topic name or topic ID. The first time
 // that Publish is called, a Publisher stream is opened by the client that will run in
 // its own go routine for the duration to the program; if the publish stream cannot be
-// opened an error is returned. Otherwise, each event passed to the publish method will
-// be sent to Ensign. If the Ensign connection has dropped or another connection error
-// occurs an error will be returned. Once the event is published, it is up to the user
-// to listen for an Ack or Nack on each event to determine if the event was specifically
-// published or not.
+// opened an error is returned. Clients returned by WithCallOptions open their own
+// publish stream with the specified call options the first time Publish is called on
+// the clone. Otherwise, each event passed to the publish method will be sent to Ensign.
+// If the Ensign connection has dropped or another connection error occurs an error will
+// be returned. Once the event is published, it is up to the user to listen for an Ack
+// or Nack on each event to determine if the event was specifically published or not.
+// If the client was created WithPublishIdleTimeout the publish stream is closed when
+// inactive and is reopened the next time Publish is called. Events with a zero Created
+// timestamp are stamped with the current time and events with nil metadata are given
+// empty metadata before they are published; events with a mimetype that is not defined
+// by the protocol are not published (ErrInvalidMimetype).
 func (c *Client) Publish(topic string, events ...*Event) (err error) {
-	// Ensure the publisher is open before publishing
-	c.openPub.Do(func() {
-		c.pub, err = stream.NewPublisher(c, c.copts...)
-	})
+	return c.publish(context.Background(), topic, events...)
+}
+
+// PublishContext publishes one or more events to the specified topic name or topic ID
+// in the same manner as Publish but returns the context error if the context is done
+// before all events are sent; events after the first error are not published. The
+// context is attached to each published event so that the deadline also bounds Wait,
+// which blocks until the event is acked or nacked by the server.
+func (c *Client) PublishContext(ctx context.Context, topic string, events ...*Event) error {
+	for _, event := range events {
+		event.SetContext(ctx)
+	}
+	return c.publish(ctx, topic, events...)
+}
+
+func (c *Client) publish(ctx context.Context, topic string, events ...*Event) (err error) {
+	if c.opts.ReadOnly {
+		return ErrReadOnlyClient
+	}
 
-	// If the publisher could not be opened, return an error
-	if err != nil {
+	// Resolve the topic name using the topic cache if one is configured.
+	if topic, err = c.resolveTopic(ctx, topic); err != nil {
+		return err
+	}
+
+	// Ensure the publisher is open before publishing
+	var pub *stream.Publisher
+	if pub, err = c.publisher(); err != nil {
 		return err
 	}
 
 	// Attempt to send all events to the server, stopping on the first error.
 	for _, event := range events {
+		// Default the created timestamp and metadata of the event and check its mimetype.
+		if err = event.normalize(); err != nil {
+			return err
+		}
+
+		// Do not publish events that do not match the schema of their event type.
+		if err = c.validate(ctx, event); err != nil {
+			return err
+		}
+
+		// Trace the event, propagating the trace context in the event metadata.
+		span := c.startPublishSpan(topic, event)
+
 		// Publish the event and collect the event info and reply channel.
-		if event.info, event.pub, err = c.pub.Publish(topic, event.Proto()); err != nil {
+		event.sent = time.Now()
+		var popts []stream.PublishOption
+		if key := c.opts.eventKey(event); len(key) > 0 {
+			popts = append(popts, stream.WithKey(key))
+		}
+
+		if signer := c.opts.signer(); signer != nil {
+			popts = append(popts, signer.sign)
+		}
+
+		if event.info, event.pub, err = pub.PublishContext(ctx, topic, event.Proto(), popts...); err != nil {
+			span.RecordError(err)
+			span.End()
 			return err
 		}
+		span.End()
 
 		// Ensure the event state is set to published.
 		event.state = published
@@ -40,10 +95,144 @@ func (c *Client) Publish(topic string, events ...*Event) (err error) {
 	return nil
 }
 
+// Returns the publisher of the client, opening the publish stream with the call options
+// of the client the first time it is called. If the publish stream cannot be opened an
+// error is returned and the stream will be opened on the next call. The stream is opened
+// without holding the client lock so that opening it does not block other operations.
+func (c *Client) publisher() (pub *stream.Publisher, err error) {
+	c.RLock()
+	pub = c.pub
+	c.RUnlock()
+	if pub != nil {
+		return pub, nil
+	}
+
+	// Only one publish stream is opened; check if it was opened while waiting.
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	c.RLock()
+	pub = c.pub
+	c.RUnlock()
+	if pub != nil {
+		return pub, nil
+	}
+
+	if err = c.preflight(auth.PermissionPublisher); err != nil {
+		return nil, err
+	}
+
+	if err = c.streams.acquire(); err != nil {
+		return nil, err
+	}
+
+	sopts := []stream.Option{
+		stream.WithCallOptions(c.copts...),
+		stream.WithQuota(c.opts.PublishQuota),
+		stream.WithClientID(c.opts.ClientName),
+		stream.WithIdleTimeout(c.opts.PublishIdleTimeout),
+		stream.WithAckTimeout(c.opts.PublishAckTimeout),
+		stream.WithLogger(c.opts.Logger),
+		stream.WithReadyHook(c.opts.OnStreamReady),
+		stream.WithConnectionHook(c.opts.OnConnection),
+		stream.WithBackoff(c.opts.Backoff),
+		stream.WithMaxEventSize(c.opts.maxEventSize()),
+	}
+	if c.opts.PublishResend {
+		sopts = append(sopts, stream.WithResend())
+	}
+
+	if len(c.opts.PublishTopics) > 0 {
+		sopts = append(sopts, stream.WithTopics(c.opts.PublishTopics...))
+	}
+
+	if pub, err = stream.NewPublisherWithOptions(c, sopts...); err != nil {
+		c.streams.release()
+		return nil, translateError(err)
+	}
+
+	// Do not leak the stream if the client was closed while the stream was opened.
+	c.Lock()
+	if c.api == nil {
+		c.Unlock()
+		pub.Close()
+		c.streams.release()
+		return nil, ErrClientClosed
+	}
+	c.pub = pub
+	c.Unlock()
+
+	c.cacheTopics(pub.Topics())
+	return pub, nil
+}
+
+// PublishStats returns the aggregated counts and latencies of the events published by
+// the client. If no events have been published yet, the zero-valued stats are returned.
+func (c *Client) PublishStats() stream.PublisherStats {
+	c.RLock()
+	defer c.RUnlock()
+	if c.pub == nil {
+		return stream.PublisherStats{}
+	}
+	return c.pub.Stats()
+}
+
+// Flush blocks until the server has acked or nacked every event published by the client
+// or until the context is done, in which case the context error is returned. Batch jobs
+// should flush the client before exiting to confirm that all events were delivered;
+// the ack or nack of each event can then be checked without blocking, e.g. with Acked.
+func (c *Client) Flush(ctx context.Context) error {
+	c.RLock()
+	pub := c.pub
+	c.RUnlock()
+
+	if pub == nil {
+		return nil
+	}
+	return pub.Flush(ctx)
+}
+
+// Pending returns the number of events published by the client that have not been
+// acked or nacked by the server yet.
+func (c *Client) Pending() int {
+	c.RLock()
+	defer c.RUnlock()
+	if c.pub == nil {
+		return 0
+	}
+	return c.pub.Pending()
+}
+
+// PublishStreamInfo returns the info sent by the server when the publish stream of the
+// client was last opened, e.g. the node the stream is connected to. If no events have
+// been published yet, the zero-valued stream info is returned.
+func (c *Client) PublishStreamInfo() stream.StreamInfo {
+	c.RLock()
+	defer c.RUnlock()
+	if c.pub == nil {
+		return stream.StreamInfo{}
+	}
+	return c.pub.Info()
+}
+
+// ResumePublishing resumes a publisher that has been paused after reaching the hard
+// limit of the publish quota configured with WithPublishQuota, resetting the session
+// usage. If the publisher has not been opened or is not paused this is a no-op.
+func (c *Client) ResumePublishing() {
+	c.RLock()
+	defer c.RUnlock()
+	if c.pub != nil {
+		c.pub.Resume()
+	}
+}
+
 // PublishStream allows you to open a gRPC stream server to ensign for publishing API
 // events directly. This manual mechanism of opening a stream is for advanced users and
 // is not recommended in production. Instead using Publish or CreatePublisher is the
 // best way to establish a stream connection to Ensign.
 func (c *Client) PublishStream(ctx context.Context, opts ...grpc.CallOption) (api.Ensign_PublishClient, error) {
+	if c.opts.ReadOnly {
+		return nil, ErrReadOnlyClient
+	}
 	return c.api.Publish(ctx, opts...)
 }
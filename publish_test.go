@@ -2,9 +2,16 @@ package ensign_test
 
 import (
 	"context"
+	"testing"
+	"time"
 
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
 	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/stream"
+	"github.com/stretchr/testify/require"
 )
 
 func (s *sdkTestSuite) TestPublish() {
@@ -57,3 +64,348 @@ func (s *sdkTestSuite) TestPublishStream() {
 	require.NoError(err)
 	require.NotNil(msg.GetAck(), "expected an ack from the server")
 }
+
+func TestAssertPublished(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	recorder := mock.NewPublishRecorder(map[string]ulid.ULID{"orders": topicID})
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	event := &sdk.Event{
+		Metadata: sdk.Metadata{"customer": "42"},
+		Data:     []byte(`{"order": 1, "items": ["apple", "pear"]}`),
+		Mimetype: mimetype.ApplicationJSON,
+		Type:     &api.Type{Name: "OrderPlaced", MajorVersion: 1, MinorVersion: 2},
+	}
+
+	require.NoError(t, client.Publish(topicID.String(), NewEvent(), event))
+	_, err = event.Wait()
+	require.NoError(t, err, "event was not acked")
+	require.Len(t, recorder.Published(), 2)
+
+	pub := mock.AssertPublished(t, recorder,
+		mock.OnTopic("orders"),
+		mock.OnTopic(topicID.String()),
+		mock.HasType("OrderPlaced", "1.2.0"),
+		mock.HasMetadata("customer", "42"),
+		mock.PayloadJSONEquals(map[string]interface{}{"items": []string{"apple", "pear"}, "order": 1}),
+		mock.PayloadJSONEquals(`{"items": ["apple", "pear"], "order": 1}`),
+	)
+	require.NotNil(t, pub)
+	require.Equal(t, "orders", pub.Topic)
+
+	mock.AssertNotPublished(t, recorder, mock.HasType("OrderCanceled", ""))
+
+	// Matchers should describe why an event does not match
+	require.EqualError(t, mock.Match(pub, mock.OnTopic("invoices")), "published to topic 01GWM89049D49FHJH81BT8795H not invoices")
+	require.EqualError(t, mock.Match(pub, mock.HasMetadata("customer", "7")), `metadata "customer" is "42" not "7"`)
+	require.EqualError(t, mock.Match(pub, mock.HasMetadata("region", "us")), `metadata key "region" is missing`)
+	require.EqualError(t, mock.Match(pub, mock.HasType("OrderPlaced", "2.0.0")), "type version is 1.2.0 not 2.0.0")
+	require.Error(t, mock.Match(pub, mock.PayloadJSONEquals(`{"order": 2}`)))
+	require.Error(t, mock.Match(recorder.Published()[0], mock.PayloadJSONEquals(`{"order": 1}`)), "expected binary data not to match")
+
+	recorder.Reset()
+	require.Empty(t, recorder.Published())
+}
+
+func TestPublishContentKeys(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	_, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithContentKeys(sdk.ContentHash(42)))
+	require.ErrorIs(t, err, sdk.ErrInvalidContentHash)
+
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	recorder := mock.NewPublishRecorder(map[string]ulid.ULID{"orders": topicID})
+	emock.OnPublish = recorder.OnPublish
+
+	for _, algorithm := range []sdk.ContentHash{sdk.Murmur3, sdk.SHA256} {
+		recorder.Reset()
+		client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithContentKeys(algorithm))
+		require.NoError(t, err, "could not create client")
+
+		// Events with the same payload have the same key
+		first := &sdk.Event{Data: []byte("order 1"), Mimetype: mimetype.TextPlain}
+		duplicate := &sdk.Event{Data: []byte("order 1"), Mimetype: mimetype.TextPlain}
+		other := &sdk.Event{Data: []byte("order 2"), Mimetype: mimetype.TextPlain}
+
+		// Events with a partition key keep their key
+		keyed := &sdk.Event{Data: []byte("order 1"), Mimetype: mimetype.TextPlain, Key: []byte("customer-42")}
+
+		require.NoError(t, client.Publish("orders", first, duplicate, other, keyed))
+		require.NoError(t, client.Flush(context.Background()))
+		client.Close()
+
+		published := recorder.Published()
+		require.Len(t, published, 4)
+		require.Equal(t, algorithm.Sum([]byte("order 1")), published[0].Wrapper.Key)
+		require.Equal(t, published[0].Wrapper.Key, published[1].Wrapper.Key)
+		require.NotEqual(t, published[0].Wrapper.Key, published[2].Wrapper.Key)
+		require.Equal(t, []byte("customer-42"), published[3].Wrapper.Key)
+	}
+
+	require.Len(t, sdk.Murmur3.Sum([]byte("order 1")), 16)
+	require.Len(t, sdk.SHA256.Sum([]byte("order 1")), 32)
+	require.Nil(t, sdk.NoContentHash.Sum([]byte("order 1")))
+}
+
+func TestPublishKey(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	recorder := mock.NewPublishRecorder(map[string]ulid.ULID{"orders": topicID})
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	keyed := NewEvent()
+	keyed.Key = []byte("customer-42")
+	unkeyed := NewEvent()
+
+	require.NoError(t, client.Publish("orders", keyed, unkeyed))
+	require.NoError(t, client.Flush(context.Background()))
+
+	// The key is sent in the event wrapper so that the server can shard the event
+	require.Equal(t, []byte("customer-42"), keyed.Info().Key)
+	mock.AssertPublished(t, recorder, mock.HasKey([]byte("customer-42")))
+	require.Empty(t, recorder.Published()[1].Wrapper.Key, "expected no key on unkeyed events")
+	require.EqualError(t, mock.Match(recorder.Published()[1], mock.HasKey([]byte("customer-42"))), `partition key is "" not "customer-42"`)
+
+	// The key is available on events that are received by subscribers
+	incoming := sdk.NewIncomingEvent(recorder.Published()[0].Wrapper, nil)
+	require.Equal(t, []byte("customer-42"), incoming.Key)
+	require.Equal(t, keyed.Key, incoming.Clone().Key)
+}
+
+func TestFlush(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	// Flushing before any events are published returns immediately.
+	require.NoError(t, client.Flush(context.Background()))
+	require.Zero(t, client.Pending())
+
+	// Hold the acks from the server until the events are released.
+	release := make(chan struct{})
+	handler := mock.NewPublishHandler(nil)
+	ack := handler.OnEvent
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		<-release
+		return ack(in)
+	}
+	emock.OnPublish = handler.OnPublish
+
+	events := []*sdk.Event{NewEvent(), NewEvent(), NewEvent()}
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", events...))
+	require.Equal(t, 3, client.Pending())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, client.Flush(ctx), context.DeadlineExceeded)
+	require.Equal(t, 3, client.Pending())
+
+	close(release)
+	require.NoError(t, client.Flush(context.Background()))
+	require.Zero(t, client.Pending())
+
+	// Once flushed, the acks can be checked without waiting.
+	for _, event := range events {
+		acked, err := event.Acked()
+		require.NoError(t, err)
+		require.True(t, acked, "expected event to be acked after flush")
+	}
+}
+
+func TestPublishResend(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithPublishResend())
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	// Events are published with an idempotency key unless they already have one.
+	event, keyed := NewEvent(), NewEvent()
+	keyed.Metadata = sdk.Metadata{sdk.IdempotencyKey: "order-42"}
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", event, keyed))
+	require.NoError(t, client.Flush(context.Background()))
+
+	require.Empty(t, event.IdempotencyKey(), "the metadata of the published event should not be modified")
+	require.Equal(t, "order-42", keyed.IdempotencyKey())
+
+	published := recorder.Published()
+	require.Len(t, published, 2)
+	require.Len(t, published[0].Event.Metadata[sdk.IdempotencyKey], 26, "expected a ulid idempotency key")
+	mock.AssertPublished(t, recorder, mock.HasMetadata(sdk.IdempotencyKey, "order-42"))
+}
+
+func TestPublishFixture(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	require.NoError(t, emock.UseFixture(mock.PublishRPC, "testdata/publish.pb.json"))
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	acked, nacked := NewEvent(), NewEvent()
+	require.NoError(t, client.Publish("orders", acked, nacked))
+
+	// The scripted ack and nack are matched to the published events in order
+	ok, err := acked.Wait()
+	require.NoError(t, err)
+	require.True(t, ok, "expected the first event to be acked")
+	require.Equal(t, time.Date(2023, 8, 14, 12, 31, 3, 0, time.UTC), acked.Committed())
+
+	ok, err = nacked.Wait()
+	require.False(t, ok, "expected the second event to be nacked")
+	require.EqualError(t, err, "[MAX_EVENT_SIZE_EXCEEDED] event is too large")
+}
+
+func TestPublishAckTimeout(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	// The server never replies to the first event that is published
+	var nevents int
+	handler := mock.NewPublishHandler(nil)
+	ack := handler.OnEvent
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		nevents++
+		if nevents == 1 {
+			return nil, nil
+		}
+		return ack(in)
+	}
+	emock.OnPublish = handler.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithPublishAckTimeout(50*time.Millisecond))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	lost, acked := NewEvent(), NewEvent()
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", lost, acked))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ok, err := acked.WaitForAck(ctx)
+	require.NoError(t, err)
+	require.True(t, ok, "expected the event to be acked")
+
+	ok, err = lost.WaitForAck(ctx)
+	require.False(t, ok, "expected the event to be nacked after the ack timeout")
+
+	var nack *sdk.NackError
+	require.ErrorAs(t, err, &nack)
+	require.Equal(t, api.Nack_TIMEOUT, nack.Code)
+
+	stats := client.PublishStats()
+	require.Equal(t, uint64(1), stats.Timeouts)
+	require.NoError(t, client.Flush(ctx), "expected no pending events after the timeout")
+
+	// Events that have not been published cannot be waited on
+	_, err = NewEvent().WaitForAck(ctx)
+	require.ErrorIs(t, err, sdk.ErrNotPublished)
+}
+
+func TestPublishDefaults(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	// Events are published with a created timestamp and metadata
+	before := time.Now()
+	event := &sdk.Event{Data: []byte("hello world"), Mimetype: mimetype.TextPlain}
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", event))
+	require.NoError(t, client.Flush(context.Background()))
+
+	require.False(t, event.Created.Before(before), "expected created to be defaulted to now")
+	require.NotNil(t, event.Metadata)
+
+	published := recorder.Published()
+	require.Len(t, published, 1)
+	require.True(t, event.Created.Equal(published[0].Event.Created.AsTime()))
+
+	// The created timestamp is not replaced if it is set
+	created := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	event = &sdk.Event{Data: []byte("hello world"), Created: created}
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", event))
+	require.Equal(t, created, event.Created)
+
+	// Events with an unknown mimetype are not published
+	event = &sdk.Event{Data: []byte("hello world"), Mimetype: mimetype.MIME(4242)}
+	require.ErrorIs(t, client.Publish("01GWM89049D49FHJH81BT8795H", event), sdk.ErrInvalidMimetype)
+	require.NoError(t, client.Flush(context.Background()))
+	require.Len(t, recorder.Published(), 2)
+}
+
+func TestPublishOpenStream(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	// The server does not reply to the stream until released
+	release := make(chan struct{})
+	handler := mock.NewPublishHandler(nil)
+	emock.OnPublish = func(srv api.Ensign_PublishServer) error {
+		<-release
+		return handler.OnPublish(srv)
+	}
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	published := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			published <- client.Publish("01GWM89049D49FHJH81BT8795H", NewEvent())
+		}()
+	}
+
+	// Opening the publish stream does not block other client operations
+	stats := make(chan stream.PublisherStats, 1)
+	go func() {
+		stats <- client.PublishStats()
+	}()
+
+	select {
+	case <-stats:
+	case <-time.After(time.Second):
+		t.Fatal("expected publish stats not to block while the publish stream is opened")
+	}
+
+	// Concurrent publishers share the same publish stream
+	close(release)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-published)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, client.Flush(ctx))
+	require.Equal(t, 1, emock.Calls[mock.PublishRPC])
+}
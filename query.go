@@ -2,19 +2,26 @@ package ensign
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"reflect"
+	"sync"
 
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/ensql"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 // QueryCursor exposes event results from an EnSQL query with familiar database cursor
 // semantics. Note that the cursor is not thread safe and should only be used from a
-// single thread.
+// single thread; use Events to consume the results from a channel instead.
 type QueryCursor struct {
 	stream api.Ensign_EnSQLClient
 	result *Event
+	cancel context.CancelFunc
+	emu    sync.RWMutex
+	err    error
 }
 
 // NewQueryCursor creates a new query cursor that reads from the specified stream.
@@ -124,8 +131,137 @@ func (i *QueryCursor) FetchAll() (events []*Event, err error) {
 	return events, nil
 }
 
+// Scan decodes the data of the next query result into dest using the codec registered
+// for the mimetype of the event (see Event.Decode). If there are no more results then
+// ErrNoRows is returned.
+func (i *QueryCursor) Scan(dest interface{}) (err error) {
+	var event *Event
+	if event, err = i.FetchOne(); err != nil {
+		return err
+	}
+	return event.Decode(dest)
+}
+
+// FetchAllInto decodes the data of all the remaining query results into dest, which
+// must be a pointer to a slice, e.g. *[]T or *[]*T; the decoded values are appended to
+// the slice. Each event is decoded using the codec registered for its mimetype. If
+// there are no more results then ErrNoRows is returned.
+func (i *QueryCursor) FetchAllInto(dest interface{}) (err error) {
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: expected a pointer to a slice not %T", ErrInvalidScanDest, dest)
+	}
+
+	slice := ptr.Elem()
+	elem := slice.Type().Elem()
+	isPtr := elem.Kind() == reflect.Pointer
+	if isPtr {
+		elem = elem.Elem()
+	}
+
+	var events []*Event
+	if events, err = i.FetchAll(); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		val := reflect.New(elem)
+		if err = event.Decode(val.Interface()); err != nil {
+			return err
+		}
+
+		if !isPtr {
+			val = val.Elem()
+		}
+		slice = reflect.Append(slice, val)
+	}
+
+	ptr.Elem().Set(slice)
+	return nil
+}
+
+// Events streams the remaining query results on the returned channel, which is closed
+// when all results have been delivered, when the context is canceled, or when an error
+// occurs; the cursor is closed automatically. Check Err once the channel is closed to
+// determine if all results were received. After calling Events, the cursor is owned by
+// the streaming go routine and no other cursor methods except Err should be called.
+func (i *QueryCursor) Events(ctx context.Context) <-chan *Event {
+	out := make(chan *Event)
+	done := make(chan struct{})
+
+	// Interrupt a blocking read from the stream if the context is canceled.
+	if i.cancel != nil {
+		go func(cancel context.CancelFunc) {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-done:
+			}
+		}(i.cancel)
+	}
+
+	go func() {
+		defer func() {
+			close(done)
+			i.Close()
+			close(out)
+		}()
+
+		for {
+			if err := ctx.Err(); err != nil {
+				i.setErr(err)
+				return
+			}
+
+			// A canceled stream is reported as closed by read, so the context is checked
+			// to distinguish cancellation from the end of the results.
+			event, err := i.read()
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				i.setErr(ctxErr)
+				return
+			}
+
+			if err != nil {
+				i.setErr(err)
+				return
+			}
+
+			if event == nil {
+				return
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				i.setErr(ctx.Err())
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Err returns the error that stopped the Events channel before all results were
+// delivered or nil if all results were streamed. The error is set before the channel
+// is closed so that it can be checked as soon as a range loop over the channel exits.
+func (i *QueryCursor) Err() error {
+	i.emu.RLock()
+	defer i.emu.RUnlock()
+	return i.err
+}
+
+func (i *QueryCursor) setErr(err error) {
+	i.emu.Lock()
+	i.err = err
+	i.emu.Unlock()
+}
+
 // Close the cursor, which closes the underlying stream.
 func (i *QueryCursor) Close() (err error) {
+	if i.cancel != nil {
+		defer i.cancel()
+	}
+
 	if i.stream == nil {
 		return nil
 	}
@@ -148,13 +284,52 @@ func (c *Client) EnSQL(ctx context.Context, query *api.Query) (cursor *QueryCurs
 		return nil, ErrEmptyQuery
 	}
 
-	// Create the stream by sending the query request to the server.
+	// Create the stream by sending the query request to the server; the stream is
+	// canceled when the cursor is closed so that Events can interrupt blocked reads.
+	ctx, cancel := context.WithCancel(ctx)
 	var stream api.Ensign_EnSQLClient
 	if stream, err = c.api.EnSQL(ctx, query, c.copts...); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if cursor, err = NewQueryCursor(stream); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	cursor.cancel = cancel
+	return cursor, nil
+}
+
+// Query executes an EnSQL query with positional parameters that are bound to the $1,
+// $2, etc. placeholders in the query, e.g. "SELECT * FROM orders WHERE meta.region = $1".
+// The parameters are sent to the server separately from the query rather than being
+// interpolated into the query string, which prevents injection. See NewQuery for the
+// supported parameter types.
+func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (cursor *QueryCursor, err error) {
+	var q *api.Query
+	if q, err = NewQuery(query, args...); err != nil {
 		return nil, err
 	}
+	return c.EnSQL(ctx, q)
+}
 
-	return NewQueryCursor(stream)
+// NewQuery creates an EnSQL query that binds the positional arguments to the $1, $2,
+// etc. placeholders in the query; the query can be executed with EnSQL or explained
+// with Explain. See ensql.Bind for the supported argument types; an error wrapping
+// ErrInvalidQueryParam is returned for any other type of argument. To assemble and
+// validate queries from Go code, use the ensql.Select query builder instead.
+func NewQuery(query string, args ...interface{}) (_ *api.Query, err error) {
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	q := &api.Query{Query: query}
+	if q.Params, err = ensql.Bind(args...); err != nil {
+		return nil, err
+	}
+	return q, nil
 }
 
 // Explain returns the query plan for the specified query, including the expected
@@ -2,6 +2,8 @@ package ensign_test
 
 import (
 	"context"
+	"math"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rotationalio/go-ensign"
@@ -9,6 +11,8 @@ import (
 	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
 	"github.com/rotationalio/go-ensign/mock"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -142,3 +146,188 @@ func (s *sdkTestSuite) TestEnSQL() {
 	_, err = s.client.EnSQL(ctx, query)
 	s.GRPCErrorIs(err, codes.InvalidArgument, "unparseable query")
 }
+
+func (s *sdkTestSuite) TestQuery() {
+	require := s.Require()
+	ctx := context.Background()
+
+	require.NoError(s.Authenticate(ctx))
+
+	var params []*api.Parameter
+	s.mock.OnEnSQL = func(in *api.Query, stream api.Ensign_EnSQLServer) (err error) {
+		params = in.Params
+		return stream.Send(mock.NewEventWrapper())
+	}
+
+	// An empty query returns an error without making a request
+	_, err := s.client.Query(ctx, "")
+	require.ErrorIs(err, ensign.ErrEmptyQuery)
+
+	// Unsupported parameter types return an error
+	_, err = s.client.Query(ctx, "SELECT * FROM topic WHERE meta.foo = $1", struct{}{})
+	require.ErrorIs(err, ensign.ErrInvalidQueryParam)
+
+	_, err = s.client.Query(ctx, "SELECT * FROM topic WHERE offset > $1", uint64(math.MaxUint64))
+	require.ErrorIs(err, ensign.ErrInvalidQueryParam)
+
+	ts := time.Date(2023, 8, 14, 12, 31, 3, 0, time.UTC)
+	cursor, err := s.client.Query(ctx, "SELECT * FROM topic WHERE meta.foo = $1 AND meta.count > $2", "bar' OR 1=1", uint16(42), 3.14, true, []byte{0x1}, ts)
+	require.NoError(err, "could not execute parameterized query")
+	require.NoError(cursor.Close())
+
+	expected := []*api.Parameter{
+		{Name: "$1", Value: &api.Parameter_S{S: "bar' OR 1=1"}},
+		{Name: "$2", Value: &api.Parameter_I{I: 42}},
+		{Name: "$3", Value: &api.Parameter_D{D: 3.14}},
+		{Name: "$4", Value: &api.Parameter_B{B: true}},
+		{Name: "$5", Value: &api.Parameter_Y{Y: []byte{0x1}}},
+		{Name: "$6", Value: &api.Parameter_S{S: "2023-08-14T12:31:03Z"}},
+	}
+
+	require.Len(params, len(expected), "expected all parameters to be sent to the server")
+	for i, param := range params {
+		require.True(proto.Equal(expected[i], param), "parameter %d does not match", i)
+	}
+}
+
+func (s *sdkTestSuite) TestQueryScan() {
+	require := s.Require()
+	ctx := context.Background()
+
+	require.NoError(s.Authenticate(ctx))
+
+	type person struct {
+		Name string `json:"name"`
+	}
+
+	events := []*api.Event{
+		{Data: []byte(`{"name": "Alice"}`), Mimetype: mimetype.ApplicationJSON},
+		{Data: []byte(`{"name": "Bob"}`), Mimetype: mimetype.ApplicationJSON},
+		{Data: []byte(`{"name": "Carol"}`), Mimetype: mimetype.ApplicationJSON},
+	}
+
+	s.mock.OnEnSQL = func(in *api.Query, stream api.Ensign_EnSQLServer) (err error) {
+		for _, event := range events {
+			wrapper := &api.EventWrapper{Committed: timestamppb.Now()}
+			if err = wrapper.Wrap(event); err != nil {
+				return err
+			}
+
+			if err = stream.Send(wrapper); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Scan one result at a time
+	cursor, err := s.client.Query(ctx, "SELECT * FROM people")
+	require.NoError(err)
+
+	var alice person
+	require.NoError(cursor.Scan(&alice))
+	require.Equal("Alice", alice.Name)
+
+	// Fetch the remaining results into a slice of structs
+	var people []person
+	require.NoError(cursor.FetchAllInto(&people))
+	require.Equal([]person{{"Bob"}, {"Carol"}}, people)
+
+	require.ErrorIs(cursor.Scan(&alice), ensign.ErrCursorClosed)
+
+	// Fetch all results into a slice of pointers
+	cursor, err = s.client.Query(ctx, "SELECT * FROM people")
+	require.NoError(err)
+
+	var ptrs []*person
+	require.NoError(cursor.FetchAllInto(&ptrs))
+	require.Len(ptrs, 3)
+	require.Equal("Carol", ptrs[2].Name)
+
+	// The destination must be a pointer to a slice
+	cursor, err = s.client.Query(ctx, "SELECT * FROM people")
+	require.NoError(err)
+	require.ErrorIs(cursor.FetchAllInto(people), ensign.ErrInvalidScanDest)
+	require.ErrorIs(cursor.FetchAllInto(&alice), ensign.ErrInvalidScanDest)
+
+	// Events with mimetypes that have no codec cannot be decoded
+	events = []*api.Event{{Data: []byte("hello world"), Mimetype: mimetype.TextPlain}}
+	cursor, err = s.client.Query(ctx, "SELECT * FROM messages")
+	require.NoError(err)
+	require.ErrorIs(cursor.Scan(&alice), ensign.ErrNoCodec)
+}
+
+func (s *sdkTestSuite) TestQueryEvents() {
+	require := s.Require()
+	ctx := context.Background()
+
+	require.NoError(s.Authenticate(ctx))
+
+	// The mock sends the number of events in the query then blocks if requested
+	block := make(chan struct{})
+	defer close(block)
+	s.mock.OnEnSQL = func(in *api.Query, stream api.Ensign_EnSQLServer) (err error) {
+		for i := int64(0); i < in.Params[0].GetI(); i++ {
+			if err = stream.Send(mock.NewEventWrapper()); err != nil {
+				return err
+			}
+		}
+
+		if in.Params[1].GetB() {
+			select {
+			case <-block:
+			case <-stream.Context().Done():
+			}
+		}
+		return nil
+	}
+
+	// All events are streamed and the channel is closed
+	cursor, err := s.client.Query(ctx, "SELECT * FROM topic LIMIT $1", 5, false)
+	require.NoError(err)
+
+	nEvents := 0
+	for event := range cursor.Events(ctx) {
+		require.NotNil(event)
+		nEvents++
+	}
+	require.Equal(5, nEvents)
+	require.NoError(cursor.Err())
+	require.ErrorIs(cursor.Scan(&struct{}{}), ensign.ErrCursorClosed, "expected cursor to be closed")
+
+	// Canceling the context interrupts a blocked stream
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cursor, err = s.client.Query(ctx, "SELECT * FROM topic LIMIT $1", 2, true)
+	require.NoError(err)
+
+	nEvents = 0
+	for range cursor.Events(cctx) {
+		nEvents++
+		if nEvents == 2 {
+			cancel()
+		}
+	}
+	require.Equal(2, nEvents)
+	require.ErrorIs(cursor.Err(), context.Canceled)
+}
+
+func (s *sdkTestSuite) TestEnSQLFixture() {
+	require := s.Require()
+	ctx := context.Background()
+
+	require.NoError(s.Authenticate(ctx))
+	require.NoError(s.mock.UseFixture(mock.EnSQLRPC, "testdata/ensql.pb.json"))
+
+	cursor, err := s.client.Query(ctx, "SELECT * FROM orders")
+	require.NoError(err)
+
+	// The scripted events are returned before the stream ends with the fixture error
+	nEvents := 0
+	for event := range cursor.Events(ctx) {
+		require.Equal("Person", event.Type.Name)
+		nEvents++
+	}
+	require.Equal(2, nEvents)
+	require.Equal(codes.Unavailable, status.Code(cursor.Err()))
+}
@@ -0,0 +1,77 @@
+package ensign
+
+import (
+	"context"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryAttempts is the maximum number of times an idempotent RPC is attempted
+// when retries are enabled with WithRetries and no maximum is specified.
+const DefaultRetryAttempts = 4
+
+// DefaultRetryCodes are the gRPC status codes of failed idempotent RPCs that are retried
+// when retries are enabled with WithRetries and no codes are specified.
+var DefaultRetryCodes = []codes.Code{codes.Unavailable}
+
+// The unary RPCs of the Ensign API that do not modify state on the server and are safe
+// to retry. RPCs that are not in this set are never retried by the client.
+var idempotentRPCs = map[string]struct{}{
+	api.Ensign_Explain_FullMethodName:       {},
+	api.Ensign_ListTopics_FullMethodName:    {},
+	api.Ensign_TopicNames_FullMethodName:    {},
+	api.Ensign_TopicExists_FullMethodName:   {},
+	api.Ensign_RetrieveTopic_FullMethodName: {},
+	api.Ensign_Info_FullMethodName:          {},
+	api.Ensign_Status_FullMethodName:        {},
+}
+
+// RetryOptions configure how idempotent unary RPCs such as ListTopics, TopicNames,
+// Explain, Info, and Status are retried by the client when they fail with a transient
+// error (see WithRetries). Zero values are replaced by the defaults when the client is
+// created; if Backoff is nil, the backoff policy of the client is used.
+type RetryOptions struct {
+	// The maximum number of times an RPC is attempted, including the first attempt.
+	MaxAttempts int
+
+	// The gRPC status codes that indicate an RPC can be retried.
+	Codes []codes.Code
+
+	// The backoff policy used to wait between attempts.
+	Backoff backoff.Policy
+}
+
+// Returns a unary client interceptor that retries idempotent RPCs that fail with one of
+// the retryable codes until the maximum number of attempts is reached, the backoff
+// policy stops, or the context of the RPC is done; the last error is returned.
+func (o *RetryOptions) interceptor() grpc.UnaryClientInterceptor {
+	retryable := make(map[codes.Code]struct{}, len(o.Codes))
+	for _, code := range o.Codes {
+		retryable[code] = struct{}{}
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		if _, ok := idempotentRPCs[method]; !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ticker := o.Backoff()
+		for attempt := 1; ; attempt++ {
+			if err = invoker(ctx, method, req, reply, cc, opts...); err == nil {
+				return nil
+			}
+
+			if _, ok := retryable[status.Code(err)]; !ok || attempt >= o.MaxAttempts {
+				return err
+			}
+
+			if backoff.Wait(ctx, ticker) != nil {
+				return err
+			}
+		}
+	}
+}
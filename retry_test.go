@@ -0,0 +1,88 @@
+package ensign_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetries(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	policy := func() backoff.Backoff { return &backoff.ConstantBackOff{Interval: time.Millisecond} }
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithRetries(sdk.RetryOptions{MaxAttempts: 3, Backoff: policy}))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	t.Run("Transient", func(t *testing.T) {
+		defer emock.Reset()
+		emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+			if emock.Calls[mock.StatusRPC] < 3 {
+				return nil, status.Error(codes.Unavailable, "ensign is restarting")
+			}
+			return &api.ServiceState{Status: api.ServiceState_HEALTHY}, nil
+		}
+
+		state, err := client.Status(context.Background())
+		require.NoError(t, err, "expected status to be retried")
+		require.Equal(t, api.ServiceState_HEALTHY, state.Status)
+		require.Equal(t, 3, emock.Calls[mock.StatusRPC])
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		defer emock.Reset()
+		emock.UseError(mock.ListTopicsRPC, codes.Unavailable, "ensign is down")
+
+		_, err := client.ListTopics(context.Background())
+		require.ErrorIs(t, err, sdk.ErrUnavailable)
+		require.Equal(t, 3, emock.Calls[mock.ListTopicsRPC])
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		defer emock.Reset()
+		emock.UseError(mock.InfoRPC, codes.PermissionDenied, "not allowed")
+
+		_, err := client.Info(context.Background())
+		require.ErrorIs(t, err, sdk.ErrUnauthorized)
+		require.Equal(t, 1, emock.Calls[mock.InfoRPC])
+	})
+
+	t.Run("NotIdempotent", func(t *testing.T) {
+		defer emock.Reset()
+		emock.UseError(mock.CreateTopicRPC, codes.Unavailable, "ensign is down")
+
+		_, err := client.CreateTopic(context.Background(), "testing.topics.retry")
+		require.ErrorIs(t, err, sdk.ErrUnavailable)
+		require.Equal(t, 1, emock.Calls[mock.CreateTopicRPC])
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		// The context deadline should stop the client waiting for the next attempt
+		policy := func() backoff.Backoff { return &backoff.ConstantBackOff{Interval: time.Hour} }
+		emock2 := mock.New(nil)
+		defer emock2.Shutdown()
+		emock2.UseError(mock.TopicNamesRPC, codes.Unavailable, "ensign is down")
+
+		slow, err := sdk.New(sdk.WithMock(emock2), sdk.WithAuthenticator("", true), sdk.WithRetries(sdk.RetryOptions{Backoff: policy}))
+		require.NoError(t, err, "could not create client")
+		defer slow.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+		defer cancel()
+		_, err = slow.TopicID(ctx, "testing.topics.retry")
+		require.ErrorIs(t, err, sdk.ErrUnavailable)
+		require.Equal(t, 1, emock2.Calls[mock.TopicNamesRPC])
+	})
+
+	_, err = sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithRetries(sdk.RetryOptions{MaxAttempts: -1}))
+	require.ErrorIs(t, err, sdk.ErrInvalidRetryAttempts)
+}
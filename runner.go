@@ -0,0 +1,257 @@
+package ensign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// EventHandler processes an event received from a subscription. If the handler returns
+// an error and has not acked or nacked the event, the event is nacked by the runner.
+type EventHandler func(*Event) error
+
+// Middleware wraps an event handler with behavior that is shared by many handlers, such
+// as logging, metrics, retries, or payload validation. The middleware should call the
+// next handler to continue processing the event or return an error to stop processing.
+type Middleware func(next EventHandler) EventHandler
+
+// Chain wraps the handler with the middleware so that the first middleware is the
+// outermost, e.g. Chain(h, a, b) processes events with a, then b, then h.
+func Chain(handler EventHandler, middleware ...Middleware) EventHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Ordering specifies how events are routed to the workers of a subscription runner in
+// order to guarantee that related events are processed sequentially.
+type Ordering uint8
+
+const (
+	// Unordered events are processed by any available worker; events may be processed
+	// concurrently and out of order with respect to each other (default).
+	Unordered Ordering = iota
+
+	// OrderByTopic events are routed to a worker by topic so that events in the same
+	// topic are processed in order while events in different topics are processed in
+	// parallel.
+	OrderByTopic
+
+	// OrderByKey events are routed to a worker by the event key so that events with
+	// the same key are processed in order while events with different keys are
+	// processed in parallel. Events without a key are processed by any worker.
+	OrderByKey
+
+	// OrderByMetadata events are routed to a worker by the value of a metadata field
+	// (see WithOrderedByMetadata) so that events for the same entity are processed in
+	// order even if the events are not keyed. Events without the field are processed by
+	// any worker.
+	OrderByMetadata
+)
+
+// RunOption configures how the events of a subscription are processed by Run.
+type RunOption func(o *RunOptions)
+
+// RunOptions configure the worker pool used to process events by Subscription.Run.
+type RunOptions struct {
+	// The number of concurrent workers to process events with; by default GOMAXPROCS.
+	Workers int
+
+	// How events are routed to workers to guarantee in-order processing; if ordered by
+	// metadata, events are routed by the value of the ordering field.
+	Ordering      Ordering
+	OrderingField string
+
+	// Middleware that wraps the handler, outermost first.
+	Middleware []Middleware
+}
+
+// WithWorkers specifies the number of workers that process events concurrently.
+func WithWorkers(n int) RunOption {
+	return func(o *RunOptions) {
+		o.Workers = n
+	}
+}
+
+// WithOrderedDelivery specifies how events are routed to workers so that events in the
+// same topic or with the same key are processed in order by a single worker while other
+// events are processed in parallel by the remaining workers. Use WithOrderedByMetadata
+// to order events by a metadata field rather than by the key of the event.
+func WithOrderedDelivery(ordering Ordering) RunOption {
+	return func(o *RunOptions) {
+		o.Ordering = ordering
+	}
+}
+
+// WithOrderedByMetadata routes events to workers by the value of the metadata field,
+// e.g. a customer or account ID, so that events with the same value are processed in
+// order by a single worker while events with other values are processed in parallel.
+func WithOrderedByMetadata(field string) RunOption {
+	return func(o *RunOptions) {
+		o.Ordering = OrderByMetadata
+		o.OrderingField = field
+	}
+}
+
+// WithMiddleware wraps the handler passed to Run with the middleware; the first
+// middleware is the outermost. If specified multiple times the middleware is appended.
+// Because the middleware is applied inside the runner, events that are nacked or acked
+// by the runner based on the handler's error reflect the error the middleware returns.
+func WithMiddleware(middleware ...Middleware) RunOption {
+	return func(o *RunOptions) {
+		o.Middleware = append(o.Middleware, middleware...)
+	}
+}
+
+// Run processes the events from the subscription with a pool of workers that call the
+// handler for each event, blocking until the subscription is closed or the context is
+// canceled. Events are delivered to workers according to the ordering specified by
+// WithOrderedDelivery, by default events are processed in parallel without ordering
+// guarantees. When the subscription is closed or the context is canceled, Run waits
+// for the workers to finish processing their events before returning. Run should not
+// be used in conjunction with reading events directly from the C channel. Events are
+// acked according to the AckMode of the subscription (see WithAckMode). If the
+// subscription terminates because the stream could not be reconnected, Run returns the
+// error that caused the subscription to terminate.
+func (c *Subscription) Run(ctx context.Context, handler EventHandler, opts ...RunOption) error {
+	options := RunOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Workers < 1 {
+		options.Workers = runtime.GOMAXPROCS(0)
+	}
+	handler = Chain(handler, options.Middleware...)
+
+	// Each worker gets its own queue so that ordered events are processed serially by
+	// a single worker; unordered events are placed on a shared queue.
+	var wg sync.WaitGroup
+	shared := make(chan *Event)
+	queues := make([]chan *Event, options.Workers)
+	for i := range queues {
+		queues[i] = make(chan *Event)
+		wg.Add(1)
+		go func(queue, shared <-chan *Event) {
+			defer wg.Done()
+			for {
+				select {
+				case event, ok := <-queue:
+					if !ok {
+						return
+					}
+					c.handle(handler, event)
+				case event, ok := <-shared:
+					if !ok {
+						shared = nil
+						continue
+					}
+					c.handle(handler, event)
+				}
+			}
+		}(queues[i], shared)
+	}
+
+	// Close the worker queues and wait for in-flight events to finish when returning.
+	defer func() {
+		close(shared)
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-c.C:
+			if !ok {
+				return c.Err()
+			}
+
+			queue := shared
+			if key := orderingKey(event, options); key != nil {
+				queue = queues[partition(key, len(queues))]
+			}
+
+			select {
+			case queue <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Returns the key used to route the event to a serialized worker or nil if the event
+// can be processed by any worker.
+func orderingKey(event *Event, options RunOptions) []byte {
+	if event.info == nil {
+		return nil
+	}
+
+	switch options.Ordering {
+	case OrderByTopic:
+		if len(event.info.TopicId) > 0 {
+			return event.info.TopicId
+		}
+	case OrderByKey:
+		if len(event.info.Key) > 0 {
+			return event.info.Key
+		}
+	case OrderByMetadata:
+		if value, ok := event.Metadata[options.OrderingField]; ok {
+			return []byte(value)
+		}
+	}
+	return nil
+}
+
+// Returns the index of the worker that the key is consistently routed to.
+func partition(key []byte, n int) int {
+	hash := fnv.New32a()
+	hash.Write(key)
+	return int(hash.Sum32() % uint32(n))
+}
+
+// Calls the handler and nacks the event if the handler returns an error without having
+// acked or nacked the event. If the subscription acks on handler success, the event is
+// acked if the handler returns nil without having acked or nacked the event. If the
+// subscription has a dead letter topic, events that cause the handler to panic are
+// moved to the dead letter topic rather than crashing the consumer.
+func (c *Subscription) handle(handler EventHandler, event *Event) {
+	ctx, span := c.tracer.Start(event.Context(), HandleSpanName)
+	event.SetContext(ctx)
+	defer span.End()
+
+	if c.dlq != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				reason := fmt.Sprintf("panic: %v", r)
+				span.RecordError(errors.New(reason))
+				if !event.deadLetter(reason) && c.log != nil {
+					c.log.Error("could not dead letter event after handler panic", "client_id", c.ClientID(), "event_id", event.ID(), "reason", reason)
+				}
+			}
+		}()
+	}
+
+	if err := handler(event); err != nil {
+		span.RecordError(err)
+		if !event.handled() {
+			event.Nack(api.Nack_UNPROCESSED)
+		}
+		return
+	}
+
+	if c.opts.AckMode == AckOnHandlerSuccess && !event.handled() {
+		event.Ack()
+	}
+}
@@ -0,0 +1,275 @@
+package ensign_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionRun(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	var nacks sync.WaitGroup
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		defer nacks.Done()
+		require.Equal(t, api.Nack_UNPROCESSED, in.Code)
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.Subscribe("testing.topics.topica", "testing.topics.topicb")
+	require.NoError(t, err, "could not subscribe")
+
+	factories := []*mock.EventFactory{
+		{Topic: ulid.MustParse("01GWM89049D49FHJH81BT8795H")},
+		{Topic: ulid.MustParse("01GWM936SNSN36JKTMSF9Q3N8B")},
+	}
+
+	var (
+		mu    sync.Mutex
+		seen  = make(map[string][]uint64)
+		count sync.WaitGroup
+	)
+
+	nEvents := 100
+	count.Add(2 * nEvents)
+	nacks.Add(2 * nEvents / 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Run(ctx, func(event *sdk.Event) error {
+			defer count.Done()
+
+			// Jitter processing so that unordered events would be interleaved.
+			time.Sleep(time.Duration(rand.Int63n(int64(time.Millisecond))))
+
+			offset, _ := event.Offset()
+			mu.Lock()
+			seen[event.TopicID()] = append(seen[event.TopicID()], offset)
+			mu.Unlock()
+
+			if offset%10 == 0 {
+				return errors.New("could not handle event")
+			}
+
+			event.Ack()
+			return nil
+		}, sdk.WithWorkers(4), sdk.WithOrderedDelivery(sdk.OrderByTopic))
+	}()
+
+	for i := 0; i < nEvents; i++ {
+		for _, factory := range factories {
+			handler.Send <- factory.Make()
+		}
+	}
+
+	count.Wait()
+	nacks.Wait()
+
+	// Events in each topic should have been processed in order
+	require.Len(t, seen, 2)
+	for topic, offsets := range seen {
+		require.Len(t, offsets, nEvents)
+		for i, offset := range offsets {
+			require.Equal(t, uint64(i+1), offset, "events for topic %s processed out of order", topic)
+		}
+	}
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscriptionRunOrderedByKey(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	// Creates events for accounts keyed by the wrapper key and the account metadata.
+	entities := []string{"alpha", "bravo", "charlie"}
+	makeEvent := func(entity string, seq int) *api.EventWrapper {
+		wrapper := mock.NewEventWrapper()
+		wrapper.Key = []byte(entity)
+		event, err := wrapper.Unwrap()
+		require.NoError(t, err)
+		event.Metadata = map[string]string{"account": entity, "seq": strconv.Itoa(seq)}
+		require.NoError(t, wrapper.Wrap(event))
+		return wrapper
+	}
+
+	testCases := []struct {
+		name string
+		opt  sdk.RunOption
+	}{
+		{"key", sdk.WithOrderedDelivery(sdk.OrderByKey)},
+		{"metadata", sdk.WithOrderedByMetadata("account")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := mock.NewSubscribeHandler()
+			emock.OnSubscribe = handler.OnSubscribe
+			defer handler.Shutdown()
+
+			sub, err := client.Subscribe("testing.topics.topica")
+			require.NoError(t, err, "could not subscribe")
+			defer sub.Close()
+
+			var (
+				mu    sync.Mutex
+				seen  = make(map[string][]int)
+				count sync.WaitGroup
+			)
+
+			nEvents := 50
+			count.Add(len(entities) * nEvents)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- sub.Run(ctx, func(event *sdk.Event) error {
+					defer count.Done()
+
+					// Jitter processing so that unordered events would be interleaved.
+					time.Sleep(time.Duration(rand.Int63n(int64(time.Millisecond))))
+
+					seq, _ := strconv.Atoi(event.Metadata.Get("seq"))
+					mu.Lock()
+					seen[event.Metadata.Get("account")] = append(seen[event.Metadata.Get("account")], seq)
+					mu.Unlock()
+
+					event.Ack()
+					return nil
+				}, sdk.WithWorkers(4), tc.opt)
+			}()
+
+			for i := 0; i < nEvents; i++ {
+				for _, entity := range entities {
+					handler.Send <- makeEvent(entity, i)
+				}
+			}
+			count.Wait()
+
+			// Events for each entity should have been processed in order
+			require.Len(t, seen, len(entities))
+			for entity, seqs := range seen {
+				require.Len(t, seqs, nEvents)
+				for i, seq := range seqs {
+					require.Equal(t, i, seq, "events for %s processed out of order", entity)
+				}
+			}
+
+			cancel()
+			require.ErrorIs(t, <-done, context.Canceled)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	trace := func(name string) sdk.Middleware {
+		return func(next sdk.EventHandler) sdk.EventHandler {
+			return func(event *sdk.Event) error {
+				calls = append(calls, name)
+				return next(event)
+			}
+		}
+	}
+
+	// Middleware is applied outermost first
+	handler := sdk.Chain(func(*sdk.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}, trace("first"), trace("second"))
+	require.NoError(t, handler(NewEvent()))
+	require.Equal(t, []string{"first", "second", "handler"}, calls)
+
+	// Middleware is applied to the handler of the subscription runner
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	nacks := make(chan *api.Nack, 1)
+	handlerMock := mock.NewSubscribeHandler()
+	handlerMock.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handlerMock.OnSubscribe
+
+	sub, err := client.Subscribe("testing.topics.topica")
+	require.NoError(t, err, "could not subscribe")
+
+	// Validation middleware rejects empty payloads before they reach the handler
+	validate := func(next sdk.EventHandler) sdk.EventHandler {
+		return func(event *sdk.Event) error {
+			if len(event.Data) == 0 {
+				return errors.New("empty payload")
+			}
+			return next(event)
+		}
+	}
+
+	handled := make(chan *sdk.Event, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Run(ctx, func(event *sdk.Event) error {
+			handled <- event
+			event.Ack()
+			return nil
+		}, sdk.WithWorkers(1), sdk.WithMiddleware(validate))
+	}()
+
+	empty := mock.NewEventWrapper()
+	event, err := empty.Unwrap()
+	require.NoError(t, err)
+	event.Data = nil
+	require.NoError(t, empty.Wrap(event))
+	handlerMock.Send <- empty
+
+	select {
+	case nack := <-nacks:
+		require.Equal(t, empty.Id, nack.Id)
+	case <-time.After(time.Second):
+		t.Fatal("expected invalid event to be nacked by the runner")
+	}
+
+	handlerMock.Send <- mock.NewEventWrapper()
+	select {
+	case event := <-handled:
+		require.NotEmpty(t, event.Data)
+	case <-time.After(time.Second):
+		t.Fatal("expected valid event to be handled")
+	}
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	handlerMock.Shutdown()
+	require.NoError(t, sub.Close())
+}
@@ -0,0 +1,23 @@
+package ensign
+
+import (
+	"context"
+)
+
+// Validates the payload of the event against the schema of its type if the client has
+// a schema registry; events without a type are not validated.
+func (c *Client) validate(ctx context.Context, event *Event) error {
+	if c.opts.Schemas == nil || event.Type == nil {
+		return nil
+	}
+	return c.opts.Schemas.Validate(ctx, event.Type, event.Mimetype, event.Data)
+}
+
+// Validates the payload of a received event against the schema of its type if the
+// subscription has a schema registry; events without a type are not validated.
+func (c *Subscription) validate(event *Event) error {
+	if c.schemas == nil || event.Type == nil {
+		return nil
+	}
+	return c.schemas.Validate(event.Context(), event.Type, event.Mimetype, event.Data)
+}
@@ -0,0 +1,146 @@
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// JSONSchema validates JSON payloads using a subset of JSON Schema: the type,
+// properties, required, additionalProperties, items, and enum keywords. Other keywords
+// are ignored so that schemas from a registry can be used even if they cannot be fully
+// enforced by the SDK.
+type JSONSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+}
+
+var _ Schema = &JSONSchema{}
+
+// ParseJSONSchema parses a JSON Schema document.
+func ParseJSONSchema(data []byte) (schema *JSONSchema, err error) {
+	schema = &JSONSchema{}
+	if err = json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("could not parse json schema: %w", err)
+	}
+	return schema, nil
+}
+
+// Validate the JSON data; only application/json data can be validated.
+func (s *JSONSchema) Validate(mime mimetype.MIME, data []byte) (err error) {
+	switch mime {
+	case mimetype.ApplicationJSON, mimetype.ApplicationJSONLD:
+	default:
+		return fmt.Errorf("%w %s", ErrUnsupportedMimetype, mime.MimeType())
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPayload, err)
+	}
+
+	if err = s.validate("$", value); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPayload, err)
+	}
+	return nil
+}
+
+func (s *JSONSchema) validate(path string, value interface{}) (err error) {
+	if s.Type != "" && !isType(s.Type, value) {
+		return fmt.Errorf("%s must be of type %s", path, s.Type)
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		return fmt.Errorf("%s must be one of the enumerated values", path)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+
+		for name, field := range v {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+
+			if err = prop.validate(path+"."+name, field); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if err = s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func isType(kind string, value interface{}) bool {
+	switch kind {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		num, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := num.Float64()
+		return err == nil && f == math.Trunc(f)
+	default:
+		// Unknown types cannot be enforced.
+		return true
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	// Enum values are decoded without UseNumber so numbers are compared as float64.
+	if num, ok := value.(json.Number); ok {
+		if f, err := num.Float64(); err == nil {
+			value = f
+		}
+	}
+
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
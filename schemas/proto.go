@@ -0,0 +1,73 @@
+package schemas
+
+import (
+	"fmt"
+
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtoSchema validates protocol buffer payloads against a message descriptor. Payloads
+// are invalid if they cannot be unmarshaled as the message, if they contain fields
+// that are not defined by the message, or if required fields are missing.
+type ProtoSchema struct {
+	desc protoreflect.MessageDescriptor
+}
+
+var _ Schema = &ProtoSchema{}
+
+// Proto returns a schema that validates payloads as the type of the message.
+func Proto(m proto.Message) *ProtoSchema {
+	return ProtoDescriptor(m.ProtoReflect().Descriptor())
+}
+
+// ProtoDescriptor returns a schema that validates payloads as the described message,
+// e.g. a descriptor resolved from a file descriptor set fetched from a registry.
+func ProtoDescriptor(desc protoreflect.MessageDescriptor) *ProtoSchema {
+	return &ProtoSchema{desc: desc}
+}
+
+// Validate the protocol buffer data; only application/protobuf data can be validated.
+func (s *ProtoSchema) Validate(mime mimetype.MIME, data []byte) (err error) {
+	if mime != mimetype.ApplicationProtobuf {
+		return fmt.Errorf("%w %s", ErrUnsupportedMimetype, mime.MimeType())
+	}
+
+	msg := dynamicpb.NewMessage(s.desc)
+	if err = proto.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPayload, err)
+	}
+
+	if hasUnknown(msg) {
+		return fmt.Errorf("%w: payload contains fields not defined by %s", ErrInvalidPayload, s.desc.FullName())
+	}
+	return nil
+}
+
+// Returns true if the message or any of its nested messages have unknown fields.
+func hasUnknown(msg protoreflect.Message) (unknown bool) {
+	if len(msg.GetUnknown()) > 0 {
+		return true
+	}
+
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len() && !unknown; i++ {
+				unknown = hasUnknown(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				unknown = hasUnknown(mv.Message())
+				return !unknown
+			})
+		case fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			unknown = hasUnknown(v.Message())
+		}
+		return !unknown
+	})
+	return unknown
+}
@@ -0,0 +1,167 @@
+/*
+Package schemas validates event payloads against the schemas of their event types so
+that producers and consumers agree on the structure of the data in a topic. Schemas are
+registered with or fetched by a Registry, which caches them by event type and version.
+The Ensign client validates events before they are published and after they are
+received when it is created with ensign.WithSchemaRegistry.
+*/
+package schemas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// DefaultTimeout bounds requests to fetch schemas from the source of a registry when
+// the caller does not specify a deadline.
+const DefaultTimeout = 15 * time.Second
+
+var (
+	ErrSchemaNotFound      = errors.New("schema not found")
+	ErrInvalidPayload      = errors.New("event payload does not match schema")
+	ErrUnsupportedMimetype = errors.New("schema cannot validate payloads with mimetype")
+	ErrNoEventType         = errors.New("event type is required to lookup a schema")
+)
+
+// Schema validates the payload of events of a specific type. Validate should return
+// an error wrapping ErrInvalidPayload if the data does not match the schema or an error
+// wrapping ErrUnsupportedMimetype if the schema cannot validate data of the mimetype.
+type Schema interface {
+	Validate(mime mimetype.MIME, data []byte) error
+}
+
+// Source fetches the schema of an event type, e.g. from a schema registry service.
+// Fetch must return ErrSchemaNotFound if the event type has no schema.
+type Source interface {
+	Fetch(ctx context.Context, eventType *api.Type) (Schema, error)
+}
+
+// SourceFunc allows a function to be used as a Source.
+type SourceFunc func(ctx context.Context, eventType *api.Type) (Schema, error)
+
+// Fetch calls the function.
+func (f SourceFunc) Fetch(ctx context.Context, eventType *api.Type) (Schema, error) {
+	return f(ctx, eventType)
+}
+
+// Registry caches the schemas of event types keyed by the type name and semantic
+// version. Schemas can be registered directly or are fetched from the source of the
+// registry the first time they are needed. A Registry is safe for concurrent use.
+type Registry struct {
+	sync.RWMutex
+	source  Source
+	schemas map[string]Schema
+}
+
+// NewRegistry creates a registry that fetches schemas that have not been registered
+// from the source. The source may be nil if all schemas are registered with Register.
+func NewRegistry(source Source) *Registry {
+	return &Registry{
+		source:  source,
+		schemas: make(map[string]Schema),
+	}
+}
+
+// Register the schema of the event type, replacing any cached schema for the type.
+func (r *Registry) Register(eventType *api.Type, schema Schema) {
+	r.Lock()
+	defer r.Unlock()
+	r.schemas[key(eventType)] = schema
+}
+
+// Lookup returns the schema of the event type from the cache without fetching it; the
+// second return value is false if the schema is not in the cache.
+func (r *Registry) Lookup(eventType *api.Type) (schema Schema, cached bool) {
+	r.RLock()
+	defer r.RUnlock()
+	schema, cached = r.schemas[key(eventType)]
+	return schema, cached
+}
+
+// Get the schema of the event type, fetching it from the source and caching it if it
+// is not already in the cache. Returns ErrSchemaNotFound if the schema is not cached
+// and the registry has no source.
+func (r *Registry) Get(ctx context.Context, eventType *api.Type) (schema Schema, err error) {
+	if eventType == nil || eventType.Name == "" {
+		return nil, ErrNoEventType
+	}
+
+	var cached bool
+	if schema, cached = r.Lookup(eventType); cached {
+		return schema, nil
+	}
+
+	if r.source == nil {
+		return nil, fmt.Errorf("%w for %s", ErrSchemaNotFound, describe(eventType))
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	if schema, err = r.source.Fetch(ctx, eventType); err != nil {
+		if errors.Is(err, ErrSchemaNotFound) {
+			return nil, fmt.Errorf("%w for %s", ErrSchemaNotFound, describe(eventType))
+		}
+		return nil, fmt.Errorf("could not fetch schema for %s: %w", describe(eventType), err)
+	}
+
+	r.Register(eventType, schema)
+	return schema, nil
+}
+
+// Validate the payload of an event of the specified type and mimetype against the
+// schema of the type.
+func (r *Registry) Validate(ctx context.Context, eventType *api.Type, mime mimetype.MIME, data []byte) (err error) {
+	var schema Schema
+	if schema, err = r.Get(ctx, eventType); err != nil {
+		return err
+	}
+
+	if err = schema.Validate(mime, data); err != nil {
+		return fmt.Errorf("%s: %w", describe(eventType), err)
+	}
+	return nil
+}
+
+// Delete the schema of the event type from the cache so that it is fetched again.
+func (r *Registry) Delete(eventType *api.Type) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.schemas, key(eventType))
+}
+
+// Clear all of the schemas in the cache.
+func (r *Registry) Clear() {
+	r.Lock()
+	defer r.Unlock()
+	for key := range r.schemas {
+		delete(r.schemas, key)
+	}
+}
+
+// Length returns the number of schemas in the cache.
+func (r *Registry) Length() int {
+	r.RLock()
+	defer r.RUnlock()
+	return len(r.schemas)
+}
+
+func key(eventType *api.Type) string {
+	if eventType == nil {
+		return ""
+	}
+	return eventType.Version()
+}
+
+func describe(eventType *api.Type) string {
+	return "event type " + key(eventType)
+}
@@ -0,0 +1,145 @@
+package schemas_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	. "github.com/rotationalio/go-ensign/schemas"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRegistry(t *testing.T) {
+	orderPlaced := &api.Type{Name: "OrderPlaced", MajorVersion: 1}
+	schema, err := ParseJSONSchema([]byte(`{"type": "object", "required": ["id"]}`))
+	require.NoError(t, err)
+
+	// A registry without a source only has the registered schemas
+	registry := NewRegistry(nil)
+	_, err = registry.Get(context.Background(), orderPlaced)
+	require.ErrorIs(t, err, ErrSchemaNotFound)
+	require.EqualError(t, err, "schema not found for event type OrderPlaced v1.0.0")
+
+	registry.Register(orderPlaced, schema)
+	require.Equal(t, 1, registry.Length())
+
+	actual, err := registry.Get(context.Background(), orderPlaced)
+	require.NoError(t, err)
+	require.Same(t, schema, actual)
+
+	// Versions are registered separately
+	_, cached := registry.Lookup(&api.Type{Name: "OrderPlaced", MajorVersion: 2})
+	require.False(t, cached)
+
+	_, err = registry.Get(context.Background(), &api.Type{})
+	require.ErrorIs(t, err, ErrNoEventType)
+
+	require.NoError(t, registry.Validate(context.Background(), orderPlaced, mimetype.ApplicationJSON, []byte(`{"id": 1}`)))
+	err = registry.Validate(context.Background(), orderPlaced, mimetype.ApplicationJSON, []byte(`{}`))
+	require.ErrorIs(t, err, ErrInvalidPayload)
+	require.EqualError(t, err, "event type OrderPlaced v1.0.0: event payload does not match schema: $.id is required")
+
+	registry.Delete(orderPlaced)
+	require.Zero(t, registry.Length())
+}
+
+func TestRegistrySource(t *testing.T) {
+	calls := 0
+	schema := Proto(&api.Type{})
+	registry := NewRegistry(SourceFunc(func(ctx context.Context, eventType *api.Type) (Schema, error) {
+		calls++
+		_, hasDeadline := ctx.Deadline()
+		require.True(t, hasDeadline, "expected fetch to have a deadline")
+
+		switch eventType.Name {
+		case "Type":
+			return schema, nil
+		case "Broken":
+			return nil, errors.New("registry unavailable")
+		default:
+			return nil, ErrSchemaNotFound
+		}
+	}))
+
+	// Schemas are fetched from the source once and then cached
+	for i := 0; i < 3; i++ {
+		actual, err := registry.Get(context.Background(), &api.Type{Name: "Type"})
+		require.NoError(t, err)
+		require.Same(t, schema, actual)
+	}
+	require.Equal(t, 1, calls)
+
+	_, err := registry.Get(context.Background(), &api.Type{Name: "Missing"})
+	require.ErrorIs(t, err, ErrSchemaNotFound)
+
+	_, err = registry.Get(context.Background(), &api.Type{Name: "Broken"})
+	require.EqualError(t, err, "could not fetch schema for event type Broken v0.0.0: registry unavailable")
+
+	registry.Clear()
+	require.Zero(t, registry.Length())
+}
+
+func TestProtoSchema(t *testing.T) {
+	schema := Proto(&api.Type{})
+
+	data, err := proto.Marshal(&api.Type{Name: "OrderPlaced", MajorVersion: 1})
+	require.NoError(t, err)
+	require.NoError(t, schema.Validate(mimetype.ApplicationProtobuf, data))
+
+	// Fields that are not defined by the message are invalid
+	data, err = proto.Marshal(&api.Topic{Name: "orders", ProjectId: []byte{0x01}, Offset: 42})
+	require.NoError(t, err)
+	require.ErrorIs(t, schema.Validate(mimetype.ApplicationProtobuf, data), ErrInvalidPayload)
+
+	require.ErrorIs(t, schema.Validate(mimetype.ApplicationProtobuf, []byte{0xff, 0xff}), ErrInvalidPayload)
+	require.ErrorIs(t, schema.Validate(mimetype.ApplicationJSON, data), ErrUnsupportedMimetype)
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{
+		"type": "object",
+		"required": ["id", "status"],
+		"additionalProperties": false,
+		"properties": {
+			"id": {"type": "integer"},
+			"status": {"type": "string", "enum": ["placed", "shipped"]},
+			"total": {"type": "number"},
+			"items": {"type": "array", "items": {"type": "object", "required": ["sku"]}}
+		}
+	}`))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		data string
+		err  string
+	}{
+		{`{"id": 1, "status": "placed"}`, ""},
+		{`{"id": 1, "status": "shipped", "total": 9.99, "items": [{"sku": "a"}, {"sku": "b", "qty": 2}]}`, ""},
+		{`{"id": 1.5, "status": "placed"}`, "$.id must be of type integer"},
+		{`{"id": 1}`, "$.status is required"},
+		{`{"id": 1, "status": "lost"}`, "$.status must be one of the enumerated values"},
+		{`{"id": 1, "status": "placed", "total": "9.99"}`, "$.total must be of type number"},
+		{`{"id": 1, "status": "placed", "items": [{"qty": 2}]}`, "$.items[0].sku is required"},
+		{`{"id": 1, "status": "placed", "color": "red"}`, "$.color is not allowed"},
+		{`[]`, "$ must be of type object"},
+		{`{"id": `, "unexpected EOF"},
+	}
+
+	for _, tc := range testCases {
+		err := schema.Validate(mimetype.ApplicationJSON, []byte(tc.data))
+		if tc.err == "" {
+			require.NoError(t, err, tc.data)
+			continue
+		}
+		require.ErrorIs(t, err, ErrInvalidPayload, tc.data)
+		require.EqualError(t, err, "event payload does not match schema: "+tc.err, tc.data)
+	}
+
+	require.ErrorIs(t, schema.Validate(mimetype.ApplicationMsgPack, []byte{}), ErrUnsupportedMimetype)
+
+	_, err = ParseJSONSchema([]byte(`{"type": 1}`))
+	require.Error(t, err)
+}
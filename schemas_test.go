@@ -0,0 +1,86 @@
+package ensign_test
+
+import (
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	orderPlaced := &api.Type{Name: "OrderPlaced", MajorVersion: 1}
+	schema, err := schemas.ParseJSONSchema([]byte(`{"type": "object", "required": ["id"]}`))
+	require.NoError(t, err)
+
+	registry := schemas.NewRegistry(nil)
+	registry.Register(orderPlaced, schema)
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithSchemaRegistry(registry))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	// Events that do not match their schema should not be published
+	invalid := &sdk.Event{Type: orderPlaced, Mimetype: mimetype.ApplicationJSON, Data: []byte(`{"total": 9.99}`)}
+	err = client.Publish("01GWM89049D49FHJH81BT8795H", invalid)
+	require.ErrorIs(t, err, schemas.ErrInvalidPayload)
+
+	// Events without a type and events that match their schema should be published
+	valid := &sdk.Event{Type: orderPlaced, Mimetype: mimetype.ApplicationJSON, Data: []byte(`{"id": 1}`)}
+	require.NoError(t, client.Publish("01GWM89049D49FHJH81BT8795H", &sdk.Event{Data: []byte("untyped")}, valid))
+	_, err = valid.Wait()
+	require.NoError(t, err, "event was not acked")
+	require.Len(t, recorder.Published(), 2)
+	mock.AssertNotPublished(t, recorder, mock.PayloadJSONEquals(`{"total": 9.99}`))
+
+	// Received events that do not match their schema should be nacked
+	nacks := make(chan *api.Nack, 2)
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.Subscribe("testing.topics.topica")
+	require.NoError(t, err, "could not create subscriber")
+	defer sub.Close()
+	defer handler.Shutdown()
+
+	wrap := func(event *api.Event) *api.EventWrapper {
+		wrapper := mock.NewEventWrapper()
+		require.NoError(t, wrapper.Wrap(event))
+		return wrapper
+	}
+
+	handler.Send <- wrap(&api.Event{Type: orderPlaced, Mimetype: mimetype.ApplicationJSON, Data: []byte(`{}`)})
+	handler.Send <- wrap(&api.Event{Type: &api.Type{Name: "Unknown"}, Mimetype: mimetype.ApplicationJSON, Data: []byte(`{}`)})
+
+	for _, code := range []api.Nack_Code{api.Nack_UNPROCESSED, api.Nack_UNKNOWN_TYPE} {
+		select {
+		case nack := <-nacks:
+			require.Equal(t, code, nack.Code)
+		case <-time.After(time.Second):
+			t.Fatal("expected invalid event to be nacked")
+		}
+	}
+
+	handler.Send <- wrap(&api.Event{Type: orderPlaced, Mimetype: mimetype.ApplicationJSON, Data: []byte(`{"id": 2}`)})
+	select {
+	case event := <-sub.C:
+		require.JSONEq(t, `{"id": 2}`, string(event.Data))
+	case <-time.After(time.Second):
+		t.Fatal("expected valid event to be delivered")
+	}
+}
@@ -0,0 +1,69 @@
+package ensign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Signs the events published by a client with the signing key of the client so that
+// subscribers can verify which publisher the event came from. HMAC-SHA256 is used since
+// it is the only signature algorithm that the event wrapper supports; the key ID is
+// stored in the public key ID field of the wrapper so that the subscriber can look up
+// the secret that is shared with the publisher.
+type signer struct {
+	keyID  string
+	secret []byte
+}
+
+// Returns the signer of the client or nil if published events are not signed.
+func (o *Options) signer() *signer {
+	if o.SigningKeyID == "" {
+		return nil
+	}
+	return &signer{keyID: o.SigningKeyID, secret: o.SigningKey}
+}
+
+// Signs the marshaled event in the wrapper; it is used as a stream.PublishOption so it
+// is applied after the event is wrapped and before it is sent to the server.
+func (s *signer) sign(env *api.EventWrapper) {
+	if env.Encryption == nil {
+		env.Encryption = &api.Encryption{}
+	}
+
+	env.Encryption.PublicKeyId = s.keyID
+	env.Encryption.SignatureAlgorithm = api.Encryption_HMAC_SHA256
+	env.Encryption.Signature = signature(s.secret, env.Event)
+}
+
+// Verifies the signature of the event in the wrapper using the secret of the key that
+// signed the event. Events that are not signed or that are signed by an unknown key
+// cannot be verified and return an error.
+func verifySignature(wrapper *api.EventWrapper, keys map[string][]byte) error {
+	enc := wrapper.Encryption
+	if enc == nil || enc.SignatureAlgorithm == api.Encryption_PLAINTEXT {
+		return ErrUnsignedEvent
+	}
+
+	if enc.SignatureAlgorithm != api.Encryption_HMAC_SHA256 {
+		return fmt.Errorf("%w: unsupported signature algorithm %s", ErrInvalidSignature, enc.SignatureAlgorithm)
+	}
+
+	secret, ok := keys[enc.PublicKeyId]
+	if !ok {
+		return fmt.Errorf("%w: unknown signing key %q", ErrInvalidSignature, enc.PublicKeyId)
+	}
+
+	if !hmac.Equal(enc.Signature, signature(secret, wrapper.Event)) {
+		return fmt.Errorf("%w: event was not signed by key %q", ErrInvalidSignature, enc.PublicKeyId)
+	}
+	return nil
+}
+
+func signature(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
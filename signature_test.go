@@ -0,0 +1,105 @@
+package ensign_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSignatures(t *testing.T) {
+	_, err := sdk.New(sdk.WithMock(mock.New(nil)), sdk.WithAuthenticator("", true), sdk.WithSigningKey("", []byte("secret")))
+	require.ErrorIs(t, err, sdk.ErrInvalidSigningKey)
+
+	// Publish events signed by two different publishers
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	recorder := mock.NewPublishRecorder(map[string]ulid.ULID{"orders": topicID})
+	emock.OnPublish = recorder.OnPublish
+
+	keys := map[string][]byte{"billing": []byte("billing-secret"), "shipping": []byte("shipping-secret")}
+	for keyID, secret := range keys {
+		publisher, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithSigningKey(keyID, secret))
+		require.NoError(t, err, "could not create publisher")
+
+		event := NewEvent()
+		require.NoError(t, publisher.Publish("orders", event))
+		_, err = event.Wait()
+		require.NoError(t, err, "event was not acked")
+		require.Equal(t, keyID, event.SignedBy())
+		require.Equal(t, api.Encryption_HMAC_SHA256, event.Info().Encryption.SignatureAlgorithm)
+		publisher.Close()
+	}
+
+	published := recorder.Published()
+	require.Len(t, published, 2)
+
+	// Subscribers only deliver events that are signed by a known key
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	nacks := make(chan *api.Nack, 4)
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithVerifySignatures(map[string][]byte{"billing": keys["billing"]}))
+	require.NoError(t, err, "could not create subscriber")
+	defer sub.Close()
+	defer handler.Shutdown()
+
+	signed := func(keyID string) *api.EventWrapper {
+		for _, pub := range published {
+			if pub.Wrapper.Encryption.PublicKeyId == keyID {
+				wrapper := proto.Clone(pub.Wrapper).(*api.EventWrapper)
+				wrapper.Id = mock.NewEventWrapper().Id
+				return wrapper
+			}
+		}
+		t.Fatalf("no event signed by %q", keyID)
+		return nil
+	}
+
+	requireNacked := func(wrapper *api.EventWrapper, msg string) {
+		handler.Send <- wrapper
+		select {
+		case nack := <-nacks:
+			require.Equal(t, wrapper.Id, nack.Id)
+			require.Equal(t, api.Nack_UNPROCESSED, nack.Code)
+			require.Contains(t, nack.Error, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("expected event to be nacked: %s", msg)
+		}
+	}
+
+	requireNacked(mock.NewEventWrapper(), sdk.ErrUnsignedEvent.Error())
+	requireNacked(signed("shipping"), `unknown signing key "shipping"`)
+
+	tampered := signed("billing")
+	event, err := tampered.Unwrap()
+	require.NoError(t, err, "could not unwrap event")
+	event.Data = []byte("tampered")
+	require.NoError(t, tampered.Wrap(event), "could not wrap event")
+	requireNacked(tampered, `event was not signed by key "billing"`)
+
+	valid := signed("billing")
+	handler.Send <- valid
+	select {
+	case event := <-sub.C:
+		require.Equal(t, "billing", event.SignedBy())
+	case <-time.After(time.Second):
+		t.Fatal("expected signed event to be delivered")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Package source provides helpers to quickly wire external data sources such as files and
+FIFOs into Ensign topics. A Source produces events, e.g. one event per line of a file,
+and Run publishes the events to a topic, notifying the source when each event has been
+acked by Ensign so that the source can checkpoint its position and resume from it
+without republishing events that have already been committed.
+*/
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/rotationalio/go-ensign"
+)
+
+var (
+	ErrInvalidRecord = errors.New("invalid record")
+	ErrClosed        = errors.New("source is closed")
+)
+
+// Source produces events to publish. Next blocks until the next event is available or
+// the context is done and returns io.EOF when the source has no more events. Ack is
+// called with each event once it has been acked by Ensign, in the order the events were
+// produced, so that the source can checkpoint its position.
+type Source interface {
+	Next(ctx context.Context) (*ensign.Event, error)
+	Ack(event *ensign.Event) error
+	Close() error
+}
+
+// Run publishes the events of the source to the topic until the source returns io.EOF,
+// the context is done, or an error occurs. Events are published without waiting for the
+// previous events to be acked; Ack is called on the source as events are acked in the
+// order they were produced. When the source is exhausted, Run waits for the remaining
+// events to be acked before returning nil. If an event is nacked, the nack error is
+// returned and the events after it are not acked on the source, so that they are
+// produced again when the source resumes from its checkpoint. The source is not closed.
+func Run(ctx context.Context, pub ensign.Publisher, topic string, src Source) (err error) {
+	var pending []*ensign.Event
+
+	// Ack the source with the events that have been acked, in order, stopping at the
+	// first event that has not been acked yet unless wait is true.
+	ack := func(wait bool) error {
+		for len(pending) > 0 {
+			var acked bool
+			if wait {
+				acked, err = pending[0].WaitForAck(ctx)
+			} else {
+				acked, err = pending[0].Acked()
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if !acked {
+				return nil
+			}
+
+			if err = src.Ack(pending[0]); err != nil {
+				return err
+			}
+			pending = pending[1:]
+		}
+		return nil
+	}
+
+	for {
+		var event *ensign.Event
+		if event, err = src.Next(ctx); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ack(true)
+			}
+			return err
+		}
+
+		if err = pub.PublishContext(ctx, topic, event); err != nil {
+			return err
+		}
+
+		pending = append(pending, event)
+		if err = ack(false); err != nil {
+			return err
+		}
+	}
+}
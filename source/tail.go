@@ -0,0 +1,290 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// Metadata keys added to the events produced by a Tailer.
+const (
+	PathKey   = "source_path"
+	OffsetKey = "source_offset"
+)
+
+// DefaultPollInterval is how often a Tailer that follows a file checks for new data.
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Tailer is a Source that reads newline-delimited records from a file, producing one
+// event per line (empty lines are skipped). By default the tailer stops at the end of
+// the file; if it follows the file, it waits for new lines to be appended and starts
+// again from the beginning of the file if it is truncated. Tailers can also read from
+// FIFOs, though FIFOs cannot be checkpointed.
+//
+// If a checkpoint path is specified, the offset after the last acked record is written
+// to the checkpoint file and the tailer resumes from the offset when it is reopened. If
+// the file is smaller than the checkpointed offset, it is read from the beginning.
+type Tailer struct {
+	path       string
+	checkpoint string
+	follow     bool
+	jsonLines  bool
+	interval   time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	reader  *bufio.Reader
+	regular bool
+	offset  int64
+	partial []byte
+	pending map[*ensign.Event]int64
+	closed  bool
+}
+
+// TailOption configures a Tailer.
+type TailOption func(t *Tailer)
+
+// WithFollow waits for new lines to be appended to the file rather than stopping at the
+// end of the file, checking for new data at the poll interval.
+func WithFollow(interval time.Duration) TailOption {
+	return func(t *Tailer) {
+		t.follow = true
+		if interval > 0 {
+			t.interval = interval
+		}
+	}
+}
+
+// WithJSONLines validates that each line is a JSON document and publishes the events
+// with the application/json mimetype; an invalid line returns an ErrInvalidRecord error.
+// By default lines are published as text/plain events.
+func WithJSONLines() TailOption {
+	return func(t *Tailer) {
+		t.jsonLines = true
+	}
+}
+
+// WithCheckpoint stores the offset of the last acked record in the specified file so
+// that the tailer resumes after the record when it is reopened.
+func WithCheckpoint(path string) TailOption {
+	return func(t *Tailer) {
+		t.checkpoint = path
+	}
+}
+
+// Tail opens the file at the path and returns a tailer that starts reading at the
+// checkpointed offset if there is one, otherwise at the beginning of the file.
+func Tail(path string, opts ...TailOption) (t *Tailer, err error) {
+	t = &Tailer{
+		path:     path,
+		interval: DefaultPollInterval,
+		pending:  make(map[*ensign.Event]int64),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.file, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	var info os.FileInfo
+	if info, err = t.file.Stat(); err != nil {
+		t.file.Close()
+		return nil, err
+	}
+	t.regular = info.Mode().IsRegular()
+
+	if t.regular && t.checkpoint != "" {
+		if t.offset, err = readCheckpoint(t.checkpoint); err != nil {
+			t.file.Close()
+			return nil, err
+		}
+
+		if t.offset > info.Size() {
+			t.offset = 0
+		}
+
+		if _, err = t.file.Seek(t.offset, io.SeekStart); err != nil {
+			t.file.Close()
+			return nil, err
+		}
+	}
+
+	t.reader = bufio.NewReader(t.file)
+	return t, nil
+}
+
+// Next returns an event for the next line of the file, blocking until a line is
+// appended if the tailer follows the file. If the tailer does not follow the file, a
+// final line without a trailing newline is returned before io.EOF.
+func (t *Tailer) Next(ctx context.Context) (_ *ensign.Event, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		if t.closed {
+			return nil, ErrClosed
+		}
+
+		var line []byte
+		line, err = t.reader.ReadBytes('\n')
+		t.partial = append(t.partial, line...)
+
+		switch {
+		case err == nil:
+		case errors.Is(err, io.EOF) && !t.follow:
+			if len(t.partial) == 0 {
+				return nil, io.EOF
+			}
+		case errors.Is(err, io.EOF):
+			if err = t.wait(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			return nil, err
+		}
+
+		record := t.partial
+		start := t.offset
+		t.offset += int64(len(record))
+		t.partial = nil
+
+		if record = bytes.TrimRight(record, "\r\n"); len(record) == 0 {
+			continue
+		}
+		return t.event(record, start)
+	}
+}
+
+// Ack checkpoints the offset after the record of the event if a checkpoint path is set.
+func (t *Tailer) Ack(event *ensign.Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offset, ok := t.pending[event]
+	if !ok {
+		return nil
+	}
+	delete(t.pending, event)
+
+	if t.checkpoint == "" || !t.regular {
+		return nil
+	}
+	return writeCheckpoint(t.checkpoint, offset)
+}
+
+// Close the file; the checkpoint is not modified.
+func (t *Tailer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	return t.file.Close()
+}
+
+// Create the event for the record that starts at the offset.
+func (t *Tailer) event(record []byte, offset int64) (*ensign.Event, error) {
+	event := &ensign.Event{
+		Metadata: ensign.Metadata{PathKey: t.path},
+		Data:     append([]byte(nil), record...),
+		Mimetype: mimetype.TextPlain,
+		Created:  time.Now(),
+	}
+	event.Metadata.SetInt(OffsetKey, offset)
+
+	if t.jsonLines {
+		if !json.Valid(record) {
+			return nil, fmt.Errorf("%w: line at offset %d of %s is not valid json", ErrInvalidRecord, offset, t.path)
+		}
+		event.Mimetype = mimetype.ApplicationJSON
+	}
+
+	t.pending[event] = t.offset
+	return event, nil
+}
+
+// Wait for the poll interval, then start reading from the beginning of the file if it
+// has been truncated. Must hold the lock.
+func (t *Tailer) wait(ctx context.Context) (err error) {
+	timer := time.NewTimer(t.interval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	if !t.regular {
+		return nil
+	}
+
+	var info os.FileInfo
+	if info, err = t.file.Stat(); err != nil {
+		return err
+	}
+
+	if info.Size() < t.offset+int64(len(t.partial)) {
+		if _, err = t.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.reader.Reset(t.file)
+		t.offset = 0
+		t.partial = nil
+	}
+	return nil
+}
+
+func readCheckpoint(path string) (offset int64, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if offset, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+		return 0, fmt.Errorf("could not parse checkpoint %s: %w", path, err)
+	}
+	return offset, nil
+}
+
+// Write the checkpoint to a temporary file and rename it so that the checkpoint is
+// not corrupted if the process is interrupted while writing.
+func writeCheckpoint(path string, offset int64) (err error) {
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"); err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.WriteString(strconv.FormatInt(offset, 10)); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,171 @@
+package source_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/source"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.log")
+	require.NoError(t, os.WriteFile(path, []byte("alpha\n\nbravo\r\ncharlie"), 0600))
+
+	tail, err := source.Tail(path)
+	require.NoError(t, err, "could not open file")
+	defer tail.Close()
+
+	ctx := context.Background()
+	for _, expected := range []struct {
+		data   string
+		offset string
+	}{{"alpha", "0"}, {"bravo", "7"}, {"charlie", "14"}} {
+		event, err := tail.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, expected.data, string(event.Data))
+		require.Equal(t, mimetype.TextPlain, event.Mimetype)
+		require.Equal(t, path, event.Metadata.Get(source.PathKey))
+		require.Equal(t, expected.offset, event.Metadata.Get(source.OffsetKey))
+	}
+
+	_, err = tail.Next(ctx)
+	require.ErrorIs(t, err, io.EOF)
+
+	require.NoError(t, tail.Close())
+	_, err = tail.Next(ctx)
+	require.ErrorIs(t, err, source.ErrClosed)
+}
+
+func TestTailJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"n\":1}\nnot json\n"), 0600))
+
+	tail, err := source.Tail(path, source.WithJSONLines())
+	require.NoError(t, err, "could not open file")
+	defer tail.Close()
+
+	event, err := tail.Next(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, mimetype.ApplicationJSON, event.Mimetype)
+
+	_, err = tail.Next(context.Background())
+	require.ErrorIs(t, err, source.ErrInvalidRecord)
+}
+
+func TestTailFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.log")
+	require.NoError(t, os.WriteFile(path, []byte("alpha\nbra"), 0600))
+
+	tail, err := source.Tail(path, source.WithFollow(5*time.Millisecond))
+	require.NoError(t, err, "could not open file")
+	defer tail.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	event, err := tail.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "alpha", string(event.Data))
+
+	// The partial line is completed when the rest of the line is appended
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		f.WriteString("vo\n")
+		f.Close()
+	}()
+
+	event, err = tail.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "bravo", string(event.Data))
+
+	// The file is read from the beginning after it is truncated
+	require.NoError(t, os.WriteFile(path, []byte("delta\n"), 0600))
+	event, err = tail.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "delta", string(event.Data))
+	require.Equal(t, "0", event.Metadata.Get(source.OffsetKey))
+
+	// Next returns when the context is done
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = tail.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRun(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	// Nack the event with the charlie record
+	var (
+		mu        sync.Mutex
+		published []string
+	)
+
+	handler := mock.NewPublishHandler(nil)
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		event, err := in.Unwrap()
+		if err != nil {
+			return nil, err
+		}
+
+		if string(event.Data) == "charlie" {
+			return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_INTERNAL}}}, nil
+		}
+
+		mu.Lock()
+		published = append(published, string(event.Data))
+		mu.Unlock()
+		return &api.PublisherReply{Embed: &api.PublisherReply_Ack{Ack: &api.Ack{Id: in.LocalId}}}, nil
+	}
+	emock.OnPublish = handler.OnPublish
+
+	client, err := ensign.New(ensign.WithMock(emock), ensign.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	dir := t.TempDir()
+	path, checkpoint := filepath.Join(dir, "records.log"), filepath.Join(dir, "records.checkpoint")
+	require.NoError(t, os.WriteFile(path, []byte("alpha\nbravo\ncharlie\ndelta\n"), 0600))
+
+	// The run stops on the nacked event, checkpointing the events before it
+	tail, err := source.Tail(path, source.WithCheckpoint(checkpoint))
+	require.NoError(t, err, "could not open file")
+
+	var nack *ensign.NackError
+	require.ErrorAs(t, source.Run(context.Background(), client, "01GWM89049D49FHJH81BT8795H", tail), &nack)
+	require.NoError(t, tail.Close())
+
+	data, err := os.ReadFile(checkpoint)
+	require.NoError(t, err, "expected checkpoint to be written")
+	require.Equal(t, "12", string(data), "expected checkpoint after bravo")
+
+	// The tailer resumes from the checkpoint
+	require.NoError(t, os.WriteFile(path, []byte("alpha\nbravo\nCHARLIE\ndelta\n"), 0600))
+	tail, err = source.Tail(path, source.WithCheckpoint(checkpoint))
+	require.NoError(t, err, "could not open file")
+	defer tail.Close()
+
+	require.NoError(t, source.Run(context.Background(), client, "01GWM89049D49FHJH81BT8795H", tail))
+
+	data, err = os.ReadFile(checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, "26", string(data), "expected checkpoint at the end of the file")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Contains(t, published, "CHARLIE")
+	require.Equal(t, []string{"alpha", "bravo"}, published[:2])
+	require.Equal(t, []string{"CHARLIE", "delta"}, published[len(published)-2:], "expected events to be republished from the checkpoint")
+}
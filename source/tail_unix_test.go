@@ -0,0 +1,44 @@
+//go:build unix
+
+package source_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/rotationalio/go-ensign/source"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailFIFO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skipf("could not create fifo: %s", err)
+	}
+
+	go func() {
+		f, _ := os.OpenFile(path, os.O_WRONLY, 0600)
+		f.WriteString("alpha\nbravo\n")
+		f.Close()
+	}()
+
+	tail, err := source.Tail(path, source.WithCheckpoint(filepath.Join(t.TempDir(), "checkpoint")))
+	require.NoError(t, err, "could not open fifo")
+	defer tail.Close()
+
+	var records []string
+	for {
+		event, err := tail.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, tail.Ack(event), "fifos are not checkpointed")
+		records = append(records, string(event.Data))
+	}
+	require.Equal(t, []string{"alpha", "bravo"}, records)
+}
@@ -1,15 +1,12 @@
 package ensign_test
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
 	"fmt"
-	"os"
 	"regexp"
 	"strconv"
-	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -17,6 +14,7 @@ import (
 	"github.com/oklog/ulid/v2"
 	"github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/integration"
 	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
 	"github.com/stretchr/testify/suite"
 )
@@ -32,14 +30,8 @@ type stagingTestSuite struct {
 }
 
 func TestStaging(t *testing.T) {
-	// Load the .env file if it exists
-	loadDotEnv()
-
-	// Check if the tests are enabled
-	if !parseBool(os.Getenv("ENSIGN_TEST_STAGING")) {
-		t.Skip("set the $ENSIGN_TEST_STAGING environment variable to execute this test suite")
-		return
-	}
+	// Check if the tests are enabled (loading the .env file if it exists)
+	integration.SkipUnlessEnabled(t)
 
 	// Try to create the Ensign staging client
 	client, err := ensign.New(
@@ -83,14 +75,17 @@ func (s *stagingTestSuite) TestEnsignIntegration() {
 	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
 	defer cancel()
 
+	// Create a harness that destroys the topics created by the test when it completes
+	harness := integration.New(s.T(), s.client, integration.WithQuota(1, 100, 0))
+
 	// Create a topic with a random name and assert it does not exist
-	topicName := fmt.Sprintf("testing.random.%s", strings.ToLower(ulid.Make().String()))
+	topicName := harness.TopicName("random")
 	exists, err := s.client.TopicExists(ctx, topicName)
 	require.NoError(err, "could not query topic exists")
 	require.False(exists, "random topic already exists")
 
 	// Create the topic in Ensign
-	topicID, err := s.client.CreateTopic(ctx, topicName)
+	topicID, err := harness.CreateTopic(ctx, "random")
 	require.NoError(err, "could not create topic in Ensign")
 	require.NotEmpty(topicID, "no topic id was returned")
 
@@ -162,7 +157,7 @@ func (s *stagingTestSuite) TestEnsignIntegration() {
 			event.Metadata["msg"] = strconv.Itoa(i + 1)
 			rand.Read(event.Data)
 
-			err := s.client.Publish(topicID, event)
+			err := harness.Publish(topicID, event)
 			assert.NoError(err, "could not publish event")
 			nsent++
 		}
@@ -173,58 +168,16 @@ func (s *stagingTestSuite) TestEnsignIntegration() {
 			Mimetype: mimetype.TextPlain,
 		}
 		event.Metadata["done"] = "true"
-		err := s.client.Publish(topicID, event)
+		err := harness.Publish(topicID, event)
 		assert.NoError(err, "could not publish done event")
 		nsent++
 	}()
 
 	wg.Wait()
 	require.Equal(nsent, nrecv, "the number of messages published does not equal those consumed")
+	require.Equal(uint64(nsent), harness.Usage().Events, "expected harness to account for published events")
 
 	// TODO: test archiving the topic
-	// TODO: delete the topic so we are not wasting resources
-}
-
-// A lightweight mechanism to load a .env file without adding godotenv as a dependency.
-// This method is not as robust as godotenv and some valid .env files may not load.
-func loadDotEnv() (err error) {
-	var f *os.File
-	if f, err = os.Open(".env"); err != nil {
-		return err
-	}
-	defer f.Close()
-
-	reader := bufio.NewScanner(f)
-	for reader.Scan() {
-		line := strings.TrimSpace(reader.Text())
-		if strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		parts := strings.Split(line, "=")
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		val := strings.TrimSpace(parts[1])
-
-		if err = os.Setenv(key, val); err != nil {
-			return err
-		}
-	}
-	return reader.Err()
-}
-
-func parseBool(s string) bool {
-	switch strings.TrimSpace(s) {
-	case "", "0", "n", "f", "no", "false":
-		return false
-	case "1", "t", "y", "yes", "true":
-		return true
-	default:
-		return false
-	}
 }
 
 func parseSemVer(s string) (major, minor int, err error) {
@@ -1,6 +1,11 @@
 package stream
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
 
 type Errorer interface {
 	Err() error
@@ -10,4 +15,30 @@ var (
 	ErrStreamUninitialized = errors.New("could not initialize stream with server")
 	ErrReconnect           = errors.New("failed to reconnect to remote server within timeout")
 	ErrResolveTopic        = errors.New("could not resolve topic, specify topic ID or allowed topic name")
+	ErrPaused              = errors.New("publisher is paused after reaching a hard quota limit")
+	ErrPublisherClosed     = errors.New("publisher has been closed")
+	ErrSubscriberClosed    = errors.New("subscriber has been closed")
+	ErrNoCallback          = errors.New("a callback is required to publish asynchronously")
+	ErrOverflow            = errors.New("subscriber buffer is full")
+	ErrStreamNotOpen       = errors.New("stream is not open")
+	ErrEventTooLarge       = errors.New("event is larger than the maximum event size")
 )
+
+// NackError is passed to an AckCallback when the server nacks an asynchronously
+// published event and describes why the event was not published.
+type NackError struct {
+	Nack *api.Nack
+}
+
+// Error implements the error interface.
+func (e *NackError) Error() string {
+	if e.Nack.Error != "" {
+		return fmt.Sprintf("[%s] %s", e.Nack.Code.String(), e.Nack.Error)
+	}
+	return e.Nack.Code.String()
+}
+
+// Info describes the nack code, e.g. for retry and alerting logic.
+func (e *NackError) Info() api.NackCodeInfo {
+	return e.Nack.Code.Info()
+}
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// StreamInfo describes the publish or subscribe stream that was negotiated with the
+// Ensign server in the stream ready message when the stream was most recently opened.
+// Because the stream may be reconnected to a different node, the info is updated every
+// time the stream is reopened.
+type StreamInfo struct {
+	ClientID   string               // the client ID repeated back by the server
+	ServerID   string               // the node that the stream is connected to
+	Topics     map[string]ulid.ULID // maps topic names to the topic IDs available on the node
+	Opened     time.Time            // the timestamp the stream was most recently opened
+	Reconnects uint64               // the number of times the stream has been reopened
+}
+
+// ReadyHook is called with the stream info every time a stream is opened or reopened.
+type ReadyHook func(StreamInfo)
+
+// ConnectionState describes a transition in the lifecycle of a connection to Ensign.
+type ConnectionState uint8
+
+const (
+	// Connected is reported when the connection is first established.
+	Connected ConnectionState = iota + 1
+
+	// Reconnecting is reported when the connection goes down and the client starts
+	// waiting for it to be re-established.
+	Reconnecting
+
+	// Reconnected is reported when the connection is re-established after going down.
+	Reconnected
+
+	// Fatal is reported when the connection cannot be re-established; the stream is
+	// no longer usable and the error of the event describes why.
+	Fatal
+)
+
+// The sources of connection events.
+const (
+	SourceClient     = "client"
+	SourcePublisher  = "publisher"
+	SourceSubscriber = "subscriber"
+)
+
+// ConnectionEvent is sent to the connection hook on every connection state transition.
+type ConnectionEvent struct {
+	State  ConnectionState // the state that the connection transitioned to
+	Source string          // the client, publisher, or subscriber that reported the event
+	Info   StreamInfo      // the most recent stream info; empty for client events
+	Err    error           // the error that caused a Reconnecting or Fatal event, if known
+	Time   time.Time       // the timestamp of the transition
+}
+
+// ConnectionHook is called with a connection event every time the state of a connection
+// changes, so that orchestration code can react to reconnects and fatal errors.
+type ConnectionHook func(ConnectionEvent)
+
+// Calls the hook with the event if the hook is not nil, timestamping the event.
+func (h ConnectionHook) notify(state ConnectionState, source string, info StreamInfo, err error) {
+	if h == nil {
+		return
+	}
+	h(ConnectionEvent{State: state, Source: source, Info: info, Err: err, Time: time.Now()})
+}
+
+// Notify calls the hook with a connection event for the state; it is a no-op if the
+// hook is nil. It is exported so that the client can report its own connection events.
+func (h ConnectionHook) Notify(state ConnectionState, source string, err error) {
+	h.notify(state, source, StreamInfo{}, err)
+}
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	case Reconnected:
+		return "reconnected"
+	case Fatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
+
+// Create the stream info from the ready message sent by the server. Topic IDs that
+// cannot be parsed are omitted. If the stream has been opened before, the new info
+// counts the stream as reconnected.
+func newStreamInfo(ready *api.StreamReady, prev StreamInfo) StreamInfo {
+	info := StreamInfo{
+		ClientID: ready.ClientId,
+		ServerID: ready.ServerId,
+		Topics:   make(map[string]ulid.ULID, len(ready.Topics)),
+		Opened:   time.Now(),
+	}
+
+	if !prev.Opened.IsZero() {
+		info.Reconnects = prev.Reconnects + 1
+	}
+
+	for name, data := range ready.Topics {
+		var topicID ulid.ULID
+		if err := topicID.UnmarshalBinary(data); err == nil {
+			info.Topics[name] = topicID
+		}
+	}
+	return info
+}
@@ -0,0 +1,21 @@
+package stream
+
+// Logger is used by the stream managers to report reconnects, dropped events, and
+// unexpected messages from the server that would otherwise be silent. Arguments are
+// alternating key/value pairs that describe the log message. The method set matches
+// *slog.Logger so that a structured logger from the standard library can be used
+// directly, and adapters to other logging libraries are straightforward to write.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// nopLogger discards all log messages and is used when no logger is configured.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
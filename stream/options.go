@@ -0,0 +1,266 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/go-ensign/backoff"
+	"google.golang.org/grpc"
+)
+
+// Option configures the behavior of a Publisher or Subscriber stream manager.
+type Option func(o *Options)
+
+// Options contains the configuration shared by the publish and subscribe stream
+// managers. Options that are not applicable to a specific stream type are ignored.
+type Options struct {
+	// Call options passed to the streaming RPC when the stream is (re)opened.
+	CallOptions []grpc.CallOption
+
+	// Quota sets soft and hard limits on the number of events and bytes sent per stream
+	// session; currently only applicable to publishers.
+	Quota Quota
+
+	// A human readable name that identifies the service that owns the stream; it is
+	// suffixed with a unique instance ULID to create the client ID sent to the server.
+	ClientID string
+
+	// The names or IDs of the topics that the stream is allowed to publish to; by default
+	// all topics in the project are allowed. Currently only applicable to publishers.
+	Topics []string
+
+	// The offsets of the topics to start delivering events from, keyed by topic; the
+	// offsets are sent as the topic offsets of the subscription's consumer group.
+	// Currently only applicable to subscribers.
+	Offsets map[string]uint64
+
+	// Close the stream after the specified duration without any events being published
+	// and reopen it on the next publish; currently only applicable to publishers.
+	IdleTimeout time.Duration
+
+	// Nack events that are not acked or nacked by the server within the timeout so that
+	// they are not pending forever; currently only applicable to publishers.
+	AckTimeout time.Duration
+
+	// The maximum size in bytes of a published event; zero uses DefaultMaxEventSize and
+	// a negative size disables the check. Currently only applicable to publishers.
+	MaxEventSize int
+
+	// The size of the subscriber events channel buffer and how to handle events that
+	// are received when the buffer is full; currently only applicable to subscribers.
+	// If the overflow policy is OverflowSpill, events are spilled to a temporary file
+	// in SpillDir (by default the system temporary directory).
+	BufferSize int
+	Overflow   OverflowPolicy
+	SpillDir   string
+
+	// Logger reports reconnects, dropped events, and unhandled messages from the server;
+	// by default log messages are discarded.
+	Logger Logger
+
+	// OnReady is called with the stream info every time the stream is (re)opened.
+	OnReady ReadyHook
+
+	// OnConnection is called every time the stream connects, reconnects, or fails.
+	OnConnection ConnectionHook
+
+	// The backoff policy used to wait for the connection to be re-established when the
+	// stream goes down; by default the stream waits for up to ReconnectTimeout.
+	Backoff backoff.Policy
+
+	// If true, events that have not been acked or nacked when the stream goes down are
+	// republished after the stream reconnects; currently only applicable to publishers.
+	Resend bool
+}
+
+// OverflowPolicy specifies how a subscriber handles events received from the server
+// when the events channel buffer is full because the caller is consuming events slower
+// than they are being received.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock blocks the receiver until the caller consumes an event, applying
+	// backpressure to the stream (default).
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNack drops the event and nacks it with the DELIVER_AGAIN_ANY code so
+	// that the server can redeliver it, possibly to another consumer in the group.
+	OverflowDropNack
+
+	// OverflowSpill writes the event to a temporary file on disk and delivers spilled
+	// events in order as the caller consumes events. Spilled events are discarded if
+	// the subscriber is closed before they are delivered.
+	OverflowSpill
+)
+
+// WithCallOptions specifies the gRPC call options to use when opening the stream.
+func WithCallOptions(opts ...grpc.CallOption) Option {
+	return func(o *Options) {
+		o.CallOptions = opts
+	}
+}
+
+// WithQuota specifies the soft and hard limits of a publish stream session.
+func WithQuota(quota Quota) Option {
+	return func(o *Options) {
+		o.Quota = quota
+	}
+}
+
+// WithClientID specifies a stable, human-readable name for the stream (e.g. the name of
+// the service that owns the stream) so that streams can be identified on the server.
+// The name is suffixed with a unique instance ULID so that multiple instances of the
+// same service have distinct client IDs, e.g. "billing-01HCG64Y1SMFQBW7A42SRV207A".
+func WithClientID(name string) Option {
+	return func(o *Options) {
+		o.ClientID = name
+	}
+}
+
+// WithTopics restricts the publish stream to the specified topic names or IDs, which
+// are sent to the server when the stream is opened. The server only returns the allowed
+// topics in the topic map of the stream so events published to other topics by name
+// cannot be resolved and events published to other topics by ID are rejected.
+func WithTopics(topics ...string) Option {
+	return func(o *Options) {
+		o.Topics = topics
+	}
+}
+
+// WithOffsets requests that the server starts delivering the events of each topic from
+// the specified offset rather than from the current offsets of the consumer group.
+func WithOffsets(offsets map[string]uint64) Option {
+	return func(o *Options) {
+		o.Offsets = offsets
+	}
+}
+
+// WithIdleTimeout closes the publish stream when no events have been published and no
+// acks or nacks are pending for the specified duration, freeing server resources for
+// publishers that publish infrequently. The stream is reopened on the next publish. If
+// the timeout is zero (the default) the stream is held open until the publisher closes.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.IdleTimeout = timeout
+	}
+}
+
+// WithAckTimeout nacks published events with the TIMEOUT code if the server does not
+// ack or nack them within the timeout after they are sent, removing them from the
+// pending events so that replies lost by the server do not leak. The timeout is not
+// reset if the event is republished after a reconnect. If the timeout is zero (the
+// default) events are pending until the server replies or the publisher is closed.
+func WithAckTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.AckTimeout = timeout
+	}
+}
+
+// WithMaxEventSize specifies the maximum size in bytes of the encoded publish request
+// that carries an event. Events that are larger are not sent and Publish returns
+// ErrEventTooLarge rather than waiting for the server to reject the event. If the size
+// is zero (the default) DefaultMaxEventSize is used; a negative size disables the check,
+// e.g. if the server accepts larger events than the client can know about.
+func WithMaxEventSize(size int) Option {
+	return func(o *Options) {
+		o.MaxEventSize = size
+	}
+}
+
+// WithBufferSize specifies the size of the subscriber events channel buffer; by default
+// the buffer size is BufferSize.
+func WithBufferSize(size int) Option {
+	return func(o *Options) {
+		o.BufferSize = size
+	}
+}
+
+// WithOverflowPolicy specifies how the subscriber handles events that are received
+// when the events channel buffer is full.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *Options) {
+		o.Overflow = policy
+	}
+}
+
+// WithSpillDir specifies the directory that events are spilled to when the overflow
+// policy is OverflowSpill; by default the system temporary directory is used.
+func WithSpillDir(dir string) Option {
+	return func(o *Options) {
+		o.SpillDir = dir
+	}
+}
+
+// WithLogger specifies the logger used to report the activity of the stream.
+func WithLogger(logger Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithReadyHook specifies a function that is called with the stream info negotiated
+// with the server every time the stream is opened or reopened, e.g. to log the node
+// that the stream is connected to after a reconnect. The hook is called synchronously
+// by the stream manager so it should return quickly.
+func WithReadyHook(hook ReadyHook) Option {
+	return func(o *Options) {
+		o.OnReady = hook
+	}
+}
+
+// WithConnectionHook specifies a function that is called when the stream is connected,
+// when it goes down and starts reconnecting, when it is reconnected, and when it fails
+// with a fatal error. The hook is called synchronously by the stream manager so it
+// should return quickly and must not call Close on the stream.
+func WithConnectionHook(hook ConnectionHook) Option {
+	return func(o *Options) {
+		o.OnConnection = hook
+	}
+}
+
+// WithBackoff specifies the policy used to wait for the connection to be re-established
+// when the stream goes down, e.g. to retry for longer on flaky networks. The stream
+// fails with ErrReconnect once the policy stops retrying rather than after the fixed
+// ReconnectTimeout, so a policy that never stops retries forever. The client of the
+// stream must implement BackoffObserver for the policy to be used.
+func WithBackoff(policy backoff.Policy) Option {
+	return func(o *Options) {
+		o.Backoff = policy
+	}
+}
+
+// WithResend republishes the events that have been sent but not acked or nacked by the
+// server when the publish stream goes down once the stream has been reconnected, rather
+// than leaving them pending forever since their replies are lost with the stream. Each
+// event is given an idempotency key in its metadata (see IdempotencyKey) so that the
+// server can deduplicate events that were committed before the stream went down.
+func WithResend() Option {
+	return func(o *Options) {
+		o.Resend = true
+	}
+}
+
+// Creates the client ID that identifies the stream to the server from the name. If no
+// name is specified then the client ID is just a ULID.
+func clientID(name string) string {
+	if name == "" {
+		return ulid.Make().String()
+	}
+	return name + "-" + ulid.Make().String()
+}
+
+func newOptions(opts ...Option) *Options {
+	options := &Options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.Logger == nil {
+		options.Logger = nopLogger{}
+	}
+
+	if options.MaxEventSize == 0 {
+		options.MaxEventSize = DefaultMaxEventSize
+	}
+	return options
+}
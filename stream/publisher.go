@@ -1,14 +1,20 @@
 package stream
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 // Publisher wraps an stream.PublishClient to maintain an open publish stream to an
@@ -21,24 +27,98 @@ import (
 //
 // Publishing messages happens synchronously in the user thread, and an error is
 // returned if the message cannot be published.
+//
+// If an idle timeout is specified, the start go routine closes the stream when no
+// events have been published for the timeout and there are no pending acks/nacks. The
+// next call to Publish wakes the start go routine, which reopens the stream using the
+// same reconnect path that is used when the stream goes down.
+//
+// The server may also ask the publisher to move to a different Ensign node, e.g. when
+// the placement of a topic changes, either by closing the stream or by nacking an event
+// with a REDIRECT code. In both cases the publisher reopens the stream on the
+// connection, which is routed to a ready node when the client is balanced across
+// multiple endpoints, and the redirected events are republished on the new stream.
 type Publisher struct {
-	client   PublishClient            // the client is used to call the Publish RPC to establish a stream
-	copts    []grpc.CallOption        // call options to pass to the Publish RPC
-	smu      sync.RWMutex             // guards updates to the stream
-	stream   api.Ensign_PublishClient // the currently open stream, maintained open using reconnect
-	stop     chan struct{}            // global stop signal to shutdown the publisher
-	down     chan struct{}            // signal from receiver that the stream is down and needs to be reconnected
-	wg       *sync.WaitGroup          // reusable wait group to wait until sender/receiver are down
-	fmu      sync.RWMutex             // guards updates to the fatal error
-	fatal    error                    // if the publisher has fatally errored and cannot reconnect
-	pmu      sync.Mutex               // guards updates to the pending map
-	pending  map[ulid.ULID]pubreply   // track acks/nacks from the publisher
-	topics   map[string]ulid.ULID     // maps topic names to topic IDs from the server
-	serverID string                   // the server this publisher is connected to
+	client   PublishClient               // the client is used to call the Publish RPC to establish a stream
+	copts    []grpc.CallOption           // call options to pass to the Publish RPC
+	quota    Quota                       // soft and hard limits of the stream session
+	smu      sync.RWMutex                // guards updates to the stream
+	stream   api.Ensign_PublishClient    // the currently open stream, maintained open using reconnect
+	stop     chan struct{}               // global stop signal to shutdown the publisher
+	down     chan struct{}               // signal from receiver that the stream is down and needs to be reconnected
+	wg       *sync.WaitGroup             // reusable wait group to wait until sender/receiver are down
+	fmu      sync.RWMutex                // guards updates to the fatal error
+	fatal    error                       // if the publisher has fatally errored and cannot reconnect
+	pmu      sync.Mutex                  // guards updates to the pending map and stats
+	pending  map[ulid.ULID]*pendingEvent // track acks/nacks from the publisher
+	flushed  []chan struct{}             // closed when there are no pending acks/nacks
+	resolves int                         // replies removed from pending but not yet delivered
+	stats    PublisherStats              // aggregated counts and latencies of the stream
+	usage    Usage                       // events and bytes sent in the current stream session
+	warned   bool                        // if the soft quota warning has been issued this session
+	paused   bool                        // if the hard quota was reached and publishing is paused
+	info     StreamInfo                  // stream info (e.g. topics) sent by the server when the stream is opened
+	onReady  ReadyHook                   // called with the stream info when the stream is opened
+	onConn   ConnectionHook              // called on connection state transitions
+	clientID string                      // the client ID sent to the server when the stream is opened
+	topics   []string                    // the allowed topics sent to the server when the stream is opened
+	timeout  time.Duration               // close the stream after this duration of inactivity
+	ackwait  time.Duration               // nack pending events after this duration without a reply
+	maxsize  int                         // the maximum size of a publish request, disabled if negative
+	timers   sync.WaitGroup              // expiring ack timers that are delivering a timeout nack
+	closed   bool                        // if the publisher is closed and timers must not expire
+	closing  bool                        // if the publisher is closing and the stream must not be reopened
+	imu      sync.RWMutex                // guards the idle state so idling does not interrupt a send
+	idle     bool                        // if the stream has been closed due to inactivity
+	active   time.Time                   // the last time the stream was opened or an event was published
+	wake     chan chan error             // requests the start routine to reopen an idle stream
+	done     chan struct{}               // closed when the start go routine exits
+	recv     chan struct{}               // closed when the current receiver go routine exits
+	dispatch chan callback               // replies to be delivered to callbacks by the dispatcher
+	dispdone chan struct{}               // closed when the dispatcher go routine exits
+	log      Logger                      // reports stream activity that would otherwise be silent
+	backoff  backoff.Policy              // the policy used to wait for the connection to be re-established
+	resend   bool                        // republish pending events after the stream is reconnected
 }
 
+// IdempotencyKey is the metadata key of the unique key that is added to the events
+// published by a publisher created WithResend so that the server can deduplicate events
+// that are republished after a reconnect. If the event already has an idempotency key in
+// its metadata then the key is not replaced.
+const IdempotencyKey = "idempotency_key"
+
+// The number of times an event is republished after a REDIRECT nack from the server
+// before the nack is delivered to the caller, so that a misconfigured cluster that keeps
+// redirecting the stream does not keep the event pending forever.
+const maxRedirects = 3
+
+// AckCallback is called by the dispatcher go routine of the publisher when an event
+// published with PublishAsync is acked or nacked by the server. If the event is acked,
+// the ack is passed to the callback with a nil error, otherwise the error is a
+// *NackError describing why the server nacked the event.
+type AckCallback func(ack *api.Ack, err error)
+
 type pubreply chan<- *api.PublisherReply
 
+// pendingEvent tracks an event that has been sent to the server but not acked or nacked.
+// Either the reply channel or the callback is set depending on how it was published.
+type pendingEvent struct {
+	reply      pubreply
+	callback   AckCallback
+	sent       time.Time
+	created    time.Time
+	env        *api.EventWrapper // kept to republish the event after a reconnect or a redirect
+	timer      *time.Timer       // nacks the event if it is not replied to within the ack timeout
+	redirects  int               // the number of times the server redirected the event
+	redirected bool              // if the event must be republished because it was redirected
+}
+
+// callback is a reply from the server that needs to be dispatched to a user callback.
+type callback struct {
+	fn    AckCallback
+	reply *api.PublisherReply
+}
+
 // Create a new low-level publisher stream manager that maintains the open publish stream
 // and allows users to publish events and receive acks/nacks from the Ensign node. This
 // function opens a publish stream and returns an error if the user is not authenticated
@@ -47,22 +127,46 @@ type pubreply chan<- *api.PublisherReply
 // temporarily goes down. The start go routine also kicks of the receive routine to
 // get acks/nacks back from the server as well as other streaming messages.
 func NewPublisher(client PublishClient, opts ...grpc.CallOption) (*Publisher, error) {
+	return NewPublisherWithOptions(client, WithCallOptions(opts...))
+}
+
+// NewPublisherWithOptions creates a publisher stream manager like NewPublisher that is
+// configured by the stream options, e.g. to set quotas or an idle timeout.
+func NewPublisherWithOptions(client PublishClient, opts ...Option) (*Publisher, error) {
+	options := newOptions(opts...)
 	pub := &Publisher{
-		client:  client,
-		copts:   opts,
-		stop:    make(chan struct{}, 1),
-		down:    make(chan struct{}, 1),
-		wg:      &sync.WaitGroup{},
-		fatal:   nil,
-		pending: make(map[ulid.ULID]pubreply),
+		client:   client,
+		copts:    options.CallOptions,
+		quota:    options.Quota,
+		stop:     make(chan struct{}, 1),
+		down:     make(chan struct{}, 1),
+		wg:       &sync.WaitGroup{},
+		fatal:    nil,
+		pending:  make(map[ulid.ULID]*pendingEvent),
+		clientID: clientID(options.ClientID),
+		topics:   options.Topics,
+		timeout:  options.IdleTimeout,
+		ackwait:  options.AckTimeout,
+		maxsize:  options.MaxEventSize,
+		wake:     make(chan chan error),
+		done:     make(chan struct{}),
+		dispatch: make(chan callback, BufferSize),
+		dispdone: make(chan struct{}),
+		log:      options.Logger,
+		onReady:  options.OnReady,
+		onConn:   options.OnConnection,
+		backoff:  options.Backoff,
+		resend:   options.Resend,
 	}
 
 	if err := pub.openStream(); err != nil {
 		return nil, err
 	}
+	pub.onConn.notify(Connected, SourcePublisher, pub.Info(), nil)
 
 	pub.wg.Add(1)
 	go pub.start()
+	go pub.dispatcher()
 	return pub, nil
 }
 
@@ -74,14 +178,59 @@ func NewPublisher(client PublishClient, opts ...grpc.CallOption) (*Publisher, er
 // topic, which must be in the topic map returned by the server at the start of the
 // publish stream. This method also assigns the topic a localID and returns a channel
 // for the user to consume an ack/nack on to check that the event has been published.
-func (p *Publisher) Publish(topic string, event *api.Event) (_ *api.EventWrapper, _ <-chan *api.PublisherReply, err error) {
+// Publish options such as WithKey are applied to the event wrapper before it is sent.
+func (p *Publisher) Publish(topic string, event *api.Event, opts ...PublishOption) (_ *api.EventWrapper, _ <-chan *api.PublisherReply, err error) {
+	return p.PublishContext(context.Background(), topic, event, opts...)
+}
+
+// PublishContext publishes an event to the publish stream, returning the context error
+// if the context is done before the event can be sent, e.g. while waiting for an idle
+// stream to be reopened. Note that the context cannot interrupt a send that is blocked
+// by gRPC flow control since the context of the stream is not bound to the event.
+func (p *Publisher) PublishContext(ctx context.Context, topic string, event *api.Event, opts ...PublishOption) (_ *api.EventWrapper, _ <-chan *api.PublisherReply, err error) {
+	// Create the reply channel to return to the user to receive an ack/nack.
+	reply := make(chan *api.PublisherReply, 1)
+	entry := &pendingEvent{reply: pubreply(reply)}
+
+	var env *api.EventWrapper
+	if env, err = p.publish(ctx, topic, event, entry, opts...); err != nil {
+		return nil, nil, err
+	}
+	return env, reply, nil
+}
+
+// PublishAsync publishes an event to the publish stream and calls the callback when
+// the event is acked or nacked by the server instead of returning a reply channel.
+// Callbacks are called sequentially in the order the replies are received from the
+// server by a single dispatcher go routine, so callbacks should not block for long
+// periods of time or they will delay other callbacks and eventually the receipt of
+// replies from the server. If an error is returned the callback will not be called.
+func (p *Publisher) PublishAsync(topic string, event *api.Event, cb AckCallback, opts ...PublishOption) (_ *api.EventWrapper, err error) {
+	if cb == nil {
+		return nil, ErrNoCallback
+	}
+	return p.publish(context.Background(), topic, event, &pendingEvent{callback: cb}, opts...)
+}
+
+// Publish the event with the pending entry that handles the reply from the server.
+func (p *Publisher) publish(ctx context.Context, topic string, event *api.Event, entry *pendingEvent, opts ...PublishOption) (_ *api.EventWrapper, err error) {
+	// Do not publish if the publisher has been paused by the quota
+	if p.Paused() {
+		return nil, ErrPaused
+	}
+
 	// Create a local ID for acks and nacks
 	localID := ulid.Make()
 
 	// Attempt to determine the topicID from the string
 	var topicID ulid.ULID
 	if topicID, err = p.resolveTopic(topic); err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	// Add an idempotency key so that the server can deduplicate republished events.
+	if p.resend {
+		event = withIdempotencyKey(event, localID)
 	}
 
 	// Create the event wrapper for the event
@@ -91,30 +240,80 @@ func (p *Publisher) Publish(topic string, event *api.Event) (_ *api.EventWrapper
 	}
 
 	if err = env.Wrap(event); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
+	for _, opt := range opts {
+		opt(env)
+	}
+
+	// Fail fast rather than sending an event that the server will reject.
+	req := &api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: env}}
+	if p.maxsize > 0 {
+		if size := proto.Size(req); size > p.maxsize {
+			return nil, fmt.Errorf("%w: %d bytes exceeds the maximum of %d bytes", ErrEventTooLarge, size, p.maxsize)
+		}
+	}
+
+	// Register the event as pending before sending so that a fast reply from the server
+	// is not missed by the receiver.
+	entry.sent = time.Now()
+	if event.Created != nil {
+		entry.created = event.Created.AsTime()
+	}
+
+	entry.env = env
+
+	// Ensure the stream is open; the idle lock is held until the event is sent so that
+	// the stream cannot be closed due to inactivity while the event is being published.
+	if err = p.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	// Do not send the event if the context was canceled while acquiring the stream.
+	if err = ctx.Err(); err != nil {
+		p.imu.RUnlock()
+		return nil, err
+	}
+
+	p.pmu.Lock()
+	p.pending[localID] = entry
+	p.active = entry.sent
+	p.pmu.Unlock()
+
 	// Attempt to send the message to the publisher
 	p.smu.RLock()
-	if p.stream == nil {
-		panic("cannot send event when stream is not open")
+	if p.stream != nil {
+		err = p.stream.Send(req)
+	} else {
+		err = ErrStreamNotOpen
 	}
-
-	err = p.stream.Send(&api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: env}})
 	p.smu.RUnlock()
+	p.imu.RUnlock()
 
 	// Handle any send errors by returning them to the user
+	p.pmu.Lock()
 	if err != nil {
-		return nil, nil, err
+		delete(p.pending, localID)
+		p.notifyFlushed()
+		p.pmu.Unlock()
+		return nil, err
 	}
+	p.stats.Events++
+	warning := p.account(env)
 
-	// Create ack and nack channels and return
-	reply := make(chan *api.PublisherReply, 1)
-	p.pmu.Lock()
-	p.pending[localID] = pubreply(reply)
+	// Start the ack timer if the server has not already replied to the event.
+	if p.ackwait > 0 && p.pending[localID] == entry {
+		entry.timer = time.AfterFunc(p.ackwait, func() { p.expire(localID, entry) })
+	}
 	p.pmu.Unlock()
 
-	return env, reply, nil
+	// Call the warning hook outside of the lock so that the user can inspect the stream.
+	if warning != nil {
+		p.quota.OnWarning(*warning)
+	}
+
+	return env, nil
 }
 
 // Close the publisher gracefully, once closed, the publisher cannot be restarted.
@@ -122,19 +321,88 @@ func (p *Publisher) Close() error {
 	// Send a stop signal so we do not reconnect on error
 	p.stop <- struct{}{}
 
-	// Attempt to send a close stream message
+	// Attempt to send a close stream message; if the stream could not be reopened
+	// after a fatal error there is no stream to close.
+	// The stream is marked as closing so the receiver does not mistake the end of the
+	// stream for the server closing it and the stream is not reopened by a reconnect.
+	p.pmu.Lock()
+	p.closing = true
+	p.pmu.Unlock()
+
+	var err error
 	p.smu.RLock()
-	err := p.stream.CloseSend()
+	if p.stream != nil {
+		err = p.stream.CloseSend()
+	}
 	p.smu.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	// Wait until the publisher stops gracefully
+	// Stop the ack timers so that no timeouts are dispatched once the publisher is
+	// closed, waiting for any timers that have already expired to deliver their nacks.
+	p.pmu.Lock()
+	p.closed = true
+	for _, entry := range p.pending {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+	}
+	p.pmu.Unlock()
+	p.timers.Wait()
+
+	// Wait until the publisher stops gracefully then stop the dispatcher once all of
+	// the replies that were received have been delivered to their callbacks.
 	p.wg.Wait()
+	close(p.dispatch)
+	<-p.dispdone
 	return nil
 }
 
+// Pending returns the number of events that have been sent to the server but that have
+// not been acked or nacked yet.
+func (p *Publisher) Pending() int {
+	p.pmu.Lock()
+	defer p.pmu.Unlock()
+	return len(p.pending)
+}
+
+// Flush blocks until every event that has been sent to the server has been acked or
+// nacked or until the context is done, in which case the context error is returned.
+// Events that are published while flushing are also waited on. Flush does not wait for
+// the callbacks of events published with PublishAsync to return.
+func (p *Publisher) Flush(ctx context.Context) error {
+	p.pmu.Lock()
+	if len(p.pending) == 0 && p.resolves == 0 {
+		p.pmu.Unlock()
+		return nil
+	}
+
+	flushed := make(chan struct{})
+	p.flushed = append(p.flushed, flushed)
+	p.pmu.Unlock()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify the callers of Flush if there are no pending events and all replies have been
+// delivered to the reply channels or the dispatcher; must hold the pending lock.
+func (p *Publisher) notifyFlushed() {
+	if len(p.pending) > 0 || p.resolves > 0 {
+		return
+	}
+
+	for _, flushed := range p.flushed {
+		close(flushed)
+	}
+	p.flushed = nil
+}
+
 // Err returns any fatal errors that are set on the publisher. If a non-nil error is
 // returned then the publisher is not running and all events published will fail.
 func (p *Publisher) Err() error {
@@ -143,44 +411,177 @@ func (p *Publisher) Err() error {
 	return p.fatal
 }
 
+// Idle returns true if the stream has been closed because no events were published
+// within the idle timeout. The stream is reopened the next time an event is published.
+func (p *Publisher) Idle() bool {
+	p.imu.RLock()
+	defer p.imu.RUnlock()
+	return p.idle
+}
+
+// Returns true once Close has been called on the publisher.
+func (p *Publisher) isClosing() bool {
+	p.pmu.Lock()
+	defer p.pmu.Unlock()
+	return p.closing
+}
+
+// Stats returns a snapshot of the events published on the stream and the latencies of
+// the acks that have been received from the server.
+func (p *Publisher) Stats() PublisherStats {
+	p.pmu.Lock()
+	defer p.pmu.Unlock()
+	return p.stats
+}
+
+// Usage returns the number of events and bytes sent in the current stream session.
+func (p *Publisher) Usage() Usage {
+	p.pmu.Lock()
+	defer p.pmu.Unlock()
+	return p.usage
+}
+
+// Paused returns true if the publisher has reached a hard quota limit and will not
+// publish any more events until Resume is called.
+func (p *Publisher) Paused() bool {
+	p.pmu.Lock()
+	defer p.pmu.Unlock()
+	return p.paused
+}
+
+// Resume a paused publisher, resetting the session usage so that events can be
+// published again until the next soft or hard limit is reached.
+func (p *Publisher) Resume() {
+	p.pmu.Lock()
+	p.paused = false
+	p.resetUsage()
+	p.pmu.Unlock()
+}
+
+// Account for the event that was just sent in the current session usage, returning the
+// usage if the soft quota limit warning needs to be issued. Must hold the pending lock.
+func (p *Publisher) account(env *api.EventWrapper) (warning *Usage) {
+	p.usage.Events++
+	p.usage.Bytes += uint64(proto.Size(env))
+
+	if !p.quota.Enabled() {
+		return nil
+	}
+
+	if p.quota.hard(p.usage) {
+		p.paused = true
+	}
+
+	if !p.warned && p.quota.soft(p.usage) {
+		p.warned = true
+		if p.quota.OnWarning != nil {
+			usage := p.usage
+			return &usage
+		}
+	}
+	return nil
+}
+
+// Reset the session usage; must hold the pending lock.
+func (p *Publisher) resetUsage() {
+	p.usage = Usage{}
+	p.warned = false
+}
+
+// ClientID returns the client ID that identifies the publisher stream to the server.
+func (p *Publisher) ClientID() string {
+	return p.clientID
+}
+
 // Topics returns the map of topic names to ULID that is sent by the server when the
 // stream is opened and correctly initialized.
 func (p *Publisher) Topics() map[string]ulid.ULID {
 	p.smu.RLock()
 	defer p.smu.RUnlock()
-	return p.topics
+	return p.info.Topics
+}
+
+// Info returns the stream info sent by the server when the stream was last opened.
+func (p *Publisher) Info() StreamInfo {
+	p.smu.RLock()
+	defer p.smu.RUnlock()
+	return p.info
 }
 
 // The start go routine manages the stream and receive go routine. If the receive go
 // routine goes down, this routine waits until the connection is reestablished then
-// reopens the stream and restarts the recv go routine.
+// reopens the stream and restarts the recv go routine. If an idle timeout is set, this
+// routine also closes the stream when it is inactive and reopens it when woken.
 func (p *Publisher) start() {
 	// Ensure the start go routine marks itself as done when it exits
 	defer p.wg.Done()
+	defer close(p.done)
 
 	// Start a receiver channel; it is assumed that openStream has already been called.
-	p.wg.Add(1)
-	go p.receiver()
+	p.startReceiver()
+
+	// The idle timer channel is nil and never fires if there is no idle timeout.
+	var (
+		timer *time.Timer
+		idle  <-chan time.Time
+	)
+	if p.timeout > 0 {
+		timer = time.NewTimer(p.timeout)
+		defer timer.Stop()
+		idle = timer.C
+	}
 
 	// Maintain the publish stream connection
 	for {
 		select {
 		case <-p.down:
+			// If the stream was closed due to inactivity it is reopened when woken.
+			if p.Idle() {
+				continue
+			}
+
 			// If we're not able to reconnect in a timely fashion, set the fatal error.
-			if err := p.reconnect(); err != nil {
+			p.log.Info("publish stream is down, reconnecting", "client_id", p.clientID)
+			p.onConn.notify(Reconnecting, SourcePublisher, p.Info(), nil)
+			if err := p.restart(); err != nil {
+				if errors.Is(err, ErrPublisherClosed) {
+					return
+				}
+
+				p.log.Error("could not reconnect publish stream", "client_id", p.clientID, "error", err)
 				p.setFatal(err)
 				return
 			}
+			p.log.Info("publish stream reconnected", "client_id", p.clientID, "server_id", p.Info().ServerID)
+			p.onConn.notify(Reconnected, SourcePublisher, p.Info(), nil)
 
-			// Attempt to reopen the stream to the server
-			if err := p.openStream(); err != nil {
-				p.setFatal(err)
+		case <-idle:
+			if !p.closeIdle() {
+				timer.Reset(p.remaining())
+				continue
+			}
+			p.log.Debug("closed idle publish stream", "client_id", p.clientID, "idle_timeout", p.timeout)
+
+			// Wait for the receiver to stop so that the stream can be safely reopened
+			// and discard any down signal the receiver sent before the stream was idled.
+			select {
+			case <-p.recv:
+				select {
+				case <-p.down:
+				default:
+				}
+			case <-p.stop:
 				return
 			}
 
-			// Restart the receiver, which should be stopped when we got the down msg.
-			p.wg.Add(1)
-			go p.receiver()
+		case errc := <-p.wake:
+			// Reopen the idle stream; if it cannot be reopened the error is returned to
+			// the publisher and the stream remains idle so the next publish can retry.
+			err := p.reopen()
+			if err == nil {
+				timer.Reset(p.timeout)
+			}
+			errc <- err
 
 		case <-p.stop:
 			return
@@ -188,22 +589,209 @@ func (p *Publisher) start() {
 	}
 }
 
+// Reconnect to the server, reopen the stream and restart the receiver; this is used
+// both when the stream goes down and when an idle stream is woken by a publish.
+func (p *Publisher) restart() (err error) {
+	if err = p.reconnect(); err != nil {
+		return err
+	}
+
+	// Attempt to reopen the stream to the server
+	if err = p.openStream(); err != nil {
+		return err
+	}
+
+	// Restart the receiver, which should be stopped when we got the down msg.
+	p.startReceiver()
+
+	// Republish events that were redirected by the server, which expects them on the new
+	// stream, and if resending, all events whose replies were lost when the stream went
+	// down. Without resend other pending events are not republished since the previous
+	// node may have accepted them, which would publish them twice.
+	p.resendPending(p.resend)
+	return nil
+}
+
+// Republish the events that were sent on a previous stream but were never acked or
+// nacked, in the order they were originally published; if all is false only the events
+// that were redirected by the server are republished. The events remain pending so
+// that their replies are delivered as usual when they are received on the new stream.
+func (p *Publisher) resendPending(all bool) {
+	p.pmu.Lock()
+	envs := make([]*api.EventWrapper, 0, len(p.pending))
+	for _, entry := range p.pending {
+		if entry.env != nil && (all || entry.redirected) {
+			envs = append(envs, entry.env)
+		}
+		entry.redirected = false
+	}
+	p.pmu.Unlock()
+
+	if len(envs) == 0 {
+		return
+	}
+
+	sort.Slice(envs, func(i, j int) bool { return bytes.Compare(envs[i].LocalId, envs[j].LocalId) < 0 })
+
+	p.smu.RLock()
+	defer p.smu.RUnlock()
+	for i, env := range envs {
+		if err := p.stream.Send(&api.PublisherRequest{Embed: &api.PublisherRequest_Event{Event: env}}); err != nil {
+			p.log.Warn("could not republish pending events", "client_id", p.clientID, "n_events", len(envs)-i, "error", err)
+			return
+		}
+
+		p.pmu.Lock()
+		p.stats.Resent++
+		p.pmu.Unlock()
+	}
+	p.log.Info("republished pending events after reconnect", "client_id", p.clientID, "n_events", len(envs))
+}
+
+// Returns a copy of the event with an idempotency key derived from the local ID unless
+// the event already has one; the metadata of the original event is not modified.
+func withIdempotencyKey(event *api.Event, localID ulid.ULID) *api.Event {
+	if _, ok := event.Metadata[IdempotencyKey]; ok {
+		return event
+	}
+
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, val := range event.Metadata {
+		metadata[key] = val
+	}
+	metadata[IdempotencyKey] = localID.String()
+
+	return &api.Event{
+		Data:     event.Data,
+		Metadata: metadata,
+		Mimetype: event.Mimetype,
+		Type:     event.Type,
+		Created:  event.Created,
+	}
+}
+
+// Start a receiver go routine, tracking when it exits so that the stream can be idled.
+// Should only be called by the start go routine.
+func (p *Publisher) startReceiver() {
+	p.recv = make(chan struct{})
+	p.wg.Add(1)
+	go p.receiver(p.recv)
+}
+
+// Acquires the idle read lock, waking the stream and waiting for it to be reopened if
+// it is idle. If no error is returned, the caller must release the idle read lock.
+func (p *Publisher) acquire(ctx context.Context) error {
+	for {
+		p.imu.RLock()
+		if !p.idle {
+			return nil
+		}
+		p.imu.RUnlock()
+
+		errc := make(chan error, 1)
+		select {
+		case p.wake <- errc:
+		case <-p.done:
+			return ErrPublisherClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close the stream if no events have been published within the idle timeout and there
+// are no pending acks or nacks. Returns true if the stream was idled.
+func (p *Publisher) closeIdle() bool {
+	p.imu.Lock()
+	defer p.imu.Unlock()
+
+	p.pmu.Lock()
+	busy := len(p.pending) > 0 || time.Since(p.active) < p.timeout
+	p.pmu.Unlock()
+
+	if busy {
+		return false
+	}
+
+	// Mark the stream as idle before closing it so the receiver does not reconnect.
+	// If CloseSend fails the receiver will stop on the stream error instead.
+	p.idle = true
+	p.smu.RLock()
+	if p.stream != nil {
+		p.stream.CloseSend()
+	}
+	p.smu.RUnlock()
+	return true
+}
+
+// Reopen the stream if it was closed due to inactivity.
+func (p *Publisher) reopen() (err error) {
+	if !p.Idle() {
+		return nil
+	}
+
+	if err = p.restart(); err != nil {
+		return err
+	}
+
+	p.imu.Lock()
+	p.idle = false
+	p.imu.Unlock()
+	return nil
+}
+
+// Returns the remaining duration until the stream is idle; if the stream has pending
+// events the full idle timeout is returned.
+func (p *Publisher) remaining() time.Duration {
+	p.pmu.Lock()
+	defer p.pmu.Unlock()
+	if remaining := p.timeout - time.Since(p.active); remaining > 0 && len(p.pending) == 0 {
+		return remaining
+	}
+	return p.timeout
+}
+
 // openStream returns a new publish bidirectional stream using the Ensign client. It
 // uses the default timeout to establish the stream and returns an error if the stream
 // could not be connected. This method also sends the stream initialization message and
 // waits for a stream ready response from the server. If it fails to open the stream or
 // the user is unauthenticated an error is returned.
 func (p *Publisher) openStream() (err error) {
+	// Call the ready hook once the stream lock has been released.
+	var info StreamInfo
+	defer func() {
+		if err == nil && p.onReady != nil {
+			p.onReady(info)
+		}
+	}()
+
 	p.smu.Lock()
 	defer p.smu.Unlock()
+	if p.isClosing() {
+		return ErrPublisherClosed
+	}
+
+	// Close the previous stream in case the server has not ended it, e.g. when the
+	// stream is migrated after the server redirected an event.
+	if p.stream != nil {
+		p.stream.CloseSend()
+	}
+
 	if p.stream, err = p.client.PublishStream(context.Background(), p.copts...); err != nil {
 		return err
 	}
 
 	// Send an open stream request
-	// TODO: how to allow user to specify client ID?
-	// TODO: how to specify the allowed topics?
-	open := &api.OpenStream{ClientId: ulid.Make().String()}
+	open := &api.OpenStream{ClientId: p.clientID, Topics: p.topics}
 	if err = p.stream.Send(&api.PublisherRequest{Embed: &api.PublisherRequest_OpenStream{OpenStream: open}}); err != nil {
 		return err
 	}
@@ -219,41 +807,38 @@ func (p *Publisher) openStream() (err error) {
 		return ErrStreamUninitialized
 	}
 
-	// Create topic map and server info
-	p.serverID = ready.ServerId
-	p.topics = make(map[string]ulid.ULID)
-	for name, data := range ready.Topics {
-		var topicID ulid.ULID
-		if err = topicID.UnmarshalBinary(data); err == nil {
-			p.topics[name] = topicID
-		}
-	}
+	// A new stream session has started so reset the quota usage
+	p.pmu.Lock()
+	p.resetUsage()
+	p.active = time.Now()
+	p.pmu.Unlock()
 
+	// Create topic map and server info
+	p.info = newStreamInfo(ready, p.info)
+	info = p.info
 	return nil
 }
 
 // Wait for the gRPC connection to reconnect to the Ensign node.
 func (p *Publisher) reconnect() error {
-	ctx, cancel := context.WithTimeout(context.Background(), ReconnectTimeout)
-	defer cancel()
-
-	if !p.client.WaitForReconnect(ctx) {
-		return ErrReconnect
-	}
-	return nil
+	return waitForReconnect(p.client, p.backoff)
 }
 
 // The receiver go routine listens for publish reply messages from the server and sends
 // them to the pubreply channel (closing the channel and cleaning it up). It is this
 // routine's responsibility to detect if the stream is down by an error on the recv; if
 // so the routine quits and sends a signal to the start routine to reconnect.
-func (p *Publisher) receiver() {
+func (p *Publisher) receiver(done chan<- struct{}) {
 	defer p.wg.Done()
+	defer close(done)
 	for {
 		// Use an rlock to make sure the currently active stream is accessed
 		p.smu.RLock()
 		if p.stream == nil {
-			panic("publisher receiver running when stream is not open")
+			p.smu.RUnlock()
+			p.log.Error("publisher receiver running when stream is not open", "client_id", p.clientID)
+			p.setFatal(ErrStreamNotOpen)
+			return
 		}
 
 		// Fetch the next server message or the error for handling
@@ -261,14 +846,20 @@ func (p *Publisher) receiver() {
 		p.smu.RUnlock()
 
 		if err != nil {
-			// Assume clean shutdown when error is EOF, stop the go routine.
-			if errors.Is(err, io.EOF) {
+			// Assume clean shutdown when the publisher is closing or the stream was
+			// closed due to inactivity, stop the go routine.
+			if p.isClosing() || p.Idle() {
 				return
 			}
 
-			// Otherwise log the error and send a reconnect signal before shutting down.
-			// TODO: configure logging for go sdk
-			// log.Debug().Err(err).Msg("could not recv message from publish stream, attempting reconnect")
+			// If the server ended the stream it is reopened so that the stream can be
+			// migrated to another node, otherwise log the error and send a reconnect
+			// signal before shutting down.
+			if errors.Is(err, io.EOF) {
+				p.log.Info("publish stream closed by server, reopening stream", "client_id", p.clientID)
+			} else {
+				p.log.Debug("could not recv message from publish stream, attempting reconnect", "client_id", p.clientID, "error", err)
+			}
 			p.down <- struct{}{}
 			return
 		}
@@ -278,40 +869,136 @@ func (p *Publisher) receiver() {
 		case *api.PublisherReply_Ack:
 			var localID ulid.ULID
 			if err = localID.UnmarshalBinary(msg.Ack.Id); err != nil {
-				// TODO: log instead of panic on error
-				panic(err)
+				p.log.Warn("could not parse local id of ack from server", "client_id", p.clientID, "error", err)
+				continue
 			}
 
 			p.pmu.Lock()
-			if pending, ok := p.pending[localID]; ok {
-				pending <- in
-				close(pending)
-				delete(p.pending, localID)
+			pending, ok := p.pending[localID]
+			if ok {
+				p.stats.Acks++
+				p.stats.RoundTrip.update(time.Since(pending.sent))
+				if !pending.created.IsZero() && msg.Ack.Committed != nil {
+					p.stats.Committed.update(msg.Ack.Committed.AsTime().Sub(pending.created))
+				}
+				p.remove(localID, pending)
 			}
 			p.pmu.Unlock()
 
+			if ok {
+				p.resolve(pending, in)
+				p.pmu.Lock()
+				p.resolves--
+				p.notifyFlushed()
+				p.pmu.Unlock()
+			}
+
 		case *api.PublisherReply_Nack:
 			var localID ulid.ULID
 			if err = localID.UnmarshalBinary(msg.Nack.Id); err != nil {
-				// TODO: log instead of panic on error
-				panic(err)
+				p.log.Warn("could not parse local id of nack from server", "client_id", p.clientID, "error", err)
+				continue
 			}
 
+			// A redirected event remains pending and is republished once the stream has
+			// been reopened, unless it has already been redirected too many times.
 			p.pmu.Lock()
-			if pending, ok := p.pending[localID]; ok {
-				pending <- in
-				close(pending)
-				delete(p.pending, localID)
+			pending, ok := p.pending[localID]
+			redirect := ok && msg.Nack.Code == api.Nack_REDIRECT && pending.redirects < maxRedirects
+			switch {
+			case redirect:
+				pending.redirects++
+				pending.redirected = true
+				p.stats.Redirects++
+			case ok:
+				p.stats.Nacks++
+				p.remove(localID, pending)
 			}
 			p.pmu.Unlock()
 
+			if redirect {
+				p.log.Info("publish stream redirected by server, reopening stream", "client_id", p.clientID, "local_id", localID.String())
+				p.down <- struct{}{}
+				return
+			}
+
+			if ok {
+				p.resolve(pending, in)
+				p.pmu.Lock()
+				p.resolves--
+				p.notifyFlushed()
+				p.pmu.Unlock()
+			}
+
 		case *api.PublisherReply_CloseStream:
-			// TODO: handle close stream and logging for close stream
-			// stats := msg.CloseStream
-			// log.Debug().Uint64("n_events", stats.Events).Uint64("n_topics", stats.Topics).Uint64("n_consumers", stats.Consumers).Msg("publish stream closed")
+			stats := msg.CloseStream
+			p.log.Debug("publish stream closed", "client_id", p.clientID, "n_events", stats.Events, "n_topics", stats.Topics, "n_acks", stats.Acks, "n_nacks", stats.Nacks)
 		default:
-			// TODO: configure logging for go sdk
-			// log.Debug().Type("publisher_reply", in.Embed).Msg("unhandled publish stream message from server: ignoring")
+			p.log.Debug("unhandled publish stream message from server: ignoring", "client_id", p.clientID, "publisher_reply", fmt.Sprintf("%T", in.Embed))
+		}
+	}
+}
+
+// Remove the pending event once it has been replied to, stopping its ack timer. The
+// reply must then be delivered with resolve; must hold the pending lock.
+func (p *Publisher) remove(localID ulid.ULID, pending *pendingEvent) {
+	if pending.timer != nil {
+		pending.timer.Stop()
+	}
+	delete(p.pending, localID)
+	p.resolves++
+}
+
+// Called by the ack timer of the pending event when the server has not replied to it
+// within the ack timeout; delivers a TIMEOUT nack to the pending event in place of the
+// reply from the server. If the server replies after the timeout, the reply is ignored.
+func (p *Publisher) expire(localID ulid.ULID, pending *pendingEvent) {
+	p.pmu.Lock()
+	if p.closed || p.pending[localID] != pending {
+		p.pmu.Unlock()
+		return
+	}
+
+	p.stats.Timeouts++
+	p.remove(localID, pending)
+	p.timers.Add(1)
+	defer p.timers.Done()
+	p.pmu.Unlock()
+
+	p.log.Debug("no reply to published event within ack timeout", "client_id", p.clientID, "local_id", localID.String(), "ack_timeout", p.ackwait)
+	nack := &api.Nack{Id: localID.Bytes(), Code: api.Nack_TIMEOUT, Error: fmt.Sprintf("no reply from server within %s", p.ackwait)}
+	p.resolve(pending, &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: nack}})
+
+	p.pmu.Lock()
+	p.resolves--
+	p.notifyFlushed()
+	p.pmu.Unlock()
+}
+
+// Deliver the reply to the pending event, either on the reply channel or by queueing it
+// for the dispatcher to call the callback. Must not hold the pending lock since the
+// dispatch queue applies backpressure to the receiver if callbacks are slow.
+func (p *Publisher) resolve(pending *pendingEvent, in *api.PublisherReply) {
+	if pending.callback != nil {
+		p.dispatch <- callback{fn: pending.callback, reply: in}
+		return
+	}
+
+	pending.reply <- in
+	close(pending.reply)
+}
+
+// The dispatcher go routine calls the callbacks of events published with PublishAsync
+// when their replies are received so that slow callbacks do not block the receiver.
+// The dispatcher runs until the dispatch queue is closed when the publisher is closed.
+func (p *Publisher) dispatcher() {
+	defer close(p.dispdone)
+	for cb := range p.dispatch {
+		switch msg := cb.reply.Embed.(type) {
+		case *api.PublisherReply_Ack:
+			cb.fn(msg.Ack, nil)
+		case *api.PublisherReply_Nack:
+			cb.fn(nil, &NackError{Nack: msg.Nack})
 		}
 	}
 }
@@ -321,6 +1008,7 @@ func (p *Publisher) setFatal(err error) {
 	p.fmu.Lock()
 	p.fatal = err
 	p.fmu.Unlock()
+	p.onConn.notify(Fatal, SourcePublisher, p.Info(), err)
 }
 
 // Determine if the topic is an ULID string by parsing it, otherwise look the topic up
@@ -334,7 +1022,7 @@ func (p *Publisher) resolveTopic(topic string) (topicID ulid.ULID, err error) {
 	// Attempt to lookup the topicID from the topic map
 	p.smu.RLock()
 	defer p.smu.RUnlock()
-	if topicID, ok := p.topics[topic]; ok {
+	if topicID, ok := p.info.Topics[topic]; ok {
 		return topicID, nil
 	}
 
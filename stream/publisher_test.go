@@ -1,15 +1,21 @@
 package stream_test
 
 import (
+	"context"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
 	"github.com/rotationalio/go-ensign/mock"
 	"github.com/rotationalio/go-ensign/stream"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type publisherTestSuite struct {
@@ -109,6 +115,98 @@ func (s *publisherTestSuite) TestPublisherTopicNames() {
 	require.NoError(pub.Close())
 }
 
+func (s *publisherTestSuite) TestPublisherStats() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	// Nack every third event that is published
+	nevents := 0
+	handler := mock.NewPublishHandler(fixture)
+	handler.OnEvent = func(in *api.EventWrapper) (out *api.PublisherReply, err error) {
+		nevents++
+		if nevents%3 == 0 {
+			return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_INTERNAL}}}, nil
+		}
+		return &api.PublisherReply{Embed: &api.PublisherReply_Ack{Ack: &api.Ack{Id: in.LocalId, Committed: timestamppb.Now()}}}, nil
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisher(s.mock)
+	require.NoError(err, "could not connect to publisher")
+	require.Zero(pub.Stats(), "expected no stats before publishing")
+
+	for i := 0; i < 9; i++ {
+		_, C, err := pub.Publish("testing.123", mock.NewEvent())
+		require.NoError(err, "could not publish event")
+		<-C
+	}
+
+	stats := pub.Stats()
+	require.Equal(uint64(9), stats.Events)
+	require.Equal(uint64(6), stats.Acks)
+	require.Equal(uint64(3), stats.Nacks)
+	require.Equal(uint64(6), stats.RoundTrip.Count)
+	require.Equal(uint64(6), stats.Committed.Count)
+	require.Greater(stats.RoundTrip.Mean(), time.Duration(0))
+	require.LessOrEqual(stats.RoundTrip.Min, stats.RoundTrip.Mean())
+	require.GreaterOrEqual(stats.RoundTrip.Max, stats.RoundTrip.Mean())
+
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublisherQuota() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	handler := mock.NewPublishHandler(fixture)
+	s.mock.server.OnPublish = handler.OnPublish
+
+	// Create a publisher with soft and hard event limits
+	warnings := make([]stream.Usage, 0, 1)
+	quota := stream.Quota{
+		SoftEvents: 3,
+		HardEvents: 5,
+		OnWarning: func(usage stream.Usage) {
+			warnings = append(warnings, usage)
+		},
+	}
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithQuota(quota))
+	require.NoError(err, "could not connect to publisher")
+
+	for i := 0; i < 5; i++ {
+		_, C, err := pub.Publish("testing.123", mock.NewEvent())
+		require.NoError(err, "could not publish event %d", i)
+		<-C
+	}
+
+	// The warning should only have been issued once at the soft limit
+	require.Len(warnings, 1, "expected exactly one soft limit warning")
+	require.Equal(uint64(3), warnings[0].Events)
+	require.Greater(warnings[0].Bytes, uint64(0))
+
+	// The publisher should be paused after the hard limit
+	require.True(pub.Paused(), "expected publisher to be paused")
+	_, _, err = pub.Publish("testing.123", mock.NewEvent())
+	require.ErrorIs(err, stream.ErrPaused)
+	require.Equal(uint64(5), pub.Usage().Events)
+
+	// Resuming should reset the usage and allow publishing
+	pub.Resume()
+	require.False(pub.Paused(), "expected publisher to be resumed")
+	require.Zero(pub.Usage(), "expected usage to be reset")
+
+	_, C, err := pub.Publish("testing.123", mock.NewEvent())
+	require.NoError(err, "could not publish event after resume")
+	<-C
+
+	require.NoError(pub.Close())
+}
+
 func (s *publisherTestSuite) TestCannotResolveTopicID() {
 	// When the stream is opened, send a topic map back.
 	fixture := map[string]ulid.ULID{
@@ -210,6 +308,685 @@ func (s *publisherTestSuite) TestPublisherTopicIDs() {
 	require.NoError(pub.Close())
 }
 
+func (s *publisherTestSuite) TestPublisherIdleTimeout() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	handler := mock.NewPublishHandler(fixture)
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithIdleTimeout(50*time.Millisecond))
+	require.NoError(err, "could not connect to publisher")
+	require.False(pub.Idle(), "expected publisher to be active when opened")
+
+	for i := 0; i < 3; i++ {
+		_, C, err := pub.Publish("testing.123", mock.NewEvent())
+		require.NoError(err, "could not publish event")
+		require.NotNil((<-C).GetAck(), "expected event to be acked")
+		require.False(pub.Idle(), "expected publisher to be active after publishing")
+
+		// The stream should be closed after the idle timeout
+		require.Eventually(pub.Idle, time.Second, 10*time.Millisecond, "expected publisher to become idle")
+		require.Equal(i+1, s.mock.server.Calls[mock.PublishRPC], "expected stream to be reopened on publish")
+	}
+
+	require.NoError(pub.Err())
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublisherContext() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	handler := mock.NewPublishHandler(fixture)
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithIdleTimeout(50*time.Millisecond))
+	require.NoError(err, "could not connect to publisher")
+
+	// A canceled context should prevent the event from being sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = pub.PublishContext(ctx, "testing.123", mock.NewEvent())
+	require.ErrorIs(err, context.Canceled)
+	require.Zero(pub.Stats().Events, "expected no events to be sent")
+
+	// A canceled context should not wake an idle stream
+	require.Eventually(pub.Idle, time.Second, 10*time.Millisecond, "expected publisher to become idle")
+	_, _, err = pub.PublishContext(ctx, "testing.123", mock.NewEvent())
+	require.ErrorIs(err, context.Canceled)
+	require.True(pub.Idle(), "expected publisher to remain idle")
+
+	// A valid context should publish the event
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, C, err := pub.PublishContext(ctx, "testing.123", mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	require.NotNil((<-C).GetAck(), "expected event to be acked")
+
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublishAsync() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	// Nack every other event that is published
+	nevents := 0
+	handler := mock.NewPublishHandler(fixture)
+	handler.OnEvent = func(in *api.EventWrapper) (out *api.PublisherReply, err error) {
+		nevents++
+		if nevents%2 == 0 {
+			return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_TOPIC_ARCHIVED, Error: "topic is readonly"}}}, nil
+		}
+		return &api.PublisherReply{Embed: &api.PublisherReply_Ack{Ack: &api.Ack{Id: in.LocalId, Committed: timestamppb.Now()}}}, nil
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisher(s.mock)
+	require.NoError(err, "could not connect to publisher")
+
+	_, err = pub.PublishAsync("testing.123", mock.NewEvent(), nil)
+	require.ErrorIs(err, stream.ErrNoCallback)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		acks  int
+		nacks int
+	)
+
+	callback := func(ack *api.Ack, err error) {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			nacks++
+			require.Nil(ack)
+
+			var nack *stream.NackError
+			require.ErrorAs(err, &nack)
+			require.Equal(api.Nack_TOPIC_ARCHIVED, nack.Nack.Code)
+			require.EqualError(err, "[TOPIC_ARCHIVED] topic is readonly")
+			return
+		}
+
+		acks++
+		require.NotNil(ack)
+		require.NotEmpty(ack.Id)
+	}
+
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		env, err := pub.PublishAsync("testing.123", mock.NewEvent(), callback)
+		require.NoError(err, "could not publish event asynchronously")
+		require.NotNil(env)
+	}
+
+	wg.Wait()
+	require.Equal(5, acks)
+	require.Equal(5, nacks)
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublisherAckTimeout() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	// The server never replies to the events that are published
+	handler := mock.NewPublishHandler(fixture)
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		return nil, nil
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithAckTimeout(50*time.Millisecond))
+	require.NoError(err, "could not connect to publisher")
+
+	env, C, err := pub.Publish("testing.123", mock.NewEvent())
+	require.NoError(err, "could not publish event")
+
+	nacked := make(chan error, 1)
+	_, err = pub.PublishAsync("testing.123", mock.NewEvent(), func(ack *api.Ack, err error) {
+		nacked <- err
+	})
+	require.NoError(err, "could not publish event asynchronously")
+	require.Equal(2, pub.Pending())
+
+	// A timeout nack is sent on the reply channel and the reply channel is closed
+	nack := (<-C).GetNack()
+	require.NotNil(nack, "expected event to be nacked")
+	require.Equal(api.Nack_TIMEOUT, nack.Code)
+	require.Equal(env.LocalId, nack.Id)
+
+	_, ok := <-C
+	require.False(ok, "expected reply channel to be closed")
+
+	// The callback is called with a timeout nack error
+	var nerr *stream.NackError
+	require.ErrorAs(<-nacked, &nerr)
+	require.Equal(api.Nack_TIMEOUT, nerr.Nack.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(pub.Flush(ctx), "expected expired events to be flushed")
+	require.Zero(pub.Pending(), "expected expired events to be evicted")
+
+	stats := pub.Stats()
+	require.Equal(uint64(2), stats.Timeouts)
+	require.Zero(stats.Nacks)
+	require.NoError(pub.Close())
+
+	// Closing the publisher stops the ack timers of pending events
+	pub, err = stream.NewPublisherWithOptions(s.mock, stream.WithAckTimeout(time.Hour))
+	require.NoError(err, "could not connect to publisher")
+
+	_, err = pub.PublishAsync("testing.123", mock.NewEvent(), func(*api.Ack, error) {
+		require.Fail("callback should not be called after the publisher is closed")
+	})
+	require.NoError(err, "could not publish event asynchronously")
+	require.NoError(pub.Close())
+}
+
 func (s *publisherTestSuite) TestPublisherReconnect() {
 	s.T().Skip("publisher reconnect test not implemented")
 }
+
+func (s *publisherTestSuite) TestPublisherInfo() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	handler := mock.NewPublishHandler(fixture)
+	handler.OnInitialize = func(in *api.OpenStream) (*api.StreamReady, error) {
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock-node", Topics: map[string][]byte{"testing.123": fixture["testing.123"].Bytes()}}, nil
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	// The ready hook should be called every time the stream is opened
+	var mu sync.Mutex
+	var opened []stream.StreamInfo
+	hook := func(info stream.StreamInfo) {
+		mu.Lock()
+		opened = append(opened, info)
+		mu.Unlock()
+	}
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithIdleTimeout(50*time.Millisecond), stream.WithReadyHook(hook), stream.WithClientID("info"))
+	require.NoError(err, "could not connect to publisher")
+
+	info := pub.Info()
+	require.Equal(pub.ClientID(), info.ClientID)
+	require.Equal("mock-node", info.ServerID)
+	require.Equal(fixture, info.Topics)
+	require.False(info.Opened.IsZero(), "expected opened timestamp to be set")
+	require.Zero(info.Reconnects)
+
+	// Reopening the stream after it becomes idle should update the info
+	require.Eventually(pub.Idle, time.Second, 10*time.Millisecond, "expected publisher to become idle")
+	_, C, err := pub.Publish("testing.123", mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	require.NotNil((<-C).GetAck(), "expected event to be acked")
+
+	reopened := pub.Info()
+	require.Equal(uint64(1), reopened.Reconnects)
+	require.True(reopened.Opened.After(info.Opened), "expected opened timestamp to be updated")
+
+	mu.Lock()
+	require.Len(opened, 2, "expected ready hook to be called on open and reopen")
+	require.Equal(info, opened[0])
+	require.Equal(reopened, opened[1])
+	mu.Unlock()
+
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublisherAllowedTopics() {
+	fixture := map[string]ulid.ULID{
+		"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+		"example.456": ulid.MustParse("01H1PA4P7C6VT5KZCXH56H1XHS"),
+	}
+
+	// The server only returns the allowed topics in the topic map
+	var open *api.OpenStream
+	handler := mock.NewPublishHandler(fixture)
+	handler.OnInitialize = func(in *api.OpenStream) (*api.StreamReady, error) {
+		open = in
+		topics := make(map[string][]byte)
+		for _, name := range in.Topics {
+			topics[name] = fixture[name].Bytes()
+		}
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock-node", Topics: topics}, nil
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithClientID("allowed"), stream.WithTopics("testing.123"))
+	require.NoError(err, "could not connect to publisher")
+
+	require.NotNil(open, "expected the stream to be initialized")
+	require.Equal(pub.ClientID(), open.ClientId)
+	require.Equal([]string{"testing.123"}, open.Topics)
+	require.Equal(map[string]ulid.ULID{"testing.123": fixture["testing.123"]}, pub.Topics())
+
+	_, C, err := pub.Publish("testing.123", mock.NewEvent())
+	require.NoError(err, "could not publish to allowed topic")
+	require.NotNil((<-C).GetAck(), "expected event to be acked")
+
+	_, _, err = pub.Publish("example.456", mock.NewEvent())
+	require.ErrorIs(err, stream.ErrResolveTopic, "expected topic outside the allow list to be unresolved")
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublisherFlush() {
+	// Hold the acks from the server until the events are released.
+	release := make(chan struct{})
+	handler := mock.NewPublishHandler(nil)
+	ack := handler.OnEvent
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		<-release
+		return ack(in)
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisher(s.mock)
+	require.NoError(err, "could not connect to publisher")
+
+	// Flushing without any pending events returns immediately.
+	require.NoError(pub.Flush(context.Background()))
+
+	var acks sync.WaitGroup
+	acks.Add(3)
+	for i := 0; i < 3; i++ {
+		_, err := pub.PublishAsync("01H1PA4FA9G2Y79Z5FC36CWYYJ", &api.Event{Data: []byte("hello")}, func(*api.Ack, error) { acks.Done() })
+		require.NoError(err, "could not publish event")
+	}
+	require.Equal(3, pub.Pending())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(pub.Flush(ctx), context.DeadlineExceeded)
+	require.Equal(3, pub.Pending())
+
+	close(release)
+	require.NoError(pub.Flush(context.Background()))
+	require.Zero(pub.Pending())
+
+	acks.Wait()
+	require.NoError(pub.Close())
+}
+
+func (s *publisherTestSuite) TestPublisherResend() {
+	var (
+		mu      sync.Mutex
+		down    = true
+		fail    = make(chan struct{})
+		dropped *api.Event
+		resent  []*api.Event
+	)
+
+	// The first stream goes down without replying to any events; after the reconnect
+	// the events are acked and recorded.
+	handler := mock.NewPublishHandler(nil)
+	ack := handler.OnEvent
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		event, err := in.Unwrap()
+		s.Require().NoError(err, "could not unwrap event")
+
+		mu.Lock()
+		if down {
+			down = false
+			dropped = event
+			mu.Unlock()
+			<-fail
+			return nil, status.Error(codes.Unavailable, "node is going down")
+		}
+		resent = append(resent, event)
+		mu.Unlock()
+		return ack(in)
+	}
+	s.mock.server.OnPublish = handler.OnPublish
+
+	require := s.Require()
+	pub, err := stream.NewPublisherWithOptions(s.mock, stream.WithResend())
+	require.NoError(err, "could not connect to publisher")
+
+	replies := make([]<-chan *api.PublisherReply, 0, 3)
+	for i := 0; i < 3; i++ {
+		event := mock.NewEvent()
+		event.Metadata = map[string]string{"index": strconv.Itoa(i)}
+		_, C, err := pub.Publish("01H1PA4FA9G2Y79Z5FC36CWYYJ", event)
+		require.NoError(err, "could not publish event")
+		require.NotContains(event.Metadata, stream.IdempotencyKey, "the original metadata should not be modified")
+		replies = append(replies, C)
+	}
+	require.Equal(3, pub.Pending())
+	close(fail)
+
+	for i, C := range replies {
+		select {
+		case rep := <-C:
+			require.NotNil(rep.GetAck(), "expected event %d to be acked after reconnect", i)
+		case <-time.After(5 * time.Second):
+			require.Fail("expected event to be republished after reconnect")
+		}
+	}
+
+	require.Equal(uint64(3), pub.Stats().Resent)
+
+	// The events are republished in order with the same idempotency keys.
+	mu.Lock()
+	require.Len(resent, 3)
+	require.NotEmpty(dropped.Metadata[stream.IdempotencyKey])
+	require.Equal(dropped.Metadata[stream.IdempotencyKey], resent[0].Metadata[stream.IdempotencyKey])
+	for i, event := range resent {
+		require.Equal(strconv.Itoa(i), event.Metadata["index"])
+	}
+	mu.Unlock()
+
+	require.NoError(pub.Close())
+}
+
+func TestPublisherInMemory(t *testing.T) {
+	// The publisher can be tested without gRPC using in-memory streams
+	fixture := map[string]ulid.ULID{"testing.123": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ")}
+	streams := mock.NewStreams()
+	streams.OnPublish = mock.NewPublishHandler(fixture).OnPublish
+
+	require := require.New(t)
+	pub, err := stream.NewPublisher(streams)
+	require.NoError(err, "could not connect to publisher")
+	require.Equal(fixture, pub.Topics())
+
+	for i := 0; i < 5; i++ {
+		_, C, err := pub.Publish("testing.123", mock.NewEvent())
+		require.NoError(err, "could not publish event")
+		require.NotNil((<-C).GetAck(), "expected event to be acked")
+	}
+
+	require.NoError(pub.Close())
+	require.Equal(1, streams.Calls[mock.PublishRPC])
+
+	// Handler errors are returned to the client as status errors
+	handler := mock.NewPublishHandler(nil)
+	handler.OnInitialize = func(*api.OpenStream) (*api.StreamReady, error) {
+		return nil, status.Error(codes.Unauthenticated, "bad api keys")
+	}
+	streams.OnPublish = handler.OnPublish
+
+	_, err = stream.NewPublisher(streams)
+	CheckStatusError(require, err, codes.Unauthenticated, "bad api keys")
+
+	streams.Reset()
+	_, err = stream.NewPublisher(streams)
+	CheckStatusError(require, err, codes.Unavailable, "mock method has not been configured")
+}
+
+func TestPublisherRedirect(t *testing.T) {
+	// Nodes that redirect every event until the specified number of streams are opened.
+	topicID := "01H1PA4FA9G2Y79Z5FC36CWYYJ"
+	redirect := mock.NewPublishHandler(nil)
+	redirect.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_REDIRECT}}}, nil
+	}
+
+	streams := mock.NewStreams()
+	redirects := func(n int) {
+		streams.Reset()
+		streams.OnPublish = func(srv api.Ensign_PublishServer) error {
+			streams.RLock()
+			calls := streams.Calls[mock.PublishRPC]
+			streams.RUnlock()
+
+			if calls <= n {
+				return redirect.OnPublish(srv)
+			}
+			return mock.NewPublishHandler(nil).OnPublish(srv)
+		}
+	}
+
+	// A redirected event is republished on a new stream, even without resend
+	redirects(1)
+	require := require.New(t)
+	pub, err := stream.NewPublisher(streams)
+	require.NoError(err, "could not connect to publisher")
+
+	_, C, err := pub.Publish(topicID, mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	require.NotNil((<-C).GetAck(), "expected event to be acked after the redirect")
+	require.NoError(pub.Close())
+
+	stats := pub.Stats()
+	require.Equal(2, streams.Calls[mock.PublishRPC])
+	require.Equal(uint64(1), stats.Redirects)
+	require.Equal(uint64(1), stats.Resent)
+	require.Equal(uint64(1), stats.Acks)
+	require.Zero(stats.Nacks)
+
+	// The nack is delivered if the event keeps being redirected
+	redirects(10)
+	pub, err = stream.NewPublisher(streams)
+	require.NoError(err, "could not connect to publisher")
+
+	_, C, err = pub.Publish(topicID, mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	require.Equal(api.Nack_REDIRECT, (<-C).GetNack().GetCode())
+	require.NoError(pub.Close())
+
+	stats = pub.Stats()
+	require.Equal(4, streams.Calls[mock.PublishRPC])
+	require.Equal(uint64(3), stats.Redirects)
+	require.Equal(uint64(1), stats.Nacks)
+
+	// Without resend only the redirected event is republished; the reply to the other
+	// pending event may have been lost after the previous node accepted it.
+	resent := make(chan *api.EventWrapper, 2)
+	streams.Reset()
+	streams.OnPublish = func(srv api.Ensign_PublishServer) error {
+		streams.RLock()
+		calls := streams.Calls[mock.PublishRPC]
+		streams.RUnlock()
+
+		handler := mock.NewPublishHandler(nil)
+		if calls == 1 {
+			received := 0
+			handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+				if received++; received == 1 {
+					return nil, nil
+				}
+				return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_REDIRECT}}}, nil
+			}
+			return handler.OnPublish(srv)
+		}
+
+		ack := handler.OnEvent
+		handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+			resent <- in
+			return ack(in)
+		}
+		return handler.OnPublish(srv)
+	}
+
+	pub, err = stream.NewPublisher(streams)
+	require.NoError(err, "could not connect to publisher")
+
+	_, _, err = pub.Publish(topicID, mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	redirected, C, err := pub.Publish(topicID, mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	require.NotNil((<-C).GetAck(), "expected event to be acked after the redirect")
+
+	require.Len(resent, 1, "expected only the redirected event to be republished")
+	require.Equal(redirected.LocalId, (<-resent).LocalId)
+	require.Equal(1, pub.Pending(), "expected the event that was not redirected to remain pending")
+	require.NoError(pub.Close())
+
+	stats = pub.Stats()
+	require.Equal(2, streams.Calls[mock.PublishRPC])
+	require.Equal(uint64(1), stats.Redirects)
+	require.Equal(uint64(1), stats.Resent)
+}
+
+func TestPublisherServerClose(t *testing.T) {
+	// The first stream is closed by the server once it is ready
+	streams := mock.NewStreams()
+	streams.OnPublish = func(srv api.Ensign_PublishServer) error {
+		streams.RLock()
+		calls := streams.Calls[mock.PublishRPC]
+		streams.RUnlock()
+
+		if calls > 1 {
+			return mock.NewPublishHandler(nil).OnPublish(srv)
+		}
+
+		if _, err := srv.Recv(); err != nil {
+			return err
+		}
+
+		if err := srv.Send(&api.PublisherReply{Embed: &api.PublisherReply_Ready{Ready: &api.StreamReady{ServerId: "alpha"}}}); err != nil {
+			return err
+		}
+		return srv.Send(&api.PublisherReply{Embed: &api.PublisherReply_CloseStream{CloseStream: &api.CloseStream{}}})
+	}
+
+	require := require.New(t)
+	pub, err := stream.NewPublisher(streams)
+	require.NoError(err, "could not connect to publisher")
+
+	// The publisher reopens the stream rather than stopping
+	require.Eventually(func() bool {
+		return pub.Info().ServerID == "mock"
+	}, time.Second, 10*time.Millisecond, "expected the stream to be reopened")
+
+	_, C, err := pub.Publish("01H1PA4FA9G2Y79Z5FC36CWYYJ", mock.NewEvent())
+	require.NoError(err, "could not publish event")
+	require.NotNil((<-C).GetAck(), "expected event to be acked")
+
+	// Closing the publisher does not reopen the stream
+	require.NoError(pub.Close())
+	require.NoError(pub.Err())
+	require.Equal(2, streams.Calls[mock.PublishRPC])
+}
+
+func TestPublisherConnectionHook(t *testing.T) {
+	// The first stream fails, the second stream is closed, and the third cannot be opened
+	streams := mock.NewStreams()
+	streams.OnPublish = func(srv api.Ensign_PublishServer) error {
+		streams.RLock()
+		calls := streams.Calls[mock.PublishRPC]
+		streams.RUnlock()
+
+		if calls > 2 {
+			return status.Error(codes.Unauthenticated, "token expired")
+		}
+
+		if _, err := srv.Recv(); err != nil {
+			return err
+		}
+
+		if err := srv.Send(&api.PublisherReply{Embed: &api.PublisherReply_Ready{Ready: &api.StreamReady{ServerId: "node" + strconv.Itoa(calls)}}}); err != nil {
+			return err
+		}
+
+		if calls == 1 {
+			return status.Error(codes.Unavailable, "node is shutting down")
+		}
+		return srv.Send(&api.PublisherReply{Embed: &api.PublisherReply_CloseStream{CloseStream: &api.CloseStream{}}})
+	}
+
+	require := require.New(t)
+	recorder := &connectionRecorder{}
+	pub, err := stream.NewPublisherWithOptions(streams, stream.WithConnectionHook(recorder.Hook))
+	require.NoError(err, "could not connect to publisher")
+	defer pub.Close()
+
+	require.Eventually(func() bool {
+		return pub.Err() != nil
+	}, time.Second, 10*time.Millisecond, "expected the publisher to fail")
+
+	events := recorder.Events()
+	require.Equal([]stream.ConnectionState{stream.Connected, stream.Reconnecting, stream.Reconnected, stream.Reconnecting, stream.Fatal}, recorder.States())
+	for _, event := range events {
+		require.Equal(stream.SourcePublisher, event.Source)
+		require.False(event.Time.IsZero())
+	}
+
+	require.Equal("node1", events[0].Info.ServerID)
+	require.Equal("node2", events[2].Info.ServerID)
+	require.Equal(uint64(1), events[2].Info.Reconnects)
+	require.Equal(codes.Unauthenticated, status.Code(events[4].Err))
+	require.ErrorIs(pub.Err(), events[4].Err)
+}
+
+// Records the connection events sent to a connection hook.
+type connectionRecorder struct {
+	sync.Mutex
+	events []stream.ConnectionEvent
+}
+
+func (r *connectionRecorder) Hook(event stream.ConnectionEvent) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *connectionRecorder) Events() []stream.ConnectionEvent {
+	r.Lock()
+	defer r.Unlock()
+	return append([]stream.ConnectionEvent(nil), r.events...)
+}
+
+func (r *connectionRecorder) States() []stream.ConnectionState {
+	events := r.Events()
+	states := make([]stream.ConnectionState, 0, len(events))
+	for _, event := range events {
+		states = append(states, event.State)
+	}
+	return states
+}
+
+func TestPublisherMaxEventSize(t *testing.T) {
+	streams := mock.NewStreams()
+	streams.OnPublish = mock.NewPublishHandler(nil).OnPublish
+
+	require := require.New(t)
+	pub, err := stream.NewPublisherWithOptions(streams, stream.WithMaxEventSize(1024))
+	require.NoError(err, "could not connect to publisher")
+
+	// Events larger than the maximum event size are not sent
+	topicID := "01H1PA4FA9G2Y79Z5FC36CWYYJ"
+	event := mock.NewEvent()
+	event.Data = make([]byte, 1024)
+	_, _, err = pub.Publish(topicID, event)
+	require.ErrorIs(err, stream.ErrEventTooLarge)
+	require.Zero(pub.Stats().Events)
+	require.Zero(pub.Pending())
+
+	event.Data = make([]byte, 512)
+	_, C, err := pub.Publish(topicID, event)
+	require.NoError(err, "could not publish event")
+	require.NotNil((<-C).GetAck(), "expected event to be acked")
+	require.NoError(pub.Close())
+
+	// A negative size disables the check
+	pub, err = stream.NewPublisherWithOptions(streams, stream.WithMaxEventSize(-1))
+	require.NoError(err, "could not connect to publisher")
+
+	event.Data = make([]byte, stream.DefaultMaxEventSize)
+	_, _, err = pub.Publish(topicID, event)
+	require.NoError(err, "expected the event to be sent")
+	require.NoError(pub.Close())
+}
@@ -0,0 +1,37 @@
+package stream
+
+// Quota specifies limits on the number of events and bytes that may be sent during a
+// single stream session (e.g. since the stream was last opened or resumed). When a soft
+// limit is reached the OnWarning hook is called once for the session so that producers
+// can be alerted that they are approaching a project quota or are stuck in a
+// pathological loop. When a hard limit is reached the stream is paused and all
+// subsequent sends fail with ErrPaused until Resume is explicitly called. Zero values
+// for any limit means that the limit is not enforced.
+type Quota struct {
+	SoftEvents uint64
+	SoftBytes  uint64
+	HardEvents uint64
+	HardBytes  uint64
+	OnWarning  func(Usage)
+}
+
+// Usage describes the number of events and bytes sent during the current session.
+type Usage struct {
+	Events uint64
+	Bytes  uint64
+}
+
+// Enabled returns true if any soft or hard limit is configured on the quota.
+func (q Quota) Enabled() bool {
+	return q.SoftEvents > 0 || q.SoftBytes > 0 || q.HardEvents > 0 || q.HardBytes > 0
+}
+
+// Returns true if the usage has reached or exceeded any of the soft limits.
+func (q Quota) soft(u Usage) bool {
+	return (q.SoftEvents > 0 && u.Events >= q.SoftEvents) || (q.SoftBytes > 0 && u.Bytes >= q.SoftBytes)
+}
+
+// Returns true if the usage has reached or exceeded any of the hard limits.
+func (q Quota) hard(u Usage) bool {
+	return (q.HardEvents > 0 && u.Events >= q.HardEvents) || (q.HardBytes > 0 && u.Bytes >= q.HardBytes)
+}
@@ -0,0 +1,33 @@
+package stream
+
+import (
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/spaolacci/murmur3"
+)
+
+// PublishOption modifies the event wrapper of an event before it is sent to the server.
+type PublishOption func(env *api.EventWrapper)
+
+// WithKey sets the partition key of the published event. When the topic is sharded
+// with the CONSISTENT_KEY_HASH strategy, the server assigns all events with the same
+// key to the same shard so that they are kept in order relative to each other.
+func WithKey(key []byte) PublishOption {
+	return func(env *api.EventWrapper) {
+		env.Key = key
+	}
+}
+
+// KeyHash returns the 64 bit murmur3 hash of the partition key.
+func KeyHash(key []byte) uint64 {
+	return murmur3.Sum64(key)
+}
+
+// ShardFor returns the shard in [0, shards) that the partition key hashes to, e.g. to
+// partition keyed events across local workers in the same way they are partitioned
+// across the shards of a topic. If there are no shards then 0 is returned.
+func ShardFor(key []byte, shards uint32) uint64 {
+	if shards == 0 {
+		return 0
+	}
+	return KeyHash(key) % uint64(shards)
+}
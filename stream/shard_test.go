@@ -0,0 +1,29 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/go-ensign/stream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardFor(t *testing.T) {
+	// Keys are always assigned to the same shard
+	key := []byte("customer-42")
+	require.Equal(t, stream.KeyHash(key), stream.KeyHash([]byte("customer-42")))
+	require.Equal(t, stream.KeyHash(key)%8, stream.ShardFor(key, 8))
+	require.Equal(t, stream.ShardFor(key, 8), stream.ShardFor([]byte("customer-42"), 8))
+
+	// Keys should be spread across all of the shards
+	counts := make(map[uint64]int)
+	for i := 0; i < 1000; i++ {
+		shard := stream.ShardFor([]byte{byte(i), byte(i >> 8)}, 4)
+		require.Less(t, shard, uint64(4))
+		counts[shard]++
+	}
+	require.Len(t, counts, 4)
+
+	// There is only one shard if the topic is not sharded
+	require.Zero(t, stream.ShardFor(key, 0))
+	require.Zero(t, stream.ShardFor(key, 1))
+}
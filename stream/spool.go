@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"google.golang.org/protobuf/proto"
+)
+
+// spool is a disk-backed FIFO queue of events that is used by the subscriber to spill
+// events to disk when the events channel is full. Events are appended to a temporary
+// file as length-prefixed protocol buffers and read back in order; when all spilled
+// events have been read the file is truncated so that it does not grow unbounded.
+type spool struct {
+	sync.Mutex
+	file   *os.File
+	rpos   int64         // offset of the next event to read
+	wpos   int64         // offset to write the next event to
+	count  int           // number of events in the spool
+	notify chan struct{} // signals that an event has been spilled
+}
+
+func newSpool(dir string) (_ *spool, err error) {
+	s := &spool{notify: make(chan struct{}, 1)}
+	if s.file, err = os.CreateTemp(dir, "ensign-spill-*"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Len returns the number of events in the spool.
+func (s *spool) Len() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.count
+}
+
+// Push appends the event to the end of the spool.
+func (s *spool) Push(event *api.EventWrapper) (err error) {
+	var data []byte
+	if data, err = proto.Marshal(event); err != nil {
+		return err
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(buf, uint64(len(data)))
+	n += copy(buf[n:], data)
+
+	s.Lock()
+	if _, err = s.file.WriteAt(buf[:n], s.wpos); err != nil {
+		s.Unlock()
+		return err
+	}
+	s.wpos += int64(n)
+	s.count++
+	s.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Peek reads the event at the front of the spool without removing it; the returned
+// size must be passed to Pop to remove the event once it has been handled. If the
+// spool is empty, io.EOF is returned.
+func (s *spool) Peek() (event *api.EventWrapper, size int64, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.count == 0 {
+		return nil, 0, io.EOF
+	}
+
+	reader := io.NewSectionReader(s.file, s.rpos, s.wpos-s.rpos)
+	var length uint64
+	if length, err = binary.ReadUvarint(&byteReader{r: reader}); err != nil {
+		return nil, 0, err
+	}
+
+	prefix := int64(uvarintLen(length))
+	data := make([]byte, length)
+	if _, err = s.file.ReadAt(data, s.rpos+prefix); err != nil {
+		return nil, 0, err
+	}
+
+	event = &api.EventWrapper{}
+	if err = proto.Unmarshal(data, event); err != nil {
+		return nil, 0, err
+	}
+	return event, prefix + int64(length), nil
+}
+
+// Pop removes the event at the front of the spool, truncating the spill file if the
+// spool is empty.
+func (s *spool) Pop(size int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.rpos += size
+	s.count--
+
+	if s.count == 0 {
+		s.rpos, s.wpos = 0, 0
+		return s.file.Truncate(0)
+	}
+	return nil
+}
+
+// Close and remove the spill file; any events remaining in the spool are discarded.
+func (s *spool) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// byteReader adapts an io.Reader to an io.ByteReader to read uvarint prefixes.
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(b.r, b.buf[:]); err != nil {
+		return 0, err
+	}
+	return b.buf[0], nil
+}
+
+func uvarintLen(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
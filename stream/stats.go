@@ -0,0 +1,51 @@
+package stream
+
+import "time"
+
+// PublisherStats aggregates the number of events sent on a publish stream along with
+// the acks and nacks received from the server. Latencies are tracked for every ack:
+// the round trip is measured from the moment the event is sent on the stream until
+// the ack is received and the commit latency is measured from the event's created
+// timestamp until the committed timestamp assigned by the server. Events republished
+// after a reconnect are counted by Resent rather than Events and events that were not
+// replied to within the ack timeout are counted by Timeouts rather than Nacks. REDIRECT
+// nacks that cause the event to be republished on a new stream are counted by Redirects.
+type PublisherStats struct {
+	Events    uint64
+	Acks      uint64
+	Nacks     uint64
+	Resent    uint64
+	Timeouts  uint64
+	Redirects uint64
+	RoundTrip LatencyStats
+	Committed LatencyStats
+}
+
+// LatencyStats is a lightweight online aggregation of observed durations.
+type LatencyStats struct {
+	Count uint64
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the average observed latency or zero if there are no observations.
+func (l LatencyStats) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+func (l *LatencyStats) update(d time.Duration) {
+	if l.Count == 0 || d < l.Min {
+		l.Min = d
+	}
+
+	if d > l.Max {
+		l.Max = d
+	}
+
+	l.Count++
+	l.Total += d
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 )
@@ -17,11 +18,25 @@ const (
 	ReconnectTimeout = 5 * time.Minute
 )
 
+// DefaultMaxEventSize is the maximum size in bytes of the encoded publish request that
+// carries an event unless otherwise specified WithMaxEventSize. It matches the default
+// maximum message size that Ensign nodes receive so that events the server would reject
+// fail fast on the client.
+const DefaultMaxEventSize = 4 * 1024 * 1024
+
 type ConnectionObserver interface {
 	ConnState() connectivity.State
 	WaitForReconnect(ctx context.Context) bool
 }
 
+// BackoffObserver is implemented by connection observers that can wait for the
+// connection to be re-established using a specific backoff rather than their own retry
+// policy. Streams configured WithBackoff require the client to implement this interface,
+// otherwise the client's WaitForReconnect is used.
+type BackoffObserver interface {
+	WaitForReconnectBackoff(ctx context.Context, b backoff.Backoff) bool
+}
+
 type PublishClient interface {
 	ConnectionObserver
 	PublishStream(context.Context, ...grpc.CallOption) (api.Ensign_PublishClient, error)
@@ -31,3 +46,23 @@ type SubscribeClient interface {
 	ConnectionObserver
 	SubscribeStream(context.Context, ...grpc.CallOption) (api.Ensign_SubscribeClient, error)
 }
+
+// Wait for the connection of the client to be re-established. If the stream has a
+// backoff policy and the client implements BackoffObserver then the policy determines
+// how long to wait, otherwise the client waits for up to the ReconnectTimeout.
+func waitForReconnect(client ConnectionObserver, policy backoff.Policy) error {
+	if observer, ok := client.(BackoffObserver); ok && policy != nil {
+		if !observer.WaitForReconnectBackoff(context.Background(), policy()) {
+			return ErrReconnect
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReconnectTimeout)
+	defer cancel()
+
+	if !client.WaitForReconnect(ctx) {
+		return ErrReconnect
+	}
+	return nil
+}
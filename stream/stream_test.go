@@ -2,9 +2,12 @@ package stream_test
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
 	"github.com/rotationalio/go-ensign/mock"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -69,6 +72,22 @@ func (c *MockConnectionObserver) WaitForReconnect(ctx context.Context) bool {
 	}
 }
 
+// BackoffObserver records the backoff used by a stream to wait for a reconnect and
+// simulates a connection that is never re-established.
+type BackoffObserver struct {
+	*MockConnectionObserver
+	calls   int32
+	retries int32
+}
+
+func (c *BackoffObserver) WaitForReconnectBackoff(ctx context.Context, b backoff.Backoff) bool {
+	atomic.AddInt32(&c.calls, 1)
+	for backoff.Wait(ctx, b) == nil {
+		atomic.AddInt32(&c.retries, 1)
+	}
+	return false
+}
+
 func (c *MockConnectionObserver) PublishStream(ctx context.Context, opts ...grpc.CallOption) (api.Ensign_PublishClient, error) {
 	return c.client.Publish(ctx, opts...)
 }
@@ -88,3 +107,30 @@ func CheckStatusError(require *require.Assertions, err error, code codes.Code, m
 		require.Equal(message, serr.Message(), msgAndArgs...)
 	}
 }
+
+// RecordingLogger implements stream.Logger and records the messages logged at each
+// level so that tests can assert on the activity reported by the stream managers.
+type RecordingLogger struct {
+	sync.Mutex
+	logs map[string][]string
+}
+
+func (l *RecordingLogger) Debug(msg string, _ ...interface{}) { l.record("debug", msg) }
+func (l *RecordingLogger) Info(msg string, _ ...interface{})  { l.record("info", msg) }
+func (l *RecordingLogger) Warn(msg string, _ ...interface{})  { l.record("warn", msg) }
+func (l *RecordingLogger) Error(msg string, _ ...interface{}) { l.record("error", msg) }
+
+func (l *RecordingLogger) record(level, msg string) {
+	l.Lock()
+	defer l.Unlock()
+	if l.logs == nil {
+		l.logs = make(map[string][]string)
+	}
+	l.logs[level] = append(l.logs[level], msg)
+}
+
+func (l *RecordingLogger) Messages(level string) []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string(nil), l.logs[level]...)
+}
@@ -3,12 +3,15 @@ package stream
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 
 	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 // Subscriber wraps a stream.SubscribeClient to maintain an open subscribe stream to an
@@ -16,7 +19,8 @@ import (
 // for when the stream goes down and attempts to reconnect it gracefully. This go
 // routine also spins off go routines for receiving messages from the stream. The
 // received events are passed to a channel that must be consumed by the caller; if the
-// channel fills up, the event will be dropped and a nack sent back to the server.
+// channel fills up, the overflow policy determines if the receiver blocks, drops and
+// nacks the event, or spills the event to disk until the caller catches up.
 //
 // Sending acks/nacks back to the server happens synchronously in the user thread, an
 // error is returned if the message cannot be sent.
@@ -26,14 +30,25 @@ type Subscriber struct {
 	subscription *api.Subscription          // the subscription info to initialize the stream (e.g. consumer groups, topics, etc.)
 	smu          sync.RWMutex               // guards updates to the stream
 	stream       api.Ensign_SubscribeClient // the currently open stream, maintained open using reconnect
+	closing      bool                       // if the subscriber is closing and the stream must not be reopened
 	events       chan<- *api.EventWrapper   // the channel received events are sent on
 	stop         chan struct{}              // global stop signal to shutdown the subscriber
 	down         chan struct{}              // signal from the receiver that the stream is down and needs to be reconnected
-	wg           *sync.WaitGroup            // reusable wait group to wait until the start and receive go routines are stopped
+	resub        chan chan error            // requests that the start go routine reopens the stream with the updated subscription
+	wg           *sync.WaitGroup            // wait group to wait until the start and receive go routines are stopped
 	fmu          sync.RWMutex               // guards updates to the fatal error
 	fatal        error                      // if the subscriber has fatally errored and cannot reconnect
-	topics       map[string]ulid.ULID       // maps topic names to topic IDs from the server
-	serverID     string                     // the server this subscriber is connected to
+	info         StreamInfo                 // stream info (e.g. topics) sent by the server when the stream is opened
+	onReady      ReadyHook                  // called with the stream info when the stream is opened
+	onConn       ConnectionHook             // called on connection state transitions
+	overflow     OverflowPolicy             // how to handle received events when the events channel is full
+	spool        *spool                     // events spilled to disk if the overflow policy is OverflowSpill
+	quit         chan struct{}              // stops the spool drain go routine
+	drained      chan struct{}              // closed when the spool drain go routine exits
+	failed       chan struct{}              // closed by the spool drain go routine if spilled events cannot be read
+	log          Logger                     // reports stream activity that would otherwise be silent
+	done         chan struct{}              // closed when the subscriber stops, either on close or a fatal error
+	backoff      backoff.Policy             // the policy used to wait for the connection to be re-established
 }
 
 // Create a new low-level subscribe stream manager that maintains an open subscribe
@@ -45,34 +60,76 @@ type Subscriber struct {
 // get events from the server, which are sent down the returned event channel.
 //
 // NOTE: it is the caller's responsibility to consume the returned event channel; if the
-// buffer gets filled up events may be dropped and nacked back to the server.
-func NewSubscriber(client SubscribeClient, topics []string, opts ...grpc.CallOption) (_ <-chan *api.EventWrapper, _ *Subscriber, err error) {
+// buffer gets filled up the receiver blocks unless another overflow policy is specified
+// using WithOverflowPolicy, in which case events may be nacked or spilled to disk.
+func NewSubscriber(client SubscribeClient, topics []string, opts ...grpc.CallOption) (<-chan *api.EventWrapper, *Subscriber, error) {
+	return NewSubscriberWithOptions(client, topics, WithCallOptions(opts...))
+}
+
+// NewSubscriberWithOptions creates a subscriber stream manager like NewSubscriber that
+// is configured by the stream options, e.g. to set the buffer size or overflow policy.
+func NewSubscriberWithOptions(client SubscribeClient, topics []string, opts ...Option) (_ <-chan *api.EventWrapper, _ *Subscriber, err error) {
+	options := newOptions(opts...)
 	sub := &Subscriber{
-		client: client,
-		copts:  opts,
-		stop:   make(chan struct{}, 1),
-		down:   make(chan struct{}, 1),
-		wg:     &sync.WaitGroup{},
-		fatal:  nil,
+		client:   client,
+		copts:    options.CallOptions,
+		stop:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		down:     make(chan struct{}, 1),
+		resub:    make(chan chan error),
+		wg:       &sync.WaitGroup{},
+		fatal:    nil,
+		overflow: options.Overflow,
+		log:      options.Logger,
+		onReady:  options.OnReady,
+		onConn:   options.OnConnection,
+		backoff:  options.Backoff,
+	}
+
+	// Create the spool to spill events to disk before the stream is opened.
+	if sub.overflow == OverflowSpill {
+		if sub.spool, err = newSpool(options.SpillDir); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Create the subscription to reconnect the stream with.
 	// TODO: map topic names to IDs for a better subscription experience
 	// TODO: handle consumer groups, queries, and other subscribe options.
 	sub.subscription = &api.Subscription{
-		ClientId: ulid.Make().String(),
+		ClientId: clientID(options.ClientID),
 		Topics:   topics,
 	}
 
+	if len(options.Offsets) > 0 {
+		sub.subscription.Group = &api.ConsumerGroup{TopicOffsets: options.Offsets}
+	}
+
 	if err = sub.openStream(); err != nil {
+		if sub.spool != nil {
+			sub.spool.Close()
+		}
 		return nil, nil, err
 	}
+	sub.onConn.notify(Connected, SourceSubscriber, sub.Info(), nil)
 
 	// Create the channel to send received events on
-	events := make(chan *api.EventWrapper, BufferSize)
+	size := options.BufferSize
+	if size <= 0 {
+		size = BufferSize
+	}
+
+	events := make(chan *api.EventWrapper, size)
 	sub.events = events
 
 	// Start go routines
+	if sub.spool != nil {
+		sub.quit = make(chan struct{})
+		sub.drained = make(chan struct{})
+		sub.failed = make(chan struct{})
+		go sub.drain()
+	}
+
 	sub.wg.Add(1)
 	go sub.start()
 
@@ -91,7 +148,7 @@ func (c *Subscriber) Ack(ack *api.Ack) error {
 	c.smu.RLock()
 	defer c.smu.RUnlock()
 	if c.stream == nil {
-		panic("cannot send ack when stream is not open")
+		return ErrStreamNotOpen
 	}
 
 	return c.stream.Send(req)
@@ -109,7 +166,7 @@ func (c *Subscriber) Nack(nack *api.Nack) error {
 	c.smu.RLock()
 	defer c.smu.RUnlock()
 	if c.stream == nil {
-		panic("cannot send nack when stream is not open")
+		return ErrStreamNotOpen
 	}
 
 	return c.stream.Send(req)
@@ -120,10 +177,15 @@ func (c *Subscriber) Close() error {
 	// Send a stop signal so that we do not reconnect on error
 	c.stop <- struct{}{}
 
-	// Attempt to send a close stream message
-	c.smu.RLock()
-	err := c.stream.CloseSend()
-	c.smu.RUnlock()
+	// Attempt to send a close stream message; if the stream could not be reopened
+	// after a fatal error there is no stream to close.
+	var err error
+	c.smu.Lock()
+	c.closing = true
+	if c.stream != nil {
+		err = c.stream.CloseSend()
+	}
+	c.smu.Unlock()
 
 	if err != nil {
 		return err
@@ -132,11 +194,26 @@ func (c *Subscriber) Close() error {
 	// Wait until subscriber stops gracefully
 	c.wg.Wait()
 
+	// Stop draining the spool; any events that remain on disk are discarded and will be
+	// redelivered by the server since they were never acked.
+	if c.spool != nil {
+		close(c.quit)
+		<-c.drained
+		c.spool.Close()
+	}
+
 	// Close the events channel to signal to any go routines that the subscriber is done.
 	close(c.events)
 	return nil
 }
 
+// Done returns a channel that is closed when the subscriber stops maintaining the
+// stream, either because it was closed or because of a fatal error, which can be
+// retrieved with Err. No more events are received once the subscriber is done.
+func (c *Subscriber) Done() <-chan struct{} {
+	return c.done
+}
+
 // Err returns any fatal errors that are set on the subscriber. If a non-nil error is
 // returned then the subscriber is not running so no events will be received and no
 // messages can be sent to the server.
@@ -146,12 +223,57 @@ func (c *Subscriber) Err() error {
 	return c.fatal
 }
 
+// Subscription returns a copy of the subscription used to initialize the stream.
+func (c *Subscriber) Subscription() *api.Subscription {
+	c.smu.RLock()
+	defer c.smu.RUnlock()
+	return proto.Clone(c.subscription).(*api.Subscription)
+}
+
+// Resubscribe applies the update function to the subscription used to initialize the
+// stream then reopens the stream so that the server applies the modified subscription
+// (e.g. to start from specific consumer group offsets). The previous stream is closed
+// once the new stream is ready. The update is also used for all subsequent reconnects,
+// so if the stream cannot be reopened the error is returned and the subscriber attempts
+// to reconnect with the updated subscription as though the stream went down.
+func (c *Subscriber) Resubscribe(update func(*api.Subscription)) (err error) {
+	c.smu.Lock()
+	update(c.subscription)
+	c.smu.Unlock()
+
+	// The stream is reopened by the start go routine, which owns the receivers.
+	errc := make(chan error, 1)
+	select {
+	case c.resub <- errc:
+		return <-errc
+	case <-c.done:
+		if err = c.Err(); err != nil {
+			return err
+		}
+		return ErrSubscriberClosed
+	}
+}
+
+// ClientID returns the client ID that identifies the subscriber stream to the server.
+func (c *Subscriber) ClientID() string {
+	c.smu.RLock()
+	defer c.smu.RUnlock()
+	return c.subscription.ClientId
+}
+
 // Topics returns the map of topic names to ULID that is sent by the server when the
 // stream is opened and correctly initialized.
 func (c *Subscriber) Topics() map[string]ulid.ULID {
 	c.smu.RLock()
 	defer c.smu.RUnlock()
-	return c.topics
+	return c.info.Topics
+}
+
+// Info returns the stream info sent by the server when the stream was last opened.
+func (c *Subscriber) Info() StreamInfo {
+	c.smu.RLock()
+	defer c.smu.RUnlock()
+	return c.info
 }
 
 // The start go routine manages the stream and receive go routine. If the receive go
@@ -160,28 +282,41 @@ func (c *Subscriber) Topics() map[string]ulid.ULID {
 func (c *Subscriber) start() {
 	// Ensure the start go routine marks itself as done when it exits
 	defer c.wg.Done()
+	defer close(c.done)
 
 	// Start a receiver channel; it is assumed that openStream has already been called.
-	go c.receiver(c.stream)
+	c.startReceiver()
 
 	// Maintain the subscribe stream connection
 	for {
 		select {
 		case <-c.down:
-			// If we're not able to reconnect in a timely fashion, set the fatal error.
-			if err := c.reconnect(); err != nil {
-				c.setFatal(err)
+			if !c.restart() {
 				return
 			}
 
-			// Attempt to reopen the stream to the server
-			if err := c.openStream(); err != nil {
-				c.setFatal(err)
-				return
+		case errc := <-c.resub:
+			err := c.resubscribe()
+			errc <- err
+
+			// If the stream could not be reopened, reconnect as though the stream is down.
+			if err != nil {
+				if errors.Is(err, ErrSubscriberClosed) || !c.restart() {
+					return
+				}
 			}
 
-			// Restart the receiver, which should have been stopped when we got the down signal.
-			go c.receiver(c.stream)
+		case <-c.failed:
+			// Spilled events can no longer be delivered so stop receiving events; the
+			// stream is closed so that the receiver stops and the server can redeliver
+			// the events that were not acked.
+			c.smu.Lock()
+			c.closing = true
+			if c.stream != nil {
+				c.stream.CloseSend()
+			}
+			c.smu.Unlock()
+			return
 
 		case <-c.stop:
 			return
@@ -189,14 +324,88 @@ func (c *Subscriber) start() {
 	}
 }
 
+// Reconnects to the server, reopens the stream and restarts the receiver after the
+// stream went down. If the stream cannot be reopened the fatal error is set and false
+// is returned so that the start go routine stops.
+func (c *Subscriber) restart() bool {
+	// If we're not able to reconnect in a timely fashion, set the fatal error.
+	c.log.Info("subscribe stream is down, reconnecting", "client_id", c.ClientID())
+	c.onConn.notify(Reconnecting, SourceSubscriber, c.Info(), nil)
+	if err := c.reconnect(); err != nil {
+		c.log.Error("could not reconnect subscribe stream", "client_id", c.ClientID(), "error", err)
+		c.setFatal(err)
+		return false
+	}
+
+	// Attempt to reopen the stream to the server
+	if err := c.openStream(); err != nil {
+		if errors.Is(err, ErrSubscriberClosed) {
+			return false
+		}
+
+		c.log.Error("could not reopen subscribe stream", "client_id", c.ClientID(), "error", err)
+		c.setFatal(err)
+		return false
+	}
+	c.log.Info("subscribe stream reconnected", "client_id", c.ClientID(), "server_id", c.Info().ServerID)
+	c.onConn.notify(Reconnected, SourceSubscriber, c.Info(), nil)
+
+	// Restart the receiver, which should have been stopped when we got the down signal.
+	c.startReceiver()
+	return true
+}
+
+// Reopens the stream with the updated subscription, restarts the receiver on the new
+// stream and closes the previous stream, which causes its receiver to stop on EOF. If
+// the new stream cannot be opened, the previous stream is closed since it has been
+// replaced and the error is returned.
+func (c *Subscriber) resubscribe() (err error) {
+	c.smu.RLock()
+	prev := c.stream
+	c.smu.RUnlock()
+
+	if err = c.openStream(); err == nil {
+		c.startReceiver()
+	}
+
+	if prev != nil {
+		if cerr := prev.CloseSend(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Starts a receiver on the current stream that is tracked by the wait group.
+func (c *Subscriber) startReceiver() {
+	c.smu.RLock()
+	stream := c.stream
+	c.smu.RUnlock()
+
+	c.wg.Add(1)
+	go c.receiver(stream)
+}
+
 // openStream returns a new subscribe bidirectional stream using the Ensign client. It
 // uses the default timeout to establish the stream and returns an error if the stream
 // could not be connected. Once connected, it sends a subscription message to the server
 // and waits until it receives the stream ready response from the server. If it fails
 // to open the stream or the subscription cannot be established an error is returned.
 func (c *Subscriber) openStream() (err error) {
+	// Call the ready hook once the stream lock has been released.
+	var info StreamInfo
+	defer func() {
+		if err == nil && c.onReady != nil {
+			c.onReady(info)
+		}
+	}()
+
 	c.smu.Lock()
 	defer c.smu.Unlock()
+	if c.closing {
+		return ErrSubscriberClosed
+	}
+
 	if c.stream, err = c.client.SubscribeStream(context.Background(), c.copts...); err != nil {
 		return err
 	}
@@ -218,60 +427,152 @@ func (c *Subscriber) openStream() (err error) {
 	}
 
 	// Create topic map and server info
-	c.serverID = ready.ServerId
-	c.topics = make(map[string]ulid.ULID)
-	for name, data := range ready.Topics {
-		var topicID ulid.ULID
-		if err = topicID.UnmarshalBinary(data); err == nil {
-			c.topics[name] = topicID
-		}
-	}
-
+	c.info = newStreamInfo(ready, c.info)
+	info = c.info
 	return nil
 }
 
 // Wait for the gRPC connection to reconnect to the Ensign node.
 func (c *Subscriber) reconnect() error {
-	ctx, cancel := context.WithTimeout(context.Background(), ReconnectTimeout)
-	defer cancel()
-
-	if !c.client.WaitForReconnect(ctx) {
-		return ErrReconnect
-	}
-	return nil
+	return waitForReconnect(c.client, c.backoff)
 }
 
 // The receiver go routine listens for subscribe events and sends them to the events
 // channel. It is this routine's responsibility to detect if the stream is down on an
 // error by recv. If so, the routine quits and sends a signal to the start routine to
-// reconnect. Note that if the events buffer is full, this routine will block forever.
+// reconnect. Note that if the events buffer is full and the overflow policy is to block
+// then this routine will block until the caller consumes an event.
+//
+// If the server ends the stream, e.g. because the placement of the subscribed topics
+// has changed, the stream is reopened so that it migrates to a node that can serve the
+// subscription. The stream is not reopened if it was closed by Close or Resubscribe.
 func (c *Subscriber) receiver(stream api.Ensign_SubscribeClient) {
+	defer c.wg.Done()
 	for {
-		in, err := c.stream.Recv()
+		in, err := stream.Recv()
 		if err != nil {
-			// Assume a clean shutdown when error is EOF, stop go routine
-			if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+
+			if errors.Is(err, io.EOF) {
+				// Assume a clean shutdown when the stream was closed by the client.
+				if !c.closedByServer(stream) {
+					return
+				}
+
+				c.log.Info("subscribe stream closed by server, reopening stream", "client_id", c.ClientID())
+				c.signalDown()
 				return
 			}
 
 			// Otherwise log the error and send a reconnect signal before shutting down.
-			// TODO: configure logging for go sdk
-			// log.Debug().Err(err).Msg("could not recv message from subscribe stream, attempting reconnect")
-			c.down <- struct{}{}
+			c.log.Debug("could not recv message from subscribe stream, attempting reconnect", "client_id", c.ClientID(), "error", err)
+			c.signalDown()
 			return
 		}
 
 		// Handle the message from the server
 		switch msg := in.Embed.(type) {
 		case *api.SubscribeReply_Event:
-			c.events <- msg.Event
+			c.deliver(msg.Event)
 		case *api.SubscribeReply_CloseStream:
-			// TODO: handle close stream and logging for close stream
-			// stats := msg.CloseStream
-			// log.Debug().Uint64("n_events", stats.Events).Uint64("n_topics", stats.Topics).Uint64("n_consumers", stats.Consumers).Msg("subscribe stream closed")
+			stats := msg.CloseStream
+			c.log.Debug("subscribe stream closed", "client_id", c.ClientID(), "n_events", stats.Events, "n_topics", stats.Topics, "n_acks", stats.Acks, "n_nacks", stats.Nacks)
 		default:
-			// TODO: configure logging for go sdk
-			// log.Debug().Type("subscriber_reply", in.Embed).Msg("unhandled subscribe stream message from server: ignoring")
+			c.log.Debug("unhandled subscribe stream message from server: ignoring", "client_id", c.ClientID(), "subscriber_reply", fmt.Sprintf("%T", in.Embed))
+		}
+	}
+}
+
+// Signals the start go routine that the stream is down unless it has stopped.
+func (c *Subscriber) signalDown() {
+	select {
+	case c.down <- struct{}{}:
+	case <-c.done:
+	}
+}
+
+// Returns true if the stream ended without being closed by Close or replaced by
+// Resubscribe, in which case the server closed the stream.
+func (c *Subscriber) closedByServer(stream api.Ensign_SubscribeClient) bool {
+	c.smu.RLock()
+	defer c.smu.RUnlock()
+	return !c.closing && c.stream == stream
+}
+
+// Send the event on the events channel, handling a full channel using the overflow
+// policy. When spilling, events are spilled to disk while the spool is not empty so
+// that events are delivered to the caller in the order they were received.
+func (c *Subscriber) deliver(event *api.EventWrapper) {
+	switch c.overflow {
+	case OverflowDropNack:
+		select {
+		case c.events <- event:
+		default:
+			c.log.Warn("events buffer is full, dropping event", "client_id", c.ClientID(), "event_id", fmt.Sprintf("%x", event.Id))
+			c.Nack(&api.Nack{Id: event.Id, Code: api.Nack_DELIVER_AGAIN_ANY, Error: ErrOverflow.Error()})
+		}
+
+	case OverflowSpill:
+		if c.spool.Len() == 0 {
+			select {
+			case c.events <- event:
+				return
+			default:
+			}
+		}
+
+		// If the event cannot be spilled nack it so the server can redeliver it.
+		if err := c.spool.Push(event); err != nil {
+			c.log.Error("could not spill event to disk, dropping event", "client_id", c.ClientID(), "event_id", fmt.Sprintf("%x", event.Id), "error", err)
+			c.Nack(&api.Nack{Id: event.Id, Code: api.Nack_DELIVER_AGAIN_ANY, Error: err.Error()})
+		}
+
+	default:
+		// Events received after the subscriber stops are not delivered so that Close
+		// does not wait on a caller that is no longer consuming events.
+		select {
+		case c.events <- event:
+		case <-c.done:
+		}
+	}
+}
+
+// The drain go routine sends the events spilled to disk on the events channel in order
+// as the caller consumes events, until the subscriber is closed. If the spilled events
+// cannot be read the fatal error is set and the subscriber is stopped.
+func (c *Subscriber) drain() {
+	defer close(c.drained)
+	for {
+		event, size, err := c.spool.Peek()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				c.log.Error("could not read spilled event from disk", "client_id", c.ClientID(), "error", err)
+				c.setFatal(err)
+				close(c.failed)
+				return
+			}
+
+			// Wait for an event to be spilled
+			select {
+			case <-c.spool.notify:
+				continue
+			case <-c.quit:
+				return
+			}
+		}
+
+		select {
+		case c.events <- event:
+			if err = c.spool.Pop(size); err != nil {
+				c.log.Error("could not remove spilled event from disk", "client_id", c.ClientID(), "error", err)
+				c.setFatal(err)
+				close(c.failed)
+				return
+			}
+		case <-c.quit:
+			return
 		}
 	}
 }
@@ -281,4 +582,5 @@ func (c *Subscriber) setFatal(err error) {
 	c.fmu.Lock()
 	c.fatal = err
 	c.fmu.Unlock()
+	c.onConn.notify(Fatal, SourceSubscriber, c.Info(), err)
 }
@@ -1,17 +1,23 @@
 package stream_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
 	"github.com/rotationalio/go-ensign/mock"
 	"github.com/rotationalio/go-ensign/stream"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type subscriberTestSuite struct {
@@ -66,6 +72,31 @@ func (s *subscriberTestSuite) TestSubscriberTopics() {
 	require.NoError(sub.Close())
 }
 
+func (s *subscriberTestSuite) TestSubscriberClientID() {
+	// Capture the client ID sent by the subscriber when the stream is opened.
+	clientIDs := make(chan string, 1)
+	handler := mock.NewSubscribeHandler()
+	handler.OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+		clientIDs <- in.ClientId
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock"}, nil
+	}
+	s.mock.server.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	require := s.Require()
+	_, sub, err := stream.NewSubscriberWithOptions(s.mock, []string{"testing.123"}, stream.WithClientID("billing"))
+	require.NoError(err, "could not connect to subscriber")
+
+	clientID := <-clientIDs
+	require.Equal(clientID, sub.ClientID())
+	require.Regexp(`^billing-[0-9A-HJKMNP-TV-Z]{26}$`, clientID)
+
+	instanceID, err := ulid.Parse(strings.TrimPrefix(clientID, "billing-"))
+	require.NoError(err, "expected client id to be suffixed with an instance ulid")
+	require.NotEqual(ulid.ULID{}, instanceID)
+	require.NoError(sub.Close())
+}
+
 func (s *subscriberTestSuite) TestSubscriberBadSubscription() {
 	// When the stream is opened, send a topic map back.
 	fixture := map[string]ulid.ULID{
@@ -171,3 +202,355 @@ func (s *subscriberTestSuite) TestSubscriberAcksNacks() {
 func (s *subscriberTestSuite) TestSubscriberReconnect() {
 	s.T().Skip("TODO: implement subscriber reconnect test")
 }
+
+func (s *subscriberTestSuite) TestSubscriberReconnectBackoff() {
+	// Open the stream then fail it so that the subscriber attempts to reconnect.
+	fail := make(chan struct{})
+	s.mock.server.OnSubscribe = func(stream api.Ensign_SubscribeServer) error {
+		in, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		ready := &api.StreamReady{ClientId: in.GetSubscription().ClientId, ServerId: "mock"}
+		if err = stream.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_Ready{Ready: ready}}); err != nil {
+			return err
+		}
+
+		<-fail
+		return status.Error(codes.Unavailable, "node is going down")
+	}
+
+	// The backoff policy of the stream stops after two retries.
+	observer := &BackoffObserver{MockConnectionObserver: s.mock}
+	policy := func() backoff.Backoff {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = time.Millisecond
+		b.MaxRetries = 2
+		b.Reset()
+		return b
+	}
+
+	require := s.Require()
+	_, sub, err := stream.NewSubscriberWithOptions(observer, []string{"testing.123"}, stream.WithBackoff(policy))
+	require.NoError(err, "could not connect to subscriber")
+
+	close(fail)
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		require.Fail("expected subscriber to stop when the backoff stopped")
+	}
+
+	require.ErrorIs(sub.Err(), stream.ErrReconnect)
+	require.Equal(int32(1), atomic.LoadInt32(&observer.calls), "expected the stream backoff to be used")
+	require.Equal(int32(2), atomic.LoadInt32(&observer.retries))
+}
+
+func (s *subscriberTestSuite) TestSubscriberOverflowDropNack() {
+	nacks := make(chan *api.Nack, 8)
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	s.mock.server.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	require := s.Require()
+	logger := &RecordingLogger{}
+	events, sub, err := stream.NewSubscriberWithOptions(s.mock, []string{"testing.123"}, stream.WithBufferSize(2), stream.WithOverflowPolicy(stream.OverflowDropNack), stream.WithLogger(logger))
+	require.NoError(err, "could not connect to subscriber")
+
+	// Send more events than the buffer can hold without consuming any
+	sent := make([]*api.EventWrapper, 0, 5)
+	for i := 0; i < 5; i++ {
+		event := mock.NewEventWrapper()
+		sent = append(sent, event)
+		handler.Send <- event
+	}
+
+	// The events that overflowed the buffer should be nacked
+	for i := 2; i < 5; i++ {
+		select {
+		case nack := <-nacks:
+			require.Equal(sent[i].Id, nack.Id)
+			require.Equal(api.Nack_DELIVER_AGAIN_ANY, nack.Code)
+		case <-time.After(time.Second):
+			require.Fail("expected overflowed event to be nacked")
+		}
+	}
+
+	// The dropped events should be reported to the logger
+	require.Len(logger.Messages("warn"), 3, "expected dropped events to be logged")
+
+	// The buffered events should be delivered
+	for i := 0; i < 2; i++ {
+		require.Equal(sent[i].Id, (<-events).Id)
+	}
+	require.NoError(sub.Close())
+}
+
+func (s *subscriberTestSuite) TestSubscriberOverflowSpill() {
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		s.Fail("no events should be nacked when spilling to disk")
+		return nil
+	}
+	s.mock.server.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	require := s.Require()
+	dir := s.T().TempDir()
+	events, sub, err := stream.NewSubscriberWithOptions(s.mock, []string{"testing.123"}, stream.WithBufferSize(2), stream.WithOverflowPolicy(stream.OverflowSpill), stream.WithSpillDir(dir))
+	require.NoError(err, "could not connect to subscriber")
+
+	// Send more events than the buffer can hold without consuming any
+	nEvents := 20
+	sent := make([]*api.EventWrapper, 0, nEvents)
+	for i := 0; i < nEvents; i++ {
+		event := mock.NewEventWrapper()
+		sent = append(sent, event)
+		handler.Send <- event
+	}
+
+	// Wait for the events to be spilled to disk
+	time.Sleep(50 * time.Millisecond)
+	files, err := os.ReadDir(dir)
+	require.NoError(err)
+	require.Len(files, 1, "expected a spill file to be created")
+
+	// All events should be delivered in order
+	for i := 0; i < nEvents; i++ {
+		select {
+		case event := <-events:
+			require.Equal(sent[i].Id, event.Id, "expected event %d to be delivered in order", i)
+			require.True(proto.Equal(sent[i], event))
+		case <-time.After(time.Second):
+			require.Fail("expected spilled event to be delivered")
+		}
+	}
+
+	// The spill file should be removed when the subscriber is closed
+	require.NoError(sub.Close())
+	files, err = os.ReadDir(dir)
+	require.NoError(err)
+	require.Len(files, 0, "expected spill file to be removed")
+}
+
+func (s *subscriberTestSuite) TestSubscriberSpillFailure() {
+	handler := mock.NewSubscribeHandler()
+	s.mock.server.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	require := s.Require()
+	dir := s.T().TempDir()
+	events, sub, err := stream.NewSubscriberWithOptions(s.mock, []string{"testing.123"}, stream.WithBufferSize(2), stream.WithOverflowPolicy(stream.OverflowSpill), stream.WithSpillDir(dir))
+	require.NoError(err, "could not connect to subscriber")
+
+	// Send more events than the buffer can hold without consuming any
+	for i := 0; i < 10; i++ {
+		handler.Send <- mock.NewEventWrapper()
+	}
+
+	// Wait for the events to be spilled to disk, then corrupt the spill file
+	var path string
+	require.Eventually(func() bool {
+		files, err := os.ReadDir(dir)
+		if err != nil || len(files) != 1 {
+			return false
+		}
+
+		path = filepath.Join(dir, files[0].Name())
+		info, err := os.Stat(path)
+		return err == nil && info.Size() > 0
+	}, time.Second, 10*time.Millisecond, "expected events to be spilled to disk")
+
+	info, err := os.Stat(path)
+	require.NoError(err)
+	garbage := make([]byte, info.Size())
+	for i := range garbage {
+		garbage[i] = 0xff
+	}
+	require.NoError(os.WriteFile(path, garbage, 0600))
+
+	// Consume the buffered events so that the spilled events are read from disk
+	go func() {
+		for range events {
+		}
+	}()
+
+	// The subscriber should stop when the spilled events cannot be read
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		require.Fail("expected the subscriber to stop when the spool cannot be read")
+	}
+	require.Error(sub.Err(), "expected a fatal error to be set")
+	require.NoError(sub.Close())
+}
+
+func TestSubscriberInMemory(t *testing.T) {
+	// The subscriber can be tested without gRPC using in-memory streams
+	acks := make(chan *api.Ack, 3)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	defer handler.Shutdown()
+
+	streams := mock.NewStreams()
+	streams.OnSubscribe = handler.OnSubscribe
+
+	require := require.New(t)
+	C, sub, err := stream.NewSubscriber(streams, []string{"testing.123"})
+	require.NoError(err, "could not connect to subscriber")
+
+	sent := make([]*api.EventWrapper, 0, 3)
+	for i := 0; i < 3; i++ {
+		event := mock.NewEventWrapper()
+		sent = append(sent, event)
+		handler.Send <- event
+	}
+
+	for i := 0; i < 3; i++ {
+		event := <-C
+		require.True(proto.Equal(sent[i], event), "expected events to be received in order")
+		require.NotSame(sent[i], event, "expected event to be copied")
+		require.NoError(sub.Ack(&api.Ack{Id: event.Id}))
+		require.Equal(event.Id, (<-acks).Id)
+	}
+
+	require.NoError(sub.Close())
+	require.Equal(1, streams.Calls[mock.SubscribeRPC])
+}
+
+func TestSubscriberServerClose(t *testing.T) {
+	handler := mock.NewSubscribeHandler()
+	defer handler.Shutdown()
+
+	// The first stream is closed by the server once it is ready
+	streams := mock.NewStreams()
+	streams.OnSubscribe = func(srv api.Ensign_SubscribeServer) error {
+		streams.RLock()
+		calls := streams.Calls[mock.SubscribeRPC]
+		streams.RUnlock()
+
+		if calls > 1 {
+			return handler.OnSubscribe(srv)
+		}
+
+		if _, err := srv.Recv(); err != nil {
+			return err
+		}
+
+		if err := srv.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_Ready{Ready: &api.StreamReady{ServerId: "alpha"}}}); err != nil {
+			return err
+		}
+		return srv.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_CloseStream{CloseStream: &api.CloseStream{}}})
+	}
+
+	require := require.New(t)
+	C, sub, err := stream.NewSubscriber(streams, []string{"testing.123"})
+	require.NoError(err, "could not connect to subscriber")
+
+	// Events are received on the reopened stream
+	event := mock.NewEventWrapper()
+	handler.Send <- event
+	require.True(proto.Equal(event, <-C), "expected event to be received on the reopened stream")
+	require.Equal("mock", sub.Info().ServerID)
+
+	// Closing the subscriber does not reopen the stream
+	require.NoError(sub.Close())
+	require.NoError(sub.Err())
+	require.Equal(2, streams.Calls[mock.SubscribeRPC])
+}
+
+func TestSubscriberConnectionHook(t *testing.T) {
+	handler := mock.NewSubscribeHandler()
+	defer handler.Shutdown()
+
+	// The first stream fails and the second stream is handled by the mock
+	streams := mock.NewStreams()
+	streams.OnSubscribe = func(srv api.Ensign_SubscribeServer) error {
+		streams.RLock()
+		calls := streams.Calls[mock.SubscribeRPC]
+		streams.RUnlock()
+
+		if calls > 1 {
+			return handler.OnSubscribe(srv)
+		}
+
+		if _, err := srv.Recv(); err != nil {
+			return err
+		}
+
+		if err := srv.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_Ready{Ready: &api.StreamReady{ServerId: "alpha"}}}); err != nil {
+			return err
+		}
+		return status.Error(codes.Unavailable, "node is shutting down")
+	}
+
+	require := require.New(t)
+	recorder := &connectionRecorder{}
+	_, sub, err := stream.NewSubscriberWithOptions(streams, []string{"testing.123"}, stream.WithConnectionHook(recorder.Hook))
+	require.NoError(err, "could not connect to subscriber")
+
+	require.Eventually(func() bool {
+		return len(recorder.Events()) == 3
+	}, time.Second, 10*time.Millisecond, "expected the stream to be reconnected")
+
+	// Closing the subscriber is not reported as a connection event
+	require.NoError(sub.Close())
+	require.Equal([]stream.ConnectionState{stream.Connected, stream.Reconnecting, stream.Reconnected}, recorder.States())
+
+	events := recorder.Events()
+	require.Equal(stream.SourceSubscriber, events[0].Source)
+	require.Equal("alpha", events[0].Info.ServerID)
+	require.Equal("mock", events[2].Info.ServerID)
+}
+
+func TestSubscriberResubscribe(t *testing.T) {
+	// Each stream is handled by its own handler so that events are sent on the new stream
+	subs := make(chan *api.Subscription, 2)
+	handlers := make([]*mock.SubscribeHandler, 2)
+	for i := range handlers {
+		handlers[i] = mock.NewSubscribeHandler()
+		handlers[i].OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+			subs <- in
+			return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock"}, nil
+		}
+		defer handlers[i].Shutdown()
+	}
+
+	streams := mock.NewStreams()
+	streams.OnSubscribe = func(srv api.Ensign_SubscribeServer) error {
+		streams.RLock()
+		calls := streams.Calls[mock.SubscribeRPC]
+		streams.RUnlock()
+		return handlers[calls-1].OnSubscribe(srv)
+	}
+
+	require := require.New(t)
+	C, sub, err := stream.NewSubscriber(streams, []string{"testing.123"})
+	require.NoError(err, "could not connect to subscriber")
+	require.Nil((<-subs).Group)
+
+	// The stream is reopened with the updated subscription
+	err = sub.Resubscribe(func(in *api.Subscription) {
+		in.Group = &api.ConsumerGroup{TopicOffsets: map[string]uint64{"testing.123": 42}}
+	})
+	require.NoError(err, "could not resubscribe")
+	require.Equal(map[string]uint64{"testing.123": 42}, (<-subs).Group.TopicOffsets)
+	require.Equal(uint64(1), sub.Info().Reconnects)
+
+	event := mock.NewEventWrapper()
+	handlers[1].Send <- event
+	require.True(proto.Equal(event, <-C), "expected event to be received after resubscribing")
+
+	// The subscriber cannot be resubscribed once it is closed
+	require.NoError(sub.Close())
+	require.ErrorIs(sub.Resubscribe(func(*api.Subscription) {}), stream.ErrSubscriberClosed)
+	require.Equal(2, streams.Calls[mock.SubscribeRPC])
+}
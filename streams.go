@@ -0,0 +1,80 @@
+package ensign
+
+import (
+	"fmt"
+	"sync"
+)
+
+// streams accounts for the publish and subscribe streams opened by a client and its
+// clones (see WithCallOptions), which share a connection to Ensign. If max is greater
+// than zero, no more than max streams may be open at the same time. Subscriptions are
+// tracked until they terminate so that they can be closed when the client shuts down.
+type streams struct {
+	sync.Mutex
+	max    int
+	active int
+	subs   map[*Subscription]struct{}
+}
+
+// Reserve a stream, returning ErrTooManyStreams if the maximum would be exceeded.
+func (s *streams) acquire() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.max > 0 && s.active >= s.max {
+		return fmt.Errorf("%w: %d of %d streams are open", ErrTooManyStreams, s.active, s.max)
+	}
+	s.active++
+	return nil
+}
+
+// Release a stream reserved with acquire when the stream is closed or fails to open.
+func (s *streams) release() {
+	s.Lock()
+	defer s.Unlock()
+	if s.active > 0 {
+		s.active--
+	}
+}
+
+// Track the subscription until it terminates.
+func (s *streams) track(sub *Subscription) {
+	s.Lock()
+	defer s.Unlock()
+	if s.subs == nil {
+		s.subs = make(map[*Subscription]struct{})
+	}
+	s.subs[sub] = struct{}{}
+}
+
+func (s *streams) untrack(sub *Subscription) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.subs, sub)
+}
+
+// Returns the subscriptions that have not terminated.
+func (s *streams) subscriptions() []*Subscription {
+	s.Lock()
+	defer s.Unlock()
+	subs := make([]*Subscription, 0, len(s.subs))
+	for sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (s *streams) count() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.active
+}
+
+// ActiveStreams returns the number of publish and subscribe streams that are currently
+// open on the connection of the client, including the streams opened by clones created
+// with WithCallOptions. The publish stream of a client is counted from the first
+// publish until the client is closed and each subscription is counted until it is
+// closed or terminates. Streams opened directly with PublishStream or SubscribeStream
+// are not counted.
+func (c *Client) ActiveStreams() int {
+	return c.streams.count()
+}
@@ -2,9 +2,15 @@ package ensign
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"time"
 
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/auth"
+	"github.com/rotationalio/go-ensign/schemas"
 	"github.com/rotationalio/go-ensign/stream"
+	"github.com/rotationalio/go-ensign/trace"
 	"google.golang.org/grpc"
 )
 
@@ -14,26 +20,132 @@ import (
 // responsibility to Ack and Nack events when they are handled by using the methods on
 // the event itself.
 type Subscription struct {
-	C      <-chan *Event
-	events <-chan *api.EventWrapper
-	stream *stream.Subscriber
+	C         <-chan *Event
+	events    <-chan *api.EventWrapper
+	stream    *stream.Subscriber
+	acks      Acknowledger
+	opts      SubscribeOptions
+	positions positions
+	log       Logger
+	schemas   *schemas.Registry
+	dlq       *deadLetter
+	throttle  *throttle
+
+	tracer     trace.Tracer
+	propagator trace.Propagator
+
+	done    chan struct{}
+	emu     sync.RWMutex
+	err     error
+	release func()
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // Subscribe creates a subscription stream to the specified topics and returns a
-// Subscription with a channel that can be listened on for incoming events. If the
-// client cannot connect to Ensign or a subscription stream cannot be established, an
-// error is returned.
+// Subscription with a channel that can be listened on for incoming events. Topic names
+// are resolved to topic IDs before the stream is opened; if a topic does not exist then
+// ErrTopicNameNotFound is returned. If the client cannot connect to Ensign or a
+// subscription stream cannot be established, an error is returned. If the client is in read-only mode, the subscription is opened in
+// inspect mode and acking or nacking events returns ErrReadOnlyClient.
 func (c *Client) Subscribe(topics ...string) (sub *Subscription, err error) {
+	return c.CreateSubscriber(topics)
+}
+
+// CreateSubscriber creates a subscription stream to the specified topics that is
+// configured by the specified subscribe options, e.g. to handle slow consumers. See
+// Subscribe for more details about the returned Subscription.
+func (c *Client) CreateSubscriber(topics []string, opts ...SubscribeOption) (sub *Subscription, err error) {
+	if err = c.preflight(auth.PermissionSubscriber); err != nil {
+		return nil, err
+	}
+
 	// Create the internal subscription stream
-	sub = &Subscription{}
-	if sub.events, sub.stream, err = stream.NewSubscriber(c, topics, c.copts...); err != nil {
+	sub = &Subscription{
+		opts:       newSubscribeOptions(opts...),
+		log:        c.opts.Logger,
+		schemas:    c.opts.Schemas,
+		tracer:     c.tracer,
+		propagator: c.propagator,
+		done:       make(chan struct{}),
+	}
+
+	// Resolve topic names to topic IDs before the stream is opened so that unknown
+	// topics are reported by name rather than by an opaque stream error.
+	if topics, err = c.resolveTopics(topics, sub.opts.EnsureTopics); err != nil {
+		return nil, err
+	}
+
+	// Resume from the positions stored by the checkpointer if configured.
+	var resume map[string]uint64
+	if sub.opts.Checkpointer != nil {
+		if err = sub.positions.load(sub.opts.Checkpointer, sub.opts.CheckpointGroup); err != nil {
+			return nil, err
+		}
+		resume = sub.positions.offsets()
+	}
+
+	// Reserve the stream until the subscription terminates.
+	if err = c.streams.acquire(); err != nil {
+		return nil, err
+	}
+	sub.release = func() {
+		c.streams.untrack(sub)
+		c.streams.release()
+	}
+
+	// Reconnect using the backoff policy of the subscription or of the client.
+	policy := sub.opts.Backoff
+	if policy == nil {
+		policy = c.opts.Backoff
+	}
+
+	sopts := append(sub.opts.streamOptions(topics, resume),
+		stream.WithCallOptions(c.copts...),
+		stream.WithClientID(c.opts.ClientName),
+		stream.WithLogger(c.opts.Logger),
+		stream.WithReadyHook(c.opts.OnStreamReady),
+		stream.WithConnectionHook(c.opts.OnConnection),
+		stream.WithBackoff(policy),
+	)
+	if sub.events, sub.stream, err = stream.NewSubscriberWithOptions(c, topics, sopts...); err != nil {
+		c.streams.release()
 		return nil, err
 	}
 
-	// Create the user events channel
-	out := make(chan *Event, 1)
+	// Add the topic mapping returned by the server to the topic cache if configured.
+	c.cacheTopics(sub.stream.Topics())
+
+	// Events are acked and nacked via the stream unless in read-only inspect mode.
+	if c.opts.ReadOnly {
+		sub.acks = inspector{}
+	} else {
+		sub.acks = sub.stream
+	}
+
+	// Failed events are moved to the dead letter topic if configured; events cannot be
+	// dead lettered in read-only mode since they cannot be acked or published.
+	if sub.opts.DeadLetterTopic != "" && !c.opts.ReadOnly {
+		sub.dlq = newDeadLetter(c, sub.opts.DeadLetterTopic, sub.opts.MaxNacks)
+	}
+
+	// Throttle the delivery of events to the consumer if limits are configured.
+	sub.throttle = newThrottle(sub.opts.MaxInflight, sub.opts.RateLimit)
+
+	// Create the user events channel; if lag is being monitored the channel is not
+	// buffered so that the time an event waits for the consumer can be measured.
+	var out chan *Event
+	if sub.opts.LagThreshold > 0 {
+		out = make(chan *Event)
+	} else {
+		out = make(chan *Event, 1)
+	}
 	sub.C = out
 
+	// Track the subscription so that it is closed when the client is shut down.
+	c.streams.track(sub)
+
 	// Run the subscription background go routine
 	go sub.eventHandler(out)
 	return sub, nil
@@ -41,25 +153,206 @@ func (c *Client) Subscribe(topics ...string) (sub *Subscription, err error) {
 
 // Close the subscription stream and associated channels, preventing any more events
 // from being received and signaling to handler code that no more events will arrive.
+// Close may be called more than once, e.g. after the client has been shut down.
 func (c *Subscription) Close() error {
-	return c.stream.Close()
+	c.closeOnce.Do(func() {
+		c.closeErr = c.stream.Close()
+	})
+	return c.closeErr
+}
+
+// ClientID returns the client ID that identifies the subscription stream on the server.
+func (c *Subscription) ClientID() string {
+	return c.stream.ClientID()
+}
+
+// Info returns the info sent by the server when the subscription stream was last
+// opened, e.g. the node the stream is connected to and the topics it subscribes to.
+func (c *Subscription) Info() stream.StreamInfo {
+	return c.stream.Info()
+}
+
+// Done returns a channel that is closed when the subscription terminates, either
+// because it was closed or because the stream could not be reconnected. The C channel
+// is always closed before Done so that handler code can wait for either signal.
+func (c *Subscription) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that caused the subscription to terminate or nil if the
+// subscription was closed or is still running. The error is set before the C channel
+// is closed so that it can be checked as soon as a range loop over C exits.
+func (c *Subscription) Err() error {
+	c.emu.RLock()
+	defer c.emu.RUnlock()
+	return c.err
 }
 
 func (c *Subscription) eventHandler(out chan<- *Event) {
-	for wrapper := range c.events {
-		// Convert the event into an API event
-		event := &Event{}
-		if err := event.fromPB(wrapper, subscription); err != nil {
-			// TODO: what to do about the error?
-			panic(err)
+	// Signal to handler code that no more events will arrive once the cause of the
+	// termination has been set; the ordering ensures range loops can retrieve the error.
+	defer func() {
+		c.emu.Lock()
+		c.err = c.stream.Err()
+		c.emu.Unlock()
+		c.release()
+
+		close(out)
+		close(c.done)
+	}()
+
+	for {
+		select {
+		case wrapper, ok := <-c.events:
+			if !ok {
+				return
+			}
+			c.receive(out, wrapper)
+		case <-c.stream.Done():
+			return
 		}
+	}
+}
+
+// Converts the event received from the stream and delivers it to the consumer.
+func (c *Subscription) receive(out chan<- *Event, wrapper *api.EventWrapper) {
+	// Ack and skip events from before the start position of the subscription so that
+	// only events from the requested position onward are delivered to the consumer, and
+	// events that were acked before the subscription was resumed from a checkpoint.
+	if c.opts.beforeStart(wrapper) || c.positions.resumed(wrapper) {
+		c.acks.Ack(&api.Ack{Id: wrapper.Id})
+		return
+	}
+
+	// If the event cannot be unwrapped it cannot be handled by the consumer, so it
+	// is nacked rather than delivered and the error is logged if possible.
+	event := &Event{}
+	if err := event.fromPB(wrapper, subscription); err != nil {
+		if c.log != nil {
+			c.log.Error("could not unwrap event from subscribe stream", "client_id", c.ClientID(), "error", err)
+		}
+		c.acks.Nack(&api.Nack{Id: wrapper.Id, Code: api.Nack_UNPROCESSED, Error: err.Error()})
+		return
+	}
+
+	// Attach the stream to send acks/nacks back, tracking the position of the event
+	// in the topic when it is acked for checkpointing and counting nacks if the event
+	// may be moved to the dead letter topic.
+	tr := &tracker{acks: c.acks, wrapper: wrapper, positions: &c.positions, log: c.log, dlq: c.dlq}
+	event.sub = tr
+
+	// Trace receiving the event, continuing the trace propagated by the publisher.
+	span := c.startReceiveSpan(event)
+	defer span.End()
+
+	// Nack events that cannot be verified so that consumers only receive events from
+	// trusted publishers.
+	if c.opts.VerifyKeys != nil {
+		if err := verifySignature(wrapper, c.opts.VerifyKeys); err != nil {
+			if c.log != nil {
+				c.log.Warn("could not verify signature of received event", "client_id", c.ClientID(), "event_id", event.ID(), "error", err)
+			}
+			event.nack(&api.Nack{Code: api.Nack_UNPROCESSED, Error: err.Error()})
+			return
+		}
+	}
+
+	// Nack events that do not match the schema of their type so they are not delivered.
+	if err := c.validate(event); err != nil {
+		if c.log != nil {
+			c.log.Warn("received event does not match schema", "client_id", c.ClientID(), "event_id", event.ID(), "error", err)
+		}
+
+		code := api.Nack_UNPROCESSED
+		if errors.Is(err, schemas.ErrSchemaNotFound) {
+			code = api.Nack_UNKNOWN_TYPE
+		}
+		event.nack(&api.Nack{Code: code, Error: err.Error()})
+		return
+	}
+
+	// Ack and skip the event if it has expired and expired events are dropped.
+	if c.opts.DropExpired && event.Expired() {
+		event.Ack()
+		return
+	}
+
+	// Wait until the event can be delivered without exceeding the rate or in-flight
+	// limits of the subscription; the in-flight slot is released on ack or nack.
+	if c.throttle != nil {
+		if !c.throttle.wait(c.stream.Done()) {
+			return
+		}
+		tr.inflight = &releaser{throttle: c.throttle}
+	}
+
+	// Ack the event before it is delivered to the consumer if acking on receive,
+	// otherwise nack the event if the consumer does not handle it before the deadline.
+	if c.opts.AckMode == AckOnReceive {
+		event.Ack()
+	} else if c.opts.AckDeadline > 0 {
+		c.expireAck(event, tr)
+	}
+
+	c.deliver(out, event)
+}
+
+// Nacks the event so that it is redelivered if it has not been acked or nacked when the
+// ack deadline of the subscription passes. The timer is kept on the tracker so that it
+// is stopped when the event is acked or nacked; the tracker is only called while the
+// event lock is held, so the timer is set under the event lock.
+func (c *Subscription) expireAck(event *Event, tr *tracker) {
+	event.mu.Lock()
+	defer event.mu.Unlock()
+	tr.deadline = time.AfterFunc(c.opts.AckDeadline, func() {
+		if event.handled() {
+			return
+		}
+
+		if c.log != nil {
+			c.log.Warn("event was not acked before the ack deadline", "client_id", c.ClientID(), "event_id", event.ID(), "deadline", c.opts.AckDeadline)
+		}
+		event.Nack(api.Nack_DELIVER_AGAIN_ANY)
+	})
+}
 
-		// Attach the stream to send acks/nacks back
-		event.sub = c.stream
+// Send the event to the consumer on the events channel. If a lag threshold is set and
+// the consumer does not receive the event before the threshold, the lag hook is called
+// and the event is nacked so that it can be redelivered to another consumer if the
+// subscription is configured to nack on lag.
+func (c *Subscription) deliver(out chan<- *Event, event *Event) {
+	if c.opts.LagThreshold <= 0 {
 		out <- event
+		return
+	}
+
+	timer := time.NewTimer(c.opts.LagThreshold)
+	defer timer.Stop()
+
+	select {
+	case out <- event:
+		return
+	case <-timer.C:
+	}
+
+	if c.opts.OnLag != nil {
+		c.opts.OnLag(event, c.opts.LagThreshold)
+	}
+
+	if c.opts.NackOnLag {
+		event.Nack(api.Nack_DELIVER_AGAIN_ANY)
+		return
 	}
+	out <- event
 }
 
+// inspector is used as the acknowledger for subscriptions in read-only mode so that
+// events can be consumed without modifying the consumer group offsets.
+type inspector struct{}
+
+func (inspector) Ack(*api.Ack) error   { return ErrReadOnlyClient }
+func (inspector) Nack(*api.Nack) error { return ErrReadOnlyClient }
+
 // SubscribeStream allows you to open a gRPC stream server to ensign for subscribing to
 // API events directly. This manual mechanism of opening a stream is for advanced users
 // and is not recommended in production. Instead using Subscribe or CreateSubscriber is
@@ -0,0 +1,124 @@
+package ensign
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// MultiSubscription manages the lifecycle of a subscription created by SubscribeMany
+// whose events are processed in the background by the handler of the event's topic.
+type MultiSubscription struct {
+	sub   *Subscription
+	once  sync.Once
+	done  chan struct{}
+	err   error
+	close error
+}
+
+// SubscribeMany opens a single subscription to all of the topics in the handlers map
+// and processes the events in the background, routing each event to the handler of its
+// topic. The handlers map is keyed by topic name or topic ID. If a handler returns nil
+// the event is acked and if it returns an error the event is nacked, unless the handler
+// has already acked or nacked the event. Events are processed by a worker pool that is
+// configured by the run options as described by Subscription.Run. The returned
+// MultiSubscription is used to stop processing events and wait for the handlers to
+// finish; the subscription is also closed when the context is canceled.
+func (c *Client) SubscribeMany(ctx context.Context, handlers map[string]EventHandler, opts ...RunOption) (_ *MultiSubscription, err error) {
+	if len(handlers) == 0 {
+		return nil, ErrNoHandlers
+	}
+
+	topics := make([]string, 0, len(handlers))
+	for topic := range handlers {
+		topics = append(topics, topic)
+	}
+
+	var sub *Subscription
+	if sub, err = c.CreateSubscriber(topics, WithAckMode(AckOnHandlerSuccess)); err != nil {
+		return nil, err
+	}
+
+	// Resolve the topic names of the handlers using the topic map from the server.
+	var routes map[ulid.ULID]EventHandler
+	if routes, err = routeTopics(handlers, sub.stream.Topics()); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	router := func(event *Event) error {
+		topicID, err := event.TopicULID()
+		if err != nil {
+			return err
+		}
+
+		handler, ok := routes[topicID]
+		if !ok {
+			event.Nack(api.Nack_TOPIC_UNKNOWN)
+			return fmt.Errorf("no handler for topic %s", topicID)
+		}
+
+		return handler(event)
+	}
+
+	multi := &MultiSubscription{sub: sub, done: make(chan struct{})}
+	go func() {
+		defer close(multi.done)
+		multi.err = sub.Run(ctx, router, opts...)
+		multi.stop()
+	}()
+	return multi, nil
+}
+
+// Close the subscription and wait for the handlers to finish processing their events.
+func (m *MultiSubscription) Close() error {
+	m.stop()
+	<-m.done
+	return m.close
+}
+
+// Wait blocks until the subscription is closed or the context passed to SubscribeMany
+// is canceled and the handlers have finished, returning the context error if any. If
+// the subscription terminates because of a stream error, that error is returned.
+func (m *MultiSubscription) Wait() error {
+	<-m.done
+	return m.err
+}
+
+// Done returns a channel that is closed when the handlers have stopped processing.
+func (m *MultiSubscription) Done() <-chan struct{} {
+	return m.done
+}
+
+// ClientID returns the client ID that identifies the subscription stream on the server.
+func (m *MultiSubscription) ClientID() string {
+	return m.sub.ClientID()
+}
+
+// Close the underlying subscription exactly once.
+func (m *MultiSubscription) stop() {
+	m.once.Do(func() {
+		m.close = m.sub.Close()
+	})
+}
+
+// Map the handlers to the topic IDs of their topics so that events can be routed by
+// the topic ID in the event. Handlers may be keyed by topic ID or by topic name, in
+// which case the name is resolved using the topic map returned by the server.
+func routeTopics(handlers map[string]EventHandler, topics map[string]ulid.ULID) (_ map[ulid.ULID]EventHandler, err error) {
+	routes := make(map[ulid.ULID]EventHandler, len(handlers))
+	for topic, handler := range handlers {
+		var topicID ulid.ULID
+		if topicID, err = ulid.Parse(topic); err != nil {
+			var ok bool
+			if topicID, ok = topics[topic]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrTopicNameNotFound, topic)
+			}
+		}
+		routes[topicID] = handler
+	}
+	return routes, nil
+}
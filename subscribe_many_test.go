@@ -0,0 +1,133 @@
+package ensign_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeMany(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	topics := map[string]ulid.ULID{
+		"testing.topics.topica": ulid.MustParse("01GWM89049D49FHJH81BT8795H"),
+		"testing.topics.topicb": ulid.MustParse("01GWM936SNSN36JKTMSF9Q3N8B"),
+		"testing.topics.topicc": ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ"),
+	}
+
+	// The server returns the topic map of the project regardless of how the topics are
+	// specified in the subscription.
+	ready := func(in *api.Subscription) (*api.StreamReady, error) {
+		out := &api.StreamReady{ClientId: in.ClientId, ServerId: "mock", Topics: make(map[string][]byte)}
+		for name, topicID := range topics {
+			out.Topics[name] = topicID.Bytes()
+		}
+		return out, nil
+	}
+
+	var acks, nacks sync.WaitGroup
+	handler := mock.NewSubscribeHandler()
+	handler.OnInitialize = ready
+	handler.OnAck = func(*api.Ack) error {
+		acks.Done()
+		return nil
+	}
+	handler.OnNack = func(in *api.Nack) error {
+		defer nacks.Done()
+		require.Equal(t, api.Nack_UNPROCESSED, in.Code)
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	// An empty handlers map should return an error
+	_, err = client.SubscribeMany(context.Background(), nil)
+	require.ErrorIs(t, err, sdk.ErrNoHandlers)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]int)
+	)
+
+	record := func(name string, err error) sdk.EventHandler {
+		return func(event *sdk.Event) error {
+			mu.Lock()
+			seen[name]++
+			mu.Unlock()
+			return err
+		}
+	}
+
+	handlers := map[string]sdk.EventHandler{
+		"testing.topics.topica":      record("a", nil),
+		"01GWM936SNSN36JKTMSF9Q3N8B": record("b", errors.New("could not handle event")),
+	}
+
+	sub, err := client.SubscribeMany(context.Background(), handlers, sdk.WithWorkers(2))
+	require.NoError(t, err, "could not subscribe to many topics")
+	require.NotEmpty(t, sub.ClientID())
+
+	nEvents := 10
+	acks.Add(nEvents)
+	nacks.Add(nEvents)
+
+	factories := []*mock.EventFactory{
+		{Topic: topics["testing.topics.topica"]},
+		{Topic: topics["testing.topics.topicb"]},
+	}
+
+	for i := 0; i < nEvents; i++ {
+		for _, factory := range factories {
+			handler.Send <- factory.Make()
+		}
+	}
+
+	acks.Wait()
+	nacks.Wait()
+
+	mu.Lock()
+	require.Equal(t, map[string]int{"a": nEvents, "b": nEvents}, seen)
+	mu.Unlock()
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+	require.NoError(t, sub.Wait())
+
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("expected done channel to be closed")
+	}
+
+	// Canceling the context should close the subscription
+	handler = mock.NewSubscribeHandler()
+	handler.OnInitialize = ready
+	emock.OnSubscribe = handler.OnSubscribe
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err = client.SubscribeMany(ctx, map[string]sdk.EventHandler{"testing.topics.topicc": record("c", nil)})
+	require.NoError(t, err, "could not subscribe to many topics")
+
+	handler.Shutdown()
+	cancel()
+
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected subscription to stop when the context is canceled")
+	}
+	require.ErrorIs(t, sub.Wait(), context.Canceled)
+	require.NoError(t, sub.Close())
+}
@@ -0,0 +1,331 @@
+package ensign
+
+import (
+	"time"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
+	"github.com/rotationalio/go-ensign/stream"
+)
+
+// AckMode specifies when the events of a subscription are acked, which determines the
+// delivery guarantees of the consumer.
+type AckMode uint8
+
+const (
+	// AckManual events must be acked or nacked by the consumer (default). Events that
+	// are not acked may be redelivered, providing at-least-once processing as long as
+	// the consumer acks each event only after it has been processed. When using Run,
+	// events are nacked if the handler returns an error without acking the event.
+	AckManual AckMode = iota
+
+	// AckOnReceive events are acked by the subscription as soon as they are received
+	// from the server, before they are delivered to the consumer on the C channel or to
+	// the Run handler. This provides at-most-once processing: events that are received
+	// but not processed (e.g. because the consumer crashes) are not redelivered. Events
+	// cannot be nacked by the consumer or the handler in this mode.
+	AckOnReceive
+
+	// AckOnHandlerSuccess events are acked by Run when the handler returns nil and
+	// nacked when the handler returns an error, unless the handler already acked or
+	// nacked the event. This provides at-least-once processing without the handler
+	// having to ack events. Because events read directly from the C channel have no
+	// handler, this mode behaves like AckManual for the channel-based API.
+	AckOnHandlerSuccess
+)
+
+// SubscribeOption configures a subscription created by CreateSubscriber.
+type SubscribeOption func(o *SubscribeOptions)
+
+// SubscribeOptions configure how events are delivered to the consumer of a subscription.
+type SubscribeOptions struct {
+	// If events wait in the subscription channel for longer than the lag threshold
+	// then the OnLag hook is called and the event is nacked if NackOnLag is true.
+	LagThreshold time.Duration
+	OnLag        func(event *Event, lag time.Duration)
+	NackOnLag    bool
+
+	// Specifies when events are acked; by default events are acked manually. If an ack
+	// deadline is set, events that are not acked or nacked within the deadline of being
+	// received are nacked so that they are redelivered.
+	AckMode     AckMode
+	AckDeadline time.Duration
+
+	// If true, topics that do not exist are created before the subscription is opened.
+	EnsureTopics bool
+
+	// If true, events that have expired (see Event.SetTTL) are acked and skipped.
+	DropExpired bool
+
+	// If not nil, events whose signatures cannot be verified with the secret of the
+	// signing key ID are nacked rather than delivered.
+	VerifyKeys map[string][]byte
+
+	// If a dead letter topic is set, events that are nacked MaxNacks times or that
+	// cause the Run handler to panic are published to the dead letter topic and acked.
+	DeadLetterTopic string
+	MaxNacks        int
+
+	// The size of the buffer of events received from the server and how events are
+	// handled when the buffer is full; see the stream.OverflowPolicy constants.
+	BufferSize int
+	Overflow   stream.OverflowPolicy
+	SpillDir   string
+
+	// The backoff policy used to reconnect the subscription; by default the backoff
+	// policy of the client is used.
+	Backoff backoff.Policy
+
+	// Limits the number of events delivered to the consumer that have not been acked or
+	// nacked and the rate (events per second) that events are delivered.
+	MaxInflight int
+	RateLimit   float64
+
+	// The position to start the subscription from; events before the start offset or
+	// committed before the start time are acked and skipped. If StartOffset is nil, the
+	// server determines where the subscription starts, e.g. from the group's offsets.
+	StartOffset *Position
+	StartTime   time.Time
+
+	// Stores the positions of the consumer group so that the subscription resumes from
+	// the positions saved by a previous subscription of the same group.
+	Checkpointer    Checkpointer
+	CheckpointGroup string
+}
+
+// WithLagThreshold monitors how long events wait in the subscription channel before
+// they are received by the consumer. If an event is not received within the threshold
+// the hook is called with the event and the lag so that slow consumers can be detected
+// before processing latency silently grows. The hook may be nil, e.g. if the lag
+// threshold is only used to nack events with WithNackOnLag.
+func WithLagThreshold(threshold time.Duration, hook func(event *Event, lag time.Duration)) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.LagThreshold = threshold
+		o.OnLag = hook
+	}
+}
+
+// WithNackOnLag nacks events that are not received by the consumer within the lag
+// threshold with the DELIVER_AGAIN_ANY code so that another consumer in the consumer
+// group can process the event. The nacked event is not delivered to the consumer.
+// This option has no effect unless a lag threshold is set with WithLagThreshold.
+func WithNackOnLag() SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.NackOnLag = true
+	}
+}
+
+// WithAckMode specifies when the events of the subscription are acked. See the AckMode
+// constants for the delivery guarantees of each mode.
+func WithAckMode(mode AckMode) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.AckMode = mode
+	}
+}
+
+// WithAckDeadline nacks events with the DELIVER_AGAIN_ANY code if they have not been
+// acked or nacked within the deadline of being received from the server, so that events
+// whose acks are forgotten (e.g. because of a bug in the consumer) are redelivered rather
+// than stalling the consumer group. The deadline includes the time the event waits to be
+// consumed; it has no effect when the subscription acks events on receive.
+func WithAckDeadline(deadline time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.AckDeadline = deadline
+	}
+}
+
+// WithMaxInflight limits the number of events that have been delivered to the consumer
+// but not yet acked or nacked; once the limit is reached, no more events are delivered
+// until an event is acked or nacked, applying backpressure to the stream. This protects
+// downstream systems from bursts of events, e.g. after the stream reconnects. Note that
+// the consumer must ack or nack every event it receives (see WithAckDeadline).
+func WithMaxInflight(n int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.MaxInflight = n
+	}
+}
+
+// WithRateLimit limits the rate that events are delivered to the consumer to the number
+// of events per second, e.g. to avoid overwhelming a downstream API. Events are evenly
+// spaced rather than delivered in bursts, and backpressure is applied to the stream.
+func WithRateLimit(eventsPerSecond float64) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.RateLimit = eventsPerSecond
+	}
+}
+
+// WithEnsureTopics creates any topics of the subscription that do not exist before the
+// subscribe stream is opened, e.g. so that consumers can be started before producers.
+// Topic IDs cannot be created, only topic names.
+func WithEnsureTopics() SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.EnsureTopics = true
+	}
+}
+
+// WithDropExpired acks and skips events whose expiration (set by the publisher using
+// Event.SetTTL or Event.SetExpires) has passed when they are received, so that stale
+// events are never delivered to the consumer. Events without an expiration are always
+// delivered. Expiration is determined using the local clock of the consumer.
+func WithDropExpired() SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DropExpired = true
+	}
+}
+
+// WithVerifySignatures verifies the signatures of received events using the secrets of
+// the publishers, keyed by the signing key ID (see WithSigningKey). Events that are not
+// signed, that are signed by an unknown key, or whose signature does not match are
+// nacked with the UNPROCESSED code and are not delivered to the consumer. Use
+// Event.SignedBy to determine which publisher signed a delivered event.
+func WithVerifySignatures(keys map[string][]byte) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.VerifyKeys = keys
+	}
+}
+
+// WithDeadLetter republishes events to the dead letter topic once they have been nacked
+// maxNacks times by the consumer, or as soon as they cause the Run handler to panic,
+// and then acks the original event so that it is not redelivered. The metadata of the
+// dead lettered event records the reason for the failure, the original topic and event
+// ID, and the number of nacks (see DeadLetterReasonKey). Nacks are counted in memory
+// by the subscription, so redeliveries to other consumers in the group are not counted.
+// If maxNacks is less than 1 events are dead lettered on their first nack.
+func WithDeadLetter(topic string, maxNacks int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetterTopic = topic
+		o.MaxNacks = maxNacks
+	}
+}
+
+// WithBufferSize specifies how many events received from the server are buffered until
+// they are consumed; by default stream.BufferSize events are buffered.
+func WithBufferSize(size int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.BufferSize = size
+	}
+}
+
+// WithOverflowPolicy specifies how events received from the server are handled when the
+// buffer is full because the consumer is slower than the rate events are received. By
+// default the subscription stops receiving events until the consumer catches up
+// (stream.OverflowBlock). Events can instead be nacked so that they are redelivered
+// (stream.OverflowDropNack) or spilled to a temporary file in the spill directory and
+// delivered in order when the consumer catches up (stream.OverflowSpill). If the spill
+// directory is empty then the system temporary directory is used.
+func WithOverflowPolicy(policy stream.OverflowPolicy, spillDir string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Overflow = policy
+		o.SpillDir = spillDir
+	}
+}
+
+// WithReconnectBackoff specifies the backoff policy used to wait for the connection to be
+// re-established when the subscribe stream goes down, overriding the backoff policy of
+// the client (see WithBackoff). The subscription terminates once the policy stops
+// retrying, so a policy that never stops retries forever.
+func WithReconnectBackoff(policy backoff.Policy) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Backoff = policy
+	}
+}
+
+// FromOffset starts the subscription at the specified epoch and offset in each of its
+// topics so that consumers can replay the history of the topics from a known position,
+// e.g. a position recorded by ExportCheckpoint. Events before the position are acked
+// and skipped if the server delivers them.
+func FromOffset(epoch, offset uint64) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.StartOffset = &Position{Epoch: epoch, Offset: offset}
+		o.StartTime = time.Time{}
+	}
+}
+
+// FromBeginning starts the subscription at the first event in each of its topics so
+// that consumers can replay the entire history of the topics.
+func FromBeginning() SubscribeOption {
+	return FromOffset(0, 0)
+}
+
+// FromTimestamp replays the topics of the subscription from the beginning and acks and
+// skips the events that were committed before the timestamp, so that only events that
+// were committed at or after the timestamp are delivered to the consumer.
+func FromTimestamp(ts time.Time) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.StartOffset = &Position{}
+		o.StartTime = ts
+	}
+}
+
+// FromLatest only delivers events that are committed after the subscription is created;
+// any events committed before, e.g. events redelivered from the consumer group's
+// offsets, are acked and skipped. The commit time of events is determined by the
+// server, so the clocks of the consumer and the server should be synchronized.
+func FromLatest() SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.StartOffset = nil
+		o.StartTime = time.Now()
+	}
+}
+
+// WithCheckpointer saves the position of the last acked event in each topic to the
+// checkpointer under the consumer group name and resumes the subscription from the
+// saved positions when it is created, so that a consumer that is restarted continues
+// where it left off rather than relying only on the consumer group state kept by the
+// server. The saved offsets are requested from the server and events at or before the
+// saved positions are acked and skipped if the server redelivers them. Positions only
+// move forward, so events acked out of order that were not acked before the restart
+// may be skipped. Saved positions take precedence over the start position of the
+// subscription (e.g. FromBeginning) for the topics that have a saved position.
+func WithCheckpointer(checkpointer Checkpointer, group string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Checkpointer = checkpointer
+		o.CheckpointGroup = group
+	}
+}
+
+// Returns the stream options used to create the subscriber stream for the topics. The
+// resume offsets are keyed by topic ID and override the start offset of the topic.
+func (o SubscribeOptions) streamOptions(topics []string, resume map[string]uint64) []stream.Option {
+	opts := []stream.Option{
+		stream.WithBufferSize(o.BufferSize),
+		stream.WithOverflowPolicy(o.Overflow),
+		stream.WithSpillDir(o.SpillDir),
+	}
+
+	offsets := make(map[string]uint64, len(topics))
+	for _, topic := range topics {
+		if offset, ok := resume[topic]; ok {
+			offsets[topic] = offset
+		} else if o.StartOffset != nil {
+			offsets[topic] = o.StartOffset.Offset
+		}
+	}
+
+	if len(offsets) > 0 {
+		opts = append(opts, stream.WithOffsets(offsets))
+	}
+	return opts
+}
+
+// Returns true if the event is before the start position of the subscription.
+func (o SubscribeOptions) beforeStart(wrapper *api.EventWrapper) bool {
+	if start := o.StartOffset; start != nil {
+		if wrapper.Epoch < start.Epoch || (wrapper.Epoch == start.Epoch && wrapper.Offset < start.Offset) {
+			return true
+		}
+	}
+
+	if !o.StartTime.IsZero() && wrapper.Committed != nil {
+		return wrapper.Committed.AsTime().Before(o.StartTime)
+	}
+	return false
+}
+
+func newSubscribeOptions(opts ...SubscribeOption) SubscribeOptions {
+	options := SubscribeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
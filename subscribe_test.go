@@ -0,0 +1,608 @@
+package ensign_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/stream"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSubscribeLagThreshold(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	nacks := make(chan *api.Nack, 2)
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	var (
+		mu     sync.Mutex
+		lagged []*sdk.Event
+	)
+
+	sub, err := client.CreateSubscriber(
+		[]string{"testing.topics.topica"},
+		sdk.WithLagThreshold(10*time.Millisecond, func(event *sdk.Event, lag time.Duration) {
+			mu.Lock()
+			lagged = append(lagged, event)
+			mu.Unlock()
+			require.Equal(t, 10*time.Millisecond, lag)
+		}),
+		sdk.WithNackOnLag(),
+	)
+	require.NoError(t, err, "could not create subscriber")
+
+	// Events that are not consumed within the threshold should be nacked
+	handler.Send <- mock.NewEventWrapper()
+	handler.Send <- mock.NewEventWrapper()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case nack := <-nacks:
+			require.Equal(t, api.Nack_DELIVER_AGAIN_ANY, nack.Code)
+		case <-time.After(time.Second):
+			t.Fatal("expected lagging event to be nacked")
+		}
+	}
+
+	mu.Lock()
+	require.Len(t, lagged, 2, "expected the lag hook to be called for each event")
+	mu.Unlock()
+
+	// Events that are consumed within the threshold should be delivered
+	handler.Send <- mock.NewEventWrapper()
+	select {
+	case event := <-sub.C:
+		_, err = event.Ack()
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected event to be delivered")
+	}
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscribeAckOnReceive(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	acks := make(chan *api.Ack, 1)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithAckMode(sdk.AckOnReceive))
+	require.NoError(t, err, "could not create subscriber")
+
+	// The event should be acked before it is consumed
+	handler.Send <- mock.NewEventWrapper()
+	select {
+	case <-acks:
+	case <-time.After(time.Second):
+		t.Fatal("expected event to be acked on receive")
+	}
+
+	// The consumer cannot nack an event that has already been acked
+	event := <-sub.C
+	nacked, err := event.Nack(api.Nack_UNPROCESSED)
+	require.NoError(t, err)
+	require.False(t, nacked, "expected acked event not to be nacked")
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscribeAckOnHandlerSuccess(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	var (
+		mu      sync.Mutex
+		replies sync.WaitGroup
+		acks    int
+		nacks   int
+	)
+
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(*api.Ack) error {
+		defer replies.Done()
+		mu.Lock()
+		acks++
+		mu.Unlock()
+		return nil
+	}
+	handler.OnNack = func(in *api.Nack) error {
+		defer replies.Done()
+		require.Equal(t, api.Nack_UNPROCESSED, in.Code)
+		mu.Lock()
+		nacks++
+		mu.Unlock()
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithAckMode(sdk.AckOnHandlerSuccess))
+	require.NoError(t, err, "could not create subscriber")
+
+	nEvents := 20
+	replies.Add(nEvents)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go sub.Run(ctx, func(event *sdk.Event) error {
+		if offset, _ := event.Offset(); offset%4 == 0 {
+			return errors.New("could not handle event")
+		}
+		return nil
+	})
+
+	factory := &mock.EventFactory{Topic: ulid.MustParse("01GWM89049D49FHJH81BT8795H")}
+	for i := 0; i < nEvents; i++ {
+		handler.Send <- factory.Make()
+	}
+
+	replies.Wait()
+	mu.Lock()
+	require.Equal(t, nEvents/4, nacks)
+	require.Equal(t, nEvents-nEvents/4, acks)
+	mu.Unlock()
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscribeDropExpired(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	acks := make(chan *api.Ack, 1)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithDropExpired())
+	require.NoError(t, err, "could not create subscriber")
+
+	// Creates an event wrapper that expires at the specified time.
+	expiring := func(expires time.Time) *api.EventWrapper {
+		wrapper := mock.NewEventWrapper()
+		event, err := wrapper.Unwrap()
+		require.NoError(t, err)
+		event.Metadata[sdk.ExpiresAtKey] = expires.Format(time.RFC3339Nano)
+		require.NoError(t, wrapper.Wrap(event))
+		return wrapper
+	}
+
+	// The expired event should be acked and not delivered
+	expired := expiring(time.Now().Add(-1 * time.Minute))
+	handler.Send <- expired
+
+	select {
+	case ack := <-acks:
+		require.Equal(t, expired.Id, ack.Id)
+	case <-time.After(time.Second):
+		t.Fatal("expected expired event to be acked")
+	}
+
+	// Unexpired events and events without expiration should be delivered
+	fresh := expiring(time.Now().Add(time.Minute))
+	handler.Send <- fresh
+	handler.Send <- mock.NewEventWrapper()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-sub.C:
+			require.False(t, event.Expired())
+		case <-time.After(time.Second):
+			t.Fatal("expected unexpired event to be delivered")
+		}
+	}
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscribeMalformedEvent(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	nacks := make(chan *api.Nack, 1)
+	handler := mock.NewSubscribeHandler()
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"})
+	require.NoError(t, err, "could not create subscriber")
+	defer sub.Close()
+	defer handler.Shutdown()
+
+	// An event that cannot be unwrapped should be nacked rather than crash the handler
+	malformed := mock.NewEventWrapper()
+	malformed.Event = []byte("not a protocol buffer event")
+	handler.Send <- malformed
+
+	select {
+	case nack := <-nacks:
+		require.Equal(t, malformed.Id, nack.Id)
+		require.Equal(t, api.Nack_UNPROCESSED, nack.Code)
+	case <-time.After(time.Second):
+		t.Fatal("expected malformed event to be nacked")
+	}
+
+	// Subsequent events should still be delivered
+	valid := mock.NewEventWrapper()
+	handler.Send <- valid
+
+	select {
+	case event := <-sub.C:
+		require.Equal(t, valid.LocalId, event.LocalID())
+	case <-time.After(time.Second):
+		t.Fatal("expected valid event to be delivered")
+	}
+}
+
+func TestSubscriptionInfo(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	infos := make(chan stream.StreamInfo, 1)
+	hook := func(info stream.StreamInfo) { infos <- info }
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithStreamReadyHook(hook))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	handler := mock.NewSubscribeHandler()
+	handler.OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock-node"}, nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"})
+	require.NoError(t, err, "could not create subscriber")
+	defer sub.Close()
+	defer handler.Shutdown()
+
+	info := sub.Info()
+	require.Equal(t, sub.ClientID(), info.ClientID)
+	require.Equal(t, "mock-node", info.ServerID)
+
+	select {
+	case hooked := <-infos:
+		require.Equal(t, info, hooked)
+	default:
+		t.Fatal("expected the stream ready hook to be called when the subscription opened")
+	}
+}
+
+func TestSubscriptionDone(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	// Do not retry reconnects so that a dropped stream is a fatal error.
+	stop := backoff.Exponential(time.Millisecond, time.Millisecond, time.Nanosecond)
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithBackoff(stop))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	t.Run("Closed", func(t *testing.T) {
+		handler := mock.NewSubscribeHandler()
+		emock.OnSubscribe = handler.OnSubscribe
+		defer handler.Shutdown()
+
+		sub, err := client.Subscribe("testing.topics.topica")
+		require.NoError(t, err, "could not subscribe")
+
+		select {
+		case <-sub.Done():
+			t.Fatal("expected subscription to be running")
+		default:
+		}
+
+		require.NoError(t, sub.Close())
+		for range sub.C {
+		}
+
+		<-sub.Done()
+		require.NoError(t, sub.Err(), "expected no error when the subscription is closed")
+	})
+
+	t.Run("Fatal", func(t *testing.T) {
+		// Drop the stream with an error after it is initialized
+		emock.OnSubscribe = func(stream api.Ensign_SubscribeServer) error {
+			in, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+
+			ready := &api.StreamReady{ClientId: in.GetSubscription().ClientId, ServerId: "mock"}
+			if err = stream.Send(&api.SubscribeReply{Embed: &api.SubscribeReply_Ready{Ready: ready}}); err != nil {
+				return err
+			}
+			return status.Error(codes.Unavailable, "node is going down")
+		}
+
+		sub, err := client.Subscribe("testing.topics.topica")
+		require.NoError(t, err, "could not subscribe")
+		defer sub.Close()
+
+		// The range loop should terminate with the error available
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range sub.C {
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected events channel to be closed when the stream fails")
+		}
+
+		require.ErrorIs(t, sub.Err(), stream.ErrReconnect)
+		<-sub.Done()
+
+		// Run should return the error that terminated the subscription
+		require.ErrorIs(t, sub.Run(context.Background(), func(*sdk.Event) error { return nil }), stream.ErrReconnect)
+	})
+}
+
+func TestSubscribeStartPosition(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	subscribe := func(opt sdk.SubscribeOption) (*sdk.Subscription, *mock.SubscribeHandler, *api.Subscription, chan *api.Ack) {
+		var subscription *api.Subscription
+		acks := make(chan *api.Ack, 1)
+		handler := mock.NewSubscribeHandler()
+		handler.OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+			subscription = in
+			return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock"}, nil
+		}
+		handler.OnAck = func(in *api.Ack) error {
+			acks <- in
+			return nil
+		}
+		emock.OnSubscribe = handler.OnSubscribe
+
+		sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, opt)
+		require.NoError(t, err, "could not create subscriber")
+		return sub, handler, subscription, acks
+	}
+
+	// Sends the events to the subscription and checks which are delivered or skipped.
+	check := func(sub *sdk.Subscription, handler *mock.SubscribeHandler, acks chan *api.Ack, skipped, delivered *api.EventWrapper) {
+		handler.Send <- skipped
+		select {
+		case ack := <-acks:
+			require.Equal(t, skipped.Id, ack.Id)
+		case <-time.After(time.Second):
+			t.Fatal("expected event before the start position to be acked")
+		}
+
+		handler.Send <- delivered
+		select {
+		case event := <-sub.C:
+			require.Equal(t, delivered.Id, event.Info().Id)
+		case <-time.After(time.Second):
+			t.Fatal("expected event after the start position to be delivered")
+		}
+
+		handler.Shutdown()
+		require.NoError(t, sub.Close())
+	}
+
+	at := func(epoch, offset uint64, committed time.Time) *api.EventWrapper {
+		wrapper := mock.NewEventWrapper()
+		wrapper.Epoch, wrapper.Offset = epoch, offset
+		wrapper.Committed = timestamppb.New(committed)
+		return wrapper
+	}
+
+	// The start offset is requested from the server as the topic offsets of the group
+	sub, handler, subscription, acks := subscribe(sdk.FromOffset(2, 42))
+	require.Equal(t, map[string]uint64{"01GWM89049D49FHJH81BT8795H": 42}, subscription.Group.TopicOffsets)
+	check(sub, handler, acks, at(2, 41, time.Now()), at(2, 42, time.Now()))
+
+	sub, handler, subscription, _ = subscribe(sdk.FromBeginning())
+	require.Equal(t, map[string]uint64{"01GWM89049D49FHJH81BT8795H": 0}, subscription.Group.TopicOffsets)
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+
+	// Events committed before the start time are skipped
+	since := time.Now().Add(-1 * time.Hour)
+	sub, handler, subscription, acks = subscribe(sdk.FromTimestamp(since))
+	require.Equal(t, map[string]uint64{"01GWM89049D49FHJH81BT8795H": 0}, subscription.Group.TopicOffsets)
+	check(sub, handler, acks, at(1, 1, since.Add(-1*time.Minute)), at(1, 2, since.Add(time.Minute)))
+
+	// Only events committed after the subscription is created are delivered
+	sub, handler, subscription, acks = subscribe(sdk.FromLatest())
+	require.Nil(t, subscription.Group, "expected no topic offsets to be requested")
+	check(sub, handler, acks, at(1, 1, time.Now().Add(-1*time.Minute)), at(1, 2, time.Now().Add(time.Minute)))
+}
+
+func TestSubscribeAckDeadline(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	acks := make(chan *api.Ack, 1)
+	nacks := make(chan *api.Nack, 1)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	handler.OnNack = func(in *api.Nack) error {
+		nacks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithAckDeadline(50*time.Millisecond))
+	require.NoError(t, err, "could not create subscriber")
+
+	// Events that are not acked before the deadline are nacked for redelivery
+	forgotten := mock.NewEventWrapper()
+	handler.Send <- forgotten
+	event := <-sub.C
+
+	select {
+	case nack := <-nacks:
+		require.Equal(t, forgotten.Id, nack.Id)
+		require.Equal(t, api.Nack_DELIVER_AGAIN_ANY, nack.Code)
+	case <-time.After(time.Second):
+		t.Fatal("expected event to be nacked after the ack deadline")
+	}
+
+	acked, err := event.Ack()
+	require.NoError(t, err)
+	require.False(t, acked, "expected expired event to already be nacked")
+
+	// Events that are acked before the deadline are not nacked
+	handler.Send <- mock.NewEventWrapper()
+	event = <-sub.C
+	acked, err = event.Ack()
+	require.NoError(t, err)
+	require.True(t, acked)
+	<-acks
+
+	select {
+	case <-nacks:
+		t.Fatal("expected acked event to not be nacked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscribeMaxInflight(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	handler := mock.NewSubscribeHandler()
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithMaxInflight(2))
+	require.NoError(t, err, "could not create subscriber")
+
+	for i := 0; i < 3; i++ {
+		handler.Send <- mock.NewEventWrapper()
+	}
+
+	// Only two events are delivered until one of them is handled
+	first, second := <-sub.C, <-sub.C
+	select {
+	case <-sub.C:
+		t.Fatal("expected no more than two events in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Acking or nacking the same event more than once only releases one slot
+	first.Ack()
+	first.Ack()
+	select {
+	case event := <-sub.C:
+		event.Nack(api.Nack_UNPROCESSED)
+	case <-time.After(time.Second):
+		t.Fatal("expected third event to be delivered after the first was acked")
+	}
+
+	second.Ack()
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
+
+func TestSubscribeRateLimit(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	handler := mock.NewSubscribeHandler()
+	emock.OnSubscribe = handler.OnSubscribe
+
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica"}, sdk.WithRateLimit(20))
+	require.NoError(t, err, "could not create subscriber")
+
+	for i := 0; i < 5; i++ {
+		handler.Send <- mock.NewEventWrapper()
+	}
+
+	// Events are spaced 50ms apart so receiving five events takes at least 200ms
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		select {
+		case event := <-sub.C:
+			event.Ack()
+		case <-time.After(time.Second):
+			t.Fatal("expected rate limited event to be delivered")
+		}
+	}
+	require.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+
+	handler.Shutdown()
+	require.NoError(t, sub.Close())
+}
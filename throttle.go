@@ -0,0 +1,83 @@
+package ensign
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle limits the rate that a subscription delivers events to the consumer and the
+// number of delivered events that have not yet been acked or nacked, protecting
+// downstream systems from bursts of events, e.g. after the stream reconnects. The wait
+// method must only be called from the event handler go routine of the subscription.
+type throttle struct {
+	interval time.Duration
+	next     time.Time
+	inflight chan struct{}
+}
+
+// Returns nil if neither the in-flight nor the rate limit is set.
+func newThrottle(maxInflight int, rate float64) *throttle {
+	if maxInflight <= 0 && rate <= 0 {
+		return nil
+	}
+
+	t := &throttle{}
+	if maxInflight > 0 {
+		t.inflight = make(chan struct{}, maxInflight)
+	}
+
+	if rate > 0 {
+		t.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return t
+}
+
+// Blocks until an event can be delivered, returning false if done is closed first. If
+// true is returned and the in-flight limit is set, release must be called once the
+// event has been acked or nacked.
+func (t *throttle) wait(done <-chan struct{}) bool {
+	if t.inflight != nil {
+		select {
+		case t.inflight <- struct{}{}:
+		case <-done:
+			return false
+		}
+	}
+
+	if t.interval > 0 {
+		now := time.Now()
+		if t.next.After(now) {
+			timer := time.NewTimer(t.next.Sub(now))
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-done:
+				t.release()
+				return false
+			}
+			now = t.next
+		}
+		t.next = now.Add(t.interval)
+	}
+	return true
+}
+
+func (t *throttle) release() {
+	if t.inflight != nil {
+		<-t.inflight
+	}
+}
+
+// releaser releases the in-flight slot of an event exactly once, however many times
+// the event is acked or nacked.
+type releaser struct {
+	once     sync.Once
+	throttle *throttle
+}
+
+func (r *releaser) release() {
+	if r != nil {
+		r.once.Do(r.throttle.release)
+	}
+}
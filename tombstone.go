@@ -0,0 +1,107 @@
+package ensign
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A Tombstone is returned when a topic is archived or destroyed by name and records
+// the state of the topic reported by the server. Destroying a topic is asynchronous; the
+// server marks the topic as deleting and removes its data in the background, use
+// WaitForDestroyed to block until the topic has been completely removed.
+type Tombstone struct {
+	TopicID string
+	Name    string
+	State   api.TopicState
+	client  *Client
+}
+
+// ArchiveTopicByName resolves the topic name to a topic ID using the topic cache of
+// the client (if it has one) and archives the topic, marking it as read-only.
+func (c *Client) ArchiveTopicByName(ctx context.Context, topicName string) (_ *Tombstone, err error) {
+	return c.deleteTopicByName(ctx, topicName, api.TopicMod_ARCHIVE)
+}
+
+// DestroyTopicByName resolves the topic name to a topic ID using the topic cache of
+// the client (if it has one) and destroys the topic, removing it and all of its data.
+// Because destroying a topic is irreversible, the confirm argument must match the topic
+// name, otherwise ErrDestroyNotConfirmed is returned and no request is made. The topic
+// is removed from the topic cache once the server has accepted the request.
+func (c *Client) DestroyTopicByName(ctx context.Context, topicName, confirm string) (_ *Tombstone, err error) {
+	if confirm != topicName {
+		return nil, fmt.Errorf("%w: %q", ErrDestroyNotConfirmed, topicName)
+	}
+
+	var tomb *Tombstone
+	if tomb, err = c.deleteTopicByName(ctx, topicName, api.TopicMod_DESTROY); err != nil {
+		return nil, err
+	}
+
+	if c.opts.TopicCache != nil {
+		c.opts.TopicCache.Delete(topicName)
+	}
+	return tomb, nil
+}
+
+func (c *Client) deleteTopicByName(ctx context.Context, topicName string, op api.TopicMod_Operation) (_ *Tombstone, err error) {
+	if c.opts.ReadOnly {
+		return nil, ErrReadOnlyClient
+	}
+
+	tomb := &Tombstone{Name: topicName, client: c}
+	if tomb.TopicID, err = c.TopicID(ctx, topicName); err != nil {
+		return nil, err
+	}
+
+	req := &api.TopicMod{
+		Id:        tomb.TopicID,
+		Operation: op,
+	}
+
+	var state *api.TopicStatus
+	if state, err = c.api.DeleteTopic(ctx, req, c.copts...); err != nil {
+		return nil, translateError(err)
+	}
+
+	tomb.State = state.State
+	return tomb, nil
+}
+
+// WaitForDestroyed polls the server using the backoff policy of the client until the
+// topic is no longer found. Use a context deadline to limit how long to wait; if the
+// context is done or the backoff stops before the topic is removed, an error wrapping
+// ErrTopicNotDestroyed is returned and the last known state is kept on the tombstone.
+func (t *Tombstone) WaitForDestroyed(ctx context.Context) (err error) {
+	var id ulid.ULID
+	if id, err = ulid.Parse(t.TopicID); err != nil {
+		return err
+	}
+
+	c := t.client
+	ticker := c.opts.backoffPolicy()()
+	for {
+		var info *api.Topic
+		if info, err = c.api.RetrieveTopic(ctx, &api.Topic{Id: id[:]}, c.copts...); err != nil {
+			if serr, ok := status.FromError(err); ok && serr.Code() == codes.NotFound {
+				t.State = api.TopicState_UNDEFINED
+				return nil
+			}
+
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: topic %s is %s: %s", ErrTopicNotDestroyed, t.TopicID, t.State, ctx.Err())
+			}
+			return translateError(err)
+		}
+
+		t.State = info.Status
+		if err = backoff.Wait(ctx, ticker); err != nil {
+			return fmt.Errorf("%w: topic %s is %s: %s", ErrTopicNotDestroyed, t.TopicID, t.State, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package ensign
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Topic is a convenience wrapper around the topic protocol buffers returned by Ensign,
+// e.g. by ListTopics, in the same way that Event wraps api.Event. The identifiers and
+// timestamps of the topic are parsed and the status, deduplication and sharding policies
+// of the topic can be inspected without navigating the protocol buffers. Use Proto to
+// convert the topic back into a protocol buffer.
+type Topic struct {
+	ID        ulid.ULID
+	ProjectID ulid.ULID
+	Name      string
+	Status    api.TopicState
+	Readonly  bool
+	Offset    uint64
+	Shards    uint32
+	Created   time.Time
+	Modified  time.Time
+
+	pb *api.Topic
+}
+
+// NewTopic creates a topic from the protocol buffer returned by Ensign, returning an
+// error if the topic or project ID cannot be parsed. The project ID may be empty.
+func NewTopic(pb *api.Topic) (topic *Topic, err error) {
+	topic = &Topic{
+		Name:     pb.Name,
+		Status:   pb.Status,
+		Readonly: pb.Readonly,
+		Offset:   pb.Offset,
+		Shards:   pb.Shards,
+		pb:       pb,
+	}
+
+	if err = topic.ID.UnmarshalBinary(pb.Id); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTopicID, err)
+	}
+
+	if len(pb.ProjectId) > 0 {
+		if err = topic.ProjectID.UnmarshalBinary(pb.ProjectId); err != nil {
+			return nil, fmt.Errorf("could not parse project id: %w", err)
+		}
+	}
+
+	if pb.Created != nil {
+		topic.Created = pb.Created.AsTime()
+	}
+
+	if pb.Modified != nil {
+		topic.Modified = pb.Modified.AsTime()
+	}
+	return topic, nil
+}
+
+// Proto converts the topic back into a protocol buffer. The deduplication policy,
+// placements and event types of the original protocol buffer are preserved.
+func (t *Topic) Proto() *api.Topic {
+	pb := &api.Topic{}
+	if t.pb != nil {
+		pb = proto.Clone(t.pb).(*api.Topic)
+	}
+
+	pb.Id = t.ID.Bytes()
+	pb.Name = t.Name
+	pb.Status = t.Status
+	pb.Readonly = t.Readonly
+	pb.Offset = t.Offset
+	pb.Shards = t.Shards
+
+	pb.ProjectId = nil
+	if t.ProjectID != (ulid.ULID{}) {
+		pb.ProjectId = t.ProjectID.Bytes()
+	}
+
+	pb.Created, pb.Modified = nil, nil
+	if !t.Created.IsZero() {
+		pb.Created = timestamppb.New(t.Created)
+	}
+
+	if !t.Modified.IsZero() {
+		pb.Modified = timestamppb.New(t.Modified)
+	}
+	return pb
+}
+
+// String returns the name of the topic.
+func (t *Topic) String() string {
+	return t.Name
+}
+
+// Ready returns true if events can be published to and consumed from the topic.
+func (t *Topic) Ready() bool {
+	return t.Status == api.TopicState_READY && !t.Readonly
+}
+
+// Archived returns true if the topic is read-only and events can no longer be published.
+func (t *Topic) Archived() bool {
+	return t.Readonly || t.Status == api.TopicState_READONLY
+}
+
+// Deleting returns true if the topic is being destroyed.
+func (t *Topic) Deleting() bool {
+	return t.Status == api.TopicState_DELETING
+}
+
+// Pending returns true if the topic is still being created or is being modified by the
+// server, e.g. while it is being allocated to nodes or repaired.
+func (t *Topic) Pending() bool {
+	switch t.Status {
+	case api.TopicState_PENDING, api.TopicState_ALLOCATING, api.TopicState_REPAIRING:
+		return true
+	default:
+		return false
+	}
+}
+
+// Deduplication returns the deduplication strategy of the topic and the offset position
+// that duplicates are stored at. If the topic has no deduplication policy, the strategy
+// is api.Deduplication_UNKNOWN.
+func (t *Topic) Deduplication() (api.Deduplication_Strategy, api.Deduplication_OffsetPosition) {
+	dedup := t.deduplication()
+	return dedup.GetStrategy(), dedup.GetOffset()
+}
+
+// DeduplicationKeys returns the metadata keys used to identify duplicates by the
+// KEY_GROUPED and UNIQUE_KEY deduplication strategies.
+func (t *Topic) DeduplicationKeys() []string {
+	return t.deduplication().GetKeys()
+}
+
+// DeduplicationFields returns the data fields used to identify duplicates by the
+// UNIQUE_FIELD deduplication strategy.
+func (t *Topic) DeduplicationFields() []string {
+	return t.deduplication().GetFields()
+}
+
+// OverwriteDuplicates returns true if duplicate events are overwritten by the original.
+func (t *Topic) OverwriteDuplicates() bool {
+	return t.deduplication().GetOverwriteDuplicate()
+}
+
+// Sharding returns the sharding strategy of the current placement of the topic, i.e.
+// the placement with the latest epoch, or api.ShardingStrategy_UNKNOWN if the topic
+// has not been placed.
+func (t *Topic) Sharding() api.ShardingStrategy {
+	return t.placement().GetSharding()
+}
+
+// Regions returns the regions that the topic is currently placed in.
+func (t *Topic) Regions() []region.Region {
+	return t.placement().GetRegions()
+}
+
+// Types returns the event types that have been published to the topic.
+func (t *Topic) Types() []*api.Type {
+	if t.pb == nil {
+		return nil
+	}
+	return t.pb.Types
+}
+
+func (t *Topic) deduplication() *api.Deduplication {
+	if t.pb == nil {
+		return nil
+	}
+	return t.pb.Deduplication
+}
+
+func (t *Topic) placement() *api.Placement {
+	if t.pb == nil || len(t.pb.Placements) == 0 {
+		return nil
+	}
+
+	placements := append([]*api.Placement(nil), t.pb.Placements...)
+	sort.Slice(placements, func(i, j int) bool { return placements[i].Epoch > placements[j].Epoch })
+	return placements[0]
+}
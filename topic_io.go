@@ -0,0 +1,211 @@
+package ensign
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+)
+
+// DefaultChunkSize is the maximum number of bytes in each event published by a
+// TopicWriter unless a different chunk size is specified.
+const DefaultChunkSize = 64 * 1024
+
+// TopicWriter is an io.Writer that publishes the bytes written to it as events to a
+// topic, e.g. so that a log pipeline can stream its output through Ensign. Each write
+// is split into events of at most ChunkSize bytes, which are published in order without
+// waiting for acks; use Flush or Close to wait until the events are acked. Once an event
+// is nacked, the nack error is returned by all subsequent writes. A TopicWriter is safe
+// for concurrent use, though concurrent writes may be interleaved by chunk.
+type TopicWriter struct {
+	// The maximum number of bytes in each event and the mimetype of the events; these
+	// fields must not be modified after the first write.
+	ChunkSize int
+	Mimetype  mimetype.MIME
+
+	mu      sync.Mutex
+	client  *Client
+	topic   string
+	pending []*Event
+	err     error
+	closed  bool
+}
+
+// NewTopicWriter returns a writer that publishes the bytes written to it as events to
+// the specified topic name or ID using the client's publish stream.
+func NewTopicWriter(client *Client, topic string) *TopicWriter {
+	return &TopicWriter{
+		ChunkSize: DefaultChunkSize,
+		Mimetype:  mimetype.ApplicationOctetStream,
+		client:    client,
+		topic:     topic,
+	}
+}
+
+// Write publishes the bytes as one or more events, returning the number of bytes that
+// were published. The bytes are copied so the caller can reuse the slice.
+func (w *TopicWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	w.prune()
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	size := w.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	for n < len(p) {
+		end := n + size
+		if end > len(p) {
+			end = len(p)
+		}
+
+		event := &Event{
+			Data:     append([]byte(nil), p[n:end]...),
+			Mimetype: w.Mimetype,
+		}
+
+		if err = w.client.Publish(w.topic, event); err != nil {
+			w.err = err
+			return n, err
+		}
+
+		w.pending = append(w.pending, event)
+		n = end
+	}
+	return n, nil
+}
+
+// Flush blocks until all of the events published by the writer are acked or until the
+// context is done, returning the error of the first event that was nacked.
+func (w *TopicWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for len(w.pending) > 0 {
+		if _, err := w.pending[0].WaitForAck(ctx); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			if w.err == nil {
+				w.err = err
+			}
+		}
+		w.pending = w.pending[1:]
+	}
+	return w.err
+}
+
+// Close flushes the writer, blocking until all of the events are acked, and prevents
+// further writes. The client is not closed.
+func (w *TopicWriter) Close() error {
+	err := w.Flush(context.Background())
+
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	return err
+}
+
+// Remove the events that have been acked from the front of the pending queue so that
+// the queue does not grow unbounded, recording the error of any nacked event. Must hold
+// the lock.
+func (w *TopicWriter) prune() {
+	for len(w.pending) > 0 {
+		if acked, err := w.pending[0].Acked(); !acked {
+			if err == nil {
+				return
+			}
+
+			if w.err == nil {
+				w.err = err
+			}
+		}
+		w.pending = w.pending[1:]
+	}
+}
+
+// TopicReader is an io.Reader over a subscription to a topic that reads the payloads
+// of the events received as a continuous stream of bytes, e.g. to consume the bytes
+// written by a TopicWriter. Each event is acked once all of its bytes have been read,
+// so events that are only partially read when the reader is closed are redelivered.
+// Read blocks until an event is received; a TopicReader is not safe for concurrent use.
+type TopicReader struct {
+	sub    *Subscription
+	event  *Event
+	buf    []byte
+	closed bool
+}
+
+// NewTopicReader subscribes to the specified topic name or ID and returns a reader
+// over the payloads of the events received by the subscription.
+func NewTopicReader(client *Client, topic string, opts ...SubscribeOption) (_ *TopicReader, err error) {
+	var sub *Subscription
+	if sub, err = client.CreateSubscriber([]string{topic}, opts...); err != nil {
+		return nil, err
+	}
+	return &TopicReader{sub: sub}, nil
+}
+
+// Read reads the bytes of the current event into p, blocking until the next event is
+// received if the current event has been read. If the subscription stops, the error
+// of the subscription is returned or io.EOF if it stopped without an error.
+func (r *TopicReader) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for len(r.buf) == 0 {
+		if err = r.ack(); err != nil {
+			return 0, err
+		}
+
+		event, ok := <-r.sub.C
+		if !ok {
+			if err = r.sub.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+
+		r.event, r.buf = event, event.Data
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	// Ack the event as soon as it has been read so that it is not redelivered.
+	if len(r.buf) == 0 {
+		err = r.ack()
+	}
+	return n, err
+}
+
+// Close the subscription; the event that is currently being read is not acked.
+func (r *TopicReader) Close() error {
+	r.closed = true
+	return r.sub.Close()
+}
+
+// Ack the current event if it has not been acked.
+func (r *TopicReader) ack() (err error) {
+	if r.event != nil {
+		_, err = r.event.Ack()
+		r.event = nil
+	}
+	return err
+}
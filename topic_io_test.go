@@ -0,0 +1,142 @@
+package ensign_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	mimetype "github.com/rotationalio/go-ensign/mimetype/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicWriter(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	recorder := mock.NewPublishRecorder(nil)
+	emock.OnPublish = recorder.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	w := sdk.NewTopicWriter(client, "01GWM89049D49FHJH81BT8795H")
+	w.ChunkSize = 4
+	w.Mimetype = mimetype.TextPlain
+
+	// Writes are chunked into events
+	n, err := w.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.Equal(t, 11, n)
+
+	n, err = w.Write(nil)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = io.WriteString(w, "!\n")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, w.Flush(ctx))
+
+	published := recorder.Published()
+	require.Len(t, published, 4)
+
+	var data []byte
+	for _, pub := range published {
+		require.LessOrEqual(t, len(pub.Event.Data), 4)
+		require.Equal(t, mimetype.TextPlain, pub.Event.Mimetype)
+		data = append(data, pub.Event.Data...)
+	}
+	require.Equal(t, "hello world!\n", string(data))
+
+	// Writes fail after the writer is closed
+	require.NoError(t, w.Close())
+	_, err = w.Write([]byte("closed"))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestTopicWriterNack(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	handler := mock.NewPublishHandler(nil)
+	handler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		return &api.PublisherReply{Embed: &api.PublisherReply_Nack{Nack: &api.Nack{Id: in.LocalId, Code: api.Nack_MAX_EVENT_SIZE_EXCEEDED}}}, nil
+	}
+	emock.OnPublish = handler.OnPublish
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	w := sdk.NewTopicWriter(client, "01GWM89049D49FHJH81BT8795H")
+	_, err = w.Write([]byte("too large"))
+	require.NoError(t, err, "writes do not wait for acks")
+
+	// The nack is returned by flush and subsequent writes
+	var nack *sdk.NackError
+	require.ErrorAs(t, w.Flush(context.Background()), &nack)
+	require.Equal(t, api.Nack_MAX_EVENT_SIZE_EXCEEDED, nack.Code)
+
+	_, err = w.Write([]byte("hello"))
+	require.ErrorAs(t, err, &nack)
+	require.ErrorAs(t, w.Close(), &nack)
+}
+
+func TestTopicReader(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	acks := make(chan *api.Ack, 4)
+	handler := mock.NewSubscribeHandler()
+	handler.OnAck = func(in *api.Ack) error {
+		acks <- in
+		return nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	r, err := sdk.NewTopicReader(client, "testing.topics.topica")
+	require.NoError(t, err, "could not create topic reader")
+
+	for _, data := range []string{"hello ", "", "world"} {
+		env := mock.NewEventWrapper()
+		require.NoError(t, env.Wrap(&api.Event{Data: []byte(data), Mimetype: mimetype.TextPlain}))
+		handler.Send <- env
+	}
+
+	// Events are read as a continuous stream of bytes
+	buf := make([]byte, 4)
+	var out bytes.Buffer
+	for out.Len() < 11 {
+		n, err := r.Read(buf)
+		require.NoError(t, err)
+		out.Write(buf[:n])
+	}
+	require.Equal(t, "hello world", out.String())
+
+	// All events, including the empty event, are acked once they are read
+	for i := 0; i < 3; i++ {
+		select {
+		case <-acks:
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %d to be acked", i)
+		}
+	}
+
+	require.NoError(t, r.Close())
+	_, err = r.Read(buf)
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
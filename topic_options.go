@@ -0,0 +1,89 @@
+package ensign
+
+import (
+	"fmt"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+)
+
+// CreateTopicOption configures the topic created by CreateTopic so that the policies
+// of the topic are set when it is created rather than by follow-up calls such as
+// SetTopicDeduplicationPolicy and SetTopicShardingStrategy.
+type CreateTopicOption func(topic *api.Topic) error
+
+// WithTopicTypes specifies the event types that are expected to be published to the
+// topic when it is created.
+func WithTopicTypes(types ...*api.Type) CreateTopicOption {
+	return func(topic *api.Topic) error {
+		topic.Types = append(topic.Types, types...)
+		return nil
+	}
+}
+
+// WithDeduplication specifies the deduplication policy of the topic; the arguments are
+// the same as for SetTopicDeduplicationPolicy. Keys are required by the KEY_GROUPED and
+// UNIQUE_KEY strategies and fields by the UNIQUE_FIELD strategy.
+func WithDeduplication(policy api.Deduplication_Strategy, offset api.Deduplication_OffsetPosition, keysOrFields []string, overwriteDuplicate bool) CreateTopicOption {
+	return func(topic *api.Topic) (err error) {
+		topic.Deduplication, err = newDeduplication(policy, offset, keysOrFields, overwriteDuplicate)
+		return err
+	}
+}
+
+// WithShardingStrategy specifies the sharding strategy of the topic.
+func WithShardingStrategy(strategy api.ShardingStrategy) CreateTopicOption {
+	return func(topic *api.Topic) error {
+		initialPlacement(topic).Sharding = strategy
+		return nil
+	}
+}
+
+// WithPlacementRegions specifies the regions that the topic should be placed in.
+func WithPlacementRegions(regions ...region.Region) CreateTopicOption {
+	return func(topic *api.Topic) error {
+		placement := initialPlacement(topic)
+		placement.Regions = append(placement.Regions, regions...)
+		return nil
+	}
+}
+
+// Creates the topic request from the topic name and the options.
+func newTopicRequest(name string, opts ...CreateTopicOption) (topic *api.Topic, err error) {
+	topic = &api.Topic{Name: name}
+	for _, opt := range opts {
+		if err = opt(topic); err != nil {
+			return nil, err
+		}
+	}
+	return topic, nil
+}
+
+// Returns the placement that the sharding strategy and regions of a new topic are set on.
+func initialPlacement(topic *api.Topic) *api.Placement {
+	if len(topic.Placements) == 0 {
+		topic.Placements = []*api.Placement{{}}
+	}
+	return topic.Placements[0]
+}
+
+// Creates a deduplication policy, assigning keys or fields depending on the strategy.
+func newDeduplication(policy api.Deduplication_Strategy, offset api.Deduplication_OffsetPosition, keysOrFields []string, overwriteDuplicate bool) (*api.Deduplication, error) {
+	dedup := &api.Deduplication{
+		Strategy:           policy,
+		Offset:             offset,
+		OverwriteDuplicate: overwriteDuplicate,
+	}
+
+	switch policy {
+	case api.Deduplication_KEY_GROUPED, api.Deduplication_UNIQUE_KEY:
+		dedup.Keys = keysOrFields
+	case api.Deduplication_UNIQUE_FIELD:
+		dedup.Fields = keysOrFields
+	default:
+		if len(keysOrFields) > 0 {
+			return nil, fmt.Errorf("%s policy does not support keys or fields", policy)
+		}
+	}
+	return dedup, nil
+}
@@ -0,0 +1,100 @@
+package ensign_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTopic(t *testing.T) {
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	projectID := ulid.MustParse("01GWM8Q1V9Q7ZC7HS0A0SEF8K5")
+	created := time.Date(2023, 4, 12, 14, 21, 3, 0, time.UTC)
+
+	pb := &api.Topic{
+		Id:        topicID.Bytes(),
+		ProjectId: projectID.Bytes(),
+		Name:      "testing.topics.topica",
+		Offset:    42,
+		Shards:    1,
+		Status:    api.TopicState_READY,
+		Deduplication: &api.Deduplication{
+			Strategy:           api.Deduplication_UNIQUE_KEY,
+			Offset:             api.Deduplication_OFFSET_LATEST,
+			Keys:               []string{"order_id"},
+			OverwriteDuplicate: true,
+		},
+		Placements: []*api.Placement{
+			{Epoch: 1, Sharding: api.ShardingStrategy_NO_SHARDING, Regions: []region.Region{region.Region_LKE_US_EAST_1A}},
+			{Epoch: 2, Sharding: api.ShardingStrategy_CONSISTENT_KEY_HASH, Regions: []region.Region{region.Region_LKE_US_WEST_1A}},
+		},
+		Types:    []*api.Type{{Name: "Order", MajorVersion: 1}},
+		Created:  timestamppb.New(created),
+		Modified: timestamppb.New(created.Add(time.Hour)),
+	}
+
+	topic, err := sdk.NewTopic(pb)
+	require.NoError(t, err, "could not create topic")
+	require.Equal(t, topicID, topic.ID)
+	require.Equal(t, projectID, topic.ProjectID)
+	require.Equal(t, "testing.topics.topica", topic.Name)
+	require.Equal(t, "testing.topics.topica", topic.String())
+	require.Equal(t, uint64(42), topic.Offset)
+	require.Equal(t, created, topic.Created)
+	require.Equal(t, created.Add(time.Hour), topic.Modified)
+
+	require.True(t, topic.Ready())
+	require.False(t, topic.Archived())
+	require.False(t, topic.Deleting())
+	require.False(t, topic.Pending())
+
+	strategy, offset := topic.Deduplication()
+	require.Equal(t, api.Deduplication_UNIQUE_KEY, strategy)
+	require.Equal(t, api.Deduplication_OFFSET_LATEST, offset)
+	require.Equal(t, []string{"order_id"}, topic.DeduplicationKeys())
+	require.Empty(t, topic.DeduplicationFields())
+	require.True(t, topic.OverwriteDuplicates())
+
+	require.Equal(t, api.ShardingStrategy_CONSISTENT_KEY_HASH, topic.Sharding(), "expected the sharding strategy of the latest placement")
+	require.Equal(t, []region.Region{region.Region_LKE_US_WEST_1A}, topic.Regions())
+	require.Len(t, topic.Types(), 1)
+
+	// The topic should convert back into an identical protocol buffer.
+	require.True(t, proto.Equal(pb, topic.Proto()), "expected topic to round trip")
+
+	// Changes to the topic are reflected in the protocol buffer.
+	topic.Status = api.TopicState_READONLY
+	require.True(t, topic.Archived())
+	require.False(t, topic.Ready())
+	require.Equal(t, api.TopicState_READONLY, topic.Proto().Status)
+	require.Equal(t, api.TopicState_READY, pb.Status, "the original protocol buffer should not be modified")
+
+	topic.Status = api.TopicState_ALLOCATING
+	require.True(t, topic.Pending())
+
+	topic.Status = api.TopicState_DELETING
+	require.True(t, topic.Deleting())
+
+	// Topics without policies should return zero values.
+	topic, err = sdk.NewTopic(&api.Topic{Id: topicID.Bytes(), Name: "bare"})
+	require.NoError(t, err, "could not create topic without a project")
+	require.Zero(t, topic.ProjectID)
+	require.True(t, topic.Created.IsZero())
+
+	strategy, _ = topic.Deduplication()
+	require.Equal(t, api.Deduplication_UNKNOWN, strategy)
+	require.Equal(t, api.ShardingStrategy_UNKNOWN, topic.Sharding())
+	require.Empty(t, topic.Regions())
+	require.True(t, proto.Equal(&api.Topic{Id: topicID.Bytes(), Name: "bare"}, topic.Proto()))
+
+	// Topic IDs are required.
+	_, err = sdk.NewTopic(&api.Topic{Name: "invalid"})
+	require.ErrorIs(t, err, sdk.ErrInvalidTopicID)
+}
@@ -3,11 +3,16 @@ package ensign
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 
 	"github.com/oklog/ulid/v2"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
+	"github.com/rotationalio/go-ensign/topics"
 	"github.com/spaolacci/murmur3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Check if a topic with the specified name exists in the project or not. The returned
@@ -16,7 +21,7 @@ import (
 func (c *Client) TopicExists(ctx context.Context, topicName string) (_ bool, err error) {
 	var info *api.TopicExistsInfo
 	if info, err = c.api.TopicExists(ctx, &api.TopicName{Name: topicName}, c.copts...); err != nil {
-		return false, err
+		return false, translateError(err)
 	}
 	return info.Exists, nil
 }
@@ -24,56 +29,109 @@ func (c *Client) TopicExists(ctx context.Context, topicName string) (_ bool, err
 // Create topic with the specified name and return the topic ID if there was no error.
 // This method returns a gRPC error if the RPC cannot be successfully completed.
 func (c *Client) CreateTopic(ctx context.Context, topic string) (_ string, err error) {
-	var reply *api.Topic
-	if reply, err = c.api.CreateTopic(ctx, &api.Topic{Name: topic}, c.copts...); err != nil {
-		// TODO: do a better job of categorizing the error
+	return c.CreateTopicWithOptions(ctx, topic)
+}
+
+// CreateTopicWithOptions creates a topic like CreateTopic, specifying the event types,
+// deduplication policy, sharding strategy and placement of the topic with the options
+// so that they do not have to be set after the topic is created.
+func (c *Client) CreateTopicWithOptions(ctx context.Context, topic string, opts ...CreateTopicOption) (_ string, err error) {
+	if c.opts.ReadOnly {
+		return "", ErrReadOnlyClient
+	}
+
+	var req *api.Topic
+	if req, err = newTopicRequest(topic, opts...); err != nil {
 		return "", err
 	}
 
+	var reply *api.Topic
+	if reply, err = c.api.CreateTopic(ctx, req, c.copts...); err != nil {
+		return "", translateError(err)
+	}
+
 	// Convert the topic ID into a ULID string for user consumption.
 	var topicID ulid.ULID
 	if err = topicID.UnmarshalBinary(reply.Id); err != nil {
-		// TODO: do a better job of categorizing the error
-		return "", err
+		return "", fmt.Errorf("%w: %s", ErrInvalidTopicID, err)
 	}
+
+	c.cacheTopic(topic, topicID.String())
 	return topicID.String(), nil
 }
 
-// ListTopics fetches all the topics that the client has access to in the project that
-// the API keys are defined for. The ListTopics RPC is a paginated RPC, and this method
-// continues to fetch all pages before returning a list of a results; fully
-// materializing the list of topics in memory.
-func (c *Client) ListTopics(ctx context.Context) (topics []*api.Topic, err error) {
-	// TODO: return an iterator rather than materializing all of the topics
-	topics = make([]*api.Topic, 0)
-	query := &api.PageInfo{PageSize: DefaultPageSize}
+// CreateTopicAndWait creates a topic with the specified name and then polls the Ensign
+// server until the topic is ready, so that events can be published to the topic as soon
+// as this method returns. The topic is polled using the backoff policy of the client;
+// use a context deadline to limit how long to wait. If the topic is created but is not
+// ready before the context is done or the backoff stops, the topic ID is returned
+// along with an error that wraps ErrTopicNotReady. The options are the same as for
+// CreateTopicWithOptions.
+func (c *Client) CreateTopicAndWait(ctx context.Context, topic string, opts ...CreateTopicOption) (topicID string, err error) {
+	if topicID, err = c.CreateTopicWithOptions(ctx, topic, opts...); err != nil {
+		return "", err
+	}
 
-	// Request all topics pages making each request in succession.
-	var page *api.TopicsPage
-	for page == nil || page.NextPageToken != "" {
-		// If the context is done, stop requesting new pages
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+	if err = c.waitForTopic(ctx, topicID); err != nil {
+		return topicID, err
+	}
+	return topicID, nil
+}
+
+// Polls the server using the backoff policy until the topic is ready. A topic that is
+// not found is assumed to still be propagating to the node the client is connected to.
+func (c *Client) waitForTopic(ctx context.Context, topicID string) (err error) {
+	var id ulid.ULID
+	if id, err = ulid.Parse(topicID); err != nil {
+		return err
+	}
+
+	state := api.TopicState_UNDEFINED
+	ticker := c.opts.backoffPolicy()()
+	for {
+		var info *api.Topic
+		if info, err = c.api.RetrieveTopic(ctx, &api.Topic{Id: id[:]}, c.copts...); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: topic %s is %s: %s", ErrTopicNotReady, topicID, state, ctx.Err())
+			}
+
+			if serr, ok := status.FromError(err); !ok || serr.Code() != codes.NotFound {
+				return translateError(err)
+			}
+		} else if state = info.Status; state == api.TopicState_READY {
+			return nil
 		}
 
-		// Make the topics page request
-		if page, err = c.api.ListTopics(ctx, query, c.copts...); err != nil {
-			// TODO: do a better job of categorizing the error
-			return nil, err
+		if err = backoff.Wait(ctx, ticker); err != nil {
+			return fmt.Errorf("%w: topic %s is %s: %s", ErrTopicNotReady, topicID, state, err)
 		}
+	}
+}
 
-		// Update the query and append the topics to the request
-		topics = append(topics, page.Topics...)
-		query.NextPageToken = page.NextPageToken
+// ListTopics fetches all the topics that the client has access to in the project that
+// the API keys are defined for. The ListTopics RPC is a paginated RPC, and this method
+// continues to fetch all pages before returning a list of a results; fully
+// materializing the list of topics in memory. Use Topics to iterate over the topics
+// one page at a time for projects with many topics.
+func (c *Client) ListTopics(ctx context.Context, opts ...TopicsOption) (topics []*api.Topic, err error) {
+	topics = make([]*api.Topic, 0)
+	iter := c.Topics(ctx, opts...)
+	for iter.Next() {
+		topics = append(topics, iter.Topic())
 	}
 
+	if err = iter.Err(); err != nil {
+		return nil, err
+	}
 	return topics, nil
 }
 
 // Archive a topic marking it as read-only.
 func (c *Client) ArchiveTopic(ctx context.Context, topicID string) (_ api.TopicState, err error) {
+	if c.opts.ReadOnly {
+		return api.TopicState_UNDEFINED, ErrReadOnlyClient
+	}
+
 	req := &api.TopicMod{
 		Id:        topicID,
 		Operation: api.TopicMod_ARCHIVE,
@@ -81,7 +139,7 @@ func (c *Client) ArchiveTopic(ctx context.Context, topicID string) (_ api.TopicS
 
 	var state *api.TopicStatus
 	if state, err = c.api.DeleteTopic(ctx, req, c.copts...); err != nil {
-		return api.TopicState_UNDEFINED, err
+		return api.TopicState_UNDEFINED, translateError(err)
 	}
 
 	return state.State, nil
@@ -89,6 +147,10 @@ func (c *Client) ArchiveTopic(ctx context.Context, topicID string) (_ api.TopicS
 
 // Destroy a topic removing it and all of its data.
 func (c *Client) DestroyTopic(ctx context.Context, topicID string) (_ api.TopicState, err error) {
+	if c.opts.ReadOnly {
+		return api.TopicState_UNDEFINED, ErrReadOnlyClient
+	}
+
 	req := &api.TopicMod{
 		Id:        topicID,
 		Operation: api.TopicMod_DESTROY,
@@ -96,7 +158,7 @@ func (c *Client) DestroyTopic(ctx context.Context, topicID string) (_ api.TopicS
 
 	var state *api.TopicStatus
 	if state, err = c.api.DeleteTopic(ctx, req, c.copts...); err != nil {
-		return api.TopicState_UNDEFINED, err
+		return api.TopicState_UNDEFINED, translateError(err)
 	}
 
 	return state.State, nil
@@ -104,35 +166,28 @@ func (c *Client) DestroyTopic(ctx context.Context, topicID string) (_ api.TopicS
 
 // Set the topic deduplication policy on the server.
 func (c *Client) SetTopicDeduplicationPolicy(ctx context.Context, topicID string, policy api.Deduplication_Strategy, offset api.Deduplication_OffsetPosition, keysOrFields []string, overwriteDuplicate bool) (_ api.TopicState, err error) {
-	out := &api.TopicPolicy{
-		Id: topicID,
-		DeduplicationPolicy: &api.Deduplication{
-			Strategy:           policy,
-			Offset:             offset,
-			OverwriteDuplicate: overwriteDuplicate,
-		},
-	}
-
-	switch policy {
-	case api.Deduplication_KEY_GROUPED, api.Deduplication_UNIQUE_KEY:
-		out.DeduplicationPolicy.Keys = keysOrFields
-	case api.Deduplication_UNIQUE_FIELD:
-		out.DeduplicationPolicy.Fields = keysOrFields
-	default:
-		if len(keysOrFields) > 0 {
-			return api.TopicState_UNDEFINED, fmt.Errorf("%s policy does not support keys or fields", policy)
-		}
+	if c.opts.ReadOnly {
+		return api.TopicState_UNDEFINED, ErrReadOnlyClient
+	}
+
+	out := &api.TopicPolicy{Id: topicID}
+	if out.DeduplicationPolicy, err = newDeduplication(policy, offset, keysOrFields, overwriteDuplicate); err != nil {
+		return api.TopicState_UNDEFINED, err
 	}
 
 	var rep *api.TopicStatus
 	if rep, err = c.api.SetTopicPolicy(ctx, out, c.copts...); err != nil {
-		return api.TopicState_UNDEFINED, err
+		return api.TopicState_UNDEFINED, translateError(err)
 	}
 	return rep.State, nil
 }
 
 // Set the topic sharding strategy on the server.
 func (c *Client) SetTopicShardingStrategy(ctx context.Context, topicID string, strategy api.ShardingStrategy) (_ api.TopicState, err error) {
+	if c.opts.ReadOnly {
+		return api.TopicState_UNDEFINED, ErrReadOnlyClient
+	}
+
 	out := &api.TopicPolicy{
 		Id:               topicID,
 		ShardingStrategy: strategy,
@@ -140,14 +195,21 @@ func (c *Client) SetTopicShardingStrategy(ctx context.Context, topicID string, s
 
 	var rep *api.TopicStatus
 	if rep, err = c.api.SetTopicPolicy(ctx, out, c.copts...); err != nil {
-		return api.TopicState_UNDEFINED, err
+		return api.TopicState_UNDEFINED, translateError(err)
 	}
 	return rep.State, nil
 }
 
-// Find a topic ID from a topic name.
-// TODO: automate and cache this on the client for easier lookups.
+// Find a topic ID from a topic name. If the client was created with a topic cache using
+// WithTopicCache the cache is checked first and the topic ID is added to the cache
+// after it is fetched from Ensign.
 func (c *Client) TopicID(ctx context.Context, topicName string) (_ string, err error) {
+	if c.opts.TopicCache != nil {
+		if topicID, cached := c.opts.TopicCache.Lookup(topicName); cached {
+			return topicID, nil
+		}
+	}
+
 	// Create a base64 encoded murmur3 hash of the topic name
 	hash := murmur3.New128()
 	hash.Write([]byte(topicName))
@@ -159,11 +221,12 @@ func (c *Client) TopicID(ctx context.Context, topicName string) (_ string, err e
 
 	for page == nil || page.NextPageToken != "" {
 		if page, err = c.api.TopicNames(ctx, query, c.copts...); err != nil {
-			return "", err
+			return "", translateError(err)
 		}
 
 		for _, topic := range page.TopicNames {
 			if topic.Name == topicHash {
+				c.cacheTopic(topicName, topic.TopicId)
 				return topic.TopicId, nil
 			}
 		}
@@ -172,3 +235,77 @@ func (c *Client) TopicID(ctx context.Context, topicName string) (_ string, err e
 
 	return "", ErrTopicNameNotFound
 }
+
+// Add the topic name and ID to the topic cache if the client has one.
+func (c *Client) cacheTopic(topicName, topicID string) {
+	if c.opts.TopicCache != nil {
+		c.opts.TopicCache.Set(topicName, topicID)
+	}
+}
+
+// Add all of the topics returned by the server when a stream is opened to the topic
+// cache if the client has one.
+func (c *Client) cacheTopics(topics map[string]ulid.ULID) {
+	if c.opts.TopicCache != nil {
+		for name, topicID := range topics {
+			c.opts.TopicCache.Set(name, topicID.String())
+		}
+	}
+}
+
+// Resolve the topic names of a subscription to topic IDs so that unknown topics are
+// reported by name. Names are resolved with the topic cache if the client has one or
+// with a temporary cache otherwise; if ensure is true then topics that do not exist
+// are created.
+func (c *Client) resolveTopics(names []string, ensure bool) (_ []string, err error) {
+	cache := c.opts.TopicCache
+	if cache == nil {
+		cache = topics.NewCache(c)
+	}
+
+	topicIDs := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, err = ulid.Parse(name); err == nil {
+			topicIDs = append(topicIDs, name)
+			continue
+		}
+
+		var topicID string
+		if ensure {
+			topicID, err = cache.Ensure(name)
+		} else {
+			topicID, err = cache.Get(name)
+		}
+
+		if err != nil {
+			if errors.Is(err, topics.ErrTopicNotFound) {
+				return nil, fmt.Errorf("%w: %q", ErrTopicNameNotFound, name)
+			}
+			return nil, fmt.Errorf("could not resolve topic %q: %w", name, err)
+		}
+		topicIDs = append(topicIDs, topicID)
+	}
+	return topicIDs, nil
+}
+
+// Resolve a topic name to a topic ID using the topic cache if the client has one, so
+// that Publish uses the same topic mapping as the user. If the topic is already a
+// topic ID or the client has no topic cache, the topic is returned unmodified. The
+// lookup is bounded by the context.
+func (c *Client) resolveTopic(ctx context.Context, topic string) (topicID string, err error) {
+	if c.opts.TopicCache == nil {
+		return topic, nil
+	}
+
+	if _, err = ulid.Parse(topic); err == nil {
+		return topic, nil
+	}
+
+	if topicID, err = c.opts.TopicCache.GetContext(ctx, topic); err != nil {
+		if errors.Is(err, topics.ErrTopicNotFound) {
+			return "", fmt.Errorf("%w: %q", ErrTopicNameNotFound, topic)
+		}
+		return "", err
+	}
+	return topicID, nil
+}
@@ -3,9 +3,8 @@ package topics
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
-
-	sdk "github.com/rotationalio/go-ensign"
 )
 
 const DefaultTimeout = 15 * time.Second
@@ -13,13 +12,35 @@ const DefaultTimeout = 15 * time.Second
 var (
 	// TODO: move to dedicated errors package
 	ErrTopicNotFound = errors.New("topic with specified name does not exist")
+
+	// ErrTopicNameNotFound is returned by a Client when a topic name cannot be found in
+	// the project; it is aliased by the ensign package as ensign.ErrTopicNameNotFound.
+	ErrTopicNameNotFound = errors.New("topic name not found in project")
+
+	// ErrTopicAlreadyExists is returned by a Client when a topic cannot be created
+	// because a topic with the same name already exists in the project; it is aliased by
+	// the ensign package as ensign.ErrTopicAlreadyExists.
+	ErrTopicAlreadyExists = errors.New("topic already exists")
+
+	// ErrNoClient is returned when a topic is not in the cache and must be looked up but
+	// the cache was created without a client and has not been passed to an Ensign client
+	// using ensign.WithTopicCache.
+	ErrNoClient = errors.New("topic cache does not have a client to lookup topics")
 )
 
 // Cache manages topics on behalf of the user, looking up topicIDs by name and
 // cacheing them to prevent multiple remote requests. The cache should also wrap an
 // Ensign client but the cache uses the topic management functionality of the client, so
 // an independent interface is added to make testing simpler.
+//
+// A Cache is safe for concurrent use by multiple go routines and can be shared between
+// user code and an Ensign client using ensign.WithTopicCache, in which case the client
+// resolves and caches topic names using the shared cache. Because topic names are only
+// unique within a project, a cache must only be shared between clients that connect to
+// the same project. Lookups for names that are not in the cache may be performed
+// concurrently; the last topicID fetched from Ensign is the one that is cached.
 type Cache struct {
+	sync.RWMutex
 	topics map[string]string
 	client Client
 }
@@ -30,6 +51,9 @@ type Client interface {
 	CreateTopic(context.Context, string) (string, error)
 }
 
+// NewCache creates a topic cache that uses the client to lookup topics. The client may
+// be nil if the cache is passed to an Ensign client using ensign.WithTopicCache, in
+// which case the Ensign client will be used to lookup topics.
 func NewCache(client Client) *Cache {
 	return &Cache{
 		topics: make(map[string]string),
@@ -37,41 +61,92 @@ func NewCache(client Client) *Cache {
 	}
 }
 
+// UseClient sets the client that is used to lookup topics if the cache was created
+// without a client. If the cache already has a client then this method is a no-op.
+func (t *Cache) UseClient(client Client) {
+	t.Lock()
+	defer t.Unlock()
+	if t.client == nil {
+		t.client = client
+	}
+}
+
 // Get returns a topicID from a topic; if the topic is not in the cache; an RPC call to
 // ensign is made to get and store the topic ID.
 func (t *Cache) Get(topic string) (topicID string, err error) {
+	return t.GetContext(context.Background(), topic)
+}
+
+// GetContext returns a topicID from a topic in the same manner as Get but the RPC call to
+// ensign is bounded by the context as well as by the default timeout.
+func (t *Cache) GetContext(ctx context.Context, topic string) (topicID string, err error) {
 	var cached bool
-	if topicID, cached = t.topics[topic]; !cached {
+	if topicID, cached = t.Lookup(topic); !cached {
 		// Fetch the topicID from Ensign
-		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
 		defer cancel()
 
-		if topicID, err = t.client.TopicID(ctx, topic); err != nil {
-			if errors.Is(err, sdk.ErrTopicNameNotFound) {
+		var client Client
+		if client, err = t.api(); err != nil {
+			return "", err
+		}
+
+		if topicID, err = client.TopicID(ctx, topic); err != nil {
+			if errors.Is(err, ErrTopicNameNotFound) {
 				return "", ErrTopicNotFound
 			}
 			return "", err
 		}
 
 		// Cache the topicID to prevent future RPC calls
-		t.topics[topic] = topicID
+		t.Set(topic, topicID)
 	}
 	return topicID, nil
 }
 
+// Lookup returns the topicID for the topic from the cache without making an RPC call
+// to Ensign; the second return value is false if the topic is not in the cache.
+func (t *Cache) Lookup(topic string) (topicID string, cached bool) {
+	t.RLock()
+	defer t.RUnlock()
+	topicID, cached = t.topics[topic]
+	return topicID, cached
+}
+
+// Set the topicID of the topic in the cache, e.g. from a topic map returned by Ensign
+// when a stream is opened.
+func (t *Cache) Set(topic, topicID string) {
+	t.Lock()
+	t.topics[topic] = topicID
+	t.Unlock()
+}
+
+// Delete the topic from the cache, e.g. when the topic has been destroyed.
+func (t *Cache) Delete(topic string) {
+	t.Lock()
+	delete(t.topics, topic)
+	t.Unlock()
+}
+
 // Exists checks if the topic exists, first by checking the cache and if the topic is
 // not in the cache by performing an RPC call to ensign to check if the topic exists.
 func (t *Cache) Exists(topic string) (exists bool, err error) {
 	// Check if the topic is in the topic cache.
-	if _, exists = t.topics[topic]; exists {
+	if _, exists = t.Lookup(topic); exists {
 		return true, nil
 	}
 
 	// Otherwise make a request to Ensign to see if the topic exists
+	var client Client
+	if client, err = t.api(); err != nil {
+		return false, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancel()
 
-	return t.client.TopicExists(ctx, topic)
+	return client.TopicExists(ctx, topic)
 }
 
 // Ensure the topic exists by first performing a check if the topic exists and if it
@@ -80,42 +155,52 @@ func (t *Cache) Exists(topic string) (exists bool, err error) {
 // already exists error).
 func (t *Cache) Ensure(topic string) (topicID string, err error) {
 	var cached bool
-	if topicID, cached = t.topics[topic]; !cached {
+	if topicID, cached = t.Lookup(topic); !cached {
 		// Fetch the topicID from Ensign
 		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
 		defer cancel()
 
 		// TODO: this could probably be optimized using a call to TopicID and checking
 		// if the error is NotFound. The exists check is written here for clarity.
+		var client Client
+		if client, err = t.api(); err != nil {
+			return "", err
+		}
+
 		var exists bool
-		if exists, err = t.client.TopicExists(ctx, topic); err != nil {
+		if exists, err = client.TopicExists(ctx, topic); err != nil {
 			return "", err
 		}
 
 		if !exists {
 			// NOTE: there is a race condition between the existence check and the
-			// create topic call (e.g. some other process could create the topic), which
-			// would result in an error being returned by CreateTopic. Better error
-			// handling would fix this case, since the user only needs the topic to be
-			// created and the topicID returned.
-			if topicID, err = t.client.CreateTopic(ctx, topic); err != nil {
-				// TODO: check ErrTopicAlreadyExists and return no error in this case.
-				return "", err
+			// create topic call (e.g. some other process could create the topic); since
+			// the user only needs the topic to exist, the topicID is fetched instead.
+			if topicID, err = client.CreateTopic(ctx, topic); err != nil {
+				if !errors.Is(err, ErrTopicAlreadyExists) {
+					return "", err
+				}
+
+				if topicID, err = client.TopicID(ctx, topic); err != nil {
+					return "", err
+				}
 			}
 		} else {
-			if topicID, err = t.client.TopicID(ctx, topic); err != nil {
+			if topicID, err = client.TopicID(ctx, topic); err != nil {
 				return "", err
 			}
 		}
 
 		// Cache the topicID to prevent future RPC calls
-		t.topics[topic] = topicID
+		t.Set(topic, topicID)
 	}
 	return topicID, nil
 }
 
 // Clear the topic cache resetting any internal cached state and refetching topic info.
 func (t *Cache) Clear() {
+	t.Lock()
+	defer t.Unlock()
 	for key := range t.topics {
 		delete(t.topics, key)
 	}
@@ -123,5 +208,17 @@ func (t *Cache) Clear() {
 
 // Length returns the number of items in the cache
 func (t *Cache) Length() int {
+	t.RLock()
+	defer t.RUnlock()
 	return len(t.topics)
 }
+
+// Returns the client used to lookup topics or ErrNoClient if the cache has no client.
+func (t *Cache) api() (Client, error) {
+	t.RLock()
+	defer t.RUnlock()
+	if t.client == nil {
+		return nil, ErrNoClient
+	}
+	return t.client, nil
+}
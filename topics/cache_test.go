@@ -250,6 +250,29 @@ func (s *topicTestSuite) TestEnsureCreateError() {
 	require.EqualError(err, "rpc error: code = Internal desc = couldn't create topic")
 }
 
+func (s *topicTestSuite) TestEnsureAlreadyExists() {
+	// If the topic is created between the exists check and create topic, the topicID
+	// of the existing topic should be returned.
+	require := s.Require()
+	require.Equal(0, s.cache.Length(), "expected cache to be empty")
+
+	s.mock.OnTopicExists = func(context.Context, *api.TopicName) (*api.TopicExistsInfo, error) {
+		return &api.TopicExistsInfo{
+			Exists: false,
+		}, nil
+	}
+
+	s.mock.UseError(mock.CreateTopicRPC, codes.AlreadyExists, "topic already exists")
+	err := s.mock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json")
+	require.NoError(err, "could not load topic names fixture")
+
+	topicID, err := s.cache.Ensure("testing.topics.topica")
+	require.NoError(err, "expected the existing topic to be returned")
+	require.Equal("01GWM89049D49FHJH81BT8795H", topicID, "unexpected topicId returned")
+	require.Equal(1, s.cache.Length(), "expected the topic to be cached")
+	require.Equal(1, s.mock.Calls[mock.TopicNamesRPC], "expected the topic id to be looked up")
+}
+
 func (s *topicTestSuite) TestEnsureExistsError() {
 	// The topic cache should be empty to start and make a request to Ensign; after
 	// which point the topic name should be retrieved from the cache without an RPC.
@@ -279,3 +302,46 @@ func (s *topicTestSuite) TestEnsureTopicIDError() {
 	_, err := s.cache.Ensure("testing.topics.topica")
 	require.EqualError(err, "rpc error: code = Internal desc = couldn't get topic id")
 }
+
+func (s *topicTestSuite) TestNoClient() {
+	// A cache without a client cannot lookup topics that are not cached.
+	require := s.Require()
+	cache := NewCache(nil)
+
+	_, err := cache.Get("testing.topics.topica")
+	require.ErrorIs(err, ErrNoClient)
+
+	_, err = cache.Exists("testing.topics.topica")
+	require.ErrorIs(err, ErrNoClient)
+
+	_, err = cache.Ensure("testing.topics.topica")
+	require.ErrorIs(err, ErrNoClient)
+
+	// Cached topics are returned without a client.
+	cache.Set("testing.topics.topica", "01GWM936SNSN36JKTMSF9Q3N8B")
+	topicID, err := cache.Get("testing.topics.topica")
+	require.NoError(err, "expected the cached topic to be returned")
+	require.Equal("01GWM936SNSN36JKTMSF9Q3N8B", topicID)
+
+	exists, err := cache.Exists("testing.topics.topica")
+	require.NoError(err, "expected the cached topic to exist")
+	require.True(exists)
+	require.Len(s.mock.Calls, 0, "expected no RPCs to be called")
+}
+
+func (s *topicTestSuite) TestGetContext() {
+	// The lookup should be bounded by the context
+	require := s.Require()
+	err := s.mock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json")
+	require.NoError(err, "could not load topic names fixture")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.cache.GetContext(ctx, "testing.topics.topicb")
+	require.ErrorIs(err, context.Canceled)
+	require.Equal(0, s.cache.Length(), "expected the topic not to be cached")
+
+	topicID, err := s.cache.GetContext(context.Background(), "testing.topics.topicb")
+	require.NoError(err, "could not lookup topic id")
+	require.Equal("01GWM936SNSN36JKTMSF9Q3N8B", topicID)
+}
@@ -0,0 +1,104 @@
+package ensign
+
+import (
+	"context"
+
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// TopicsOption configures how a TopicIterator fetches pages of topics.
+type TopicsOption func(*api.PageInfo)
+
+// WithPageSize specifies the number of topics fetched with each request by a topic
+// iterator; by default DefaultPageSize topics are fetched per page.
+func WithPageSize(size uint32) TopicsOption {
+	return func(query *api.PageInfo) {
+		query.PageSize = size
+	}
+}
+
+// TopicIterator lazily fetches the topics that the client has access to one page at a
+// time, so that only a single page of topics is held in memory. Call Next to advance
+// the iterator to the next topic, fetching the next page if required, then call Topic
+// to get the current topic. When Next returns false, check Err for any errors that
+// stopped the iteration, e.g.
+//
+//	iter := client.Topics(ctx)
+//	for iter.Next() {
+//	    topic := iter.Topic()
+//	}
+//
+//	if err := iter.Err(); err != nil {
+//	    return err
+//	}
+type TopicIterator struct {
+	ctx    context.Context
+	client *Client
+	query  *api.PageInfo
+	page   []*api.Topic
+	topic  *api.Topic
+	last   bool
+	err    error
+}
+
+// Topics returns an iterator over the topics that the client has access to in the
+// project that the API keys are defined for. Pages of topics are requested from Ensign
+// as they are needed by the iterator rather than all at once (see ListTopics).
+func (c *Client) Topics(ctx context.Context, opts ...TopicsOption) *TopicIterator {
+	query := &api.PageInfo{PageSize: DefaultPageSize}
+	for _, opt := range opts {
+		opt(query)
+	}
+	return &TopicIterator{ctx: ctx, client: c, query: query}
+}
+
+// Next advances the iterator to the next topic, returning false when there are no more
+// topics or if an error occurred while fetching the next page of topics.
+func (i *TopicIterator) Next() bool {
+	i.topic = nil
+	if i.err != nil {
+		return false
+	}
+
+	// Fetch pages until a page with topics is found or there are no more pages.
+	for len(i.page) == 0 {
+		if i.last {
+			return false
+		}
+
+		if i.err = i.fetch(); i.err != nil {
+			return false
+		}
+	}
+
+	i.topic, i.page = i.page[0], i.page[1:]
+	return true
+}
+
+// Topic returns the current topic or nil if Next has not been called or returned false.
+func (i *TopicIterator) Topic() *api.Topic {
+	return i.topic
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (i *TopicIterator) Err() error {
+	return i.err
+}
+
+// Fetch the next page of topics.
+func (i *TopicIterator) fetch() (err error) {
+	// If the context is done, stop requesting new pages
+	if err = i.ctx.Err(); err != nil {
+		return err
+	}
+
+	var page *api.TopicsPage
+	if page, err = i.client.api.ListTopics(i.ctx, i.query, i.client.copts...); err != nil {
+		return translateError(err)
+	}
+
+	i.page = page.Topics
+	i.query.NextPageToken = page.NextPageToken
+	i.last = page.NextPageToken == ""
+	return nil
+}
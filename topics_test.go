@@ -2,11 +2,22 @@ package ensign_test
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
 
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
 	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/backoff"
 	"github.com/rotationalio/go-ensign/mock"
+	region "github.com/rotationalio/go-ensign/region/v1beta1"
+	"github.com/rotationalio/go-ensign/topics"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func (s *sdkTestSuite) TestSetTopicDeduplicationPolicy() {
@@ -178,3 +189,418 @@ func (s *sdkTestSuite) TestSetTopicShardingStrategy() {
 		require.Equal(1, s.mock.Calls[mock.SetTopicPolicyRPC])
 	})
 }
+
+func TestWithTopicCache(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	err := emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json")
+	require.NoError(t, err, "could not load topic names fixture")
+
+	// The cache is created without a client so the ensign client is used for lookups.
+	cache := topics.NewCache(nil)
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithTopicCache(cache))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	// Looking up the topic ID on the client should populate the shared cache
+	topicID, err := client.TopicID(context.Background(), "testing.topics.topica")
+	require.NoError(t, err, "could not lookup topic id")
+	require.Equal(t, "01GWM89049D49FHJH81BT8795H", topicID)
+	require.Equal(t, 1, cache.Length())
+	require.Equal(t, 1, emock.Calls[mock.TopicNamesRPC])
+
+	// Lookups on the cache and client should not make any additional RPCs
+	topicID, err = cache.Get("testing.topics.topica")
+	require.NoError(t, err, "could not get topic from cache")
+	require.Equal(t, "01GWM89049D49FHJH81BT8795H", topicID)
+
+	topicID, err = client.TopicID(context.Background(), "testing.topics.topica")
+	require.NoError(t, err, "could not lookup topic id")
+	require.Equal(t, "01GWM89049D49FHJH81BT8795H", topicID)
+	require.Equal(t, 1, emock.Calls[mock.TopicNamesRPC])
+
+	// Cache misses made by the user should use the ensign client
+	topicID, err = cache.Get("testing.topics.topicb")
+	require.NoError(t, err, "could not get topic from cache")
+	require.Equal(t, "01GWM936SNSN36JKTMSF9Q3N8B", topicID)
+	require.Equal(t, 2, emock.Calls[mock.TopicNamesRPC])
+
+	_, err = cache.Get("testing.topics.missing")
+	require.ErrorIs(t, err, topics.ErrTopicNotFound)
+
+	// Publish should resolve topic names using the cache before opening a stream
+	err = client.Publish("testing.topics.missing", NewEvent())
+	require.ErrorIs(t, err, sdk.ErrTopicNameNotFound)
+	require.ErrorContains(t, err, "testing.topics.missing")
+	require.Equal(t, 4, emock.Calls[mock.TopicNamesRPC])
+	require.Zero(t, emock.Calls[mock.PublishRPC], "expected no publish stream to be opened")
+
+	// The topic lookup should be bounded by the publish context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.PublishContext(ctx, "testing.topics.topicc", NewEvent())
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, emock.Calls[mock.PublishRPC], "expected no publish stream to be opened")
+
+	_, cached := cache.Lookup("testing.topics.topicc")
+	require.False(t, cached, "expected the topic not to be cached")
+
+	// Subscribe should resolve topic names using the cache before opening a stream
+	_, err = client.Subscribe("testing.topics.missing")
+	require.ErrorIs(t, err, sdk.ErrTopicNameNotFound)
+	require.Zero(t, emock.Calls[mock.SubscribeRPC], "expected no subscribe stream to be opened")
+
+	// Subscribe should seed the cache with the topics returned by the server
+	handler := mock.NewSubscribeHandler()
+	handler.UseTopicMap(map[string]ulid.ULID{
+		"testing.topics.topica": ulid.MustParse("01GWM89049D49FHJH81BT8795H"),
+		"testing.topics.topicd": ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A"),
+	})
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	sub, err := client.Subscribe("testing.topics.topica", "01HCG64Y1SMFQBW7A42SRV207A")
+	require.NoError(t, err, "could not subscribe")
+	defer sub.Close()
+
+	topicID, cached = cache.Lookup("testing.topics.topicd")
+	require.True(t, cached, "expected subscriber topics to be added to the cache")
+	require.Equal(t, "01HCG64Y1SMFQBW7A42SRV207A", topicID)
+}
+
+func TestSubscribeEnsureTopics(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	err := emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json")
+	require.NoError(t, err, "could not load topic names fixture")
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	// Only the missing topic should be created
+	emock.OnTopicExists = func(_ context.Context, in *api.TopicName) (*api.TopicExistsInfo, error) {
+		return &api.TopicExistsInfo{Query: in.Name, Exists: in.Name == "testing.topics.topica"}, nil
+	}
+
+	created := ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A")
+	emock.OnCreateTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		return &api.Topic{Id: created[:], Name: in.Name}, nil
+	}
+
+	// The subscription should be opened with the topic IDs
+	var requested []string
+	handler := mock.NewSubscribeHandler()
+	handler.OnInitialize = func(in *api.Subscription) (*api.StreamReady, error) {
+		requested = in.Topics
+		return &api.StreamReady{ClientId: in.ClientId, ServerId: "mock"}, nil
+	}
+	emock.OnSubscribe = handler.OnSubscribe
+	defer handler.Shutdown()
+
+	topicID := "01HCG64Y1SMFQBW7A42SRV207A"
+	sub, err := client.CreateSubscriber([]string{"testing.topics.topica", "testing.topics.created", topicID}, sdk.WithEnsureTopics())
+	require.NoError(t, err, "could not subscribe")
+	require.NoError(t, sub.Close())
+
+	require.Equal(t, []string{"01GWM89049D49FHJH81BT8795H", created.String(), topicID}, requested)
+	require.Equal(t, 1, emock.Calls[mock.CreateTopicRPC])
+
+	// Without a topic cache or the ensure topics option, topics are still resolved
+	sub, err = client.Subscribe("testing.topics.topicb")
+	require.NoError(t, err, "could not subscribe")
+	require.NoError(t, sub.Close())
+	require.Equal(t, []string{"01GWM936SNSN36JKTMSF9Q3N8B"}, requested)
+	require.Equal(t, 1, emock.Calls[mock.CreateTopicRPC], "expected no topics to be created")
+
+	// Unknown topics are reported by name before the stream is opened
+	_, err = client.Subscribe("testing.topics.missing")
+	require.ErrorIs(t, err, sdk.ErrTopicNameNotFound)
+	require.ErrorContains(t, err, "testing.topics.missing")
+	require.Equal(t, 2, emock.Calls[mock.SubscribeRPC])
+}
+
+func TestCreateTopicAndWait(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	policy := func() backoff.Backoff { return &backoff.ConstantBackOff{Interval: time.Millisecond} }
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithBackoff(policy))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	topicID := ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A")
+	emock.OnCreateTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		return &api.Topic{Id: topicID[:], Name: in.Name, Status: api.TopicState_PENDING}, nil
+	}
+
+	// The topic is not found, then pending, before it becomes ready
+	emock.OnRetrieveTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		switch emock.Calls[mock.RetrieveTopicRPC] {
+		case 1:
+			return nil, status.Error(codes.NotFound, "topic not found")
+		case 2, 3:
+			return &api.Topic{Id: in.Id, Status: api.TopicState_PENDING}, nil
+		default:
+			return &api.Topic{Id: in.Id, Status: api.TopicState_READY}, nil
+		}
+	}
+
+	id, err := client.CreateTopicAndWait(context.Background(), "testing.topics.ready")
+	require.NoError(t, err, "could not create topic and wait for it to be ready")
+	require.Equal(t, topicID.String(), id)
+	require.Equal(t, 4, emock.Calls[mock.RetrieveTopicRPC])
+
+	// If the topic never becomes ready the context deadline should stop polling
+	emock.OnRetrieveTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		return &api.Topic{Id: in.Id, Status: api.TopicState_ALLOCATING}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+	id, err = client.CreateTopicAndWait(ctx, "testing.topics.allocating")
+	require.ErrorIs(t, err, sdk.ErrTopicNotReady)
+	require.Equal(t, topicID.String(), id, "expected topic id to be returned even if not ready")
+
+	// Errors other than not found should be returned immediately
+	emock.OnRetrieveTopic = func(context.Context, *api.Topic) (*api.Topic, error) {
+		return nil, status.Error(codes.PermissionDenied, "not allowed")
+	}
+	_, err = client.CreateTopicAndWait(context.Background(), "testing.topics.denied")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, sdk.ErrTopicNotReady)
+}
+
+func TestTopicIterator(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+
+	fixture := make([]*api.Topic, 0, 7)
+	for i := 0; i < 7; i++ {
+		fixture = append(fixture, &api.Topic{Id: ulid.Make().Bytes(), Name: fmt.Sprintf("topic-%d", i)})
+	}
+
+	// Serve the topics in pages using the offset of the page as the page token.
+	var fail bool
+	emock.OnListTopics = func(_ context.Context, in *api.PageInfo) (*api.TopicsPage, error) {
+		offset := 0
+		if in.NextPageToken != "" {
+			if fail {
+				return nil, status.Error(codes.Unavailable, "could not fetch page")
+			}
+			offset, _ = strconv.Atoi(in.NextPageToken)
+		}
+
+		end := offset + int(in.PageSize)
+		if end >= len(fixture) {
+			return &api.TopicsPage{Topics: fixture[offset:]}, nil
+		}
+		return &api.TopicsPage{Topics: fixture[offset:end], NextPageToken: strconv.Itoa(end)}, nil
+	}
+
+	t.Run("Lazy", func(t *testing.T) {
+		iter := client.Topics(context.Background(), sdk.WithPageSize(3))
+		require.Nil(t, iter.Topic(), "expected no topic before next")
+		require.Zero(t, emock.Calls[mock.ListTopicsRPC], "expected no pages to be fetched before next")
+
+		for i, expected := range fixture {
+			require.True(t, iter.Next(), "expected topic %d", i)
+			require.Equal(t, expected.Name, iter.Topic().Name)
+			require.Equal(t, i/3+1, emock.Calls[mock.ListTopicsRPC], "expected pages to be fetched as needed")
+		}
+
+		require.False(t, iter.Next(), "expected no more topics")
+		require.Nil(t, iter.Topic())
+		require.NoError(t, iter.Err())
+		require.Equal(t, 3, emock.Calls[mock.ListTopicsRPC])
+	})
+
+	t.Run("ListTopics", func(t *testing.T) {
+		topics, err := client.ListTopics(context.Background(), sdk.WithPageSize(2))
+		require.NoError(t, err)
+		require.Len(t, topics, len(fixture))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		fail = true
+		defer func() { fail = false }()
+
+		iter := client.Topics(context.Background(), sdk.WithPageSize(5))
+		for i := 0; i < 5; i++ {
+			require.True(t, iter.Next(), "expected the first page to be iterated")
+		}
+
+		require.False(t, iter.Next(), "expected the iteration to stop on error")
+		require.False(t, iter.Next(), "expected the iteration to remain stopped")
+		require.Equal(t, codes.Unavailable, status.Code(iter.Err()))
+
+		_, err := client.ListTopics(context.Background(), sdk.WithPageSize(5))
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		iter := client.Topics(ctx)
+		require.False(t, iter.Next())
+		require.ErrorIs(t, iter.Err(), context.Canceled)
+	})
+}
+
+func TestCreateTopicWithOptions(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	var created *api.Topic
+	topicID := ulid.MustParse("01HCG64Y1SMFQBW7A42SRV207A")
+	emock.OnCreateTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		created = in
+		return &api.Topic{Id: topicID[:], Name: in.Name}, nil
+	}
+
+	orderType := &api.Type{Name: "Order", MajorVersion: 1}
+	out, err := client.CreateTopicWithOptions(context.Background(), "orders",
+		sdk.WithTopicTypes(orderType),
+		sdk.WithDeduplication(api.Deduplication_UNIQUE_FIELD, api.Deduplication_OFFSET_EARLIEST, []string{"order_id"}, false),
+		sdk.WithShardingStrategy(api.ShardingStrategy_CONSISTENT_KEY_HASH),
+		sdk.WithPlacementRegions(region.Region_LKE_US_EAST_1A, region.Region_LKE_US_WEST_1A),
+	)
+	require.NoError(t, err, "could not create topic")
+	require.Equal(t, topicID.String(), out)
+
+	require.Equal(t, "orders", created.Name)
+	require.Len(t, created.Types, 1)
+	require.True(t, proto.Equal(orderType, created.Types[0]))
+	require.Equal(t, api.Deduplication_UNIQUE_FIELD, created.Deduplication.Strategy)
+	require.Equal(t, []string{"order_id"}, created.Deduplication.Fields)
+	require.Empty(t, created.Deduplication.Keys)
+	require.Len(t, created.Placements, 1)
+	require.Equal(t, api.ShardingStrategy_CONSISTENT_KEY_HASH, created.Placements[0].Sharding)
+	require.Equal(t, []region.Region{region.Region_LKE_US_EAST_1A, region.Region_LKE_US_WEST_1A}, created.Placements[0].Regions)
+
+	// Invalid options are reported before the topic is created.
+	_, err = client.CreateTopicWithOptions(context.Background(), "invalid", sdk.WithDeduplication(api.Deduplication_STRICT, api.Deduplication_OFFSET_EARLIEST, []string{"order_id"}, false))
+	require.EqualError(t, err, "STRICT policy does not support keys or fields")
+	require.Equal(t, 1, emock.Calls[mock.CreateTopicRPC])
+}
+
+func TestDeleteTopicByName(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	policy := func() backoff.Backoff { return &backoff.ConstantBackOff{Interval: time.Millisecond} }
+	cache := topics.NewCache(nil)
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithBackoff(policy), sdk.WithTopicCache(cache))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	topicID := "01HCG64Y1SMFQBW7A42SRV207A"
+	cache.Set("testing.topics.delete", topicID)
+
+	emock.OnDeleteTopic = func(_ context.Context, in *api.TopicMod) (*api.TopicStatus, error) {
+		require.Equal(t, topicID, in.Id)
+		if in.Operation == api.TopicMod_ARCHIVE {
+			return &api.TopicStatus{Id: in.Id, State: api.TopicState_READONLY}, nil
+		}
+		return &api.TopicStatus{Id: in.Id, State: api.TopicState_DELETING}, nil
+	}
+
+	tomb, err := client.ArchiveTopicByName(context.Background(), "testing.topics.delete")
+	require.NoError(t, err, "could not archive topic")
+	require.Equal(t, topicID, tomb.TopicID)
+	require.Equal(t, api.TopicState_READONLY, tomb.State)
+
+	// Destroy must be confirmed with the topic name
+	_, err = client.DestroyTopicByName(context.Background(), "testing.topics.delete", "testing.topics")
+	require.ErrorIs(t, err, sdk.ErrDestroyNotConfirmed)
+	require.Equal(t, 1, emock.Calls[mock.DeleteTopicRPC])
+
+	tomb, err = client.DestroyTopicByName(context.Background(), "testing.topics.delete", "testing.topics.delete")
+	require.NoError(t, err, "could not destroy topic")
+	require.Equal(t, api.TopicState_DELETING, tomb.State)
+	_, cached := cache.Lookup("testing.topics.delete")
+	require.False(t, cached, "expected destroyed topic to be removed from the cache")
+
+	// The topic is deleting before it is no longer found
+	emock.OnRetrieveTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		if emock.Calls[mock.RetrieveTopicRPC] < 3 {
+			return &api.Topic{Id: in.Id, Status: api.TopicState_DELETING}, nil
+		}
+		return nil, status.Error(codes.NotFound, "topic not found")
+	}
+	require.NoError(t, tomb.WaitForDestroyed(context.Background()))
+	require.Equal(t, 3, emock.Calls[mock.RetrieveTopicRPC])
+
+	// If the topic is never removed the context deadline should stop polling
+	emock.OnRetrieveTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		return &api.Topic{Id: in.Id, Status: api.TopicState_DELETING}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, tomb.WaitForDestroyed(ctx), sdk.ErrTopicNotDestroyed)
+	require.Equal(t, api.TopicState_DELETING, tomb.State)
+
+	// Unknown topic names should not be deleted
+	emock.OnTopicNames = func(context.Context, *api.PageInfo) (*api.TopicNamesPage, error) {
+		return &api.TopicNamesPage{}, nil
+	}
+	_, err = client.ArchiveTopicByName(context.Background(), "testing.topics.unknown")
+	require.ErrorIs(t, err, sdk.ErrTopicNameNotFound)
+}
+
+func TestTopicErrors(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	tests := []struct {
+		code     codes.Code
+		msg      string
+		expected error
+	}{
+		{codes.AlreadyExists, "topic already exists", sdk.ErrTopicAlreadyExists},
+		{codes.Unauthenticated, "missing credentials", sdk.ErrUnauthorized},
+		{codes.PermissionDenied, "missing topics:create permission", sdk.ErrUnauthorized},
+		{codes.ResourceExhausted, "topic limit reached", sdk.ErrQuotaExceeded},
+		{codes.FailedPrecondition, "topic is archived", sdk.ErrTopicArchived},
+		{codes.NotFound, "topic not found", sdk.ErrTopicNotFound},
+		{codes.Unavailable, "ensign is shutting down", sdk.ErrUnavailable},
+		{codes.InvalidArgument, "invalid topic name", sdk.ErrInvalidArgument},
+		{codes.DeadlineExceeded, "deadline exceeded", context.DeadlineExceeded},
+	}
+
+	for _, tc := range tests {
+		emock.UseError(mock.CreateTopicRPC, tc.code, tc.msg)
+		_, err := client.CreateTopic(context.Background(), "testing.topics.errors")
+		require.ErrorIs(t, err, tc.expected, "expected %s to be translated", tc.code)
+
+		// The gRPC status should be preserved
+		var serr *sdk.StatusError
+		require.ErrorAs(t, err, &serr)
+		require.Equal(t, tc.code, serr.Code())
+		require.Equal(t, tc.code, status.Code(err))
+		require.Equal(t, tc.expected.Error()+": "+tc.msg, err.Error())
+	}
+
+	// Errors that cannot be categorized should be returned unmodified
+	emock.UseError(mock.DeleteTopicRPC, codes.FailedPrecondition, "topic is being repaired")
+	_, err = client.ArchiveTopic(context.Background(), "01HCG64Y1SMFQBW7A42SRV207A")
+	require.EqualError(t, err, "rpc error: code = FailedPrecondition desc = topic is being repaired")
+
+	emock.UseError(mock.DeleteTopicRPC, codes.Internal, "something bad happened")
+	_, err = client.DestroyTopic(context.Background(), "01HCG64Y1SMFQBW7A42SRV207A")
+	require.EqualError(t, err, "rpc error: code = Internal desc = something bad happened")
+}
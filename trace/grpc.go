@@ -0,0 +1,26 @@
+package trace
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor returns a gRPC interceptor that traces every unary RPC made
+// to the Ensign server with a span named by the full RPC method, recording the status
+// code of the reply and any error that is returned.
+func UnaryClientInterceptor(tracer Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		var span Span
+		ctx, span = tracer.Start(ctx, method, Attr("rpc.system", "grpc"), Attr("rpc.method", method))
+		defer span.End()
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		span.SetAttributes(Attr("rpc.grpc.status_code", status.Code(err).String()))
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Package trace provides the tracing abstraction used to instrument the Ensign SDK with
+spans around publishing, subscribe event handling, Ensign RPCs, and authentication with
+Quarterdeck. The interfaces mirror the OpenTelemetry tracing API so that an OTel tracer
+provider and text map propagator can be adapted with a few lines of code, without
+requiring the SDK to depend on OpenTelemetry. By default the SDK uses a noop tracer
+provider so that tracing has no overhead unless it is configured.
+*/
+package trace
+
+import (
+	"context"
+)
+
+// TracerProvider creates named tracers, e.g. for the SDK and the authentication client.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts spans. The returned context contains the span so that spans started
+// with the context are children of the span. The caller must End the span.
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span records a single traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key/value pair that describes a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr is a helper to create a span attribute.
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Carrier stores trace context as string key/value pairs, e.g. in the metadata of an
+// event. The method set matches the OpenTelemetry TextMapCarrier.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+	Keys() []string
+}
+
+// Propagator injects the trace context into a carrier so that it can be sent along
+// with an event and extracts the trace context from a carrier so that a trace can be
+// continued by the consumer of the event.
+type Propagator interface {
+	Inject(ctx context.Context, carrier Carrier)
+	Extract(ctx context.Context, carrier Carrier) context.Context
+}
+
+// NewNoopTracerProvider returns a tracer provider whose spans do nothing.
+func NewNoopTracerProvider() TracerProvider {
+	return noop{}
+}
+
+// NewNoopPropagator returns a propagator that does not inject or extract trace context.
+func NewNoopPropagator() Propagator {
+	return noop{}
+}
+
+type noop struct{}
+
+func (noop) Tracer(string) Tracer { return noop{} }
+
+func (noop) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noop{}
+}
+
+func (noop) SetAttributes(...Attribute) {}
+func (noop) RecordError(error)          {}
+func (noop) End()                       {}
+
+func (noop) Inject(context.Context, Carrier) {}
+
+func (noop) Extract(ctx context.Context, _ Carrier) context.Context {
+	return ctx
+}
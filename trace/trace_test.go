@@ -0,0 +1,82 @@
+package trace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rotationalio/go-ensign/trace"
+	"github.com/rotationalio/go-ensign/trace/tracetest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNoop(t *testing.T) {
+	ctx := context.Background()
+	tracer := trace.NewNoopTracerProvider().Tracer("testing")
+	sctx, span := tracer.Start(ctx, "noop", trace.Attr("key", "value"))
+	require.Equal(t, ctx, sctx, "expected noop tracer to return the context unmodified")
+	span.SetAttributes(trace.Attr("key", "value"))
+	span.RecordError(context.Canceled)
+	span.End()
+
+	// Even if the context has a span, the noop propagator should not inject it
+	sctx, span = tracetest.NewRecorder().Start(ctx, "parent")
+	defer span.End()
+
+	meta := make(metadata)
+	propagator := trace.NewNoopPropagator()
+	propagator.Inject(sctx, meta)
+	require.Empty(t, meta, "expected noop propagator to not inject trace context")
+	require.Equal(t, ctx, propagator.Extract(ctx, meta))
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	recorder := tracetest.NewRecorder()
+	interceptor := trace.UnaryClientInterceptor(recorder.Tracer("testing"))
+
+	// The span should be in the context passed to the invoker
+	ctx, parent := recorder.Start(context.Background(), "parent")
+	err := interceptor(ctx, "/testing/Success", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		meta := make(metadata)
+		recorder.Inject(ctx, meta)
+		require.NotEmpty(t, meta.Get(tracetest.TraceKey), "expected rpc span in invoker context")
+		return nil
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Find("/testing/Success")
+	require.Len(t, spans, 1)
+	require.True(t, spans[0].IsEnded())
+	require.Equal(t, "grpc", spans[0].Attributes["rpc.system"])
+	require.Equal(t, codes.OK.String(), spans[0].Attributes["rpc.grpc.status_code"])
+	require.Equal(t, recorder.Find("parent")[0].SpanID, spans[0].ParentID)
+	require.Empty(t, spans[0].Errors)
+
+	// Errors should be recorded on the span and returned
+	err = interceptor(context.Background(), "/testing/Failure", nil, nil, nil, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "not found")
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	spans = recorder.Find("/testing/Failure")
+	require.Len(t, spans, 1)
+	require.Equal(t, codes.NotFound.String(), spans[0].Attributes["rpc.grpc.status_code"])
+	require.Len(t, spans[0].Errors, 1)
+}
+
+// metadata implements trace.Carrier for testing.
+type metadata map[string]string
+
+func (m metadata) Get(key string) string { return m[key] }
+func (m metadata) Set(key, value string) { m[key] = value }
+
+func (m metadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
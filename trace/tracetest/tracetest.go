@@ -0,0 +1,150 @@
+/*
+Package tracetest provides an in-memory tracer provider and propagator that record the
+spans started by the Ensign SDK so that tests can verify tracing instrumentation
+without an OpenTelemetry exporter.
+*/
+package tracetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rotationalio/go-ensign/trace"
+)
+
+// TraceKey is the carrier key that the Recorder propagates trace context with.
+const TraceKey = "tracetest"
+
+// Recorder implements trace.TracerProvider, trace.Tracer, and trace.Propagator and
+// records every span that is started so that they can be inspected by tests.
+type Recorder struct {
+	sync.Mutex
+	spans []*Span
+	ids   uint64
+}
+
+// Span is a recorded span. Trace and span IDs are assigned sequentially by the
+// recorder; spans continued from an extracted trace context share the trace ID of the
+// remote span and have the remote span as their parent.
+type Span struct {
+	Name       string
+	TraceID    uint64
+	SpanID     uint64
+	ParentID   uint64
+	Attributes map[string]interface{}
+	Errors     []error
+	Ended      bool
+	rec        *Recorder
+}
+
+type spanKey struct{}
+
+var (
+	_ trace.TracerProvider = &Recorder{}
+	_ trace.Tracer         = &Recorder{}
+	_ trace.Propagator     = &Recorder{}
+	_ trace.Span           = &Span{}
+)
+
+// NewRecorder returns a recorder with no spans.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Tracer returns the recorder; all tracers share the recorded spans.
+func (r *Recorder) Tracer(string) trace.Tracer {
+	return r
+}
+
+// Start a span that is a child of the span in the context, if any.
+func (r *Recorder) Start(ctx context.Context, spanName string, attrs ...trace.Attribute) (context.Context, trace.Span) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.ids++
+	span := &Span{Name: spanName, TraceID: r.ids, SpanID: r.ids, Attributes: make(map[string]interface{}), rec: r}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	}
+
+	for _, attr := range attrs {
+		span.Attributes[attr.Key] = attr.Value
+	}
+
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Inject the trace and span ID of the span in the context into the carrier.
+func (r *Recorder) Inject(ctx context.Context, carrier trace.Carrier) {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		carrier.Set(TraceKey, fmt.Sprintf("%d-%d", span.TraceID, span.SpanID))
+	}
+}
+
+// Extract the trace and span ID from the carrier as the parent of subsequent spans.
+func (r *Recorder) Extract(ctx context.Context, carrier trace.Carrier) context.Context {
+	remote := &Span{}
+	if _, err := fmt.Sscanf(carrier.Get(TraceKey), "%d-%d", &remote.TraceID, &remote.SpanID); err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, remote)
+}
+
+// Spans returns all of the spans that have been started in the order they started.
+func (r *Recorder) Spans() []*Span {
+	r.Lock()
+	defer r.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
+
+// Find returns the spans that have been started with the specified name.
+func (r *Recorder) Find(name string) (spans []*Span) {
+	r.Lock()
+	defer r.Unlock()
+	for _, span := range r.spans {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Reset removes all recorded spans.
+func (r *Recorder) Reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.spans = nil
+}
+
+// SetAttributes on the span, overwriting existing attributes with the same key.
+func (s *Span) SetAttributes(attrs ...trace.Attribute) {
+	s.rec.Lock()
+	defer s.rec.Unlock()
+	for _, attr := range attrs {
+		s.Attributes[attr.Key] = attr.Value
+	}
+}
+
+// RecordError appends the error to the errors of the span.
+func (s *Span) RecordError(err error) {
+	s.rec.Lock()
+	defer s.rec.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+// End the span.
+func (s *Span) End() {
+	s.rec.Lock()
+	defer s.rec.Unlock()
+	s.Ended = true
+}
+
+// IsEnded returns true if the span has been ended; safe to call concurrently.
+func (s *Span) IsEnded() bool {
+	s.rec.Lock()
+	defer s.rec.Unlock()
+	return s.Ended
+}
@@ -0,0 +1,64 @@
+package ensign
+
+import (
+	"context"
+
+	"github.com/rotationalio/go-ensign/trace"
+)
+
+// TracerName is the name of the tracer used to instrument the Ensign client.
+const TracerName = "github.com/rotationalio/go-ensign"
+
+// Names of the spans started by the client when tracing is configured. Unary RPCs to
+// Ensign are traced with spans named by the full gRPC method.
+const (
+	PublishSpanName = "ensign.publish"
+	ReceiveSpanName = "ensign.receive"
+	HandleSpanName  = "ensign.handle"
+)
+
+// Returns the tracer and propagator configured by the options; noops are returned if
+// tracing or propagation is not configured.
+func (o *Options) tracing() (tracer trace.Tracer, propagator trace.Propagator) {
+	if o.TracerProvider != nil {
+		tracer = o.TracerProvider.Tracer(TracerName)
+	} else {
+		tracer = trace.NewNoopTracerProvider().Tracer(TracerName)
+	}
+
+	if o.TracePropagator != nil {
+		propagator = o.TracePropagator
+	} else {
+		propagator = trace.NewNoopPropagator()
+	}
+	return tracer, propagator
+}
+
+// Starts a span for publishing the event and injects the trace context of the span
+// into the event metadata so that subscribers can continue the trace.
+func (c *Client) startPublishSpan(topic string, event *Event) trace.Span {
+	ctx, span := c.tracer.Start(event.Context(), PublishSpanName, trace.Attr("ensign.topic", topic))
+
+	// Only create metadata if there is trace context to propagate.
+	if event.Metadata == nil {
+		meta := make(Metadata)
+		c.propagator.Inject(ctx, meta)
+		if len(meta) > 0 {
+			event.Metadata = meta
+		}
+		return span
+	}
+
+	c.propagator.Inject(ctx, event.Metadata)
+	return span
+}
+
+// Starts a span for receiving the event from the subscribe stream that continues the
+// trace propagated in the event metadata; the context of the event is set to the span
+// context so that handlers can create child spans.
+func (c *Subscription) startReceiveSpan(event *Event) trace.Span {
+	ctx := c.propagator.Extract(context.Background(), event.Metadata)
+	ctx, span := c.tracer.Start(ctx, ReceiveSpanName, trace.Attr("ensign.topic_id", event.TopicID()), trace.Attr("ensign.event_id", event.ID()))
+	event.SetContext(ctx)
+	return span
+}
@@ -0,0 +1,112 @@
+package ensign_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/trace/tracetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracing(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	recorder := tracetest.NewRecorder()
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithTracerProvider(recorder, recorder))
+	require.NoError(t, err, "could not create client")
+
+	// Unary RPCs to Ensign should be traced
+	topicID := ulid.MustParse("01GWM89049D49FHJH81BT8795H")
+	emock.OnCreateTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		return &api.Topic{Id: topicID[:], Name: in.Name}, nil
+	}
+
+	_, err = client.CreateTopic(context.Background(), "testing.topics.topica")
+	require.NoError(t, err, "could not create topic")
+
+	spans := recorder.Find(mock.CreateTopicRPC)
+	require.Len(t, spans, 1, "expected create topic rpc to be traced")
+	require.True(t, spans[0].IsEnded())
+	require.Equal(t, "OK", spans[0].Attributes["rpc.grpc.status_code"])
+
+	// Published events should be traced and the trace context added to the metadata
+	published := make(chan *api.EventWrapper, 1)
+	pubHandler := mock.NewPublishHandler(nil)
+	onEvent := pubHandler.OnEvent
+	pubHandler.OnEvent = func(in *api.EventWrapper) (*api.PublisherReply, error) {
+		published <- in
+		return onEvent(in)
+	}
+	emock.OnPublish = pubHandler.OnPublish
+
+	event := NewEvent()
+	require.NoError(t, client.Publish(topicID.String(), event), "could not publish event")
+	_, err = event.Wait()
+	require.NoError(t, err, "event was not acked")
+
+	spans = recorder.Find(sdk.PublishSpanName)
+	require.Len(t, spans, 1, "expected publish to be traced")
+	pubSpan := spans[0]
+	require.True(t, pubSpan.IsEnded())
+	require.Equal(t, topicID.String(), pubSpan.Attributes["ensign.topic"])
+	require.NotEmpty(t, event.Metadata.Get(tracetest.TraceKey), "expected trace context in metadata")
+
+	// Subscribers should continue the trace propagated in the event metadata
+	subHandler := mock.NewSubscribeHandler()
+	emock.OnSubscribe = subHandler.OnSubscribe
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	sub, err := client.Subscribe("testing.topics.topica")
+	require.NoError(t, err, "could not subscribe")
+	defer sub.Close()
+	defer subHandler.Shutdown()
+
+	wrapper := mock.NewEventWrapper()
+	select {
+	case in := <-published:
+		wrapper.TopicId = in.TopicId
+		wrapper.Event = in.Event
+	case <-time.After(time.Second):
+		t.Fatal("expected event to be published")
+	}
+	subHandler.Send <- wrapper
+
+	handled := make(chan context.Context, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sub.Run(ctx, func(event *sdk.Event) error {
+		handled <- event.Context()
+		event.Ack()
+		return nil
+	})
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("expected event to be handled")
+	}
+	cancel()
+
+	spans = recorder.Find(sdk.ReceiveSpanName)
+	require.Len(t, spans, 1, "expected receive to be traced")
+	recvSpan := spans[0]
+	require.Equal(t, pubSpan.TraceID, recvSpan.TraceID, "expected receive span to continue the publish trace")
+	require.Equal(t, pubSpan.SpanID, recvSpan.ParentID)
+
+	require.Eventually(t, func() bool {
+		spans := recorder.Find(sdk.HandleSpanName)
+		return len(spans) == 1 && spans[0].IsEnded()
+	}, time.Second, 10*time.Millisecond, "expected handler to be traced")
+
+	handleSpan := recorder.Find(sdk.HandleSpanName)[0]
+	require.Equal(t, pubSpan.TraceID, handleSpan.TraceID)
+	require.Equal(t, recvSpan.SpanID, handleSpan.ParentID, "expected handle span to be a child of the receive span")
+
+	require.NoError(t, client.Close())
+}
@@ -0,0 +1,43 @@
+package ensign
+
+import "time"
+
+// ExpiresAtKey is the metadata key that stores the time an event expires as an RFC3339
+// timestamp with nanosecond precision. Events with this key can be dropped by
+// subscriptions created WithDropExpired once the timestamp has passed.
+const ExpiresAtKey = "expires_at"
+
+// SetTTL marks the event as expiring after the specified duration relative to the
+// event's created timestamp, or relative to now if the created timestamp is not set.
+// Time-sensitive events (e.g. presence or price quotes) should be given a TTL so that
+// consumers can skip stale events rather than processing them. The expiration is
+// stored in the event metadata using the ExpiresAtKey and is only a convention; events
+// are not expired by the Ensign server.
+func (e *Event) SetTTL(ttl time.Duration) {
+	created := e.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	e.SetExpires(created.Add(ttl))
+}
+
+// SetExpires marks the event as expiring at the specified time. See SetTTL for details.
+func (e *Event) SetExpires(expires time.Time) {
+	if e.Metadata == nil {
+		e.Metadata = make(Metadata)
+	}
+	e.Metadata.SetTime(ExpiresAtKey, expires)
+}
+
+// Expires returns the time the event expires at and true if the event has an expiration
+// set in its metadata. If the expiration is not set or cannot be parsed, false is
+// returned.
+func (e *Event) Expires() (time.Time, bool) {
+	return e.Metadata.GetTime(ExpiresAtKey)
+}
+
+// Expired returns true if the event has an expiration that is in the past.
+func (e *Event) Expired() bool {
+	expires, ok := e.Expires()
+	return ok && time.Now().After(expires)
+}
@@ -0,0 +1,39 @@
+package ensign_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotationalio/go-ensign"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTTL(t *testing.T) {
+	event := &ensign.Event{Data: []byte("foo")}
+	_, ok := event.Expires()
+	require.False(t, ok, "expected no expiration on a new event")
+	require.False(t, event.Expired(), "events without an expiration should never expire")
+
+	// TTL should be relative to now if the created timestamp is not set
+	before := time.Now()
+	event.SetTTL(time.Minute)
+	expires, ok := event.Expires()
+	require.True(t, ok)
+	require.False(t, expires.Before(before.Add(time.Minute)))
+	require.False(t, event.Expired())
+
+	// TTL should be relative to the created timestamp if it is set
+	event.Created = time.Date(2023, 7, 14, 12, 0, 0, 0, time.UTC)
+	event.SetTTL(30 * time.Second)
+	require.Equal(t, "2023-07-14T12:00:30Z", event.Metadata.Get(ensign.ExpiresAtKey))
+	expires, ok = event.Expires()
+	require.True(t, ok)
+	require.True(t, expires.Equal(event.Created.Add(30*time.Second)))
+	require.True(t, event.Expired())
+
+	// Unparseable expirations are ignored
+	event.Metadata.Set(ensign.ExpiresAtKey, "tomorrow")
+	_, ok = event.Expires()
+	require.False(t, ok)
+	require.False(t, event.Expired())
+}
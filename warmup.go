@@ -0,0 +1,195 @@
+package ensign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// Names of the checks performed by Warmup in the order they are performed.
+const (
+	WarmupAuthenticate = "authenticate"
+	WarmupStatus       = "status"
+	WarmupTopics       = "topics"
+	WarmupPublisher    = "publisher"
+	WarmupSubscriber   = "subscriber"
+)
+
+// Readiness is a report of the checks performed by Warmup, describing if the client is
+// ready to publish and subscribe to the warmed up topics.
+type Readiness struct {
+	// The checks performed by warmup in the order they were performed.
+	Checks []WarmupCheck
+
+	// The status of the Ensign service if the status check was successful.
+	Status *api.ServiceState
+
+	// The topic IDs of the topics passed to Warmup, mapped by the topic name or ID.
+	Topics map[string]string
+}
+
+// WarmupCheck is the result of a single check performed by Warmup. If a prior check
+// failed then the check is skipped and the error is nil.
+type WarmupCheck struct {
+	Name     string
+	Skipped  bool
+	Duration time.Duration
+	Err      error
+}
+
+// Warmup prepares the client to publish and subscribe to the specified topics so that
+// services can fail fast at startup if Ensign is unreachable or misconfigured rather
+// than when the first event is published. Warmup authenticates with Quarterdeck,
+// verifies that the Ensign service is healthy, resolves the topic IDs of the topics
+// (creating any topics that do not exist unless the client is read-only), and opens
+// the publish stream of the client so that it is ready for the first call to Publish.
+// If the client was created WithWarmupSubscriber, a subscription to the topics is also
+// opened and closed to verify that the client can subscribe to them.
+//
+// Warmup stops at the first check that fails, skipping the remaining checks, and
+// returns the readiness report along with an error that wraps ErrNotReady. The report
+// is returned even if there is an error so that it can be logged by the caller.
+func (c *Client) Warmup(ctx context.Context, topics ...string) (report *Readiness, err error) {
+	report = &Readiness{
+		Checks: make([]WarmupCheck, 0, 5),
+		Topics: make(map[string]string, len(topics)),
+	}
+
+	checks := []struct {
+		name string
+		skip bool
+		run  func(context.Context, *Readiness) error
+	}{
+		{WarmupAuthenticate, c.opts.NoAuthentication, c.warmupAuth},
+		{WarmupStatus, false, c.warmupStatus},
+		{WarmupTopics, len(topics) == 0, func(ctx context.Context, report *Readiness) error {
+			return c.warmupTopics(ctx, report, topics)
+		}},
+		{WarmupPublisher, c.opts.ReadOnly, c.warmupPublisher},
+		{WarmupSubscriber, !c.opts.WarmupSubscriber || len(topics) == 0, c.warmupSubscriber},
+	}
+
+	for _, check := range checks {
+		result := WarmupCheck{Name: check.name, Skipped: check.skip || err != nil}
+		if !result.Skipped {
+			start := time.Now()
+			result.Err = check.run(ctx, report)
+			result.Duration = time.Since(start)
+
+			if result.Err != nil {
+				err = fmt.Errorf("%w: %s check failed: %s", ErrNotReady, check.name, result.Err)
+			}
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report, err
+}
+
+// Ready returns true if all of the checks that were not skipped succeeded.
+func (r *Readiness) Ready() bool {
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Check returns the result of the check with the specified name and false if the
+// check is not in the report.
+func (r *Readiness) Check(name string) (WarmupCheck, bool) {
+	for _, check := range r.Checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return WarmupCheck{}, false
+}
+
+// String returns a single line summary of the readiness report for logging.
+func (r *Readiness) String() string {
+	checks := make([]string, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		checks = append(checks, check.Name+"="+check.String())
+	}
+	return strings.Join(checks, " ")
+}
+
+// String returns ok, skipped, or the error of the check.
+func (c WarmupCheck) String() string {
+	switch {
+	case c.Skipped:
+		return "skipped"
+	case c.Err != nil:
+		return fmt.Sprintf("%q", c.Err.Error())
+	default:
+		return "ok"
+	}
+}
+
+// Ensure the client has valid access tokens, logging in with the API key if necessary.
+func (c *Client) warmupAuth(ctx context.Context, _ *Readiness) (err error) {
+	_, err = c.auth.Credentials(ctx)
+	return err
+}
+
+// Ensure that the Ensign service is reachable and healthy.
+func (c *Client) warmupStatus(ctx context.Context, report *Readiness) (err error) {
+	if report.Status, err = c.Status(ctx); err != nil {
+		return err
+	}
+
+	if report.Status.Status != api.ServiceState_HEALTHY {
+		return fmt.Errorf("ensign service is %s", report.Status.Status)
+	}
+	return nil
+}
+
+// Resolve the topic IDs of the topics, creating the topics that don't exist.
+func (c *Client) warmupTopics(ctx context.Context, report *Readiness, topics []string) (err error) {
+	for _, topic := range topics {
+		if _, perr := ulid.Parse(topic); perr == nil {
+			report.Topics[topic] = topic
+			continue
+		}
+
+		var topicID string
+		if topicID, err = c.TopicID(ctx, topic); err != nil {
+			if !errors.Is(err, ErrTopicNameNotFound) || c.opts.ReadOnly {
+				return fmt.Errorf("could not resolve topic %q: %w", topic, err)
+			}
+
+			if topicID, err = c.CreateTopic(ctx, topic); err != nil {
+				return fmt.Errorf("could not create topic %q: %w", topic, err)
+			}
+		}
+		report.Topics[topic] = topicID
+	}
+	return nil
+}
+
+// Open the publish stream of the client so that it is ready to publish.
+func (c *Client) warmupPublisher(ctx context.Context, _ *Readiness) (err error) {
+	_, err = c.publisher()
+	return err
+}
+
+// Open and close a subscription to the topics to verify the client can subscribe.
+func (c *Client) warmupSubscriber(ctx context.Context, report *Readiness) (err error) {
+	topics := make([]string, 0, len(report.Topics))
+	for _, topicID := range report.Topics {
+		topics = append(topics, topicID)
+	}
+
+	var sub *Subscription
+	if sub, err = c.CreateSubscriber(topics); err != nil {
+		return err
+	}
+	return sub.Close()
+}
@@ -0,0 +1,91 @@
+package ensign_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmup(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithWarmupSubscriber())
+	require.NoError(t, err, "could not create client")
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_HEALTHY, Version: "0.12.0"}, nil
+	}
+	require.NoError(t, emock.UseFixture(mock.TopicNamesRPC, "testdata/topicnames.pb.json"))
+
+	created := ulid.MustParse("01H1PA51X8WP0VEHVQ3VBEH1VH")
+	emock.OnCreateTopic = func(_ context.Context, in *api.Topic) (*api.Topic, error) {
+		require.Equal(t, "testing.topics.created", in.Name)
+		return &api.Topic{Id: created.Bytes(), Name: in.Name}, nil
+	}
+
+	emock.OnPublish = mock.NewPublishHandler(nil).OnPublish
+
+	subscriber := mock.NewSubscribeHandler()
+	emock.OnSubscribe = subscriber.OnSubscribe
+
+	report, err := client.Warmup(context.Background(), "testing.topics.topica", "testing.topics.created", "01GWM936SNSN36JKTMSF9Q3N8B")
+	require.NoError(t, err, "expected warmup to succeed")
+	require.True(t, report.Ready())
+	require.Equal(t, "0.12.0", report.Status.Version)
+	require.Equal(t, map[string]string{
+		"testing.topics.topica":      "01GWM89049D49FHJH81BT8795H",
+		"testing.topics.created":     created.String(),
+		"01GWM936SNSN36JKTMSF9Q3N8B": "01GWM936SNSN36JKTMSF9Q3N8B",
+	}, report.Topics)
+	require.Equal(t, "authenticate=skipped status=ok topics=ok publisher=ok subscriber=ok", report.String())
+
+	require.Equal(t, 1, emock.Calls[mock.StatusRPC])
+	require.Equal(t, 1, emock.Calls[mock.CreateTopicRPC])
+	require.Equal(t, 1, emock.Calls[mock.PublishRPC])
+	require.Equal(t, 1, emock.Calls[mock.SubscribeRPC])
+
+	// The publish stream should already be open when publishing
+	stats := client.PublishStats()
+	require.Zero(t, stats.Events)
+
+	subscriber.Shutdown()
+	require.NoError(t, client.Close())
+}
+
+func TestWarmupNotReady(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		return &api.ServiceState{Status: api.ServiceState_MAINTENANCE}, nil
+	}
+
+	report, err := client.Warmup(context.Background(), "testing.topics.topica")
+	require.ErrorIs(t, err, sdk.ErrNotReady)
+	require.EqualError(t, err, "client is not ready: status check failed: ensign service is MAINTENANCE")
+	require.False(t, report.Ready())
+
+	check, ok := report.Check(sdk.WarmupStatus)
+	require.True(t, ok)
+	require.Error(t, check.Err)
+
+	// Checks after the failing check should be skipped
+	for _, name := range []string{sdk.WarmupTopics, sdk.WarmupPublisher, sdk.WarmupSubscriber} {
+		check, ok = report.Check(name)
+		require.True(t, ok)
+		require.True(t, check.Skipped, "expected %s check to be skipped", name)
+	}
+
+	require.Zero(t, emock.Calls[mock.TopicNamesRPC])
+	require.Zero(t, emock.Calls[mock.PublishRPC])
+}
@@ -0,0 +1,223 @@
+package ensign
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+)
+
+// TopicChangeType describes how a topic changed between two polls of the project.
+type TopicChangeType uint8
+
+const (
+	TopicCreated   TopicChangeType = iota + 1 // the topic was added to the project
+	TopicArchived                             // the topic became read-only
+	TopicDestroyed                            // the topic was removed or is being deleted
+)
+
+// TopicChange is emitted by WatchTopics when a topic is created, archived, or
+// destroyed. The Topic is the most recent version of the topic returned by the server;
+// for destroyed topics that have been removed from the project, it is the last version
+// of the topic that was observed.
+type TopicChange struct {
+	Type  TopicChangeType
+	Topic *api.Topic
+}
+
+// WatchTopics polls the topics in the project at the specified interval and emits a
+// TopicChange on the returned channel for each topic that is created, archived, or
+// destroyed between polls. Topics that exist when the watch is started do not generate
+// changes. The topics are listed once before this method returns so that an error is
+// returned if the client cannot list topics; errors on subsequent polls are ignored and
+// the topics are listed again on the next interval. The channel is closed when the
+// context is done. If the client has a topic cache, created topics are added to the
+// cache and destroyed topics are removed from it.
+func (c *Client) WatchTopics(ctx context.Context, interval time.Duration) (_ <-chan TopicChange, err error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	var snapshot map[ulid.ULID]*api.Topic
+	if snapshot, err = c.snapshotTopics(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, topic := range snapshot {
+		c.cacheWatched(TopicCreated, topic)
+	}
+
+	changes := make(chan TopicChange, 1)
+	go c.watchTopics(ctx, interval, snapshot, changes)
+	return changes, nil
+}
+
+func (c *Client) watchTopics(ctx context.Context, interval time.Duration, snapshot map[ulid.ULID]*api.Topic, changes chan<- TopicChange) {
+	defer close(changes)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := c.snapshotTopics(ctx)
+		if err != nil {
+			continue
+		}
+
+		for _, change := range diffTopics(snapshot, current) {
+			c.cacheWatched(change.Type, change.Topic)
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+		snapshot = current
+	}
+}
+
+// WatchStatus polls the status of Ensign at the specified interval and calls the
+// callback with the service state whenever the status changes, e.g. when Ensign goes
+// from HEALTHY to UNHEALTHY or into MAINTENANCE, so that applications can shed load or
+// alert during Ensign maintenance windows. The callback is called with the first state
+// that is observed and then on every transition; if the status cannot be retrieved
+// within the interval (e.g. because Ensign is unreachable) the callback is called with
+// an OFFLINE state. The callback is called synchronously so it should return quickly.
+// WatchStatus blocks until the context is done and then returns the context error.
+func (c *Client) WatchStatus(ctx context.Context, interval time.Duration, callback func(*api.ServiceState)) error {
+	if interval <= 0 {
+		return ErrInvalidInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		observed bool
+		status   api.ServiceState_Status
+	)
+
+	for {
+		state, err := c.pollStatus(ctx, interval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			state = &api.ServiceState{Status: api.ServiceState_OFFLINE}
+		}
+
+		if !observed || state.Status != status {
+			observed, status = true, state.Status
+			callback(state)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fetch the status of Ensign, timing out after the poll interval.
+func (c *Client) pollStatus(ctx context.Context, timeout time.Duration) (*api.ServiceState, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return c.Status(ctx)
+}
+
+// List all of the topics in the project, mapping them by topic ID.
+func (c *Client) snapshotTopics(ctx context.Context) (_ map[ulid.ULID]*api.Topic, err error) {
+	snapshot := make(map[ulid.ULID]*api.Topic)
+	iter := c.Topics(ctx)
+	for iter.Next() {
+		topic := iter.Topic()
+
+		var topicID ulid.ULID
+		if err = topicID.UnmarshalBinary(topic.Id); err != nil {
+			return nil, fmt.Errorf("could not parse topic id: %w", err)
+		}
+		snapshot[topicID] = topic
+	}
+
+	if err = iter.Err(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Update the topic cache of the client (if any) with the topic change.
+func (c *Client) cacheWatched(change TopicChangeType, topic *api.Topic) {
+	if c.opts.TopicCache == nil {
+		return
+	}
+
+	switch change {
+	case TopicCreated:
+		var topicID ulid.ULID
+		if !destroyed(topic) && topicID.UnmarshalBinary(topic.Id) == nil {
+			c.opts.TopicCache.Set(topic.Name, topicID.String())
+		}
+	case TopicDestroyed:
+		c.opts.TopicCache.Delete(topic.Name)
+	}
+}
+
+// Compute the changes between two snapshots of the topics in the project.
+func diffTopics(prev, next map[ulid.ULID]*api.Topic) (changes []TopicChange) {
+	for topicID, topic := range next {
+		before, ok := prev[topicID]
+		switch {
+		case !ok:
+			// A topic that is being deleted when first observed only emits destroyed.
+			if destroyed(topic) {
+				changes = append(changes, TopicChange{Type: TopicDestroyed, Topic: topic})
+			} else {
+				changes = append(changes, TopicChange{Type: TopicCreated, Topic: topic})
+				if archived(topic) {
+					changes = append(changes, TopicChange{Type: TopicArchived, Topic: topic})
+				}
+			}
+		case destroyed(topic) && !destroyed(before):
+			changes = append(changes, TopicChange{Type: TopicDestroyed, Topic: topic})
+		case archived(topic) && !archived(before):
+			changes = append(changes, TopicChange{Type: TopicArchived, Topic: topic})
+		}
+	}
+
+	for topicID, topic := range prev {
+		if _, ok := next[topicID]; !ok && !destroyed(topic) {
+			changes = append(changes, TopicChange{Type: TopicDestroyed, Topic: topic})
+		}
+	}
+	return changes
+}
+
+func archived(topic *api.Topic) bool {
+	return topic.Readonly || topic.Status == api.TopicState_READONLY
+}
+
+func destroyed(topic *api.Topic) bool {
+	return topic.Status == api.TopicState_DELETING
+}
+
+// String returns a human readable representation of the topic change type.
+func (t TopicChangeType) String() string {
+	switch t {
+	case TopicCreated:
+		return "created"
+	case TopicArchived:
+		return "archived"
+	case TopicDestroyed:
+		return "destroyed"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,160 @@
+package ensign_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	sdk "github.com/rotationalio/go-ensign"
+	api "github.com/rotationalio/go-ensign/api/v1beta1"
+	"github.com/rotationalio/go-ensign/mock"
+	"github.com/rotationalio/go-ensign/topics"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWatchTopics(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	cache := topics.NewCache(nil)
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true), sdk.WithTopicCache(cache))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	var (
+		mu      sync.Mutex
+		project = []*api.Topic{
+			{Id: ulid.MustParse("01H1PA4FA9G2Y79Z5FC36CWYYJ").Bytes(), Name: "testing.123", Status: api.TopicState_READY},
+			{Id: ulid.MustParse("01H1PA4P7C6VT5KZCXH56H1XHS").Bytes(), Name: "example.456", Status: api.TopicState_READY},
+		}
+	)
+
+	emock.OnListTopics = func(context.Context, *api.PageInfo) (*api.TopicsPage, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &api.TopicsPage{Topics: project}, nil
+	}
+
+	// Updates the topics in the project and waits for the next change.
+	update := func(changes <-chan sdk.TopicChange, topics ...*api.Topic) sdk.TopicChange {
+		mu.Lock()
+		project = topics
+		mu.Unlock()
+
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for topic change")
+		}
+		return sdk.TopicChange{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = client.WatchTopics(ctx, 0)
+	require.ErrorIs(t, err, sdk.ErrInvalidInterval)
+
+	changes, err := client.WatchTopics(ctx, 5*time.Millisecond)
+	require.NoError(t, err, "could not watch topics")
+	require.Equal(t, 2, cache.Length(), "expected existing topics to be cached")
+
+	// A new topic should be emitted and cached
+	created := &api.Topic{Id: ulid.MustParse("01H1PA51X8WP0VEHVQ3VBEH1VH").Bytes(), Name: "created.789", Status: api.TopicState_READY}
+	change := update(changes, project[0], project[1], created)
+	require.Equal(t, sdk.TopicCreated, change.Type)
+	require.Equal(t, "created.789", change.Topic.Name)
+
+	topicID, cached := cache.Lookup("created.789")
+	require.True(t, cached, "expected created topic to be cached")
+	require.Equal(t, "01H1PA51X8WP0VEHVQ3VBEH1VH", topicID)
+
+	// An archived topic should be emitted and remain in the cache
+	archived := &api.Topic{Id: created.Id, Name: created.Name, Readonly: true, Status: api.TopicState_READONLY}
+	change = update(changes, project[0], project[1], archived)
+	require.Equal(t, sdk.TopicArchived, change.Type)
+	require.Equal(t, "created.789", change.Topic.Name)
+	require.Equal(t, 3, cache.Length())
+
+	// A topic that is removed from the project should be emitted and removed from the cache
+	change = update(changes, project[0], archived)
+	require.Equal(t, sdk.TopicDestroyed, change.Type)
+	require.Equal(t, "example.456", change.Topic.Name)
+
+	_, cached = cache.Lookup("example.456")
+	require.False(t, cached, "expected destroyed topic to be evicted from the cache")
+
+	// A topic that is being deleted should be emitted as destroyed
+	deleting := &api.Topic{Id: archived.Id, Name: archived.Name, Status: api.TopicState_DELETING}
+	change = update(changes, project[0], deleting)
+	require.Equal(t, sdk.TopicDestroyed, change.Type)
+	require.Equal(t, "created.789", change.Topic.Name)
+	require.Equal(t, 1, cache.Length())
+
+	// The channel should be closed when the context is canceled
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-changes:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 5*time.Millisecond, "expected changes channel to be closed")
+}
+
+func TestWatchStatus(t *testing.T) {
+	emock := mock.New(nil)
+	defer emock.Shutdown()
+
+	client, err := sdk.New(sdk.WithMock(emock), sdk.WithAuthenticator("", true))
+	require.NoError(t, err, "could not create client")
+	defer client.Close()
+
+	// Each poll returns the next status in the sequence, then the last status forever.
+	var (
+		mu       sync.Mutex
+		sequence = []api.ServiceState_Status{
+			api.ServiceState_HEALTHY, api.ServiceState_HEALTHY, api.ServiceState_MAINTENANCE,
+			api.ServiceState_MAINTENANCE, api.ServiceState_OFFLINE, api.ServiceState_HEALTHY,
+		}
+	)
+
+	emock.OnStatus = func(context.Context, *api.HealthCheck) (*api.ServiceState, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		next := sequence[0]
+		if len(sequence) > 1 {
+			sequence = sequence[1:]
+		}
+
+		// An OFFLINE status simulates a node that cannot be reached.
+		if next == api.ServiceState_OFFLINE {
+			return nil, status.Error(codes.Unavailable, "ensign is unavailable")
+		}
+		return &api.ServiceState{Status: next}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.ErrorIs(t, client.WatchStatus(ctx, 0, nil), sdk.ErrInvalidInterval)
+
+	// Only transitions are passed to the callback
+	var transitions []api.ServiceState_Status
+	err = client.WatchStatus(ctx, 5*time.Millisecond, func(state *api.ServiceState) {
+		transitions = append(transitions, state.Status)
+		if len(transitions) == 4 {
+			cancel()
+		}
+	})
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []api.ServiceState_Status{api.ServiceState_HEALTHY, api.ServiceState_MAINTENANCE, api.ServiceState_OFFLINE, api.ServiceState_HEALTHY}, transitions)
+}